@@ -29,6 +29,7 @@ import (
 	"sync"
 	"fmt"
 	"errors"
+	"time"
 )
 
 // MaxQueueSize is the critical number of messages in the queue that result in an immediate flush.
@@ -48,6 +49,8 @@ type asyncLogger struct {
 	msgQueue         *list.List
 	queueMutex       *sync.Mutex
 	queueHasElements *sync.Cond
+	staleCount       int // Number of stale messages dropped since the last report
+	overload         *overloadState
 }
 
 // newAsyncLogger creates a new asynchronous logger
@@ -59,6 +62,7 @@ func newAsyncLogger(config *logConfig) *asyncLogger {
 	asnLogger.queueHasElements = sync.NewCond(new(sync.Mutex))
 
 	asnLogger.commonLogger = *newCommonLogger(config, asnLogger)
+	asnLogger.overload = newOverloadState(config.OverloadQueue)
 
 	return asnLogger
 }
@@ -77,8 +81,8 @@ func (asnLogger *asyncLogger) Close() {
 
 	if !asnLogger.closed {
 		asnLogger.flushQueue()
-		asnLogger.config.RootDispatcher.Flush()
-		asnLogger.config.RootDispatcher.Close()
+		asnLogger.outputs.Flush()
+		asnLogger.outputs.Close()
 		asnLogger.queueHasElements.Broadcast()
 	}
 }
@@ -89,7 +93,7 @@ func (asnLogger *asyncLogger) Flush() {
 
 	if !asnLogger.closed {
 		asnLogger.flushQueue()
-		asnLogger.config.RootDispatcher.Flush()
+		asnLogger.outputs.Flush()
 	}
 }
 
@@ -97,17 +101,40 @@ func (asnLogger *asyncLogger) flushQueue() {
 	asnLogger.queueHasElements.L.Lock()
 	defer asnLogger.queueHasElements.L.Unlock()
 
+	asnLogger.processQueuedMessages()
+}
+
+// processQueuedMessages processes all queued messages. queueHasElements.L must be held.
+func (asnLogger *asyncLogger) processQueuedMessages() {
 	for asnLogger.msgQueue.Len() > 0 {
 		asnLogger.processQueueElement()
 	}
+	asnLogger.reportStaleMessages()
 }
 
 func (asnLogger *asyncLogger) processQueueElement() {
 	if asnLogger.msgQueue.Len() > 0 {
 		backElement := asnLogger.msgQueue.Front()
 		msg, _ := backElement.Value.(msgQueueItem)
-		asnLogger.processLogMsg(msg.level, msg.message, msg.context)
 		asnLogger.msgQueue.Remove(backElement)
+
+		if asnLogger.config.QueueAge.isStale(msg.level, msg.context, time.Now()) {
+			asnLogger.staleCount++
+			asnLogger.overload.update(asnLogger.msgQueue.Len(), true)
+			return
+		}
+		asnLogger.reportStaleMessages()
+		asnLogger.processLogMsg(msg.level, msg.message, msg.context)
+		asnLogger.overload.update(asnLogger.msgQueue.Len(), false)
+	}
+}
+
+// reportStaleMessages reports the number of stale messages dropped since the last report.
+func (asnLogger *asyncLogger) reportStaleMessages() {
+	if asnLogger.staleCount > 0 {
+		reportInternalError(fmt.Errorf("Dropped %d messages which waited in the queue longer than %s",
+			asnLogger.staleCount, asnLogger.config.QueueAge.MaxAge))
+		asnLogger.staleCount = 0
 	}
 }
 
@@ -119,17 +146,20 @@ func (asnLogger *asyncLogger) addMsgToQueue(
 	defer asnLogger.queueMutex.Unlock()
 
 	if !asnLogger.closed {
+		// The queue is changed by the processing loop under queueHasElements.L
+		asnLogger.queueHasElements.L.Lock()
+		defer asnLogger.queueHasElements.L.Unlock()
+
 		if asnLogger.msgQueue.Len() >= MaxQueueSize {
 			fmt.Printf("Seelog queue overflow: more than %v messages in the queue. Flushing.\n", MaxQueueSize)
-			asnLogger.flushQueue()
+			asnLogger.processQueuedMessages()
 		}
-		
-		queueItem := msgQueueItem{level, context, message}
 
-		asnLogger.queueHasElements.L.Lock()
-		defer asnLogger.queueHasElements.L.Unlock()
+		context.setQueueDepth(asnLogger.msgQueue.Len())
+		queueItem := msgQueueItem{level, context, message}
 
 		asnLogger.msgQueue.PushBack(queueItem)
+		asnLogger.overload.update(asnLogger.msgQueue.Len(), false)
 		asnLogger.queueHasElements.Broadcast()
 	} else {
 		err := errors.New(fmt.Sprintf("Queue closed! Cannot process element: %d %#v", level, message))
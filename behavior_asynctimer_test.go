@@ -25,8 +25,11 @@
 package seelog
 
 import (
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func Test_Asynctimer(t *testing.T) {
@@ -81,3 +84,63 @@ func Test_Asynctimer(t *testing.T) {
 
 	Current.Close()
 }
+
+func Test_AsynctimerMaxQueueAge(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "beh_test_asynctimer_age.log")
+
+	testConfig := `
+<seelog type="asynctimer" asyncinterval="4000000000" maxqueueage="1ms">
+	<outputs formatid="msg">
+		<file path="` + fileName + `"/>
+	</outputs>
+	<formats>
+		<format id="msg" format="%Msg%n"/>
+	</formats>
+</seelog>`
+
+	logger, err := LoggerFromConfigAsString(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	// The first message is processed at once, the next ones wait for the interval
+	logger.Info("first")
+	time.Sleep(10 * time.Millisecond)
+
+	logger.Info("stale info")
+	logger.Debug("stale debug")
+	logger.Error("old error")
+	time.Sleep(20 * time.Millisecond)
+	logger.Info("fresh info")
+	logger.Flush()
+
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "first\nold error\nfresh info\n" {
+		t.Errorf("Unexpected messages: %q", content)
+	}
+}
+
+func TestMaxQueueAgeConfig(t *testing.T) {
+	invalidConfigs := []string{
+		`<seelog type="sync" maxqueueage="1s"/>`,
+		`<seelog maxqueueage="abc"/>`,
+		`<seelog maxqueueage="-1s"/>`,
+		`<seelog maxqueueagelevel="info"/>`,
+		`<seelog maxqueueage="1s" maxqueueagelevel="off"/>`,
+	}
+	for _, config := range invalidConfigs {
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+
+	logger, err := LoggerFromConfigAsString(`<seelog maxqueueage="30s" maxqueueagelevel="warn"/>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// OverloadStatus tells whether an async logger keeps up with the messages it is given.
+// The logger is overloaded when its queue reaches the 'overloadqueue' length of the
+// config (MaxQueueSize by default) or when it sheds messages which waited in the queue
+// longer than 'maxqueueage'. It leaves the overload once the queue is drained.
+type OverloadStatus struct {
+	Overloaded  bool
+	Shedding    bool      // Messages are dropped, see maxqueueage
+	QueueLength int       // Length of the queue when the status changed
+	Since       time.Time // Time of the change; zero for the initial status
+}
+
+// overloadState tracks the overload status of an async logger and notifies its
+// subscribers about changes. The queue lock of the logger serializes the updates.
+type overloadState struct {
+	threshold   int
+	mutex       sync.Mutex
+	status      OverloadStatus
+	subscribers map[*overloadSubscriber]bool
+}
+
+// overloadSubscriber receives status changes in its own goroutine, so callbacks may log
+// and may be slow. A slow subscriber gets only the latest status.
+type overloadSubscriber struct {
+	statuses chan OverloadStatus
+}
+
+func newOverloadState(threshold int) *overloadState {
+	if threshold == 0 {
+		threshold = MaxQueueSize
+	}
+	return &overloadState{threshold: threshold, subscribers: make(map[*overloadSubscriber]bool)}
+}
+
+// update sets the status after a message was queued or processed. shed is true if a
+// message was dropped; a drop which drains the queue is reported as a short overload.
+func (state *overloadState) update(queueLength int, shed bool) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if shed {
+		state.set(true, true, queueLength)
+	}
+	switch {
+	case queueLength == 0:
+		state.set(false, false, queueLength)
+	case queueLength >= state.threshold:
+		state.set(true, state.status.Shedding, queueLength)
+	}
+}
+
+// set changes the status and notifies the subscribers, unless the status is the same.
+func (state *overloadState) set(overloaded bool, shedding bool, queueLength int) {
+	if overloaded == state.status.Overloaded && shedding == state.status.Shedding {
+		return
+	}
+
+	state.status = OverloadStatus{overloaded, shedding, queueLength, time.Now()}
+	for subscriber := range state.subscribers {
+		subscriber.send(state.status)
+	}
+}
+
+func (state *overloadState) subscribe(callback func(OverloadStatus)) func() {
+	subscriber := &overloadSubscriber{make(chan OverloadStatus, 1)}
+	go func() {
+		for status := range subscriber.statuses {
+			callback(status)
+		}
+	}()
+
+	state.mutex.Lock()
+	state.subscribers[subscriber] = true
+	subscriber.send(state.status)
+	state.mutex.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			state.mutex.Lock()
+			delete(state.subscribers, subscriber)
+			close(subscriber.statuses)
+			state.mutex.Unlock()
+		})
+	}
+}
+
+// send replaces a status which was not delivered yet by the given one.
+func (subscriber *overloadSubscriber) send(status OverloadStatus) {
+	select {
+	case <-subscriber.statuses:
+	default:
+	}
+	subscriber.statuses <- status
+}
+
+// overloadNotifier is implemented by loggers which support SubscribeOverload.
+type overloadNotifier interface {
+	subscribeOverload(callback func(OverloadStatus)) func()
+}
+
+func (asnLogger *asyncLogger) subscribeOverload(callback func(OverloadStatus)) func() {
+	return asnLogger.overload.subscribe(callback)
+}
+
+func (fLogger *fieldsLogger) subscribeOverload(callback func(OverloadStatus)) func() {
+	if notifier, ok := fLogger.logger.(overloadNotifier); ok {
+		return notifier.subscribeOverload(callback)
+	}
+	return nil
+}
+
+// SubscribeOverload calls callback whenever an async logger enters or leaves the overload,
+// so services can report degraded logging in their health checks. The callback is called
+// with the current status right away, and then on every change, in a goroutine of the
+// subscription; a callback which is slower than the changes gets only the latest status.
+// Sync loggers have no queue and can not be subscribed.
+//
+// Example:
+//     unsubscribe, err := seelog.SubscribeOverload(logger, func(status seelog.OverloadStatus) {
+//         health.SetDegraded("logging", status.Overloaded)
+//     })
+func SubscribeOverload(logger LoggerInterface, callback func(OverloadStatus)) (unsubscribe func(), err error) {
+	if callback == nil {
+		return nil, errors.New("Callback can not be nil")
+	}
+
+	if notifier, ok := logger.(overloadNotifier); ok {
+		if unsubscribe := notifier.subscribeOverload(callback); unsubscribe != nil {
+			return unsubscribe, nil
+		}
+	}
+	return nil, errors.New("Logger does not support overload notifications")
+}
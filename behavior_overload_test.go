@@ -0,0 +1,138 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// overloadRecorder keeps the latest status received by a subscription.
+type overloadRecorder struct {
+	mutex  sync.Mutex
+	status *OverloadStatus
+}
+
+func (recorder *overloadRecorder) record(status OverloadStatus) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.status = &status
+}
+
+func (recorder *overloadRecorder) waitFor(t *testing.T, overloaded bool) OverloadStatus {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		recorder.mutex.Lock()
+		status := recorder.status
+		recorder.mutex.Unlock()
+		if status != nil && status.Overloaded == overloaded {
+			return *status
+		}
+	}
+	t.Fatalf("Expected the overloaded status %t", overloaded)
+	return OverloadStatus{}
+}
+
+func TestSubscribeOverload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "overload.log")
+	logger, err := LoggerFromConfigAsString(`
+<seelog type="asynctimer" asyncinterval="4000000000" overloadqueue="3">
+	<outputs>
+		<file path="` + fileName + `"/>
+	</outputs>
+</seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	recorder := new(overloadRecorder)
+	unsubscribe, err := SubscribeOverload(LoggerWithFields(logger, Str("k", "v")), recorder.record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+	if status := recorder.waitFor(t, false); !status.Since.IsZero() {
+		t.Errorf("Unexpected initial status: %+v", status)
+	}
+
+	// The first message is processed at once, the next ones wait for the interval
+	logger.Info("first")
+	time.Sleep(10 * time.Millisecond)
+	logger.Info("second")
+	logger.Info("third")
+	logger.Info("fourth")
+
+	status := recorder.waitFor(t, true)
+	if status.Shedding || status.QueueLength != 3 || status.Since.IsZero() {
+		t.Errorf("Unexpected overload status: %+v", status)
+	}
+
+	logger.Flush()
+	recorder.waitFor(t, false)
+}
+
+func TestOverloadShedding(t *testing.T) {
+	state := newOverloadState(0)
+	if state.threshold != MaxQueueSize {
+		t.Errorf("Unexpected default threshold: %d", state.threshold)
+	}
+
+	state.update(10, true)
+	if !state.status.Overloaded || !state.status.Shedding {
+		t.Errorf("A dropped message must start shedding: %+v", state.status)
+	}
+	state.update(5, false)
+	if !state.status.Shedding {
+		t.Errorf("Shedding must last until the queue is drained: %+v", state.status)
+	}
+	state.update(0, true)
+	if state.status.Overloaded || state.status.Shedding {
+		t.Errorf("A drained queue must end the overload: %+v", state.status)
+	}
+}
+
+func TestSubscribeOverloadErrors(t *testing.T) {
+	logger, err := LoggerFromConfigAsString(`<seelog type="sync"><outputs><console/></outputs></seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	if _, err := SubscribeOverload(logger, func(OverloadStatus) {}); err == nil {
+		t.Error("Expected an error for a sync logger")
+	}
+
+	invalidConfigs := []string{
+		`<seelog type="sync" overloadqueue="10"/>`,
+		`<seelog overloadqueue="0"/>`,
+		`<seelog overloadqueue="1000000"/>`,
+	}
+	for _, config := range invalidConfigs {
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -53,12 +53,12 @@ func (cLogger *syncLogger) innerLog(
 
 func (syncLogger *syncLogger) Close() {
 	if !syncLogger.closed {
-		syncLogger.config.RootDispatcher.Close()
+		syncLogger.outputs.Close()
 	}
 }
 
 func (syncLogger *syncLogger) Flush() {
 	if !syncLogger.closed {
-		syncLogger.config.RootDispatcher.Flush()
+		syncLogger.outputs.Flush()
 	}
 }
@@ -0,0 +1,304 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigBuilder builds a logger config in Go code. It produces the same config
+// as the equivalent xml, so all config rules apply.
+//
+// Example:
+//     logger, err := seelog.NewConfig().
+//         Sync().
+//         MinLevel(seelog.InfoLvl).
+//         Format("%Date %Time [%LEVEL] %Msg%n").
+//         AddConsole().
+//         AddRollingFile("app.log", 10*1024*1024, 5).
+//         AddFile("errors.log").OnlyLevels(seelog.ErrorLvl, seelog.CriticalLvl).
+//         Build()
+type ConfigBuilder struct {
+	root       *xmlNode
+	outputs    *xmlNode
+	formats    *xmlNode
+	exceptions *xmlNode
+	last       *xmlNode // Last added output, changed by WithFormat and OnlyLevels
+	err        error
+}
+
+// NewConfig creates a builder of a config with default settings: asyncloop logger,
+// all levels and no outputs.
+func NewConfig() *ConfigBuilder {
+	builder := &ConfigBuilder{root: newBuilderNode(seelogConfigId)}
+	builder.outputs = newBuilderNode(outputsId)
+	builder.root.add(builder.outputs)
+	return builder
+}
+
+func newBuilderNode(name string, attributes ...string) *xmlNode {
+	node := newNode()
+	node.name = name
+	for i := 0; i+1 < len(attributes); i += 2 {
+		node.attributes[attributes[i]] = attributes[i+1]
+	}
+	return node
+}
+
+func (builder *ConfigBuilder) fail(err error) *ConfigBuilder {
+	if builder.err == nil {
+		builder.err = err
+	}
+	return builder
+}
+
+// Sync makes the logger process messages in the calling goroutine.
+func (builder *ConfigBuilder) Sync() *ConfigBuilder {
+	return builder.loggerType(syncloggerTypeFromStringStr)
+}
+
+// Async makes the logger process messages in a separate goroutine as soon as they arrive.
+func (builder *ConfigBuilder) Async() *ConfigBuilder {
+	return builder.loggerType(asyncloggerTypeFromStringStr)
+}
+
+// AsyncTimer makes the logger process queued messages in a separate goroutine once per interval.
+func (builder *ConfigBuilder) AsyncTimer(interval time.Duration) *ConfigBuilder {
+	builder.loggerType(asyncTimerloggerTypeFromStringStr)
+	builder.root.attributes[asyncLoggerIntervalAttr] = strconv.FormatInt(int64(interval), 10)
+	return builder
+}
+
+// Adaptive makes the logger process queued messages with an interval between min and max,
+// which shrinks as the queue grows up to criticalMsgCount messages.
+func (builder *ConfigBuilder) Adaptive(min time.Duration, max time.Duration, criticalMsgCount int) *ConfigBuilder {
+	builder.loggerType(adaptiveLoggerTypeFromStringStr)
+	builder.root.attributes[adaptLoggerMinIntervalAttr] = strconv.FormatInt(int64(min), 10)
+	builder.root.attributes[adaptLoggerMaxIntervalAttr] = strconv.FormatInt(int64(max), 10)
+	builder.root.attributes[adaptLoggerCriticalMsgCountAttr] = strconv.Itoa(criticalMsgCount)
+	return builder
+}
+
+func (builder *ConfigBuilder) loggerType(loggerType string) *ConfigBuilder {
+	builder.root.attributes[loggerTypeFromStringAttr] = loggerType
+	return builder
+}
+
+// MinLevel sets the minimal level of messages the logger writes.
+func (builder *ConfigBuilder) MinLevel(level LogLevel) *ConfigBuilder {
+	return builder.levelAttribute(builder.root, minLevelId, level)
+}
+
+// MaxLevel sets the maximal level of messages the logger writes.
+func (builder *ConfigBuilder) MaxLevel(level LogLevel) *ConfigBuilder {
+	return builder.levelAttribute(builder.root, maxLevelId, level)
+}
+
+// Levels sets the list of levels the logger writes. It can not be combined with MinLevel and MaxLevel.
+func (builder *ConfigBuilder) Levels(levels ...LogLevel) *ConfigBuilder {
+	return builder.levelsAttribute(builder.root, levelsId, levels)
+}
+
+// CaptureStack makes the logger capture at most depth frames of the caller stack for
+// records of minLevel and above. See StackFrame.
+func (builder *ConfigBuilder) CaptureStack(depth int, minLevel LogLevel) *ConfigBuilder {
+	builder.root.attributes[stackDepthAttr] = strconv.Itoa(depth)
+	return builder.levelAttribute(builder.root, stackLevelAttr, minLevel)
+}
+
+// Exception overrides the minimal level for messages from functions and files matching
+// the patterns. Patterns may contain '*' wildcards; an empty pattern matches everything.
+func (builder *ConfigBuilder) Exception(funcPattern string, filePattern string, minLevel LogLevel) *ConfigBuilder {
+	if builder.exceptions == nil {
+		builder.exceptions = newBuilderNode(exceptionsId)
+		builder.root.add(builder.exceptions)
+	}
+
+	exception := newBuilderNode(exceptionId)
+	if funcPattern != "" {
+		exception.attributes[funcPatternId] = funcPattern
+	}
+	if filePattern != "" {
+		exception.attributes[filePatternId] = filePattern
+	}
+	builder.exceptions.add(exception)
+
+	return builder.levelAttribute(exception, minLevelId, minLevel)
+}
+
+// PackageLevel overrides the minimal level for messages from the packages with import
+// paths matching the pattern. A pattern ending with "/*" matches a package and all
+// packages below it.
+//
+// Example:
+//     log.NewConfig().MinLevel(log.InfoLvl).PackageLevel("github.com/me/app/db/*", log.DebugLvl)
+func (builder *ConfigBuilder) PackageLevel(packagePattern string, minLevel LogLevel) *ConfigBuilder {
+	if builder.exceptions == nil {
+		builder.exceptions = newBuilderNode(exceptionsId)
+		builder.root.add(builder.exceptions)
+	}
+
+	exception := newBuilderNode(exceptionId, packagePatternId, packagePattern)
+	builder.exceptions.add(exception)
+
+	return builder.levelAttribute(exception, minLevelId, minLevel)
+}
+
+// Format sets the default format of all outputs.
+func (builder *ConfigBuilder) Format(format string) *ConfigBuilder {
+	builder.outputs.attributes[outputFormatId] = builder.addFormat(format)
+	return builder
+}
+
+// WithFormat sets the format of the last added output.
+func (builder *ConfigBuilder) WithFormat(format string) *ConfigBuilder {
+	if builder.last == nil {
+		return builder.fail(errors.New("WithFormat must follow an Add call"))
+	}
+
+	builder.last.attributes[outputFormatId] = builder.addFormat(format)
+	return builder
+}
+
+// OnlyLevels makes the last added output receive only messages with the given levels.
+func (builder *ConfigBuilder) OnlyLevels(levels ...LogLevel) *ConfigBuilder {
+	if builder.last == nil {
+		return builder.fail(errors.New("OnlyLevels must follow an Add call"))
+	}
+
+	filter := newBuilderNode(filterDispatcherId)
+	builder.levelsAttribute(filter, filterLevelsAttrId, levels)
+
+	for i, child := range builder.outputs.children {
+		if child == builder.last {
+			builder.outputs.children[i] = filter
+		}
+	}
+	filter.add(builder.last)
+	return builder
+}
+
+// addFormat declares a format and returns its id. Predefined formats (std:...) are used as is.
+func (builder *ConfigBuilder) addFormat(format string) string {
+	if strings.HasPrefix(format, predefinedPrefix) {
+		return format
+	}
+
+	if builder.formats == nil {
+		builder.formats = newBuilderNode(formatsId)
+		builder.root.add(builder.formats)
+	}
+
+	id := "format" + strconv.Itoa(len(builder.formats.children)+1)
+	builder.formats.add(newBuilderNode(formatId, formatKeyAttrId, id, formatAttrId, format))
+	return id
+}
+
+// AddConsole adds an output to the standard output.
+func (builder *ConfigBuilder) AddConsole() *ConfigBuilder {
+	return builder.addOutput(newBuilderNode(consoleWriterId))
+}
+
+// AddFile adds an output to a file.
+func (builder *ConfigBuilder) AddFile(path string) *ConfigBuilder {
+	return builder.addOutput(newBuilderNode(fileWriterId, pathId, path))
+}
+
+// AddRollingFile adds an output to a file which is rolled when it grows over maxSize bytes.
+// At most maxRolls rolled files are kept.
+func (builder *ConfigBuilder) AddRollingFile(path string, maxSize int64, maxRolls int) *ConfigBuilder {
+	return builder.addOutput(newBuilderNode(rollingfileWriterId,
+		rollingFileTypeAttr, "size",
+		rollingFilePathAttr, path,
+		rollingFileMaxSizeAttr, strconv.FormatInt(maxSize, 10),
+		rollingFileMaxRollsAttr, strconv.Itoa(maxRolls)))
+}
+
+// AddRollingFileByDate adds an output to a file which is rolled when the current time
+// formatted with datePattern changes.
+func (builder *ConfigBuilder) AddRollingFileByDate(path string, datePattern string) *ConfigBuilder {
+	return builder.addOutput(newBuilderNode(rollingfileWriterId,
+		rollingFileTypeAttr, "date",
+		rollingFilePathAttr, path,
+		rollingFileDataPatternAttr, datePattern))
+}
+
+// AddConn adds an output to a network connection.
+func (builder *ConfigBuilder) AddConn(network string, address string, reconnectOnMsg bool) *ConfigBuilder {
+	return builder.addOutput(newBuilderNode(connWriterId,
+		connWriterNetAttr, network,
+		connWriterAddrAttr, address,
+		connWriterReconnectOnMsgAttr, strconv.FormatBool(reconnectOnMsg)))
+}
+
+func (builder *ConfigBuilder) addOutput(output *xmlNode) *ConfigBuilder {
+	builder.outputs.add(output)
+	builder.last = output
+	return builder
+}
+
+func (builder *ConfigBuilder) levelAttribute(node *xmlNode, attr string, level LogLevel) *ConfigBuilder {
+	levelStr := level.String()
+	if levelStr == "" {
+		return builder.fail(errors.New("Invalid level: " + strconv.Itoa(int(level))))
+	}
+
+	node.attributes[attr] = levelStr
+	return builder
+}
+
+func (builder *ConfigBuilder) levelsAttribute(node *xmlNode, attr string, levels []LogLevel) *ConfigBuilder {
+	names := make([]string, len(levels))
+	for i, level := range levels {
+		names[i] = level.String()
+		if names[i] == "" {
+			return builder.fail(errors.New("Invalid level: " + strconv.Itoa(int(level))))
+		}
+	}
+
+	node.attributes[attr] = strings.Join(names, ",")
+	return builder
+}
+
+// Build creates a logger from the config.
+func (builder *ConfigBuilder) Build() (LoggerInterface, error) {
+	conf, err := builder.config()
+	if err != nil {
+		return nil, err
+	}
+
+	return createLoggerFromConfig(conf)
+}
+
+func (builder *ConfigBuilder) config() (*logConfig, error) {
+	if builder.err != nil {
+		return nil, builder.err
+	}
+
+	return configFromRoot(builder.root)
+}
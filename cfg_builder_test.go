@@ -0,0 +1,104 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type builderTest struct {
+	testName string
+	builder  *ConfigBuilder
+	config   string
+}
+
+var builderTests = []builderTest{
+	{"Console",
+		NewConfig().AddConsole(),
+		`<seelog><outputs><console/></outputs></seelog>`},
+	{"Sync levels format",
+		NewConfig().Sync().MinLevel(InfoLvl).MaxLevel(ErrorLvl).Format("%Msg").AddConsole(),
+		`<seelog type="sync" minlevel="info" maxlevel="error">
+			<outputs formatid="format1"><console/></outputs>
+			<formats><format id="format1" format="%Msg"/></formats>
+		</seelog>`},
+	{"Async timer with rolling file",
+		NewConfig().AsyncTimer(100*time.Millisecond).AddRollingFile("log.log", 1024, 3).WithFormat("std:json"),
+		`<seelog type="asynctimer" asyncinterval="100000000">
+			<outputs>
+				<rollingfile type="size" filename="log.log" maxsize="1024" maxrolls="3" formatid="std:json"/>
+			</outputs>
+		</seelog>`},
+	{"Filtered output with exception",
+		NewConfig().Adaptive(time.Millisecond, 10*time.Millisecond, 50).Levels(InfoLvl, ErrorLvl).
+			AddConsole().AddFile("err.log").OnlyLevels(ErrorLvl).
+			Exception("main*", "", CriticalLvl),
+		`<seelog type="adaptive" mininterval="1000000" maxinterval="10000000" critmsgcount="50" levels="info,error">
+			<outputs>
+				<console/>
+				<filter levels="error"><file path="err.log"/></filter>
+			</outputs>
+			<exceptions><exception funcpattern="main*" minlevel="critical"/></exceptions>
+		</seelog>`},
+}
+
+func TestConfigBuilder(t *testing.T) {
+	defer cleanupAfterCfgTest(t)
+
+	for _, test := range builderTests {
+		expected, err := configFromReader(strings.NewReader(test.config))
+		if err != nil {
+			t.Errorf("%s: invalid expected config: %s", test.testName, err)
+			continue
+		}
+
+		conf, err := test.builder.config()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.testName, err)
+			continue
+		}
+
+		if !configsAreEqual(conf, expected) {
+			t.Errorf("%s:\n* Expected: %v.\n* Got: %v", test.testName, expected, conf)
+		}
+	}
+}
+
+func TestConfigBuilderErrors(t *testing.T) {
+	invalidBuilders := []*ConfigBuilder{
+		NewConfig().WithFormat("%Msg").AddConsole(),
+		NewConfig().OnlyLevels(InfoLvl).AddConsole(),
+		NewConfig().MinLevel(LogLevel(42)).AddConsole(),
+		NewConfig(),
+	}
+
+	for i, builder := range invalidBuilders {
+		if _, err := builder.Build(); err == nil {
+			t.Errorf("Builder %d: expected error", i)
+		}
+	}
+}
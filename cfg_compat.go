@@ -0,0 +1,135 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"sort"
+	"sync/atomic"
+)
+
+var upstreamCompatible int32
+
+// SetUpstreamCompatible turns the upstream compatibility mode on or off. In this mode the
+// output of loggers is byte-identical to the output of upstream seelog
+// (github.com/cihub/seelog) with the same config, so that downstream parsers of the logs
+// keep working. Configs and formats created while the mode is on
+//     - may use only the elements and attributes of the upstream config schema
+//     - may use only the upstream verbs and predefined formats; a fork verb like %LevelColor
+//       is read as upstream reads it: %Level followed by 'Color'
+//     - are not expanded: includes and ${VAR} references are not supported
+// Loggers created before the mode is changed keep their behavior. Turn the mode on before
+// loading the config, e.g. in an init func.
+func SetUpstreamCompatible(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&upstreamCompatible, value)
+}
+
+// UpstreamCompatible reports whether the upstream compatibility mode is on.
+// See SetUpstreamCompatible.
+func UpstreamCompatible() bool {
+	return atomic.LoadInt32(&upstreamCompatible) != 0
+}
+
+// upstreamSchema maps the config elements of upstream seelog to their attributes.
+var upstreamSchema = map[string][]string{
+	seelogConfigId: {minLevelId, maxLevelId, levelsId, loggerTypeFromStringAttr, asyncLoggerIntervalAttr,
+		adaptLoggerMinIntervalAttr, adaptLoggerMaxIntervalAttr, adaptLoggerCriticalMsgCountAttr},
+	outputsId:    {outputFormatId},
+	formatsId:    {},
+	formatId:     {formatKeyAttrId, formatAttrId},
+	exceptionsId: {},
+	exceptionId:  {minLevelId, maxLevelId, levelsId, funcPatternId, filePatternId},
+
+	fileWriterId:    {outputFormatId, pathId},
+	consoleWriterId: {outputFormatId},
+	rollingfileWriterId: {outputFormatId, rollingFileTypeAttr, rollingFilePathAttr, rollingFileMaxSizeAttr,
+		rollingFileMaxRollsAttr, rollingFileDataPatternAttr, rollingFileArchiveAttr, rollingFileArchivePathAttr},
+	bufferedWriterId: {outputFormatId, bufferedSizeAttr, bufferedFlushPeriodAttr},
+	smtpWriterId:     {outputFormatId, senderaddressId, senderNameId, hostNameId, hostPortId, userNameId, userPassId},
+	recipientId:      {addressId},
+	cACertDirpathId:  {pathId},
+	connWriterId:     {outputFormatId, connWriterAddrAttr, connWriterNetAttr, connWriterReconnectOnMsgAttr},
+
+	splitterDispatcherId: {outputFormatId},
+	filterDispatcherId:   {outputFormatId, filterLevelsAttrId},
+}
+
+// upstreamPredefinedFormats are the predefined formats of upstream seelog, without prefix.
+var upstreamPredefinedFormats = map[string]bool{
+	"xml-debug":        true,
+	"xml-debug-short":  true,
+	"xml":              true,
+	"xml-short":        true,
+	"json-debug":       true,
+	"json-debug-short": true,
+	"json":             true,
+	"json-short":       true,
+	"debug":            true,
+	"debug-short":      true,
+	"fast":             true,
+}
+
+// checkUpstreamSchema checks that the node and its children use only elements and
+// attributes of the upstream config schema.
+func checkUpstreamSchema(node *xmlNode) error {
+	attributes, ok := upstreamSchema[node.name]
+	if !ok {
+		return errors.New("Element '" + node.errorName() + "' is not supported in upstream compatibility mode")
+	}
+
+	var unsupported []string
+	for name := range node.attributes {
+		if !containsString(attributes, name) {
+			unsupported = append(unsupported, name)
+		}
+	}
+	if len(unsupported) != 0 {
+		sort.Strings(unsupported)
+		return errors.New("Attribute '" + unsupported[0] + "' of " + node.errorName() +
+			" is not supported in upstream compatibility mode")
+	}
+
+	for _, child := range node.children {
+		err := checkUpstreamSchema(child)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The corpus in testdata/upstream_compat.json was produced by upstream seelog
+// (github.com/cihub/seelog d2c6e5aa9fbf): formatters of upstream were run with the context
+// of upstreamTestContext, and configs with the messages of logUpstreamTestMessages.
+type upstreamCorpus struct {
+	Formats []struct {
+		Format  string `json:"format"`
+		Level   string `json:"level"`
+		Message string `json:"message"`
+		Output  string `json:"output"`
+		Error   bool   `json:"error"`
+	} `json:"formats"`
+	Configs []struct {
+		Name    string            `json:"name"`
+		Config  string            `json:"config"`
+		Outputs map[string]string `json:"outputs"`
+	} `json:"configs"`
+}
+
+func readUpstreamCorpus(t *testing.T) *upstreamCorpus {
+	data, err := os.ReadFile(filepath.Join("testdata", "upstream_compat.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corpus := new(upstreamCorpus)
+	if err := json.Unmarshal(data, corpus); err != nil {
+		t.Fatal(err)
+	}
+	return corpus
+}
+
+func withUpstreamCompat(t *testing.T) {
+	SetUpstreamCompatible(true)
+	t.Cleanup(func() { SetUpstreamCompatible(false) })
+}
+
+var upstreamTestContext = &logContext{
+	funcName:  "main.(*server).handle",
+	line:      42,
+	shortPath: "app/server.go",
+	fullPath:  "/home/dev/go/src/app/server.go",
+	fileName:  "server.go",
+	callTime:  time.Date(2013, 4, 5, 6, 7, 8, 123456789, time.FixedZone("CEST", 2*3600)),
+}
+
+func logUpstreamTestMessages(logger LoggerInterface) {
+	logger.Trace("trace message")
+	logger.Debugf("debug %d %s", 1, "two")
+	logger.Info("info", 2, "x", 3, 4)
+	logger.Warnf("warn %v%%", 99.5)
+	logger.Error("error: ", os.ErrNotExist)
+	logger.Critical("critical\nsecond line")
+}
+
+func TestUpstreamCompatFormats(t *testing.T) {
+	withUpstreamCompat(t)
+
+	for _, test := range readUpstreamCorpus(t).Formats {
+		formatter, err := newFormatter(test.Format)
+		if test.Error {
+			if err == nil {
+				t.Errorf("Format %q: expected an error", test.Format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Format %q: %s", test.Format, err)
+			continue
+		}
+
+		level, _ := LogLevelFromString(test.Level)
+		output := formatter.Format(test.Message, level, upstreamTestContext)
+		if output != test.Output {
+			t.Errorf("Format %q, level %s, message %q:\nexpected %q\ngot      %q",
+				test.Format, test.Level, test.Message, test.Output, output)
+		}
+	}
+}
+
+func TestUpstreamCompatConfigs(t *testing.T) {
+	withUpstreamCompat(t)
+
+	for _, test := range readUpstreamCorpus(t).Configs {
+		dir := t.TempDir()
+		logger, err := LoggerFromConfigAsString(strings.Replace(test.Config, "OUT", dir, -1))
+		if err != nil {
+			t.Errorf("Config %s: %s", test.Name, err)
+			continue
+		}
+		logUpstreamTestMessages(logger)
+		logger.Close()
+
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != len(test.Outputs) {
+			t.Errorf("Config %s: expected %d files, got %d", test.Name, len(test.Outputs), len(files))
+		}
+		for name, expected := range test.Outputs {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Errorf("Config %s: %s", test.Name, err)
+				continue
+			}
+			if string(data) != expected {
+				t.Errorf("Config %s, file %s:\nexpected %q\ngot      %q", test.Name, name, expected, string(data))
+			}
+		}
+	}
+}
+
+func TestUpstreamCompatRejectsForkConfigs(t *testing.T) {
+	withUpstreamCompat(t)
+
+	configs := []string{
+		`<seelog><include file="common.xml"/></seelog>`,
+		`<seelog><pipeline name="audit"/></seelog>`,
+		`<seelog maxqueueage="1s"/>`,
+		`<seelog><outputs><sampler rate="1"><console/></sampler></outputs></seelog>`,
+		`<seelog><formats><format id="f" format="%Msg" locale="de"/></formats></seelog>`,
+		`<seelog><outputs formatid="std:json-fields"><console/></outputs></seelog>`,
+	}
+	for _, config := range configs {
+		if _, err := configFromReader(strings.NewReader(config)); err == nil {
+			t.Errorf("Expected an error for %s", config)
+		}
+	}
+}
+
+func TestUpstreamCompatKeepsVariables(t *testing.T) {
+	withTestEnv(t, map[string]string{"HOST": "example.com"})
+	withUpstreamCompat(t)
+
+	conf, err := configFromReader(strings.NewReader(`
+	<seelog>
+		<outputs formatid="f"><console/></outputs>
+		<formats><format id="f" format="${HOST} %Msg"/></formats>
+	</seelog>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formatter := conf.RootDispatcher.(*splitDispatcher).formatter
+	if output := formatter.Format("hello", InfoLvl, upstreamTestContext); output != "${HOST} hello" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestUpstreamVerbsWithoutCompat(t *testing.T) {
+	formatter, err := newFormatter("%UTCTime %UTCDate(2006-01-02 15:04) %UTCNs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "04:07:08 2013-04-05 04:07 1365134828123456789"
+	if output := formatter.Format("", InfoLvl, upstreamTestContext); output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+
+	if _, err := newFormatter("%Date(2006"); err == nil {
+		t.Error("Expected an error for an unmatched parenthesis")
+	}
+}
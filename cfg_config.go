@@ -27,18 +27,17 @@ package seelog
 import (
 	"bytes"
 	"io"
-	"os"
 )
 
 // LoggerFromConfigAsFile creates logger with config from file. File should contain valid seelog xml.
+// Relative paths of <include file="..."/> elements are resolved against the directory of the file.
 func LoggerFromConfigAsFile(fileName string) (LoggerInterface, error) {
-	file, err := os.Open(fileName)
+	config, err := unmarshalOverriddenConfigFile(fileName, unmarshalConfig)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	conf, err := configFromReader(file)
+	conf, err := configFromRoot(config)
 	if err != nil {
 		return nil, err
 	}
@@ -61,6 +60,104 @@ func LoggerFromConfigAsString(data string) (LoggerInterface, error) {
 	return LoggerFromConfigAsBytes([]byte(data))
 }
 
+// LoggerFromConfigs creates a logger from a base config with overlays merged into it, so
+// that a platform team can ship a base config and applications add only what they need.
+// Every config is xml, or JSON if it starts with '{'. Overlays are merged in order, later
+// ones taking precedence:
+//   - attributes of the root element and of sections are replaced; level attributes
+//     (minlevel, maxlevel, levels) are replaced as a group
+//   - outputs, formats, exceptions and custom levels sections are merged, with the
+//     receivers of the base first; a format with an existing id replaces it, an
+//     exception with the same patterns replaces the previous one
+//   - pipelines with the same name are merged the same way, new pipelines are added
+// Includes are resolved relative to the working directory after merging.
+//
+// Example:
+//     logger, err := log.LoggerFromConfigs(platformConfig, []byte(`
+//         <seelog minlevel="debug">
+//             <outputs><file path="app.log"/></outputs>
+//         </seelog>`))
+func LoggerFromConfigs(base []byte, overlays ...[]byte) (LoggerInterface, error) {
+	config, err := mergeConfigData(base, overlays)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := configFromRoot(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return createLoggerFromConfig(conf)
+}
+
+// LoggerFromJSON creates a logger with config from JSON data. The JSON document mirrors
+// the xml schema: an object with a "seelog" member, where scalar members are attributes,
+// object members are child elements and array members are repeated child elements.
+//
+// Example:
+//     {"seelog": {"minlevel": "info", "outputs": {"console": {}, "file": {"path": "app.log"}}}}
+func LoggerFromJSON(data []byte) (LoggerInterface, error) {
+	conf, err := configFromJsonReader(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return createLoggerFromConfig(conf)
+}
+
+// LoggerFromJSONFile creates a logger with config from a JSON file. See LoggerFromJSON.
+func LoggerFromJSONFile(fileName string) (LoggerInterface, error) {
+	config, err := unmarshalOverriddenConfigFile(fileName, unmarshalJsonConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := configFromRoot(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return createLoggerFromConfig(conf)
+}
+
+// LoggerFromConfigPipeline creates a logger from the pipeline with the given name in a config file.
+// A config may declare several named pipelines inside the root element, each with the same
+// attributes and sections as the root, so that one file defines all loggers of an application.
+//
+// Example:
+//     <seelog>
+//         <formats>...</formats>
+//         <pipeline name="audit" minlevel="info">
+//             <outputs>...</outputs>
+//         </pipeline>
+//         <pipeline name="app">...</pipeline>
+//     </seelog>
+func LoggerFromConfigPipeline(fileName string, name string) (LoggerInterface, error) {
+	config, err := unmarshalOverriddenConfigFile(fileName, unmarshalConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := pipelineConfigFromRoot(config, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return createLoggerFromConfig(conf)
+}
+
+// LoggerFromConfigPipelineAsBytes creates a logger from the pipeline with the given name in a config
+// from bytes stream. See LoggerFromConfigPipeline.
+func LoggerFromConfigPipelineAsBytes(data []byte, name string) (LoggerInterface, error) {
+	conf, err := pipelineConfigFromReader(bytes.NewBuffer(data), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return createLoggerFromConfig(conf)
+}
+
 // LoggerFromWriterWithMinLevel creates a simple logger for usage with non-Seelog systems. 
 // Creates logger that writes to output with minimal level = minLevel.
 func LoggerFromWriterWithMinLevel(output io.Writer, minLevel LogLevel) (LoggerInterface, error) {
@@ -0,0 +1,311 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigDescription is the effective logger topology of a config, see DescribeConfig.
+type ConfigDescription struct {
+	Name       string            // Pipeline name; empty for the root logger
+	Type       string            // Logger type, e.g. "asyncloop"
+	Settings   map[string]string // Logger type settings and the queue age limit
+	Levels     []LogLevel        // Levels allowed by the general constraints
+	Exceptions []ExceptionDescription
+	Outputs    []*OutputDescription
+	Pipelines  []*ConfigDescription // Pipelines of the root logger
+
+	levels levelSet // Custom levels declared by the config, which may not be active
+}
+
+// ExceptionDescription is an exception to the general constraints.
+type ExceptionDescription struct {
+	FuncPattern    string
+	FilePattern    string
+	PackagePattern string
+	Levels         []LogLevel // Levels allowed in matching funcs, files and packages
+}
+
+// OutputDescription is a receiver of the output tree.
+type OutputDescription struct {
+	Kind       string            // Element name, e.g. "file" or "filter"
+	FormatId   string            // Id of the effective format; empty for the default format
+	Format     string            // Effective format string
+	Attributes map[string]string // Attributes other than formatid; passwords are masked unless they are secret references
+	Outputs    []*OutputDescription
+}
+
+// DescribeConfig parses a config and returns what the created logger will actually do:
+// its type with all settings, the allowed levels, exceptions, and the output tree with
+// the effective format of every receiver after defaults and inheritance are applied.
+// Conditional sections, includes and environment variables are resolved. The config is
+// xml, or JSON if it starts with '{'. An error is returned for configs which LoggerFrom*
+// funcs would reject.
+// No files or connections are opened.
+//
+// The String method of the result prints the description as a tree.
+func DescribeConfig(data []byte) (*ConfigDescription, error) {
+	root, err := unmarshalConfigData(data)
+	if err != nil {
+		return nil, err
+	}
+	err = prepareRootConfig(root)
+	if err != nil {
+		return nil, err
+	}
+
+	description, err := describeLogger(root, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedFormats, err := getFormats(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range root.children {
+		if child.name != pipelineId {
+			continue
+		}
+		pipeline, err := describeLogger(child, sharedFormats, true)
+		if err != nil {
+			return nil, err
+		}
+		description.Pipelines = append(description.Pipelines, pipeline)
+	}
+
+	return description, nil
+}
+
+func describeLogger(node *xmlNode, sharedFormats map[string]*formatter, isPipeline bool) (*ConfigDescription, error) {
+	config, err := configFromNode(node, sharedFormats, isPipeline)
+	if err != nil {
+		return nil, err
+	}
+	config.RootDispatcher.Close()
+
+	description := &ConfigDescription{
+		Name:     node.attributes[pipelineNameAttr],
+		Type:     loggerTypeToStringRepresentations[config.LogType],
+		Settings: make(map[string]string),
+		Levels:   allowedLevels(config.Constraints, node.levels),
+		levels:   node.levels,
+	}
+
+	switch data := config.LoggerData.(type) {
+	case asyncTimerLoggerData:
+		description.Settings[asyncLoggerIntervalAttr] = strconv.FormatUint(uint64(data.AsyncInterval), 10)
+	case adaptiveLoggerData:
+		description.Settings[adaptLoggerMinIntervalAttr] = strconv.FormatUint(uint64(data.MinInterval), 10)
+		description.Settings[adaptLoggerMaxIntervalAttr] = strconv.FormatUint(uint64(data.MaxInterval), 10)
+		description.Settings[adaptLoggerCriticalMsgCountAttr] = strconv.FormatUint(uint64(data.CriticalMsgCount), 10)
+	}
+	if config.QueueAge != nil {
+		description.Settings[maxQueueAgeAttr] = config.QueueAge.MaxAge.String()
+		description.Settings[maxQueueAgeLevelAttr] = node.levels.name(config.QueueAge.MaxLevel)
+	}
+	if config.OverloadQueue != 0 {
+		description.Settings[overloadQueueAttr] = strconv.Itoa(config.OverloadQueue)
+	}
+	if config.Stack != nil {
+		description.Settings[stackDepthAttr] = strconv.Itoa(config.Stack.Depth)
+		description.Settings[stackLevelAttr] = node.levels.name(config.Stack.MinLevel)
+	}
+
+	for _, exception := range config.Exceptions {
+		description.Exceptions = append(description.Exceptions, ExceptionDescription{
+			FuncPattern:    exception.funcPattern,
+			FilePattern:    exception.filePattern,
+			PackagePattern: exception.packagePattern,
+			Levels:         allowedLevels(exception.constraints, node.levels),
+		})
+	}
+
+	formats, err := getFormats(node)
+	if err != nil {
+		return nil, err
+	}
+	for id, sharedFormat := range sharedFormats {
+		if _, ok := formats[id]; !ok {
+			formats[id] = sharedFormat
+		}
+	}
+
+	var outputsNode *xmlNode
+	for _, child := range node.children {
+		if child.name == outputsId {
+			outputsNode = child
+		}
+	}
+	if outputsNode == nil {
+		description.Outputs = []*OutputDescription{{
+			Kind:       consoleWriterId,
+			Format:     defaultformatter.fmtStringOriginal,
+			Attributes: map[string]string{},
+		}}
+		return description, nil
+	}
+
+	outputs := describeOutput(outputsNode, "", formats)
+	description.Outputs = outputs.Outputs
+	return description, nil
+}
+
+func allowedLevels(constraints logLevelConstraints, declared levelSet) []LogLevel {
+	var levels []LogLevel
+	for _, level := range declared.definedLevels() {
+		if constraints.IsAllowed(level) {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// describeOutput describes a node of the output tree. The format of the parent is
+// inherited unless the node declares its own one.
+func describeOutput(node *xmlNode, parentFormatId string, formats map[string]*formatter) *OutputDescription {
+	description := &OutputDescription{
+		Kind:       node.name,
+		FormatId:   parentFormatId,
+		Attributes: make(map[string]string),
+	}
+	for name, value := range node.attributes {
+		if name == outputFormatId {
+			description.FormatId = value
+			continue
+		}
+		if name == userPassId && !isSecretReference(value) {
+			value = "******"
+		}
+		description.Attributes[name] = value
+	}
+
+	_, isReceiver := elementMap[node.name]
+	if isReceiver || node.name == outputsId {
+		description.Format = defaultformatter.fmtStringOriginal
+		if format, ok := formats[description.FormatId]; ok {
+			description.Format = format.fmtStringOriginal
+		} else if format, ok := predefinedFormats[description.FormatId]; ok {
+			description.Format = format.fmtStringOriginal
+		}
+	} else {
+		description.FormatId = ""
+	}
+
+	for _, child := range node.children {
+		description.Outputs = append(description.Outputs, describeOutput(child, description.FormatId, formats))
+	}
+	return description
+}
+
+// String prints the description as a tree, one element per line.
+//
+// Example:
+//     seelog type=asynctimer asyncinterval=5000
+//       levels: info,warn,error,critical
+//       exception funcpattern=* filepattern=test*: error,critical
+//       file path=all.log format=all "%Level %Msg%n"
+//       filter levels=error
+//         console format=short "%Msg%n"
+func (description *ConfigDescription) String() string {
+	var buf bytes.Buffer
+	description.write(&buf, "")
+	return buf.String()
+}
+
+func (description *ConfigDescription) write(buf *bytes.Buffer, indent string) {
+	if description.Name == "" {
+		buf.WriteString(indent + seelogConfigId)
+	} else {
+		buf.WriteString(indent + pipelineId + " " + description.Name)
+	}
+	buf.WriteString(" type=" + description.Type + formatAttributes(description.Settings) + "\n")
+
+	indent += "  "
+	buf.WriteString(indent + "levels: " + formatLevelList(description.Levels, description.levels) + "\n")
+	for _, exception := range description.Exceptions {
+		fmt.Fprintf(buf, "%sexception funcpattern=%s filepattern=%s", indent, exception.FuncPattern, exception.FilePattern)
+		if exception.PackagePattern != "*" {
+			buf.WriteString(" package=" + exception.PackagePattern)
+		}
+		buf.WriteString(": " + formatLevelList(exception.Levels, description.levels) + "\n")
+	}
+	for _, output := range description.Outputs {
+		output.write(buf, indent)
+	}
+	for _, pipeline := range description.Pipelines {
+		pipeline.write(buf, indent)
+	}
+}
+
+func (description *OutputDescription) write(buf *bytes.Buffer, indent string) {
+	buf.WriteString(indent + description.Kind + formatAttributes(description.Attributes))
+	if description.Format != "" {
+		if description.FormatId != "" {
+			buf.WriteString(" format=" + description.FormatId)
+		}
+		buf.WriteString(" " + strconv.Quote(description.Format))
+	}
+	buf.WriteString("\n")
+
+	for _, output := range description.Outputs {
+		output.write(buf, indent+"  ")
+	}
+}
+
+// formatAttributes formats attributes as " name=value" pairs sorted by name.
+func formatAttributes(attributes map[string]string) string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		value := attributes[name]
+		if value == "" || strings.ContainsAny(value, " \t\"") {
+			value = strconv.Quote(value)
+		}
+		buf.WriteString(" " + name + "=" + value)
+	}
+	return buf.String()
+}
+
+func formatLevelList(levels []LogLevel, declared levelSet) string {
+	if len(levels) == 0 {
+		return LogLevel(Off).String()
+	}
+	names := make([]string, len(levels))
+	for i, level := range levels {
+		names[i] = declared.name(level)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDescribeConfig(t *testing.T) {
+	config := `<seelog type="asynctimer" asyncinterval="5000" minlevel="info" maxqueueage="30s">
+	<exceptions>
+		<exception filepattern="test*" minlevel="error"/>
+	</exceptions>
+	<outputs formatid="all">
+		<file path="all.log"/>
+		<filter levels="error,critical">
+			<console formatid="std:json"/>
+			<smtp senderaddress="a@b.c" sendername="log" hostname="mail" hostport="25" username="u" password="secret">
+				<recipient address="ops@b.c"/>
+			</smtp>
+		</filter>
+	</outputs>
+	<formats>
+		<format id="all" format="%Level %Msg%n"/>
+	</formats>
+	<pipeline name="audit" type="sync" levels="warn">
+		<outputs formatid="all"><file path="audit.log"/></outputs>
+	</pipeline>
+</seelog>`
+
+	description, err := DescribeConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if description.Type != "asynctimer" || description.Settings[maxQueueAgeLevelAttr] != "info" {
+		t.Errorf("Unexpected logger settings: %s %v", description.Type, description.Settings)
+	}
+	if !reflect.DeepEqual(description.Levels, []LogLevel{InfoLvl, WarnLvl, ErrorLvl, CriticalLvl}) {
+		t.Errorf("Unexpected levels: %v", description.Levels)
+	}
+	if len(description.Exceptions) != 1 || description.Exceptions[0].FilePattern != "test*" {
+		t.Errorf("Unexpected exceptions: %v", description.Exceptions)
+	}
+
+	expected := `seelog type=asynctimer asyncinterval=5000 maxqueueage=30s maxqueueagelevel=info
+  levels: info,warn,error,critical
+  exception funcpattern=* filepattern=test*: error,critical
+  file path=all.log format=all "%Level %Msg%n"
+  filter levels=error,critical format=all "%Level %Msg%n"
+    console format=std:json ` + strconv.Quote(predefinedFormats["std:json"].fmtStringOriginal) + `
+    smtp hostname=mail hostport=25 password=****** senderaddress=a@b.c sendername=log username=u format=all "%Level %Msg%n"
+      recipient address=ops@b.c
+  pipeline audit type=sync
+    levels: warn
+    file path=audit.log format=all "%Level %Msg%n"
+`
+	if description.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, description)
+	}
+}
+
+func TestDescribeConfigDefaults(t *testing.T) {
+	description, err := DescribeConfig([]byte(`<seelog/>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "seelog type=asyncloop\n  levels: trace,debug,info,warn,error,critical\n  console " +
+		`"` + defaultformatter.fmtStringOriginal + `"` + "\n"
+	if description.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, description)
+	}
+}
+
+func TestDescribeConfigInvalid(t *testing.T) {
+	_, err := DescribeConfig([]byte(`<seelog type="asynctimer"/>`))
+	if err == nil {
+		t.Error("Expected an error for a missing asyncinterval")
+	}
+}
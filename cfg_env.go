@@ -0,0 +1,112 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+var defaultLookupEnv = os.LookupEnv
+
+// lookupEnv is replaced in tests.
+var lookupEnv = defaultLookupEnv
+
+// expandConfigEnv replaces environment variable references in all attribute values
+// and text values of the node and its children. Supported forms:
+//     ${VAR}          value of VAR; an error if VAR is not set
+//     ${VAR:-default} value of VAR, or default if VAR is not set or empty
+//     $${             a literal '${'
+func expandConfigEnv(node *xmlNode) error {
+	for name, value := range node.attributes {
+		expanded, err := expandEnv(value)
+		if err != nil {
+			return errors.New(node.errorName() + " attribute '" + name + "': " + err.Error())
+		}
+		node.attributes[name] = expanded
+	}
+
+	expanded, err := expandEnv(node.value)
+	if err != nil {
+		return errors.New(node.errorName() + ": " + err.Error())
+	}
+	node.value = expanded
+
+	for _, child := range node.children {
+		err = expandConfigEnv(child)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func expandEnv(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var result []byte
+	for i := 0; i < len(value); i++ {
+		if strings.HasPrefix(value[i:], "$${") {
+			result = append(result, "${"...)
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(value[i:], "${") {
+			result = append(result, value[i])
+			continue
+		}
+
+		end := strings.IndexByte(value[i:], '}')
+		if end < 0 {
+			return "", errors.New("Unclosed variable reference: " + value[i:])
+		}
+		reference := value[i+2 : i+end]
+
+		name, defaultValue, hasDefault := reference, "", false
+		if sep := strings.Index(reference, ":-"); sep >= 0 {
+			name, defaultValue, hasDefault = reference[:sep], reference[sep+2:], true
+		}
+		if name == "" {
+			return "", errors.New("Empty variable name: ${" + reference + "}")
+		}
+
+		variable, ok := lookupEnv(name)
+		switch {
+		case hasDefault && variable == "":
+			variable = defaultValue
+		case !ok:
+			return "", errors.New("Environment variable is not set: " + name)
+		}
+
+		result = append(result, variable...)
+		i += end
+	}
+
+	return string(result), nil
+}
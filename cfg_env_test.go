@@ -0,0 +1,104 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"strings"
+	"testing"
+)
+
+type expandEnvTest struct {
+	value         string
+	expected      string
+	errorExpected bool
+}
+
+var expandEnvTests = []expandEnvTest{
+	{"plain", "plain", false},
+	{"${HOST}", "example.com", false},
+	{"logs/${HOST}/app.log", "logs/example.com/app.log", false},
+	{"${HOST}:${PORT:-514}", "example.com:514", false},
+	{"${EMPTY:-fallback}", "fallback", false},
+	{"${EMPTY}", "", false},
+	{"${LEVEL:-info}", "info", false},
+	{"$${HOST} ${HOST}", "${HOST} example.com", false},
+	{"$HOST", "$HOST", false},
+	{"${MISSING}", "", true},
+	{"${HOST", "", true},
+	{"${:-x}", "", true},
+}
+
+func withTestEnv(t *testing.T, env map[string]string) {
+	lookupEnv = func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	t.Cleanup(func() { lookupEnv = defaultLookupEnv })
+}
+
+func TestExpandEnv(t *testing.T) {
+	withTestEnv(t, map[string]string{"HOST": "example.com", "EMPTY": ""})
+
+	for _, test := range expandEnvTests {
+		expanded, err := expandEnv(test.value)
+		if (err != nil) != test.errorExpected {
+			t.Errorf("Input: %s\n* Expected error: %t. Got error: %v", test.value, test.errorExpected, err)
+			continue
+		}
+		if err == nil && expanded != test.expected {
+			t.Errorf("Input: %s\n* Expected: %s. Got: %s", test.value, test.expected, expanded)
+		}
+	}
+}
+
+func TestConfigEnvExpansion(t *testing.T) {
+	withTestEnv(t, map[string]string{"LOG_LEVEL": "warn"})
+
+	config := `
+	<seelog type="sync" minlevel="${LOG_LEVEL:-trace}">
+		<outputs formatid="${FORMAT:-main}"><console/></outputs>
+		<formats><format id="main" format="%Msg"/></formats>
+	</seelog>`
+
+	conf, err := configFromReader(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := new(logConfig)
+	expected.Constraints, _ = newMinMaxConstraints(WarnLvl, CriticalLvl)
+	consoleWriter, _ := newConsoleWriter()
+	formatter, _ := newFormatter("%Msg")
+	expected.RootDispatcher, _ = newSplitDispatcher(formatter, []interface{}{consoleWriter})
+	expected.LogType = syncloggerTypeFromString
+	if !configsAreEqual(conf, expected) {
+		t.Errorf("\n* Expected: %v.\n* Got: %v", expected, conf)
+	}
+
+	_, err = configFromReader(strings.NewReader(`<seelog><outputs><file path="${LOG_DIR}/app.log"/></outputs></seelog>`))
+	if err == nil || !strings.Contains(err.Error(), "LOG_DIR") {
+		t.Errorf("Expected error naming the unset variable, got: %v", err)
+	}
+}
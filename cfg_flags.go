@@ -0,0 +1,295 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"sync"
+)
+
+// flagFormatId is the id of the format added to configs by the -log.format flag.
+const flagFormatId = "seelog-flags"
+
+// flagOverrides holds the values of the flags registered by RegisterFlags.
+type flagOverrides struct {
+	mutex  sync.Mutex
+	level  string
+	config string
+	format string
+	logger LoggerInterface // The package level logger created by the flags, if any
+}
+
+var overrides flagOverrides
+
+// overrideFlag is a flag.Value of one of the flags registered by RegisterFlags.
+type overrideFlag int
+
+const (
+	levelFlag overrideFlag = iota
+	configFlag
+	formatFlag
+)
+
+// RegisterFlags adds flags to fs which override the configs loaded by the program:
+//     -log.level  minimal level of the root logger and of all pipelines; 'off' disables them
+//     -log.config config file read instead of the one passed to LoggerFromConfigAsFile,
+//                 LoggerFromJSONFile and LoggerFromConfigPipeline
+//     -log.format format of all outputs: a format string or a predefined format like std:json
+// Level and format overrides apply to all configs created after the flags are parsed.
+// Exceptions of the configs are kept. If the package level logger is still the default
+// one when the flags are parsed, it is replaced by a logger from the -log.config file, or
+// from an empty config, with the overrides.
+//
+// Example:
+//     seelog.RegisterFlags(flag.CommandLine)
+//     flag.Parse()
+//     logger, err := seelog.LoggerFromConfigAsFile("seelog.xml")
+// run as
+//     myservice -log.level debug
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.Var(levelFlag, "log.level", "minimal log level, overrides the config")
+	fs.Var(configFlag, "log.config", "seelog config file, overrides the config file of the program")
+	fs.Var(formatFlag, "log.format", "format of all log outputs, e.g. std:json, overrides the config")
+}
+
+func (f overrideFlag) String() string {
+	overrides.mutex.Lock()
+	defer overrides.mutex.Unlock()
+	return *overrides.value(f)
+}
+
+func (f overrideFlag) Set(value string) error {
+	err := checkOverride(f, value)
+	if err != nil {
+		return err
+	}
+
+	overrides.mutex.Lock()
+	*overrides.value(f) = value
+	overrides.mutex.Unlock()
+
+	return overrides.replaceDefaultLogger()
+}
+
+func (o *flagOverrides) value(f overrideFlag) *string {
+	switch f {
+	case configFlag:
+		return &o.config
+	case formatFlag:
+		return &o.format
+	}
+	return &o.level
+}
+
+func checkOverride(f overrideFlag, value string) error {
+	switch f {
+	case levelFlag:
+		if _, ok := LogLevelFromString(value); !ok {
+			return errors.New("Unknown log level: " + value)
+		}
+	case configFlag:
+		if value == "" {
+			return errors.New("Config file name can not be empty")
+		}
+	case formatFlag:
+		if strings.HasPrefix(value, predefinedPrefix) {
+			if _, ok := predefinedFormats[value]; !ok {
+				return errors.New("Unknown predefined format: " + value)
+			}
+			return nil
+		}
+		_, err := newFormatter(value)
+		return err
+	}
+	return nil
+}
+
+// replaceDefaultLogger replaces the package level logger by one created with the overrides
+// unless the program has already replaced the default logger with its own.
+func (o *flagOverrides) replaceDefaultLogger() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	current := stdFacade.Logger()
+	if current != Default && (o.logger == nil || current != o.logger) {
+		return nil
+	}
+
+	var logger LoggerInterface
+	var err error
+	if o.config != "" {
+		logger, err = loggerFromFlagConfig(o.config, o.level, o.format)
+	} else {
+		logger, err = loggerFromRootWithOverrides(newNode(), o.level, o.format)
+	}
+	if err != nil {
+		return err
+	}
+
+	o.logger = logger
+	return stdFacade.ReplaceLogger(logger)
+}
+
+func loggerFromFlagConfig(fileName string, level string, format string) (LoggerInterface, error) {
+	config, err := unmarshalConfigFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return loggerFromRootWithOverrides(config, level, format)
+}
+
+func loggerFromRootWithOverrides(config *xmlNode, level string, format string) (LoggerInterface, error) {
+	if config.name == "" {
+		config.name = seelogConfigId
+	}
+
+	err := prepareRootConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	overrideConfig(config, level, format)
+
+	conf, err := configFromNode(config, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return createLoggerFromConfig(conf)
+}
+
+// unmarshalOverriddenConfigFile reads the -log.config file if the flag is set, or else
+// the given file.
+func unmarshalOverriddenConfigFile(fileName string, unmarshal func(io.Reader) (*xmlNode, error)) (*xmlNode, error) {
+	overrides.mutex.Lock()
+	override := overrides.config
+	overrides.mutex.Unlock()
+
+	if override != "" {
+		return unmarshalConfigFile(override)
+	}
+	return unmarshalConfigFileWith(fileName, unmarshal)
+}
+
+// applyFlagOverrides applies the -log.level and -log.format flags to a prepared root config.
+func applyFlagOverrides(config *xmlNode) {
+	overrides.mutex.Lock()
+	level, format := overrides.level, overrides.format
+	overrides.mutex.Unlock()
+
+	overrideConfig(config, level, format)
+}
+
+func overrideConfig(config *xmlNode, level string, format string) {
+	if level == "" && format == "" {
+		return
+	}
+
+	nodes := []*xmlNode{config}
+	for _, child := range config.children {
+		if child.name == pipelineId {
+			nodes = append(nodes, child)
+		}
+	}
+
+	for _, node := range nodes {
+		if level != "" {
+			overrideLevel(node, level)
+		}
+		if format != "" {
+			overrideFormat(node, format)
+		}
+	}
+}
+
+func overrideLevel(node *xmlNode, level string) {
+	delete(node.attributes, minLevelId)
+	delete(node.attributes, maxLevelId)
+	delete(node.attributes, levelsId)
+
+	if level == OffStr {
+		node.attributes[levelsId] = OffStr
+	} else {
+		node.attributes[minLevelId] = level
+	}
+}
+
+// overrideFormat makes all outputs of the node use the format. A node without outputs
+// gets the default console output.
+func overrideFormat(node *xmlNode, format string) {
+	id := format
+	if _, ok := predefinedFormats[format]; !ok {
+		id = flagFormatId
+		formats := overrideChild(node, formatsId)
+
+		var formatNode *xmlNode
+		for _, child := range formats.children {
+			if child.name == formatId && child.attributes[formatKeyAttrId] == flagFormatId {
+				formatNode = child
+			}
+		}
+		if formatNode == nil {
+			formatNode = newNode()
+			formatNode.name = formatId
+			formatNode.attributes[formatKeyAttrId] = flagFormatId
+			formats.children = append(formats.children, formatNode)
+		}
+		formatNode.attributes[formatAttrId] = format
+	}
+
+	outputs := overrideChild(node, outputsId)
+	if len(outputs.children) == 0 {
+		console := newNode()
+		console.name = consoleWriterId
+		outputs.children = append(outputs.children, console)
+	}
+	removeFormatIds(outputs)
+	outputs.attributes[outputFormatId] = id
+}
+
+// overrideChild returns the child of the node with the given name, adding it if needed.
+func overrideChild(node *xmlNode, name string) *xmlNode {
+	for _, child := range node.children {
+		if child.name == name {
+			return child
+		}
+	}
+
+	child := newNode()
+	child.name = name
+	node.children = append(node.children, child)
+	return child
+}
+
+func removeFormatIds(node *xmlNode) {
+	delete(node.attributes, outputFormatId)
+	for _, child := range node.children {
+		removeFormatIds(child)
+	}
+}
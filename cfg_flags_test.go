@@ -0,0 +1,177 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseTestFlags parses the seelog flags and restores the overrides and the package level
+// logger when the test ends.
+func parseTestFlags(t *testing.T, args ...string) error {
+	previous := CurrentLogger()
+	t.Cleanup(func() {
+		overrides.mutex.Lock()
+		flagLogger := overrides.logger
+		overrides.level, overrides.config, overrides.format, overrides.logger = "", "", "", nil
+		overrides.mutex.Unlock()
+
+		UseLogger(previous)
+		if flagLogger != nil {
+			flagLogger.Close()
+		}
+	})
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(new(strings.Builder))
+	RegisterFlags(fs)
+	return fs.Parse(args)
+}
+
+func readTestLog(t *testing.T, fileName string) string {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFlagsOverrideLevelAndFormat(t *testing.T) {
+	err := parseTestFlags(t, "-log.level", "warn", "-log.format", "%Level %Msg%n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "app.log")
+	logger, err := LoggerFromConfigAsString(`
+	<seelog type="sync" levels="trace,info">
+		<outputs formatid="app">
+			<file formatid="app" path="` + fileName + `"/>
+		</outputs>
+		<formats>
+			<format id="app" format="%Msg%n"/>
+		</formats>
+	</seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("info message")
+	logger.Error("error message")
+	logger.Close()
+
+	if log := readTestLog(t, fileName); log != "Error error message\n" {
+		t.Errorf("Unexpected log: %q", log)
+	}
+}
+
+func TestFlagsOverridePipelines(t *testing.T) {
+	err := parseTestFlags(t, "-log.level", "off", "-log.format", "std:json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := pipelineConfigFromReader(strings.NewReader(`
+	<seelog>
+		<pipeline name="audit" minlevel="info">
+			<outputs><console/></outputs>
+		</pipeline>
+	</seelog>`), "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Constraints.IsAllowed(CriticalLvl) {
+		t.Error("Expected the pipeline to be disabled")
+	}
+}
+
+func TestFlagsOverrideConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "app.log")
+	configName := filepath.Join(dir, "seelog.xml")
+	config := `<seelog type="sync" minlevel="info"><outputs formatid="app"><file path="` + fileName + `"/></outputs>` +
+		`<formats><format id="app" format="%Msg%n"/></formats></seelog>`
+	if err := os.WriteFile(configName, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	UseLogger(Default)
+	err := parseTestFlags(t, "-log.config", configName, "-log.level", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if CurrentLogger() == Default {
+		t.Fatal("Expected the default logger to be replaced")
+	}
+	Trace("trace message")
+	Debug("debug message")
+
+	logger, err := LoggerFromConfigAsFile(filepath.Join(dir, "missing.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("file message")
+	logger.Close()
+	Flush()
+
+	if log := readTestLog(t, fileName); log != "debug message\nfile message\n" {
+		t.Errorf("Unexpected log: %q", log)
+	}
+}
+
+func TestFlagsKeepProgramLogger(t *testing.T) {
+	logger, err := LoggerFromWriterWithMinLevel(new(strings.Builder), InfoLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	UseLogger(logger)
+	err = parseTestFlags(t, "-log.level", "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if CurrentLogger() != logger {
+		t.Error("Expected the logger of the program to be kept")
+	}
+}
+
+func TestFlagsInvalidValues(t *testing.T) {
+	for _, args := range [][]string{
+		{"-log.level", "verbose"},
+		{"-log.format", "std:unknown"},
+		{"-log.format", "%Unknown"},
+		{"-log.config", ""},
+	} {
+		if err := parseTestFlags(t, args...); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	includeId       = "include"
+	includeFileAttr = "file"
+)
+
+// includeMergedSections are the sections which are merged into one when both
+// the including and the included config declare them.
+var includeMergedSections = []string{outputsId, formatsId, exceptionsId, customLevelsId}
+
+// unmarshalConfigFile reads a config file. Files with the .json extension are read as
+// JSON configs, others as xml. The directory of the file is remembered for includes.
+func unmarshalConfigFile(fileName string) (*xmlNode, error) {
+	unmarshal := unmarshalConfig
+	if strings.EqualFold(filepath.Ext(fileName), ".json") {
+		unmarshal = unmarshalJsonConfig
+	}
+
+	return unmarshalConfigFileWith(fileName, unmarshal)
+}
+
+func unmarshalConfigFileWith(fileName string, unmarshal func(io.Reader) (*xmlNode, error)) (*xmlNode, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config, err := unmarshal(file)
+	if err != nil {
+		return nil, err
+	}
+
+	config.dir = filepath.Dir(fileName)
+	return config, nil
+}
+
+// resolveIncludes replaces all include elements of the config with the content of
+// the included files. Include paths are relative to the directory of the including
+// file, or to the working directory for configs which were not read from a file.
+//
+// The root element of an included file must be 'seelog'. Its children are inserted in
+// place of the include element and its attributes are used as defaults of the element
+// containing the include. Sections declared both in the including and in an included
+// config are merged; for formats with the same id the including config wins.
+// The names of the included files are kept in the includes of the config.
+func resolveIncludes(config *xmlNode) error {
+	return resolveNodeIncludes(config, config.dir, nil, &config.includes)
+}
+
+func resolveNodeIncludes(node *xmlNode, dir string, stack []string, includes *[]string) error {
+	var children []*xmlNode
+	included := make(map[*xmlNode]bool)
+	for _, child := range node.children {
+		if child.name != includeId {
+			err := resolveNodeIncludes(child, dir, stack, includes)
+			if err != nil {
+				return err
+			}
+			children = append(children, child)
+			continue
+		}
+
+		includedConfig, err := readInclude(child, dir, stack, includes)
+		if err != nil {
+			return err
+		}
+
+		for name, value := range includedConfig.attributes {
+			if _, ok := node.attributes[name]; !ok {
+				node.attributes[name] = value
+			}
+		}
+		for _, includedChild := range includedConfig.children {
+			included[includedChild] = true
+			children = append(children, includedChild)
+		}
+	}
+
+	node.children = mergeIncludedSections(children, included)
+	return nil
+}
+
+func readInclude(node *xmlNode, dir string, stack []string, includes *[]string) (*xmlNode, error) {
+	err := checkUnexpectedAttribute(node, includeFileAttr)
+	if err != nil {
+		return nil, err
+	}
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	fileName, ok := node.attributes[includeFileAttr]
+	if !ok || fileName == "" {
+		return nil, errors.New(node.errorName() + " has no '" + includeFileAttr + "' attribute")
+	}
+	fileName, err = expandEnv(fileName)
+	if err != nil {
+		return nil, errors.New(node.errorName() + ": " + err.Error())
+	}
+	if !filepath.IsAbs(fileName) && dir != "" {
+		fileName = filepath.Join(dir, fileName)
+	}
+
+	absName, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	for _, including := range stack {
+		if including == absName {
+			return nil, errors.New("Include cycle: " + strings.Join(append(stack, absName), " -> "))
+		}
+	}
+
+	*includes = append(*includes, fileName)
+	config, err := unmarshalConfigFile(fileName)
+	if err != nil {
+		return nil, errors.New("Cannot include '" + fileName + "': " + err.Error())
+	}
+	if config.name != seelogConfigId {
+		return nil, errors.New("Root element of included '" + fileName + "' must be '" + seelogConfigId + "'")
+	}
+
+	err = resolveConditions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = resolveNodeIncludes(config, config.dir, append(stack, absName), includes)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// mergeIncludedSections merges repeated sections into the first of them. Children of
+// included sections go first, so that later declarations of the including config win;
+// attributes of the including config win as well.
+func mergeIncludedSections(children []*xmlNode, included map[*xmlNode]bool) []*xmlNode {
+	if len(included) == 0 {
+		return children
+	}
+
+	type mergedSection struct {
+		node                  *xmlNode
+		includedNodes, locals []*xmlNode
+	}
+
+	sections := make(map[string]*mergedSection)
+	result := make([]*xmlNode, 0, len(children))
+	for _, child := range children {
+		if !isIncludeMergedSection(child.name) {
+			result = append(result, child)
+			continue
+		}
+
+		section, ok := sections[child.name]
+		if !ok {
+			section = &mergedSection{node: newNode()}
+			section.node.name = child.name
+			section.node.location = child.location
+			sections[child.name] = section
+			result = append(result, section.node)
+		}
+
+		for name, value := range child.attributes {
+			if _, exists := section.node.attributes[name]; !exists || !included[child] {
+				section.node.attributes[name] = value
+			}
+		}
+		if included[child] {
+			section.includedNodes = append(section.includedNodes, child.children...)
+		} else {
+			section.locals = append(section.locals, child.children...)
+		}
+	}
+
+	for _, section := range sections {
+		section.node.children = append(section.includedNodes, section.locals...)
+	}
+
+	return result
+}
+
+func isIncludeMergedSection(name string) bool {
+	for _, section := range includeMergedSections {
+		if name == section {
+			return true
+		}
+	}
+	return false
+}
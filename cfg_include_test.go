@@ -0,0 +1,120 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeIncludeTestFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), defaultFilePermissions); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestConfigInclude(t *testing.T) {
+	dir := writeIncludeTestFiles(t, map[string]string{
+		"shared/base.xml": `
+		<seelog type="sync" minlevel="debug">
+			<outputs formatid="main"><console/></outputs>
+			<formats>
+				<format id="main" format="%Lev %Msg"/>
+				<format id="short" format="%l"/>
+			</formats>
+		</seelog>`,
+		"service.xml": `
+		<seelog minlevel="info">
+			<include file="shared/base.xml"/>
+			<formats><format id="main" format="%Msg"/></formats>
+			<outputs><console formatid="short"/></outputs>
+		</seelog>`,
+	})
+
+	config, err := unmarshalConfigFile(filepath.Join(dir, "service.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := configFromRoot(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := new(logConfig)
+	expected.Constraints, _ = newMinMaxConstraints(InfoLvl, CriticalLvl)
+	consoleWriter, _ := newConsoleWriter()
+	mainFormat, _ := newFormatter("%Msg")
+	shortFormat, _ := newFormatter("%l")
+	shortWriter, _ := newFormattedWriter(consoleWriter, shortFormat)
+	expected.RootDispatcher, _ = newSplitDispatcher(mainFormat, []interface{}{consoleWriter, shortWriter})
+	expected.LogType = syncloggerTypeFromString
+	if !configsAreEqual(conf, expected) {
+		t.Errorf("\n* Expected: %v.\n* Got: %v", expected, conf)
+	}
+}
+
+func TestConfigIncludeJson(t *testing.T) {
+	dir := writeIncludeTestFiles(t, map[string]string{
+		"base.xml": `<seelog><formats><format id="main" format="%Msg"/></formats></seelog>`,
+		"service.json": `{"seelog": {"type": "sync", "include": {"file": "base.xml"},
+			"outputs": {"formatid": "main", "console": {}}}}`,
+	})
+
+	if _, err := LoggerFromJSONFile(filepath.Join(dir, "service.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConfigIncludeErrors(t *testing.T) {
+	dir := writeIncludeTestFiles(t, map[string]string{
+		"a.xml":       `<seelog><include file="b.xml"/></seelog>`,
+		"b.xml":       `<seelog><include file="a.xml"/></seelog>`,
+		"missing.xml": `<seelog><include file="nothing.xml"/></seelog>`,
+		"noattr.xml":  `<seelog><include/></seelog>`,
+		"wrong.xml":   `<seelog><include file="root.xml"/></seelog>`,
+		"root.xml":    `<config/>`,
+	})
+
+	for _, name := range []string{"a.xml", "missing.xml", "noattr.xml", "wrong.xml"} {
+		_, err := LoggerFromConfigAsFile(filepath.Join(dir, name))
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+			continue
+		}
+		if name == "a.xml" && !strings.Contains(err.Error(), "cycle") {
+			t.Errorf("%s: expected cycle error, got: %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"fmt"
+)
+
+// IssueSeverity tells how serious a config issue is.
+type IssueSeverity int
+
+const (
+	// IssueWarning marks a config which works, but probably not as intended.
+	IssueWarning IssueSeverity = iota
+	// IssueError marks a config which can not be used or will misbehave.
+	IssueError
+)
+
+func (severity IssueSeverity) String() string {
+	switch severity {
+	case IssueWarning:
+		return "warning"
+	case IssueError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(severity))
+}
+
+// Issue describes a problem found in a config.
+type Issue struct {
+	Severity IssueSeverity
+	Rule     string // Short id of the check which found the issue, e.g. "unreferenced-format"
+	Path     string // Element path, e.g. "seelog/outputs/file[2]"
+	Line     int    // 1-based position of the element in the config; 0 if unknown
+	Column   int
+	Message  string // Human readable explanation
+}
+
+func (issue Issue) String() string {
+	position := issue.Path
+	if issue.Line > 0 {
+		position = fmt.Sprintf("%d:%d: %s", issue.Line, issue.Column, issue.Path)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", position, issue.Severity, issue.Message, issue.Rule)
+}
+
+// hasErrorIssues returns true if any of the issues is an error.
+func hasErrorIssues(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == IssueError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// JSON configs mirror the xml schema. The document is an object with a single
+// "seelog" member. Inside an element object:
+//     string, number and boolean members are attributes;
+//     object members are child elements named by the member key;
+//     array members are several child elements with the same name.
+// Member order is preserved, so outputs are created in the declared order.
+//
+// Example:
+//     {"seelog": {
+//         "type": "sync",
+//         "outputs": {"formatid": "main", "console": {}, "file": [{"path": "a.log"}, {"path": "b.log"}]},
+//         "formats": {"format": {"id": "main", "format": "%Level %Msg%n"}}
+//     }}
+//
+// Errors refer to elements by their path in the document (seelog.outputs.file[1]).
+
+// configFromJsonReader parses a JSON config from a given reader.
+func configFromJsonReader(reader io.Reader) (*logConfig, error) {
+	config, err := unmarshalJsonConfig(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return configFromRoot(config)
+}
+
+// unmarshalJsonConfig converts a JSON config to the node tree produced from xml configs.
+func unmarshalJsonConfig(reader io.Reader) (*xmlNode, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	if err := expectJsonDelim(decoder, '{', "config"); err != nil {
+		return nil, err
+	}
+
+	var root *xmlNode
+	for decoder.More() {
+		key, err := readJsonKey(decoder, "config")
+		if err != nil {
+			return nil, err
+		}
+		if root != nil {
+			return nil, errors.New("JSON config contains more than one root element: " + key)
+		}
+
+		root, err = unmarshalJsonElement(decoder, key, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if root == nil {
+		return nil, errors.New("JSON config has no content")
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, jsonSyntaxError(err, "config")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("JSON config contains data after the root object")
+	}
+
+	return root, nil
+}
+
+// unmarshalJsonElement reads an element object whose opening brace is the next token.
+func unmarshalJsonElement(decoder *json.Decoder, name string, path string) (*xmlNode, error) {
+	if err := expectJsonDelim(decoder, '{', path); err != nil {
+		return nil, err
+	}
+
+	return unmarshalJsonElementBody(decoder, name, path)
+}
+
+// unmarshalJsonElementBody reads an element object whose opening brace was already read.
+func unmarshalJsonElementBody(decoder *json.Decoder, name string, path string) (*xmlNode, error) {
+	node := newNode()
+	node.name = name
+	node.location = path
+
+	for decoder.More() {
+		key, err := readJsonKey(decoder, path)
+		if err != nil {
+			return nil, err
+		}
+		memberPath := path + "." + key
+
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, jsonSyntaxError(err, memberPath)
+		}
+
+		switch value := token.(type) {
+		case json.Delim:
+			if value == '{' {
+				child, err := unmarshalJsonElementBody(decoder, key, memberPath)
+				if err != nil {
+					return nil, err
+				}
+				node.add(child)
+				continue
+			}
+			if value != '[' {
+				return nil, errors.New(memberPath + ": unexpected '" + value.String() + "'")
+			}
+
+			for i := 0; decoder.More(); i++ {
+				child, err := unmarshalJsonElement(decoder, key, memberPath+"["+strconv.Itoa(i)+"]")
+				if err != nil {
+					return nil, err
+				}
+				node.add(child)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, jsonSyntaxError(err, memberPath)
+			}
+		case string:
+			err = addJsonAttribute(node, key, value, memberPath)
+		case json.Number:
+			err = addJsonAttribute(node, key, value.String(), memberPath)
+		case bool:
+			err = addJsonAttribute(node, key, strconv.FormatBool(value), memberPath)
+		default:
+			err = errors.New(memberPath + ": attribute value must be a string, number or boolean")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, jsonSyntaxError(err, path)
+	}
+
+	return node, nil
+}
+
+func addJsonAttribute(node *xmlNode, key string, value string, path string) error {
+	if _, exists := node.attributes[key]; exists {
+		return errors.New(path + ": duplicated attribute")
+	}
+	node.attributes[key] = value
+	return nil
+}
+
+func readJsonKey(decoder *json.Decoder, path string) (string, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return "", jsonSyntaxError(err, path)
+	}
+	return token.(string), nil
+}
+
+func expectJsonDelim(decoder *json.Decoder, delim json.Delim, path string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return jsonSyntaxError(err, path)
+	}
+	if token != delim {
+		return fmt.Errorf("%s: expected an object, got %v", path, token)
+	}
+	return nil
+}
+
+func jsonSyntaxError(err error, path string) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return errors.New(path + ": " + err.Error())
+}
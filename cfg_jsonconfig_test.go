@@ -0,0 +1,106 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJsonConfigMatchesXml(t *testing.T) {
+	xmlConfig := `
+	<seelog type="sync" minlevel="info">
+		<exceptions>
+			<exception funcpattern="*main.test*" minlevel="trace"/>
+		</exceptions>
+		<outputs formatid="main">
+			<console/>
+			<filter levels="error,critical">
+				<console formatid="short"/>
+			</filter>
+		</outputs>
+		<formats>
+			<format id="main" format="%Level %Msg%n"/>
+			<format id="short" format="%Lev %Msg%n"/>
+		</formats>
+	</seelog>`
+
+	jsonConfig := `{"seelog": {
+		"type": "sync",
+		"minlevel": "info",
+		"exceptions": {"exception": [{"funcpattern": "*main.test*", "minlevel": "trace"}]},
+		"outputs": {
+			"formatid": "main",
+			"console": {},
+			"filter": {"levels": "error,critical", "console": {"formatid": "short"}}
+		},
+		"formats": {"format": [
+			{"id": "main", "format": "%Level %Msg%n"},
+			{"id": "short", "format": "%Lev %Msg%n"}
+		]}
+	}}`
+
+	expected, err := configFromReader(strings.NewReader(xmlConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := configFromJsonReader(strings.NewReader(jsonConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !configsAreEqual(conf, expected) {
+		t.Errorf("\n* Expected: %v\n* Got: %v", expected, conf)
+	}
+}
+
+func TestJsonConfigErrors(t *testing.T) {
+	errorTests := []struct {
+		config   string
+		errorPos string
+	}{
+		{`{"seelog": {"outputs": {"file": [{"path": "a.log"}, {}]}}}`, "seelog.outputs.file[1]"},
+		{`{"seelog": {"outputs": {"console": {"formatid": null}}}}`, "seelog.outputs.console.formatid"},
+		{`{"seelog": {"outputs": {"unknown": {}}}}`, "seelog.outputs.unknown"},
+		{`{"seelog": {"outputs": {"console": {"color": "red"}}}}`, "seelog.outputs.console"},
+		{`{"seelog": {"outputs": {"file": ["a.log"]}}}`, "seelog.outputs.file[0]"},
+		{`{"seelog": {"outputs": {"console": {}`, "seelog.outputs"},
+		{`{"seelog": {}, "other": {}}`, "other"},
+		{`{}`, "no content"},
+		{`{"seelog": {"minlevel": "info", "minlevel": "debug"}}`, "seelog.minlevel"},
+	}
+
+	for _, test := range errorTests {
+		_, err := configFromJsonReader(strings.NewReader(test.config))
+		if err == nil {
+			t.Errorf("Expected error for config: %s", test.config)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.errorPos) {
+			t.Errorf("Error for config %s must point to %s, got: %s", test.config, test.errorPos, err)
+		}
+	}
+}
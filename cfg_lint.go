@@ -0,0 +1,409 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Profiles for LintConfig
+const (
+	LintProfileDefault    = ""
+	LintProfileProduction = "prod"
+)
+
+// Lint rule ids
+const (
+	lintUnreferencedFormatRule  = "unreferenced-format"
+	lintDuplicateOutputPathRule = "duplicate-output-path"
+	lintAsyncSmtpRule           = "async-smtp"
+	lintTraceInProductionRule   = "trace-in-prod"
+	lintEmptyLevelRangeRule     = "empty-level-range"
+	lintUnreachableOutputRule   = "unreachable-output"
+	lintPlaintextSecretRule     = "plaintext-secret"
+)
+
+// lintNode is a config element together with its path.
+type lintNode struct {
+	node *xmlNode
+	path string
+}
+
+// LintConfig checks a valid config for settings which are probably mistakes and returns
+// the found issues, sorted by path. The data is an xml config, or a JSON config if it
+// starts with '{'. Rules of the production profile additionally flag settings which are
+// fine during development only. Includes are not resolved.
+//
+// Rules:
+//     unreferenced-format    (warning) a declared format is not used by any output
+//     duplicate-output-path  (error)   several outputs write to the same file
+//     async-smtp             (warning) an async logger sends mail, which is lost if the
+//                                      process exits without Flush
+//     empty-level-range      (error)   minlevel is above maxlevel, so no level is allowed
+//     unreachable-output     (warning) a filter passes only levels its logger never writes
+//     trace-in-prod          (warning) production profile only: trace messages are allowed
+//     plaintext-secret       (warning) production profile only: a password is written in
+//                                      the config instead of a secret reference
+func LintConfig(data []byte, profile string) ([]Issue, error) {
+	config, err := unmarshalConfigData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return lintConfigNode(config, profile), nil
+}
+
+// unmarshalConfigData parses an xml config, or a JSON config if data starts with '{'.
+func unmarshalConfigData(data []byte) (*xmlNode, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return unmarshalJsonConfig(bytes.NewReader(data))
+	}
+	return unmarshalConfig(bytes.NewReader(data))
+}
+
+func lintConfigNode(config *xmlNode, profile string) []Issue {
+	root := lintNode{config, config.name}
+
+	// Bad custom levels are reported by ValidateConfig
+	levels, _ := declareCustomLevels(config)
+	setConfigLevels(config, levels)
+
+	var issues []Issue
+	issues = append(issues, lintFormats(root)...)
+	issues = append(issues, lintOutputPaths(root)...)
+	if profile == LintProfileProduction {
+		issues = append(issues, lintSecrets(root)...)
+	}
+
+	loggers := []lintNode{root}
+	for _, child := range lintChildren(root) {
+		if child.node.name == pipelineId {
+			loggers = append(loggers, child)
+		}
+	}
+	for _, logger := range loggers {
+		issues = append(issues, lintAsyncSmtp(logger)...)
+		issues = append(issues, lintLevelRanges(logger)...)
+		issues = append(issues, lintUnreachableOutputs(logger)...)
+		if profile == LintProfileProduction {
+			issues = append(issues, lintTraceLevel(logger)...)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Path < issues[j].Path
+	})
+	return issues
+}
+
+// lintChildren returns the children of a node with their paths. Children which share
+// a name with their siblings get a 1-based index.
+func lintChildren(parent lintNode) []lintNode {
+	counts := make(map[string]int)
+	for _, child := range parent.node.children {
+		counts[child.name]++
+	}
+
+	indexes := make(map[string]int)
+	children := make([]lintNode, len(parent.node.children))
+	for i, child := range parent.node.children {
+		path := parent.path + "/" + child.name
+		if counts[child.name] > 1 {
+			indexes[child.name]++
+			path += "[" + strconv.Itoa(indexes[child.name]) + "]"
+		}
+		children[i] = lintNode{child, path}
+	}
+	return children
+}
+
+// walkLint calls visit for all descendants of the node. Pipelines are skipped if
+// skipPipelines is set.
+func walkLint(parent lintNode, skipPipelines bool, visit func(lintNode)) {
+	for _, child := range lintChildren(parent) {
+		if skipPipelines && child.node.name == pipelineId {
+			continue
+		}
+		visit(child)
+		walkLint(child, skipPipelines, visit)
+	}
+}
+
+func lintFormats(root lintNode) []Issue {
+	referenced := make(map[string]bool)
+	var declared []lintNode
+
+	walkLint(root, false, func(n lintNode) {
+		if id, ok := n.node.attributes[outputFormatId]; ok {
+			referenced[id] = true
+		}
+		if n.node.name == formatId {
+			declared = append(declared, n)
+		}
+	})
+
+	var issues []Issue
+	for _, format := range declared {
+		id := format.node.attributes[formatKeyAttrId]
+		if !referenced[id] {
+			issues = append(issues, Issue{
+				Severity: IssueWarning,
+				Rule:     lintUnreferencedFormatRule,
+				Path:     format.path,
+				Line:     format.node.line,
+				Column:   format.node.column,
+				Message:  "Format '" + id + "' is not used by any output",
+			})
+		}
+	}
+	return issues
+}
+
+func lintOutputPaths(root lintNode) []Issue {
+	first := make(map[string]lintNode)
+
+	var issues []Issue
+	walkLint(root, false, func(n lintNode) {
+		var path string
+		switch n.node.name {
+		case fileWriterId:
+			path = n.node.attributes[pathId]
+		case rollingfileWriterId:
+			path = n.node.attributes[rollingFilePathAttr]
+		default:
+			return
+		}
+		if path == "" {
+			return
+		}
+
+		cleanPath := filepath.Clean(path)
+		if previous, ok := first[cleanPath]; ok {
+			issues = append(issues, Issue{
+				Severity: IssueError,
+				Rule:     lintDuplicateOutputPathRule,
+				Path:     n.path,
+				Line:     n.node.line,
+				Column:   n.node.column,
+				Message:  "File '" + path + "' is already written by " + previous.path,
+			})
+			return
+		}
+		first[cleanPath] = n
+	})
+	return issues
+}
+
+func lintAsyncSmtp(logger lintNode) []Issue {
+	loggerType := logger.node.attributes[loggerTypeFromStringAttr]
+	if loggerType == syncloggerTypeFromStringStr {
+		return nil
+	}
+
+	var issues []Issue
+	walkLint(logger, true, func(n lintNode) {
+		if n.node.name == smtpWriterId {
+			issues = append(issues, Issue{
+				Severity: IssueWarning,
+				Rule:     lintAsyncSmtpRule,
+				Path:     n.path,
+				Line:     n.node.line,
+				Column:   n.node.column,
+				Message:  "Mails of an async logger are lost if the process exits without Flush; use type=\"sync\" or flush before exit",
+			})
+		}
+	})
+	return issues
+}
+
+func lintTraceLevel(logger lintNode) []Issue {
+	attributes := logger.node.attributes
+	traceAllowed := false
+	if levels, ok := attributes[levelsId]; ok {
+		for _, level := range strings.Split(strings.Replace(levels, " ", "", -1), ",") {
+			traceAllowed = traceAllowed || level == TraceStr
+		}
+	} else {
+		minLevel, ok := attributes[minLevelId]
+		traceAllowed = !ok || minLevel == TraceStr
+	}
+
+	var issues []Issue
+	if traceAllowed {
+		issues = append(issues, Issue{
+			Severity: IssueWarning,
+			Rule:     lintTraceInProductionRule,
+			Path:     logger.path,
+			Line:     logger.node.line,
+			Column:   logger.node.column,
+			Message:  "Trace messages are allowed in production; set minlevel or levels",
+		})
+	}
+
+	walkLint(logger, true, func(n lintNode) {
+		if n.node.name == exceptionId && n.node.attributes[minLevelId] == TraceStr {
+			issues = append(issues, Issue{
+				Severity: IssueWarning,
+				Rule:     lintTraceInProductionRule,
+				Path:     n.path,
+				Line:     n.node.line,
+				Column:   n.node.column,
+				Message:  "Exception allows trace messages in production",
+			})
+		}
+	})
+	return issues
+}
+
+// lintLevelRanges flags the logger and its exceptions if their minlevel is above maxlevel.
+func lintLevelRanges(logger lintNode) []Issue {
+	nodes := []lintNode{logger}
+	walkLint(logger, true, func(n lintNode) {
+		if n.node.name == exceptionId {
+			nodes = append(nodes, n)
+		}
+	})
+
+	var issues []Issue
+	for _, n := range nodes {
+		minLevel, isMin := n.node.levels.fromString(n.node.attributes[minLevelId])
+		maxLevel, isMax := n.node.levels.fromString(n.node.attributes[maxLevelId])
+		if !isMin || !isMax || n.node.levels.severity(minLevel) <= n.node.levels.severity(maxLevel) {
+			continue
+		}
+		issues = append(issues, Issue{
+			Severity: IssueError,
+			Rule:     lintEmptyLevelRangeRule,
+			Path:     n.path,
+			Line:     n.node.line,
+			Column:   n.node.column,
+			Message:  "Min level " + n.node.levels.name(minLevel) + " is above max level " + n.node.levels.name(maxLevel) + ", no messages are allowed",
+		})
+	}
+	return issues
+}
+
+// lintUnreachableOutputs flags filters which pass none of the levels the logger writes,
+// taking its exceptions and the enclosing filters into account.
+func lintUnreachableOutputs(logger lintNode) []Issue {
+	allowed, ok := lintLoggerLevels(logger)
+	if !ok {
+		return nil
+	}
+
+	var issues []Issue
+	for _, child := range lintChildren(logger) {
+		if child.node.name == outputsId {
+			issues = append(issues, lintFilterLevels(child, allowed)...)
+		}
+	}
+	return issues
+}
+
+// lintLoggerLevels returns the levels allowed by the constraints of the logger or by any
+// of its exceptions. It returns false if some level attribute can not be parsed.
+func lintLoggerLevels(logger lintNode) (map[LogLevel]bool, bool) {
+	nodes := []*xmlNode{logger.node}
+	walkLint(logger, true, func(n lintNode) {
+		if n.node.name == exceptionId {
+			nodes = append(nodes, n.node)
+		}
+	})
+
+	allowed := make(map[LogLevel]bool)
+	for _, node := range nodes {
+		constraints, err := getConstraints(node)
+		if err != nil {
+			return nil, false
+		}
+		for _, level := range allowedLevels(constraints, node.levels) {
+			allowed[level] = true
+		}
+	}
+	return allowed, true
+}
+
+func lintFilterLevels(parent lintNode, allowed map[LogLevel]bool) []Issue {
+	var issues []Issue
+	for _, child := range lintChildren(parent) {
+		childAllowed := allowed
+		if child.node.name == filterDispatcherId {
+			levels, err := parseLevels(child.node.attributes[filterLevelsAttrId], child.node.levels)
+			if err != nil {
+				continue
+			}
+
+			childAllowed = make(map[LogLevel]bool)
+			var names []string
+			for _, level := range levels {
+				names = append(names, level.String())
+				if allowed[level] {
+					childAllowed[level] = true
+				}
+			}
+			if len(childAllowed) == 0 {
+				issues = append(issues, Issue{
+					Severity: IssueWarning,
+					Rule:     lintUnreachableOutputRule,
+					Path:     child.path,
+					Line:     child.node.line,
+					Column:   child.node.column,
+					Message:  "Filter passes only levels which never reach it: " + strings.Join(names, ","),
+				})
+				continue
+			}
+		}
+		issues = append(issues, lintFilterLevels(child, childAllowed)...)
+	}
+	return issues
+}
+
+// lintSecrets flags passwords which are neither secret references nor environment
+// variables, see SecretProvider.
+func lintSecrets(root lintNode) []Issue {
+	var issues []Issue
+	walkLint(root, false, func(n lintNode) {
+		if n.node.name != smtpWriterId {
+			return
+		}
+		password, ok := n.node.attributes[userPassId]
+		if !ok || password == "" || isSecretReference(password) || strings.Contains(password, "${") {
+			return
+		}
+		issues = append(issues, Issue{
+			Severity: IssueWarning,
+			Rule:     lintPlaintextSecretRule,
+			Path:     n.path,
+			Line:     n.node.line,
+			Column:   n.node.column,
+			Message:  "Password is written in the config; use a secret reference such as \"env://SMTP_PASSWORD\"",
+		})
+	})
+	return issues
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"reflect"
+	"testing"
+)
+
+type lintTest struct {
+	testName string
+	config   string
+	profile  string
+	expected []string // "rule path" of every issue
+}
+
+var lintTests = []lintTest{
+	{"Clean", `
+	<seelog type="sync" minlevel="info">
+		<outputs formatid="main"><file path="a.log"/></outputs>
+		<formats><format id="main" format="%Msg"/></formats>
+	</seelog>`, LintProfileProduction, nil},
+
+	{"Unreferenced format", `
+	<seelog>
+		<outputs><console formatid="used"/></outputs>
+		<formats>
+			<format id="used" format="%Msg"/>
+			<format id="unused" format="%Msg"/>
+		</formats>
+	</seelog>`, "", []string{"unreferenced-format seelog/formats/format[2]"}},
+
+	{"Duplicate paths", `
+	<seelog>
+		<outputs>
+			<file path="logs/a.log"/>
+			<filter levels="error"><rollingfile type="size" filename="./logs/a.log" maxsize="10" maxrolls="1"/></filter>
+		</outputs>
+		<pipeline name="audit"><outputs><file path="logs/a.log"/></outputs></pipeline>
+	</seelog>`, "", []string{
+		"duplicate-output-path seelog/outputs/filter/rollingfile",
+		"duplicate-output-path seelog/pipeline/outputs/file",
+	}},
+
+	{"Async smtp", `
+	<seelog>
+		<outputs>
+			<smtp senderaddress="a@b.c" sendername="a" hostname="h" hostport="25" username="u" password="p">
+				<recipient address="d@e.f"/>
+			</smtp>
+		</outputs>
+		<pipeline name="mail" type="sync"><outputs><smtp/></outputs></pipeline>
+	</seelog>`, "", []string{"async-smtp seelog/outputs/smtp"}},
+
+	{"Trace in production", `
+	<seelog levels="trace,error">
+		<outputs><console/></outputs>
+		<exceptions><exception funcpattern="main*" minlevel="trace"/></exceptions>
+		<pipeline name="a" type="sync" minlevel="debug"><outputs><console/></outputs></pipeline>
+		<pipeline name="b" type="sync"><outputs><console/></outputs></pipeline>
+	</seelog>`, LintProfileProduction, []string{
+		"trace-in-prod seelog",
+		"trace-in-prod seelog/exceptions/exception",
+		"trace-in-prod seelog/pipeline[2]",
+	}},
+
+	{"Empty level range", `
+	<seelog type="sync" minlevel="error" maxlevel="info">
+		<outputs><console/></outputs>
+		<exceptions><exception funcpattern="main*" minlevel="warn" maxlevel="debug"/></exceptions>
+		<pipeline name="a" type="sync" minlevel="info" maxlevel="error"><outputs><console/></outputs></pipeline>
+	</seelog>`, "", []string{
+		"empty-level-range seelog",
+		"empty-level-range seelog/exceptions/exception",
+	}},
+
+	{"Unreachable outputs", `
+	<seelog type="sync" minlevel="warn">
+		<outputs>
+			<filter levels="debug,info"><file path="debug.log"/></filter>
+			<filter levels="info,error">
+				<filter levels="info"><file path="info.log"/></filter>
+				<filter levels="error"><file path="error.log"/></filter>
+			</filter>
+		</outputs>
+		<exceptions><exception funcpattern="main*" levels="debug"/></exceptions>
+		<pipeline name="a" type="sync" levels="critical">
+			<outputs><filter levels="trace"><console/></filter></outputs>
+		</pipeline>
+	</seelog>`, "", []string{
+		"unreachable-output seelog/outputs/filter[2]/filter[1]",
+		"unreachable-output seelog/pipeline/outputs/filter",
+	}},
+
+	{"Plaintext secrets", `
+	<seelog type="sync" minlevel="info">
+		<outputs>
+			<smtp senderaddress="a@b.c" sendername="a" hostname="h" hostport="25" username="u" password="p"/>
+			<smtp senderaddress="a@b.c" sendername="a" hostname="h" hostport="25" username="u" password="env://SMTP_PASSWORD"/>
+			<smtp senderaddress="a@b.c" sendername="a" hostname="h" hostport="25" username="u" password="${SMTP_PASSWORD}"/>
+		</outputs>
+	</seelog>`, LintProfileProduction, []string{"plaintext-secret seelog/outputs/smtp[1]"}},
+
+	{"Trace in default profile", `<seelog type="sync"><outputs><console/></outputs></seelog>`, "", nil},
+}
+
+func TestLintConfig(t *testing.T) {
+	for _, test := range lintTests {
+		issues, err := LintConfig([]byte(test.config), test.profile)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.testName, err)
+			continue
+		}
+
+		var got []string
+		for _, issue := range issues {
+			got = append(got, issue.Rule+" "+issue.Path)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s:\n* Expected: %v\n* Got: %v", test.testName, test.expected, issues)
+		}
+	}
+
+	if _, err := LintConfig([]byte("<seelog>"), ""); err == nil {
+		t.Error("Expected error for a malformed config")
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	issue := Issue{IssueError, "rule", "seelog/outputs", 3, 7, "Something is wrong"}
+	if got := issue.String(); got != "3:7: seelog/outputs: error: Something is wrong (rule)" {
+		t.Errorf("Unexpected string: %s", got)
+	}
+}
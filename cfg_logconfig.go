@@ -26,6 +26,7 @@ package seelog
 
 import (
 	"errors"
+	"time"
 )
 
 type loggerTypeFromString uint8
@@ -83,6 +84,28 @@ type logConfig struct {
 	RootDispatcher dispatcherInterface  // Root of output tree
 	LogType        loggerTypeFromString
 	LoggerData     interface{}
+	QueueAge       *queueAgeLimit // Limit of the age of queued messages of async loggers; nil if not limited
+	Stack          *stackCapture  // Records which get a captured caller stack; nil if stacks are not captured
+	OverloadQueue  int            // Queue length at which async loggers are overloaded; 0 for MaxQueueSize
+	Levels         levelSet       // Custom levels declared by the config
+}
+
+// queueAgeLimit makes async loggers drop messages at MaxLevel and below which waited
+// in the queue longer than MaxAge.
+type queueAgeLimit struct {
+	MaxAge   time.Duration
+	MaxLevel LogLevel
+}
+
+// isStale returns true if the queued message must be dropped at time now.
+func (limit *queueAgeLimit) isStale(level LogLevel, context logContextInterface, now time.Time) bool {
+	if limit == nil || level.severity() > limit.MaxLevel.severity() {
+		return false
+	}
+	if _, isEvent := eventName(context); isEvent {
+		return false
+	}
+	return now.Sub(context.CallTime()) > limit.MaxAge
 }
 
 func newConfig(
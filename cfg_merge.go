@@ -0,0 +1,140 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"strconv"
+)
+
+// mergeConfigData parses the base config and the overlays and merges the overlays into
+// the base one by one, see LoggerFromConfigs.
+func mergeConfigData(base []byte, overlays [][]byte) (*xmlNode, error) {
+	config, err := unmarshalConfigData(base)
+	if err != nil {
+		return nil, err
+	}
+	if config.name != seelogConfigId {
+		return nil, errors.New("Root xml tag must be '" + seelogConfigId + "'")
+	}
+
+	for i, data := range overlays {
+		overlay, err := unmarshalConfigData(data)
+		if err != nil {
+			return nil, err
+		}
+		if overlay.name != seelogConfigId {
+			return nil, errors.New("Root xml tag of overlay " + strconv.Itoa(i+1) + " must be '" + seelogConfigId + "'")
+		}
+		mergeConfigNode(config, overlay)
+	}
+
+	return config, nil
+}
+
+// mergeConfigNode merges the overlay into the base logger node. Attributes of the overlay
+// win; its level attributes (minlevel, maxlevel, levels) replace those of the base as a
+// group. Sections are merged as for includes, with the children of the base first.
+// Exceptions of the overlay replace the base ones with the same patterns, and pipelines
+// with the same name are merged recursively.
+func mergeConfigNode(base *xmlNode, overlay *xmlNode) {
+	for _, name := range []string{minLevelId, maxLevelId, levelsId} {
+		if _, ok := overlay.attributes[name]; ok {
+			delete(base.attributes, minLevelId)
+			delete(base.attributes, maxLevelId)
+			delete(base.attributes, levelsId)
+			break
+		}
+	}
+	for name, value := range overlay.attributes {
+		base.attributes[name] = value
+	}
+
+	removeOverriddenExceptions(base, overlay)
+
+	children := make([]*xmlNode, 0, len(base.children)+len(overlay.children))
+	baseChildren := make(map[*xmlNode]bool)
+	pipelines := make(map[string]*xmlNode)
+	for _, child := range base.children {
+		baseChildren[child] = true
+		children = append(children, child)
+		if child.name == pipelineId {
+			pipelines[child.attributes[pipelineNameAttr]] = child
+		}
+	}
+	for _, child := range overlay.children {
+		if child.name == pipelineId {
+			if pipeline, ok := pipelines[child.attributes[pipelineNameAttr]]; ok {
+				mergeConfigNode(pipeline, child)
+				continue
+			}
+		}
+		children = append(children, child)
+	}
+
+	base.children = mergeIncludedSections(children, baseChildren)
+}
+
+// removeOverriddenExceptions removes the exceptions of the base which the overlay
+// declares again with the same patterns.
+func removeOverriddenExceptions(base *xmlNode, overlay *xmlNode) {
+	overridden := make(map[string]bool)
+	for _, section := range overlay.children {
+		if section.name != exceptionsId {
+			continue
+		}
+		for _, exception := range section.children {
+			overridden[exceptionPatternsKey(exception)] = true
+		}
+	}
+	if len(overridden) == 0 {
+		return
+	}
+
+	for _, section := range base.children {
+		if section.name != exceptionsId {
+			continue
+		}
+		var kept []*xmlNode
+		for _, exception := range section.children {
+			if !overridden[exceptionPatternsKey(exception)] {
+				kept = append(kept, exception)
+			}
+		}
+		section.children = kept
+	}
+}
+
+func exceptionPatternsKey(exception *xmlNode) string {
+	key := ""
+	for _, name := range []string{funcPatternId, filePatternId, packagePatternId} {
+		pattern, ok := exception.attributes[name]
+		if !ok {
+			pattern = "*"
+		}
+		key += pattern + "\x00"
+	}
+	return key
+}
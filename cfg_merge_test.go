@@ -0,0 +1,158 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mergeTestBase = `
+<seelog type="sync" minlevel="info" maxlevel="error">
+	<outputs formatid="main">
+		<console/>
+	</outputs>
+	<formats>
+		<format id="main" format="base %Msg%n"/>
+		<format id="short" format="%Msg"/>
+	</formats>
+	<exceptions>
+		<exception funcpattern="main.*" minlevel="warn"/>
+		<exception filepattern="*db*" minlevel="error"/>
+	</exceptions>
+	<pipeline name="audit" minlevel="warn">
+		<outputs><console/></outputs>
+	</pipeline>
+</seelog>`
+
+func TestMergeConfigs(t *testing.T) {
+	config, err := mergeConfigData([]byte(mergeTestBase), [][]byte{[]byte(`
+<seelog levels="debug,critical">
+	<outputs>
+		<file path="app.log" formatid="short"/>
+	</outputs>
+	<formats>
+		<format id="main" format="app %Msg%n"/>
+	</formats>
+	<exceptions>
+		<exception funcpattern="main.*" minlevel="trace"/>
+	</exceptions>
+	<pipeline name="audit" type="sync">
+		<outputs><file path="audit.log"/></outputs>
+	</pipeline>
+	<pipeline name="debug" type="sync">
+		<outputs><console/></outputs>
+	</pipeline>
+</seelog>`), []byte(`{"seelog": {"type": "asyncloop"}}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = prepareRootConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	description, err := describeLogger(config, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.Type != "asyncloop" {
+		t.Errorf("Later overlays must win: %s", description.Type)
+	}
+	if !reflect.DeepEqual(description.Levels, []LogLevel{DebugLvl, CriticalLvl}) {
+		t.Errorf("Level attributes must be replaced as a group: %v", description.Levels)
+	}
+
+	var outputs []string
+	for _, output := range description.Outputs {
+		outputs = append(outputs, output.Kind+":"+output.Format)
+	}
+	if strings.Join(outputs, ",") != "console:app %Msg%n,file:%Msg" {
+		t.Errorf("Unexpected outputs: %v", outputs)
+	}
+
+	var exceptions []string
+	for _, exception := range description.Exceptions {
+		exceptions = append(exceptions, exception.FuncPattern+" "+exception.FilePattern+" "+exception.Levels[0].String())
+	}
+	if strings.Join(exceptions, ",") != "* *db* error,main.* * trace" {
+		t.Errorf("Unexpected exceptions: %v", exceptions)
+	}
+
+	var pipelines []string
+	for _, child := range config.children {
+		if child.name == pipelineId {
+			pipeline, err := describeLogger(child, nil, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pipelines = append(pipelines, pipeline.Name+":"+pipeline.Type+":"+pipeline.Levels[0].String()+":"+
+				outputKinds(pipeline.Outputs))
+		}
+	}
+	if strings.Join(pipelines, ",") != "audit:sync:warn:console+file,debug:sync:trace:console" {
+		t.Errorf("Unexpected pipelines: %v", pipelines)
+	}
+}
+
+func outputKinds(outputs []*OutputDescription) string {
+	var kinds []string
+	for _, output := range outputs {
+		kinds = append(kinds, output.Kind)
+	}
+	return strings.Join(kinds, "+")
+}
+
+func TestLoggerFromConfigs(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "app.log")
+	logger, err := LoggerFromConfigs([]byte(`
+<seelog type="sync" minlevel="info">
+	<outputs formatid="main"><file path="`+fileName+`"/></outputs>
+	<formats><format id="main" format="base %Msg%n"/></formats>
+</seelog>`), []byte(`
+<seelog minlevel="debug">
+	<formats><format id="main" format="[%LEV] %Msg%n"/></formats>
+</seelog>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Trace("trace")
+	logger.Debug("debug")
+	logger.Close()
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[DBG] debug\n" {
+		t.Errorf("Unexpected log: %q", data)
+	}
+
+	if _, err := LoggerFromConfigs([]byte(mergeTestBase), []byte(`<logger/>`)); err == nil {
+		t.Error("Overlay with a wrong root element must be rejected")
+	}
+}
@@ -25,9 +25,15 @@
 package seelog
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -45,10 +51,13 @@ const (
 	exceptionId                     = "exception"
 	funcPatternId                   = "funcpattern"
 	filePatternId                   = "filepattern"
+	packagePatternId                = "package"
 	formatId                        = "format"
 	formatAttrId                    = "format"
 	formatKeyAttrId                 = "id"
+	formatLocaleAttr                = "locale"
 	outputFormatId                  = "formatid"
+	outputIdAttr                    = "id"
 	pathId                          = "path"
 	fileWriterId                    = "file"
 	smtpWriterId                    = "smtp"
@@ -61,6 +70,21 @@ const (
 	userNameId                      = "username"
 	userPassId                      = "password"
 	cACertDirpathId                 = "cacertdirpath"
+	smtpDigestAttr                  = "digest"
+	smtpDigestLevelsAttr            = "digestlevels"
+	smtpDigestCountAttr             = "digestcount"
+	smtpDigestIntervalAttr          = "digestinterval"
+	smtpDigestSubjectAttr           = "digestsubject"
+	smtpHtmlTemplateAttr            = "htmltemplate"
+	smtpContextAttr                 = "context"
+	smtpTLSAttr                     = "tls"
+	smtpTLSServerNameAttr           = "tlsservername"
+	smtpTLSSkipVerifyAttr           = "tlsskipverify"
+	smtpAuthAttr                    = "auth"
+	smtpTokenAttr                   = "token"
+	smtpDedupAttr                   = "dedup"
+	smtpLimitAttr                   = "limit"
+	smtpIntervalAttr                = "interval"
 	splitterDispatcherId            = "splitter"
 	consoleWriterId                 = "console"
 	filterDispatcherId              = "filter"
@@ -86,6 +110,308 @@ const (
 	connWriterAddrAttr              = "addr"
 	connWriterNetAttr               = "net"
 	connWriterReconnectOnMsgAttr    = "reconnectonmsg"
+	connWriterTLSAttr               = "tls"
+	connWriterCACertAttr            = "cacert"
+	connWriterCertAttr              = "cert"
+	connWriterKeyAttr               = "key"
+	connWriterServerNameAttr        = "servername"
+	connWriterReconnectAttr         = "reconnect"
+	connWriterMaxReconnectAttr      = "maxreconnect"
+	connWriterTimeoutAttr           = "timeout"
+	connWriterBufferSizeAttr        = "buffersize"
+	connWriterBufferPathAttr        = "bufferpath"
+	connWriterBufferFileSizeAttr    = "bufferfilesize"
+	connWriterWriteTimeoutAttr      = "writetimeout"
+	connWriterOverflowAttr          = "overflow"
+	syslogWriterId                  = "syslog"
+	syslogNetAttr                   = "net"
+	syslogAddrAttr                  = "addr"
+	syslogRFCAttr                   = "rfc"
+	syslogFacilityAttr              = "facility"
+	syslogTagAttr                   = "tag"
+	syslogSeveritiesAttr            = "severities"
+	journaldWriterId                = "journald"
+	journaldSocketAttr              = "socket"
+	fluentWriterId                  = "fluent"
+	fluentAddrAttr                  = "addr"
+	fluentTagAttr                   = "tag"
+	fluentAckAttr                   = "ack"
+	fluentTLSAttr                   = "tls"
+	fluentCACertDirAttr             = "cacertdirpath"
+	fluentTimeoutAttr               = "timeout"
+	gelfWriterId                    = "gelf"
+	gelfNetAttr                     = "net"
+	gelfAddrAttr                    = "addr"
+	gelfHostAttr                    = "host"
+	gelfCompressAttr                = "compress"
+	gelfChunkSizeAttr               = "chunksize"
+	gelfTimeoutAttr                 = "timeout"
+	logstashWriterId                = "logstash"
+	logstashAddrAttr                = "addr"
+	logstashTLSAttr                 = "tls"
+	logstashCACertDirAttr           = "cacertdirpath"
+	logstashTimeoutAttr             = "timeout"
+	logstashKeepAliveAttr           = "keepalive"
+	logstashBufferSizeAttr          = "buffersize"
+	logstashReconnectAttr           = "reconnect"
+	websocketWriterId               = "websocket"
+	websocketAddrAttr               = "addr"
+	websocketPathAttr               = "path"
+	websocketBufferAttr             = "buffer"
+	udpWriterId                     = "udp"
+	udpAddrAttr                     = "addr"
+	udpMaxSizeAttr                  = "maxsize"
+	udpLoadAttr                     = "load"
+	udpSampleAttr                   = "sample"
+	grpcWriterId                    = "grpc"
+	grpcAddrAttr                    = "addr"
+	grpcMethodAttr                  = "method"
+	grpcTLSAttr                     = "tls"
+	grpcCACertDirAttr               = "cacertdirpath"
+	grpcCertAttr                    = "cert"
+	grpcKeyAttr                     = "key"
+	grpcTokenAttr                   = "token"
+	grpcWindowAttr                  = "window"
+	grpcTimeoutAttr                 = "timeout"
+	grpcReconnectAttr               = "reconnect"
+	httpWriterId                    = "http"
+	httpUrlAttr                     = "url"
+	httpBatchAttr                   = "batch"
+	httpContentTypeAttr             = "contenttype"
+	httpCompressionAttr             = "compression"
+	httpUsernameAttr                = "username"
+	httpPasswordAttr                = "password"
+	httpTokenAttr                   = "token"
+	httpMaxCountAttr                = "maxcount"
+	httpMaxSizeAttr                 = "maxsize"
+	httpMaxIntervalAttr             = "maxinterval"
+	httpRetriesAttr                 = "retries"
+	httpBackoffAttr                 = "backoff"
+	httpTimeoutAttr                 = "timeout"
+	httpBufferPathAttr              = "bufferpath"
+	httpBufferSizeAttr              = "buffersize"
+	httpHeaderId                    = "header"
+	httpHeaderNameAttr              = "name"
+	httpHeaderValueAttr             = "value"
+	redisWriterId                   = "redis"
+	redisAddrAttr                   = "addr"
+	redisModeAttr                   = "mode"
+	redisKeyAttr                    = "key"
+	redisPasswordAttr               = "password"
+	redisDbAttr                     = "db"
+	redisPoolSizeAttr               = "poolsize"
+	redisTimeoutAttr                = "timeout"
+	multiplexWriterId               = "multiplex"
+	multiplexStreamAttr             = "stream"
+	multiplexChannelAttr            = "channel"
+	multiplexFramingAttr            = "framing"
+	eventLogWriterId                = "eventlog"
+	eventLogSourceAttr              = "source"
+	eventLogEventIdAttr             = "eventid"
+	eventLogInstallAttr             = "install"
+	objectStoreWriterId             = "objectstore"
+	objectStoreEndpointAttr         = "endpoint"
+	objectStoreBucketAttr           = "bucket"
+	objectStorePrefixAttr           = "prefix"
+	objectStoreRegionAttr           = "region"
+	objectStoreAccessKeyAttr        = "accesskey"
+	objectStoreSecretKeyAttr        = "secretkey"
+	objectStoreMaxSizeAttr          = "maxsize"
+	objectStoreMaxIntervalAttr      = "maxinterval"
+	s3ArchiveWriterId               = "s3archive"
+	gcsArchiveWriterId              = "gcsarchive"
+	gcsArchiveEndpointAttr          = "endpoint"
+	gcsArchiveBucketAttr            = "bucket"
+	gcsArchiveCredentialsAttr       = "credentials"
+	gcsArchiveChunkSizeAttr         = "chunksize"
+	azureBlobWriterId               = "azureblob"
+	azureBlobAccountAttr            = "account"
+	azureBlobEndpointAttr           = "endpoint"
+	azureBlobContainerAttr          = "container"
+	azureBlobNameAttr               = "blob"
+	azureBlobServiceAttr            = "service"
+	azureBlobSasAttr                = "sas"
+	azureBlobClientIdAttr           = "clientid"
+	azureBlobMaxSizeAttr            = "maxsize"
+	azureBlobMaxIntervalAttr        = "maxinterval"
+	sqliteWriterId                  = "sqlite"
+	sqliteDriverAttr                = "driver"
+	sqlitePathAttr                  = "path"
+	sqliteTableAttr                 = "table"
+	sqliteMaxCountAttr              = "maxcount"
+	sqliteMaxIntervalAttr           = "maxinterval"
+	sqliteMaxRowsAttr               = "maxrows"
+	sqliteMaxSizeAttr               = "maxsize"
+	postgresWriterId                = "postgres"
+	postgresAddrAttr                = "addr"
+	postgresUserAttr                = "user"
+	postgresPasswordAttr            = "password"
+	postgresDatabaseAttr            = "database"
+	postgresTableAttr               = "table"
+	postgresTLSAttr                 = "tls"
+	postgresCACertDirAttr           = "cacertdirpath"
+	postgresMaxCountAttr            = "maxcount"
+	postgresMaxIntervalAttr         = "maxinterval"
+	postgresTimeoutAttr             = "timeout"
+	postgresColumnId                = "column"
+	postgresColumnNameAttr          = "name"
+	postgresColumnValueAttr         = "value"
+	postgresColumnFieldAttr         = "field"
+	mongoWriterId                   = "mongodb"
+	mongoAddrAttr                   = "addr"
+	mongoDatabaseAttr               = "database"
+	mongoCollectionAttr             = "collection"
+	mongoUserAttr                   = "user"
+	mongoPasswordAttr               = "password"
+	mongoAuthSourceAttr             = "authsource"
+	mongoTLSAttr                    = "tls"
+	mongoCACertDirAttr              = "cacertdirpath"
+	mongoCappedAttr                 = "capped"
+	mongoTTLAttr                    = "ttl"
+	mongoWAttr                      = "w"
+	mongoJournalAttr                = "journal"
+	mongoWTimeoutAttr               = "wtimeout"
+	mongoMaxCountAttr               = "maxcount"
+	mongoMaxIntervalAttr            = "maxinterval"
+	mongoTimeoutAttr                = "timeout"
+	clickhouseWriterId              = "clickhouse"
+	clickhouseDatabaseAttr          = "database"
+	clickhouseTableAttr             = "table"
+	clickhouseUserAttr              = "user"
+	clickhouseAsyncAttr             = "async"
+	influxWriterId                  = "influxdb"
+	influxOrgAttr                   = "org"
+	influxBucketAttr                = "bucket"
+	influxDatabaseAttr              = "database"
+	influxMeasurementAttr           = "measurement"
+	influxTagsAttr                  = "tags"
+	influxPrecisionAttr             = "precision"
+	slackWriterId                   = "slack"
+	slackChannelAttr                = "channel"
+	slackUsernameAttr               = "username"
+	slackIconAttr                   = "icon"
+	slackDedupAttr                  = "dedup"
+	slackLimitAttr                  = "limit"
+	slackIntervalAttr               = "interval"
+	slackRouteId                    = "route"
+	telegramWriterId                = "telegram"
+	telegramTokenAttr               = "token"
+	telegramChatsAttr               = "chats"
+	telegramAPIAttr                 = "api"
+	telegramDelayAttr               = "delay"
+	telegramLimitAttr               = "limit"
+	telegramIntervalAttr            = "interval"
+	telegramTimeoutAttr             = "timeout"
+	pagerDutyWriterId               = "pagerduty"
+	pagerDutyRoutingKeyAttr         = "routingkey"
+	pagerDutyDedupKeyAttr           = "dedupkey"
+	pagerDutySourceAttr             = "source"
+	pagerDutyComponentAttr          = "component"
+	pagerDutyGroupAttr              = "group"
+	pagerDutyClassAttr              = "class"
+	pagerDutyRepeatAttr             = "repeat"
+	pagerDutyAutoResolveAttr        = "autoresolve"
+	datadogWriterId                 = "datadog"
+	datadogApiKeyAttr               = "apikey"
+	datadogSiteAttr                 = "site"
+	datadogSourceAttr               = "source"
+	datadogTagsAttr                 = "tags"
+	datadogServiceAttr              = "service"
+	datadogHostnameAttr             = "hostname"
+	splunkWriterId                  = "splunk"
+	splunkIndexAttr                 = "index"
+	splunkSourceAttr                = "source"
+	splunkSourceTypeAttr            = "sourcetype"
+	splunkHostAttr                  = "host"
+	splunkAckAttr                   = "ack"
+	splunkChannelAttr               = "channel"
+	splunkAckTimeoutAttr            = "acktimeout"
+	cloudWatchWriterId              = "cloudwatch"
+	cloudWatchGroupAttr             = "group"
+	cloudWatchStreamAttr            = "stream"
+	cloudWatchRetentionAttr         = "retention"
+	cloudLoggingWriterId            = "cloudlogging"
+	cloudLoggingProjectAttr         = "project"
+	cloudLoggingLogAttr             = "log"
+	cloudLoggingLabelsAttr          = "labels"
+	azureMonitorWriterId            = "azuremonitor"
+	azureMonitorWorkspaceAttr       = "workspace"
+	azureMonitorSharedKeyAttr       = "sharedkey"
+	azureMonitorLogTypeAttr         = "logtype"
+	azureMonitorResourceIdAttr      = "resourceid"
+	statsdWriterId                  = "statsd"
+	statsdPrefixAttr                = "prefix"
+	statsdTagsAttr                  = "tags"
+	statsdTimersAttr                = "timers"
+	statsdIntervalAttr              = "interval"
+	discardWriterId                 = "discard"
+	discardNameAttr                 = "name"
+	ringBufferWriterId              = "ringbuffer"
+	ringBufferNameAttr              = "name"
+	ringBufferSizeAttr              = "size"
+	mqttWriterId                    = "mqtt"
+	mqttTopicAttr                   = "topic"
+	mqttQosAttr                     = "qos"
+	mqttRetainAttr                  = "retain"
+	mqttClientIdAttr                = "clientid"
+	mqttUserAttr                    = "user"
+	mqttTLSAttr                     = "tls"
+	mqttCACertDirAttr               = "cacertdirpath"
+	mqttKeepAliveAttr               = "keepalive"
+	zmqWriterId                     = "zmq"
+	zmqEndpointAttr                 = "endpoint"
+	zmqTypeAttr                     = "type"
+	zmqBindAttr                     = "bind"
+	zmqTopicAttr                    = "topic"
+	zmqHwmAttr                      = "hwm"
+	fifoWriterId                    = "fifo"
+	fifoCreateAttr                  = "create"
+	fifoBufferSizeAttr              = "buffersize"
+	fifoRetryAttr                   = "retry"
+	fifoTimeoutAttr                 = "timeout"
+	archiveDirAttr                  = "dir"
+	archiveKeyAttr                  = "key"
+	archiveServiceAttr              = "service"
+	archiveMaxSizeAttr              = "maxsize"
+	archiveMaxIntervalAttr          = "maxinterval"
+	archiveBufferSizeAttr           = "buffersize"
+	elasticsearchWriterId           = "elasticsearch"
+	elasticsearchEndpointAttr       = "endpoint"
+	elasticsearchIndexAttr          = "index"
+	elasticsearchUsernameAttr       = "username"
+	elasticsearchPasswordAttr       = "password"
+	elasticsearchApiKeyAttr         = "apikey"
+	elasticsearchMaxCountAttr       = "maxcount"
+	elasticsearchMaxSizeAttr        = "maxsize"
+	elasticsearchMaxIntervalAttr    = "maxinterval"
+	elasticsearchRetriesAttr        = "retries"
+	elasticsearchBackoffAttr        = "backoff"
+	elasticsearchCompressionAttr    = "compression"
+	pipelineId                      = "pipeline"
+	pipelineNameAttr                = "name"
+	sloCounterId                    = "slo"
+	sloNameAttr                     = "name"
+	sloEventRuleId                  = "event"
+	sloBadRuleId                    = "bad"
+	sloRuleLevelsAttr               = "levels"
+	sloRuleMsgAttr                  = "msg"
+	sloRuleFieldAttr                = "field"
+	sloRuleOpAttr                   = "op"
+	sloRuleValueAttr                = "value"
+	eventsDispatcherId              = "events"
+	samplerDispatcherId             = "sampler"
+	samplerRateAttr                 = "rate"
+	samplerLimitAttr                = "limit"
+	samplerBurstAttr                = "burst"
+	maxQueueAgeAttr                 = "maxqueueage"
+	maxQueueAgeLevelAttr            = "maxqueueagelevel"
+	stackDepthAttr                  = "stackdepth"
+	stackLevelAttr                  = "stacklevel"
+	overloadQueueAttr               = "overloadqueue"
+	customLevelsId                  = "customlevels"
+	customLevelId                   = "level"
+	customLevelNameAttr             = "name"
+	customLevelAboveAttr            = "above"
 )
 
 type elementMapEntry struct {
@@ -105,6 +431,45 @@ func init() {
 		bufferedWriterId:    {createbufferedWriter},
 		smtpWriterId:        {createSmtpWriter},
 		connWriterId:        {createconnWriter},
+		syslogWriterId:      {createSyslogWriter},
+		journaldWriterId:    {createJournaldWriter},
+		objectStoreWriterId: {createObjectStoreWriter},
+		s3ArchiveWriterId:   {createS3ArchiveWriter},
+		gcsArchiveWriterId:  {createGcsArchiveWriter},
+		azureBlobWriterId:   {createAzureBlobWriter},
+		sqliteWriterId:      {createSqliteWriter},
+		postgresWriterId:    {createPostgresWriter},
+		mongoWriterId:       {createMongoWriter},
+		clickhouseWriterId:  {createClickhouseWriter},
+		influxWriterId:      {createInfluxWriter},
+		slackWriterId:       {createSlackWriter},
+		telegramWriterId:    {createTelegramWriter},
+		pagerDutyWriterId:   {createPagerDutyWriter},
+		datadogWriterId:     {createDatadogWriter},
+		splunkWriterId:      {createSplunkWriter},
+		cloudWatchWriterId:  {createCloudWatchWriter},
+		cloudLoggingWriterId: {createCloudLoggingWriter},
+		azureMonitorWriterId: {createAzureMonitorWriter},
+		statsdWriterId:      {createStatsdWriter},
+		discardWriterId:     {createDiscardWriter},
+		ringBufferWriterId:  {createRingBufferWriter},
+		mqttWriterId:        {createMqttWriter},
+		zmqWriterId:         {createZmqWriter},
+		fifoWriterId:        {createFifoWriter},
+		eventLogWriterId:    {createEventLogWriter},
+		multiplexWriterId:   {createMultiplexWriter},
+		redisWriterId:       {createRedisWriter},
+		httpWriterId:        {createHttpWriter},
+		grpcWriterId:        {createGrpcWriter},
+		udpWriterId:         {createUdpWriter},
+		websocketWriterId:   {createWebsocketWriter},
+		fluentWriterId:      {createFluentWriter},
+		logstashWriterId:    {createLogstashWriter},
+		gelfWriterId:        {createGelfWriter},
+		elasticsearchWriterId: {createElasticsearchWriter},
+		sloCounterId:        {createSloCounter},
+		eventsDispatcherId:  {createEvents},
+		samplerDispatcherId: {createSampler},
 	}
 
 	err := fillPredefinedFormats()
@@ -124,6 +489,8 @@ func fillPredefinedFormats() error {
 		"json-debug-short": `{"t":%Ns,"l":"%Lev","m":"%Msg","p":"%RelFile","f":"%Func"}`,
 		"json":             `{"time":%Ns,"lev":"%Lev","msg":"%Msg"}`,
 		"json-short":       `{"t":%Ns,"l":"%Lev","m":"%Msg"}`,
+		"json-fields":      `%Json%n`,
+		"msgpack":          `%MsgPack`,
 
 		"debug":       `[%LEVEL] %RelFile:%Func.%Line %Date %Time %Msg%n`,
 		"debug-short": `[%LEVEL] %Date %Time %Msg%n`,
@@ -153,12 +520,225 @@ func configFromReader(reader io.Reader) (*logConfig, error) {
 		return nil, err
 	}
 
+	return configFromRoot(config)
+}
+
+// configFromRoot creates a config from the root node of a parsed config document.
+// The overrides of the flags registered by RegisterFlags are applied.
+func configFromRoot(config *xmlNode) (*logConfig, error) {
+	err := prepareRootConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	applyFlagOverrides(config)
+
+	return configFromNode(config, nil, false)
+}
+
+// pipelineConfigFromReader parses data from a given reader and returns the config
+// of the pipeline with the given name. Formats declared in the root node are
+// available to all pipelines; a pipeline may override them with its own formats.
+func pipelineConfigFromReader(reader io.Reader, name string) (*logConfig, error) {
+	config, err := unmarshalConfig(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return pipelineConfigFromRoot(config, name)
+}
+
+func pipelineConfigFromRoot(config *xmlNode, name string) (*logConfig, error) {
+	err := prepareRootConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	applyFlagOverrides(config)
+
+	sharedFormats, err := getFormats(config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range config.children {
+		if child.name == pipelineId && child.attributes[pipelineNameAttr] == name {
+			return configFromNode(child, sharedFormats, true)
+		}
+	}
+
+	return nil, errors.New("Pipeline not found: " + name)
+}
+
+// prepareRootConfig resolves conditional sections and includes, expands environment
+// variables in the parsed config, checks its root node and declares its custom levels. In the upstream compatibility mode the config is only checked
+// against the upstream schema.
+func prepareRootConfig(config *xmlNode) error {
+	if UpstreamCompatible() {
+		err := checkRootConfig(config)
+		if err != nil {
+			return err
+		}
+		return checkUpstreamSchema(config)
+	}
+
+	err := resolveConditions(config)
+	if err != nil {
+		return err
+	}
+
+	err = resolveIncludes(config)
+	if err != nil {
+		return err
+	}
+
+	err = expandConfigEnv(config)
+	if err != nil {
+		return err
+	}
+
+	err = checkRootConfig(config)
+	if err != nil {
+		return err
+	}
+
+	levels, err := declareCustomLevels(config)
+	if err != nil {
+		return err
+	}
+	setConfigLevels(config, levels)
+
+	return nil
+}
+
+func checkRootConfig(config *xmlNode) error {
 	if config.name != seelogConfigId {
-		return nil, errors.New("Root xml tag must be '" + seelogConfigId + "'")
+		return errors.New("Root xml tag must be '" + seelogConfigId + "'")
+	}
+
+	return checkPipelines(config)
+}
+
+// declareCustomLevels returns the levels declared in the customlevels section of the root
+// node. They are not registered: the config keeps them (see setConfigLevels) and they
+// become known to LogLevelFromString when a logger is created from the config.
+//
+// Example:
+//     <customlevels>
+//         <level name="notice" above="info"/>
+//         <level name="audit" above="critical"/>
+//     </customlevels>
+func declareCustomLevels(config *xmlNode) (levelSet, error) {
+	var levels levelSet
+	for _, section := range config.children {
+		if section.name != customLevelsId {
+			continue
+		}
+		if levels == nil {
+			levels = make(levelSet)
+		}
+
+		err := checkUnexpectedAttribute(section)
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkExpectedElements(section, multipleMandatoryElements(customLevelId))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, levelNode := range section.children {
+			err = checkUnexpectedAttribute(levelNode, customLevelNameAttr, customLevelAboveAttr)
+			if err != nil {
+				return nil, err
+			}
+
+			name, ok := levelNode.attributes[customLevelNameAttr]
+			if !ok {
+				return nil, newMissingArgumentError(levelNode.errorName(), customLevelNameAttr)
+			}
+			aboveStr, ok := levelNode.attributes[customLevelAboveAttr]
+			if !ok {
+				return nil, newMissingArgumentError(levelNode.errorName(), customLevelAboveAttr)
+			}
+			above, found := levels.fromString(aboveStr)
+			if !found {
+				return nil, errors.New("Declared level not found: " + aboveStr)
+			}
+
+			_, err = levels.declareLevel(name, above)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return levels, nil
+}
+
+// setConfigLevels makes the levels declared by a config available to all of its nodes.
+func setConfigLevels(node *xmlNode, levels levelSet) {
+	node.levels = levels
+	for _, child := range node.children {
+		setConfigLevels(child, levels)
+	}
+}
+
+// checkPipelines checks that all pipelines of the root node have distinct non-empty names.
+func checkPipelines(config *xmlNode) error {
+	names := make(map[string]bool)
+	for _, child := range config.children {
+		if child.name != pipelineId {
+			continue
+		}
+
+		name, ok := child.attributes[pipelineNameAttr]
+		if !ok || name == "" {
+			return errors.New("Pipeline has no '" + pipelineNameAttr + "' attribute")
+		}
+		if names[name] {
+			return errors.New("Duplicate pipeline name: " + name)
+		}
+		names[name] = true
+	}
+
+	return nil
+}
+
+// checkOutputIds checks that the ids of the receivers in the outputs section of a root
+// or pipeline node are non-empty and distinct.
+func checkOutputIds(config *xmlNode) error {
+	ids := make(map[string]bool)
+	var check func(node *xmlNode) error
+	check = func(node *xmlNode) error {
+		for _, child := range node.children {
+			if id, ok := child.attributes[outputIdAttr]; ok {
+				if id == "" {
+					return errors.New("Output '" + child.errorName() + "' has an empty '" + outputIdAttr + "' attribute")
+				}
+				if ids[id] {
+					return errors.New("Duplicate output id: " + id)
+				}
+				ids[id] = true
+			}
+			if err := check(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, child := range config.children {
+		if child.name == outputsId {
+			return check(child)
+		}
 	}
+	return nil
+}
 
-	err = checkUnexpectedAttribute(
-		config,
+// configFromNode creates a config from the root node or from a pipeline node.
+// Formats from sharedFormats are used unless the node declares formats with the same ids.
+func configFromNode(config *xmlNode, sharedFormats map[string]*formatter, isPipeline bool) (*logConfig, error) {
+	expectedAttrs := []string{
 		minLevelId,
 		maxLevelId,
 		levelsId,
@@ -167,12 +747,29 @@ func configFromReader(reader io.Reader) (*logConfig, error) {
 		adaptLoggerMinIntervalAttr,
 		adaptLoggerMaxIntervalAttr,
 		adaptLoggerCriticalMsgCountAttr,
-	)
+		maxQueueAgeAttr,
+		maxQueueAgeLevelAttr,
+		stackDepthAttr,
+		stackLevelAttr,
+		overloadQueueAttr,
+	}
+	expectedElements := []expectedElementInfo{
+		optionalElement(outputsId),
+		optionalElement(formatsId),
+		optionalElement(exceptionsId),
+	}
+	if isPipeline {
+		expectedAttrs = append(expectedAttrs, pipelineNameAttr)
+	} else {
+		expectedElements = append(expectedElements, multipleElements(pipelineId), optionalElement(customLevelsId))
+	}
+
+	err := checkUnexpectedAttribute(config, expectedAttrs...)
 	if err != nil {
 		return nil, err
 	}
 
-	err = checkExpectedElements(config, optionalElement(outputsId), optionalElement(formatsId), optionalElement(exceptionsId))
+	err = checkExpectedElements(config, expectedElements...)
 	if err != nil {
 		return nil, err
 	}
@@ -195,6 +792,16 @@ func configFromReader(reader io.Reader) (*logConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	for id, sharedFormat := range sharedFormats {
+		if _, ok := formats[id]; !ok {
+			formats[id] = sharedFormat
+		}
+	}
+
+	err = checkOutputIds(config)
+	if err != nil {
+		return nil, err
+	}
 
 	dispatcher, err := getOutputsTree(config, formats)
 	if err != nil {
@@ -212,7 +819,122 @@ func configFromReader(reader io.Reader) (*logConfig, error) {
 		return nil, err
 	}
 
-	return newConfig(constraints, exceptions, dispatcher, loggerType, logData)
+	queueAge, err := getQueueAgeLimit(config, loggerType)
+	if err != nil {
+		return nil, err
+	}
+
+	stack, err := getStackCapture(config)
+	if err != nil {
+		return nil, err
+	}
+
+	overloadQueue, err := getOverloadQueue(config, loggerType)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := newConfig(constraints, exceptions, dispatcher, loggerType, logData)
+	if err != nil {
+		return nil, err
+	}
+	conf.QueueAge = queueAge
+	conf.Stack = stack
+	conf.OverloadQueue = overloadQueue
+	conf.Levels = config.levels
+
+	return conf, nil
+}
+
+// getQueueAgeLimit parses the limit of the age of queued messages. The age is a duration
+// like "30s"; messages at the level given by the maxqueueagelevel attribute (info by default)
+// and below are dropped when they get older.
+func getQueueAgeLimit(config *xmlNode, loggerType loggerTypeFromString) (*queueAgeLimit, error) {
+	maxAgeStr, isMaxAge := config.attributes[maxQueueAgeAttr]
+	levelStr, isLevel := config.attributes[maxQueueAgeLevelAttr]
+	if !isMaxAge {
+		if isLevel {
+			return nil, newMissingArgumentError(config.errorName(), maxQueueAgeAttr)
+		}
+		return nil, nil
+	}
+
+	if loggerType == syncloggerTypeFromString {
+		return nil, errors.New("'" + maxQueueAgeAttr + "' can not be used with the sync logger")
+	}
+
+	maxAge, err := time.ParseDuration(maxAgeStr)
+	if err != nil {
+		return nil, err
+	}
+	if maxAge <= 0 {
+		return nil, errors.New("'" + maxQueueAgeAttr + "' must be positive. Got: " + maxAgeStr)
+	}
+
+	maxLevel := LogLevel(InfoLvl)
+	if isLevel {
+		var found bool
+		maxLevel, found = config.levels.fromString(levelStr)
+		if !found || maxLevel == Off {
+			return nil, errors.New("Declared level not found: " + levelStr)
+		}
+	}
+
+	return &queueAgeLimit{maxAge, maxLevel}, nil
+}
+
+// getOverloadQueue parses the queue length at which an async logger is overloaded, see
+// SubscribeOverload. 0 is returned if the attribute is not set.
+func getOverloadQueue(config *xmlNode, loggerType loggerTypeFromString) (int, error) {
+	lengthStr, isLength := config.attributes[overloadQueueAttr]
+	if !isLength {
+		return 0, nil
+	}
+
+	if loggerType == syncloggerTypeFromString {
+		return 0, errors.New("'" + overloadQueueAttr + "' can not be used with the sync logger")
+	}
+
+	length, err := strconv.Atoi(lengthStr)
+	if err != nil {
+		return 0, err
+	}
+	if length <= 0 || length > MaxQueueSize {
+		return 0, fmt.Errorf("'%s' must be between 1 and %d. Got: %s", overloadQueueAttr, MaxQueueSize, lengthStr)
+	}
+
+	return length, nil
+}
+
+// getStackCapture parses which records get a captured caller stack, see StackFrame.
+func getStackCapture(config *xmlNode) (*stackCapture, error) {
+	depthStr, isDepth := config.attributes[stackDepthAttr]
+	levelStr, isLevel := config.attributes[stackLevelAttr]
+	if !isDepth {
+		if isLevel {
+			return nil, newMissingArgumentError(config.errorName(), stackDepthAttr)
+		}
+		return nil, nil
+	}
+
+	depth, err := strconv.Atoi(depthStr)
+	if err != nil {
+		return nil, err
+	}
+	if depth <= 0 {
+		return nil, errors.New("'" + stackDepthAttr + "' must be positive. Got: " + depthStr)
+	}
+
+	minLevel := LogLevel(ErrorLvl)
+	if isLevel {
+		var found bool
+		minLevel, found = config.levels.fromString(levelStr)
+		if !found || minLevel == Off {
+			return nil, errors.New("Declared level not found: " + levelStr)
+		}
+	}
+
+	return &stackCapture{depth, minLevel}, nil
 }
 
 func getConstraints(node *xmlNode) (logLevelConstraints, error) {
@@ -234,17 +956,17 @@ func getConstraints(node *xmlNode) (logLevelConstraints, error) {
 	}
 
 	if isLevels {
-		levels, err := parseLevels(levelsStr)
+		levels, err := parseLevels(levelsStr, node.levels)
 		if err != nil {
 			return nil, err
 		}
-		return newListConstraints(levels)
+		return newConfigListConstraints(levels, node.levels)
 	}
 
 	var minLevel LogLevel = TraceLvl
 	if isMinLevel {
 		found := true
-		minLevel, found = LogLevelFromString(minLevelStr)
+		minLevel, found = node.levels.fromString(minLevelStr)
 		if !found {
 			return nil, errors.New("Declared " + minLevelId + " not found: " + minLevelStr)
 		}
@@ -253,20 +975,20 @@ func getConstraints(node *xmlNode) (logLevelConstraints, error) {
 	var maxLevel LogLevel = CriticalLvl
 	if isMaxLevel {
 		found := true
-		maxLevel, found = LogLevelFromString(maxLevelStr)
+		maxLevel, found = node.levels.fromString(maxLevelStr)
 		if !found {
 			return nil, errors.New("Declared " + maxLevelId + " not found: " + maxLevelStr)
 		}
 	}
 
-	return newMinMaxConstraints(minLevel, maxLevel)
+	return newConfigMinMaxConstraints(minLevel, maxLevel, node.levels)
 }
 
-func parseLevels(str string) ([]LogLevel, error) {
+func parseLevels(str string, declared levelSet) ([]LogLevel, error) {
 	levelsStrArr := strings.Split(strings.Replace(str, " ", "", -1), ",")
 	levels := make([]LogLevel, 0)
 	for _, levelStr := range levelsStrArr {
-		level, found := LogLevelFromString(levelStr)
+		level, found := declared.fromString(levelStr)
 		if !found {
 			return nil, errors.New("Declared level not found: " + levelStr)
 		}
@@ -304,10 +1026,11 @@ func getExceptions(config *xmlNode) ([]*logLevelException, error) {
 
 	for _, exceptionNode := range exceptionsNode.children {
 		if exceptionNode.name != exceptionId {
-			return nil, errors.New("Incorrect nested element in exceptions section: " + exceptionNode.name)
+			return nil, errors.New("Incorrect nested element in exceptions section: " + exceptionNode.errorName())
 		}
 
-		err := checkUnexpectedAttribute(exceptionNode, minLevelId, maxLevelId, levelsId, funcPatternId, filePatternId)
+		err := checkUnexpectedAttribute(exceptionNode, minLevelId, maxLevelId, levelsId, funcPatternId, filePatternId,
+			packagePatternId)
 		if err != nil {
 			return nil, err
 		}
@@ -319,14 +1042,18 @@ func getExceptions(config *xmlNode) ([]*logLevelException, error) {
 
 		funcPattern, isFuncPattern := exceptionNode.attributes[funcPatternId]
 		filePattern, isFilePattern := exceptionNode.attributes[filePatternId]
+		packagePattern, isPackagePattern := exceptionNode.attributes[packagePatternId]
 		if !isFuncPattern {
 			funcPattern = "*"
 		}
 		if !isFilePattern {
 			filePattern = "*"
 		}
+		if !isPackagePattern {
+			packagePattern = "*"
+		}
 
-		exception, err := newLogLevelException(funcPattern, filePattern, constraints)
+		exception, err := newPackageLevelException(packagePattern, funcPattern, filePattern, constraints)
 		if err != nil {
 			return nil, errors.New("Incorrect exception node: " + err.Error())
 		}
@@ -345,7 +1072,8 @@ func checkDistinctExceptions(exceptions []*logLevelException) error {
 			}
 
 			if exception.FuncPattern() == exception1.FuncPattern() &&
-				exception.FilePattern() == exception1.FilePattern() {
+				exception.FilePattern() == exception1.FilePattern() &&
+				exception.PackagePattern() == exception1.PackagePattern() {
 
 				return errors.New(fmt.Sprintf("There are two or more duplicate exceptions. Func: %v, file% %v",
 					exception.FuncPattern(), exception.FilePattern()))
@@ -383,10 +1111,10 @@ func getFormats(config *xmlNode) (map[string]*formatter, error) {
 
 	for _, formatNode := range formatsNode.children {
 		if formatNode.name != formatId {
-			return nil, errors.New("Incorrect nested element in " + formatsId + " section: " + formatNode.name)
+			return nil, errors.New("Incorrect nested element in " + formatsId + " section: " + formatNode.errorName())
 		}
 
-		err := checkUnexpectedAttribute(formatNode, formatKeyAttrId, formatId)
+		err := checkUnexpectedAttribute(formatNode, formatKeyAttrId, formatId, formatLocaleAttr)
 		if err != nil {
 			return nil, err
 		}
@@ -400,7 +1128,16 @@ func getFormats(config *xmlNode) (map[string]*formatter, error) {
 			return nil, errors.New("Format[" + id + "] has no '" + formatAttrId + "' attribute")
 		}
 
-		formatter, err := newFormatter(formatStr)
+		var locale *FormatLocale
+		localeName, isLocale := formatNode.attributes[formatLocaleAttr]
+		if isLocale {
+			locale, isLocale = formatLocaleByName(localeName)
+			if !isLocale {
+				return nil, errors.New("Format[" + id + "] has unknown locale: " + localeName)
+			}
+		}
+
+		formatter, err := newFormatterWithLocale(formatStr, locale)
 		if err != nil {
 			return nil, err
 		}
@@ -427,7 +1164,7 @@ func getloggerTypeFromStringData(config *xmlNode) (logType loggerTypeFromString,
 	if logType == asyncTimerloggerTypeFromString {
 		intervalStr, intervalExists := config.attributes[asyncLoggerIntervalAttr]
 		if !intervalExists {
-			return 0, nil, newMissingArgumentError(config.name, asyncLoggerIntervalAttr)
+			return 0, nil, newMissingArgumentError(config.errorName(), asyncLoggerIntervalAttr)
 		}
 
 		interval, err := strconv.ParseUint(intervalStr, 10, 32)
@@ -441,7 +1178,7 @@ func getloggerTypeFromStringData(config *xmlNode) (logType loggerTypeFromString,
 		// Min interval
 		minIntStr, minIntExists := config.attributes[adaptLoggerMinIntervalAttr]
 		if !minIntExists {
-			return 0, nil, newMissingArgumentError(config.name, adaptLoggerMinIntervalAttr)
+			return 0, nil, newMissingArgumentError(config.errorName(), adaptLoggerMinIntervalAttr)
 		}
 		minInterval, err := strconv.ParseUint(minIntStr, 10, 32)
 		if err != nil {
@@ -451,7 +1188,7 @@ func getloggerTypeFromStringData(config *xmlNode) (logType loggerTypeFromString,
 		// Max interval
 		maxIntStr, maxIntExists := config.attributes[adaptLoggerMaxIntervalAttr]
 		if !maxIntExists {
-			return 0, nil, newMissingArgumentError(config.name, adaptLoggerMaxIntervalAttr)
+			return 0, nil, newMissingArgumentError(config.errorName(), adaptLoggerMaxIntervalAttr)
 		}
 		maxInterval, err := strconv.ParseUint(maxIntStr, 10, 32)
 		if err != nil {
@@ -461,7 +1198,7 @@ func getloggerTypeFromStringData(config *xmlNode) (logType loggerTypeFromString,
 		// Critical msg count
 		criticalMsgCountStr, criticalMsgCountExists := config.attributes[adaptLoggerCriticalMsgCountAttr]
 		if !criticalMsgCountExists {
-			return 0, nil, newMissingArgumentError(config.name, adaptLoggerCriticalMsgCountAttr)
+			return 0, nil, newMissingArgumentError(config.errorName(), adaptLoggerCriticalMsgCountAttr)
 		}
 		criticalMsgCount, err := strconv.ParseUint(criticalMsgCountStr, 10, 32)
 		if err != nil {
@@ -525,6 +1262,9 @@ func getCurrentFormat(node *xmlNode, formatFromParent *formatter, formats map[st
 
 	// Test for predefined format match
 	pdFormat, pdOk := predefinedFormats[formatId]
+	if pdOk && UpstreamCompatible() {
+		pdOk = upstreamPredefinedFormats[strings.TrimPrefix(formatId, predefinedPrefix)]
+	}
 
 	if !pdOk {
 		return nil, errors.New("Formatid = '" + formatId + "' doesn't exist")
@@ -538,7 +1278,13 @@ func createInnerReceivers(node *xmlNode, format *formatter, formats map[string]*
 	for _, childNode := range node.children {
 		entry, ok := elementMap[childNode.name]
 		if !ok {
-			return nil, errors.New("Unnknown tag '" + childNode.name + "' in outputs section")
+			return nil, errors.New("Unnknown tag '" + childNode.errorName() + "' in outputs section")
+		}
+
+		// Receivers with an id can be changed at runtime, see AddLoggerOutput
+		id, hasId := childNode.attributes[outputIdAttr]
+		if hasId {
+			childNode = withoutAttribute(childNode, outputIdAttr)
 		}
 
 		output, err := entry.constructor(childNode, format, formats)
@@ -546,12 +1292,32 @@ func createInnerReceivers(node *xmlNode, format *formatter, formats map[string]*
 			return nil, err
 		}
 
+		if hasId {
+			output, err = newOutputSlot(id, format, formats, output)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		outputs = append(outputs, output)
 	}
 
 	return outputs, nil
 }
 
+// withoutAttribute returns a copy of the node without the given attribute. The children
+// are shared with the node.
+func withoutAttribute(node *xmlNode, name string) *xmlNode {
+	copied := *node
+	copied.attributes = make(map[string]string, len(node.attributes))
+	for attr, value := range node.attributes {
+		if attr != name {
+			copied.attributes[attr] = value
+		}
+	}
+	return &copied
+}
+
 func createSplitter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
 	err := checkUnexpectedAttribute(node, outputFormatId)
 	if err != nil {
@@ -592,10 +1358,10 @@ func createFilter(node *xmlNode, formatFromParent *formatter, formats map[string
 
 	levelsStr, isLevels := node.attributes[filterLevelsAttrId]
 	if !isLevels {
-		return nil, newMissingArgumentError(node.name, filterLevelsAttrId)
+		return nil, newMissingArgumentError(node.errorName(), filterLevelsAttrId)
 	}
 
-	levels, err := parseLevels(levelsStr)
+	levels, err := parseLevels(levelsStr, node.levels)
 	if err != nil {
 		return nil, err
 	}
@@ -608,14 +1374,23 @@ func createFilter(node *xmlNode, formatFromParent *formatter, formats map[string
 	return newFilterDispatcher(currentFormat, receivers, levels...)
 }
 
-func createfileWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
-	err := checkUnexpectedAttribute(node, outputFormatId, pathId)
+// Creates a sampler if encountered in the config file. The 'rate' attribute sets the sampling
+// rate of all levels, attributes named after levels override it for a single level.
+//
+// Example:
+//     <sampler rate="0.01" error="1" critical="1" limit="100"><conn .../></sampler>
+func createSampler(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	expectedAttrs := []string{outputFormatId, samplerRateAttr, samplerLimitAttr, samplerBurstAttr}
+	for level := LogLevel(TraceLvl); level <= CriticalLvl; level++ {
+		expectedAttrs = append(expectedAttrs, level.String())
+	}
+	err := checkUnexpectedAttribute(node, expectedAttrs...)
 	if err != nil {
 		return nil, err
 	}
 
-	if node.hasChildren() {
-		return nil, nodeCannotHaveChildrenError
+	if !node.hasChildren() {
+		return nil, nodeMustHaveChildrenError
 	}
 
 	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
@@ -623,132 +1398,2825 @@ func createfileWriter(node *xmlNode, formatFromParent *formatter, formats map[st
 		return nil, err
 	}
 
-	path, isPath := node.attributes[pathId]
-	if !isPath {
-		return nil, newMissingArgumentError(node.name, pathId)
+	rates := make(map[LogLevel]float64)
+	for level := LogLevel(TraceLvl); level <= CriticalLvl; level++ {
+		rateStr, ok := node.attributes[level.String()]
+		if !ok {
+			rateStr, ok = node.attributes[samplerRateAttr]
+		}
+		if !ok {
+			continue
+		}
+
+		rates[level], err = strconv.ParseFloat(rateStr, 64)
+		if err != nil {
+			return nil, errors.New("Invalid sampling rate of " + node.errorName() + ": " + rateStr)
+		}
 	}
 
-	fileWriter, err := newFileWriter(path)
+	var limit float64
+	if limitStr, ok := node.attributes[samplerLimitAttr]; ok {
+		limit, err = strconv.ParseFloat(limitStr, 64)
+		if err != nil {
+			return nil, errors.New("Invalid limit of " + node.errorName() + ": " + limitStr)
+		}
+	}
+
+	burst := 1
+	if burstStr, ok := node.attributes[samplerBurstAttr]; ok {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil {
+			return nil, errors.New("Invalid burst of " + node.errorName() + ": " + burstStr)
+		}
+	}
+
+	receivers, err := createInnerReceivers(node, currentFormat, formats)
 	if err != nil {
 		return nil, err
 	}
 
-	return newFormattedWriter(fileWriter, currentFormat)
+	return newSamplerDispatcher(currentFormat, receivers, rates, limit, burst)
 }
 
-// Creates new SMTP writer if encountered in the config file.
-func createSmtpWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
-	err := checkUnexpectedAttribute(node, outputFormatId, senderaddressId, senderNameId, hostNameId, hostPortId, userNameId, userPassId)
+// Creates a dispatcher of event records if encountered in the config file.
+func createEvents(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	err := checkUnexpectedAttribute(node)
 	if err != nil {
 		return nil, err
 	}
-	// Node must have children.
+
 	if !node.hasChildren() {
 		return nil, nodeMustHaveChildrenError
 	}
-	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+
+	receivers, err := createInnerReceivers(node, formatFromParent, formats)
 	if err != nil {
 		return nil, err
 	}
-	senderAddress, ok := node.attributes[senderaddressId]
-	if !ok {
-		return nil, newMissingArgumentError(node.name, senderaddressId)
+
+	return newEventsDispatcher(receivers)
+}
+
+// Creates a counter of good and bad SLO events if encountered in the config file.
+func createSloCounter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	err := checkUnexpectedAttribute(node, sloNameAttr)
+	if err != nil {
+		return nil, err
 	}
-	senderName, ok := node.attributes[senderNameId]
-	if !ok {
-		return nil, newMissingArgumentError(node.name, senderNameId)
+
+	if !node.hasChildren() {
+		return nil, nodeMustHaveChildrenError
 	}
-	// Process child nodes scanning for recipient email addresses and/or CA certificate paths.
-	var recipientAddresses []string
-	var caCertDirPaths []string
+
+	var eventRules, badRules []*sloRule
 	for _, childNode := range node.children {
+		rule, err := createSloRule(childNode)
+		if err != nil {
+			return nil, err
+		}
+
 		switch childNode.name {
-		// Extract recipient address from child nodes.
-		case recipientId:
-			address, ok := childNode.attributes[addressId]
-			if !ok {
-				return nil, newMissingArgumentError(childNode.name, addressId)
-			}
-			recipientAddresses = append(recipientAddresses, address)
-		// Extract CA certificate file path from child nodes.
-		case cACertDirpathId:
-			path, ok := childNode.attributes[pathId]
-			if !ok {
-				return nil, newMissingArgumentError(childNode.name, pathId)
-			}
-			caCertDirPaths = append(caCertDirPaths, path)
+		case sloEventRuleId:
+			eventRules = append(eventRules, rule)
+		case sloBadRuleId:
+			badRules = append(badRules, rule)
 		default:
-			return nil, newUnexpectedChildElementError(childNode.name)
+			return nil, newUnexpectedChildElementError(childNode.errorName())
+		}
+	}
+
+	return newSloCounterDispatcher(node.attributes[sloNameAttr], eventRules, badRules)
+}
+
+func createSloRule(node *xmlNode) (*sloRule, error) {
+	err := checkUnexpectedAttribute(node, sloRuleLevelsAttr, sloRuleMsgAttr, sloRuleFieldAttr, sloRuleOpAttr, sloRuleValueAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	var levels []LogLevel
+	if levelsStr, ok := node.attributes[sloRuleLevelsAttr]; ok {
+		levels, err = parseLevels(levelsStr, node.levels)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newSloRule(levels, node.attributes[sloRuleMsgAttr], node.attributes[sloRuleFieldAttr],
+		node.attributes[sloRuleOpAttr], node.attributes[sloRuleValueAttr])
+}
+
+func createfileWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	err := checkUnexpectedAttribute(node, outputFormatId, pathId)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	path, isPath := node.attributes[pathId]
+	if !isPath {
+		return nil, newMissingArgumentError(node.errorName(), pathId)
+	}
+
+	fileWriter, err := newFileWriter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(fileWriter, currentFormat)
+}
+
+// Creates new SMTP writer if encountered in the config file. With digest="true", messages
+// of 'digestlevels' (error and critical by default) are sent together, one email per
+// 'digestcount' messages or 'digestinterval' (see smtpAlertWriter for 'digestsubject').
+// Messages repeated within 'dedup' are dropped, and every recipient gets at most 'limit'
+// emails per 'interval' (an hour by default). Messages below 'minlevel' are not sent, but
+// the last 'context' of them are added to the next email; 'htmltemplate' is the path of
+// an html/template file the emails are rendered from (see smtpTemplateData):
+//     <smtp senderaddress="..." sendername="..." hostname="..." hostport="587" username="..." password="..."
+//         digest="true" digestcount="500" digestinterval="10m" digestsubject="[billing] %Summary: %First"
+//         dedup="30m" limit="20" minlevel="error" context="50" htmltemplate="alert.html">
+//
+// tls="implicit" connects with TLS from the start (SMTPS, port 465), tls="starttls" fails
+// when the server does not support STARTTLS, and the default, "auto", uses it if it does.
+// 'tlsservername' is the name the certificate is verified for. With auth="xoauth2" the
+// OAuth 2.0 access 'token' is sent instead of a password; it is resolved as a secret
+// reference for every email, so a secret provider can refresh it:
+//     <smtp senderaddress="..." sendername="..." hostname="smtp.office365.com" hostport="587" username="alerts@..."
+//         tls="starttls" auth="xoauth2" token="secret://o365">
+//
+// Recipients with 'levels' get only messages of these levels, recipients without get all:
+//     <recipient address="oncall@..." levels="critical"/>
+//     <recipient address="team@..." levels="error,critical"/>
+func createSmtpWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	err := checkUnexpectedAttribute(node, outputFormatId, senderaddressId, senderNameId, hostNameId, hostPortId, userNameId, userPassId,
+		smtpDigestAttr, smtpDigestLevelsAttr, smtpDigestCountAttr, smtpDigestIntervalAttr, smtpDigestSubjectAttr,
+		smtpDedupAttr, smtpLimitAttr, smtpIntervalAttr, minLevelId, smtpContextAttr, smtpHtmlTemplateAttr,
+		smtpTLSAttr, smtpTLSServerNameAttr, smtpTLSSkipVerifyAttr, smtpAuthAttr, smtpTokenAttr)
+	if err != nil {
+		return nil, err
+	}
+	// Node must have children.
+	if !node.hasChildren() {
+		return nil, nodeMustHaveChildrenError
+	}
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+	senderAddress, ok := node.attributes[senderaddressId]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), senderaddressId)
+	}
+	senderName, ok := node.attributes[senderNameId]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), senderNameId)
+	}
+	// Process child nodes scanning for recipient email addresses and/or CA certificate paths.
+	var recipientAddresses []string
+	var caCertDirPaths []string
+	// Levels of recipients which get only some levels; recipients listed more than once
+	// get the levels of all their entries.
+	var recipientLevels map[string][]LogLevel
+	allLevelsRecipients := make(map[string]bool)
+	for _, childNode := range node.children {
+		switch childNode.name {
+		// Extract recipient address from child nodes.
+		case recipientId:
+			address, ok := childNode.attributes[addressId]
+			if !ok {
+				return nil, newMissingArgumentError(childNode.errorName(), addressId)
+			}
+			_, isListed := allLevelsRecipients[address]
+			if !isListed {
+				recipientAddresses = append(recipientAddresses, address)
+			}
+			levelsStr, isLevels := childNode.attributes[filterLevelsAttrId]
+			if !isLevels {
+				allLevelsRecipients[address] = true
+				delete(recipientLevels, address)
+				continue
+			}
+			levels, err := parseLevels(levelsStr, node.levels)
+			if err != nil {
+				return nil, err
+			}
+			if !allLevelsRecipients[address] {
+				allLevelsRecipients[address] = false
+				if recipientLevels == nil {
+					recipientLevels = make(map[string][]LogLevel)
+				}
+				recipientLevels[address] = append(recipientLevels[address], levels...)
+			}
+		// Extract CA certificate file path from child nodes.
+		case cACertDirpathId:
+			path, ok := childNode.attributes[pathId]
+			if !ok {
+				return nil, newMissingArgumentError(childNode.errorName(), pathId)
+			}
+			caCertDirPaths = append(caCertDirPaths, path)
+		default:
+			return nil, newUnexpectedChildElementError(childNode.errorName())
 		}
 	}
 	hostName, ok := node.attributes[hostNameId]
 	if !ok {
-		return nil, newMissingArgumentError(node.name, hostNameId)
+		return nil, newMissingArgumentError(node.errorName(), hostNameId)
+	}
+
+	hostPort, ok := node.attributes[hostPortId]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), hostPortId)
+	}
+
+	// Check if the string can really be converted into int.
+	if _, err := strconv.Atoi(hostPort); err != nil {
+		return nil, errors.New("Invalid host port number")
+	}
+
+	userName, ok := node.attributes[userNameId]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), userNameId)
+	}
+
+	authMechanism := smtpAuthPlain
+	if authStr, isAuth := node.attributes[smtpAuthAttr]; isAuth {
+		if err := checkSmtpAuthValue(authStr); err != nil {
+			return nil, errors.New(node.errorName() + " attribute '" + smtpAuthAttr + "': " + err.Error())
+		}
+		authMechanism = authStr
+	}
+
+	var userPass, token string
+	if authMechanism == smtpAuthXoauth2 {
+		if token, ok = node.attributes[smtpTokenAttr]; !ok {
+			return nil, newMissingArgumentError(node.errorName(), smtpTokenAttr)
+		}
+		if _, err := resolveSecret(token); err != nil {
+			return nil, errors.New(node.errorName() + " attribute '" + smtpTokenAttr + "': " + err.Error())
+		}
+	} else {
+		if userPass, ok = node.attributes[userPassId]; !ok {
+			return nil, newMissingArgumentError(node.errorName(), userPassId)
+		}
+		userPass, err = resolveSecret(userPass)
+		if err != nil {
+			return nil, errors.New(node.errorName() + " attribute '" + userPassId + "': " + err.Error())
+		}
+	}
+
+	smtpWriter := newSmtpWriter(
+		senderAddress,
+		senderName,
+		recipientAddresses,
+		hostName,
+		hostPort,
+		userName,
+		userPass,
+		caCertDirPaths,
+	)
+	if authMechanism == smtpAuthXoauth2 {
+		smtpWriter.auth = newXoauth2Auth(userName, token)
+	}
+	if tlsMode, isTLSMode := node.attributes[smtpTLSAttr]; isTLSMode {
+		if err := checkSmtpTLSValue(tlsMode); err != nil {
+			return nil, errors.New(node.errorName() + " attribute '" + smtpTLSAttr + "': " + err.Error())
+		}
+		smtpWriter.tlsMode = tlsMode
+	}
+	smtpWriter.tlsServerName = node.attributes[smtpTLSServerNameAttr]
+	if skipVerifyStr, isSkipVerify := node.attributes[smtpTLSSkipVerifyAttr]; isSkipVerify {
+		smtpWriter.tlsSkipVerify, err = strconv.ParseBool(skipVerifyStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options := smtpAlertOptions{
+		digestSubject:   node.attributes[smtpDigestSubjectAttr],
+		recipientLevels: recipientLevels,
+	}
+	if digestStr, isDigest := node.attributes[smtpDigestAttr]; isDigest {
+		options.digest, err = strconv.ParseBool(digestStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !options.digest {
+		for _, attr := range []string{smtpDigestLevelsAttr, smtpDigestCountAttr, smtpDigestIntervalAttr, smtpDigestSubjectAttr} {
+			if _, ok := node.attributes[attr]; ok {
+				return nil, errors.New(node.errorName() + " attribute '" + attr + "' needs " + smtpDigestAttr + "=\"true\"")
+			}
+		}
+	}
+	if levelsStr, isLevels := node.attributes[smtpDigestLevelsAttr]; isLevels {
+		options.digestLevels, err = parseLevels(levelsStr, node.levels)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if minLevelStr, isMinLevel := node.attributes[minLevelId]; isMinLevel {
+		var found bool
+		options.minLevel, found = node.levels.fromString(minLevelStr)
+		if !found {
+			return nil, errors.New("Declared level not found: " + minLevelStr)
+		}
+	}
+	if templatePath, isTemplate := node.attributes[smtpHtmlTemplateAttr]; isTemplate {
+		options.template, err = template.ParseFiles(templatePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ints := map[string]*int{
+		smtpDigestCountAttr: &options.digestCount,
+		smtpLimitAttr:       &options.limit,
+		smtpContextAttr:     &options.contextSize,
+	}
+	for attr, value := range ints {
+		if valueStr, isValue := node.attributes[attr]; isValue {
+			*value, err = strconv.Atoi(valueStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	durations := map[string]*time.Duration{
+		smtpDigestIntervalAttr: &options.digestInterval,
+		smtpDedupAttr:          &options.dedup,
+		smtpIntervalAttr:       &options.limitInterval,
+	}
+	for attr, value := range durations {
+		if valueStr, isValue := node.attributes[attr]; isValue {
+			*value, err = time.ParseDuration(valueStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !options.digest && options.dedup == 0 && options.limit == 0 && options.minLevel == TraceLvl &&
+		options.contextSize == 0 && options.template == nil && len(options.recipientLevels) == 0 {
+		return newFormattedWriter(smtpWriter, currentFormat)
+	}
+	return newSmtpAlertWriter(currentFormat, smtpWriter, options)
+}
+
+func createConsoleWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	err := checkUnexpectedAttribute(node, outputFormatId)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	consoleWriter, err := newConsoleWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(consoleWriter, currentFormat)
+}
+
+// createconnWriter creates a receiver writing to a stream connection. With tls="true" the
+// connection is encrypted; the server certificate is verified for 'servername', or the
+// host of 'addr', against the PEM bundle 'cacert' or the system roots, and 'cert' and
+// 'key' set a client certificate for mutual authentication:
+//     <conn net="tcp" addr="rsyslog.local:6514" tls="true" cacert="ca.pem" cert="client.pem" key="client.key"/>
+//
+// With 'reconnect', 'buffersize' or 'bufferpath', a failed dial is tried again after
+// 'reconnect' (1s by default), doubled up to 'maxreconnect' (1m), and messages are kept
+// while disconnected: 'buffersize' bytes in memory, older ones in the file at 'bufferpath'
+// up to 'bufferfilesize' bytes (64 MiB). 'timeout' (5s) limits dials and writes:
+//     <conn net="tcp" addr="collector.local:5000" buffersize="1048576" bufferpath="/var/spool/app/conn.buf"/>
+//
+// 'writetimeout' limits writes apart from dials, so that a hung collector can not hold the
+// logging goroutine. 'overflow' sets what happens to messages which fit neither in memory
+// nor in the file: "dropoldest" (the default) drops the oldest kept ones, "dropnewest" the
+// new one, and "block" waits until the kept messages are sent:
+//     <conn net="tcp" addr="collector.local:5000" buffersize="65536" writetimeout="200ms" overflow="dropnewest"/>
+func createconnWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, connWriterAddrAttr, connWriterNetAttr, connWriterReconnectOnMsgAttr,
+		connWriterTLSAttr, connWriterCACertAttr, connWriterCertAttr, connWriterKeyAttr, connWriterServerNameAttr,
+		connWriterReconnectAttr, connWriterMaxReconnectAttr, connWriterTimeoutAttr, connWriterBufferSizeAttr,
+		connWriterBufferPathAttr, connWriterBufferFileSizeAttr, connWriterWriteTimeoutAttr, connWriterOverflowAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, isAddr := node.attributes[connWriterAddrAttr]
+	if !isAddr {
+		return nil, newMissingArgumentError(node.errorName(), connWriterAddrAttr)
+	}
+
+	net, isNet := node.attributes[connWriterNetAttr]
+	if !isNet {
+		return nil, newMissingArgumentError(node.errorName(), connWriterNetAttr)
+	}
+
+	reconnectOnMsg := false
+	reconnectOnMsgStr, isReconnectOnMsgStr := node.attributes[connWriterReconnectOnMsgAttr]
+	if isReconnectOnMsgStr {
+		if reconnectOnMsgStr == "true" {
+			reconnectOnMsg = true
+		} else if reconnectOnMsgStr == "false" {
+			reconnectOnMsg = false
+		} else {
+			return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + connWriterReconnectOnMsgAttr + "' attribute value")
+		}
+	}
+
+	var options connWriterOptions
+	useTLS := false
+	if tlsStr, isTLS := node.attributes[connWriterTLSAttr]; isTLS {
+		useTLS, err = strconv.ParseBool(tlsStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if useTLS {
+		options.tlsConfig, err = getConnTLSConfig(node, addr)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, attr := range []string{connWriterCACertAttr, connWriterCertAttr, connWriterKeyAttr, connWriterServerNameAttr} {
+			if _, ok := node.attributes[attr]; ok {
+				return nil, errors.New(node.errorName() + " attribute '" + attr + "' needs " + connWriterTLSAttr + "=\"true\"")
+			}
+		}
+	}
+
+	options.bufferPath = node.attributes[connWriterBufferPathAttr]
+	options.overflow = node.attributes[connWriterOverflowAttr]
+	if sizeStr, isSize := node.attributes[connWriterBufferSizeAttr]; isSize {
+		options.bufferSize, err = strconv.Atoi(sizeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if sizeStr, isSize := node.attributes[connWriterBufferFileSizeAttr]; isSize {
+		options.bufferFileSize, err = strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	durations := map[string]*time.Duration{
+		connWriterReconnectAttr:    &options.reconnect,
+		connWriterMaxReconnectAttr: &options.maxReconnect,
+		connWriterTimeoutAttr:      &options.timeout,
+		connWriterWriteTimeoutAttr: &options.writeTimeout,
+	}
+	for attr, value := range durations {
+		if valueStr, isValue := node.attributes[attr]; isValue {
+			*value, err = time.ParseDuration(valueStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	connWriter, err := newConnWriterWithOptions(net, addr, reconnectOnMsg, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(connWriter, currentFormat)
+}
+
+// getConnTLSConfig returns the TLS config of a conn receiver with tls="true".
+func getConnTLSConfig(node *xmlNode, addr string) (*tls.Config, error) {
+	serverName, isServerName := node.attributes[connWriterServerNameAttr]
+	if !isServerName {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + connWriterAddrAttr + "' attribute value")
+		}
+		serverName = host
+	}
+	tlsConfig := &tls.Config{ServerName: serverName}
+
+	if caFile, isCA := node.attributes[connWriterCACertAttr]; isCA {
+		pemContent, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pemContent) {
+			return nil, errors.New("Invalid PEM content: " + caFile)
+		}
+	}
+
+	certFile, isCert := node.attributes[connWriterCertAttr]
+	keyFile, isKey := node.attributes[connWriterKeyAttr]
+	if isCert != isKey {
+		return nil, errors.New("Node '" + node.errorName() + "' must have both '" + connWriterCertAttr +
+			"' and '" + connWriterKeyAttr + "' or neither")
+	}
+	if isCert {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// createSyslogWriter creates a syslog receiver. Without 'net' the local daemon is used,
+// over the unix socket in 'addr' or a well-known one. RFC 5424 framing, the user facility
+// and the default level severities are used unless the attributes set others:
+//     <syslog net="tcp" addr="logs.local:601" rfc="5424" facility="local0" tag="billing" severities="info:notice"/>
+func createSyslogWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, syslogNetAttr, syslogAddrAttr, syslogRFCAttr,
+		syslogFacilityAttr, syslogTagAttr, syslogSeveritiesAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	netName, isNet := node.attributes[syslogNetAttr]
+	if isNet && checkSyslogNetValue(netName) != nil {
+		return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + syslogNetAttr + "' attribute value")
+	}
+
+	rfc := syslogRFC5424
+	if rfcStr, isRFC := node.attributes[syslogRFCAttr]; isRFC {
+		if rfcStr != syslogRFC3164 && rfcStr != syslogRFC5424 {
+			return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + syslogRFCAttr + "' attribute value")
+		}
+		rfc = rfcStr
+	}
+
+	facility := syslogFacilities["user"]
+	if facilityStr, isFacility := node.attributes[syslogFacilityAttr]; isFacility {
+		var found bool
+		facility, found = syslogFacilities[facilityStr]
+		if !found {
+			return nil, errors.New("Unknown syslog facility: " + facilityStr)
+		}
+	}
+
+	severities, err := parseSyslogSeverities(node.attributes[syslogSeveritiesAttr], node.levels)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSyslogWriter(currentFormat, netName, node.attributes[syslogAddrAttr], rfc, facility,
+		severities, node.attributes[syslogTagAttr])
+}
+
+// createJournaldWriter creates a journald receiver. The journal socket, the identifier
+// (the program name by default) and the level severities can be set:
+//     <journald tag="billing" severities="info:notice"/>
+func createJournaldWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, journaldSocketAttr, syslogTagAttr, syslogSeveritiesAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	severities, err := parseSyslogSeverities(node.attributes[syslogSeveritiesAttr], node.levels)
+	if err != nil {
+		return nil, err
+	}
+
+	return newJournaldWriter(currentFormat, node.attributes[journaldSocketAttr], severities, node.attributes[syslogTagAttr])
+}
+
+// createObjectStoreWriter creates a receiver uploading messages to S3-compatible storage.
+// The keys may be secret references. Objects are uploaded at 'maxsize' bytes (8 MiB by
+// default) or 'maxinterval' after their first message (a minute by default):
+//     <objectstore endpoint="https://s3.amazonaws.com" bucket="logs" prefix="billing/" region="eu-west-1"
+//         accesskey="env://AWS_ACCESS_KEY_ID" secretkey="env://AWS_SECRET_ACCESS_KEY" maxinterval="5m"/>
+func createObjectStoreWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, objectStoreEndpointAttr, objectStoreBucketAttr,
+		objectStorePrefixAttr, objectStoreRegionAttr, objectStoreAccessKeyAttr, objectStoreSecretKeyAttr,
+		objectStoreMaxSizeAttr, objectStoreMaxIntervalAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := node.attributes[objectStoreEndpointAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), objectStoreEndpointAttr)
+	}
+	bucket, ok := node.attributes[objectStoreBucketAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), objectStoreBucketAttr)
+	}
+
+	keys := make(map[string]string)
+	for _, attr := range []string{objectStoreAccessKeyAttr, objectStoreSecretKeyAttr} {
+		keys[attr], err = resolveSecret(node.attributes[attr])
+		if err != nil {
+			return nil, errors.New(node.errorName() + " attribute '" + attr + "': " + err.Error())
+		}
+	}
+	if (keys[objectStoreAccessKeyAttr] == "") != (keys[objectStoreSecretKeyAttr] == "") {
+		return nil, errors.New("Node '" + node.errorName() + "' must have both '" + objectStoreAccessKeyAttr +
+			"' and '" + objectStoreSecretKeyAttr + "' or neither")
+	}
+
+	maxSize := defaultObjectStoreMaxSize
+	if maxSizeStr, isMaxSize := node.attributes[objectStoreMaxSizeAttr]; isMaxSize {
+		maxSize, err = strconv.Atoi(maxSizeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	maxInterval := defaultObjectStoreMaxInterval
+	if maxIntervalStr, isMaxInterval := node.attributes[objectStoreMaxIntervalAttr]; isMaxInterval {
+		maxInterval, err = time.ParseDuration(maxIntervalStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	objectStoreWriter, err := newObjectStoreWriter(endpoint, bucket, node.attributes[objectStorePrefixAttr],
+		node.attributes[objectStoreRegionAttr], keys[objectStoreAccessKeyAttr], keys[objectStoreSecretKeyAttr],
+		maxSize, maxInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(objectStoreWriter, currentFormat)
+}
+
+// createS3ArchiveWriter creates a receiver archiving messages in S3-compatible storage as
+// gzipped chunks, buffered in 'dir' until they are uploaded. The bucket attributes are
+// those of objectstore; for the others see createArchiveWriter:
+//     <s3archive endpoint="https://s3.amazonaws.com" bucket="archive" region="eu-west-1" dir="/var/spool/app/archive"
+//         accesskey="env://AWS_ACCESS_KEY_ID" secretkey="env://AWS_SECRET_ACCESS_KEY" service="billing"/>
+func createS3ArchiveWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, objectStoreEndpointAttr, objectStoreBucketAttr,
+		objectStoreRegionAttr, objectStoreAccessKeyAttr, objectStoreSecretKeyAttr, archiveDirAttr, archiveKeyAttr,
+		archiveServiceAttr, archiveMaxSizeAttr, archiveMaxIntervalAttr, archiveBufferSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := node.attributes[objectStoreEndpointAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), objectStoreEndpointAttr)
+	}
+	bucket, ok := node.attributes[objectStoreBucketAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), objectStoreBucketAttr)
+	}
+
+	keys := make(map[string]string)
+	for _, attr := range []string{objectStoreAccessKeyAttr, objectStoreSecretKeyAttr} {
+		keys[attr], err = resolveSecret(node.attributes[attr])
+		if err != nil {
+			return nil, errors.New(node.errorName() + " attribute '" + attr + "': " + err.Error())
+		}
+	}
+	if (keys[objectStoreAccessKeyAttr] == "") != (keys[objectStoreSecretKeyAttr] == "") {
+		return nil, errors.New("Node '" + node.errorName() + "' must have both '" + objectStoreAccessKeyAttr +
+			"' and '" + objectStoreSecretKeyAttr + "' or neither")
+	}
+
+	store, err := newS3Bucket(endpoint, bucket, node.attributes[objectStoreRegionAttr],
+		keys[objectStoreAccessKeyAttr], keys[objectStoreSecretKeyAttr])
+	if err != nil {
+		return nil, err
+	}
+
+	return createArchiveWriter(node, store, formatFromParent, formats)
+}
+
+// createGcsArchiveWriter creates a receiver archiving messages in a Google Cloud Storage
+// bucket as gzipped chunks, buffered in 'dir' until they are uploaded. Without
+// 'credentials', a credentials file, Application Default Credentials are used. Uploads
+// are resumable, in requests of 'chunksize' bytes (8 MiB by default, a multiple of
+// 256 KiB). For the other attributes see createArchiveWriter:
+//     <gcsarchive bucket="archive" dir="/var/spool/app/archive" service="billing"/>
+func createGcsArchiveWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, gcsArchiveEndpointAttr, gcsArchiveBucketAttr,
+		gcsArchiveCredentialsAttr, gcsArchiveChunkSizeAttr, archiveDirAttr, archiveKeyAttr, archiveServiceAttr,
+		archiveMaxSizeAttr, archiveMaxIntervalAttr, archiveBufferSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, ok := node.attributes[gcsArchiveBucketAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), gcsArchiveBucketAttr)
+	}
+
+	chunkSize := 0
+	if chunkSizeStr, isChunkSize := node.attributes[gcsArchiveChunkSizeAttr]; isChunkSize {
+		chunkSize, err = strconv.Atoi(chunkSizeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	credentials, err := findGcpCredentials(node.attributes[gcsArchiveCredentialsAttr], gcsScope)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := newGcsBucket(node.attributes[gcsArchiveEndpointAttr], bucket, chunkSize, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	return createArchiveWriter(node, store, formatFromParent, formats)
+}
+
+// createAzureBlobWriter creates a receiver appending messages to append blobs in a
+// container of Azure Blob Storage, in the storage 'account' or at 'endpoint' (as for
+// Azurite or a sovereign cloud). 'blob' is a format for the blob names, by default
+// "service/yyyy/mm/dd/host-pid.log". Requests are authorized with the 'sas' token or,
+// without one, with the managed identity of the host, 'clientid' selecting a
+// user-assigned one:
+//     <azureblob account="prodlogs" container="app" service="billing" sas="env://LOGS_SAS"/>
+func createAzureBlobWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, azureBlobAccountAttr, azureBlobEndpointAttr,
+		azureBlobContainerAttr, azureBlobNameAttr, azureBlobServiceAttr, azureBlobSasAttr, azureBlobClientIdAttr,
+		azureBlobMaxSizeAttr, azureBlobMaxIntervalAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	container, ok := node.attributes[azureBlobContainerAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), azureBlobContainerAttr)
+	}
+	account, isAccount := node.attributes[azureBlobAccountAttr]
+	endpoint, isEndpoint := node.attributes[azureBlobEndpointAttr]
+	if isAccount == isEndpoint {
+		return nil, errors.New("Node '" + node.errorName() + "' must have either '" + azureBlobAccountAttr +
+			"' or '" + azureBlobEndpointAttr + "'")
+	}
+	if isAccount {
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+
+	sas, err := resolveSecret(node.attributes[azureBlobSasAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + azureBlobSasAttr + "': " + err.Error())
+	}
+	var identity *azureManagedIdentity
+	if sas == "" {
+		identity = newAzureManagedIdentity(node.attributes[azureBlobClientIdAttr], azureStorageResource)
+	}
+
+	maxSize := 0
+	if maxSizeStr, isMaxSize := node.attributes[azureBlobMaxSizeAttr]; isMaxSize {
+		maxSize, err = strconv.Atoi(maxSizeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	maxInterval := time.Duration(0)
+	if maxIntervalStr, isMaxInterval := node.attributes[azureBlobMaxIntervalAttr]; isMaxInterval {
+		maxInterval, err = time.ParseDuration(maxIntervalStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	azureBlobWriter, err := newAzureBlobWriter(strings.TrimRight(endpoint, "/")+"/"+container, sas, identity,
+		node.attributes[azureBlobNameAttr], node.attributes[azureBlobServiceAttr], maxSize, maxInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(azureBlobWriter, currentFormat)
+}
+
+// createSqliteWriter creates a receiver inserting messages into a table of a local SQLite
+// database with the database/sql driver 'driver' ("sqlite3" by default), which the
+// application must import. Rows beyond 'maxrows' rows or 'maxsize' bytes of the database
+// are pruned, oldest first:
+//     <sqlite path="/var/lib/app/logs.db" table="logs" maxcount="50" maxsize="67108864"/>
+func createSqliteWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, sqliteDriverAttr, sqlitePathAttr, sqliteTableAttr,
+		sqliteMaxCountAttr, sqliteMaxIntervalAttr, sqliteMaxRowsAttr, sqliteMaxSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	path, ok := node.attributes[sqlitePathAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), sqlitePathAttr)
+	}
+
+	ints := map[string]int64{
+		sqliteMaxCountAttr: defaultSqliteMaxCount,
+		sqliteMaxRowsAttr:  0,
+		sqliteMaxSizeAttr:  0,
+	}
+	for attr := range ints {
+		if str, isSet := node.attributes[attr]; isSet {
+			ints[attr], err = strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	maxInterval := defaultSqliteMaxInterval
+	if maxIntervalStr, isMaxInterval := node.attributes[sqliteMaxIntervalAttr]; isMaxInterval {
+		maxInterval, err = time.ParseDuration(maxIntervalStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newSqliteWriter(currentFormat, node.attributes[sqliteDriverAttr], path, node.attributes[sqliteTableAttr],
+		int(ints[sqliteMaxCountAttr]), maxInterval, ints[sqliteMaxRowsAttr], ints[sqliteMaxSizeAttr])
+}
+
+// createPostgresWriter creates a receiver copying messages into a PostgreSQL table in
+// batches. Without <column> children the columns are time, level, message and fields;
+// a <column> fills a column with one of those values or source, or with a field. With
+// 'cacertdirpath' the server certificate is verified against the PEM files in the
+// directory instead of the system roots, and tls is implied:
+//     <postgres addr="db.local:5432" user="logger" password="env://PGPASSWORD" database="app" table="audit.logs" tls="true">
+//         <column name="at" value="time"/>
+//         <column name="message" value="message"/>
+//         <column name="user_id" field="user"/>
+//     </postgres>
+func createPostgresWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	err := checkUnexpectedAttribute(node, outputFormatId, postgresAddrAttr, postgresUserAttr, postgresPasswordAttr,
+		postgresDatabaseAttr, postgresTableAttr, postgresTLSAttr, postgresCACertDirAttr, postgresMaxCountAttr,
+		postgresMaxIntervalAttr, postgresTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[postgresAddrAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), postgresAddrAttr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + postgresAddrAttr + "' attribute value")
+	}
+	user, ok := node.attributes[postgresUserAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), postgresUserAttr)
+	}
+	password, err := resolveSecret(node.attributes[postgresPasswordAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + postgresPasswordAttr + "': " + err.Error())
+	}
+
+	options := postgresWriterOptions{
+		user:     user,
+		password: password,
+		database: node.attributes[postgresDatabaseAttr],
+		table:    node.attributes[postgresTableAttr],
+		maxCount: defaultPostgresMaxCount,
+	}
+
+	for _, childNode := range node.children {
+		if childNode.name != postgresColumnId {
+			return nil, newUnexpectedChildElementError(childNode.errorName())
+		}
+		err := checkUnexpectedAttribute(childNode, postgresColumnNameAttr, postgresColumnValueAttr, postgresColumnFieldAttr)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := childNode.attributes[postgresColumnNameAttr]
+		if !ok {
+			return nil, newMissingArgumentError(childNode.errorName(), postgresColumnNameAttr)
+		}
+		options.columns = append(options.columns, postgresColumn{
+			name:  name,
+			value: childNode.attributes[postgresColumnValueAttr],
+			field: childNode.attributes[postgresColumnFieldAttr],
+		})
+	}
+
+	if maxCountStr, isMaxCount := node.attributes[postgresMaxCountAttr]; isMaxCount {
+		options.maxCount, err = strconv.Atoi(maxCountStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	durations := map[string]time.Duration{
+		postgresMaxIntervalAttr: defaultPostgresMaxInterval,
+		postgresTimeoutAttr:     defaultPostgresTimeout,
+	}
+	for attr := range durations {
+		if str, isSet := node.attributes[attr]; isSet {
+			durations[attr], err = time.ParseDuration(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	options.maxInterval = durations[postgresMaxIntervalAttr]
+	options.timeout = durations[postgresTimeoutAttr]
+
+	useTLS := false
+	if tlsStr, isTLS := node.attributes[postgresTLSAttr]; isTLS {
+		useTLS, err = strconv.ParseBool(tlsStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if certDir, isCertDir := node.attributes[postgresCACertDirAttr]; isCertDir {
+		options.tlsConfig, err = getTLSConfig([]string{certDir}, host)
+		if err != nil {
+			return nil, err
+		}
+	} else if useTLS {
+		options.tlsConfig = &tls.Config{ServerName: host}
+	}
+
+	return newPostgresWriter(currentFormat, addr, options)
+}
+
+// createMongoWriter creates a receiver inserting messages into a MongoDB collection in
+// batches. The collection is created 'capped' at a size in bytes, or with a 'ttl' index on
+// time. 'w', 'journal' and 'wtimeout' make up the write concern. With 'cacertdirpath' the
+// server certificate is verified against the PEM files in the directory instead of the
+// system roots, and tls is implied:
+//     <mongodb addr="mongo.local:27017" database="app" collection="logs" user="logger"
+//         password="env://MONGO_PASSWORD" ttl="720h" w="majority" wtimeout="5s"/>
+func createMongoWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, mongoAddrAttr, mongoDatabaseAttr, mongoCollectionAttr,
+		mongoUserAttr, mongoPasswordAttr, mongoAuthSourceAttr, mongoTLSAttr, mongoCACertDirAttr, mongoCappedAttr,
+		mongoTTLAttr, mongoWAttr, mongoJournalAttr, mongoWTimeoutAttr, mongoMaxCountAttr, mongoMaxIntervalAttr,
+		mongoTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[mongoAddrAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), mongoAddrAttr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + mongoAddrAttr + "' attribute value")
+	}
+	database, ok := node.attributes[mongoDatabaseAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), mongoDatabaseAttr)
+	}
+	password, err := resolveSecret(node.attributes[mongoPasswordAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + mongoPasswordAttr + "': " + err.Error())
+	}
+
+	options := mongoWriterOptions{
+		database:   database,
+		collection: node.attributes[mongoCollectionAttr],
+		user:       node.attributes[mongoUserAttr],
+		password:   password,
+		authSource: node.attributes[mongoAuthSourceAttr],
+	}
+
+	ints := map[string]int64{mongoMaxCountAttr: defaultMongoMaxCount, mongoCappedAttr: 0}
+	for attr := range ints {
+		if str, isSet := node.attributes[attr]; isSet {
+			ints[attr], err = strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	options.maxCount = int(ints[mongoMaxCountAttr])
+	options.capped = ints[mongoCappedAttr]
+
+	durations := map[string]time.Duration{
+		mongoTTLAttr:         0,
+		mongoWTimeoutAttr:    0,
+		mongoMaxIntervalAttr: defaultMongoMaxInterval,
+		mongoTimeoutAttr:     defaultMongoTimeout,
+	}
+	for attr := range durations {
+		if str, isSet := node.attributes[attr]; isSet {
+			durations[attr], err = time.ParseDuration(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	options.ttl = durations[mongoTTLAttr]
+	options.maxInterval = durations[mongoMaxIntervalAttr]
+	options.timeout = durations[mongoTimeoutAttr]
+
+	bools := map[string]bool{mongoTLSAttr: false, mongoJournalAttr: false}
+	for attr := range bools {
+		if str, isSet := node.attributes[attr]; isSet {
+			bools[attr], err = strconv.ParseBool(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if w, isW := node.attributes[mongoWAttr]; isW {
+		if count, err := strconv.Atoi(w); err == nil {
+			options.writeConcern = append(options.writeConcern, bsonElement{"w", int32(count)})
+		} else {
+			options.writeConcern = append(options.writeConcern, bsonElement{"w", w})
+		}
+	}
+	if _, isJournal := node.attributes[mongoJournalAttr]; isJournal {
+		options.writeConcern = append(options.writeConcern, bsonElement{"j", bools[mongoJournalAttr]})
+	}
+	if wTimeout := durations[mongoWTimeoutAttr]; wTimeout > 0 {
+		options.writeConcern = append(options.writeConcern, bsonElement{"wtimeout", int64(wTimeout / time.Millisecond)})
+	}
+
+	if certDir, isCertDir := node.attributes[mongoCACertDirAttr]; isCertDir {
+		options.tlsConfig, err = getTLSConfig([]string{certDir}, host)
+		if err != nil {
+			return nil, err
+		}
+	} else if bools[mongoTLSAttr] {
+		options.tlsConfig = &tls.Config{ServerName: host}
+	}
+
+	return newMongoWriter(currentFormat, addr, options)
+}
+
+// createClickhouseWriter creates a receiver inserting messages into a ClickHouse table over
+// the HTTP interface. Batching, retries, buffering and compression are those of the http
+// receiver, with the same attributes, except that bodies are gzipped at first with
+// compression="auto". The password may be a secret reference:
+//     <clickhouse url="http://clickhouse.local:8123" database="logs" table="app" user="logger"
+//         password="env://CLICKHOUSE_PASSWORD" async="true" compression="none" maxcount="1000"/>
+func createClickhouseWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, httpUrlAttr, clickhouseDatabaseAttr, clickhouseTableAttr,
+		clickhouseUserAttr, httpPasswordAttr, clickhouseAsyncAttr, httpCompressionAttr, httpMaxCountAttr, httpMaxSizeAttr,
+		httpMaxIntervalAttr, httpRetriesAttr, httpBackoffAttr, httpTimeoutAttr, httpBufferPathAttr, httpBufferSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := node.attributes[httpUrlAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), httpUrlAttr)
+	}
+	table, ok := node.attributes[clickhouseTableAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), clickhouseTableAttr)
+	}
+	password, err := resolveSecret(node.attributes[httpPasswordAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + httpPasswordAttr + "': " + err.Error())
+	}
+
+	async := false
+	if asyncStr, isAsync := node.attributes[clickhouseAsyncAttr]; isAsync {
+		async, err = strconv.ParseBool(asyncStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options := httpWriterOptions{autoCompression: httpCompressionGzip, retries: defaultHttpRetries}
+	if err := parseHttpWriterOptions(node, &options); err != nil {
+		return nil, err
+	}
+
+	return newClickhouseWriter(currentFormat, endpoint, node.attributes[clickhouseDatabaseAttr], table,
+		node.attributes[clickhouseUserAttr], password, async, options)
+}
+
+// createInfluxWriter creates a receiver writing messages as InfluxDB line protocol points to a
+// bucket, or to a database of InfluxDB 1. Fields named in tags become tags of the points. Batching,
+// retries, buffering and compression are those of the http receiver, with the same attributes,
+// except that bodies are gzipped at first with compression="auto". The token or password may be
+// a secret reference:
+//     <influxdb url="http://influx.local:8086" org="ops" bucket="events" token="env://INFLUX_TOKEN"
+//         measurement="app" tags="service,region" precision="ms"/>
+func createInfluxWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, httpUrlAttr, influxOrgAttr, influxBucketAttr,
+		influxDatabaseAttr, influxMeasurementAttr, influxTagsAttr, influxPrecisionAttr, httpUsernameAttr,
+		httpPasswordAttr, httpTokenAttr, httpCompressionAttr, httpMaxCountAttr, httpMaxSizeAttr, httpMaxIntervalAttr,
+		httpRetriesAttr, httpBackoffAttr, httpTimeoutAttr, httpBufferPathAttr, httpBufferSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := node.attributes[httpUrlAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), httpUrlAttr)
+	}
+
+	options := influxWriterOptions{
+		org:         node.attributes[influxOrgAttr],
+		bucket:      node.attributes[influxBucketAttr],
+		database:    node.attributes[influxDatabaseAttr],
+		measurement: node.attributes[influxMeasurementAttr],
+		precision:   node.attributes[influxPrecisionAttr],
+	}
+	if tags, isTags := node.attributes[influxTagsAttr]; isTags {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				options.tags = append(options.tags, tag)
+			}
+		}
+	}
+
+	httpOptions := httpWriterOptions{autoCompression: httpCompressionGzip, retries: defaultHttpRetries,
+		headers: make(http.Header)}
+	if err := parseHttpAuthorization(node, httpOptions.headers, "Token"); err != nil {
+		return nil, err
+	}
+	if err := parseHttpWriterOptions(node, &httpOptions); err != nil {
+		return nil, err
+	}
+
+	return newInfluxWriter(currentFormat, endpoint, options, httpOptions)
+}
+
+// createSlackWriter creates a receiver posting warnings and errors to Slack incoming webhooks.
+// The format of the output is the message template. Route children send the given levels to
+// another webhook or channel; each destination is deduplicated and rate limited on its own.
+// Webhook urls may be secret references:
+//     <slack url="env://SLACK_WEBHOOK" channel="#alerts" username="billing" icon=":rotating_light:"
+//         minlevel="error" dedup="10m" limit="5" interval="1m">
+//         <route levels="critical" channel="#oncall"/>
+//     </slack>
+func createSlackWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	err := checkUnexpectedAttribute(node, outputFormatId, httpUrlAttr, slackChannelAttr, slackUsernameAttr,
+		slackIconAttr, minLevelId, slackDedupAttr, slackLimitAttr, slackIntervalAttr, httpTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := node.attributes[httpUrlAttr]; !ok {
+		return nil, newMissingArgumentError(node.errorName(), httpUrlAttr)
+	}
+	url, err := resolveSecretURL(node.attributes[httpUrlAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + httpUrlAttr + "': " + err.Error())
+	}
+
+	options := slackWriterOptions{
+		minLevel: defaultSlackMinLevel,
+		channel:  node.attributes[slackChannelAttr],
+		username: node.attributes[slackUsernameAttr],
+		icon:     node.attributes[slackIconAttr],
+	}
+	if minLevelStr, isMinLevel := node.attributes[minLevelId]; isMinLevel {
+		var found bool
+		options.minLevel, found = node.levels.fromString(minLevelStr)
+		if !found || options.minLevel == Off {
+			return nil, errors.New("Declared " + minLevelId + " not found: " + minLevelStr)
+		}
+	}
+	if limitStr, isLimit := node.attributes[slackLimitAttr]; isLimit {
+		options.limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			return nil, err
+		}
+		if options.limit < 0 {
+			return nil, errors.New(node.errorName() + " attribute '" + slackLimitAttr + "' can not be negative")
+		}
+		if options.limit == 0 {
+			options.limit = -1
+		}
+	}
+	durations := map[string]*time.Duration{
+		slackDedupAttr:    &options.dedup,
+		slackIntervalAttr: &options.interval,
+		httpTimeoutAttr:   &options.timeout,
+	}
+	for attr, duration := range durations {
+		if durationStr, isDuration := node.attributes[attr]; isDuration {
+			*duration, err = time.ParseDuration(durationStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, isDedup := node.attributes[slackDedupAttr]; isDedup && options.dedup == 0 {
+		options.dedup = -1
+	}
+
+	for _, childNode := range node.children {
+		if childNode.name != slackRouteId {
+			return nil, newUnexpectedChildElementError(childNode.errorName())
+		}
+		err := checkUnexpectedAttribute(childNode, levelsId, httpUrlAttr, slackChannelAttr)
+		if err != nil {
+			return nil, err
+		}
+		levelsStr, ok := childNode.attributes[levelsId]
+		if !ok {
+			return nil, newMissingArgumentError(childNode.errorName(), levelsId)
+		}
+		levels, err := parseLevels(levelsStr, node.levels)
+		if err != nil {
+			return nil, err
+		}
+		routeURL, err := resolveSecretURL(childNode.attributes[httpUrlAttr])
+		if err != nil {
+			return nil, errors.New(childNode.errorName() + " attribute '" + httpUrlAttr + "': " + err.Error())
+		}
+		options.routes = append(options.routes, &slackRoute{
+			levels:  levels,
+			url:     routeURL,
+			channel: childNode.attributes[slackChannelAttr],
+		})
+	}
+
+	return newSlackWriter(currentFormat, url, options)
+}
+
+// createTelegramWriter creates a receiver sending warnings and errors to Telegram chats through
+// a bot. Bursts are sent as one Telegram message, and at most limit messages are sent per
+// interval. The token may be a secret reference:
+//     <telegram token="env://TELEGRAM_TOKEN" chats="-1001234567890,42" minlevel="error" delay="5s"
+//         limit="10" interval="1m"/>
+func createTelegramWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, telegramTokenAttr, telegramChatsAttr, telegramAPIAttr,
+		minLevelId, telegramDelayAttr, telegramLimitAttr, telegramIntervalAttr, telegramTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := node.attributes[telegramTokenAttr]; !ok {
+		return nil, newMissingArgumentError(node.errorName(), telegramTokenAttr)
+	}
+	token, err := resolveSecret(node.attributes[telegramTokenAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + telegramTokenAttr + "': " + err.Error())
+	}
+	chatsStr, ok := node.attributes[telegramChatsAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), telegramChatsAttr)
+	}
+	var chats []string
+	for _, chat := range strings.Split(chatsStr, ",") {
+		if chat = strings.TrimSpace(chat); chat != "" {
+			chats = append(chats, chat)
+		}
+	}
+
+	options := telegramWriterOptions{
+		api:      node.attributes[telegramAPIAttr],
+		minLevel: defaultTelegramMinLevel,
+	}
+	if minLevelStr, isMinLevel := node.attributes[minLevelId]; isMinLevel {
+		var found bool
+		options.minLevel, found = node.levels.fromString(minLevelStr)
+		if !found || options.minLevel == Off {
+			return nil, errors.New("Declared " + minLevelId + " not found: " + minLevelStr)
+		}
+	}
+	if limitStr, isLimit := node.attributes[telegramLimitAttr]; isLimit {
+		options.limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			return nil, err
+		}
+		if options.limit < 0 {
+			return nil, errors.New(node.errorName() + " attribute '" + telegramLimitAttr + "' can not be negative")
+		}
+		if options.limit == 0 {
+			options.limit = -1
+		}
+	}
+	durations := map[string]*time.Duration{
+		telegramDelayAttr:    &options.delay,
+		telegramIntervalAttr: &options.interval,
+		telegramTimeoutAttr:  &options.timeout,
+	}
+	for attr, duration := range durations {
+		if durationStr, isDuration := node.attributes[attr]; isDuration {
+			*duration, err = time.ParseDuration(durationStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return newTelegramWriter(currentFormat, token, chats, options)
+}
+
+// createPagerDutyWriter creates a receiver triggering PagerDuty alerts for critical messages.
+// The dedup key is a format, by default the function and line of the log call; with
+// autoresolve, alerts are resolved once they have not been triggered for that long. The
+// routing key may be a secret reference:
+//     <pagerduty routingkey="env://PAGERDUTY_KEY" dedupkey="%Func:%Field(tenant)" component="billing"
+//         autoresolve="15m"/>
+func createPagerDutyWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, pagerDutyRoutingKeyAttr, httpUrlAttr, minLevelId,
+		pagerDutyDedupKeyAttr, pagerDutySourceAttr, pagerDutyComponentAttr, pagerDutyGroupAttr, pagerDutyClassAttr,
+		pagerDutyRepeatAttr, pagerDutyAutoResolveAttr, httpRetriesAttr, httpBackoffAttr, httpTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := node.attributes[pagerDutyRoutingKeyAttr]; !ok {
+		return nil, newMissingArgumentError(node.errorName(), pagerDutyRoutingKeyAttr)
+	}
+	routingKey, err := resolveSecret(node.attributes[pagerDutyRoutingKeyAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + pagerDutyRoutingKeyAttr + "': " + err.Error())
+	}
+
+	options := pagerDutyWriterOptions{
+		url:       node.attributes[httpUrlAttr],
+		minLevel:  defaultPagerDutyMinLevel,
+		dedupKey:  node.attributes[pagerDutyDedupKeyAttr],
+		source:    node.attributes[pagerDutySourceAttr],
+		component: node.attributes[pagerDutyComponentAttr],
+		group:     node.attributes[pagerDutyGroupAttr],
+		class:     node.attributes[pagerDutyClassAttr],
+		retries:   defaultPagerDutyRetries,
+	}
+	if minLevelStr, isMinLevel := node.attributes[minLevelId]; isMinLevel {
+		var found bool
+		options.minLevel, found = node.levels.fromString(minLevelStr)
+		if !found || options.minLevel == Off {
+			return nil, errors.New("Declared " + minLevelId + " not found: " + minLevelStr)
+		}
+	}
+	if retriesStr, isRetries := node.attributes[httpRetriesAttr]; isRetries {
+		options.retries, err = strconv.Atoi(retriesStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	durations := map[string]*time.Duration{
+		pagerDutyRepeatAttr:      &options.repeat,
+		pagerDutyAutoResolveAttr: &options.autoResolve,
+		httpBackoffAttr:          &options.backoff,
+		httpTimeoutAttr:          &options.timeout,
+	}
+	for attr, duration := range durations {
+		if durationStr, isDuration := node.attributes[attr]; isDuration {
+			*duration, err = time.ParseDuration(durationStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, isRepeat := node.attributes[pagerDutyRepeatAttr]; isRepeat && options.repeat == 0 {
+		options.repeat = -1
+	}
+
+	return newPagerDutyWriter(currentFormat, routingKey, options)
+}
+
+// createDatadogWriter creates a receiver sending messages to the Datadog logs intake of a site.
+// Batching, retries, buffering and compression are those of the http receiver, with the same
+// attributes, except that batches are gzipped at first with compression="auto". The API key may
+// be a secret reference:
+//     <datadog apikey="env://DD_API_KEY" site="datadoghq.eu" service="billing" tags="env:prod,team:payments"/>
+func createDatadogWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, datadogApiKeyAttr, datadogSiteAttr, httpUrlAttr,
+		datadogSourceAttr, datadogTagsAttr, datadogServiceAttr, datadogHostnameAttr, httpCompressionAttr,
+		httpMaxCountAttr, httpMaxSizeAttr, httpMaxIntervalAttr, httpRetriesAttr, httpBackoffAttr, httpTimeoutAttr,
+		httpBufferPathAttr, httpBufferSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := node.attributes[datadogApiKeyAttr]; !ok {
+		return nil, newMissingArgumentError(node.errorName(), datadogApiKeyAttr)
+	}
+	apiKey, err := resolveSecret(node.attributes[datadogApiKeyAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + datadogApiKeyAttr + "': " + err.Error())
+	}
+
+	options := datadogWriterOptions{
+		site:     node.attributes[datadogSiteAttr],
+		url:      node.attributes[httpUrlAttr],
+		source:   node.attributes[datadogSourceAttr],
+		tags:     node.attributes[datadogTagsAttr],
+		service:  node.attributes[datadogServiceAttr],
+		hostname: node.attributes[datadogHostnameAttr],
+	}
+	httpOptions := httpWriterOptions{autoCompression: httpCompressionGzip, retries: defaultHttpRetries}
+	if err := parseHttpWriterOptions(node, &httpOptions); err != nil {
+		return nil, err
+	}
+
+	return newDatadogWriter(currentFormat, apiKey, options, httpOptions)
+}
+
+// createSplunkWriter creates a receiver sending messages to a Splunk HTTP Event Collector. With
+// ack, batches count as sent once the collector acknowledges them. Batching, retries, buffering
+// and compression are those of the http receiver, with the same attributes, except that batches
+// are gzipped at first with compression="auto". The token may be a secret reference:
+//     <splunk url="https://splunk.local:8088" token="env://SPLUNK_HEC_TOKEN" index="app" sourcetype="_json"
+//         ack="true"/>
+func createSplunkWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, httpUrlAttr, httpTokenAttr, splunkIndexAttr,
+		splunkSourceAttr, splunkSourceTypeAttr, splunkHostAttr, splunkAckAttr, splunkChannelAttr, splunkAckTimeoutAttr,
+		httpCompressionAttr, httpMaxCountAttr, httpMaxSizeAttr, httpMaxIntervalAttr, httpRetriesAttr,
+		httpBackoffAttr, httpTimeoutAttr, httpBufferPathAttr, httpBufferSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := node.attributes[httpUrlAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), httpUrlAttr)
+	}
+	if _, ok := node.attributes[httpTokenAttr]; !ok {
+		return nil, newMissingArgumentError(node.errorName(), httpTokenAttr)
+	}
+	token, err := resolveSecret(node.attributes[httpTokenAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + httpTokenAttr + "': " + err.Error())
+	}
+
+	options := splunkWriterOptions{
+		index:      node.attributes[splunkIndexAttr],
+		source:     node.attributes[splunkSourceAttr],
+		sourceType: node.attributes[splunkSourceTypeAttr],
+		host:       node.attributes[splunkHostAttr],
+		channel:    node.attributes[splunkChannelAttr],
+	}
+	if ackStr, isAck := node.attributes[splunkAckAttr]; isAck {
+		options.ack, err = strconv.ParseBool(ackStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ackTimeoutStr, isAckTimeout := node.attributes[splunkAckTimeoutAttr]; isAckTimeout {
+		options.ackTimeout, err = time.ParseDuration(ackTimeoutStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	httpOptions := httpWriterOptions{autoCompression: httpCompressionGzip, retries: defaultHttpRetries}
+	if err := parseHttpWriterOptions(node, &httpOptions); err != nil {
+		return nil, err
+	}
+
+	return newSplunkWriter(currentFormat, endpoint, token, options, httpOptions)
+}
+
+// createCloudWatchWriter creates a receiver sending messages to a log stream of AWS CloudWatch
+// Logs, by default named after the host and process. The region defaults to AWS_REGION. Without
+// keys, the keys of the environment or the role of the task or instance are used; keys may be
+// secret references:
+//     <cloudwatch region="eu-west-1" group="/app/billing" stream="web-1" retention="30" maxinterval="2s"/>
+func createCloudWatchWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, objectStoreRegionAttr, cloudWatchGroupAttr,
+		cloudWatchStreamAttr, cloudWatchRetentionAttr, objectStoreEndpointAttr, objectStoreAccessKeyAttr,
+		objectStoreSecretKeyAttr, httpMaxCountAttr, httpMaxIntervalAttr, httpRetriesAttr, httpBackoffAttr,
+		httpTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	group, ok := node.attributes[cloudWatchGroupAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), cloudWatchGroupAttr)
+	}
+
+	options := cloudWatchWriterOptions{
+		endpoint: node.attributes[objectStoreEndpointAttr],
+		stream:   node.attributes[cloudWatchStreamAttr],
+		retries:  defaultCloudWatchRetries,
+	}
+	if options.accessKey, err = resolveSecret(node.attributes[objectStoreAccessKeyAttr]); err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + objectStoreAccessKeyAttr + "': " + err.Error())
+	}
+	if options.secretKey, err = resolveSecret(node.attributes[objectStoreSecretKeyAttr]); err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + objectStoreSecretKeyAttr + "': " + err.Error())
+	}
+
+	ints := map[string]*int{
+		cloudWatchRetentionAttr: &options.retention,
+		httpMaxCountAttr:        &options.maxCount,
+		httpRetriesAttr:         &options.retries,
+	}
+	for attr, value := range ints {
+		if valueStr, isValue := node.attributes[attr]; isValue {
+			*value, err = strconv.Atoi(valueStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	durations := map[string]*time.Duration{
+		httpMaxIntervalAttr: &options.maxInterval,
+		httpBackoffAttr:     &options.backoff,
+		httpTimeoutAttr:     &options.timeout,
+	}
+	for attr, duration := range durations {
+		if durationStr, isDuration := node.attributes[attr]; isDuration {
+			*duration, err = time.ParseDuration(durationStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return newCloudWatchWriter(currentFormat, node.attributes[objectStoreRegionAttr], group, options)
+}
+
+// createCloudLoggingWriter creates a receiver writing messages to Google Cloud Logging,
+// with the credentials of the 'credentials' file or else the application default
+// credentials. The project is detected, like the monitored resource, if it is not set.
+// 'labels' are comma separated key=value pairs added to every entry. Batches are sent at
+// 'maxcount' messages (1000 by default) or 'maxinterval' after their first message (5s
+// by default), and retried 'retries' times (3 by default) from a 'backoff' of 500ms:
+//     <cloudlogging log="billing" labels="team=payments,tier=backend" maxinterval="2s"/>
+func createCloudLoggingWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, cloudLoggingProjectAttr, cloudLoggingLogAttr,
+		cloudLoggingLabelsAttr, gcsArchiveCredentialsAttr, gcsArchiveEndpointAttr, httpMaxCountAttr,
+		httpMaxIntervalAttr, httpRetriesAttr, httpBackoffAttr, httpTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	options := cloudLoggingWriterOptions{
+		endpoint:    node.attributes[gcsArchiveEndpointAttr],
+		project:     node.attributes[cloudLoggingProjectAttr],
+		log:         node.attributes[cloudLoggingLogAttr],
+		credentials: node.attributes[gcsArchiveCredentialsAttr],
+		retries:     defaultCloudLoggingRetries,
+	}
+	if labels, isLabels := node.attributes[cloudLoggingLabelsAttr]; isLabels {
+		options.labels = make(map[string]string)
+		for _, label := range strings.Split(labels, ",") {
+			if label = strings.TrimSpace(label); label == "" {
+				continue
+			}
+			separator := strings.Index(label, "=")
+			if separator <= 0 {
+				return nil, errors.New(node.errorName() + " attribute '" + cloudLoggingLabelsAttr +
+					"': expected key=value: " + label)
+			}
+			options.labels[strings.TrimSpace(label[:separator])] = strings.TrimSpace(label[separator+1:])
+		}
+	}
+
+	ints := map[string]*int{
+		httpMaxCountAttr: &options.maxCount,
+		httpRetriesAttr:  &options.retries,
+	}
+	for attr, value := range ints {
+		if valueStr, isValue := node.attributes[attr]; isValue {
+			*value, err = strconv.Atoi(valueStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	durations := map[string]*time.Duration{
+		httpMaxIntervalAttr: &options.maxInterval,
+		httpBackoffAttr:     &options.backoff,
+		httpTimeoutAttr:     &options.timeout,
+	}
+	for attr, duration := range durations {
+		if durationStr, isDuration := node.attributes[attr]; isDuration {
+			*duration, err = time.ParseDuration(durationStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return newCloudLoggingWriter(currentFormat, options)
+}
+
+// createAzureMonitorWriter creates a receiver sending messages to a Log Analytics workspace
+// as records of the custom log type 'logtype' (Seelog by default). Batching, retries and
+// buffering are those of the http receiver, with the same attributes. The shared key may
+// be a secret reference:
+//     <azuremonitor workspace="0b5f1a8e-7c2d-4e4b-9a61-3f2d8c9e1a77" sharedkey="env://LOG_ANALYTICS_KEY"
+//         logtype="Billing"/>
+func createAzureMonitorWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, azureMonitorWorkspaceAttr, azureMonitorSharedKeyAttr,
+		azureMonitorLogTypeAttr, azureMonitorResourceIdAttr, httpUrlAttr, httpMaxCountAttr, httpMaxSizeAttr,
+		httpMaxIntervalAttr, httpRetriesAttr, httpBackoffAttr, httpTimeoutAttr, httpBufferPathAttr, httpBufferSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceId, ok := node.attributes[azureMonitorWorkspaceAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), azureMonitorWorkspaceAttr)
+	}
+	if _, ok := node.attributes[azureMonitorSharedKeyAttr]; !ok {
+		return nil, newMissingArgumentError(node.errorName(), azureMonitorSharedKeyAttr)
+	}
+	sharedKey, err := resolveSecret(node.attributes[azureMonitorSharedKeyAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + azureMonitorSharedKeyAttr + "': " + err.Error())
+	}
+
+	options := azureMonitorWriterOptions{
+		url:        node.attributes[httpUrlAttr],
+		logType:    node.attributes[azureMonitorLogTypeAttr],
+		resourceId: node.attributes[azureMonitorResourceIdAttr],
+	}
+	httpOptions := httpWriterOptions{retries: defaultHttpRetries}
+	if err := parseHttpWriterOptions(node, &httpOptions); err != nil {
+		return nil, err
+	}
+
+	return newAzureMonitorWriter(currentFormat, workspaceId, sharedKey, options, httpOptions)
+}
+
+// createArchiveWriter creates an archive receiver uploading with the uploader, from the
+// attributes all archive receivers share. Chunks are buffered in 'dir', which is
+// required, and uploaded at 'maxsize' bytes (16 MiB by default) or 'maxinterval' after
+// their first message (5 minutes by default). Chunks failing to upload are kept in 'dir'
+// up to 'buffersize' bytes (1 GiB by default). 'key' is a format for the object keys,
+// applied with the time of the first message and the fields service (the 'service',
+// the executable name by default), host, pid and seq:
+//     key="%Field(service)/%UTCDate(2006/01/02)/%UTCDate(15)/%Field(host)-%Field(pid)-%UTCDate(150405.000)-%Field(seq).log.gz"
+func createArchiveWriter(node *xmlNode, uploader archiveUploader, formatFromParent *formatter,
+	formats map[string]*formatter) (interface{}, error) {
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, ok := node.attributes[archiveDirAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), archiveDirAttr)
+	}
+
+	sizes := map[string]int64{archiveMaxSizeAttr: 0, archiveBufferSizeAttr: 0}
+	for attr := range sizes {
+		if str, isSet := node.attributes[attr]; isSet {
+			sizes[attr], err = strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var maxInterval time.Duration
+	if maxIntervalStr, isMaxInterval := node.attributes[archiveMaxIntervalAttr]; isMaxInterval {
+		maxInterval, err = time.ParseDuration(maxIntervalStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	archiveWriter, err := newArchiveWriter(uploader, dir, node.attributes[archiveKeyAttr],
+		node.attributes[archiveServiceAttr], sizes[archiveMaxSizeAttr], maxInterval, sizes[archiveBufferSizeAttr])
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(archiveWriter, currentFormat)
+}
+
+// createElasticsearchWriter creates a receiver indexing messages in Elasticsearch with the
+// _bulk API. The index is a format ("logs-%Date" by default). The password and the api key
+// may be secret references. Batches are sent at 'maxcount' messages (500 by default),
+// 'maxsize' bytes (5 MiB by default) or 'maxinterval' after their first message (5s by
+// default), and retried 'retries' times (3 by default) from a 'backoff' of 500ms. Requests
+// are gzipped unless 'compression' is none. With auto, the default, they follow what the
+// server advertises with Accept-Encoding, and a request rejected with 415 is sent again
+// uncompressed:
+//     <elasticsearch endpoint="https://es.local:9200" index="logs-billing-%Date(2006.01.02)"
+//         apikey="env://ES_API_KEY" maxcount="1000" maxinterval="2s" retries="5" backoff="1s"/>
+func createElasticsearchWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, elasticsearchEndpointAttr, elasticsearchIndexAttr,
+		elasticsearchUsernameAttr, elasticsearchPasswordAttr, elasticsearchApiKeyAttr, elasticsearchMaxCountAttr,
+		elasticsearchMaxSizeAttr, elasticsearchMaxIntervalAttr, elasticsearchRetriesAttr, elasticsearchBackoffAttr,
+		elasticsearchCompressionAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := node.attributes[elasticsearchEndpointAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), elasticsearchEndpointAttr)
+	}
+
+	secrets := make(map[string]string)
+	for _, attr := range []string{elasticsearchPasswordAttr, elasticsearchApiKeyAttr} {
+		secrets[attr], err = resolveSecret(node.attributes[attr])
+		if err != nil {
+			return nil, errors.New(node.errorName() + " attribute '" + attr + "': " + err.Error())
+		}
+	}
+
+	ints := map[string]int{
+		elasticsearchMaxCountAttr: defaultElasticsearchMaxCount,
+		elasticsearchMaxSizeAttr:  defaultElasticsearchMaxSize,
+		elasticsearchRetriesAttr:  defaultElasticsearchRetries,
+	}
+	for attr := range ints {
+		if str, isSet := node.attributes[attr]; isSet {
+			ints[attr], err = strconv.Atoi(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	durations := map[string]time.Duration{
+		elasticsearchMaxIntervalAttr: defaultElasticsearchMaxInterval,
+		elasticsearchBackoffAttr:     defaultElasticsearchBackoff,
+	}
+	for attr := range durations {
+		if str, isSet := node.attributes[attr]; isSet {
+			durations[attr], err = time.ParseDuration(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return newElasticsearchWriter(currentFormat, endpoint, node.attributes[elasticsearchIndexAttr],
+		node.attributes[elasticsearchUsernameAttr], secrets[elasticsearchPasswordAttr], secrets[elasticsearchApiKeyAttr],
+		node.attributes[elasticsearchCompressionAttr], ints[elasticsearchMaxCountAttr], ints[elasticsearchMaxSizeAttr],
+		durations[elasticsearchMaxIntervalAttr], ints[elasticsearchRetriesAttr], durations[elasticsearchBackoffAttr])
+}
+
+// createFluentWriter creates a receiver sending messages to a Fluentd aggregator over the
+// forward protocol. With 'cacertdirpath' the server certificate is verified against the
+// PEM files in the directory instead of the system roots, and tls is implied:
+//     <fluent addr="fluentd.local:24224" tag="app.billing" ack="true" tls="true" timeout="10s"/>
+func createFluentWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, fluentAddrAttr, fluentTagAttr, fluentAckAttr,
+		fluentTLSAttr, fluentCACertDirAttr, fluentTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[fluentAddrAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), fluentAddrAttr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + fluentAddrAttr + "' attribute value")
+	}
+
+	bools := map[string]bool{fluentAckAttr: false, fluentTLSAttr: false}
+	for attr := range bools {
+		if str, isSet := node.attributes[attr]; isSet {
+			bools[attr], err = strconv.ParseBool(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if certDir, isCertDir := node.attributes[fluentCACertDirAttr]; isCertDir {
+		tlsConfig, err = getTLSConfig([]string{certDir}, host)
+		if err != nil {
+			return nil, err
+		}
+	} else if bools[fluentTLSAttr] {
+		tlsConfig = &tls.Config{ServerName: host}
+	}
+
+	timeout := defaultFluentTimeout
+	if timeoutStr, isTimeout := node.attributes[fluentTimeoutAttr]; isTimeout {
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newFluentWriter(currentFormat, addr, node.attributes[fluentTagAttr], bools[fluentAckAttr], tlsConfig, timeout)
+}
+
+// createGelfWriter creates a receiver sending messages to Graylog in the GELF format, over
+// udp (the default) or tcp. Over udp messages may be compressed with compress="gzip", and
+// are split into chunks of 'chunksize' bytes (1420 by default). 'host' defaults to the
+// host name:
+//     <gelf net="udp" addr="graylog.local:12201" compress="gzip" chunksize="8154"/>
+func createGelfWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, gelfNetAttr, gelfAddrAttr, gelfHostAttr,
+		gelfCompressAttr, gelfChunkSizeAttr, gelfTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[gelfAddrAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), gelfAddrAttr)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + gelfAddrAttr + "' attribute value")
+	}
+
+	chunkSize := 0
+	if chunkSizeStr, isChunkSize := node.attributes[gelfChunkSizeAttr]; isChunkSize {
+		chunkSize, err = strconv.Atoi(chunkSizeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := defaultGelfTimeout
+	if timeoutStr, isTimeout := node.attributes[gelfTimeoutAttr]; isTimeout {
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newGelfWriter(currentFormat, node.attributes[gelfNetAttr], addr, node.attributes[gelfHostAttr],
+		node.attributes[gelfCompressAttr], chunkSize, timeout)
+}
+
+// createLogstashWriter creates a receiver sending messages to Logstash as json_lines over
+// tcp, or tls as for fluent. Undeliverable messages are kept in a buffer of 'buffersize'
+// bytes (1 MiB by default) until Logstash is reachable, which is dialed again at most
+// every 'reconnect' (a second by default). keepalive="0s" disables tcp keepalives:
+//     <logstash addr="logstash.local:5000" tls="true" keepalive="1m" buffersize="4194304" reconnect="5s"/>
+func createLogstashWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, logstashAddrAttr, logstashTLSAttr, logstashCACertDirAttr,
+		logstashTimeoutAttr, logstashKeepAliveAttr, logstashBufferSizeAttr, logstashReconnectAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[logstashAddrAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), logstashAddrAttr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + logstashAddrAttr + "' attribute value")
+	}
+
+	useTLS := false
+	if tlsStr, isTLS := node.attributes[logstashTLSAttr]; isTLS {
+		useTLS, err = strconv.ParseBool(tlsStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if certDir, isCertDir := node.attributes[logstashCACertDirAttr]; isCertDir {
+		tlsConfig, err = getTLSConfig([]string{certDir}, host)
+		if err != nil {
+			return nil, err
+		}
+	} else if useTLS {
+		tlsConfig = &tls.Config{ServerName: host}
+	}
+
+	durations := map[string]time.Duration{
+		logstashTimeoutAttr:   defaultLogstashTimeout,
+		logstashKeepAliveAttr: defaultLogstashKeepAlive,
+		logstashReconnectAttr: defaultLogstashReconnect,
+	}
+	for attr := range durations {
+		if str, isSet := node.attributes[attr]; isSet {
+			durations[attr], err = time.ParseDuration(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	bufferSize := defaultLogstashBufferSize
+	if bufferSizeStr, isBufferSize := node.attributes[logstashBufferSizeAttr]; isBufferSize {
+		bufferSize, err = strconv.Atoi(bufferSizeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newLogstashWriter(currentFormat, addr, tlsConfig, durations[logstashTimeoutAttr],
+		durations[logstashKeepAliveAttr], bufferSize, durations[logstashReconnectAttr])
+}
+
+// createRedisWriter creates a receiver pushing messages to a Redis list, or publishing
+// them to a channel with mode="publish". Without a formatid the messages are JSON
+// records (std:json-fields). The password may be a secret reference:
+//     <redis addr="localhost:6379" key="logstash" password="env://REDIS_PASSWORD" db="1" poolsize="4" timeout="2s"/>
+func createRedisWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, redisAddrAttr, redisModeAttr, redisKeyAttr,
+		redisPasswordAttr, redisDbAttr, redisPoolSizeAttr, redisTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, predefinedFormats[predefinedPrefix+"json-fields"], formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[redisAddrAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), redisAddrAttr)
+	}
+	key, ok := node.attributes[redisKeyAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), redisKeyAttr)
+	}
+
+	password, err := resolveSecret(node.attributes[redisPasswordAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + redisPasswordAttr + "': " + err.Error())
+	}
+
+	ints := map[string]int{redisDbAttr: 0, redisPoolSizeAttr: defaultRedisPoolSize}
+	for attr := range ints {
+		if str, isSet := node.attributes[attr]; isSet {
+			ints[attr], err = strconv.Atoi(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	timeout := defaultRedisTimeout
+	if timeoutStr, isTimeout := node.attributes[redisTimeoutAttr]; isTimeout {
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	redisWriter, err := newRedisWriter(addr, node.attributes[redisModeAttr], key, password,
+		ints[redisDbAttr], ints[redisPoolSizeAttr], timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(redisWriter, currentFormat)
+}
+
+// createHttpWriter creates a receiver posting messages to an http endpoint, one per request
+// with batch="none", or in batches as a JSON array (the default) or as ndjson. Without a
+// formatid the messages are JSON records (std:json-fields). Header values, the password
+// and the bearer token may be secret references. With 'bufferpath', records which can not
+// be delivered are kept in that file, up to 'buffersize' bytes (64 MiB by default). Bodies
+// are compressed with 'compression' none or gzip, or with auto (the default) uncompressed
+// until the server advertises gzip with Accept-Encoding. A body which the server answers
+// with 415 is sent again uncompressed:
+//     <http url="https://hooks.local/logs" batch="ndjson" compression="gzip" token="env://HOOK_TOKEN"
+//         maxcount="500" maxinterval="2s" retries="5" timeout="5s" bufferpath="/var/spool/app/http.buf">
+//         <header name="X-Source" value="billing"/>
+//     </http>
+func createHttpWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	err := checkUnexpectedAttribute(node, outputFormatId, httpUrlAttr, httpBatchAttr, httpContentTypeAttr,
+		httpCompressionAttr, httpUsernameAttr, httpPasswordAttr, httpTokenAttr, httpMaxCountAttr, httpMaxSizeAttr,
+		httpMaxIntervalAttr, httpRetriesAttr, httpBackoffAttr, httpTimeoutAttr, httpBufferPathAttr, httpBufferSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, predefinedFormats[predefinedPrefix+"json-fields"], formats)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := node.attributes[httpUrlAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), httpUrlAttr)
+	}
+
+	options := httpWriterOptions{
+		batch:       node.attributes[httpBatchAttr],
+		contentType: node.attributes[httpContentTypeAttr],
+		headers:     make(http.Header),
+		retries:     defaultHttpRetries,
+	}
+
+	for _, childNode := range node.children {
+		if childNode.name != httpHeaderId {
+			return nil, newUnexpectedChildElementError(childNode.errorName())
+		}
+		name, ok := childNode.attributes[httpHeaderNameAttr]
+		if !ok {
+			return nil, newMissingArgumentError(childNode.errorName(), httpHeaderNameAttr)
+		}
+		value, err := resolveSecret(childNode.attributes[httpHeaderValueAttr])
+		if err != nil {
+			return nil, errors.New(childNode.errorName() + " attribute '" + httpHeaderValueAttr + "': " + err.Error())
+		}
+		options.headers.Add(name, value)
+	}
+
+	if err := parseHttpAuthorization(node, options.headers, "Bearer"); err != nil {
+		return nil, err
+	}
+
+	if err := parseHttpWriterOptions(node, &options); err != nil {
+		return nil, err
+	}
+
+	httpWriter, err := newHttpWriter(endpoint, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(httpWriter, currentFormat)
+}
+
+// parseHttpAuthorization sets the Authorization header from the username and password
+// attributes, or from the token attribute with the given scheme.
+func parseHttpAuthorization(node *xmlNode, headers http.Header, tokenScheme string) error {
+	secrets := make(map[string]string)
+	for _, attr := range []string{httpPasswordAttr, httpTokenAttr} {
+		secret, err := resolveSecret(node.attributes[attr])
+		if err != nil {
+			return errors.New(node.errorName() + " attribute '" + attr + "': " + err.Error())
+		}
+		secrets[attr] = secret
+	}
+	if username, isUsername := node.attributes[httpUsernameAttr]; isUsername {
+		if secrets[httpTokenAttr] != "" {
+			return errors.New("Node '" + node.errorName() + "' can not have both '" + httpUsernameAttr +
+				"' and '" + httpTokenAttr + "'")
+		}
+		headers.Set("Authorization", basicAuthorization(username, secrets[httpPasswordAttr]))
+	} else if secrets[httpTokenAttr] != "" {
+		headers.Set("Authorization", tokenScheme+" "+secrets[httpTokenAttr])
+	}
+	return nil
+}
+
+// parseHttpWriterOptions parses the compression, batching, retry and buffering attributes
+// of receivers which send with an httpWriter.
+func parseHttpWriterOptions(node *xmlNode, options *httpWriterOptions) error {
+	var err error
+
+	options.compression = node.attributes[httpCompressionAttr]
+
+	ints := map[string]*int{
+		httpMaxCountAttr: &options.maxCount,
+		httpMaxSizeAttr:  &options.maxSize,
+		httpRetriesAttr:  &options.retries,
+	}
+	for attr, value := range ints {
+		if str, isSet := node.attributes[attr]; isSet {
+			*value, err = strconv.Atoi(str)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if bufferSizeStr, isBufferSize := node.attributes[httpBufferSizeAttr]; isBufferSize {
+		options.bufferSize, err = strconv.ParseInt(bufferSizeStr, 10, 64)
+		if err != nil {
+			return err
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		httpMaxIntervalAttr: &options.maxInterval,
+		httpBackoffAttr:     &options.backoff,
+		httpTimeoutAttr:     &options.timeout,
+	}
+	for attr, value := range durations {
+		if str, isSet := node.attributes[attr]; isSet {
+			*value, err = time.ParseDuration(str)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	options.bufferPath = node.attributes[httpBufferPathAttr]
+	return nil
+}
+
+// createWebsocketWriter creates a receiver serving a WebSocket endpoint for live tailing,
+// which broadcasts messages to its clients. Browsers opening the path get a page showing
+// the messages:
+//     <websocket addr="localhost:9090" path="/logs" buffer="1024"/>
+func createWebsocketWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, websocketAddrAttr, websocketPathAttr, websocketBufferAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[websocketAddrAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), websocketAddrAttr)
+	}
+
+	buffer := 0
+	if bufferStr, isBuffer := node.attributes[websocketBufferAttr]; isBuffer {
+		buffer, err = strconv.Atoi(bufferStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newWebsocketWriter(currentFormat, addr, node.attributes[websocketPathAttr], buffer)
+}
+
+// createUdpWriter creates a receiver sending every message as one datagram, truncated to
+// 'maxsize' bytes. With 'load', only a share of 'sample' of the messages beyond 'load'
+// per second is sent:
+//     <udp addr="collector.local:9999" maxsize="8192" load="1000" sample="0.1"/>
+func createUdpWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, udpAddrAttr, udpMaxSizeAttr, udpLoadAttr, udpSampleAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[udpAddrAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), udpAddrAttr)
+	}
+
+	ints := map[string]int{udpMaxSizeAttr: 0, udpLoadAttr: 0}
+	for attr := range ints {
+		if str, isSet := node.attributes[attr]; isSet {
+			ints[attr], err = strconv.Atoi(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sample := 0.0
+	if sampleStr, isSample := node.attributes[udpSampleAttr]; isSample {
+		if ints[udpLoadAttr] == 0 {
+			return nil, errors.New("Node '" + node.errorName() + "' can not have '" + udpSampleAttr +
+				"' without '" + udpLoadAttr + "'")
+		}
+		sample, err = strconv.ParseFloat(sampleStr, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	udpWriter, err := newUdpWriter(addr, ints[udpMaxSizeAttr], ints[udpLoadAttr], sample)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(udpWriter, currentFormat)
+}
+
+// createFifoWriter creates a receiver writing to a named pipe, which is created if
+// 'create' is true. Messages are kept in a buffer of 'buffersize' bytes (1 MiB by
+// default) while the pipe has no reader or no room, and written again every 'retry' (1s
+// by default); writes wait at most 'timeout' (10ms by default):
+//     <fifo path="/run/app/log.fifo" create="true" buffersize="4194304"/>
+func createFifoWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
 	}
 
-	hostPort, ok := node.attributes[hostPortId]
+	err := checkUnexpectedAttribute(node, outputFormatId, pathId, fifoCreateAttr, fifoBufferSizeAttr, fifoRetryAttr,
+		fifoTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	path, ok := node.attributes[pathId]
 	if !ok {
-		return nil, newMissingArgumentError(node.name, hostPortId)
+		return nil, newMissingArgumentError(node.errorName(), pathId)
 	}
 
-	// Check if the string can really be converted into int.
-	if _, err := strconv.Atoi(hostPort); err != nil {
-		return nil, errors.New("Invalid host port number")
+	var options fifoWriterOptions
+	if createStr, isCreate := node.attributes[fifoCreateAttr]; isCreate {
+		options.create, err = strconv.ParseBool(createStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if bufferSizeStr, isBufferSize := node.attributes[fifoBufferSizeAttr]; isBufferSize {
+		options.bufferSize, err = strconv.Atoi(bufferSizeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	durations := map[string]*time.Duration{
+		fifoRetryAttr:   &options.retry,
+		fifoTimeoutAttr: &options.timeout,
+	}
+	for attr, duration := range durations {
+		if durationStr, isDuration := node.attributes[attr]; isDuration {
+			*duration, err = time.ParseDuration(durationStr)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	userName, ok := node.attributes[userNameId]
+	fifoWriter, err := newFifoWriter(path, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormattedWriter(fifoWriter, currentFormat)
+}
+
+// createStatsdWriter creates a receiver counting messages per level as statsd counters,
+// and sending the 'timers' fields (comma separated) as timings, instead of writing the
+// messages. Metrics are sent every 'interval' (1s by default):
+//     <statsd addr="127.0.0.1:8125" prefix="billing.log" timers="duration,db.latency" tags="env:prod"/>
+func createStatsdWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, udpAddrAttr, statsdPrefixAttr, statsdTagsAttr, statsdTimersAttr,
+		statsdIntervalAttr, udpMaxSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[udpAddrAttr]
 	if !ok {
-		return nil, newMissingArgumentError(node.name, userNameId)
+		return nil, newMissingArgumentError(node.errorName(), udpAddrAttr)
+	}
+
+	options := statsdWriterOptions{
+		prefix: node.attributes[statsdPrefixAttr],
+		tags:   node.attributes[statsdTagsAttr],
+	}
+	if timers, isTimers := node.attributes[statsdTimersAttr]; isTimers {
+		for _, timer := range strings.Split(timers, ",") {
+			if timer = strings.TrimSpace(timer); timer != "" {
+				options.timers = append(options.timers, timer)
+			}
+		}
+	}
+	if intervalStr, isInterval := node.attributes[statsdIntervalAttr]; isInterval {
+		options.interval, err = time.ParseDuration(intervalStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if maxSizeStr, isMaxSize := node.attributes[udpMaxSizeAttr]; isMaxSize {
+		options.maxSize, err = strconv.Atoi(maxSizeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newStatsdWriter(addr, options)
+}
+
+// createDiscardWriter creates a receiver dropping messages and counting them per level
+// under 'name' ("discard" by default), for GetDiscardCounts:
+//     <discard name="bench" formatid="json"/>
+func createDiscardWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, discardNameAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
 	}
 
-	userPass, ok := node.attributes[userPassId]
+	name, ok := node.attributes[discardNameAttr]
 	if !ok {
-		return nil, newMissingArgumentError(node.name, userPassId)
+		name = discardWriterId
 	}
 
-	smtpWriter := newSmtpWriter(
-		senderAddress,
-		senderName,
-		recipientAddresses,
-		hostName,
-		hostPort,
-		userName,
-		userPass,
-		caCertDirPaths,
-	)
+	return newDiscardWriter(name, currentFormat)
+}
+
+// createRingBufferWriter creates a receiver keeping the last 'size' records (1000 by
+// default) in memory, in the RingBuffer of 'name' ("ringbuffer" by default):
+//     <ringbuffer name="recent" size="500" formatid="debug"/>
+func createRingBufferWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, ringBufferNameAttr, ringBufferSizeAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	name, ok := node.attributes[ringBufferNameAttr]
+	if !ok {
+		name = ringBufferWriterId
+	}
+	size := 0
+	if sizeStr, isSize := node.attributes[ringBufferSizeAttr]; isSize {
+		size, err = strconv.Atoi(sizeStr)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, errors.New(node.errorName() + " attribute '" + ringBufferSizeAttr + "' must be positive")
+		}
+	}
 
-	return newFormattedWriter(smtpWriter, currentFormat)
+	return newRingBufferWriter(name, size, currentFormat)
 }
 
-func createConsoleWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
-	err := checkUnexpectedAttribute(node, outputFormatId)
+// createMqttWriter creates a receiver publishing messages to an MQTT broker. The url is
+// mqtt:// or mqtts:// (TLS), possibly with a user and password; the password may also be
+// given as a secret reference. The topic is a format ("seelog/%Level" by default). With
+// 'cacertdirpath' the broker certificate is verified against the PEM files in the
+// directory instead of the system roots, and TLS is implied:
+//     <mqtt url="mqtts://broker.local" topic="devices/%Field(device)/logs/%Level" qos="1" user="gateway"
+//         password="env://MQTT_PASSWORD"/>
+func createMqttWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, httpUrlAttr, mqttTopicAttr, mqttQosAttr, mqttRetainAttr,
+		mqttClientIdAttr, mqttUserAttr, httpPasswordAttr, mqttTLSAttr, mqttCACertDirAttr, mqttKeepAliveAttr,
+		httpTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	brokerURL, ok := node.attributes[httpUrlAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), httpUrlAttr)
+	}
+	addr, useTLS, user, password, err := parseMqttURL(brokerURL)
 	if err != nil {
 		return nil, err
 	}
+	if userAttr, isUser := node.attributes[mqttUserAttr]; isUser {
+		user = userAttr
+	}
+	if passwordAttr, isPassword := node.attributes[httpPasswordAttr]; isPassword {
+		password, err = resolveSecret(passwordAttr)
+		if err != nil {
+			return nil, errors.New(node.errorName() + " attribute '" + httpPasswordAttr + "': " + err.Error())
+		}
+	}
+
+	options := mqttWriterOptions{
+		topic:    node.attributes[mqttTopicAttr],
+		clientID: node.attributes[mqttClientIdAttr],
+		user:     user,
+		password: password,
+	}
+	if qosStr, isQos := node.attributes[mqttQosAttr]; isQos {
+		qos, err := strconv.ParseUint(qosStr, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		options.qos = byte(qos)
+	}
+	bools := map[string]*bool{mqttRetainAttr: &options.retain, mqttTLSAttr: &useTLS}
+	for attr, value := range bools {
+		if str, isSet := node.attributes[attr]; isSet {
+			*value, err = strconv.ParseBool(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	durations := map[string]*time.Duration{
+		mqttKeepAliveAttr: &options.keepAlive,
+		httpTimeoutAttr:   &options.timeout,
+	}
+	for attr, duration := range durations {
+		if durationStr, isDuration := node.attributes[attr]; isDuration {
+			*duration, err = time.ParseDuration(durationStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
 
+	host, _, _ := net.SplitHostPort(addr)
+	if certDir, isCertDir := node.attributes[mqttCACertDirAttr]; isCertDir {
+		options.tlsConfig, err = getTLSConfig([]string{certDir}, host)
+		if err != nil {
+			return nil, err
+		}
+	} else if useTLS {
+		options.tlsConfig = &tls.Config{ServerName: host}
+	}
+
+	return newMqttWriter(currentFormat, addr, options)
+}
+
+// createZmqWriter creates a receiver sending messages over a ZeroMQ PUB or PUSH socket
+// ('type', push by default), which connects to the tcp endpoint or, with bind, binds to
+// it. Messages are preceded by a frame with the 'topic' format if it is set. Every peer
+// queues up to 'hwm' messages (1000 by default); PUSH waits up to 'timeout' (5s by
+// default) when the queue is full:
+//     <zmq endpoint="tcp://collector.local:5558" type="push" hwm="10000" timeout="1s"/>
+//     <zmq endpoint="tcp://0.0.0.0:5556" type="pub" bind="true" topic="%Level"/>
+func createZmqWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
 	if node.hasChildren() {
 		return nil, nodeCannotHaveChildrenError
 	}
 
+	err := checkUnexpectedAttribute(node, outputFormatId, zmqEndpointAttr, zmqTypeAttr, zmqBindAttr, zmqTopicAttr,
+		zmqHwmAttr, httpTimeoutAttr)
+	if err != nil {
+		return nil, err
+	}
+
 	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
 	if err != nil {
 		return nil, err
 	}
 
-	consoleWriter, err := newConsoleWriter()
+	endpoint, ok := node.attributes[zmqEndpointAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), zmqEndpointAttr)
+	}
+
+	options := zmqWriterOptions{
+		socketType: node.attributes[zmqTypeAttr],
+		topic:      node.attributes[zmqTopicAttr],
+	}
+	if bindStr, isBind := node.attributes[zmqBindAttr]; isBind {
+		options.bind, err = strconv.ParseBool(bindStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hwmStr, isHwm := node.attributes[zmqHwmAttr]; isHwm {
+		options.hwm, err = strconv.Atoi(hwmStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if timeoutStr, isTimeout := node.attributes[httpTimeoutAttr]; isTimeout {
+		options.timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newZmqWriter(currentFormat, endpoint, options)
+}
+
+// createGrpcWriter creates a receiver streaming messages to the LogIngest service of
+// proto/logingest.proto, or to a service with the same messages at 'method'. 'cert' and
+// 'key' set a client certificate for mTLS. The token may be a secret reference:
+//     <grpc addr="ingest.local:443" tls="true" cert="client.pem" key="client.key" token="env://INGEST_TOKEN"
+//         window="5000" timeout="5s" reconnect="2s"/>
+func createGrpcWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, grpcAddrAttr, grpcMethodAttr, grpcTLSAttr, grpcCACertDirAttr,
+		grpcCertAttr, grpcKeyAttr, grpcTokenAttr, grpcWindowAttr, grpcTimeoutAttr, grpcReconnectAttr)
 	if err != nil {
 		return nil, err
 	}
 
-	return newFormattedWriter(consoleWriter, currentFormat)
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := node.attributes[grpcAddrAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), grpcAddrAttr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + grpcAddrAttr + "' attribute value")
+	}
+
+	useTLS := false
+	if tlsStr, isTLS := node.attributes[grpcTLSAttr]; isTLS {
+		useTLS, err = strconv.ParseBool(tlsStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if certDir, isCertDir := node.attributes[grpcCACertDirAttr]; isCertDir {
+		tlsConfig, err = getTLSConfig([]string{certDir}, host)
+		if err != nil {
+			return nil, err
+		}
+	} else if useTLS {
+		tlsConfig = &tls.Config{ServerName: host}
+	}
+
+	certFile, isCert := node.attributes[grpcCertAttr]
+	keyFile, isKey := node.attributes[grpcKeyAttr]
+	if isCert != isKey {
+		return nil, errors.New("Node '" + node.errorName() + "' must have both '" + grpcCertAttr +
+			"' and '" + grpcKeyAttr + "' or neither")
+	}
+	if isCert {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: host}
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	headers := make(http.Header)
+	token, err := resolveSecret(node.attributes[grpcTokenAttr])
+	if err != nil {
+		return nil, errors.New(node.errorName() + " attribute '" + grpcTokenAttr + "': " + err.Error())
+	}
+	if token != "" {
+		headers.Set("Authorization", "Bearer "+token)
+	}
+
+	window := 0
+	if windowStr, isWindow := node.attributes[grpcWindowAttr]; isWindow {
+		window, err = strconv.Atoi(windowStr)
+		if err != nil {
+			return nil, err
+		}
+		if window == 0 {
+			return nil, errors.New("Node '" + node.errorName() + "' must have a positive '" + grpcWindowAttr + "'")
+		}
+	}
+
+	durations := map[string]time.Duration{
+		grpcTimeoutAttr:   defaultGrpcTimeout,
+		grpcReconnectAttr: defaultGrpcReconnect,
+	}
+	for attr := range durations {
+		if str, isSet := node.attributes[attr]; isSet {
+			durations[attr], err = time.ParseDuration(str)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return newGrpcWriter(currentFormat, addr, node.attributes[grpcMethodAttr], tlsConfig, headers, window,
+		durations[grpcTimeoutAttr], durations[grpcReconnectAttr])
 }
 
-func createconnWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+// createMultiplexWriter creates a stdout receiver tagging messages with their stream, in
+// the text framing and on the stdout channel unless the attributes set others:
+//     <multiplex stream="audit" channel="stderr" framing="binary"/>
+func createMultiplexWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
 	if node.hasChildren() {
 		return nil, nodeCannotHaveChildrenError
 	}
 
-	err := checkUnexpectedAttribute(node, outputFormatId, connWriterAddrAttr, connWriterNetAttr, connWriterReconnectOnMsgAttr)
+	err := checkUnexpectedAttribute(node, outputFormatId, multiplexStreamAttr, multiplexChannelAttr, multiplexFramingAttr)
 	if err != nil {
 		return nil, err
 	}
@@ -758,31 +4226,55 @@ func createconnWriter(node *xmlNode, formatFromParent *formatter, formats map[st
 		return nil, err
 	}
 
-	addr, isAddr := node.attributes[connWriterAddrAttr]
-	if !isAddr {
-		return nil, newMissingArgumentError(node.name, connWriterAddrAttr)
+	stream, ok := node.attributes[multiplexStreamAttr]
+	if !ok {
+		return nil, newMissingArgumentError(node.errorName(), multiplexStreamAttr)
 	}
 
-	net, isNet := node.attributes[connWriterNetAttr]
-	if !isNet {
-		return nil, newMissingArgumentError(node.name, connWriterNetAttr)
+	multiplexWriter, err := newMultiplexWriter(stream, node.attributes[multiplexChannelAttr],
+		node.attributes[multiplexFramingAttr])
+	if err != nil {
+		return nil, err
 	}
 
-	reconnectOnMsg := false
-	reconnectOnMsgStr, isReconnectOnMsgStr := node.attributes[connWriterReconnectOnMsgAttr]
-	if isReconnectOnMsgStr {
-		if reconnectOnMsgStr == "true" {
-			reconnectOnMsg = true
-		} else if reconnectOnMsgStr == "false" {
-			reconnectOnMsg = false
-		} else {
-			return nil, errors.New("Node '" + node.name + "' has incorrect '" + connWriterReconnectOnMsgAttr + "' attribute value")
+	return newFormattedWriter(multiplexWriter, currentFormat)
+}
+
+// createEventLogWriter creates a Windows Event Log receiver. The event source is the
+// program name unless 'source' is set; 'install' registers it (once, as administrator):
+//     <eventlog source="Billing" eventid="100" install="true"/>
+func createEventLogWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
+	if node.hasChildren() {
+		return nil, nodeCannotHaveChildrenError
+	}
+
+	err := checkUnexpectedAttribute(node, outputFormatId, eventLogSourceAttr, eventLogEventIdAttr, eventLogInstallAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	currentFormat, err := getCurrentFormat(node, formatFromParent, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	var eventId uint64
+	if eventIdStr, isEventId := node.attributes[eventLogEventIdAttr]; isEventId {
+		eventId, err = strconv.ParseUint(eventIdStr, 10, 16)
+		if err != nil || eventId == 0 {
+			return nil, errors.New("Node '" + node.errorName() + "' has incorrect '" + eventLogEventIdAttr + "' attribute value")
 		}
 	}
 
-	connWriter := newConnWriter(net, addr, reconnectOnMsg)
+	install := false
+	if installStr, isInstall := node.attributes[eventLogInstallAttr]; isInstall {
+		install, err = strconv.ParseBool(installStr)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return newFormattedWriter(connWriter, currentFormat)
+	return newEventLogWriter(currentFormat, node.attributes[eventLogSourceAttr], uint32(eventId), install)
 }
 
 func createRollingFileWriter(node *xmlNode, formatFromParent *formatter, formats map[string]*formatter) (interface{}, error) {
@@ -792,7 +4284,7 @@ func createRollingFileWriter(node *xmlNode, formatFromParent *formatter, formats
 
 	rollingTypeStr, isRollingType := node.attributes[rollingFileTypeAttr]
 	if !isRollingType {
-		return nil, newMissingArgumentError(node.name, rollingFileTypeAttr)
+		return nil, newMissingArgumentError(node.errorName(), rollingFileTypeAttr)
 	}
 
 	rollingType, ok := rollingTypeFromString(rollingTypeStr)
@@ -807,7 +4299,7 @@ func createRollingFileWriter(node *xmlNode, formatFromParent *formatter, formats
 
 	path, isPath := node.attributes[rollingFilePathAttr]
 	if !isPath {
-		return nil, newMissingArgumentError(node.name, rollingFilePathAttr)
+		return nil, newMissingArgumentError(node.errorName(), rollingFilePathAttr)
 	}
 
 	rollingArchiveStr, archiveAttrExists := node.attributes[rollingFileArchiveAttr]
@@ -847,7 +4339,7 @@ func createRollingFileWriter(node *xmlNode, formatFromParent *formatter, formats
 
 		maxSizeStr, isMaxSize := node.attributes[rollingFileMaxSizeAttr]
 		if !isMaxSize {
-			return nil, newMissingArgumentError(node.name, rollingFileMaxSizeAttr)
+			return nil, newMissingArgumentError(node.errorName(), rollingFileMaxSizeAttr)
 		}
 
 		maxSize, err := strconv.ParseInt(maxSizeStr, 10, 64)
@@ -857,7 +4349,7 @@ func createRollingFileWriter(node *xmlNode, formatFromParent *formatter, formats
 
 		maxRollsStr, isMaxRolls := node.attributes[rollingFileMaxRollsAttr]
 		if !isMaxRolls {
-			return nil, newMissingArgumentError(node.name, rollingFileMaxRollsAttr)
+			return nil, newMissingArgumentError(node.errorName(), rollingFileMaxRollsAttr)
 		}
 
 		maxRolls, err := strconv.Atoi(maxRollsStr)
@@ -869,8 +4361,12 @@ func createRollingFileWriter(node *xmlNode, formatFromParent *formatter, formats
 		if err != nil {
 			return nil, err
 		}
+		sharedWriter, err := shareRollingFileWriter(rollingWriter)
+		if err != nil {
+			return nil, err
+		}
 
-		return newFormattedWriter(rollingWriter, currentFormat)
+		return newFormattedWriter(sharedWriter, currentFormat)
 
 	} else if rollingType == rollingTypeDate {
 		err := checkUnexpectedAttribute(node, outputFormatId, rollingFileTypeAttr, rollingFilePathAttr,
@@ -882,15 +4378,19 @@ func createRollingFileWriter(node *xmlNode, formatFromParent *formatter, formats
 
 		dataPattern, isDataPattern := node.attributes[rollingFileDataPatternAttr]
 		if !isDataPattern {
-			return nil, newMissingArgumentError(node.name, rollingFileDataPatternAttr)
+			return nil, newMissingArgumentError(node.errorName(), rollingFileDataPatternAttr)
 		}
 
 		rollingWriter, err := newRollingFileWriterDate(path, rArchiveType, rArchivePath, dataPattern)
 		if err != nil {
 			return nil, err
 		}
+		sharedWriter, err := shareRollingFileWriter(rollingWriter)
+		if err != nil {
+			return nil, err
+		}
 
-		return newFormattedWriter(rollingWriter, currentFormat)
+		return newFormattedWriter(sharedWriter, currentFormat)
 	}
 
 	return nil, errors.New("Incorrect rolling writer type " + rollingTypeStr)
@@ -913,7 +4413,7 @@ func createbufferedWriter(node *xmlNode, formatFromParent *formatter, formats ma
 
 	sizeStr, isSize := node.attributes[bufferedSizeAttr]
 	if !isSize {
-		return nil, newMissingArgumentError(node.name, bufferedSizeAttr)
+		return nil, newMissingArgumentError(node.errorName(), bufferedSizeAttr)
 	}
 
 	size, err := strconv.Atoi(sizeStr)
@@ -965,7 +4465,7 @@ func checkUnexpectedAttribute(node *xmlNode, expectedAttrs ...string) error {
 			}
 		}
 		if !isExpected {
-			return newUnexpectedAttributeError(node.name, attr)
+			return newUnexpectedAttributeError(node.errorName(), attr)
 		}
 	}
 
@@ -1001,10 +4501,10 @@ func checkExpectedElements(node *xmlNode, elements ...expectedElementInfo) error
 		}
 
 		if count == 0 && element.mandatory {
-			return errors.New(node.name + " does not have mandatory subnode - " + element.name)
+			return errors.New(node.errorName() + " does not have mandatory subnode - " + element.name)
 		}
 		if count > 1 && !element.multiple {
-			return errors.New(node.name + " has more then one subnode - " + element.name)
+			return errors.New(node.errorName() + " has more then one subnode - " + element.name)
 		}
 	}
 
@@ -1017,7 +4517,7 @@ func checkExpectedElements(node *xmlNode, elements ...expectedElementInfo) error
 		}
 
 		if !isExpected {
-			return errors.New(node.name + " has unexpected child: " + child.name)
+			return errors.New(node.errorName() + " has unexpected child: " + child.name)
 		}
 	}
 
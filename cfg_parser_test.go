@@ -903,3 +903,65 @@ func TestParser(t *testing.T) {
 		parseTest(test, t)
 	}
 }
+
+func TestPipelineParser(t *testing.T) {
+	config := `
+	<seelog type="sync">
+		<formats>
+			<format id="shared" format="%Msg"/>
+			<format id="overridden" format="%Level"/>
+		</formats>
+		<outputs><console/></outputs>
+		<pipeline name="audit" type="sync" minlevel="info">
+			<outputs formatid="shared"><console/></outputs>
+		</pipeline>
+		<pipeline name="app" type="sync">
+			<formats><format id="overridden" format="%Lev %Msg"/></formats>
+			<outputs formatid="overridden"><console/></outputs>
+		</pipeline>
+	</seelog>`
+
+	consoleWriter, _ := newConsoleWriter()
+
+	expectedAudit := new(logConfig)
+	expectedAudit.Constraints, _ = newMinMaxConstraints(InfoLvl, CriticalLvl)
+	sharedFormat, _ := newFormatter("%Msg")
+	expectedAudit.RootDispatcher, _ = newSplitDispatcher(sharedFormat, []interface{}{consoleWriter})
+	expectedAudit.LogType = syncloggerTypeFromString
+
+	expectedApp := new(logConfig)
+	expectedApp.Constraints, _ = newMinMaxConstraints(TraceLvl, CriticalLvl)
+	overriddenFormat, _ := newFormatter("%Lev %Msg")
+	expectedApp.RootDispatcher, _ = newSplitDispatcher(overriddenFormat, []interface{}{consoleWriter})
+	expectedApp.LogType = syncloggerTypeFromString
+
+	for name, expected := range map[string]*logConfig{"audit": expectedAudit, "app": expectedApp} {
+		conf, err := pipelineConfigFromReader(strings.NewReader(config), name)
+		if err != nil {
+			t.Errorf("Pipeline %s: unexpected error: %s", name, err)
+			continue
+		}
+		if !configsAreEqual(conf, expected) {
+			t.Errorf("Pipeline %s:\n* Expected: %v.\n* Got: %v", name, expected, conf)
+		}
+	}
+
+	if _, err := pipelineConfigFromReader(strings.NewReader(config), "missing"); err == nil {
+		t.Error("Expected error for a missing pipeline")
+	}
+
+	if _, err := configFromReader(strings.NewReader(config)); err != nil {
+		t.Errorf("Root config with pipelines: unexpected error: %s", err)
+	}
+
+	invalidConfigs := []string{
+		`<seelog><pipeline><outputs><console/></outputs></pipeline></seelog>`,
+		`<seelog><pipeline name="a"/><pipeline name="a"/></seelog>`,
+		`<seelog><pipeline name="a"><pipeline name="b"/></pipeline></seelog>`,
+	}
+	for _, invalid := range invalidConfigs {
+		if _, err := pipelineConfigFromReader(strings.NewReader(invalid), "a"); err == nil {
+			t.Errorf("Expected error for config: %s", invalid)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format of DevConfig: colored level, time, caller and fields
+const devConfigFormat = "%LevelColor%Time %LEV%EscM(0) %Msg %Fields %EscM(90)(%RelFile:%Line)%EscM(0)%n"
+
+// Rolling of the files of ProdConfig
+const (
+	prodConfigMaxFileSize = 100 << 20
+	prodConfigMaxRolls    = 10
+)
+
+// DevConfig returns a config preset for local development: a synchronous logger writing
+// debug and higher messages with colored levels, fields and callers to the console.
+// The result is a ConfigBuilder, so every setting can be overridden before Build.
+//
+// Example:
+//     logger, err := log.DevConfig().MinLevel(log.TraceLvl).Build()
+func DevConfig() *ConfigBuilder {
+	return NewConfig().
+		Sync().
+		MinLevel(DebugLvl).
+		Format(devConfigFormat).
+		AddConsole()
+}
+
+// ProdConfig returns a config preset for services in production: an asynchronous logger
+// writing info and higher messages as JSON lines (see %Json) to <dir>/<program name>.log,
+// which is rolled at 100 MB keeping 10 rolled files. Flush the logger before exit.
+// The result is a ConfigBuilder, so every setting can be overridden before Build.
+//
+// Example:
+//     logger, err := log.ProdConfig("/var/log/myservice").AddConsole().OnlyLevels(log.CriticalLvl).Build()
+func ProdConfig(dir string) *ConfigBuilder {
+	return NewConfig().
+		Async().
+		MinLevel(InfoLvl).
+		Format(predefinedPrefix+"json-fields").
+		AddRollingFile(filepath.Join(dir, programName()+".log"), prodConfigMaxFileSize, prodConfigMaxRolls)
+}
+
+// programName returns the name of the executable without extension.
+func programName() string {
+	name := filepath.Base(os.Args[0])
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDevConfig(t *testing.T) {
+	conf, err := DevConfig().config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.LogType != syncloggerTypeFromString {
+		t.Errorf("Expected a sync logger, got %v", conf.LogType)
+	}
+	if conf.Constraints.IsAllowed(TraceLvl) || !conf.Constraints.IsAllowed(DebugLvl) {
+		t.Error("Expected debug as the minimal level")
+	}
+}
+
+func TestProdConfig(t *testing.T) {
+	dir := t.TempDir()
+	builder := ProdConfig(dir)
+
+	conf, err := builder.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.LogType != asyncLooploggerTypeFromString {
+		t.Errorf("Expected an async logger, got %v", conf.LogType)
+	}
+	if conf.Constraints.IsAllowed(DebugLvl) || !conf.Constraints.IsAllowed(InfoLvl) {
+		t.Error("Expected info as the minimal level")
+	}
+
+	file := builder.outputs.children[0]
+	if file.attributes[rollingFilePathAttr] != filepath.Join(dir, programName()+".log") {
+		t.Errorf("Unexpected log file: %s", file.attributes[rollingFilePathAttr])
+	}
+	if builder.outputs.attributes[outputFormatId] != "std:json-fields" {
+		t.Errorf("Unexpected format: %s", builder.outputs.attributes[outputFormatId])
+	}
+}
+
+func TestPresetOverride(t *testing.T) {
+	conf, err := DevConfig().MinLevel(TraceLvl).Async().config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.LogType != asyncLooploggerTypeFromString || !conf.Constraints.IsAllowed(TraceLvl) {
+		t.Error("Preset settings must be overridable")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is the interval of config file checks used by WatchConfigFile
+// when a non-positive interval is given.
+const DefaultWatchInterval = 2 * time.Second
+
+// ReloadConfig creates a logger from the config file and replaces the current logger
+// with it (see ReplaceLogger). The previous logger is flushed before it is closed, so
+// messages queued by an async logger are not lost. Files with the .json extension are
+// read as JSON configs, others as xml. If the config is invalid, the current logger is kept.
+func ReloadConfig(fileName string) error {
+	config, err := unmarshalConfigFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	conf, err := configFromRoot(config)
+	if err != nil {
+		return err
+	}
+
+	logger, err := createLoggerFromConfig(conf)
+	if err != nil {
+		return err
+	}
+
+	return ReplaceLogger(logger)
+}
+
+// WatchConfigFile polls the config file and the files it includes: every interval it
+// checks their modification times and sizes, and reloads the config when the content of
+// one of them changes (see ReloadConfig). Filesystem notifications are not used, so a
+// change is noticed within an interval. If the changed config is invalid, a warning is
+// written to the current logger, which is kept until the file is fixed. The included
+// files are found again after every reload. Call the returned func to stop watching.
+//
+// Example:
+//     stop, err := seelog.WatchConfigFile("seelog.xml", 0)
+//     ...
+//     defer stop()
+func WatchConfigFile(fileName string, interval time.Duration) (stop func(), err error) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	files, err := watchedConfigFiles(fileName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stopChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				files = checkWatchedConfig(fileName, files)
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+
+	var stopOnce sync.Once
+	stop = func() {
+		stopOnce.Do(func() {
+			close(stopChan)
+			<-done
+		})
+	}
+	return stop, nil
+}
+
+// watchedFile is the last seen state of a file watched by WatchConfigFile.
+type watchedFile struct {
+	info    os.FileInfo
+	content []byte
+}
+
+func readWatchedFile(fileName string) (*watchedFile, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &watchedFile{info, content}, nil
+}
+
+// watchedConfigFiles returns the states of the config file and of the files it includes,
+// taking the known states from files. The includes of a config which can not be parsed
+// are not found, so only the config file is watched until it is fixed.
+func watchedConfigFiles(fileName string, files map[string]*watchedFile) (map[string]*watchedFile, error) {
+	names := []string{fileName}
+	config, err := unmarshalConfigFile(fileName)
+	if err == nil && resolveConditions(config) == nil && resolveIncludes(config) == nil {
+		names = append(names, config.includes...)
+	}
+
+	watched := make(map[string]*watchedFile, len(names))
+	for _, name := range names {
+		if file, ok := files[name]; ok {
+			watched[name] = file
+			continue
+		}
+		file, err := readWatchedFile(name)
+		if err != nil {
+			if name == fileName {
+				return nil, err
+			}
+			continue
+		}
+		watched[name] = file
+	}
+	return watched, nil
+}
+
+// checkWatchedConfig reloads the config if the content of a watched file differs from the
+// last seen one and returns the new states of the watched files. The content of a file is
+// read only if its modification time or size changed.
+func checkWatchedConfig(fileName string, files map[string]*watchedFile) map[string]*watchedFile {
+	changed := false
+	for name, file := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			Warnf("Cannot check config '%s': %s", name, err)
+			continue
+		}
+		if info.ModTime().Equal(file.info.ModTime()) && info.Size() == file.info.Size() {
+			continue
+		}
+
+		content, err := os.ReadFile(name)
+		if err != nil {
+			Warnf("Cannot read config '%s': %s", name, err)
+			continue
+		}
+		if !bytes.Equal(content, file.content) {
+			changed = true
+		}
+		files[name] = &watchedFile{info, content}
+	}
+	if !changed {
+		return files
+	}
+
+	err := ReloadConfig(fileName)
+	if err != nil {
+		Warnf("Config '%s' is not reloaded: %s", fileName, err)
+	}
+
+	watched, err := watchedConfigFiles(fileName, files)
+	if err != nil {
+		Warnf("Cannot read config '%s': %s", fileName, err)
+		return files
+	}
+	return watched
+}
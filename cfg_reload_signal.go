@@ -0,0 +1,103 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+//go:build !js
+// +build !js
+
+package seelog
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	signalReloadMutex sync.Mutex
+	signalReloadStop  chan struct{}
+	signalReloadDone  chan struct{}
+)
+
+// EnableSignalReload makes the process reload the config file on SIGHUP (see ReloadConfig).
+// Reload errors are reported as internal seelog errors and the current logger is kept.
+// Signal reload is stopped with DisableSignalReload.
+//
+// Example:
+//     logger, err := seelog.LoggerFromConfigAsFile("seelog.xml")
+//     ...
+//     seelog.ReplaceLogger(logger)
+//     seelog.EnableSignalReload("seelog.xml")
+// and then
+//     kill -HUP <pid>
+func EnableSignalReload(fileName string) error {
+	signalReloadMutex.Lock()
+	defer signalReloadMutex.Unlock()
+
+	if signalReloadStop != nil {
+		return errors.New("Signal reload is already enabled")
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	signalReloadStop, signalReloadDone = stop, done
+
+	go func() {
+		defer close(done)
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-signals:
+				err := ReloadConfig(fileName)
+				if err != nil {
+					reportInternalError(errors.New("Cannot reload config '" + fileName + "': " + err.Error()))
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// DisableSignalReload stops reloading the config on SIGHUP. A reload which is in
+// progress completes before DisableSignalReload returns.
+func DisableSignalReload() {
+	signalReloadMutex.Lock()
+	defer signalReloadMutex.Unlock()
+
+	if signalReloadStop == nil {
+		return
+	}
+
+	close(signalReloadStop)
+	<-signalReloadDone
+	signalReloadStop, signalReloadDone = nil, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+//go:build !js
+// +build !js
+
+package seelog
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalReload(t *testing.T) {
+	defer ReplaceLogger(Default)
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "seelog.xml")
+	if err := os.WriteFile(configFile, []byte(`<seelog type="sync"><outputs><console/></outputs></seelog>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnableSignalReload(configFile); err != nil {
+		t.Fatal(err)
+	}
+	defer DisableSignalReload()
+	if err := EnableSignalReload(configFile); err == nil {
+		t.Error("Expected error for a second EnableSignalReload")
+	}
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := CurrentLogger()
+	if err = process.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("Cannot send SIGHUP: %s", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for CurrentLogger() == previous {
+		if time.Now().After(deadline) {
+			t.Fatal("Logger was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	defer ReplaceLogger(Default)
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "seelog.xml")
+
+	if err := os.WriteFile(configFile, []byte(`<seelog type="sync"><outputs><console/></outputs></seelog>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+	first := CurrentLogger()
+
+	if err := os.WriteFile(configFile, []byte(`<seelog type="unknown"/>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfig(configFile); err == nil {
+		t.Error("Expected error for an invalid config")
+	}
+	if CurrentLogger() != first {
+		t.Error("Invalid config must keep the current logger")
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	defer ReplaceLogger(Default)
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "seelog.xml")
+	if err := os.WriteFile(configFile, []byte(`<seelog type="sync"/>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := WatchConfigFile(configFile, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	waitForLogger := func(previous LoggerInterface) LoggerInterface {
+		deadline := time.Now().Add(5 * time.Second)
+		for CurrentLogger() == previous {
+			if time.Now().After(deadline) {
+				t.Fatal("Logger was not reloaded")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return CurrentLogger()
+	}
+
+	previous := CurrentLogger()
+	if err = os.WriteFile(configFile, []byte(`<seelog type="sync" minlevel="info"/>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := waitForLogger(previous)
+
+	if err = os.WriteFile(configFile, []byte(`<seelog type="broken" minlevel="info"/>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if CurrentLogger() != reloaded {
+		t.Error("Invalid config must keep the current logger")
+	}
+
+	if err = os.WriteFile(configFile, []byte(`<seelog type="sync" minlevel="warn"/>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	waitForLogger(reloaded)
+
+	if _, err = WatchConfigFile(filepath.Join(dir, "missing.xml"), 0); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
+
+func TestWatchConfigFileIncludes(t *testing.T) {
+	defer ReplaceLogger(Default)
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "seelog.xml")
+	includedFile := filepath.Join(dir, "outputs.xml")
+	if err := os.WriteFile(includedFile, []byte(`<seelog><outputs><console/></outputs></seelog>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configFile, []byte(`<seelog type="sync"><include file="outputs.xml"/></seelog>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := WatchConfigFile(configFile, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	previous := CurrentLogger()
+	if err = os.WriteFile(includedFile, []byte(`<seelog minlevel="warn"><outputs><console/></outputs></seelog>`), defaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for CurrentLogger() == previous {
+		if time.Now().After(deadline) {
+			t.Fatal("Logger was not reloaded after a change of an included file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// renderedWriterIds are the names of receivers which write to an external destination.
+var renderedWriterIds = map[string]bool{
+	fileWriterId:        true,
+	rollingfileWriterId: true,
+	consoleWriterId:     true,
+	connWriterId:        true,
+	smtpWriterId:        true,
+}
+
+// RenderConfig passes the records through the root logger of the config as if they were
+// logged and returns what every writer would write, keyed by the element path of the
+// writer, e.g. "seelog/outputs/filter/console". Writers which receive no records have
+// empty output. The config is xml, or JSON if it starts with '{'.
+//
+// Nothing is written to the real destinations (files, console, network) and the records
+// are processed synchronously whatever the logger type is. Constraints, exceptions,
+// filters and formats apply as usual; date and time verbs format the record time.
+func RenderConfig(config []byte, records []Record) (map[string][]byte, error) {
+	root, err := unmarshalConfigData(config)
+	if err != nil {
+		return nil, err
+	}
+	err = prepareRootConfig(root)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "seelog-render")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	outputs := redirectWriters(root, dir)
+
+	logConfig, err := configFromNode(root, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var dispatchErr error
+	for _, record := range records {
+		_, fileName := filepath.Split(record.File)
+		context := &logContext{record.Func, record.Line, record.File, record.File, fileName, record.Time, record.Fields, record.Stack, 0}
+		if !logConfig.IsAllowed(record.Level, context) {
+			continue
+		}
+		logConfig.RootDispatcher.Dispatch(record.Message, record.Level, context, func(err error) {
+			if dispatchErr == nil {
+				dispatchErr = err
+			}
+		})
+	}
+	logConfig.RootDispatcher.Close()
+	if dispatchErr != nil {
+		return nil, dispatchErr
+	}
+
+	result := make(map[string][]byte, len(outputs))
+	for path, fileName := range outputs {
+		data, err := os.ReadFile(fileName)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		result[path] = bytes.Clone(data)
+	}
+	return result, nil
+}
+
+// redirectWriters replaces every writer of the root logger with a file writer in dir and
+// returns the file names by the element paths of the replaced writers.
+func redirectWriters(root *xmlNode, dir string) map[string]string {
+	var writers []lintNode
+	walkLint(lintNode{root, root.name}, true, func(n lintNode) {
+		if renderedWriterIds[n.node.name] {
+			writers = append(writers, n)
+		}
+	})
+
+	outputs := make(map[string]string, len(writers))
+	for i, writer := range writers {
+		fileName := filepath.Join(dir, strconv.Itoa(i)+".out")
+		outputs[writer.path] = fileName
+
+		attributes := map[string]string{pathId: fileName}
+		if id, ok := writer.node.attributes[outputFormatId]; ok {
+			attributes[outputFormatId] = id
+		}
+		writer.node.name = fileWriterId
+		writer.node.attributes = attributes
+		writer.node.children = nil
+	}
+	return outputs
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderConfig(t *testing.T) {
+	config := `<seelog type="asynctimer" asyncinterval="1000000">
+	<exceptions>
+		<exception funcpattern="*noisy*" minlevel="error"/>
+	</exceptions>
+	<outputs formatid="main">
+		<buffered size="1000"><file path="never-created.log"/></buffered>
+		<filter levels="error"><console formatid="std:xml"/></filter>
+	</outputs>
+	<formats>
+		<format id="main" format="%Time %Func:%Line %Msg%n"/>
+	</formats>
+</seelog>`
+
+	at := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+	records := []Record{
+		{Level: InfoLvl, Message: "hello", Time: at, Func: "main.main", File: "main.go", Line: 3},
+		{Level: InfoLvl, Message: "dropped", Time: at, Func: "main.noisy", File: "main.go", Line: 4},
+	}
+
+	outputs, err := RenderConfig([]byte(config), records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"seelog/outputs/buffered/file":  "07:08:09 main.main:3 hello\n",
+		"seelog/outputs/filter/console": "",
+	}
+	if len(outputs) != len(expected) {
+		t.Fatalf("Expected outputs %v, got %v", expected, outputs)
+	}
+	for path, output := range expected {
+		if string(outputs[path]) != output {
+			t.Errorf("Output of %s: expected %q, got %q", path, output, outputs[path])
+		}
+	}
+	if exists, _ := fileExists("never-created.log"); exists {
+		t.Error("Render must not write to the real destinations")
+	}
+}
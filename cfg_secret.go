@@ -0,0 +1,144 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Schemes of the built-in secret providers
+const (
+	EnvSecretScheme  = "env"
+	FileSecretScheme = "file"
+)
+
+// A SecretProvider resolves the secret references of config attributes. Sensitive
+// attributes, such as the smtp password, may hold a reference "scheme://name" instead
+// of the secret itself; the provider registered for the scheme gets the name. Built-in
+// schemes:
+//     env://NAME    value of the environment variable NAME
+//     file://PATH   content of the file at PATH without the trailing line break
+// Descriptions of configs (see DescribeConfig) show references as they are, since they
+// contain no secrets. A provider must be safe for concurrent use.
+//
+// Example:
+//     <smtp ... username="alerts" password="secret://smtp">
+type SecretProvider interface {
+	Secret(name string) (string, error)
+}
+
+// SecretProviderFunc adapts a func to the SecretProvider interface.
+type SecretProviderFunc func(name string) (string, error)
+
+// Secret calls f.
+func (f SecretProviderFunc) Secret(name string) (string, error) {
+	return f(name)
+}
+
+var (
+	secretProvidersMutex sync.RWMutex
+	secretProviders      = map[string]SecretProvider{
+		EnvSecretScheme:  SecretProviderFunc(envSecret),
+		FileSecretScheme: SecretProviderFunc(fileSecret),
+	}
+)
+
+// RegisterSecretProvider makes a secret provider resolve the references with the given
+// scheme. A provider registered for an existing scheme replaces the previous one.
+//
+// Example:
+//     seelog.RegisterSecretProvider("secret", seelog.SecretProviderFunc(func(name string) (string, error) {
+//         return vault.Read("logging/" + name)
+//     }))
+func RegisterSecretProvider(scheme string, provider SecretProvider) error {
+	if scheme == "" || strings.Contains(scheme, "://") {
+		return errors.New("Invalid secret scheme: " + scheme)
+	}
+	if provider == nil {
+		return errors.New("Secret provider cannot be nil")
+	}
+
+	secretProvidersMutex.Lock()
+	defer secretProvidersMutex.Unlock()
+	secretProviders[scheme] = provider
+	return nil
+}
+
+// isSecretReference returns true if the value has the "scheme://name" form.
+func isSecretReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	return ok && scheme != "" && !strings.ContainsAny(scheme, " /:")
+}
+
+// resolveSecret returns the secret a value refers to, or the value itself if it is not
+// a reference. Upstream seelog has no references, so in the upstream compatibility mode
+// values are always used as they are.
+func resolveSecret(value string) (string, error) {
+	if !isSecretReference(value) || UpstreamCompatible() {
+		return value, nil
+	}
+
+	scheme, name, _ := strings.Cut(value, "://")
+	secretProvidersMutex.RLock()
+	provider, ok := secretProviders[scheme]
+	secretProvidersMutex.RUnlock()
+	if !ok {
+		return "", errors.New("No secret provider for '" + scheme + "://' references")
+	}
+
+	secret, err := provider.Secret(name)
+	if err != nil {
+		return "", errors.New("Cannot resolve secret '" + value + "': " + err.Error())
+	}
+	return secret, nil
+}
+
+// resolveSecretURL is resolveSecret for values which are http urls themselves, such as
+// webhook urls: these are used as they are, and anything else must be a reference.
+func resolveSecretURL(value string) (string, error) {
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		return value, nil
+	}
+	return resolveSecret(value)
+}
+
+func envSecret(name string) (string, error) {
+	value, ok := lookupEnv(name)
+	if !ok {
+		return "", errors.New("Environment variable " + name + " is not set")
+	}
+	return value, nil
+}
+
+func fileSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
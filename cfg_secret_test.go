@@ -0,0 +1,110 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	withTestEnv(t, map[string]string{"SMTP_PASSWORD": "from-env"})
+
+	fileName := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(fileName, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := RegisterSecretProvider("testvault", SecretProviderFunc(func(name string) (string, error) {
+		if name != "smtp" {
+			return "", errors.New("not found")
+		}
+		return "from-vault", nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value         string
+		expected      string
+		errorExpected bool
+	}{
+		{"plain", "plain", false},
+		{"env://SMTP_PASSWORD", "from-env", false},
+		{"file://" + fileName, "from-file", false},
+		{"testvault://smtp", "from-vault", false},
+		{"p@ss word://x", "p@ss word://x", false},
+		{"env://MISSING", "", true},
+		{"file://" + fileName + ".missing", "", true},
+		{"testvault://other", "", true},
+		{"unknown://smtp", "", true},
+	}
+	for _, test := range tests {
+		secret, err := resolveSecret(test.value)
+		if (err != nil) != test.errorExpected {
+			t.Errorf("Value: %s\n* Expected error: %t. Got error: %v", test.value, test.errorExpected, err)
+			continue
+		}
+		if err == nil && secret != test.expected {
+			t.Errorf("Value: %s\n* Expected: %s. Got: %s", test.value, test.expected, secret)
+		}
+	}
+
+	if err := RegisterSecretProvider("", SecretProviderFunc(envSecret)); err == nil {
+		t.Error("Empty scheme must be rejected")
+	}
+	if err := RegisterSecretProvider("x", nil); err == nil {
+		t.Error("Nil provider must be rejected")
+	}
+}
+
+func TestDescribeSecrets(t *testing.T) {
+	withTestEnv(t, map[string]string{"SMTP_PASSWORD": "from-env"})
+
+	config := func(password string) []byte {
+		return []byte(`<seelog><outputs>
+			<smtp senderaddress="a@b.c" sendername="a" hostname="h" hostport="25" username="u" password="` + password + `">
+				<recipient address="d@e.f"/>
+			</smtp>
+		</outputs></seelog>`)
+	}
+
+	for password, shown := range map[string]string{"plain": "******", "env://SMTP_PASSWORD": "env://SMTP_PASSWORD"} {
+		description, err := DescribeConfig(config(password))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := description.Outputs[0].Attributes["password"]; got != shown {
+			t.Errorf("Password %s must be shown as %s, got %s", password, shown, got)
+		}
+	}
+
+	if _, err := DescribeConfig(config("env://MISSING")); err == nil {
+		t.Error("Config with an unresolvable secret must be rejected")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// configFetchTimeout limits the time of one config request.
+const configFetchTimeout = 30 * time.Second
+
+// urlConfigFetcher fetches a config over HTTP(S), using conditional requests so
+// that an unchanged config is not transferred again.
+type urlConfigFetcher struct {
+	url          string
+	client       *http.Client
+	etag         string
+	lastModified string
+	content      []byte
+}
+
+// fetch returns the root node of the config, or nil if the config has not changed
+// since the previous fetch.
+func (fetcher *urlConfigFetcher) fetch() (*xmlNode, error) {
+	request, err := http.NewRequest("GET", fetcher.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if fetcher.etag != "" {
+		request.Header.Set("If-None-Match", fetcher.etag)
+	}
+	if fetcher.lastModified != "" {
+		request.Header.Set("If-Modified-Since", fetcher.lastModified)
+	}
+
+	response, err := fetcher.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("Unexpected response status: " + response.Status)
+	}
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	fetcher.etag = response.Header.Get("ETag")
+	fetcher.lastModified = response.Header.Get("Last-Modified")
+	if fetcher.content != nil && bytes.Equal(content, fetcher.content) {
+		return nil, nil
+	}
+
+	var config *xmlNode
+	if isJsonConfigResponse(fetcher.url, response) {
+		config, err = unmarshalJsonConfig(bytes.NewReader(content))
+	} else {
+		config, err = unmarshalConfig(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher.content = content
+	return config, nil
+}
+
+// isJsonConfigResponse returns true if the response has a JSON media type or,
+// lacking a specific one, if the url path has the .json extension.
+func isJsonConfigResponse(rawUrl string, response *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err == nil {
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			return true
+		}
+		if strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml") {
+			return false
+		}
+	}
+
+	parsed, err := url.Parse(rawUrl)
+	return err == nil && strings.EqualFold(path.Ext(parsed.Path), ".json")
+}
+
+func (fetcher *urlConfigFetcher) logger() (LoggerInterface, error) {
+	config, err := fetcher.fetch()
+	if err != nil || config == nil {
+		return nil, err
+	}
+
+	conf, err := configFromRoot(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return createLoggerFromConfig(conf)
+}
+
+// LoggerFromConfigAsURL creates a logger with config fetched from an HTTP(S) url. The
+// response should contain a valid seelog xml, or a JSON config if it has a JSON media
+// type or the url path ends with .json.
+//
+// If refreshInterval is positive, the config is fetched again every refreshInterval with
+// If-None-Match/If-Modified-Since headers, and the returned logger switches to a new
+// underlying logger whenever the config changes. Invalid configs and failed requests are
+// reported as internal seelog errors and the current config stays in use. Closing the
+// returned logger stops the refresh.
+//
+// Example:
+//     logger, err := seelog.LoggerFromConfigAsURL("https://config.example.com/seelog.xml", time.Minute)
+func LoggerFromConfigAsURL(configUrl string, refreshInterval time.Duration) (LoggerInterface, error) {
+	fetcher := &urlConfigFetcher{url: configUrl, client: &http.Client{Timeout: configFetchTimeout}}
+
+	initial, err := fetcher.logger()
+	if err != nil {
+		return nil, err
+	}
+	if initial == nil {
+		return nil, errors.New("Config was not received from " + configUrl)
+	}
+	if refreshInterval <= 0 {
+		return initial, nil
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	logger := newSwapLogger(initial, func() {
+		close(stop)
+		<-done
+	})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				refreshed, err := fetcher.logger()
+				if err != nil {
+					reportInternalError(errors.New("Cannot refresh config from " + configUrl + ": " + err.Error()))
+				} else if refreshed != nil {
+					logger.swap(refreshed)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return logger, nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type configServer struct {
+	mutex       sync.Mutex
+	config      string
+	version     int
+	notModified int
+}
+
+func (server *configServer) set(config string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.config = config
+	server.version++
+}
+
+func (server *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	etag := `"` + strconv.Itoa(server.version) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		server.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(server.config))
+}
+
+func TestLoggerFromConfigAsURL(t *testing.T) {
+	configs := &configServer{}
+	configs.set(`<seelog type="sync" minlevel="info"/>`)
+	server := httptest.NewServer(configs)
+	defer server.Close()
+
+	logger, err := LoggerFromConfigAsURL(server.URL+"/seelog", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	swap, ok := logger.(*swapLogger)
+	if !ok {
+		t.Fatalf("Expected a swapping logger, got %T", logger)
+	}
+	initial := swap.current()
+
+	time.Sleep(50 * time.Millisecond)
+	if swap.current() != initial {
+		t.Error("Unchanged config must not replace the logger")
+	}
+	configs.mutex.Lock()
+	notModified := configs.notModified
+	configs.mutex.Unlock()
+	if notModified == 0 {
+		t.Error("Expected conditional requests")
+	}
+
+	configs.set(`<seelog type="broken"/>`)
+	time.Sleep(50 * time.Millisecond)
+	if swap.current() != initial {
+		t.Error("Invalid config must keep the current logger")
+	}
+
+	configs.set(`<seelog type="sync" minlevel="error"/>`)
+	deadline := time.Now().Add(5 * time.Second)
+	for swap.current() == initial {
+		if time.Now().After(deadline) {
+			t.Fatal("Logger was not replaced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLoggerFromConfigAsURLErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<seelog type="broken"/>`))
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/missing", "/broken"} {
+		if _, err := LoggerFromConfigAsURL(server.URL+path, 0); err == nil {
+			t.Errorf("%s: expected error", path)
+		}
+	}
+}
+
+func TestIsJsonConfigResponse(t *testing.T) {
+	tests := []struct {
+		url         string
+		contentType string
+		expected    bool
+	}{
+		{"http://host/seelog.json", "", true},
+		{"http://host/seelog.json", "text/xml", false},
+		{"http://host/seelog", "application/json; charset=utf-8", true},
+		{"http://host/seelog.xml", "text/plain", false},
+	}
+
+	for _, test := range tests {
+		response := &http.Response{Header: http.Header{}}
+		response.Header.Set("Content-Type", test.contentType)
+		if isJsonConfigResponse(test.url, response) != test.expected {
+			t.Errorf("%s (%s): expected %t", test.url, test.contentType, test.expected)
+		}
+	}
+}
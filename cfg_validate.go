@@ -0,0 +1,1168 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validation rule ids
+const (
+	validateSyntaxRule           = "syntax"
+	validateUnknownElementRule   = "unknown-element"
+	validateUnknownAttributeRule = "unknown-attribute"
+	validateMissingAttributeRule = "missing-attribute"
+	validateBadLevelRule         = "bad-level"
+	validateBadValueRule         = "bad-value"
+	validateMissingFormatRule    = "missing-format"
+)
+
+// attributeSpec tells how the value of a config attribute is checked.
+type attributeSpec struct {
+	rule  string      // Rule of the issues reported for bad values
+	check interface{} // func(value string) error, or func(value string, levels levelSet) error for values with levels
+}
+
+// validationElement describes the allowed content of a config element.
+type validationElement struct {
+	attributes map[string]attributeSpec
+	required   []string
+	children   []string
+	receivers  bool // Receivers (see elementMap) are allowed as children
+}
+
+var (
+	anyAttr      = attributeSpec{}
+	levelAttr    = attributeSpec{validateBadLevelRule, checkLevelValue}
+	levelsAttr   = attributeSpec{validateBadLevelRule, checkLevelsValue}
+	uintAttr     = attributeSpec{validateBadValueRule, checkUintValue}
+	floatAttr    = attributeSpec{validateBadValueRule, checkFloatValue}
+	durationAttr = attributeSpec{validateBadValueRule, checkDurationValue}
+	boolAttr     = attributeSpec{validateBadValueRule, checkBoolValue}
+)
+
+var validationElements map[string]validationElement
+
+func init() {
+	loggerAttributes := map[string]attributeSpec{
+		minLevelId:                      levelAttr,
+		maxLevelId:                      levelAttr,
+		levelsId:                        levelsAttr,
+		loggerTypeFromStringAttr:        {validateBadValueRule, checkLoggerTypeValue},
+		asyncLoggerIntervalAttr:         uintAttr,
+		adaptLoggerMinIntervalAttr:      uintAttr,
+		adaptLoggerMaxIntervalAttr:      uintAttr,
+		adaptLoggerCriticalMsgCountAttr: uintAttr,
+		maxQueueAgeAttr:                 durationAttr,
+		maxQueueAgeLevelAttr:            levelAttr,
+		stackDepthAttr:                  uintAttr,
+		stackLevelAttr:                  levelAttr,
+		overloadQueueAttr:               uintAttr,
+	}
+	pipelineAttributes := map[string]attributeSpec{pipelineNameAttr: anyAttr}
+	for name, spec := range loggerAttributes {
+		pipelineAttributes[name] = spec
+	}
+
+	samplerAttributes := map[string]attributeSpec{
+		outputFormatId:   anyAttr,
+		samplerRateAttr:  floatAttr,
+		samplerLimitAttr: floatAttr,
+		samplerBurstAttr: uintAttr,
+	}
+	for level := LogLevel(TraceLvl); level <= CriticalLvl; level++ {
+		samplerAttributes[level.String()] = floatAttr
+	}
+
+	sloRuleAttributes := map[string]attributeSpec{
+		sloRuleLevelsAttr: levelsAttr,
+		sloRuleMsgAttr:    anyAttr,
+		sloRuleFieldAttr:  anyAttr,
+		sloRuleOpAttr:     anyAttr,
+		sloRuleValueAttr:  anyAttr,
+	}
+
+	validationElements = map[string]validationElement{
+		seelogConfigId: {
+			attributes: loggerAttributes,
+			children:   []string{outputsId, formatsId, exceptionsId, pipelineId, customLevelsId},
+		},
+		pipelineId: {
+			attributes: pipelineAttributes,
+			required:   []string{pipelineNameAttr},
+			children:   []string{outputsId, formatsId, exceptionsId},
+		},
+		outputsId:    {attributes: map[string]attributeSpec{outputFormatId: anyAttr}, receivers: true},
+		formatsId:    {children: []string{formatId}},
+		exceptionsId: {children: []string{exceptionId}},
+		formatId: {
+			attributes: map[string]attributeSpec{formatKeyAttrId: anyAttr, formatAttrId: anyAttr, formatLocaleAttr: anyAttr},
+			required:   []string{formatKeyAttrId, formatAttrId},
+		},
+		exceptionId: {
+			attributes: map[string]attributeSpec{
+				minLevelId:       levelAttr,
+				maxLevelId:       levelAttr,
+				levelsId:         levelsAttr,
+				funcPatternId:    anyAttr,
+				filePatternId:    anyAttr,
+				packagePatternId: anyAttr,
+			},
+		},
+		customLevelsId: {children: []string{customLevelId}},
+		customLevelId: {
+			attributes: map[string]attributeSpec{customLevelNameAttr: anyAttr, customLevelAboveAttr: levelAttr},
+			required:   []string{customLevelNameAttr, customLevelAboveAttr},
+		},
+		whenId: {attributes: map[string]attributeSpec{whenEnvAttr: anyAttr, whenHostnameAttr: anyAttr}},
+		includeId: {
+			attributes: map[string]attributeSpec{includeFileAttr: anyAttr},
+			required:   []string{includeFileAttr},
+		},
+
+		fileWriterId: {
+			attributes: map[string]attributeSpec{outputFormatId: anyAttr, pathId: anyAttr},
+			required:   []string{pathId},
+		},
+		consoleWriterId: {attributes: map[string]attributeSpec{outputFormatId: anyAttr}},
+		rollingfileWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:             anyAttr,
+				rollingFileTypeAttr:        {validateBadValueRule, checkRollingTypeValue},
+				rollingFilePathAttr:        anyAttr,
+				rollingFileMaxSizeAttr:     uintAttr,
+				rollingFileMaxRollsAttr:    uintAttr,
+				rollingFileDataPatternAttr: anyAttr,
+				rollingFileArchiveAttr:     {validateBadValueRule, checkRollingArchiveTypeValue},
+				rollingFileArchivePathAttr: anyAttr,
+			},
+			required: []string{rollingFileTypeAttr, rollingFilePathAttr},
+		},
+		connWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:               anyAttr,
+				connWriterAddrAttr:           anyAttr,
+				connWriterNetAttr:            anyAttr,
+				connWriterReconnectOnMsgAttr: boolAttr,
+				connWriterTLSAttr:            boolAttr,
+				connWriterCACertAttr:         anyAttr,
+				connWriterCertAttr:           anyAttr,
+				connWriterKeyAttr:            anyAttr,
+				connWriterServerNameAttr:     anyAttr,
+				connWriterReconnectAttr:      durationAttr,
+				connWriterMaxReconnectAttr:   durationAttr,
+				connWriterTimeoutAttr:        durationAttr,
+				connWriterBufferSizeAttr:     uintAttr,
+				connWriterBufferPathAttr:     anyAttr,
+				connWriterBufferFileSizeAttr: uintAttr,
+				connWriterWriteTimeoutAttr:   durationAttr,
+				connWriterOverflowAttr:       {validateBadValueRule, checkConnOverflowValue},
+			},
+			required: []string{connWriterAddrAttr, connWriterNetAttr},
+		},
+		syslogWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:       anyAttr,
+				syslogNetAttr:        {validateBadValueRule, checkSyslogNetValue},
+				syslogAddrAttr:       anyAttr,
+				syslogRFCAttr:        {validateBadValueRule, checkSyslogRFCValue},
+				syslogFacilityAttr:   {validateBadValueRule, checkSyslogFacilityValue},
+				syslogTagAttr:        anyAttr,
+				syslogSeveritiesAttr: {validateBadValueRule, checkSyslogSeveritiesValue},
+			},
+		},
+		fluentWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:      anyAttr,
+				fluentAddrAttr:      anyAttr,
+				fluentTagAttr:       anyAttr,
+				fluentAckAttr:       boolAttr,
+				fluentTLSAttr:       boolAttr,
+				fluentCACertDirAttr: anyAttr,
+				fluentTimeoutAttr:   durationAttr,
+			},
+			required: []string{fluentAddrAttr},
+		},
+		elasticsearchWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:               anyAttr,
+				elasticsearchEndpointAttr:    anyAttr,
+				elasticsearchIndexAttr:       anyAttr,
+				elasticsearchUsernameAttr:    anyAttr,
+				elasticsearchPasswordAttr:    anyAttr,
+				elasticsearchApiKeyAttr:      anyAttr,
+				elasticsearchMaxCountAttr:    uintAttr,
+				elasticsearchMaxSizeAttr:     uintAttr,
+				elasticsearchMaxIntervalAttr: durationAttr,
+				elasticsearchRetriesAttr:     uintAttr,
+				elasticsearchBackoffAttr:     durationAttr,
+				elasticsearchCompressionAttr: {validateBadValueRule, checkHttpCompressionValue},
+			},
+			required: []string{elasticsearchEndpointAttr},
+		},
+		gelfWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:    anyAttr,
+				gelfNetAttr:       {validateBadValueRule, checkGelfNetValue},
+				gelfAddrAttr:      anyAttr,
+				gelfHostAttr:      anyAttr,
+				gelfCompressAttr:  {validateBadValueRule, checkGelfCompressValue},
+				gelfChunkSizeAttr: uintAttr,
+				gelfTimeoutAttr:   durationAttr,
+			},
+			required: []string{gelfAddrAttr},
+		},
+		logstashWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:         anyAttr,
+				logstashAddrAttr:       anyAttr,
+				logstashTLSAttr:        boolAttr,
+				logstashCACertDirAttr:  anyAttr,
+				logstashTimeoutAttr:    durationAttr,
+				logstashKeepAliveAttr:  durationAttr,
+				logstashBufferSizeAttr: uintAttr,
+				logstashReconnectAttr:  durationAttr,
+			},
+			required: []string{logstashAddrAttr},
+		},
+		redisWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:    anyAttr,
+				redisAddrAttr:     anyAttr,
+				redisModeAttr:     {validateBadValueRule, checkRedisModeValue},
+				redisKeyAttr:      anyAttr,
+				redisPasswordAttr: anyAttr,
+				redisDbAttr:       uintAttr,
+				redisPoolSizeAttr: uintAttr,
+				redisTimeoutAttr:  durationAttr,
+			},
+			required: []string{redisAddrAttr, redisKeyAttr},
+		},
+		websocketWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:      anyAttr,
+				websocketAddrAttr:   anyAttr,
+				websocketPathAttr:   anyAttr,
+				websocketBufferAttr: uintAttr,
+			},
+			required: []string{websocketAddrAttr},
+		},
+		udpWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId: anyAttr,
+				udpAddrAttr:    anyAttr,
+				udpMaxSizeAttr: uintAttr,
+				udpLoadAttr:    uintAttr,
+				udpSampleAttr:  floatAttr,
+			},
+			required: []string{udpAddrAttr},
+		},
+		grpcWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:    anyAttr,
+				grpcAddrAttr:      anyAttr,
+				grpcMethodAttr:    anyAttr,
+				grpcTLSAttr:       boolAttr,
+				grpcCACertDirAttr: anyAttr,
+				grpcCertAttr:      anyAttr,
+				grpcKeyAttr:       anyAttr,
+				grpcTokenAttr:     anyAttr,
+				grpcWindowAttr:    uintAttr,
+				grpcTimeoutAttr:   durationAttr,
+				grpcReconnectAttr: durationAttr,
+			},
+			required: []string{grpcAddrAttr},
+		},
+		httpWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:      anyAttr,
+				httpUrlAttr:         anyAttr,
+				httpBatchAttr:       {validateBadValueRule, checkHttpBatchValue},
+				httpContentTypeAttr: anyAttr,
+				httpCompressionAttr: {validateBadValueRule, checkHttpCompressionValue},
+				httpUsernameAttr:    anyAttr,
+				httpPasswordAttr:    anyAttr,
+				httpTokenAttr:       anyAttr,
+				httpMaxCountAttr:    uintAttr,
+				httpMaxSizeAttr:     uintAttr,
+				httpMaxIntervalAttr: durationAttr,
+				httpRetriesAttr:     uintAttr,
+				httpBackoffAttr:     durationAttr,
+				httpTimeoutAttr:     durationAttr,
+				httpBufferPathAttr:  anyAttr,
+				httpBufferSizeAttr:  uintAttr,
+			},
+			required: []string{httpUrlAttr},
+			children: []string{httpHeaderId},
+		},
+		httpHeaderId: {
+			attributes: map[string]attributeSpec{httpHeaderNameAttr: anyAttr, httpHeaderValueAttr: anyAttr},
+			required:   []string{httpHeaderNameAttr},
+		},
+		multiplexWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:       anyAttr,
+				multiplexStreamAttr:  anyAttr,
+				multiplexChannelAttr: {validateBadValueRule, checkMultiplexChannelValue},
+				multiplexFramingAttr: {validateBadValueRule, checkMultiplexFramingValue},
+			},
+			required: []string{multiplexStreamAttr},
+		},
+		eventLogWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:      anyAttr,
+				eventLogSourceAttr:  anyAttr,
+				eventLogEventIdAttr: uintAttr,
+				eventLogInstallAttr: boolAttr,
+			},
+		},
+		objectStoreWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:             anyAttr,
+				objectStoreEndpointAttr:    anyAttr,
+				objectStoreBucketAttr:      anyAttr,
+				objectStorePrefixAttr:      anyAttr,
+				objectStoreRegionAttr:      anyAttr,
+				objectStoreAccessKeyAttr:   anyAttr,
+				objectStoreSecretKeyAttr:   anyAttr,
+				objectStoreMaxSizeAttr:     uintAttr,
+				objectStoreMaxIntervalAttr: durationAttr,
+			},
+			required: []string{objectStoreEndpointAttr, objectStoreBucketAttr},
+		},
+		s3ArchiveWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:           anyAttr,
+				objectStoreEndpointAttr:  anyAttr,
+				objectStoreBucketAttr:    anyAttr,
+				objectStoreRegionAttr:    anyAttr,
+				objectStoreAccessKeyAttr: anyAttr,
+				objectStoreSecretKeyAttr: anyAttr,
+				archiveDirAttr:           anyAttr,
+				archiveKeyAttr:           anyAttr,
+				archiveServiceAttr:       anyAttr,
+				archiveMaxSizeAttr:       uintAttr,
+				archiveMaxIntervalAttr:   durationAttr,
+				archiveBufferSizeAttr:    uintAttr,
+			},
+			required: []string{objectStoreEndpointAttr, objectStoreBucketAttr, archiveDirAttr},
+		},
+		gcsArchiveWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:            anyAttr,
+				gcsArchiveEndpointAttr:    anyAttr,
+				gcsArchiveBucketAttr:      anyAttr,
+				gcsArchiveCredentialsAttr: anyAttr,
+				gcsArchiveChunkSizeAttr:   uintAttr,
+				archiveDirAttr:            anyAttr,
+				archiveKeyAttr:            anyAttr,
+				archiveServiceAttr:        anyAttr,
+				archiveMaxSizeAttr:        uintAttr,
+				archiveMaxIntervalAttr:    durationAttr,
+				archiveBufferSizeAttr:     uintAttr,
+			},
+			required: []string{gcsArchiveBucketAttr, archiveDirAttr},
+		},
+		azureBlobWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:           anyAttr,
+				azureBlobAccountAttr:     anyAttr,
+				azureBlobEndpointAttr:    anyAttr,
+				azureBlobContainerAttr:   anyAttr,
+				azureBlobNameAttr:        anyAttr,
+				azureBlobServiceAttr:     anyAttr,
+				azureBlobSasAttr:         anyAttr,
+				azureBlobClientIdAttr:    anyAttr,
+				azureBlobMaxSizeAttr:     uintAttr,
+				azureBlobMaxIntervalAttr: durationAttr,
+			},
+			required: []string{azureBlobContainerAttr},
+		},
+		sqliteWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:        anyAttr,
+				sqliteDriverAttr:      anyAttr,
+				sqlitePathAttr:        anyAttr,
+				sqliteTableAttr:       anyAttr,
+				sqliteMaxCountAttr:    uintAttr,
+				sqliteMaxIntervalAttr: durationAttr,
+				sqliteMaxRowsAttr:     uintAttr,
+				sqliteMaxSizeAttr:     uintAttr,
+			},
+			required: []string{sqlitePathAttr},
+		},
+		postgresWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:          anyAttr,
+				postgresAddrAttr:        anyAttr,
+				postgresUserAttr:        anyAttr,
+				postgresPasswordAttr:    anyAttr,
+				postgresDatabaseAttr:    anyAttr,
+				postgresTableAttr:       anyAttr,
+				postgresTLSAttr:         boolAttr,
+				postgresCACertDirAttr:   anyAttr,
+				postgresMaxCountAttr:    uintAttr,
+				postgresMaxIntervalAttr: durationAttr,
+				postgresTimeoutAttr:     durationAttr,
+			},
+			required: []string{postgresAddrAttr, postgresUserAttr},
+			children: []string{postgresColumnId},
+		},
+		postgresColumnId: {
+			attributes: map[string]attributeSpec{
+				postgresColumnNameAttr:  anyAttr,
+				postgresColumnValueAttr: anyAttr,
+				postgresColumnFieldAttr: anyAttr,
+			},
+			required: []string{postgresColumnNameAttr},
+		},
+		mongoWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:       anyAttr,
+				mongoAddrAttr:        anyAttr,
+				mongoDatabaseAttr:    anyAttr,
+				mongoCollectionAttr:  anyAttr,
+				mongoUserAttr:        anyAttr,
+				mongoPasswordAttr:    anyAttr,
+				mongoAuthSourceAttr:  anyAttr,
+				mongoTLSAttr:         boolAttr,
+				mongoCACertDirAttr:   anyAttr,
+				mongoCappedAttr:      uintAttr,
+				mongoTTLAttr:         durationAttr,
+				mongoWAttr:           anyAttr,
+				mongoJournalAttr:     boolAttr,
+				mongoWTimeoutAttr:    durationAttr,
+				mongoMaxCountAttr:    uintAttr,
+				mongoMaxIntervalAttr: durationAttr,
+				mongoTimeoutAttr:     durationAttr,
+			},
+			required: []string{mongoAddrAttr, mongoDatabaseAttr},
+		},
+		clickhouseWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:         anyAttr,
+				httpUrlAttr:            anyAttr,
+				clickhouseDatabaseAttr: anyAttr,
+				clickhouseTableAttr:    anyAttr,
+				clickhouseUserAttr:     anyAttr,
+				httpPasswordAttr:       anyAttr,
+				clickhouseAsyncAttr:    boolAttr,
+				httpCompressionAttr:    {validateBadValueRule, checkHttpCompressionValue},
+				httpMaxCountAttr:       uintAttr,
+				httpMaxSizeAttr:        uintAttr,
+				httpMaxIntervalAttr:    durationAttr,
+				httpRetriesAttr:        uintAttr,
+				httpBackoffAttr:        durationAttr,
+				httpTimeoutAttr:        durationAttr,
+				httpBufferPathAttr:     anyAttr,
+				httpBufferSizeAttr:     uintAttr,
+			},
+			required: []string{httpUrlAttr, clickhouseTableAttr},
+		},
+		influxWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:        anyAttr,
+				httpUrlAttr:           anyAttr,
+				influxOrgAttr:         anyAttr,
+				influxBucketAttr:      anyAttr,
+				influxDatabaseAttr:    anyAttr,
+				influxMeasurementAttr: anyAttr,
+				influxTagsAttr:        anyAttr,
+				influxPrecisionAttr:   anyAttr,
+				httpUsernameAttr:      anyAttr,
+				httpPasswordAttr:      anyAttr,
+				httpTokenAttr:         anyAttr,
+				httpCompressionAttr:   {validateBadValueRule, checkHttpCompressionValue},
+				httpMaxCountAttr:      uintAttr,
+				httpMaxSizeAttr:       uintAttr,
+				httpMaxIntervalAttr:   durationAttr,
+				httpRetriesAttr:       uintAttr,
+				httpBackoffAttr:       durationAttr,
+				httpTimeoutAttr:       durationAttr,
+				httpBufferPathAttr:    anyAttr,
+				httpBufferSizeAttr:    uintAttr,
+			},
+			required: []string{httpUrlAttr},
+		},
+		slackWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:    anyAttr,
+				httpUrlAttr:       anyAttr,
+				slackChannelAttr:  anyAttr,
+				slackUsernameAttr: anyAttr,
+				slackIconAttr:     anyAttr,
+				minLevelId:        levelAttr,
+				slackDedupAttr:    durationAttr,
+				slackLimitAttr:    uintAttr,
+				slackIntervalAttr: durationAttr,
+				httpTimeoutAttr:   durationAttr,
+			},
+			required: []string{httpUrlAttr},
+			children: []string{slackRouteId},
+		},
+		slackRouteId: {
+			attributes: map[string]attributeSpec{
+				levelsId:         levelsAttr,
+				httpUrlAttr:      anyAttr,
+				slackChannelAttr: anyAttr,
+			},
+			required: []string{levelsId},
+		},
+		telegramWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:       anyAttr,
+				telegramTokenAttr:    anyAttr,
+				telegramChatsAttr:    anyAttr,
+				telegramAPIAttr:      anyAttr,
+				minLevelId:           levelAttr,
+				telegramDelayAttr:    durationAttr,
+				telegramLimitAttr:    uintAttr,
+				telegramIntervalAttr: durationAttr,
+				telegramTimeoutAttr:  durationAttr,
+			},
+			required: []string{telegramTokenAttr, telegramChatsAttr},
+		},
+		pagerDutyWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:           anyAttr,
+				pagerDutyRoutingKeyAttr:  anyAttr,
+				httpUrlAttr:              anyAttr,
+				minLevelId:               levelAttr,
+				pagerDutyDedupKeyAttr:    anyAttr,
+				pagerDutySourceAttr:      anyAttr,
+				pagerDutyComponentAttr:   anyAttr,
+				pagerDutyGroupAttr:       anyAttr,
+				pagerDutyClassAttr:       anyAttr,
+				pagerDutyRepeatAttr:      durationAttr,
+				pagerDutyAutoResolveAttr: durationAttr,
+				httpRetriesAttr:          uintAttr,
+				httpBackoffAttr:          durationAttr,
+				httpTimeoutAttr:          durationAttr,
+			},
+			required: []string{pagerDutyRoutingKeyAttr},
+		},
+		datadogWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:      anyAttr,
+				datadogApiKeyAttr:   anyAttr,
+				datadogSiteAttr:     anyAttr,
+				httpUrlAttr:         anyAttr,
+				datadogSourceAttr:   anyAttr,
+				datadogTagsAttr:     anyAttr,
+				datadogServiceAttr:  anyAttr,
+				datadogHostnameAttr: anyAttr,
+				httpCompressionAttr: {validateBadValueRule, checkHttpCompressionValue},
+				httpMaxCountAttr:    uintAttr,
+				httpMaxSizeAttr:     uintAttr,
+				httpMaxIntervalAttr: durationAttr,
+				httpRetriesAttr:     uintAttr,
+				httpBackoffAttr:     durationAttr,
+				httpTimeoutAttr:     durationAttr,
+				httpBufferPathAttr:  anyAttr,
+				httpBufferSizeAttr:  uintAttr,
+			},
+			required: []string{datadogApiKeyAttr},
+		},
+		splunkWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:       anyAttr,
+				httpUrlAttr:          anyAttr,
+				httpTokenAttr:        anyAttr,
+				splunkIndexAttr:      anyAttr,
+				splunkSourceAttr:     anyAttr,
+				splunkSourceTypeAttr: anyAttr,
+				splunkHostAttr:       anyAttr,
+				splunkAckAttr:        boolAttr,
+				splunkChannelAttr:    anyAttr,
+				splunkAckTimeoutAttr: durationAttr,
+				httpCompressionAttr:  {validateBadValueRule, checkHttpCompressionValue},
+				httpMaxCountAttr:     uintAttr,
+				httpMaxSizeAttr:      uintAttr,
+				httpMaxIntervalAttr:  durationAttr,
+				httpRetriesAttr:      uintAttr,
+				httpBackoffAttr:      durationAttr,
+				httpTimeoutAttr:      durationAttr,
+				httpBufferPathAttr:   anyAttr,
+				httpBufferSizeAttr:   uintAttr,
+			},
+			required: []string{httpUrlAttr, httpTokenAttr},
+		},
+		cloudWatchWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:           anyAttr,
+				objectStoreRegionAttr:    anyAttr,
+				cloudWatchGroupAttr:      anyAttr,
+				cloudWatchStreamAttr:     anyAttr,
+				cloudWatchRetentionAttr:  uintAttr,
+				objectStoreEndpointAttr:  anyAttr,
+				objectStoreAccessKeyAttr: anyAttr,
+				objectStoreSecretKeyAttr: anyAttr,
+				httpMaxCountAttr:         uintAttr,
+				httpMaxIntervalAttr:      durationAttr,
+				httpRetriesAttr:          uintAttr,
+				httpBackoffAttr:          durationAttr,
+				httpTimeoutAttr:          durationAttr,
+			},
+			required: []string{cloudWatchGroupAttr},
+		},
+		cloudLoggingWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:            anyAttr,
+				cloudLoggingProjectAttr:   anyAttr,
+				cloudLoggingLogAttr:       anyAttr,
+				cloudLoggingLabelsAttr:    anyAttr,
+				gcsArchiveCredentialsAttr: anyAttr,
+				gcsArchiveEndpointAttr:    anyAttr,
+				httpMaxCountAttr:          uintAttr,
+				httpMaxIntervalAttr:       durationAttr,
+				httpRetriesAttr:           uintAttr,
+				httpBackoffAttr:           durationAttr,
+				httpTimeoutAttr:           durationAttr,
+			},
+		},
+		azureMonitorWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:             anyAttr,
+				azureMonitorWorkspaceAttr:  anyAttr,
+				azureMonitorSharedKeyAttr:  anyAttr,
+				azureMonitorLogTypeAttr:    anyAttr,
+				azureMonitorResourceIdAttr: anyAttr,
+				httpUrlAttr:                anyAttr,
+				httpMaxCountAttr:           uintAttr,
+				httpMaxSizeAttr:            uintAttr,
+				httpMaxIntervalAttr:        durationAttr,
+				httpRetriesAttr:            uintAttr,
+				httpBackoffAttr:            durationAttr,
+				httpTimeoutAttr:            durationAttr,
+				httpBufferPathAttr:         anyAttr,
+				httpBufferSizeAttr:         uintAttr,
+			},
+			required: []string{azureMonitorWorkspaceAttr, azureMonitorSharedKeyAttr},
+		},
+		statsdWriterId: {
+			attributes: map[string]attributeSpec{
+				udpAddrAttr:        anyAttr,
+				statsdPrefixAttr:   anyAttr,
+				statsdTagsAttr:     anyAttr,
+				statsdTimersAttr:   anyAttr,
+				statsdIntervalAttr: durationAttr,
+				udpMaxSizeAttr:     uintAttr,
+			},
+			required: []string{udpAddrAttr},
+		},
+		discardWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:  anyAttr,
+				discardNameAttr: anyAttr,
+			},
+		},
+		ringBufferWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:     anyAttr,
+				ringBufferNameAttr: anyAttr,
+				ringBufferSizeAttr: uintAttr,
+			},
+		},
+		mqttWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:    anyAttr,
+				httpUrlAttr:       anyAttr,
+				mqttTopicAttr:     anyAttr,
+				mqttQosAttr:       uintAttr,
+				mqttRetainAttr:    boolAttr,
+				mqttClientIdAttr:  anyAttr,
+				mqttUserAttr:      anyAttr,
+				httpPasswordAttr:  anyAttr,
+				mqttTLSAttr:       boolAttr,
+				mqttCACertDirAttr: anyAttr,
+				mqttKeepAliveAttr: durationAttr,
+				httpTimeoutAttr:   durationAttr,
+			},
+			required: []string{httpUrlAttr},
+		},
+		zmqWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:  anyAttr,
+				zmqEndpointAttr: anyAttr,
+				zmqTypeAttr:     anyAttr,
+				zmqBindAttr:     boolAttr,
+				zmqTopicAttr:    anyAttr,
+				zmqHwmAttr:      uintAttr,
+				httpTimeoutAttr: durationAttr,
+			},
+			required: []string{zmqEndpointAttr},
+		},
+		fifoWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:     anyAttr,
+				pathId:             anyAttr,
+				fifoCreateAttr:     boolAttr,
+				fifoBufferSizeAttr: uintAttr,
+				fifoRetryAttr:      durationAttr,
+				fifoTimeoutAttr:    durationAttr,
+			},
+			required: []string{pathId},
+		},
+		journaldWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:       anyAttr,
+				journaldSocketAttr:   anyAttr,
+				syslogTagAttr:        anyAttr,
+				syslogSeveritiesAttr: {validateBadValueRule, checkSyslogSeveritiesValue},
+			},
+		},
+		smtpWriterId: {
+			attributes: map[string]attributeSpec{
+				outputFormatId:  anyAttr,
+				senderaddressId: anyAttr,
+				senderNameId:    anyAttr,
+				hostNameId:      anyAttr,
+				hostPortId:      uintAttr,
+				userNameId:      anyAttr,
+				userPassId:      anyAttr,
+
+				smtpDigestAttr:         boolAttr,
+				smtpDigestLevelsAttr:   levelsAttr,
+				smtpDigestCountAttr:    uintAttr,
+				smtpDigestIntervalAttr: durationAttr,
+				smtpDigestSubjectAttr:  anyAttr,
+				smtpDedupAttr:          durationAttr,
+				smtpLimitAttr:          uintAttr,
+				smtpIntervalAttr:       durationAttr,
+				minLevelId:             levelAttr,
+				smtpContextAttr:        uintAttr,
+				smtpHtmlTemplateAttr:   anyAttr,
+				smtpTLSAttr:            {validateBadValueRule, checkSmtpTLSValue},
+				smtpTLSServerNameAttr:  anyAttr,
+				smtpTLSSkipVerifyAttr:  boolAttr,
+				smtpAuthAttr:           {validateBadValueRule, checkSmtpAuthValue},
+				smtpTokenAttr:          anyAttr,
+			},
+			required: []string{senderaddressId, senderNameId},
+			children: []string{recipientId, cACertDirpathId},
+		},
+		recipientId: {
+			attributes: map[string]attributeSpec{addressId: anyAttr, filterLevelsAttrId: levelsAttr},
+			required:   []string{addressId},
+		},
+		cACertDirpathId: {
+			attributes: map[string]attributeSpec{pathId: anyAttr},
+			required:   []string{pathId},
+		},
+		bufferedWriterId: {
+			attributes: map[string]attributeSpec{outputFormatId: anyAttr, bufferedSizeAttr: uintAttr, bufferedFlushPeriodAttr: uintAttr},
+			required:   []string{bufferedSizeAttr},
+			receivers:  true,
+		},
+		splitterDispatcherId: {attributes: map[string]attributeSpec{outputFormatId: anyAttr}, receivers: true},
+		filterDispatcherId: {
+			attributes: map[string]attributeSpec{outputFormatId: anyAttr, filterLevelsAttrId: levelsAttr},
+			required:   []string{filterLevelsAttrId},
+			receivers:  true,
+		},
+		samplerDispatcherId: {attributes: samplerAttributes, receivers: true},
+		eventsDispatcherId:  {receivers: true},
+		sloCounterId: {
+			attributes: map[string]attributeSpec{sloNameAttr: anyAttr},
+			children:   []string{sloEventRuleId, sloBadRuleId},
+		},
+		sloEventRuleId: {attributes: sloRuleAttributes},
+		sloBadRuleId:   {attributes: sloRuleAttributes},
+	}
+
+	// Every receiver may have an id to be changed at runtime
+	for name, element := range validationElements {
+		if _, isReceiver := elementMap[name]; !isReceiver {
+			continue
+		}
+		attributes := map[string]attributeSpec{outputIdAttr: anyAttr}
+		for attr, spec := range element.attributes {
+			attributes[attr] = spec
+		}
+		element.attributes = attributes
+		validationElements[name] = element
+	}
+}
+
+// ValidateConfig checks the structure of a config without creating a logger and returns
+// an issue for every problem found: syntax errors, unknown elements and attributes,
+// missing mandatory attributes, invalid levels and values, and references to formats
+// which are not declared. Issues of xml configs have the line and column of the element.
+// The data is an xml config, or a JSON config if it starts with '{'. An error is returned
+// only if a JSON config can not be parsed. Includes are not resolved and values using
+// environment variables are not checked.
+//
+// Example:
+//     issues, err := log.ValidateConfig(data)
+//     for _, issue := range issues {
+//         fmt.Println(issue)   // 12:9: seelog/outputs/file: error: ... (missing-attribute)
+//     }
+func ValidateConfig(data []byte) ([]Issue, error) {
+	config, err := unmarshalConfigData(data)
+	if err != nil {
+		var syntaxErr *xml.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return []Issue{{
+				Severity: IssueError,
+				Rule:     validateSyntaxRule,
+				Line:     syntaxErr.Line,
+				Message:  syntaxErr.Msg,
+			}}, nil
+		}
+		if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			return []Issue{{Severity: IssueError, Rule: validateSyntaxRule, Message: err.Error()}}, nil
+		}
+		return nil, err
+	}
+
+	return validateConfigNode(config), nil
+}
+
+func validateConfigNode(config *xmlNode) []Issue {
+	root := lintNode{config, config.name}
+	if config.name != seelogConfigId {
+		return []Issue{validationIssue(root, validateUnknownElementRule, "Root element must be '"+seelogConfigId+"'")}
+	}
+
+	// Custom levels are declared first, as the level attributes may use them
+	var issues []Issue
+	levels, err := declareCustomLevels(config)
+	if err != nil {
+		issues = append(issues, validationIssue(root, validateBadLevelRule, err.Error()))
+	}
+	setConfigLevels(config, levels)
+
+	formats := declaredFormatIds(root, nil)
+	issues = append(issues, validateElement(root, formats)...)
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Path < issues[j].Path
+	})
+	return issues
+}
+
+func validationIssue(n lintNode, rule string, message string) Issue {
+	return Issue{
+		Severity: IssueError,
+		Rule:     rule,
+		Path:     n.path,
+		Line:     n.node.line,
+		Column:   n.node.column,
+		Message:  message,
+	}
+}
+
+// declaredFormatIds returns the ids of the formats declared in the formats section of
+// a logger node, together with the inherited ones.
+func declaredFormatIds(logger lintNode, inherited map[string]bool) map[string]bool {
+	formats := make(map[string]bool)
+	for id := range inherited {
+		formats[id] = true
+	}
+	for _, section := range logger.node.children {
+		if section.name != formatsId {
+			continue
+		}
+		for _, format := range section.children {
+			if id, ok := format.attributes[formatKeyAttrId]; ok {
+				formats[id] = true
+			}
+		}
+	}
+	return formats
+}
+
+func validateElement(n lintNode, formats map[string]bool) []Issue {
+	element, known := validationElements[n.node.name]
+	if !known {
+		// Receivers registered without a validation description are not checked
+		return nil
+	}
+
+	issues := validateAttributes(n, element, formats)
+	return append(issues, validateChildren(n, element, formats)...)
+}
+
+func validateAttributes(n lintNode, element validationElement, formats map[string]bool) []Issue {
+	var issues []Issue
+
+	names := make([]string, 0, len(n.node.attributes))
+	for name := range n.node.attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := n.node.attributes[name]
+		spec, ok := element.attributes[name]
+		if !ok {
+			issues = append(issues, validationIssue(n, validateUnknownAttributeRule,
+				"Element '"+n.node.name+"' does not have attribute '"+name+"'"))
+			continue
+		}
+		if spec.check == nil || strings.Contains(value, "${") {
+			continue
+		}
+		var err error
+		switch check := spec.check.(type) {
+		case func(value string) error:
+			err = check(value)
+		case func(value string, levels levelSet) error:
+			err = check(value, n.node.levels)
+		}
+		if err != nil {
+			issues = append(issues, validationIssue(n, spec.rule,
+				"Attribute '"+name+"' has invalid value '"+value+"': "+err.Error()))
+		}
+	}
+
+	for _, name := range element.required {
+		if _, ok := n.node.attributes[name]; !ok {
+			issues = append(issues, validationIssue(n, validateMissingAttributeRule,
+				"Element '"+n.node.name+"' must have attribute '"+name+"'"))
+		}
+	}
+
+	if id, ok := n.node.attributes[outputFormatId]; ok && !formats[id] && !strings.Contains(id, "${") {
+		if _, predefined := predefinedFormats[id]; !predefined {
+			issues = append(issues, validationIssue(n, validateMissingFormatRule,
+				"Format '"+id+"' is not declared in the formats section"))
+		}
+	}
+
+	return issues
+}
+
+func validateChildren(n lintNode, element validationElement, formats map[string]bool) []Issue {
+	var issues []Issue
+	for _, child := range lintChildren(n) {
+		if !validChild(element, child.node.name) {
+			issues = append(issues, validationIssue(child, validateUnknownElementRule,
+				"Element '"+child.node.name+"' is not allowed in '"+n.node.name+"'"))
+			continue
+		}
+		if child.node.name == whenId {
+			// Children of conditional sections belong to the enclosing element
+			issues = append(issues, validateAttributes(child, validationElements[whenId], formats)...)
+			issues = append(issues, validateChildren(child, element, formats)...)
+			continue
+		}
+
+		childFormats := formats
+		if child.node.name == pipelineId {
+			childFormats = declaredFormatIds(child, formats)
+		}
+		issues = append(issues, validateElement(child, childFormats)...)
+	}
+
+	return issues
+}
+
+func validChild(element validationElement, name string) bool {
+	if name == includeId || name == whenId {
+		return true
+	}
+	if element.receivers {
+		if _, ok := elementMap[name]; ok {
+			return true
+		}
+	}
+	for _, child := range element.children {
+		if child == name {
+			return true
+		}
+	}
+	return false
+}
+
+func checkLevelValue(value string, levels levelSet) error {
+	if _, found := levels.fromString(value); !found {
+		return errors.New("expected one of trace, debug, info, warn, error, critical, off")
+	}
+	return nil
+}
+
+func checkLevelsValue(value string, levels levelSet) error {
+	_, err := parseLevels(value, levels)
+	return err
+}
+
+func checkUintValue(value string) error {
+	_, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return errors.New("expected a non-negative integer")
+	}
+	return nil
+}
+
+func checkFloatValue(value string) error {
+	_, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return errors.New("expected a number")
+	}
+	return nil
+}
+
+func checkDurationValue(value string) error {
+	_, err := time.ParseDuration(value)
+	if err != nil {
+		return errors.New("expected a duration, e.g. 30s")
+	}
+	return nil
+}
+
+func checkBoolValue(value string) error {
+	if value != "true" && value != "false" {
+		return errors.New("expected true or false")
+	}
+	return nil
+}
+
+func checkLoggerTypeValue(value string) error {
+	if _, found := getLoggerTypeFromString(value); !found {
+		return errors.New("unknown logger type")
+	}
+	return nil
+}
+
+func checkRollingTypeValue(value string) error {
+	if _, found := rollingTypeFromString(value); !found {
+		return errors.New("unknown rolling file type")
+	}
+	return nil
+}
+
+func checkRollingArchiveTypeValue(value string) error {
+	if _, found := rollingArchiveTypeFromString(value); !found {
+		return errors.New("unknown archive type")
+	}
+	return nil
+}
+
+func checkSyslogNetValue(value string) error {
+	switch value {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix", "unixgram":
+		return nil
+	}
+	return errors.New("expected one of udp, tcp, unix, unixgram")
+}
+
+func checkSyslogRFCValue(value string) error {
+	if value != syslogRFC3164 && value != syslogRFC5424 {
+		return errors.New("expected 3164 or 5424")
+	}
+	return nil
+}
+
+func checkSyslogFacilityValue(value string) error {
+	if _, found := syslogFacilities[value]; !found {
+		return errors.New("unknown syslog facility")
+	}
+	return nil
+}
+
+func checkSyslogSeveritiesValue(value string, levels levelSet) error {
+	_, err := parseSyslogSeverities(value, levels)
+	return err
+}
+
+func checkMultiplexChannelValue(value string) error {
+	if _, found := multiplexChannels[value]; !found {
+		return errors.New("expected stdout or stderr")
+	}
+	return nil
+}
+
+func checkMultiplexFramingValue(value string) error {
+	if value != multiplexTextFraming && value != multiplexBinaryFraming {
+		return errors.New("expected text or binary")
+	}
+	return nil
+}
+
+func checkRedisModeValue(value string) error {
+	if value != redisLPushMode && value != redisPublishMode {
+		return errors.New("expected lpush or publish")
+	}
+	return nil
+}
+
+func checkHttpCompressionValue(value string) error {
+	if value != httpCompressionAuto && value != httpCompressionNone && value != httpCompressionGzip {
+		return errors.New("expected auto, none or gzip")
+	}
+	return nil
+}
+
+func checkGelfNetValue(value string) error {
+	if value != "udp" && value != "tcp" {
+		return errors.New("expected udp or tcp")
+	}
+	return nil
+}
+
+func checkGelfCompressValue(value string) error {
+	if value != gelfCompressNone && value != gelfCompressGzip {
+		return errors.New("expected none or gzip")
+	}
+	return nil
+}
+
+func checkHttpBatchValue(value string) error {
+	if value != httpBatchNone && value != httpBatchArray && value != httpBatchNDJSON {
+		return errors.New("expected none, array or ndjson")
+	}
+	return nil
+}
+
+func checkConnOverflowValue(value string) error {
+	if value != connOverflowDropOldest && value != connOverflowDropNewest && value != connOverflowBlock {
+		return errors.New("expected dropoldest, dropnewest or block")
+	}
+	return nil
+}
+
+func checkSmtpTLSValue(value string) error {
+	if value != smtpTLSAuto && value != smtpTLSStartTLS && value != smtpTLSImplicit {
+		return errors.New("expected auto, starttls or implicit")
+	}
+	return nil
+}
+
+func checkSmtpAuthValue(value string) error {
+	if value != smtpAuthPlain && value != smtpAuthXoauth2 {
+		return errors.New("expected plain or xoauth2")
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	config := `<seelog minlevel="verbose" color="red">
+	<outputs formatid="missing">
+		<file/>
+		<filter levels="info,loud">
+			<console/>
+		</filter>
+		<unknown/>
+	</outputs>
+	<formats>
+		<format id="main" format="%Msg"/>
+	</formats>
+</seelog>`
+
+	issues, err := ValidateConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Issue{
+		{IssueError, validateUnknownAttributeRule, "seelog", 1, 1, ""},
+		{IssueError, validateBadLevelRule, "seelog", 1, 1, ""},
+		{IssueError, validateMissingFormatRule, "seelog/outputs", 2, 2, ""},
+		{IssueError, validateMissingAttributeRule, "seelog/outputs/file", 3, 3, ""},
+		{IssueError, validateBadLevelRule, "seelog/outputs/filter", 4, 3, ""},
+		{IssueError, validateUnknownElementRule, "seelog/outputs/unknown", 7, 3, ""},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %v", len(expected), issues)
+	}
+	for i, issue := range issues {
+		issue.Message = ""
+		if issue != expected[i] {
+			t.Errorf("Issue %d: expected %v, got %v", i, expected[i], issues[i])
+		}
+	}
+}
+
+func TestValidateConfigValid(t *testing.T) {
+	configs := []string{
+		`<seelog type="sync" minlevel="${LEVEL}">
+			<outputs formatid="main">
+				<rollingfile type="size" filename="a.log" maxsize="100" maxrolls="3"/>
+				<sampler rate="0.5" error="1"><console formatid="std:json"/></sampler>
+			</outputs>
+			<formats><format id="main" format="%Msg"/></formats>
+			<pipeline name="audit">
+				<outputs formatid="audit"><file path="audit.log"/></outputs>
+				<formats><format id="audit" format="%Msg"/></formats>
+			</pipeline>
+		</seelog>`,
+		`{"seelog": {"outputs": {"formatid": "main", "console": {}}, "formats": {"format": {"id": "main", "format": "%Msg"}}}}`,
+	}
+
+	for _, config := range configs {
+		issues, err := ValidateConfig([]byte(config))
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", config, err)
+		}
+		if len(issues) != 0 {
+			t.Errorf("Unexpected issues for %s: %v", config, issues)
+		}
+	}
+}
+
+func TestValidateConfigPipelineFormats(t *testing.T) {
+	config := `<seelog>
+	<outputs formatid="audit"><console/></outputs>
+	<pipeline name="audit">
+		<outputs formatid="audit"><console/></outputs>
+		<formats><format id="audit" format="%Msg"/></formats>
+	</pipeline>
+</seelog>`
+
+	issues, err := ValidateConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Rule != validateMissingFormatRule || issues[0].Path != "seelog/outputs" {
+		t.Errorf("Expected a missing format in the root outputs only, got %v", issues)
+	}
+}
+
+func TestValidateConfigSyntaxError(t *testing.T) {
+	issues, err := ValidateConfig([]byte("<seelog>\n<outputs>\n</seelog>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Rule != validateSyntaxRule || issues[0].Line != 3 {
+		t.Errorf("Expected a syntax error on line 3, got %v", issues)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	whenId           = "when"
+	whenEnvAttr      = "env"
+	whenHostnameAttr = "hostname"
+)
+
+var defaultLookupHostname = os.Hostname
+
+// lookupHostname is replaced in tests.
+var lookupHostname = defaultLookupHostname
+
+// resolveConditions replaces every 'when' element of the config with its children if
+// all of its conditions hold, and removes it otherwise. This way a single config file
+// can enable outputs only on some hosts or in some environments. Conditions:
+//     env="NAME=value"     environment variable NAME matches the value pattern
+//     env="NAME!=value"    environment variable NAME does not match the value pattern
+//     env="NAME"           environment variable NAME is set and not empty
+//     hostname="pattern"   host name matches the pattern
+// Patterns may contain '*' and '?' wildcards. Sections inside a 'when' element are
+// merged with the sections of the enclosing element, as for includes.
+//
+// Example:
+//     <outputs>
+//         <console/>
+//         <when env="ENV=production">
+//             <smtp senderaddress="..." sendername="..."> ... </smtp>
+//         </when>
+//         <when env="ENV=development">
+//             <console formatid="verbose"/>
+//         </when>
+//     </outputs>
+func resolveConditions(node *xmlNode) error {
+	var children []*xmlNode
+	resolved := make(map[*xmlNode]bool)
+	for _, child := range node.children {
+		if child.name != whenId {
+			err := resolveConditions(child)
+			if err != nil {
+				return err
+			}
+			children = append(children, child)
+			continue
+		}
+
+		matched, err := checkConditions(child)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		err = resolveConditions(child)
+		if err != nil {
+			return err
+		}
+		for _, conditionalChild := range child.children {
+			resolved[conditionalChild] = true
+			children = append(children, conditionalChild)
+		}
+	}
+
+	node.children = mergeIncludedSections(children, resolved)
+	return nil
+}
+
+// checkConditions returns true if all conditions of the 'when' element hold.
+func checkConditions(node *xmlNode) (bool, error) {
+	err := checkUnexpectedAttribute(node, whenEnvAttr, whenHostnameAttr)
+	if err != nil {
+		return false, err
+	}
+
+	envCondition, isEnv := node.attributes[whenEnvAttr]
+	hostnamePattern, isHostname := node.attributes[whenHostnameAttr]
+	if !isEnv && !isHostname {
+		return false, errors.New(node.errorName() + " has no '" + whenEnvAttr + "' or '" + whenHostnameAttr + "' attribute")
+	}
+
+	if isEnv {
+		matched, err := matchEnvCondition(envCondition)
+		if err != nil {
+			return false, errors.New(node.errorName() + " attribute '" + whenEnvAttr + "': " + err.Error())
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if isHostname {
+		hostname, err := lookupHostname()
+		if err != nil {
+			return false, err
+		}
+		matched, err := path.Match(hostnamePattern, hostname)
+		if err != nil {
+			return false, errors.New(node.errorName() + " attribute '" + whenHostnameAttr + "': " + err.Error())
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func matchEnvCondition(condition string) (bool, error) {
+	name, pattern, hasValue := strings.Cut(condition, "=")
+	negated := hasValue && strings.HasSuffix(name, "!")
+	name = strings.TrimSuffix(name, "!")
+	if name == "" {
+		return false, errors.New("Invalid condition '" + condition + "': expected NAME, NAME=value or NAME!=value")
+	}
+
+	value, _ := lookupEnv(name)
+	if !hasValue {
+		return value != "", nil
+	}
+
+	matched, err := path.Match(pattern, value)
+	if err != nil {
+		return false, err
+	}
+	return matched != negated, nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"strings"
+	"testing"
+)
+
+func withTestHostname(t *testing.T, hostname string) {
+	lookupHostname = func() (string, error) {
+		return hostname, nil
+	}
+	t.Cleanup(func() { lookupHostname = defaultLookupHostname })
+}
+
+type envConditionTest struct {
+	condition     string
+	expected      bool
+	errorExpected bool
+}
+
+var envConditionTests = []envConditionTest{
+	{"ENV=production", true, false},
+	{"ENV=prod*", true, false},
+	{"ENV=development", false, false},
+	{"ENV!=development", true, false},
+	{"ENV!=production", false, false},
+	{"ENV", true, false},
+	{"EMPTY", false, false},
+	{"MISSING", false, false},
+	{"MISSING=", true, false},
+	{"MISSING!=", false, false},
+	{"=production", false, true},
+	{"ENV=[", false, true},
+}
+
+func TestEnvConditions(t *testing.T) {
+	withTestEnv(t, map[string]string{"ENV": "production", "EMPTY": ""})
+
+	for _, test := range envConditionTests {
+		matched, err := matchEnvCondition(test.condition)
+		if (err != nil) != test.errorExpected {
+			t.Errorf("Condition: %s\n* Expected error: %t. Got error: %v", test.condition, test.errorExpected, err)
+			continue
+		}
+		if err == nil && matched != test.expected {
+			t.Errorf("Condition: %s\n* Expected: %t. Got: %t", test.condition, test.expected, matched)
+		}
+	}
+}
+
+func describeOutputKinds(t *testing.T, config string) string {
+	description, err := DescribeConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, output := range description.Outputs {
+		kinds = append(kinds, output.Kind+":"+output.FormatId)
+	}
+	return strings.Join(kinds, ",")
+}
+
+func TestConditionalSections(t *testing.T) {
+	config := `
+<seelog>
+	<outputs formatid="plain">
+		<console/>
+		<when env="ENV=production">
+			<file path="alerts.log"/>
+		</when>
+		<when env="ENV=development" hostname="dev-*">
+			<console formatid="verbose"/>
+		</when>
+	</outputs>
+	<when hostname="dev-*">
+		<formats>
+			<format id="verbose" format="%File:%Line %Msg%n"/>
+		</formats>
+	</when>
+	<formats>
+		<format id="plain" format="%Msg%n"/>
+	</formats>
+</seelog>`
+
+	withTestEnv(t, map[string]string{"ENV": "production"})
+	withTestHostname(t, "web-1")
+	if kinds := describeOutputKinds(t, config); kinds != "console:plain,file:plain" {
+		t.Errorf("Unexpected production outputs: %s", kinds)
+	}
+
+	withTestEnv(t, map[string]string{"ENV": "development"})
+	withTestHostname(t, "dev-laptop")
+	if kinds := describeOutputKinds(t, config); kinds != "console:plain,console:verbose" {
+		t.Errorf("Unexpected development outputs: %s", kinds)
+	}
+
+	withTestHostname(t, "web-1")
+	if _, err := DescribeConfig([]byte(config)); err != nil {
+		t.Errorf("Formats of a skipped section must not be required: %v", err)
+	}
+}
+
+func TestInvalidConditions(t *testing.T) {
+	withTestEnv(t, map[string]string{})
+	invalid := []string{
+		`<seelog><outputs><when><console/></when></outputs></seelog>`,
+		`<seelog><outputs><when env="ENV" host="x"><console/></when></outputs></seelog>`,
+		`<seelog><outputs><when hostname="["><console/></when></outputs></seelog>`,
+	}
+	for _, config := range invalid {
+		if _, err := DescribeConfig([]byte(config)); err == nil {
+			t.Errorf("Config must be rejected: %s", config)
+		}
+	}
+
+	issues, err := ValidateConfig([]byte(`
+<seelog>
+	<outputs>
+		<when env="ENV=production" host="x">
+			<console/>
+			<outputs/>
+		</when>
+	</outputs>
+</seelog>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []string
+	for _, issue := range issues {
+		rules = append(rules, issue.Rule)
+	}
+	if strings.Join(rules, ",") != "unknown-attribute,unknown-element" {
+		t.Errorf("Unexpected issues: %v", issues)
+	}
+}
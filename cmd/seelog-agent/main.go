@@ -0,0 +1,127 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+// Command seelog-agent tails local log files written by seelog (including rotated
+// and compressed ones) and forwards every line to the receivers of a seelog config.
+//
+// Example:
+//     seelog-agent -config forward.xml -level info "/var/log/app/*.log" "/var/log/app/*.zip"
+//
+// The config uses the usual seelog schema. Lines are passed as the message, so a
+// "%Msg%n" format forwards them unchanged; the file a line was read from is attached
+// as the "source" field (%Field(source)).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "seelog"
+)
+
+// SourceFieldKey is the key of the field which holds the path of the forwarded file.
+const SourceFieldKey = "source"
+
+var (
+	configFile = flag.String("config", "seelog.xml", "seelog config with the receivers to forward lines to")
+	levelName  = flag.String("level", "info", "level at which forwarded lines are logged")
+	interval   = flag.Duration("interval", time.Second, "how often the files are checked for new lines")
+	fromStart  = flag.Bool("from-start", false, "forward the current contents of the files, not only new lines")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] pattern...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "seelog-agent:", err)
+		os.Exit(1)
+	}
+}
+
+func run(patterns []string) error {
+	level, found := log.LogLevelFromString(*levelName)
+	if !found || level == log.Off {
+		return fmt.Errorf("unknown level %q", *levelName)
+	}
+
+	logger, err := log.LoggerFromConfigAsFile(*configFile)
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	forward := forwarder(logger.(log.StructuredLogger), level)
+	tail := newTailer(patterns, *fromStart, func(path string, line string) {
+		forward(line, log.Str(SourceFieldKey, path))
+	})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		if err := tail.poll(); err != nil {
+			fmt.Fprintln(os.Stderr, "seelog-agent:", err)
+		}
+		logger.Flush()
+
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// forwarder returns the logger method which writes at the given level.
+func forwarder(logger log.StructuredLogger, level log.LogLevel) func(message string, fields ...log.Field) {
+	switch level {
+	case log.TraceLvl:
+		return logger.Tracew
+	case log.DebugLvl:
+		return logger.Debugw
+	case log.WarnLvl:
+		return logger.Warnw
+	case log.ErrorLvl:
+		return logger.Errorw
+	case log.CriticalLvl:
+		return logger.Criticalw
+	}
+	return logger.Infow
+}
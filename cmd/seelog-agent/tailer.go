@@ -0,0 +1,508 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Bytes kept from the start and from the end of the sent content of a followed file,
+	// to recognize the file in archives
+	fingerprintSize = 1024
+	// Number of fingerprints kept of files which are no longer followed
+	maxEndedFiles = 256
+)
+
+// tailedFile is the state of a plain log file being followed.
+type tailedFile struct {
+	info    os.FileInfo
+	offset  int64
+	partial []byte // Last line without a trailing newline yet
+	sent    sentContent
+}
+
+// sentContent identifies the content of a followed file whose lines were already sent
+// (or skipped), so that an archive of the file, made by rotation, is not sent again.
+type sentContent struct {
+	size int64  // Length of the content
+	head []byte // First bytes of the content
+	tail []byte // Last bytes of the content
+}
+
+// archiveState is the state of an archive which was read.
+type archiveState struct {
+	size    int64
+	modTime time.Time
+	entries map[string]bool // Zip entries which were read, see zipEntryKey
+}
+
+// tailer follows log files matching a set of glob patterns. Plain files are read
+// incrementally and followed across renames and truncations. Compressed archives
+// (.gz, .zip), usually produced by rotation, are read once they are complete, except
+// for the content already sent from a followed file; zip archives are read again when
+// they change, for the entries added since.
+type tailer struct {
+	patterns  []string
+	fromStart bool
+	files     map[string]*tailedFile
+	ended     []sentContent // Content sent from files which are no longer followed
+	archives  map[string]*archiveState
+	started   bool
+	emit      func(path string, line string)
+}
+
+func newTailer(patterns []string, fromStart bool, emit func(path string, line string)) *tailer {
+	return &tailer{
+		patterns:  patterns,
+		fromStart: fromStart,
+		files:     make(map[string]*tailedFile),
+		archives:  make(map[string]*archiveState),
+		emit:      emit,
+	}
+}
+
+// poll reads everything that has been written to the matching files since the previous poll.
+// Files existing at the first poll are read from their end unless fromStart is set.
+func (t *tailer) poll() error {
+	paths, err := t.match()
+	if err != nil {
+		return err
+	}
+
+	skipExisting := !t.started && !t.fromStart
+	t.started = true
+
+	var plainPaths, archivePaths []string
+	infos := make(map[string]os.FileInfo)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		infos[path] = info
+		if isArchive(path) {
+			archivePaths = append(archivePaths, path)
+		} else {
+			plainPaths = append(plainPaths, path)
+		}
+	}
+
+	t.followRenames(infos)
+
+	for _, path := range plainPaths {
+		info := infos[path]
+		file, ok := t.files[path]
+		if !ok {
+			file = &tailedFile{info: info}
+			if skipExisting {
+				file.offset = info.Size()
+			}
+			t.files[path] = file
+		}
+		if info.Size() < file.offset {
+			t.endFile(file)
+			file.offset = 0
+			file.partial = nil
+			file.sent = sentContent{}
+		}
+		file.info = info
+
+		if err := t.readFile(path, file); err != nil {
+			return err
+		}
+	}
+
+	for path, file := range t.files {
+		if _, ok := infos[path]; !ok {
+			if !t.isFollowed(file, path) {
+				t.endFile(file)
+			}
+			delete(t.files, path)
+		}
+	}
+
+	// Archives are read after the plain files, so that the content of a file rotated
+	// since the last poll is known as sent. An archive which can not be read, e.g. as it
+	// is still being written, is tried again at the next poll.
+	var archiveErr error
+	for _, path := range archivePaths {
+		info := infos[path]
+		state, ok := t.archives[path]
+		if ok && (strings.HasSuffix(path, ".gz") || state.size == info.Size() && state.modTime.Equal(info.ModTime())) {
+			continue
+		}
+		if !ok {
+			state = &archiveState{entries: make(map[string]bool)}
+		}
+
+		if !skipExisting {
+			if err := t.readArchive(path, state); err != nil {
+				if archiveErr == nil {
+					archiveErr = err
+				}
+				continue
+			}
+		} else if strings.HasSuffix(path, ".zip") {
+			if err := t.skipZip(path, state); err != nil {
+				if archiveErr == nil {
+					archiveErr = err
+				}
+				continue
+			}
+		}
+		state.size, state.modTime = info.Size(), info.ModTime()
+		t.archives[path] = state
+	}
+
+	return archiveErr
+}
+
+func (t *tailer) match() ([]string, error) {
+	unique := make(map[string]bool)
+	for _, pattern := range t.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			unique[match] = true
+		}
+	}
+
+	paths := make([]string, 0, len(unique))
+	for path := range unique {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// followRenames moves the state of files which were renamed (e.g. by rotation) to their
+// new paths, so that the rest of a rotated file is read from where reading stopped.
+func (t *tailer) followRenames(infos map[string]os.FileInfo) {
+	moved := make(map[string]*tailedFile)
+	for oldPath, file := range t.files {
+		for path, info := range infos {
+			if path != oldPath && os.SameFile(file.info, info) {
+				moved[path] = file
+				break
+			}
+		}
+	}
+
+	for path, file := range moved {
+		current, ok := t.files[path]
+		if ok && os.SameFile(current.info, file.info) {
+			continue
+		}
+		t.files[path] = file
+	}
+
+	for path, file := range t.files {
+		info, ok := infos[path]
+		if ok && !os.SameFile(file.info, info) {
+			// A new file replaced the one that was followed at this path
+			if !t.isFollowed(file, path) {
+				t.endFile(file)
+			}
+			delete(t.files, path)
+		}
+	}
+}
+
+// isFollowed reports whether file is followed at a path other than path.
+func (t *tailer) isFollowed(file *tailedFile, path string) bool {
+	for otherPath, other := range t.files {
+		if otherPath != path && other == file {
+			return true
+		}
+	}
+	return false
+}
+
+// endFile keeps the sent content of a file which is no longer followed.
+func (t *tailer) endFile(file *tailedFile) {
+	if file.sent.size == 0 {
+		return
+	}
+	t.ended = append(t.ended, file.sent)
+	if len(t.ended) > maxEndedFiles {
+		t.ended = t.ended[len(t.ended)-maxEndedFiles:]
+	}
+}
+
+func (t *tailer) readFile(path string, file *tailedFile) error {
+	sent := file.offset - int64(len(file.partial))
+	if file.info.Size() == file.offset && sent == file.sent.size {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Content skipped at the start counts as sent
+	if sent != file.sent.size {
+		if err := file.sent.update(f, sent); err != nil {
+			return err
+		}
+	}
+	if file.info.Size() == file.offset {
+		return nil
+	}
+
+	if _, err := f.Seek(file.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	file.offset += int64(len(data))
+
+	data = append(file.partial, data...)
+	last := bytes.LastIndexByte(data, '\n')
+	if last == -1 {
+		file.partial = data
+		return nil
+	}
+	file.partial = append([]byte(nil), data[last+1:]...)
+
+	if err := t.emitLines(path, bytes.NewReader(data[:last+1])); err != nil {
+		return err
+	}
+	return file.sent.update(f, file.offset-int64(len(file.partial)))
+}
+
+// update sets the sent content to the first size bytes of f.
+func (sent *sentContent) update(f *os.File, size int64) error {
+	headSize := size
+	if headSize > fingerprintSize {
+		headSize = fingerprintSize
+	}
+	tailSize := headSize
+
+	head := make([]byte, headSize)
+	if _, err := f.ReadAt(head, 0); err != nil {
+		return err
+	}
+	tail := make([]byte, tailSize)
+	if _, err := f.ReadAt(tail, size-tailSize); err != nil {
+		return err
+	}
+
+	*sent = sentContent{size, head, tail}
+	return nil
+}
+
+// matches reports whether chunk, read at offset pos of some content, agrees with the
+// sent content.
+func (sent *sentContent) matches(chunk []byte, pos int64) bool {
+	return bytesAgree(chunk, pos, sent.head, 0) && bytesAgree(chunk, pos, sent.tail, sent.size-int64(len(sent.tail)))
+}
+
+// bytesAgree reports whether a and b, found at offsets aPos and bPos of the same content,
+// are equal where they overlap.
+func bytesAgree(a []byte, aPos int64, b []byte, bPos int64) bool {
+	start, end := aPos, aPos+int64(len(a))
+	if bPos > start {
+		start = bPos
+	}
+	if bEnd := bPos + int64(len(b)); bEnd < end {
+		end = bEnd
+	}
+	if start >= end {
+		return true
+	}
+	return bytes.Equal(a[start-aPos:end-aPos], b[start-bPos:end-bPos])
+}
+
+// sentPrefix reads the content from open in full and returns the length of the longest
+// content sent from a followed file which it starts with.
+func (t *tailer) sentPrefix(open func() (io.ReadCloser, error)) (int64, error) {
+	var candidates []sentContent
+	for _, file := range t.files {
+		if file.sent.size > 0 {
+			candidates = append(candidates, file.sent)
+		}
+	}
+	candidates = append(candidates, t.ended...)
+
+	reader, err := open()
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	var pos int64
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(chunk)
+		matching := candidates[:0]
+		for _, candidate := range candidates {
+			if candidate.matches(chunk[:n], pos) {
+				matching = append(matching, candidate)
+			}
+		}
+		candidates = matching
+		pos += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var prefix int64
+	for _, candidate := range candidates {
+		if candidate.size <= pos && candidate.size > prefix {
+			prefix = candidate.size
+		}
+	}
+	return prefix, nil
+}
+
+// readArchiveContent emits the lines of the content read from open which were not sent
+// from a followed file. The content is read once in full before, so that nothing is
+// emitted from an archive which is not complete yet.
+func (t *tailer) readArchiveContent(path string, open func() (io.ReadCloser, error)) error {
+	prefix, err := t.sentPrefix(open)
+	if err != nil {
+		return err
+	}
+
+	reader, err := open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if _, err := io.CopyN(ioutil.Discard, reader, prefix); err != nil {
+		return err
+	}
+	return t.emitLines(path, reader)
+}
+
+func (t *tailer) readArchive(path string, state *archiveState) error {
+	if strings.HasSuffix(path, ".gz") {
+		return t.readArchiveContent(path, func() (io.ReadCloser, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			reader, err := gzip.NewReader(f)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			return &gzipFile{reader, f}, nil
+		})
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		key := zipEntryKey(entry)
+		if state.entries[key] {
+			continue
+		}
+		if err := t.readArchiveContent(path+"/"+entry.Name, entry.Open); err != nil {
+			return err
+		}
+		state.entries[key] = true
+	}
+
+	return nil
+}
+
+// skipZip marks the entries of an archive existing at the first poll as read.
+func (t *tailer) skipZip(path string, state *archiveState) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		state.entries[zipEntryKey(entry)] = true
+	}
+	return nil
+}
+
+// zipEntryKey identifies an entry of a zip archive, which may be replaced by a new entry
+// of the same name when the archive is written again.
+func zipEntryKey(entry *zip.File) string {
+	return fmt.Sprintf("%s:%d:%08x", entry.Name, entry.UncompressedSize64, entry.CRC32)
+}
+
+// gzipFile closes the file under a gzip reader with the reader.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+func (t *tailer) emitLines(path string, reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line != "" {
+			t.emit(path, line)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func isArchive(path string) bool {
+	return strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".zip")
+}
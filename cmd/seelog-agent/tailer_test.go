@@ -0,0 +1,225 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type collectedLines struct {
+	lines []string
+}
+
+func (c *collectedLines) emit(path string, line string) {
+	c.lines = append(c.lines, filepath.Base(path)+": "+line)
+}
+
+func (c *collectedLines) take() []string {
+	lines := c.lines
+	c.lines = nil
+	return lines
+}
+
+func appendFile(t *testing.T, path string, data string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pollAndCheck(t *testing.T, tail *tailer, collected *collectedLines, expected ...string) {
+	if err := tail.poll(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := collected.take(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestTailerFollowsRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seelog-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logPath := filepath.Join(dir, "app.log")
+	appendFile(t, logPath, "old\n")
+
+	collected := new(collectedLines)
+	tail := newTailer([]string{filepath.Join(dir, "app.log*")}, false, collected.emit)
+
+	// Existing contents are skipped
+	pollAndCheck(t, tail, collected)
+
+	appendFile(t, logPath, "first\nsec")
+	pollAndCheck(t, tail, collected, "app.log: first")
+
+	appendFile(t, logPath, "ond\n")
+	pollAndCheck(t, tail, collected, "app.log: second")
+
+	// Rotation: the rest of the renamed file is read, the new file is read from the start
+	appendFile(t, logPath, "third\n")
+	if err := os.Rename(logPath, logPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, logPath, "fourth\n")
+	pollAndCheck(t, tail, collected, "app.log: fourth", "app.log.1: third")
+
+	// Truncation
+	if err := ioutil.WriteFile(logPath, []byte("fifth\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	pollAndCheck(t, tail, collected, "app.log: fifth")
+
+	// Compressed archives are read once
+	gz, err := os.Create(logPath + ".2.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := gzip.NewWriter(gz)
+	writer.Write([]byte("archived\n"))
+	writer.Close()
+	gz.Close()
+	pollAndCheck(t, tail, collected, "app.log.2.gz: archived")
+	pollAndCheck(t, tail, collected)
+}
+
+func gzipData(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, path string, entries ...string) {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for i := 0; i < len(entries); i += 2 {
+		entry, err := writer.Create(entries[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write([]byte(entries[i+1]))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTailerArchiveOfFollowedFile(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	appendFile(t, logPath, "old\n")
+
+	collected := new(collectedLines)
+	tail := newTailer([]string{filepath.Join(dir, "app.log*")}, false, collected.emit)
+	pollAndCheck(t, tail, collected)
+
+	appendFile(t, logPath, "first\n")
+	pollAndCheck(t, tail, collected, "app.log: first")
+
+	// Rotated and compressed between polls: only the line which was not read yet is sent
+	appendFile(t, logPath, "second\n")
+	if err := ioutil.WriteFile(logPath+".1.gz", gzipData(t, "old\nfirst\nsecond\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(logPath); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, logPath, "third\n")
+	pollAndCheck(t, tail, collected, "app.log: third", "app.log.1.gz: second")
+
+	// Rotated to a plain file first, then compressed
+	if err := os.Rename(logPath, logPath+".2"); err != nil {
+		t.Fatal(err)
+	}
+	pollAndCheck(t, tail, collected)
+	if err := ioutil.WriteFile(logPath+".2.gz", gzipData(t, "third\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(logPath + ".2"); err != nil {
+		t.Fatal(err)
+	}
+	pollAndCheck(t, tail, collected)
+}
+
+func TestTailerIncompleteArchive(t *testing.T) {
+	dir := t.TempDir()
+	collected := new(collectedLines)
+	tail := newTailer([]string{filepath.Join(dir, "*.gz")}, true, collected.emit)
+
+	data := gzipData(t, "first\nsecond\n")
+	gzPath := filepath.Join(dir, "app.log.1.gz")
+	if err := ioutil.WriteFile(gzPath, data[:len(data)-4], 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := tail.poll(); err == nil {
+		t.Error("Expected error for an incomplete archive")
+	}
+	if lines := collected.take(); len(lines) != 0 {
+		t.Errorf("Expected no lines from an incomplete archive, got %q", lines)
+	}
+
+	if err := ioutil.WriteFile(gzPath, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	pollAndCheck(t, tail, collected, "app.log.1.gz: first", "app.log.1.gz: second")
+	pollAndCheck(t, tail, collected)
+}
+
+func TestTailerGrowingZip(t *testing.T) {
+	dir := t.TempDir()
+	collected := new(collectedLines)
+	tail := newTailer([]string{filepath.Join(dir, "*.zip")}, true, collected.emit)
+
+	zipPath := filepath.Join(dir, "app.log.zip")
+	writeZip(t, zipPath, "app.log.1", "first\n")
+	pollAndCheck(t, tail, collected, "app.log.1: first")
+	pollAndCheck(t, tail, collected)
+
+	// Rolls are added to the archive by writing it again
+	writeZip(t, zipPath, "app.log.1", "first\n", "app.log.2", "second\n")
+	pollAndCheck(t, tail, collected, "app.log.2: second")
+}
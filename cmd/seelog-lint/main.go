@@ -0,0 +1,107 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+// Command seelog-lint validates seelog config files, checks valid ones for settings which
+// are probably mistakes and prints the found issues, one per line.
+//
+// Example:
+//     seelog-lint -profile prod seelog.xml
+//
+// With -describe the effective config (see seelog.DescribeConfig) of every valid file is
+// printed after the issues.
+//
+// The exit status is 1 if an issue of error severity was found (or of any severity
+// with -strict), and 2 if a file can not be read or parsed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	log "seelog"
+)
+
+var (
+	profile  = flag.String("profile", log.LintProfileDefault, "lint profile; \"prod\" enables production rules")
+	strict   = flag.Bool("strict", false, "fail on warnings too")
+	describe = flag.Bool("describe", false, "print the effective config of valid files")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] config...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	os.Exit(lintFiles(flag.Args(), *profile, *strict, *describe))
+}
+
+// lintFiles prints the issues of all files and returns the exit status.
+func lintFiles(fileNames []string, profile string, strict bool, describe bool) int {
+	status := 0
+	for _, fileName := range fileNames {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+			continue
+		}
+
+		issues, err := log.ValidateConfig(data)
+		if err == nil && len(issues) == 0 {
+			issues, err = log.LintConfig(data, profile)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", fileName, err)
+			status = 2
+			continue
+		}
+
+		valid := true
+		for _, issue := range issues {
+			fmt.Printf("%s:%s\n", fileName, issue)
+			valid = valid && issue.Severity != log.IssueError
+			if status == 0 && (strict || issue.Severity == log.IssueError) {
+				status = 1
+			}
+		}
+
+		if describe && valid {
+			description, err := log.DescribeConfig(data)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", fileName, err)
+				status = 2
+				continue
+			}
+			fmt.Printf("%s:\n%s", fileName, description)
+		}
+	}
+	return status
+}
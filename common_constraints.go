@@ -27,6 +27,7 @@ package seelog
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -39,30 +40,47 @@ type logLevelConstraints interface {
 type minMaxConstraints struct {
 	min LogLevel
 	max LogLevel
+
+	minSeverity int
+	maxSeverity int
+	levels      levelSet // Custom levels declared by the config of the constraints
 }
 
 // newMinMaxConstraints creates a new minMaxConstraints struct with the specified min and max levels.
+// Custom levels are ordered by their severity. Max level Critical also allows the custom
+// levels registered above Critical.
 func newMinMaxConstraints(min LogLevel, max LogLevel) (*minMaxConstraints, error) {
-	if min > max {
+	return newConfigMinMaxConstraints(min, max, nil)
+}
+
+// newConfigMinMaxConstraints creates min and max constraints which may use the custom levels
+// declared by a config.
+func newConfigMinMaxConstraints(min LogLevel, max LogLevel, levels levelSet) (*minMaxConstraints, error) {
+	minSeverity, maxSeverity := levels.severity(min), levels.severity(max)
+	if minSeverity > maxSeverity {
 		return nil, errors.New(fmt.Sprintf("Min level can't be greater than max. Got min: %d, max: %d", min, max))
 	}
-	if min < TraceLvl || min > CriticalLvl {
+	if minSeverity < 0 || min == Off {
 		return nil, errors.New(fmt.Sprintf("Min level can't be less than Trace or greater than Critical. Got min: %d", min))
 	}
-	if max < TraceLvl || max > CriticalLvl {
+	if maxSeverity < 0 || max == Off {
 		return nil, errors.New(fmt.Sprintf("Max level can't be less than Trace or greater than Critical. Got max: %d", max))
 	}
+	if max == CriticalLvl {
+		maxSeverity = LogLevel(Off).severity() - 1
+	}
 
-	return &minMaxConstraints{min, max}, nil
+	return &minMaxConstraints{min, max, minSeverity, maxSeverity, levels}, nil
 }
 
 // IsAllowed returns true, if log level is in [min, max] range (inclusive).
 func (minMaxConstr *minMaxConstraints) IsAllowed(level LogLevel) bool {
-	return level >= minMaxConstr.min && level <= minMaxConstr.max
+	severity := minMaxConstr.levels.severity(level)
+	return severity >= minMaxConstr.minSeverity && severity <= minMaxConstr.maxSeverity
 }
 
 func (minMaxConstr *minMaxConstraints) String() string {
-	return fmt.Sprintf("Min: %s. Max: %s", minMaxConstr.min, minMaxConstr.max)
+	return fmt.Sprintf("Min: %s. Max: %s", minMaxConstr.levels.name(minMaxConstr.min), minMaxConstr.levels.name(minMaxConstr.max))
 }
 
 //=======================================================
@@ -70,15 +88,22 @@ func (minMaxConstr *minMaxConstraints) String() string {
 // A listConstraints represents constraints which use allowed log levels list.
 type listConstraints struct {
 	allowedLevels map[LogLevel]bool
+	levels        levelSet // Custom levels declared by the config of the constraints
 }
 
 // newListConstraints creates a new listConstraints struct with the specified allowed levels.
 func newListConstraints(allowList []LogLevel) (*listConstraints, error) {
+	return newConfigListConstraints(allowList, nil)
+}
+
+// newConfigListConstraints creates list constraints which may use the custom levels declared
+// by a config.
+func newConfigListConstraints(allowList []LogLevel, levels levelSet) (*listConstraints, error) {
 	if allowList == nil {
 		return nil, errors.New("List can't be nil")
 	}
 
-	allowLevels, err := createMapFromList(allowList)
+	allowLevels, err := createMapFromList(allowList, levels)
 	if err != nil {
 		return nil, err
 	}
@@ -87,21 +112,23 @@ func newListConstraints(allowList []LogLevel) (*listConstraints, error) {
 		return nil, err
 	}
 
-	return &listConstraints{allowLevels}, nil
+	return &listConstraints{allowLevels, levels}, nil
 }
 
 func (listConstr *listConstraints) String() string {
 	allowedList := "List: "
 
-	listLevel := make([]string, len(listConstr.allowedLevels))
+	levels := make([]LogLevel, 0, len(listConstr.allowedLevels))
+	for logLevel := range listConstr.allowedLevels {
+		levels = append(levels, logLevel)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return listConstr.levels.severity(levels[i]) < listConstr.levels.severity(levels[j])
+	})
 
-	var logLevel LogLevel
-	i := 0
-	for logLevel = TraceLvl; logLevel <= Off; logLevel++ {
-		if listConstr.allowedLevels[logLevel] {
-			listLevel[i] = logLevel.String()
-			i++
-		}
+	listLevel := make([]string, len(levels))
+	for i, logLevel := range levels {
+		listLevel[i] = listConstr.levels.name(logLevel)
 	}
 
 	allowedList += strings.Join(listLevel, ",")
@@ -109,10 +136,10 @@ func (listConstr *listConstraints) String() string {
 	return allowedList
 }
 
-func createMapFromList(allowedList []LogLevel) (map[LogLevel]bool, error) {
+func createMapFromList(allowedList []LogLevel, levels levelSet) (map[LogLevel]bool, error) {
 	allowedLevels := make(map[LogLevel]bool, 0)
 	for _, level := range allowedList {
-		if level < TraceLvl || level > Off {
+		if levels.severity(level) < 0 {
 			return nil, errors.New(fmt.Sprintf("Level can't be less than Trace or greater than Critical. Got level: %d", level))
 		}
 		allowedLevels[level] = true
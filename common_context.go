@@ -58,6 +58,10 @@ type logContextInterface interface {
 	FileName() string
 	IsValid() bool
 	CallTime() time.Time
+	Fields() []Field
+	Stack() []StackFrame // Captured caller stack, starting at the log call; nil if not captured
+	QueueDepth() int     // Records ahead of this one in the async queue when it was enqueued
+	setQueueDepth(depth int)
 }
 
 // Returns context of the caller
@@ -105,15 +109,15 @@ func specificContext(skip int) (logContextInterface, error) {
 
 	if skip < 0 {
 		negativeStackFrameErr := errors.New("Can not skip negative stack frames")
-		return &errorContext{callTime, negativeStackFrameErr}, negativeStackFrameErr
+		return &errorContext{errorTime: callTime, err: negativeStackFrameErr}, negativeStackFrameErr
 	}
 
 	fullPath, shortPath, function, line, err := extractCallerInfo(skip + 2)
 	if err != nil {
-		return &errorContext{callTime, err}, err
+		return &errorContext{errorTime: callTime, err: err}, err
 	}
 	_, fileName := filepath.Split(fullPath)
-	return &logContext{function, line, shortPath, fullPath, fileName, callTime}, nil
+	return &logContext{function, line, shortPath, fullPath, fileName, callTime, nil, nil, 0}, nil
 }
 
 // Represents a normal runtime caller context
@@ -124,6 +128,9 @@ type logContext struct {
 	fullPath  string
 	fileName  string
 	callTime  time.Time
+	fields    []Field
+	stack     []StackFrame
+	depth     int
 }
 
 func (context *logContext) IsValid() bool {
@@ -154,6 +161,22 @@ func (context *logContext) CallTime() time.Time {
 	return context.callTime
 }
 
+func (context *logContext) Fields() []Field {
+	return context.fields
+}
+
+func (context *logContext) Stack() []StackFrame {
+	return context.stack
+}
+
+func (context *logContext) QueueDepth() int {
+	return context.depth
+}
+
+func (context *logContext) setQueueDepth(depth int) {
+	context.depth = depth
+}
+
 const (
 	errorContextFunc      = "Func() error:"
 	errorContextShortPath = "ShortPath() error:"
@@ -165,6 +188,8 @@ const (
 type errorContext struct {
 	errorTime time.Time
 	err       error
+	fields    []Field
+	depth     int
 }
 
 func (errContext *errorContext) IsValid() bool {
@@ -194,3 +219,29 @@ func (errContext *errorContext) FileName() string {
 func (errContext *errorContext) CallTime() time.Time {
 	return errContext.errorTime
 }
+
+func (errContext *errorContext) Fields() []Field {
+	return errContext.fields
+}
+
+func (errContext *errorContext) Stack() []StackFrame {
+	return nil
+}
+
+func (errContext *errorContext) QueueDepth() int {
+	return errContext.depth
+}
+
+func (errContext *errorContext) setQueueDepth(depth int) {
+	errContext.depth = depth
+}
+
+// setContextFields attaches fields to a context created by specificContext.
+func setContextFields(context logContextInterface, fields []Field) {
+	switch ctx := context.(type) {
+	case *logContext:
+		ctx.fields = fields
+	case *errorContext:
+		ctx.fields = fields
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+)
+
+// EventFieldKey is the key of the event name in event records.
+const EventFieldKey = "event"
+
+// eventContext marks the context of an event record. Events are written only by
+// events outputs (see eventsDispatcher) and are skipped by all other outputs.
+type eventContext struct {
+	logContextInterface
+	name string
+}
+
+// eventName returns the event name if the context belongs to an event record.
+func eventName(context logContextInterface) (string, bool) {
+	event, ok := context.(*eventContext)
+	if !ok {
+		return "", false
+	}
+	return event.name, true
+}
+
+// eventEmitter is implemented by loggers which support LoggerEventW.
+type eventEmitter interface {
+	eventW(callDepth int, name string, fields []Field)
+}
+
+func (cLogger *commonLogger) eventW(callDepth int, name string, fields []Field) {
+	if cLogger.Closed() {
+		return
+	}
+
+	context, _ := specificContext(callDepth)
+
+	mdcFields := CurrentContext()
+	if len(mdcFields) > 0 {
+		fields = append(append(make([]Field, 0, len(mdcFields)+len(fields)), mdcFields...), fields...)
+	}
+	setContextFields(context, fields)
+
+	cLogger.innerLogger.innerLog(InfoLvl, &eventContext{context, name}, newLogMessage([]interface{}{name}))
+}
+
+func (fLogger *fieldsLogger) eventW(callDepth int, name string, fields []Field) {
+	if emitter, ok := fLogger.logger.(eventEmitter); ok {
+		emitter.eventW(callDepth+1, name, append(append(make([]Field, 0, len(fLogger.fields)+len(fields)), fLogger.fields...), fields...))
+	}
+}
+
+// LoggerEventW writes a product or business event with the given name and fields.
+// Events are not subject to level constraints and are written only to the outputs
+// inside <events> elements, always as JSON objects, so that events and diagnostic
+// messages may share a transport but never a format.
+//
+// Example:
+//     seelog.LoggerEventW(logger, "signup", seelog.Str("plan", "pro"), seelog.Int("seats", 5))
+// writes
+//     {"time":"2013-05-06T12:04:05.123Z","event":"signup","plan":"pro","seats":5}
+func LoggerEventW(logger LoggerInterface, name string, fields ...Field) error {
+	if name == "" {
+		return errors.New("Event name cannot be empty")
+	}
+
+	emitter, ok := logger.(eventEmitter)
+	if !ok {
+		return errors.New("Logger does not support events")
+	}
+
+	emitter.eventW(loggerFuncCallDepth, name, fields)
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestLoggerEventW(t *testing.T) {
+	dir := t.TempDir()
+	appLog := filepath.Join(dir, "app.log")
+	eventsLog := filepath.Join(dir, "events.log")
+
+	logger, err := LoggerFromConfigAsString(`
+	<seelog type="sync" minlevel="error">
+		<outputs formatid="main">
+			<file path="` + appLog + `"/>
+			<events><file path="` + eventsLog + `"/></events>
+		</outputs>
+		<formats><format id="main" format="%Msg%n"/></formats>
+	</seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Error("failure")
+	if err = LoggerEventW(logger, "signup", Str("plan", "pro"), Int("seats", 5)); err != nil {
+		t.Fatal(err)
+	}
+	if err = LoggerEventW(LoggerWithFields(logger, Str("tenant", "acme")), "upgrade"); err != nil {
+		t.Fatal(err)
+	}
+	if err = LoggerEventW(logger, ""); err == nil {
+		t.Error("Expected error for an empty event name")
+	}
+	logger.Close()
+
+	app, err := os.ReadFile(appLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(app) != "failure\n" {
+		t.Errorf("Events must not reach text outputs, got: %q", app)
+	}
+
+	events, err := os.ReadFile(eventsLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := regexp.MustCompile(`^\{"time":"[^"]+","event":"signup","plan":"pro","seats":5\}\n` +
+		`\{"time":"[^"]+","event":"upgrade","tenant":"acme"\}\n$`)
+	if !expected.Match(events) {
+		t.Errorf("Unexpected events: %q", events)
+	}
+}
+
+func TestEventsConfigErrors(t *testing.T) {
+	invalidConfigs := []string{
+		`<seelog><outputs><events/></outputs></seelog>`,
+		`<seelog><outputs><events formatid="x"><console/></events></outputs></seelog>`,
+		`<seelog><outputs><events><filter levels="info"><console/></filter></events></outputs></seelog>`,
+	}
+
+	for _, config := range invalidConfigs {
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -33,31 +33,54 @@ import (
 
 // Used in rules creation to validate input file and func filters
 var (
-	fileFormatValidator = regexp.MustCompile(`[a-zA-Z0-9\\/ _\*\.]*`)
-	funcFormatValidator = regexp.MustCompile(`[a-zA-Z0-9_\*\.]*`)
+	fileFormatValidator    = regexp.MustCompile(`[a-zA-Z0-9\\/ _\*\.]*`)
+	funcFormatValidator    = regexp.MustCompile(`[a-zA-Z0-9_\*\.]*`)
+	packageFormatValidator = regexp.MustCompile(`[a-zA-Z0-9_~\-\/\*\.]*`)
 )
 
 // logLevelException represents an exceptional case used when you need some specific files or funcs to
 // override general constraints and to use their own.
 type logLevelException struct {
-	funcPatternParts []string
-	filePatternParts []string
+	funcPatternParts    []string
+	filePatternParts    []string
+	packagePatternParts []string
 
-	funcPattern string
-	filePattern string
+	funcPattern    string
+	filePattern    string
+	packagePattern string
+	packageTree    string // Package matched by a pattern ending with "/*" besides its subpackages
 
 	constraints logLevelConstraints
 }
 
 // newLogLevelException creates a new exception. 
 func newLogLevelException(funcPattern string, filePattern string, constraints logLevelConstraints) (*logLevelException, error) {
+	return newPackageLevelException("*", funcPattern, filePattern, constraints)
+}
+
+// newPackageLevelException creates a new exception which also matches the import path of
+// the package of the caller.
+func newPackageLevelException(
+	packagePattern string,
+	funcPattern string,
+	filePattern string,
+	constraints logLevelConstraints) (*logLevelException, error) {
 	if constraints == nil {
 		return nil, errors.New("Constraints can not be nil")
 	}
 
 	exception := new(logLevelException)
 
-	err := exception.initFuncPatternParts(funcPattern)
+	err := exception.initPackagePatternParts(packagePattern)
+	if err != nil {
+		return nil, err
+	}
+	exception.packagePattern = strings.Join(exception.packagePatternParts, "")
+	if strings.HasSuffix(exception.packagePattern, "/*") {
+		exception.packageTree = strings.TrimSuffix(exception.packagePattern, "/*")
+	}
+
+	err = exception.initFuncPatternParts(funcPattern)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +99,10 @@ func newLogLevelException(funcPattern string, filePattern string, constraints lo
 
 // MatchesContext returns true if context matches the patterns of this logLevelException
 func (logLevelEx *logLevelException) MatchesContext(context logContextInterface) bool {
-	return logLevelEx.match(context.Func(), context.FullPath())
+	if !logLevelEx.match(context.Func(), context.FullPath()) {
+		return false
+	}
+	return logLevelEx.matchPackage(funcPackage(context.Func()))
 }
 
 // IsAllowed returns true if log level is allowed according to the constraints of this logLevelException
@@ -94,6 +120,21 @@ func (logLevelEx *logLevelException) FilePattern() string {
 	return logLevelEx.filePattern
 }
 
+// PackagePattern returns the package pattern of a exception
+func (logLevelEx *logLevelException) PackagePattern() string {
+	return logLevelEx.packagePattern
+}
+
+// initPackagePatternParts checks whether the package filter has a correct format and splits it on parts
+func (logLevelEx *logLevelException) initPackagePatternParts(packagePattern string) error {
+	if packageFormatValidator.FindString(packagePattern) != packagePattern {
+		return errors.New("Package path \"" + packagePattern + "\" contains incorrect symbols. Only a-z A-Z 0-9 _ ~ - / * . allowed)")
+	}
+
+	logLevelEx.packagePatternParts = splitPattern(packagePattern)
+	return nil
+}
+
 // initFuncPatternParts checks whether the func filter has a correct format and splits funcPattern on parts
 func (logLevelEx *logLevelException) initFuncPatternParts(funcPattern string) (err error) {
 
@@ -123,8 +164,36 @@ func (logLevelEx *logLevelException) match(funcPath string, filePath string) boo
 	return stringMatchesPattern(logLevelEx.filePatternParts, filePath)
 }
 
+// matchPackage reports whether the import path matches the package pattern. A pattern
+// ending with "/*" matches the package before the slash and all packages below it.
+func (logLevelEx *logLevelException) matchPackage(packagePath string) bool {
+	if logLevelEx.packagePattern == "*" {
+		return true
+	}
+	if logLevelEx.packageTree != "" && stringMatchesPattern(splitPattern(logLevelEx.packageTree), packagePath) {
+		return true
+	}
+	return stringMatchesPattern(logLevelEx.packagePatternParts, packagePath)
+}
+
+// funcPackage returns the import path of the package of a function name reported by the
+// runtime, e.g. "github.com/me/app/db" for "github.com/me/app/db.(*Store).Get".
+func funcPackage(funcName string) string {
+	lastSlash := strings.LastIndexByte(funcName, '/')
+	dot := strings.IndexByte(funcName[lastSlash+1:], '.')
+	if dot == -1 {
+		return ""
+	}
+
+	// Dots in the last path element are escaped by the runtime, e.g. "gopkg.in/yaml%2ev2"
+	return strings.Replace(funcName[:lastSlash+1+dot], "%2e", ".", -1)
+}
+
 func (logLevelEx *logLevelException) String() string {
 	str := fmt.Sprintf("Func: %s File: %s ", logLevelEx.funcPattern, logLevelEx.filePattern)
+	if logLevelEx.packagePattern != "*" {
+		str += fmt.Sprintf("Package: %s ", logLevelEx.packagePattern)
+	}
 
 	if logLevelEx.constraints != nil {
 		str += fmt.Sprintf("Constr: %s", logLevelEx.constraints)
@@ -25,6 +25,9 @@
 package seelog
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -96,3 +99,88 @@ func TestAsterisksReducing(t *testing.T) {
 		t.Errorf("Asterisks must be reduced. Expect:%v, Got:%v", expectFile, rule.FilePattern())
 	}
 }
+
+type packageTestCase struct {
+	packagePattern string
+	funcName       string
+	match          bool
+}
+
+var packageTestCases = []packageTestCase{
+	{"*", "main.main", true},
+	{"github.com/me/app/db", "github.com/me/app/db.Query", true},
+	{"github.com/me/app/db", "github.com/me/app/db.(*Store).Get.func1", true},
+	{"github.com/me/app/db", "github.com/me/app/dbutil.Query", false},
+	{"github.com/me/app/db", "github.com/me/app/db/cache.Get", false},
+	{"github.com/me/app/db/*", "github.com/me/app/db.Query", true},
+	{"github.com/me/app/db/*", "github.com/me/app/db/cache.Get", true},
+	{"github.com/me/app/db/*", "github.com/me/app/db/cache/lru.(*Cache).Add", true},
+	{"github.com/me/app/db/*", "github.com/me/app/dbutil.Query", false},
+	{"github.com/me/*/db", "github.com/me/app/db.Query", true},
+	{"gopkg.in/yaml.v2", "gopkg.in/yaml%2ev2.Unmarshal", true},
+	{"main", "main.main", true},
+	{"main", "", false},
+}
+
+func TestPackageMatching(t *testing.T) {
+	constraints, err := newListConstraints([]LogLevel{TraceLvl})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range packageTestCases {
+		rule, err := newPackageLevelException(testCase.packagePattern, "*", "*", constraints)
+		if err != nil {
+			t.Fatalf("Unexpected error on rule creation: %v. %v", testCase.packagePattern, err)
+		}
+
+		context := &logContext{funcName: testCase.funcName, fullPath: "/src/file.go"}
+		if match := rule.MatchesContext(context); match != testCase.match {
+			t.Errorf("Incorrect matching for %v %v. Expected: %t. Got: %t",
+				testCase.packagePattern, testCase.funcName, testCase.match, match)
+		}
+	}
+
+	if _, err := newPackageLevelException("github.com/me/app?", "*", "*", constraints); err == nil {
+		t.Error("Expected an error for incorrect symbols")
+	}
+}
+
+func TestPackageLevelConfig(t *testing.T) {
+	conf, err := NewConfig().MinLevel(InfoLvl).PackageLevel("github.com/me/app/db/*", DebugLvl).AddConsole().config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &logContext{funcName: "github.com/me/app/db/cache.Get", fullPath: "/src/app/db/cache/cache.go"}
+	other := &logContext{funcName: "github.com/me/app/web.Serve", fullPath: "/src/app/web/web.go"}
+	if !conf.IsAllowed(DebugLvl, db) {
+		t.Error("Expected debug messages from the db packages")
+	}
+	if conf.IsAllowed(DebugLvl, other) || !conf.IsAllowed(InfoLvl, other) {
+		t.Error("Expected info as the minimal level of other packages")
+	}
+}
+
+func TestPackageLevelCaller(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	pkg := funcPackage(runtime.FuncForPC(pc).Name())
+
+	fileName := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewConfig().Sync().MinLevel(ErrorLvl).PackageLevel(pkg, DebugLvl).
+		Format("%Msg%n").AddFile(fileName).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Trace("trace")
+	logger.Debug("debug")
+	logger.Close()
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "debug\n" {
+		t.Errorf("Unexpected log of package %s: %q", pkg, data)
+	}
+}
@@ -0,0 +1,420 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultFacadeCallDepth is the call depth which makes the caller of a Facade method the
+// context of the logged message. See 'commonLogger.log' method comments.
+const defaultFacadeCallDepth = 3
+
+// facadeState is the mutable state shared by a facade and the facades derived from it
+// with Skip.
+type facadeState struct {
+	// Logging holds the read lock, so the logger is never closed while in use
+	mutex     sync.RWMutex
+	logger    *LoggerInterface // Guarded by mutex. Points to Current for the std facade
+	callDepth int32            // Accessed atomically
+}
+
+// Facade provides the package level convenience funcs (Trace, Debugf, ReplaceLogger, ...)
+// as methods bound to a replaceable logger. The package level funcs are shims for the
+// facade returned by Std. All methods are safe for concurrent use: a logger is replaced
+// only after all messages being logged to it are passed, and call depth changes never
+// race with logging.
+type Facade struct {
+	state   *facadeState
+	skip    int            // Frames added to the call depth of the state
+	options *recordOptions // Options of the messages, see To and AsJSON
+}
+
+// NewFacade creates a facade which logs to the specified logger.
+func NewFacade(logger LoggerInterface) (*Facade, error) {
+	if logger == nil {
+		return nil, errors.New("Logger can not be nil")
+	}
+	return newFacade(&logger), nil
+}
+
+// newFacade creates a facade which logs to the logger stored in the variable, so that
+// assignments to it are picked up. See Current.
+func newFacade(logger *LoggerInterface) *Facade {
+	return &Facade{state: &facadeState{logger: logger, callDepth: defaultFacadeCallDepth}}
+}
+
+// Skip returns a facade which shares the logger and the call depth with this one, but
+// reports the caller frames levels up as the context of messages. It is meant for
+// wrappers of the facade methods.
+//
+// Example:
+//     var wrapped = log.Std().Skip(1)
+//
+//     func Print(v ...interface{}) {
+//         wrapped.Info(v...)   // The caller of Print is the context
+//     }
+func (facade *Facade) Skip(frames int) *Facade {
+	return &Facade{state: facade.state, skip: facade.skip + frames, options: facade.options}
+}
+
+// To returns a facade which shares the logger with this one, but directs the messages to
+// the outputs with the given ids only. See LoggerTo.
+//
+// Example:
+//     seelog.To("audit").Infow("role granted", seelog.Str("user", user))
+func (facade *Facade) To(ids ...string) *Facade {
+	return facade.withTargets(ids, true)
+}
+
+// AlsoTo returns a facade which shares the logger with this one, but directs the messages
+// to the outputs with the given ids in addition to the normal dispatch. See LoggerAlsoTo.
+func (facade *Facade) AlsoTo(ids ...string) *Facade {
+	return facade.withTargets(ids, false)
+}
+
+func (facade *Facade) withTargets(ids []string, only bool) *Facade {
+	if len(ids) == 0 {
+		return facade
+	}
+	return facade.withOptions(&recordOptions{targets: &outputTargets{ids: ids, only: only}})
+}
+
+// AsJSON returns a facade which shares the logger with this one, but writes the messages
+// as JSON records to the outputs with text formats. See LoggerAsJSON.
+//
+// Example:
+//     seelog.Std().AsJSON().Infow("snapshot", seelog.Any("state", state))
+func (facade *Facade) AsJSON() *Facade {
+	return facade.withOptions(&recordOptions{format: jsonRecordFormat})
+}
+
+func (facade *Facade) withOptions(options *recordOptions) *Facade {
+	return &Facade{state: facade.state, skip: facade.skip, options: options.merge(facade.options)}
+}
+
+// Logger returns the logger the facade currently writes to.
+func (facade *Facade) Logger() LoggerInterface {
+	facade.state.mutex.RLock()
+	defer facade.state.mutex.RUnlock()
+	return *facade.state.logger
+}
+
+// CallDepth returns the call depth which is used to find the context of messages logged
+// with the facade methods, without the frames added by Skip.
+func (facade *Facade) CallDepth() int {
+	return int(atomic.LoadInt32(&facade.state.callDepth))
+}
+
+// SetCallDepth changes the call depth of the facade and of all facades derived from it
+// with Skip, and returns the previous one. Prefer Skip for wrappers: it does not affect
+// other callers.
+func (facade *Facade) SetCallDepth(depth int) int {
+	return int(atomic.SwapInt32(&facade.state.callDepth, int32(depth)))
+}
+
+// acquire returns the current logger and the call depth of a facade method and holds
+// the read lock until release is called.
+func (facade *Facade) acquire() (logger LoggerInterface, callDepth int, release func()) {
+	facade.state.mutex.RLock()
+	return *facade.state.logger, facade.CallDepth() + facade.skip, facade.state.mutex.RUnlock
+}
+
+// UseLogger makes the facade write to the specified logger. The previous logger is
+// flushed, but not closed. See the package level UseLogger.
+func (facade *Facade) UseLogger(logger LoggerInterface) error {
+	if logger == nil {
+		return errors.New("Logger can not be nil")
+	}
+
+	facade.state.mutex.Lock()
+	defer facade.state.mutex.Unlock()
+
+	oldLogger := *facade.state.logger
+	*facade.state.logger = logger
+
+	if oldLogger != nil {
+		oldLogger.Flush()
+	}
+
+	return nil
+}
+
+// ReplaceLogger acts as UseLogger but the logger that was previously used is disposed
+// (except Default and Disabled loggers). See the package level ReplaceLogger.
+func (facade *Facade) ReplaceLogger(logger LoggerInterface) error {
+	if logger == nil {
+		return errors.New("Logger can not be nil")
+	}
+
+	facade.state.mutex.Lock()
+	defer facade.state.mutex.Unlock()
+
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	oldLogger := *facade.state.logger
+	if oldLogger == Default {
+		oldLogger.Flush()
+	} else if oldLogger != nil && !oldLogger.Closed() && oldLogger != Disabled {
+		oldLogger.Flush()
+		oldLogger.Close()
+	}
+
+	*facade.state.logger = logger
+
+	return nil
+}
+
+// Tracef formats message according to format specifier and writes to the logger of the
+// facade with log level = Trace.
+func (facade *Facade) Tracef(format string, params ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.traceWithCallDepth(callDepth, newOptionsMessage(newLogFormattedMessage(format, params), facade.options))
+}
+
+// Debugf formats message according to format specifier and writes to the logger of the
+// facade with log level = Debug.
+func (facade *Facade) Debugf(format string, params ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.debugWithCallDepth(callDepth, newOptionsMessage(newLogFormattedMessage(format, params), facade.options))
+}
+
+// Infof formats message according to format specifier and writes to the logger of the
+// facade with log level = Info.
+func (facade *Facade) Infof(format string, params ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.infoWithCallDepth(callDepth, newOptionsMessage(newLogFormattedMessage(format, params), facade.options))
+}
+
+// Warnf formats message according to format specifier and writes to the logger of the
+// facade with log level = Warn.
+func (facade *Facade) Warnf(format string, params ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.warnWithCallDepth(callDepth, newOptionsMessage(newLogFormattedMessage(format, params), facade.options))
+}
+
+// Errorf formats message according to format specifier and writes to the logger of the
+// facade with log level = Error.
+func (facade *Facade) Errorf(format string, params ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.errorWithCallDepth(callDepth, newOptionsMessage(newLogFormattedMessage(format, params), facade.options))
+}
+
+// Criticalf formats message according to format specifier and writes to the logger of
+// the facade with log level = Critical.
+func (facade *Facade) Criticalf(format string, params ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.criticalWithCallDepth(callDepth, newOptionsMessage(newLogFormattedMessage(format, params), facade.options))
+}
+
+// Logf formats message according to format specifier and writes to the logger of the
+// facade with the specified level, which may be a custom level. See RegisterLevel.
+func (facade *Facade) Logf(level LogLevel, format string, params ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logWithCallDepth(logger, level, callDepth, newOptionsMessage(newLogFormattedMessage(format, params), facade.options))
+}
+
+// Trace formats message using the default formats for its operands and writes to the
+// logger of the facade with log level = Trace.
+func (facade *Facade) Trace(v ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.traceWithCallDepth(callDepth, newOptionsMessage(newLogMessage(v), facade.options))
+}
+
+// Debug formats message using the default formats for its operands and writes to the
+// logger of the facade with log level = Debug.
+func (facade *Facade) Debug(v ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.debugWithCallDepth(callDepth, newOptionsMessage(newLogMessage(v), facade.options))
+}
+
+// Info formats message using the default formats for its operands and writes to the
+// logger of the facade with log level = Info.
+func (facade *Facade) Info(v ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.infoWithCallDepth(callDepth, newOptionsMessage(newLogMessage(v), facade.options))
+}
+
+// Warn formats message using the default formats for its operands and writes to the
+// logger of the facade with log level = Warn.
+func (facade *Facade) Warn(v ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.warnWithCallDepth(callDepth, newOptionsMessage(newLogMessage(v), facade.options))
+}
+
+// Error formats message using the default formats for its operands and writes to the
+// logger of the facade with log level = Error.
+func (facade *Facade) Error(v ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.errorWithCallDepth(callDepth, newOptionsMessage(newLogMessage(v), facade.options))
+}
+
+// Critical formats message using the default formats for its operands and writes to the
+// logger of the facade with log level = Critical.
+func (facade *Facade) Critical(v ...interface{}) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.criticalWithCallDepth(callDepth, newOptionsMessage(newLogMessage(v), facade.options))
+}
+
+// Tracew writes the message with the attached fields to the logger of the facade with
+// log level = Trace.
+func (facade *Facade) Tracew(message string, fields ...Field) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.traceWithCallDepth(callDepth, newOptionsMessage(newLogFieldsMessage(message, fields), facade.options))
+}
+
+// Debugw writes the message with the attached fields to the logger of the facade with
+// log level = Debug.
+func (facade *Facade) Debugw(message string, fields ...Field) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.debugWithCallDepth(callDepth, newOptionsMessage(newLogFieldsMessage(message, fields), facade.options))
+}
+
+// Infow writes the message with the attached fields to the logger of the facade with
+// log level = Info.
+func (facade *Facade) Infow(message string, fields ...Field) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.infoWithCallDepth(callDepth, newOptionsMessage(newLogFieldsMessage(message, fields), facade.options))
+}
+
+// Warnw writes the message with the attached fields to the logger of the facade with
+// log level = Warn.
+func (facade *Facade) Warnw(message string, fields ...Field) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.warnWithCallDepth(callDepth, newOptionsMessage(newLogFieldsMessage(message, fields), facade.options))
+}
+
+// Errorw writes the message with the attached fields to the logger of the facade with
+// log level = Error.
+func (facade *Facade) Errorw(message string, fields ...Field) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.errorWithCallDepth(callDepth, newOptionsMessage(newLogFieldsMessage(message, fields), facade.options))
+}
+
+// Criticalw writes the message with the attached fields to the logger of the facade with
+// log level = Critical.
+func (facade *Facade) Criticalw(message string, fields ...Field) {
+	logger, callDepth, release := facade.acquire()
+	defer release()
+	logger.criticalWithCallDepth(callDepth, newOptionsMessage(newLogFieldsMessage(message, fields), facade.options))
+}
+
+// Flush flushes the logger of the facade. See the package level Flush.
+func (facade *Facade) Flush() {
+	logger, _, release := facade.acquire()
+	defer release()
+	logger.Flush()
+}
+
+// EventW writes a product or business event to the logger of the facade. See LoggerEventW.
+func (facade *Facade) EventW(name string, fields ...Field) error {
+	logger, _, release := facade.acquire()
+	defer release()
+	return LoggerEventW(logger, name, fields...)
+}
+
+// Heartbeat writes progress records to the logger of the facade until ctx is done, and
+// then a completion record. See LoggerHeartbeat.
+func (facade *Facade) Heartbeat(ctx context.Context, interval time.Duration, format string, params ...interface{}) error {
+	return startHeartbeat(ctx, interval, fmt.Sprintf(format, params...), func(level LogLevel, message fmt.Stringer) {
+		logger, _, release := facade.acquire()
+		defer release()
+		logWithLevel(logger, level, newOptionsMessage(message, facade.options))
+	})
+}
+
+// BoostLevel makes the logger of the facade write messages at the specified level and
+// above for the given duration. See BoostLoggerLevel.
+func (facade *Facade) BoostLevel(level LogLevel, duration time.Duration) error {
+	logger, _, release := facade.acquire()
+	defer release()
+	return BoostLoggerLevel(logger, level, duration)
+}
+
+// AddOutput adds an output to the logger of the facade. See AddLoggerOutput.
+func (facade *Facade) AddOutput(output string) error {
+	logger, _, release := facade.acquire()
+	defer release()
+	return AddLoggerOutput(logger, output)
+}
+
+// ReplaceOutput replaces an output of the logger of the facade. See ReplaceLoggerOutput.
+func (facade *Facade) ReplaceOutput(output string) error {
+	logger, _, release := facade.acquire()
+	defer release()
+	return ReplaceLoggerOutput(logger, output)
+}
+
+// RemoveOutput removes an output from the logger of the facade. See RemoveLoggerOutput.
+func (facade *Facade) RemoveOutput(id string) error {
+	logger, _, release := facade.acquire()
+	defer release()
+	return RemoveLoggerOutput(logger, id)
+}
+
+// SetOutputMinLevel sets the minimum level of an output of the logger of the facade.
+// See SetLoggerOutputMinLevel.
+func (facade *Facade) SetOutputMinLevel(id string, level LogLevel) error {
+	logger, _, release := facade.acquire()
+	defer release()
+	return SetLoggerOutputMinLevel(logger, id, level)
+}
+
+// flushAndClose flushes and closes the logger of the facade, keeping it as the current one.
+func (facade *Facade) flushAndClose() {
+	facade.state.mutex.Lock()
+	defer facade.state.mutex.Unlock()
+
+	logger := *facade.state.logger
+	logger.Flush()
+	logger.Close()
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func logThroughWrapper(facade *Facade, message string) {
+	facade.Info(message)
+}
+
+func TestFacadeCallerContext(t *testing.T) {
+	output := new(bytes.Buffer)
+	facade, err := NewFacade(newTestLogger(t, testLoggerConfig{format: "%FuncShort %Msg%n", writers: []interface{}{output}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facade.Info("direct")
+	logThroughWrapper(facade.Skip(1), "wrapped")
+
+	expected := "TestFacadeCallerContext direct\nTestFacadeCallerContext wrapped\n"
+	if output.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, output.String())
+	}
+}
+
+func TestPackageFuncsCallerContext(t *testing.T) {
+	output := new(bytes.Buffer)
+	oldLogger := CurrentLogger()
+	UseLogger(newTestLogger(t, testLoggerConfig{format: "%FuncShort %Msg%n", writers: []interface{}{output}}))
+	defer UseLogger(oldLogger)
+
+	Info("package")
+	Std().Info("std")
+
+	expected := "TestPackageFuncsCallerContext package\nTestPackageFuncsCallerContext std\n"
+	if output.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, output.String())
+	}
+	if Current != CurrentLogger() {
+		t.Error("Current must follow UseLogger")
+	}
+}
+
+func TestFacadeReplaceWhileLogging(t *testing.T) {
+	facade, err := NewFacade(Disabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				facade.Infof("message %d", j)
+				facade.SetCallDepth(facade.CallDepth())
+			}
+		}()
+	}
+
+	var outputs []*bytes.Buffer
+	for i := 0; i < 20; i++ {
+		output := new(bytes.Buffer)
+		outputs = append(outputs, output)
+		logger, err := LoggerFromWriterWithMinLevel(output, TraceLvl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		facade.ReplaceLogger(logger)
+	}
+	wg.Wait()
+	facade.Flush()
+
+	for _, output := range outputs {
+		for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+			if line != "" && !strings.Contains(line, "message") {
+				t.Errorf("Unexpected line: %q", line)
+			}
+		}
+	}
+}
+
+func TestStaticFuncCallDepth(t *testing.T) {
+	output := new(bytes.Buffer)
+	oldLogger := CurrentLogger()
+	UseLogger(newTestLogger(t, testLoggerConfig{format: "%FuncShort %Msg%n", writers: []interface{}{output}}))
+	defer UseLogger(oldLogger)
+
+	if depth := GetStaticFuncCallDepth(); depth != defaultFacadeCallDepth {
+		t.Fatalf("Expected default depth %d, got %d", defaultFacadeCallDepth, depth)
+	}
+	old := SetStaticFuncCallDepth(defaultFacadeCallDepth + 1)
+	defer SetStaticFuncCallDepth(old)
+	logThroughWrapper(Std(), "wrapped")
+
+	expected := "TestStaticFuncCallDepth wrapped\n"
+	if output.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, output.String())
+	}
+}
+
+func TestCurrentAssignment(t *testing.T) {
+	output := new(bytes.Buffer)
+	oldLogger := CurrentLogger()
+	defer UseLogger(oldLogger)
+
+	Current = newTestLogger(t, testLoggerConfig{format: "%FuncShort %Msg%n", writers: []interface{}{output}})
+	Info("assigned")
+
+	if CurrentLogger() != Current {
+		t.Error("CurrentLogger must return the assigned logger")
+	}
+	expected := "TestCurrentAssignment assigned\n"
+	if output.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, output.String())
+	}
+}
@@ -0,0 +1,523 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field is a key-value pair attached to a log record. Fields travel with the
+// record through the dispatcher tree and can be rendered by format verbs such
+// as %Field(key).
+//
+// Value keeps the type it was created with (string, int64, uint64, float64, bool,
+// time.Time, error or any other value passed to Any), so structured outputs such
+// as %Json and %MsgPack can write numbers and booleans as such. Text verbs convert
+// it with FieldValueString.
+//
+// A field created with Group holds nested fields in Group instead of a value. Groups
+// are rendered as nested objects by %Json and as dotted keys (http.method) by text verbs.
+type Field struct {
+	Key   string
+	Value interface{}
+	Group []Field
+}
+
+// Str creates a field with a string value.
+func Str(key string, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int creates a field with an integer value.
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: int64(value)}
+}
+
+// Int64 creates a field with an integer value.
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Uint64 creates a field with an unsigned integer value.
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Float64 creates a field with a floating-point value.
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Bool creates a field with a boolean value.
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Time creates a field with a time value.
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err creates a field with an error. A nil error is kept as a nil value.
+func Err(key string, err error) Field {
+	if err == nil {
+		return Field{Key: key}
+	}
+	return Field{Key: key, Value: err}
+}
+
+// Any creates a field with an arbitrary value. Values of builtin numeric types are
+// converted to int64, uint64 or float64.
+func Any(key string, value interface{}) Field {
+	switch v := value.(type) {
+	case int:
+		return Int64(key, int64(v))
+	case int8:
+		return Int64(key, int64(v))
+	case int16:
+		return Int64(key, int64(v))
+	case int32:
+		return Int64(key, int64(v))
+	case uint:
+		return Uint64(key, uint64(v))
+	case uint8:
+		return Uint64(key, uint64(v))
+	case uint16:
+		return Uint64(key, uint64(v))
+	case uint32:
+		return Uint64(key, uint64(v))
+	case float32:
+		return Float64(key, float64(v))
+	}
+	return Field{Key: key, Value: value}
+}
+
+// FieldValueString converts a field value to its text representation.
+func FieldValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	case []StackFrame:
+		return stackString(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// Group creates a field which nests the given fields under key.
+// Example:
+//     logger.Infow("request", seelog.Group("http", seelog.Str("method", m), seelog.Int("status", s)))
+// A group without fields is omitted from the output; the fields of a group with
+// an empty key are inlined into the enclosing level.
+func Group(key string, fields ...Field) Field {
+	group := make([]Field, len(fields))
+	copy(group, fields)
+
+	return Field{Key: key, Group: group}
+}
+
+// IsGroup reports whether the field was created with Group.
+func (field Field) IsGroup() bool {
+	return field.Group != nil
+}
+
+func (field Field) String() string {
+	if !field.IsGroup() {
+		return field.Key + "=" + FieldValueString(field.Value)
+	}
+
+	pairs := make([]string, 0, len(field.Group))
+	for _, nested := range flattenFields([]Field{field}) {
+		pairs = append(pairs, nested.String())
+	}
+	return strings.Join(pairs, " ")
+}
+
+// flattenFields replaces groups with their nested fields, whose keys are prefixed
+// with the group key and a dot.
+func flattenFields(fields []Field) []Field {
+	hasGroups := false
+	for _, field := range fields {
+		if field.IsGroup() {
+			hasGroups = true
+			break
+		}
+	}
+	if !hasGroups {
+		return fields
+	}
+
+	flat := make([]Field, 0, len(fields))
+	return appendFlatFields(flat, "", fields)
+}
+
+func appendFlatFields(flat []Field, prefix string, fields []Field) []Field {
+	for _, field := range fields {
+		key := field.Key
+		if prefix != "" && key != "" {
+			key = prefix + "." + key
+		} else if key == "" {
+			key = prefix
+		}
+
+		if field.IsGroup() {
+			flat = appendFlatFields(flat, key, field.Group)
+			continue
+		}
+
+		flat = append(flat, Field{Key: key, Value: field.Value})
+	}
+
+	return flat
+}
+
+// fieldsCarrier is implemented by messages which carry fields to the logger.
+type fieldsCarrier interface {
+	Fields() []Field
+}
+
+// fieldsMessage wraps a log message together with the fields attached to it.
+type fieldsMessage struct {
+	message fmt.Stringer
+	fields  []Field
+}
+
+// newFieldsMessage attaches fields to a message. If the message already carries
+// fields, the new fields are placed in front of them, so that fields bound to a
+// logger precede the ones passed at the call site.
+func newFieldsMessage(message fmt.Stringer, fields []Field) fmt.Stringer {
+	if len(fields) == 0 {
+		return message
+	}
+
+	// Options stay outermost, where the logger looks for them
+	if options, ok := message.(*optionsMessage); ok {
+		return &optionsMessage{newFieldsMessage(options.message, fields), options.options}
+	}
+
+	inner, ok := message.(*fieldsMessage)
+	if ok {
+		merged := make([]Field, 0, len(fields)+len(inner.fields))
+		merged = append(merged, fields...)
+		merged = append(merged, inner.fields...)
+		return &fieldsMessage{inner.message, merged}
+	}
+
+	return &fieldsMessage{message, fields}
+}
+
+func (message *fieldsMessage) String() string {
+	return message.message.String()
+}
+
+func (message *fieldsMessage) Fields() []Field {
+	return message.fields
+}
+
+// fieldsLogger is a StructuredLogger which attaches a fixed set of fields, and options
+// such as the outputs to direct them to, to every message it passes to the underlying
+// logger.
+type fieldsLogger struct {
+	logger  LoggerInterface
+	fields  []Field
+	options *recordOptions
+}
+
+// LoggerWithFields returns a logger which writes to the given logger and attaches
+// the specified fields to each message.
+func LoggerWithFields(logger LoggerInterface, fields ...Field) StructuredLogger {
+	if len(fields) == 0 {
+		if structured, ok := logger.(StructuredLogger); ok {
+			return structured
+		}
+		return &fieldsLogger{logger: logger}
+	}
+
+	bound := make([]Field, len(fields))
+	copy(bound, fields)
+
+	return &fieldsLogger{logger: logger, fields: bound}
+}
+
+// LoggerWithContext returns a logger which attaches the fields stored in ctx
+// (see ContextWithFields) to each message written to the given logger.
+func LoggerWithContext(logger LoggerInterface, ctx context.Context) StructuredLogger {
+	return LoggerWithFields(logger, FieldsFromContext(ctx)...)
+}
+
+func (fLogger *fieldsLogger) Tracef(format string, params ...interface{}) {
+	fLogger.logger.traceWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFormattedMessage(format, params)))
+}
+
+func (fLogger *fieldsLogger) Debugf(format string, params ...interface{}) {
+	fLogger.logger.debugWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFormattedMessage(format, params)))
+}
+
+func (fLogger *fieldsLogger) Infof(format string, params ...interface{}) {
+	fLogger.logger.infoWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFormattedMessage(format, params)))
+}
+
+func (fLogger *fieldsLogger) Warnf(format string, params ...interface{}) {
+	fLogger.logger.warnWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFormattedMessage(format, params)))
+}
+
+func (fLogger *fieldsLogger) Errorf(format string, params ...interface{}) {
+	fLogger.logger.errorWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFormattedMessage(format, params)))
+}
+
+func (fLogger *fieldsLogger) Criticalf(format string, params ...interface{}) {
+	fLogger.logger.criticalWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFormattedMessage(format, params)))
+}
+
+func (fLogger *fieldsLogger) Trace(v ...interface{}) {
+	fLogger.logger.traceWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogMessage(v)))
+}
+
+func (fLogger *fieldsLogger) Debug(v ...interface{}) {
+	fLogger.logger.debugWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogMessage(v)))
+}
+
+func (fLogger *fieldsLogger) Info(v ...interface{}) {
+	fLogger.logger.infoWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogMessage(v)))
+}
+
+func (fLogger *fieldsLogger) Warn(v ...interface{}) {
+	fLogger.logger.warnWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogMessage(v)))
+}
+
+func (fLogger *fieldsLogger) Error(v ...interface{}) {
+	fLogger.logger.errorWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogMessage(v)))
+}
+
+func (fLogger *fieldsLogger) Critical(v ...interface{}) {
+	fLogger.logger.criticalWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogMessage(v)))
+}
+
+func (fLogger *fieldsLogger) Tracew(message string, fields ...Field) {
+	fLogger.logger.traceWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFieldsMessage(message, fields)))
+}
+
+func (fLogger *fieldsLogger) Debugw(message string, fields ...Field) {
+	fLogger.logger.debugWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFieldsMessage(message, fields)))
+}
+
+func (fLogger *fieldsLogger) Infow(message string, fields ...Field) {
+	fLogger.logger.infoWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFieldsMessage(message, fields)))
+}
+
+func (fLogger *fieldsLogger) Warnw(message string, fields ...Field) {
+	fLogger.logger.warnWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFieldsMessage(message, fields)))
+}
+
+func (fLogger *fieldsLogger) Errorw(message string, fields ...Field) {
+	fLogger.logger.errorWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFieldsMessage(message, fields)))
+}
+
+func (fLogger *fieldsLogger) Criticalw(message string, fields ...Field) {
+	fLogger.logger.criticalWithCallDepth(loggerFuncCallDepth, fLogger.wrap(newLogFieldsMessage(message, fields)))
+}
+
+func (fLogger *fieldsLogger) Logf(level LogLevel, format string, params ...interface{}) {
+	logWithCallDepth(fLogger.logger, level, loggerFuncCallDepth, fLogger.wrap(newLogFormattedMessage(format, params)))
+}
+
+func (fLogger *fieldsLogger) traceWithCallDepth(callDepth int, message fmt.Stringer) {
+	fLogger.logger.traceWithCallDepth(callDepth+1, fLogger.wrap(message))
+}
+
+func (fLogger *fieldsLogger) debugWithCallDepth(callDepth int, message fmt.Stringer) {
+	fLogger.logger.debugWithCallDepth(callDepth+1, fLogger.wrap(message))
+}
+
+func (fLogger *fieldsLogger) infoWithCallDepth(callDepth int, message fmt.Stringer) {
+	fLogger.logger.infoWithCallDepth(callDepth+1, fLogger.wrap(message))
+}
+
+func (fLogger *fieldsLogger) warnWithCallDepth(callDepth int, message fmt.Stringer) {
+	fLogger.logger.warnWithCallDepth(callDepth+1, fLogger.wrap(message))
+}
+
+func (fLogger *fieldsLogger) errorWithCallDepth(callDepth int, message fmt.Stringer) {
+	fLogger.logger.errorWithCallDepth(callDepth+1, fLogger.wrap(message))
+}
+
+func (fLogger *fieldsLogger) criticalWithCallDepth(callDepth int, message fmt.Stringer) {
+	fLogger.logger.criticalWithCallDepth(callDepth+1, fLogger.wrap(message))
+}
+
+func (fLogger *fieldsLogger) logWithCallDepth(level LogLevel, callDepth int, message fmt.Stringer) {
+	logWithCallDepth(fLogger.logger, level, callDepth+1, fLogger.wrap(message))
+}
+
+func (fLogger *fieldsLogger) Close() {
+	fLogger.logger.Close()
+}
+
+func (fLogger *fieldsLogger) Flush() {
+	fLogger.logger.Flush()
+}
+
+func (fLogger *fieldsLogger) Closed() bool {
+	return fLogger.logger.Closed()
+}
+
+func (fLogger *fieldsLogger) wrap(message fmt.Stringer) fmt.Stringer {
+	return newOptionsMessage(newFieldsMessage(message, fLogger.fields), fLogger.options)
+}
+
+// contextFieldsKey is the context.Context key under which fields are stored.
+type contextFieldsKey struct{}
+
+// ContextWithFields returns a copy of ctx which carries the given fields in
+// addition to the fields already stored in ctx.
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	existing := FieldsFromContext(ctx)
+
+	merged := make([]Field, 0, len(existing)+len(fields))
+	merged = append(merged, existing...)
+	merged = append(merged, fields...)
+
+	return context.WithValue(ctx, contextFieldsKey{}, merged)
+}
+
+// FieldsFromContext returns the fields stored in ctx by ContextWithFields.
+func FieldsFromContext(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+
+	fields, _ := ctx.Value(contextFieldsKey{}).([]Field)
+	return fields
+}
+
+// writeJsonFields writes fields as members of a JSON object. If comma is set, the
+// first member is preceded by a comma. Returns whether a comma is needed before
+// the next member.
+func writeJsonFields(buf *bytes.Buffer, fields []Field, comma bool) bool {
+	for _, field := range fields {
+		if field.IsGroup() && len(field.Group) == 0 {
+			continue
+		}
+		if field.IsGroup() && field.Key == "" {
+			comma = writeJsonFields(buf, field.Group, comma)
+			continue
+		}
+
+		if comma {
+			buf.WriteByte(',')
+		}
+		comma = true
+
+		writeJsonString(buf, field.Key)
+		buf.WriteByte(':')
+		if field.IsGroup() {
+			buf.WriteByte('{')
+			writeJsonFields(buf, field.Group, false)
+			buf.WriteByte('}')
+			continue
+		}
+		writeJsonValue(buf, field.Value)
+	}
+
+	return comma
+}
+
+// writeJsonValue writes numbers, booleans and nil as JSON literals, times and errors
+// as strings and other values as encoding/json encodes them.
+func writeJsonValue(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		writeJsonString(buf, v)
+		return
+	case time.Time, error:
+		writeJsonString(buf, FieldValueString(v))
+		return
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			writeJsonString(buf, FieldValueString(v))
+			return
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		writeJsonString(buf, FieldValueString(value))
+		return
+	}
+	buf.Write(encoded)
+}
+
+func writeJsonString(buf *bytes.Buffer, str string) {
+	encoded, _ := json.Marshal(str)
+	buf.Write(encoded)
+}
+
+// findField returns the value of the first field with the given key. Fields nested in
+// groups are addressed by dotted keys (http.method).
+func findField(fields []Field, key string) (interface{}, bool) {
+	for _, field := range fields {
+		if !field.IsGroup() {
+			if field.Key == key {
+				return field.Value, true
+			}
+			continue
+		}
+
+		nestedKey := key
+		if field.Key != "" {
+			if !strings.HasPrefix(key, field.Key+".") {
+				continue
+			}
+			nestedKey = key[len(field.Key)+1:]
+		}
+
+		value, ok := findField(field.Group, nestedKey)
+		if ok {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFieldGroups(t *testing.T) {
+	bytesVerifier, err := newBytesVerifier(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := newTestLogger(t, testLoggerConfig{format: "%Msg %Field(http.method) %Fields", writers: []interface{}{bytesVerifier}})
+
+	bytesVerifier.ExpectBytes([]byte("request GET id=1 http.method=GET http.status=200 user=bob"))
+	logger.Infow("request",
+		Int("id", 1),
+		Group("http", Str("method", "GET"), Int("status", 200), Group("empty")),
+		Group("", Str("user", "bob")))
+	bytesVerifier.MustNotExpect()
+}
+
+func TestJsonFormat(t *testing.T) {
+	context, err := currentContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setContextFields(context, []Field{
+		Str("user", "bob\""),
+		Group("http", Str("method", "GET"), Group("empty"), Group("", Int("status", 200))),
+		Bool("cached", true),
+		Float64("ratio", 0.5),
+		Err("err", errors.New("failed")),
+		Err("noerr", nil),
+	})
+
+	formatter, err := newFormatter("%Json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"time":"` + context.CallTime().Format(time.RFC3339Nano) +
+		`","level":"info","msg":"hi","user":"bob\"","http":{"method":"GET","status":200},` +
+		`"cached":true,"ratio":0.5,"err":"failed","noerr":null}`
+	if msg := formatter.Format("hi", InfoLvl, context); msg != expected {
+		t.Errorf("Expected %s, got %s", expected, msg)
+	}
+}
+
+func TestJsonFormatRecordKeys(t *testing.T) {
+	context, err := currentContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setContextFields(context, []Field{
+		Str("msg", "user"),
+		Group("", Str("level", "high"), Int("n", 1)),
+		Group("http", Str("time", "1ms")),
+	})
+
+	formatter, err := newFormatter("%Json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only keys next to those of the record are prefixed
+	expected := `{"time":"` + context.CallTime().Format(time.RFC3339Nano) +
+		`","level":"info","msg":"hi","fields.msg":"user","fields.level":"high","n":1,"http":{"time":"1ms"}}`
+	if msg := formatter.Format("hi", InfoLvl, context); msg != expected {
+		t.Errorf("Expected %s, got %s", expected, msg)
+	}
+}
+
+func TestFieldValues(t *testing.T) {
+	moment := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	values := []struct {
+		field    Field
+		expected string
+	}{
+		{Any("int8", int8(-3)), "int8=-3"},
+		{Any("uint", uint(3)), "uint=3"},
+		{Any("float", float32(0.5)), "float=0.5"},
+		{Any("duration", time.Second), "duration=1s"},
+		{Time("time", moment), "time=2020-01-02T03:04:05.000000006Z"},
+		{Err("err", nil), "err="},
+	}
+
+	for _, value := range values {
+		if str := value.field.String(); str != value.expected {
+			t.Errorf("Expected %s, got %s", value.expected, str)
+		}
+	}
+	if value, _ := findField([]Field{Any("n", 5)}, "n"); value != int64(5) {
+		t.Errorf("Expected int64 value, got %T", value)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HeartbeatFieldKey is the key of the field with the state of the operation in the
+// records written by LoggerHeartbeat: HeartbeatRunning, HeartbeatDone or
+// HeartbeatDeadlineExceeded. The time since the start is in the HeartbeatElapsedKey
+// field.
+const (
+	HeartbeatFieldKey   = "heartbeat"
+	HeartbeatElapsedKey = "elapsed"
+
+	HeartbeatRunning          = "running"
+	HeartbeatDone             = "done"
+	HeartbeatDeadlineExceeded = "deadline exceeded"
+)
+
+// LoggerHeartbeat writes a progress record with the formatted message to logger every
+// interval until ctx is done, and then a completion record with the total duration, so
+// that long-running operations such as batch jobs show they are alive in a standard
+// way. Records are written at the Info level, except the completion record of an
+// operation whose deadline is exceeded, which is a warning.
+//
+// Example:
+//     ctx, done := context.WithCancel(ctx)
+//     seelog.LoggerHeartbeat(logger, ctx, time.Minute, "syncing shard %d", id)
+//     err := syncShard(id)
+//     done()
+// writes "syncing shard 7 heartbeat=running elapsed=1m0s" every minute and finally
+// "syncing shard 7 heartbeat=done elapsed=2m13.402s" (with a %Msg %Fields format).
+func LoggerHeartbeat(logger LoggerInterface, ctx context.Context, interval time.Duration, format string, params ...interface{}) error {
+	if logger == nil {
+		return errors.New("Logger can not be nil")
+	}
+	return startHeartbeat(ctx, interval, fmt.Sprintf(format, params...), func(level LogLevel, message fmt.Stringer) {
+		logWithLevel(logger, level, message)
+	})
+}
+
+// startHeartbeat starts writing the records of LoggerHeartbeat with write.
+func startHeartbeat(ctx context.Context, interval time.Duration, message string, write func(LogLevel, fmt.Stringer)) error {
+	if ctx == nil {
+		return errors.New("Context can not be nil")
+	}
+	if interval <= 0 {
+		return fmt.Errorf("Heartbeat interval must be positive. Got: %v", interval)
+	}
+
+	go runHeartbeat(ctx, interval, message, time.Now(), write)
+	return nil
+}
+
+func runHeartbeat(ctx context.Context, interval time.Duration, message string, start time.Time, write func(LogLevel, fmt.Stringer)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			write(InfoLvl, heartbeatMessage(message, HeartbeatRunning, start))
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				write(WarnLvl, heartbeatMessage(message, HeartbeatDeadlineExceeded, start))
+			} else {
+				write(InfoLvl, heartbeatMessage(message, HeartbeatDone, start))
+			}
+			return
+		}
+	}
+}
+
+func heartbeatMessage(message string, state string, start time.Time) fmt.Stringer {
+	return newLogFieldsMessage(message, []Field{
+		Str(HeartbeatFieldKey, state),
+		Str(HeartbeatElapsedKey, time.Since(start).Round(time.Millisecond).String()),
+	})
+}
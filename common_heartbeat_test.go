@@ -0,0 +1,108 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type heartbeatRecord struct {
+	level  LogLevel
+	text   string
+	fields []Field
+}
+
+func collectHeartbeat(ctx context.Context, interval time.Duration, stopAfter int, cancel func()) []heartbeatRecord {
+	records := make(chan heartbeatRecord, 16)
+	write := func(level LogLevel, message fmt.Stringer) {
+		records <- heartbeatRecord{level, message.String(), message.(fieldsCarrier).Fields()}
+	}
+	done := make(chan struct{})
+	go func() {
+		runHeartbeat(ctx, interval, "syncing shard 7", time.Now(), write)
+		close(done)
+	}()
+
+	var collected []heartbeatRecord
+	for record := range records {
+		collected = append(collected, record)
+		if len(collected) == stopAfter && cancel != nil {
+			cancel()
+		}
+		if state, _ := findField(record.fields, HeartbeatFieldKey); state != HeartbeatRunning {
+			break
+		}
+	}
+	<-done
+	return collected
+}
+
+func TestHeartbeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	records := collectHeartbeat(ctx, 10*time.Millisecond, 2, cancel)
+
+	// A tick may race with the cancellation
+	if len(records) < 3 {
+		t.Fatalf("Expected 2 progress records and a completion record, got %v", records)
+	}
+	for i, record := range records {
+		state, _ := findField(record.fields, HeartbeatFieldKey)
+		expected := HeartbeatRunning
+		if i == len(records)-1 {
+			expected = HeartbeatDone
+		}
+		if record.text != "syncing shard 7" || record.level != InfoLvl || state != expected {
+			t.Errorf("Unexpected record %d: %v", i, record)
+		}
+		if _, ok := findField(record.fields, HeartbeatElapsedKey); !ok {
+			t.Errorf("Record %d has no elapsed time: %v", i, record)
+		}
+	}
+}
+
+func TestHeartbeatDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	records := collectHeartbeat(ctx, time.Hour, 0, nil)
+
+	if len(records) != 1 || records[0].level != WarnLvl {
+		t.Fatalf("Expected a completion warning, got %v", records)
+	}
+	if state, _ := findField(records[0].fields, HeartbeatFieldKey); state != HeartbeatDeadlineExceeded {
+		t.Errorf("Unexpected state: %v", state)
+	}
+}
+
+func TestHeartbeatErrors(t *testing.T) {
+	if err := LoggerHeartbeat(nil, context.Background(), time.Second, "job"); err == nil {
+		t.Error("Expected error for nil logger")
+	}
+	if err := LoggerHeartbeat(Disabled, context.Background(), 0, "job"); err == nil {
+		t.Error("Expected error for zero interval")
+	}
+}
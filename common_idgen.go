@@ -0,0 +1,270 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RequestIdHeader is the header carrying the request id, see RequestIdHandler.
+const RequestIdHeader = "X-Request-Id"
+
+// RequestIdFieldKey is the key of the request id field added by RequestIdHandler.
+const RequestIdFieldKey = "request_id"
+
+// Names of the built-in id generators
+const (
+	UUIDv7IdGenerator    = "uuidv7"
+	ULIDIdGenerator      = "ulid"
+	SnowflakeIdGenerator = "snowflake"
+)
+
+// An IdGenerator creates unique ids, such as request or correlation ids.
+// It must be safe for concurrent use.
+type IdGenerator interface {
+	NewId() string
+}
+
+// IdGeneratorFunc adapts a func to the IdGenerator interface.
+type IdGeneratorFunc func() string
+
+// NewId calls f.
+func (f IdGeneratorFunc) NewId() string {
+	return f()
+}
+
+var (
+	idGeneratorsMutex  sync.RWMutex
+	defaultIdGenerator = UUIDv7IdGenerator
+	idGenerators       = map[string]IdGenerator{
+		UUIDv7IdGenerator:    IdGeneratorFunc(newUUIDv7),
+		ULIDIdGenerator:      IdGeneratorFunc(newULID),
+		SnowflakeIdGenerator: NewSnowflakeGenerator(hostSnowflakeNode()),
+	}
+)
+
+// RegisterIdGenerator makes an id generator available under the given name, so that it
+// can be used by the %Id verb and NewId. A generator registered under an existing
+// name replaces the previous one.
+//
+// Example:
+//     seelog.RegisterIdGenerator("seq", seelog.IdGeneratorFunc(func() string {
+//         return strconv.FormatUint(atomic.AddUint64(&seq, 1), 10)
+//     }))
+func RegisterIdGenerator(name string, generator IdGenerator) error {
+	if name == "" {
+		return errors.New("Id generator name cannot be empty")
+	}
+	if generator == nil {
+		return errors.New("Id generator cannot be nil")
+	}
+
+	idGeneratorsMutex.Lock()
+	defer idGeneratorsMutex.Unlock()
+	idGenerators[name] = generator
+	return nil
+}
+
+// SetDefaultIdGenerator sets the generator used by the %Id verb without a parameter and
+// by NewId with an empty name. The default is UUIDv7IdGenerator.
+func SetDefaultIdGenerator(name string) error {
+	idGeneratorsMutex.Lock()
+	defer idGeneratorsMutex.Unlock()
+
+	if _, ok := idGenerators[name]; !ok {
+		return errors.New("Unknown id generator: " + name)
+	}
+	defaultIdGenerator = name
+	return nil
+}
+
+// NewId creates an id with the generator registered under the given name,
+// or with the default generator if name is empty.
+func NewId(name string) (string, error) {
+	idGeneratorsMutex.RLock()
+	if name == "" {
+		name = defaultIdGenerator
+	}
+	generator, ok := idGenerators[name]
+	idGeneratorsMutex.RUnlock()
+
+	if !ok {
+		return "", errors.New("Unknown id generator: " + name)
+	}
+	return generator.NewId(), nil
+}
+
+// newUUIDv7 creates a UUID version 7 (RFC 9562): a millisecond timestamp followed by random bits.
+func newUUIDv7() string {
+	var uuid [16]byte
+	rand.Read(uuid[6:])
+
+	ms := uint64(time.Now().UnixMilli())
+	uuid[0] = byte(ms >> 40)
+	uuid[1] = byte(ms >> 32)
+	uuid[2] = byte(ms >> 24)
+	uuid[3] = byte(ms >> 16)
+	uuid[4] = byte(ms >> 8)
+	uuid[5] = byte(ms)
+	uuid[6] = uuid[6]&0x0f | 0x70 // Version 7
+	uuid[8] = uuid[8]&0x3f | 0x80 // Variant 10
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+	return string(buf[:])
+}
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID creates a ULID: a 48 bit millisecond timestamp and 80 random bits encoded
+// as 26 characters of Crockford's base32, so that ids sort by creation time.
+func newULID() string {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[0:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(id[6:])
+
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+
+	// 128 bits are encoded as 26 groups of 5 bits, the first group has only 3 bits.
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockfordBase32[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+// Layout of snowflake ids: 41 bits of milliseconds since snowflakeEpoch,
+// 10 bits of the node and 12 bits of the sequence within a millisecond.
+const (
+	snowflakeEpoch        = 1288834974657 // Unix milliseconds of 2010-11-04T01:42:54.657Z
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	snowflakeMaxNode      = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSequence  = 1<<snowflakeSequenceBits - 1
+)
+
+type snowflakeGenerator struct {
+	mutex    sync.Mutex
+	node     int64
+	lastMs   int64
+	sequence int64
+}
+
+// NewSnowflakeGenerator creates a generator of decimal snowflake ids for the given node,
+// which must be between 0 and 1023 and distinct for all processes creating ids. The
+// generator registered as SnowflakeIdGenerator uses a node derived from the host name.
+func NewSnowflakeGenerator(node int64) IdGenerator {
+	return &snowflakeGenerator{node: node & snowflakeMaxNode}
+}
+
+func (generator *snowflakeGenerator) NewId() string {
+	generator.mutex.Lock()
+	defer generator.mutex.Unlock()
+
+	ms := time.Now().UnixMilli() - snowflakeEpoch
+	if ms < generator.lastMs {
+		// The clock went back; keep the ids increasing
+		ms = generator.lastMs
+	}
+
+	if ms == generator.lastMs {
+		generator.sequence = (generator.sequence + 1) & snowflakeMaxSequence
+		if generator.sequence == 0 {
+			// The sequence is exhausted, wait for the next millisecond
+			for ms <= generator.lastMs {
+				time.Sleep(100 * time.Microsecond)
+				ms = time.Now().UnixMilli() - snowflakeEpoch
+			}
+		}
+	} else {
+		generator.sequence = 0
+	}
+	generator.lastMs = ms
+
+	id := ms<<(snowflakeNodeBits+snowflakeSequenceBits) | generator.node<<snowflakeSequenceBits | generator.sequence
+	return strconv.FormatInt(id, 10)
+}
+
+func hostSnowflakeNode() int64 {
+	hostname, _ := os.Hostname()
+	hash := fnv.New32a()
+	hash.Write([]byte(hostname))
+	hash.Write([]byte(strconv.Itoa(os.Getpid())))
+	return int64(hash.Sum32() & snowflakeMaxNode)
+}
+
+// RequestIdHandler passes requests to next with the trace context fields (see ContextFromRequest)
+// and a request id field stored in the request context. The id is taken from the
+// X-Request-Id header of the request, or created by the named generator (the default one
+// if name is empty) and set as the response header.
+//
+// Example:
+//     http.Handle("/", seelog.RequestIdHandler(handler, seelog.ULIDIdGenerator))
+//     ...
+//     func handler(w http.ResponseWriter, r *http.Request) {
+//         logger := log.LoggerWithContext(log.CurrentLogger(), r.Context())
+//         logger.Info("handling request")
+//     }
+func RequestIdHandler(next http.Handler, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIdHeader)
+		if id == "" {
+			id, _ = NewId(name)
+			w.Header().Set(RequestIdHeader, id)
+		}
+
+		ctx := ContextWithFields(ContextFromRequest(r), Str(RequestIdFieldKey, id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// createIdVerbFunc creates a verb which writes a new id for every message, created by the
+// generator with the given name. Unknown generators write an empty string.
+func createIdVerbFunc(name string) (verbFunc, error) {
+	return func(message string, level LogLevel, context logContextInterface) interface{} {
+		id, _ := NewId(name)
+		return id
+	}, nil
+}
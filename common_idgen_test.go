@@ -0,0 +1,140 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var idFormats = map[string]*regexp.Regexp{
+	UUIDv7IdGenerator:    regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+	ULIDIdGenerator:      regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`),
+	SnowflakeIdGenerator: regexp.MustCompile(`^[1-9][0-9]{17,18}$`),
+}
+
+func TestBuiltinIdGenerators(t *testing.T) {
+	for name, format := range idFormats {
+		seen := make(map[string]bool)
+		previous := ""
+		for i := 0; i < 5000; i++ {
+			id, err := NewId(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !format.MatchString(id) {
+				t.Fatalf("%s: invalid id %s", name, id)
+			}
+			if seen[id] {
+				t.Fatalf("%s: duplicate id %s", name, id)
+			}
+			seen[id] = true
+
+			if name == SnowflakeIdGenerator && previous != "" {
+				current, _ := strconv.ParseInt(id, 10, 64)
+				last, _ := strconv.ParseInt(previous, 10, 64)
+				if current <= last {
+					t.Fatalf("Snowflake ids must increase: %s after %s", id, previous)
+				}
+			}
+			previous = id
+		}
+	}
+
+	if _, err := NewId("unknown"); err == nil {
+		t.Error("Expected error for an unknown generator")
+	}
+}
+
+func TestIdVerbAndRegistry(t *testing.T) {
+	defer SetDefaultIdGenerator(UUIDv7IdGenerator)
+
+	counter := 0
+	err := RegisterIdGenerator("test-seq", IdGeneratorFunc(func() string {
+		counter++
+		return "id" + strconv.Itoa(counter)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = RegisterIdGenerator("", IdGeneratorFunc(func() string { return "" })); err == nil {
+		t.Error("Expected error for an empty name")
+	}
+
+	formatter, err := newFormatter("%Id(test-seq) %Msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := &logContext{}
+	for _, expected := range []string{"id1 a", "id2 a"} {
+		if got := formatter.Format("a", InfoLvl, context); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	}
+
+	if err = SetDefaultIdGenerator("test-seq"); err != nil {
+		t.Fatal(err)
+	}
+	if err = SetDefaultIdGenerator("unknown"); err == nil {
+		t.Error("Expected error for an unknown default generator")
+	}
+	formatter, _ = newFormatter("%Id")
+	if got := formatter.Format("", InfoLvl, context); got != "id3" {
+		t.Errorf("Expected the default generator, got %q", got)
+	}
+}
+
+func TestRequestIdHandler(t *testing.T) {
+	var fields []Field
+	handler := RequestIdHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields = FieldsFromContext(r.Context())
+	}), ULIDIdGenerator)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	id := w.Header().Get(RequestIdHeader)
+	if !idFormats[ULIDIdGenerator].MatchString(id) {
+		t.Errorf("Expected a generated ULID, got %q", id)
+	}
+	if value, _ := findField(fields, RequestIdFieldKey); value != id {
+		t.Errorf("Expected request id field %s, got %v", id, fields)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(RequestIdHeader, "incoming")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if value, _ := findField(fields, RequestIdFieldKey); value != "incoming" {
+		t.Errorf("Expected the incoming request id, got %v", fields)
+	}
+	if w.Header().Get(RequestIdHeader) != "" {
+		t.Error("Incoming request id must not be set in the response")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// levelBoost temporarily allows messages at and above a level regardless of the
+// configured constraints. The boost decays lazily: it is ignored once its deadline
+// has passed, so no timer goroutine is needed.
+type levelBoost struct {
+	level    int32 // LogLevel
+	deadline int64 // Unix nanoseconds; 0 if there is no boost
+}
+
+func (boost *levelBoost) set(level LogLevel, duration time.Duration) {
+	if duration <= 0 {
+		atomic.StoreInt64(&boost.deadline, 0)
+		return
+	}
+
+	// Disable the boost while the level is updated.
+	atomic.StoreInt64(&boost.deadline, 0)
+	atomic.StoreInt32(&boost.level, int32(level))
+	atomic.StoreInt64(&boost.deadline, time.Now().Add(duration).UnixNano())
+}
+
+// allows returns true if level is boosted at time t.
+func (boost *levelBoost) allows(level LogLevel, t time.Time) bool {
+	deadline := atomic.LoadInt64(&boost.deadline)
+	if deadline == 0 {
+		return false
+	}
+	if t.UnixNano() >= deadline {
+		atomic.CompareAndSwapInt64(&boost.deadline, deadline, 0)
+		return false
+	}
+
+	return level.severity() >= LogLevel(atomic.LoadInt32(&boost.level)).severity()
+}
+
+// allowsNow acts as allows at the current time, but reads the clock only while a boost is
+// set, so that calls at levels which are never written stay cheap.
+func (boost *levelBoost) allowsNow(level LogLevel) bool {
+	if atomic.LoadInt64(&boost.deadline) == 0 {
+		return false
+	}
+	return boost.allows(level, time.Now())
+}
+
+// levelBooster is implemented by loggers which support BoostLoggerLevel.
+type levelBooster interface {
+	boostLevel(level LogLevel, duration time.Duration)
+}
+
+func (cLogger *commonLogger) boostLevel(level LogLevel, duration time.Duration) {
+	cLogger.boost.set(level, duration)
+}
+
+func (fLogger *fieldsLogger) boostLevel(level LogLevel, duration time.Duration) {
+	if booster, ok := fLogger.logger.(levelBooster); ok {
+		booster.boostLevel(level, duration)
+	}
+}
+
+// BoostLoggerLevel makes the logger write messages at the specified level and above
+// for the given duration, even if the config or its exceptions filter them out.
+// After the duration passes, the logger returns to the configured levels on its own,
+// so a debug boost can not be forgotten. A new boost replaces the previous one;
+// a non-positive duration cancels the current boost.
+//
+// Example:
+//     seelog.BoostLoggerLevel(logger, seelog.DebugLvl, 10*time.Minute)
+func BoostLoggerLevel(logger LoggerInterface, level LogLevel, duration time.Duration) error {
+	if level < TraceLvl || level > CriticalLvl {
+		return fmt.Errorf("Boost level must be between Trace and Critical. Got: %d", level)
+	}
+
+	booster, ok := logger.(levelBooster)
+	if !ok {
+		return errors.New("Logger does not support level boosting")
+	}
+
+	booster.boostLevel(level, duration)
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoostLevel(t *testing.T) {
+	bytesVerifier, err := newBytesVerifier(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := newTestLogger(t, testLoggerConfig{minLevel: InfoLvl, format: "%Msg", writers: []interface{}{bytesVerifier}})
+	fieldsLogger := LoggerWithFields(logger, Str("k", "v"))
+
+	logger.Debug("filtered")
+	bytesVerifier.MustNotExpect()
+
+	if err := BoostLoggerLevel(fieldsLogger, DebugLvl, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	bytesVerifier.ExpectBytes([]byte("boosted"))
+	logger.Debug("boosted")
+	bytesVerifier.MustNotExpect()
+
+	logger.Trace("below boost")
+	bytesVerifier.MustNotExpect()
+
+	if err := BoostLoggerLevel(logger, DebugLvl, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	logger.Debug("decayed")
+	bytesVerifier.MustNotExpect()
+
+	if err := BoostLoggerLevel(logger, Off, time.Hour); err == nil {
+		t.Error("Expected error for Off level")
+	}
+}
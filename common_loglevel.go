@@ -24,6 +24,14 @@
 
 package seelog
 
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
 // Log level type
 type LogLevel uint8
 
@@ -66,6 +74,11 @@ func LogLevelFromString(levelStr string) (level LogLevel, found bool) {
 			return lvl, true
 		}
 	}
+	for lvl, custom := range loadCustomLevels() {
+		if custom.name == levelStr {
+			return lvl, true
+		}
+	}
 
 	return 0, false
 }
@@ -76,6 +89,282 @@ func (level LogLevel) String() string {
 	if ok {
 		return levelStr
 	}
+	if custom, ok := loadCustomLevels()[level]; ok {
+		return custom.name
+	}
 
 	return ""
 }
+
+//=======================================================
+
+// Custom levels are ranked between the built-in levels: the severity of a built-in
+// level is its value times levelSeverityStep, a custom level above it gets one of the
+// severities up to the next built-in level. Levels declared in configs rank above the
+// levels registered with RegisterLevel above the same built-in level.
+const (
+	levelSeverityStep = 32
+	maxLevelRank      = 15 // Registered or declared custom levels above one built-in level
+)
+
+// Levels declared in configs have values from declaredLevelBase on, which depend on the
+// built-in level they are declared above and their rank only, so that configs declaring
+// the same levels get the same values.
+const declaredLevelBase = 0x80
+
+// customLevel is a level defined with RegisterLevel or declared in a config.
+type customLevel struct {
+	name     string   // Name used in configs, e.g. "notice"
+	title    string   // Name written by %Level, e.g. "Notice"
+	above    LogLevel // Built-in level right below the custom level
+	severity int
+}
+
+func newCustomLevel(name string, above LogLevel, severity int) *customLevel {
+	return &customLevel{
+		name:     name,
+		title:    strings.ToUpper(name[:1]) + name[1:],
+		above:    above,
+		severity: severity,
+	}
+}
+
+// levelSet holds custom levels by value.
+type levelSet map[LogLevel]*customLevel
+
+var (
+	customLevelsMutex sync.Mutex   // Serializes changes of the custom levels
+	registeredLevels  atomic.Value // levelSet of RegisterLevel, replaced by every registration
+	customLevels      atomic.Value // levelSet of the registered levels and the active declared levels
+	activeLevels      levelSet     // Levels declared by the config of the last created logger which declared levels
+)
+
+func loadRegisteredLevels() levelSet {
+	levels, _ := registeredLevels.Load().(levelSet)
+	return levels
+}
+
+func loadCustomLevels() levelSet {
+	levels, _ := customLevels.Load().(levelSet)
+	return levels
+}
+
+// storeCustomLevels publishes the registered levels and the active declared levels.
+// Call it with customLevelsMutex locked.
+func storeCustomLevels(registered levelSet) {
+	all := make(levelSet, len(registered)+len(activeLevels))
+	for level, custom := range registered {
+		all[level] = custom
+	}
+	for level, custom := range activeLevels {
+		all[level] = custom
+	}
+	registeredLevels.Store(registered)
+	customLevels.Store(all)
+}
+
+// RegisterLevel defines a custom level, more severe than the built-in level 'above' and
+// less severe than the next built-in level. Levels registered above the same built-in
+// level are ranked in the order of registration, the last one being the most severe.
+// Registering a level again above the same built-in level returns the registered one.
+//
+// Custom levels are used in configs like built-in ones, are written by %Level, %LEVEL,
+// %Lev, %LEV and %l, and are logged with Logf. Levels above critical pass the default
+// maxlevel, which is critical. Names consist of lowercase letters, digits and '_'.
+// Register levels before creating the loggers which use them.
+//
+// Example:
+//     var Audit, _ = log.RegisterLevel("audit", log.CriticalLvl)
+//     ...
+//     log.Logf(Audit, "User %s logged in", user)
+//
+// Configs can declare custom levels of their own, which are not registered. They become
+// known to LogLevelFromString when a logger is created from the config, replacing the
+// levels declared by the config of the previously created logger:
+//     <seelog minlevel="notice">
+//         <customlevels>
+//             <level name="notice" above="info"/>
+//         </customlevels>
+//         ...
+//
+//     notice, _ := log.LogLevelFromString("notice")
+func RegisterLevel(name string, above LogLevel) (LogLevel, error) {
+	if err := checkLevelAbove(above); err != nil {
+		return 0, err
+	}
+	if err := checkLevelName(name); err != nil {
+		return 0, err
+	}
+
+	customLevelsMutex.Lock()
+	defer customLevelsMutex.Unlock()
+
+	levels := loadRegisteredLevels()
+	rank := 1
+	for level, custom := range levels {
+		if custom.name == name {
+			if custom.above != above {
+				return 0, errors.New("Level '" + name + "' is already registered above " + custom.above.String())
+			}
+			return level, nil
+		}
+		if custom.above == above {
+			rank++
+		}
+	}
+	if rank > maxLevelRank {
+		return 0, errors.New("Too many custom levels above " + above.String())
+	}
+
+	newLevels := make(levelSet, len(levels)+1)
+	for level, custom := range levels {
+		newLevels[level] = custom
+	}
+	// There are at most maxLevelRank levels above each built-in level, so the values
+	// stay below declaredLevelBase
+	level := LogLevel(int(Off) + len(levels) + 1)
+	newLevels[level] = newCustomLevel(name, above, int(above)*levelSeverityStep+rank)
+	storeCustomLevels(newLevels)
+
+	return level, nil
+}
+
+// declareLevel adds a level declared in a config to the declared levels of the config.
+// Names of registered levels are declared again only above the same built-in level,
+// which gives the registered level.
+func (levels levelSet) declareLevel(name string, above LogLevel) (LogLevel, error) {
+	if err := checkLevelAbove(above); err != nil {
+		return 0, err
+	}
+	if err := checkLevelName(name); err != nil {
+		return 0, err
+	}
+
+	for level, custom := range loadRegisteredLevels() {
+		if custom.name == name {
+			if custom.above != above {
+				return 0, errors.New("Level '" + name + "' is already registered above " + custom.above.String())
+			}
+			return level, nil
+		}
+	}
+
+	rank := 1
+	for _, custom := range levels {
+		if custom.name == name {
+			return 0, errors.New("Level '" + name + "' is declared twice")
+		}
+		if custom.above == above {
+			rank++
+		}
+	}
+	if rank > maxLevelRank {
+		return 0, errors.New("Too many custom levels above " + above.String())
+	}
+
+	level := LogLevel(declaredLevelBase + int(above)<<4 + rank)
+	levels[level] = newCustomLevel(name, above, int(above)*levelSeverityStep+maxLevelRank+rank)
+	return level, nil
+}
+
+// fromString finds built-in levels, registered levels and the levels of the set, but not
+// the levels declared by other configs.
+func (levels levelSet) fromString(name string) (LogLevel, bool) {
+	for lvl, lvlStr := range levelToStringRepresentations {
+		if lvlStr == name {
+			return lvl, true
+		}
+	}
+	for _, set := range []levelSet{loadRegisteredLevels(), levels} {
+		for lvl, custom := range set {
+			if custom.name == name {
+				return lvl, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// name returns the name of the level, which is looked up in the set before the active
+// levels.
+func (levels levelSet) name(level LogLevel) string {
+	if custom, ok := levels[level]; ok {
+		return custom.name
+	}
+	return level.String()
+}
+
+// activateLevels makes the levels declared by a config known by name, replacing the
+// levels declared by the config activated before. Sets without levels are ignored, so
+// that loggers created from configs without custom levels keep the active ones.
+func activateLevels(levels levelSet) {
+	if len(levels) == 0 {
+		return
+	}
+
+	customLevelsMutex.Lock()
+	defer customLevelsMutex.Unlock()
+
+	activeLevels = levels
+	storeCustomLevels(loadRegisteredLevels())
+}
+
+func checkLevelAbove(above LogLevel) error {
+	if above < TraceLvl || above > CriticalLvl {
+		return errors.New("Custom level must be registered above a level between Trace and Critical. Got: " + above.String())
+	}
+	return nil
+}
+
+func checkLevelName(name string) error {
+	if name == "" {
+		return errors.New("Level name can not be empty")
+	}
+	for _, builtIn := range levelToStringRepresentations {
+		if builtIn == name {
+			return errors.New("Level '" + name + "' is a built-in level")
+		}
+	}
+	for i, r := range name {
+		if (r < 'a' || r > 'z') && r != '_' && (i == 0 || r < '0' || r > '9') {
+			return errors.New("Invalid level name '" + name + "': expected lowercase letters, digits and '_'")
+		}
+	}
+	return nil
+}
+
+// severity returns the rank of the level among all levels, or -1 for values which
+// are not levels.
+func (level LogLevel) severity() int {
+	if level <= Off {
+		return int(level) * levelSeverityStep
+	}
+	if custom, ok := loadCustomLevels()[level]; ok {
+		return custom.severity
+	}
+	return -1
+}
+
+// severity returns the severity of the level, which is looked up in the set before the
+// active levels.
+func (levels levelSet) severity(level LogLevel) int {
+	if custom, ok := levels[level]; ok {
+		return custom.severity
+	}
+	return level.severity()
+}
+
+// definedLevels returns the built-in levels from Trace to Critical, the registered
+// levels and the levels of the set, ordered by severity.
+func (levels levelSet) definedLevels() []LogLevel {
+	defined := []LogLevel{TraceLvl, DebugLvl, InfoLvl, WarnLvl, ErrorLvl, CriticalLvl}
+	for _, set := range []levelSet{loadRegisteredLevels(), levels} {
+		for level := range set {
+			defined = append(defined, level)
+		}
+	}
+	sort.Slice(defined, func(i, j int) bool {
+		return levels.severity(defined[i]) < levels.severity(defined[j])
+	})
+	return defined
+}
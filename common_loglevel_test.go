@@ -0,0 +1,255 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resetCustomLevels removes the registered levels and the active levels declared by
+// configs. Tests which register levels or declare them in configs call it from t.Cleanup.
+func resetCustomLevels() {
+	customLevelsMutex.Lock()
+	defer customLevelsMutex.Unlock()
+
+	activeLevels = nil
+	storeCustomLevels(nil)
+}
+
+func TestRegisterLevel(t *testing.T) {
+	t.Cleanup(resetCustomLevels)
+
+	first, err := RegisterLevel("reglevel_first", WarnLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RegisterLevel("reglevel_second", WarnLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warn, errorLevel := LogLevel(WarnLvl).severity(), LogLevel(ErrorLvl).severity()
+	if !(warn < first.severity() && first.severity() < second.severity() && second.severity() < errorLevel) {
+		t.Errorf("Custom levels must be ordered between warn and error: %d %d %d %d",
+			warn, first.severity(), second.severity(), errorLevel)
+	}
+	if first.String() != "reglevel_first" {
+		t.Errorf("Unexpected name: %q", first.String())
+	}
+	if level, found := LogLevelFromString("reglevel_second"); !found || level != second {
+		t.Errorf("Level not found by name: %d %v", level, found)
+	}
+
+	again, err := RegisterLevel("reglevel_first", WarnLvl)
+	if err != nil || again != first {
+		t.Errorf("Registering the same level again must return it: %d %v", again, err)
+	}
+	if _, err := RegisterLevel("reglevel_first", InfoLvl); err == nil {
+		t.Error("Level registered above another level must be rejected")
+	}
+
+	invalid := []struct {
+		name  string
+		above LogLevel
+	}{
+		{"", InfoLvl},
+		{"info", InfoLvl},
+		{"Notice", InfoLvl},
+		{"1st", InfoLvl},
+		{"a,b", InfoLvl},
+		{"reglevel_off", Off},
+	}
+	for _, test := range invalid {
+		if _, err := RegisterLevel(test.name, test.above); err == nil {
+			t.Errorf("Level %q above %s must be rejected", test.name, test.above)
+		}
+	}
+}
+
+func TestCustomLevelConfig(t *testing.T) {
+	t.Cleanup(resetCustomLevels)
+
+	fileName := filepath.Join(t.TempDir(), "app.log")
+	logger, err := LoggerFromConfigAsString(`
+<seelog type="sync" minlevel="cfglevel_notice">
+	<customlevels>
+		<level name="cfglevel_notice" above="info"/>
+		<level name="cfglevel_audit" above="critical"/>
+	</customlevels>
+	<outputs formatid="levels">
+		<file path="` + fileName + `"/>
+		<filter levels="cfglevel_audit" formatid="levels">
+			<file path="` + fileName + `"/>
+		</filter>
+	</outputs>
+	<formats>
+		<format id="levels" format="%Level %LEVEL %Lev %l: %Msg%n"/>
+	</formats>
+</seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notice, _ := LogLevelFromString("cfglevel_notice")
+	audit, _ := LogLevelFromString("cfglevel_audit")
+	structured := logger.(StructuredLogger)
+	structured.Info("dropped")
+	structured.Logf(notice, "user %s", "joe")
+	structured.Warn("disk")
+	structured.Logf(audit, "login")
+	logger.Close()
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Cfglevel_notice CFGLEVEL_NOTICE Cfg c: user joe\n" +
+		"Warn WARN Wrn w: disk\n" +
+		"Cfglevel_audit CFGLEVEL_AUDIT Cfg c: login\n" +
+		"Cfglevel_audit CFGLEVEL_AUDIT Cfg c: login\n"
+	if string(data) != expected {
+		t.Errorf("Unexpected log:\n%s", data)
+	}
+}
+
+func TestCustomLevelConstraints(t *testing.T) {
+	t.Cleanup(resetCustomLevels)
+
+	audit, err := RegisterLevel("constraint_audit", CriticalLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaultMax, err := newMinMaxConstraints(InfoLvl, CriticalLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !defaultMax.IsAllowed(audit) {
+		t.Error("Max level critical must allow levels above critical")
+	}
+
+	onlyErrors, err := newMinMaxConstraints(ErrorLvl, ErrorLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onlyErrors.IsAllowed(audit) || onlyErrors.IsAllowed(LogLevel(250)) {
+		t.Error("Levels above max and undefined levels must not be allowed")
+	}
+	if _, err := newMinMaxConstraints(audit, ErrorLvl); err == nil {
+		t.Error("Min level above max level must be rejected")
+	}
+}
+
+func TestCustomLevelReload(t *testing.T) {
+	t.Cleanup(resetCustomLevels)
+
+	config := `
+<seelog type="sync" minlevel="cfglevel_notice">
+	<customlevels>
+		<level name="cfglevel_notice" above="%s"/>
+	</customlevels>
+	<outputs><console/></outputs>
+</seelog>`
+
+	first, err := LoggerFromConfigAsString(strings.Replace(config, "%s", "info", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := LoggerFromConfigAsString(strings.Replace(config, "%s", "warn", 1))
+	if err != nil {
+		t.Fatal("A config may declare a level of another config above another level:", err)
+	}
+	defer second.Close()
+
+	notice, found := LogLevelFromString("cfglevel_notice")
+	if !found {
+		t.Fatal("Level of the last created logger not found")
+	}
+	if severity := notice.severity(); severity <= LogLevel(WarnLvl).severity() || severity >= LogLevel(ErrorLvl).severity() {
+		t.Errorf("Level must be ranked between warn and error: %d", severity)
+	}
+}
+
+func TestCustomLevelsNotRegisteredByChecks(t *testing.T) {
+	t.Cleanup(resetCustomLevels)
+
+	config := []byte(`
+<seelog minlevel="cfglevel_check">
+	<customlevels>
+		<level name="cfglevel_check" above="error"/>
+	</customlevels>
+	<outputs><console/></outputs>
+</seelog>`)
+
+	issues, err := ValidateConfig(config)
+	if err != nil || len(issues) != 0 {
+		t.Fatalf("Unexpected validation result: %v %v", issues, err)
+	}
+	description, err := DescribeConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(description.String(), "levels: cfglevel_check,critical\n") {
+		t.Errorf("Declared level not described:\n%s", description)
+	}
+
+	if _, found := LogLevelFromString("cfglevel_check"); found {
+		t.Error("Validating and describing a config must not make its levels known")
+	}
+	for _, level := range levelSet(nil).definedLevels() {
+		if level.String() == "cfglevel_check" {
+			t.Error("Validating and describing a config must not define its levels")
+		}
+	}
+}
+
+// plainLogger implements only LoggerInterface, as loggers outside the package do.
+type plainLogger struct {
+	LoggerInterface
+}
+
+func TestCustomLevelWithPlainLogger(t *testing.T) {
+	t.Cleanup(resetCustomLevels)
+
+	notice, err := RegisterLevel("plain_notice", WarnLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := new(bytes.Buffer)
+	logger := plainLogger{newTestLogger(t, testLoggerConfig{format: "%Level %Msg %Field(k)%n", writers: []interface{}{output}})}
+
+	LoggerWithFields(logger, Str("k", "v")).Logf(notice, "login %d", 1)
+	LoggerWithFields(logger).Logf(DebugLvl, "debug")
+
+	expected := "Warn login 1 v\nDebug debug \n"
+	if output.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, output.String())
+	}
+}
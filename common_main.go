@@ -0,0 +1,143 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// PanicExitCode is the exit code used by Main when run panics, the same as the one
+// of an unrecovered panic.
+const PanicExitCode = 2
+
+// CrashMarkerPath is the file Main writes a crash marker to when run panics. The
+// marker is a JSON object with the time, the panic value, the stack and the last
+// Critical record. Empty disables the marker. By default it is <program name>.crash
+// in the temporary directory.
+var CrashMarkerPath = filepath.Join(os.TempDir(), filepath.Base(os.Args[0])+".crash")
+
+// exitFunc is replaced in tests.
+var exitFunc = os.Exit
+
+// criticalRecord is the last message logged with level Critical.
+type criticalRecord struct {
+	time    time.Time
+	message fmt.Stringer
+}
+
+var lastCritical atomic.Value // criticalRecord
+
+func rememberCritical(message fmt.Stringer) {
+	lastCritical.Store(criticalRecord{time.Now(), message})
+}
+
+// crashMarker is the content of the crash marker file.
+type crashMarker struct {
+	Time         time.Time `json:"time"`
+	ExitCode     int       `json:"exit_code"`
+	Panic        string    `json:"panic"`
+	Stack        string    `json:"stack"`
+	LastCritical *crashMarkerRecord `json:"last_critical,omitempty"`
+}
+
+type crashMarkerRecord struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"msg"`
+}
+
+// Main runs the main function of a program and exits with the code it returns. Whichever
+// way run finishes, the default logger is flushed and closed before the exit. If run
+// panics, the panic is logged as Critical with its stack, a crash marker is written to
+// CrashMarkerPath and the program exits with PanicExitCode.
+//
+// Example:
+//     func main() {
+//         seelog.Main(func() int {
+//             ...
+//             return 0
+//         })
+//     }
+//
+// Use Exit instead of os.Exit inside run, as os.Exit skips flushing.
+func Main(run func() int) {
+	exitFunc(runMain(run))
+}
+
+func runMain(run func() int) (code int) {
+	defer func() {
+		err := recover()
+		if err == nil {
+			stdFacade.flushAndClose()
+			return
+		}
+
+		code = PanicExitCode
+		stack := string(debug.Stack())
+
+		// The marker is written first, so that it holds the last Critical record
+		// logged before the panic
+		if markerErr := writeCrashMarker(fmt.Sprint(err), stack, code); markerErr != nil {
+			reportInternalError(markerErr)
+		}
+
+		stdFacade.Criticalf("Panic: %v\n%s", err, stack)
+
+		stdFacade.flushAndClose()
+	}()
+
+	return run()
+}
+
+// Exit flushes and closes the default logger and exits the program with the given code.
+// Use it instead of os.Exit to not lose queued messages.
+func Exit(code int) {
+	stdFacade.flushAndClose()
+	exitFunc(code)
+}
+
+func writeCrashMarker(panicValue string, stack string, code int) error {
+	if CrashMarkerPath == "" {
+		return nil
+	}
+
+	marker := crashMarker{Time: time.Now(), ExitCode: code, Panic: panicValue, Stack: stack}
+	if record, ok := lastCritical.Load().(criticalRecord); ok {
+		marker.LastCritical = &crashMarkerRecord{record.time, record.message.String()}
+	}
+
+	data, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(CrashMarkerPath, data, defaultFilePermissions)
+}
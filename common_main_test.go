@@ -0,0 +1,109 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withTestMain replaces the default logger, the crash marker path and the exit func
+// for the duration of f.
+func withTestMain(t *testing.T, f func(output *bytes.Buffer, exitCode *int)) {
+	output := new(bytes.Buffer)
+	logger, err := LoggerFromWriterWithMinLevel(output, TraceLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "seelog-main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	exitCode := -1
+	oldCurrent, oldMarker, oldExit := CurrentLogger(), CrashMarkerPath, exitFunc
+	UseLogger(logger)
+	CrashMarkerPath, exitFunc = filepath.Join(dir, "test.crash"), func(code int) { exitCode = code }
+	defer func() {
+		UseLogger(oldCurrent)
+		CrashMarkerPath, exitFunc = oldMarker, oldExit
+	}()
+
+	f(output, &exitCode)
+}
+
+func TestMainPanic(t *testing.T) {
+	withTestMain(t, func(output *bytes.Buffer, exitCode *int) {
+		Main(func() int {
+			Critical("database is down")
+			panic("boom")
+		})
+
+		if *exitCode != PanicExitCode {
+			t.Errorf("Expected exit code %d, got %d", PanicExitCode, *exitCode)
+		}
+		if !strings.Contains(output.String(), "Panic: boom") {
+			t.Errorf("Panic is not logged: %s", output.String())
+		}
+
+		data, err := ioutil.ReadFile(CrashMarkerPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var marker crashMarker
+		if err := json.Unmarshal(data, &marker); err != nil {
+			t.Fatal(err)
+		}
+		if marker.Panic != "boom" || marker.LastCritical == nil || marker.LastCritical.Message != "database is down" {
+			t.Errorf("Unexpected crash marker: %s", data)
+		}
+	})
+}
+
+func TestMainExitCode(t *testing.T) {
+	withTestMain(t, func(output *bytes.Buffer, exitCode *int) {
+		Main(func() int {
+			Info("working")
+			return 3
+		})
+
+		if *exitCode != 3 {
+			t.Errorf("Expected exit code 3, got %d", *exitCode)
+		}
+		if !strings.Contains(output.String(), "working") {
+			t.Errorf("Logger must be flushed: %s", output.String())
+		}
+		if _, err := os.Stat(CrashMarkerPath); !os.IsNotExist(err) {
+			t.Error("Crash marker must not be written without a panic")
+		}
+	})
+}
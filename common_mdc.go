@@ -0,0 +1,191 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Goroutine-local diagnostic context (MDC). Fields pushed with PushContext are
+// attached to every message logged from the same goroutine until they are popped.
+// A goroutine which exits with pushed frames leaves them in the store, and log calls
+// keep paying for the goroutine id lookup, so always defer the func PushContext returns.
+//
+// Example:
+//     func handler(w http.ResponseWriter, r *http.Request) {
+//         defer seelog.PushContext(seelog.Str("request_id", r.Header.Get("X-Request-Id")))()
+//         ...
+//         seelog.GoWithContext(func() { seelog.Info("background work") })
+//     }
+
+var (
+	mdcMutex sync.RWMutex
+	mdcStore = make(map[uint64][][]Field)
+
+	// Number of goroutines with a non-empty diagnostic context. Lets log calls skip
+	// the goroutine id lookup while the MDC is not in use.
+	mdcActive int32
+)
+
+// PushContext pushes a frame of fields to the diagnostic context of the current
+// goroutine. Every message logged from this goroutine includes the fields of all
+// pushed frames, in push order, until the frame is removed. The returned func
+// removes the frame, with the frames pushed after it which are still there; it must
+// be deferred, so that the context is removed even if the goroutine panics. Calls
+// after the first do nothing.
+func PushContext(fields ...Field) (pop func()) {
+	id := goroutineId()
+
+	mdcMutex.Lock()
+	defer mdcMutex.Unlock()
+
+	frames, ok := mdcStore[id]
+	if !ok {
+		atomic.AddInt32(&mdcActive, 1)
+	}
+	mdcStore[id] = append(frames, fields)
+
+	depth := len(frames)
+	var popped int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&popped, 0, 1) {
+			truncateContext(id, depth)
+		}
+	}
+}
+
+// PopContext removes the most recently pushed frame from the diagnostic context
+// of the current goroutine. It does nothing if the context is empty. Prefer the
+// func returned by PushContext.
+func PopContext() {
+	id := goroutineId()
+
+	mdcMutex.Lock()
+	defer mdcMutex.Unlock()
+
+	if frames, ok := mdcStore[id]; ok {
+		truncateContextLocked(id, len(frames)-1)
+	}
+}
+
+// truncateContext keeps the first depth frames of the diagnostic context of the
+// goroutine with the given id.
+func truncateContext(id uint64, depth int) {
+	mdcMutex.Lock()
+	defer mdcMutex.Unlock()
+
+	truncateContextLocked(id, depth)
+}
+
+func truncateContextLocked(id uint64, depth int) {
+	frames, ok := mdcStore[id]
+	if !ok || len(frames) <= depth {
+		return
+	}
+	if depth == 0 {
+		delete(mdcStore, id)
+		atomic.AddInt32(&mdcActive, -1)
+		return
+	}
+	mdcStore[id] = frames[:depth]
+}
+
+// ClearContext removes all frames from the diagnostic context of the current goroutine.
+// Goroutines taken from a pool should call it before being reused.
+func ClearContext() {
+	id := goroutineId()
+
+	mdcMutex.Lock()
+	defer mdcMutex.Unlock()
+
+	truncateContextLocked(id, 0)
+}
+
+// CurrentContext returns a copy of the fields in the diagnostic context of the current goroutine.
+func CurrentContext() []Field {
+	if atomic.LoadInt32(&mdcActive) == 0 {
+		return nil
+	}
+
+	id := goroutineId()
+
+	mdcMutex.RLock()
+	defer mdcMutex.RUnlock()
+
+	var fields []Field
+	for _, frame := range mdcStore[id] {
+		fields = append(fields, frame...)
+	}
+	return fields
+}
+
+// WithCurrentContext returns a func which runs f with the diagnostic context the
+// current goroutine has at the moment of the call. Use it to propagate the context
+// to goroutines started by other means than GoWithContext (worker pools, etc.).
+// The context is pushed as one frame, which is removed when f returns; the context
+// the running goroutine had before is kept.
+func WithCurrentContext(f func()) func() {
+	fields := CurrentContext()
+	return func() {
+		if len(fields) == 0 {
+			f()
+			return
+		}
+		defer PushContext(fields...)()
+		f()
+	}
+}
+
+// GoWithContext starts f in a new goroutine which inherits the diagnostic context
+// of the current goroutine.
+func GoWithContext(f func()) {
+	go WithCurrentContext(f)()
+}
+
+// withGoroutineContext prepends the diagnostic context fields of the current
+// goroutine to the message fields.
+func withGoroutineContext(message fmt.Stringer) fmt.Stringer {
+	return newFieldsMessage(message, CurrentContext())
+}
+
+var goroutinePrefix = []byte("goroutine ")
+
+// goroutineId returns the id of the current goroutine, parsed from the header
+// of its stack trace ("goroutine 18 [running]:").
+func goroutineId() uint64 {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+	header = bytes.TrimPrefix(header, goroutinePrefix)
+	if i := bytes.IndexByte(header, ' '); i > 0 {
+		header = header[:i]
+	}
+	id, _ := strconv.ParseUint(string(header), 10, 64)
+	return id
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestGoroutineContext(t *testing.T) {
+	bytesVerifier, err := newBytesVerifier(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := newTestLogger(t, testLoggerConfig{format: "%Msg %Field(request_id) %Field(user)", writers: []interface{}{bytesVerifier}})
+	defer ClearContext()
+
+	PushContext(Str("request_id", "r1"))
+
+	bytesVerifier.ExpectBytes([]byte("first r1 bob"))
+	logger.Infow("first", Str("user", "bob"))
+	bytesVerifier.MustNotExpect()
+
+	PushContext(Str("user", "alice"))
+	bytesVerifier.ExpectBytes([]byte("second r1 alice"))
+	logger.Info("second")
+	bytesVerifier.MustNotExpect()
+
+	done := make(chan struct{})
+	GoWithContext(func() {
+		defer close(done)
+		if fields := CurrentContext(); len(fields) != 2 {
+			t.Errorf("expected the context to be inherited, got %v", fields)
+		}
+	})
+	<-done
+
+	// A worker keeps its own context after running a func with the context of the caller
+	run := WithCurrentContext(func() {
+		if fields := CurrentContext(); len(fields) != 3 {
+			t.Errorf("expected the worker and the caller context, got %v", fields)
+		}
+	})
+	worked := make(chan []Field)
+	go func() {
+		PushContext(Str("worker", "w1"))
+		defer ClearContext()
+		run()
+		worked <- CurrentContext()
+	}()
+	if fields := <-worked; len(fields) != 1 || fields[0].Key != "worker" {
+		t.Errorf("expected the worker context to be kept, got %v", fields)
+	}
+
+	other := make(chan []Field)
+	go func() { other <- CurrentContext() }()
+	if fields := <-other; len(fields) != 0 {
+		t.Errorf("expected no context in an unrelated goroutine, got %v", fields)
+	}
+
+	PopContext()
+	PopContext()
+	PopContext()
+
+	bytesVerifier.ExpectBytes([]byte("third  "))
+	logger.Info("third")
+	bytesVerifier.MustNotExpect()
+}
+
+func TestPushContextPop(t *testing.T) {
+	active := atomic.LoadInt32(&mdcActive)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer PushContext(Str("request_id", "r1"))()
+		pop := PushContext(Str("user", "alice"))
+		PushContext(Str("step", "1"))
+
+		pop()
+		pop()
+		if fields := CurrentContext(); len(fields) != 1 || fields[0].Key != "request_id" {
+			t.Errorf("expected the frames from the popped one to be removed, got %v", fields)
+		}
+	}()
+	<-done
+
+	if now := atomic.LoadInt32(&mdcActive); now != active {
+		t.Errorf("expected %d goroutines with a context after the pop, got %d", active, now)
+	}
+}
+
+func TestGoroutineId(t *testing.T) {
+	ids := make(chan uint64, 2)
+	for i := 0; i < 2; i++ {
+		go func() { ids <- goroutineId() }()
+	}
+	first, second := <-ids, <-ids
+	if first == 0 || second == 0 || first == second {
+		t.Errorf("unexpected goroutine ids %d and %d", first, second)
+	}
+}
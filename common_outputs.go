@@ -0,0 +1,192 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// outputEditor is implemented by loggers which support changing outputs at runtime.
+type outputEditor interface {
+	outputTree() *outputTree
+}
+
+func (cLogger *commonLogger) outputTree() *outputTree {
+	return cLogger.outputs
+}
+
+func (fLogger *fieldsLogger) outputTree() *outputTree {
+	if editor, ok := fLogger.logger.(outputEditor); ok {
+		return editor.outputTree()
+	}
+	return nil
+}
+
+func loggerOutputTree(logger LoggerInterface) (*outputTree, error) {
+	if editor, ok := logger.(outputEditor); ok {
+		if tree := editor.outputTree(); tree != nil {
+			if logger.Closed() {
+				return nil, errors.New("Logger is closed")
+			}
+			return tree, nil
+		}
+	}
+	return nil, errors.New("Logger does not support changing outputs")
+}
+
+// parseOutput parses a receiver element of the config which has an id. The returned
+// node does not have the id attribute.
+func parseOutput(output string) (string, *xmlNode, error) {
+	node, err := unmarshalConfig(strings.NewReader(output))
+	if err != nil {
+		return "", nil, err
+	}
+	if _, ok := elementMap[node.name]; !ok {
+		return "", nil, errors.New("Unknown output element '" + node.name + "'")
+	}
+
+	id, ok := node.attributes[outputIdAttr]
+	if !ok || id == "" {
+		return "", nil, errors.New("Output must have a non-empty '" + outputIdAttr + "' attribute")
+	}
+	if hasOutputIds(node) {
+		return "", nil, errors.New("Outputs changed at runtime can not contain outputs with ids")
+	}
+	node = withoutAttribute(node, outputIdAttr)
+
+	if !UpstreamCompatible() {
+		err = expandConfigEnv(node)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	return id, node, nil
+}
+
+func hasOutputIds(node *xmlNode) bool {
+	for _, child := range node.children {
+		if _, ok := child.attributes[outputIdAttr]; ok || hasOutputIds(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddLoggerOutput adds an output to a running logger without rebuilding its output tree.
+// The output is a receiver element as in the outputs section of the config, with an id
+// attribute which is not used by another output. It gets the format of the outputs
+// section unless it has a formatid of a predefined format. The output receives the
+// messages allowed by the levels of the logger, see SetLoggerOutputMinLevel.
+//
+// Example:
+//     err := seelog.AddLoggerOutput(logger, `<file id="audit" path="audit.log" formatid="std:json"/>`)
+func AddLoggerOutput(logger LoggerInterface, output string) error {
+	tree, err := loggerOutputTree(logger)
+	if err != nil {
+		return err
+	}
+
+	id, node, err := parseOutput(output)
+	if err != nil {
+		return err
+	}
+
+	receiver, err := elementMap[node.name].constructor(node, tree.format, nil)
+	if err != nil {
+		return err
+	}
+	slot, err := newOutputSlot(id, tree.format, nil, receiver)
+	if err != nil {
+		return err
+	}
+
+	err = tree.add(slot)
+	if err != nil {
+		slot.Close()
+	}
+	return err
+}
+
+// ReplaceLoggerOutput replaces the output with the same id, from the config or added by
+// AddLoggerOutput, by a new receiver element. The new receiver has the place, format and
+// minimum level of the replaced one; the replaced receiver is flushed and closed.
+//
+// Example:
+//     err := seelog.ReplaceLoggerOutput(logger, `<file id="main" path="/var/log/app/main.log"/>`)
+func ReplaceLoggerOutput(logger LoggerInterface, output string) error {
+	tree, err := loggerOutputTree(logger)
+	if err != nil {
+		return err
+	}
+
+	id, node, err := parseOutput(output)
+	if err != nil {
+		return err
+	}
+
+	return tree.replace(id, func(format *formatter, formats map[string]*formatter) (*dispatcher, error) {
+		receiver, err := elementMap[node.name].constructor(node, format, formats)
+		if err != nil {
+			return nil, err
+		}
+		return createDispatcher(format, []interface{}{receiver})
+	})
+}
+
+// RemoveLoggerOutput removes the output with the given id from a running logger. The
+// receiver of the output is flushed and closed.
+func RemoveLoggerOutput(logger LoggerInterface, id string) error {
+	tree, err := loggerOutputTree(logger)
+	if err != nil {
+		return err
+	}
+
+	return tree.replace(id, func(*formatter, map[string]*formatter) (*dispatcher, error) {
+		return nil, nil
+	})
+}
+
+// SetLoggerOutputMinLevel makes the output with the given id skip messages below the
+// level. Messages which the levels of the logger do not allow are never written, so the
+// level can only restrict what an output receives. Outputs get all allowed messages
+// until their level is set; TraceLvl restores this.
+//
+// Example:
+//     err := seelog.SetLoggerOutputMinLevel(logger, "console", seelog.WarnLvl)
+func SetLoggerOutputMinLevel(logger LoggerInterface, id string, level LogLevel) error {
+	if level.severity() < 0 {
+		return fmt.Errorf("Unknown log level: %d", level)
+	}
+
+	tree, err := loggerOutputTree(logger)
+	if err != nil {
+		return err
+	}
+
+	return tree.setMinLevel(id, level)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeOutputs(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	logger, err := LoggerFromConfigAsString(`
+<seelog type="sync">
+    <outputs formatid="msg">
+        <filter levels="info,warn,error">
+            <file id="main" path="` + dir + `/main.log"/>
+        </filter>
+    </outputs>
+    <formats>
+        <format id="msg" format="%Msg%n"/>
+    </formats>
+</seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldsLogger := LoggerWithFields(logger, Str("k", "v"))
+
+	logger.Info("one")
+	if err := AddLoggerOutput(fieldsLogger, `<file id="extra" path="`+dir+`/extra.log"/>`); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("two")
+	if err := SetLoggerOutputMinLevel(logger, "main", WarnLvl); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("three")
+	logger.Warn("four")
+	if err := ReplaceLoggerOutput(logger, `<file id="main" path="`+dir+`/main2.log"/>`); err != nil {
+		t.Fatal(err)
+	}
+	logger.Error("five")
+	if err := RemoveLoggerOutput(logger, "extra"); err != nil {
+		t.Fatal(err)
+	}
+	logger.Error("six")
+	logger.Critical("filtered by the filter of main")
+	logger.Close()
+
+	expected := map[string]string{
+		"main.log":  "one\ntwo\nfour\n",
+		"main2.log": "five\nsix\n",
+		"extra.log": "two\nthree\nfour\nfive\n",
+	}
+	for name, content := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: expected %q, got %q", name, content, data)
+		}
+	}
+}
+
+func TestRuntimeOutputsErrors(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	logger, err := LoggerFromConfigAsString(`
+<seelog type="sync">
+    <outputs>
+        <file id="main" path="` + dir + `/main.log"/>
+    </outputs>
+</seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"duplicate id", AddLoggerOutput(logger, `<file id="main" path="`+dir+`/other.log"/>`)},
+		{"missing id", AddLoggerOutput(logger, `<file path="`+dir+`/other.log"/>`)},
+		{"unknown element", AddLoggerOutput(logger, `<outputs id="x"/>`)},
+		{"nested id", AddLoggerOutput(logger, `<splitter id="x"><file id="y" path="`+dir+`/other.log"/></splitter>`)},
+		{"replace missing", ReplaceLoggerOutput(logger, `<file id="missing" path="`+dir+`/other.log"/>`)},
+		{"remove missing", RemoveLoggerOutput(logger, "missing")},
+		{"unknown level", SetLoggerOutputMinLevel(logger, "main", LogLevel(200))},
+	}
+	for _, test := range tests {
+		if test.err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	if err := RemoveLoggerOutput(logger, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLoggerOutputMinLevel(logger, "main", WarnLvl); err == nil {
+		t.Error("Expected an error for a removed output")
+	}
+	if err := AddLoggerOutput(logger, `<file id="main" path="`+dir+`/main2.log"/>`); err != nil {
+		t.Errorf("Expected the id of a removed output to be reusable. Got: %s", err)
+	}
+}
+
+func TestOutputIdsInConfig(t *testing.T) {
+	config := `
+<seelog>
+    <outputs>
+        <console id="out"/>
+        <filter levels="error" id="errors">
+            <console id="out"/>
+        </filter>
+    </outputs>
+</seelog>`
+	if _, err := LoggerFromConfigAsString(config); err == nil || !strings.Contains(err.Error(), "Duplicate output id") {
+		t.Errorf("Expected a duplicate id error. Got: %v", err)
+	}
+
+	issues, err := ValidateConfig([]byte(`<seelog><outputs><console id="out"/></outputs></seelog>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues for an output id. Got: %v", issues)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"fmt"
+)
+
+// FormatFieldKey is the key of a field which sets the format of its record, as AsJSON
+// does: a record with the field FormatFieldKey="json" is written as a JSON record to the
+// outputs with text formats. The field itself is not written.
+//
+// Example:
+//     logger.Infow("snapshot", seelog.Str(seelog.FormatFieldKey, "json"), seelog.Any("state", state))
+const FormatFieldKey = "_format"
+
+// jsonRecordFormat is the format of records written as JSON, the one of std:json-fields.
+var jsonRecordFormat, _ = newFormatter("%Json%n")
+
+// recordOptions change how a single record is dispatched and written.
+type recordOptions struct {
+	targets *outputTargets // Outputs the record is directed to, see LoggerTo
+	format  *formatter     // Replaces the text formats of the outputs, see LoggerAsJSON
+}
+
+// merge returns the options combined with outer ones, which were set further from the
+// call site: the targets of both are merged, the format of these options wins. Either
+// may be nil.
+func (options *recordOptions) merge(outer *recordOptions) *recordOptions {
+	if outer == nil {
+		return options
+	}
+	if options == nil {
+		return outer
+	}
+
+	merged := &recordOptions{targets: mergeTargets(options.targets, outer.targets), format: options.format}
+	if merged.format == nil {
+		merged.format = outer.format
+	}
+	return merged
+}
+
+// optionsMessage wraps a log message together with its options.
+type optionsMessage struct {
+	message fmt.Stringer
+	options *recordOptions
+}
+
+// newOptionsMessage attaches options to a message. Options the message already has are
+// merged with them, and take precedence.
+func newOptionsMessage(message fmt.Stringer, options *recordOptions) fmt.Stringer {
+	if options == nil {
+		return message
+	}
+
+	inner, ok := message.(*optionsMessage)
+	if ok {
+		return &optionsMessage{inner.message, inner.options.merge(options)}
+	}
+
+	return &optionsMessage{message, options}
+}
+
+func (message *optionsMessage) String() string {
+	return message.message.String()
+}
+
+func (message *optionsMessage) Fields() []Field {
+	if carrier, ok := message.message.(fieldsCarrier); ok {
+		return carrier.Fields()
+	}
+	return nil
+}
+
+// optionsContext marks the context of a record which has options.
+type optionsContext struct {
+	logContextInterface
+	options *recordOptions
+}
+
+// recordOptionsOf returns the options of the record logged with the context; nil if
+// there are none.
+func recordOptionsOf(context logContextInterface) *recordOptions {
+	if options, ok := context.(*optionsContext); ok {
+		return options.options
+	}
+	return nil
+}
+
+// formatFromFields removes the FormatFieldKey field, if any, and returns the remaining
+// fields and the format the field sets.
+func formatFromFields(fields []Field) ([]Field, *formatter) {
+	for i, field := range fields {
+		if field.Key != FormatFieldKey || field.IsGroup() || FieldValueString(field.Value) != "json" {
+			continue
+		}
+		rest := make([]Field, 0, len(fields)-1)
+		rest = append(append(rest, fields[:i]...), fields[i+1:]...)
+		return rest, jsonRecordFormat
+	}
+	return fields, nil
+}
+
+// LoggerAsJSON returns a logger which writes to the given logger, but its messages are
+// written as JSON records (see the std:json-fields format) to all outputs with text
+// formats, so that occasional machine-readable records can be put in a human-readable
+// stream. Binary formats (%MsgPack) are kept.
+//
+// Example:
+//     seelog.LoggerAsJSON(logger).Infow("snapshot", seelog.Any("state", state))
+func LoggerAsJSON(logger LoggerInterface) StructuredLogger {
+	return &fieldsLogger{logger: logger, options: &recordOptions{format: jsonRecordFormat}}
+}
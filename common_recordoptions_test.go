@@ -0,0 +1,81 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestRecordFormatOverride(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	logger, err := LoggerFromConfigAsString(`
+<seelog type="sync">
+    <outputs formatid="msg">
+        <file id="main" path="` + dir + `/main.log"/>
+        <file id="other" path="` + dir + `/other.log"/>
+    </outputs>
+    <formats>
+        <format id="msg" format="%Level %Msg%n"/>
+    </formats>
+</seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	facade, _ := NewFacade(logger)
+
+	logger.Info("text")
+	LoggerAsJSON(logger).Infow("blob", Int("n", 1))
+	structured := logger.(StructuredLogger)
+	structured.Warnw("field", Str(FormatFieldKey, "json"), Str("k", "v"))
+	structured.Infow("unknown format", Str(FormatFieldKey, "yaml"))
+	facade.AsJSON().To("main").Error("facade")
+	logger.Close()
+
+	json := `\{"time":"[^"]+","level":"%s","msg":"%s"%s\}\n`
+	expected := map[string]string{
+		"main.log": "^Info text\n" +
+			fmt.Sprintf(json, "info", "blob", `,"n":1`) +
+			fmt.Sprintf(json, "warn", "field", `,"k":"v"`) +
+			"Info unknown format\n" +
+			fmt.Sprintf(json, "error", "facade", "") + "$",
+		"other.log": "^Info text\n" +
+			fmt.Sprintf(json, "info", "blob", `,"n":1`) +
+			fmt.Sprintf(json, "warn", "field", `,"k":"v"`) +
+			"Info unknown format\n$",
+	}
+	for name, pattern := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(pattern).Match(data) {
+			t.Errorf("%s: unexpected content %q", name, data)
+		}
+	}
+}
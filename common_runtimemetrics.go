@@ -0,0 +1,230 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"fmt"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// RuntimeMetricsMessage is the message of the records written by StartRuntimeMetrics.
+const RuntimeMetricsMessage = "runtime metrics"
+
+// Names of the runtime/metrics samples included in every runtime metrics record
+// and the keys of the fields they are written to.
+var runtimeMetricsFieldKeys = []struct {
+	name string
+	key  string
+}{
+	{"/memory/classes/heap/objects:bytes", "heap_objects_bytes"},
+	{"/gc/heap/goal:bytes", "heap_goal_bytes"},
+	{"/memory/classes/total:bytes", "memory_total_bytes"},
+	{"/gc/cycles/total:gc-cycles", "gc_cycles"},
+	{"/sched/goroutines:goroutines", "goroutines"},
+}
+
+// GC pause histograms. The first one supported by the running Go version is used.
+var runtimeMetricsPauseNames = []string{
+	"/sched/pauses/total/gc:seconds",
+	"/gc/pauses:seconds",
+}
+
+// runtimeMetricsReporter periodically writes a record with runtime metrics to a logger.
+type runtimeMetricsReporter struct {
+	logger     LoggerInterface
+	level      LogLevel
+	samples    []metrics.Sample
+	keys       []string
+	pauseIndex int      // Index of the GC pauses sample or -1 if not supported
+	lastPauses []uint64 // Pause histogram counts of the previous record
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// StartRuntimeMetrics starts writing a record with runtime metrics values (heap size, GC
+// pauses since the previous record, goroutine count) to logger every interval at the
+// specified level. The values are attached to the record as fields. Additional
+// runtime/metrics names may be passed in extraNames; unsupported names are skipped.
+// Call the returned func to stop the reporting.
+func StartRuntimeMetrics(logger LoggerInterface, interval time.Duration, level LogLevel, extraNames ...string) (stop func(), err error) {
+	if logger == nil {
+		return nil, fmt.Errorf("Logger can not be nil")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("Runtime metrics interval must be positive. Got: %v", interval)
+	}
+	if level < TraceLvl || level > CriticalLvl {
+		return nil, fmt.Errorf("Runtime metrics level must be between Trace and Critical. Got: %d", level)
+	}
+
+	reporter := newRuntimeMetricsReporter(logger, level, extraNames)
+	go reporter.run(interval)
+
+	return reporter.Stop, nil
+}
+
+func newRuntimeMetricsReporter(logger LoggerInterface, level LogLevel, extraNames []string) *runtimeMetricsReporter {
+	supported := make(map[string]bool)
+	for _, description := range metrics.All() {
+		supported[description.Name] = true
+	}
+
+	reporter := &runtimeMetricsReporter{logger: logger, level: level, pauseIndex: -1, stop: make(chan struct{})}
+
+	for _, entry := range runtimeMetricsFieldKeys {
+		if supported[entry.name] {
+			reporter.addSample(entry.name, entry.key)
+		}
+	}
+	for _, name := range extraNames {
+		if supported[name] {
+			reporter.addSample(name, name)
+		}
+	}
+	for _, name := range runtimeMetricsPauseNames {
+		if supported[name] {
+			reporter.pauseIndex = len(reporter.samples)
+			reporter.addSample(name, "gc_pauses")
+			break
+		}
+	}
+
+	return reporter
+}
+
+func (reporter *runtimeMetricsReporter) addSample(name string, key string) {
+	reporter.samples = append(reporter.samples, metrics.Sample{Name: name})
+	reporter.keys = append(reporter.keys, key)
+}
+
+// Stop stops the periodic reporting. It is safe to call Stop several times.
+func (reporter *runtimeMetricsReporter) Stop() {
+	reporter.stopOnce.Do(func() { close(reporter.stop) })
+}
+
+func (reporter *runtimeMetricsReporter) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-reporter.stop:
+			return
+		case <-ticker.C:
+			if reporter.logger.Closed() {
+				return
+			}
+			logWithLevel(reporter.logger, reporter.level, newFieldsMessage(
+				newLogMessage([]interface{}{RuntimeMetricsMessage}), reporter.read()))
+		}
+	}
+}
+
+// read samples the runtime metrics and converts them to fields.
+func (reporter *runtimeMetricsReporter) read() []Field {
+	metrics.Read(reporter.samples)
+
+	fields := make([]Field, 0, len(reporter.samples)+2)
+	for i, sample := range reporter.samples {
+		if i == reporter.pauseIndex {
+			continue
+		}
+
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			fields = append(fields, Uint64(reporter.keys[i], sample.Value.Uint64()))
+		case metrics.KindFloat64:
+			fields = append(fields, Float64(reporter.keys[i], sample.Value.Float64()))
+		}
+	}
+
+	if reporter.pauseIndex != -1 {
+		fields = append(fields, reporter.pauseFields(reporter.samples[reporter.pauseIndex].Value.Float64Histogram())...)
+	}
+
+	return fields
+}
+
+// pauseFields returns the count, 99th percentile and maximum of the GC pauses
+// that happened since the previous record. Percentiles are bucket upper bounds.
+func (reporter *runtimeMetricsReporter) pauseFields(histogram *metrics.Float64Histogram) []Field {
+	deltas := make([]uint64, len(histogram.Counts))
+	var total uint64
+	for i, count := range histogram.Counts {
+		deltas[i] = count
+		if i < len(reporter.lastPauses) {
+			deltas[i] -= reporter.lastPauses[i]
+		}
+		total += deltas[i]
+	}
+	reporter.lastPauses = append(reporter.lastPauses[:0], histogram.Counts...)
+
+	p99, max := 0.0, 0.0
+	if total > 0 {
+		threshold := uint64(math.Ceil(float64(total) * 0.99))
+		var cumulative uint64
+		for i, delta := range deltas {
+			if delta == 0 {
+				continue
+			}
+			cumulative += delta
+			upper := histogram.Buckets[i+1]
+			if math.IsInf(upper, 1) {
+				upper = histogram.Buckets[i]
+			}
+			if p99 == 0 && cumulative >= threshold {
+				p99 = upper
+			}
+			max = upper
+		}
+	}
+
+	return []Field{
+		Uint64("gc_pauses", total),
+		Str("gc_pause_p99", time.Duration(p99*float64(time.Second)).String()),
+		Str("gc_pause_max", time.Duration(max*float64(time.Second)).String()),
+	}
+}
+
+// logWithLevel writes a message to the logger using the method which corresponds to level.
+func logWithLevel(logger LoggerInterface, level LogLevel, message fmt.Stringer) {
+	switch level {
+	case TraceLvl:
+		logger.traceWithCallDepth(loggerFuncCallDepth, message)
+	case DebugLvl:
+		logger.debugWithCallDepth(loggerFuncCallDepth, message)
+	case InfoLvl:
+		logger.infoWithCallDepth(loggerFuncCallDepth, message)
+	case WarnLvl:
+		logger.warnWithCallDepth(loggerFuncCallDepth, message)
+	case ErrorLvl:
+		logger.errorWithCallDepth(loggerFuncCallDepth, message)
+	case CriticalLvl:
+		logger.criticalWithCallDepth(loggerFuncCallDepth, message)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRuntimeMetricsFields(t *testing.T) {
+	reporter := newRuntimeMetricsReporter(Disabled, InfoLvl, []string{"/gc/heap/allocs:bytes", "/no/such:metric"})
+
+	runtime.GC()
+	fields := reporter.read()
+
+	for _, key := range []string{"heap_objects_bytes", "goroutines", "gc_cycles", "/gc/heap/allocs:bytes", "gc_pauses", "gc_pause_max"} {
+		if _, ok := findField(fields, key); !ok {
+			t.Errorf("expected field %q in %v", key, fields)
+		}
+	}
+	if _, ok := findField(fields, "/no/such:metric"); ok {
+		t.Errorf("unsupported metric must be skipped: %v", fields)
+	}
+
+	// Pauses are counted since the previous record
+	if pauses, _ := findField(reporter.read(), "gc_pauses"); pauses != uint64(0) {
+		t.Errorf("expected no new gc pauses, got %s", pauses)
+	}
+}
+
+func TestStartRuntimeMetricsErrors(t *testing.T) {
+	if _, err := StartRuntimeMetrics(nil, time.Second, InfoLvl); err == nil {
+		t.Error("expected error for nil logger")
+	}
+	if _, err := StartRuntimeMetrics(Disabled, 0, InfoLvl); err == nil {
+		t.Error("expected error for zero interval")
+	}
+	if _, err := StartRuntimeMetrics(Disabled, time.Second, Off); err == nil {
+		t.Error("expected error for Off level")
+	}
+
+	stop, err := StartRuntimeMetrics(Disabled, time.Second, InfoLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	stop()
+}
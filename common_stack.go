@@ -0,0 +1,145 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// StackFrame is a frame of the caller stack captured with a log record.
+//
+// Stacks are captured only when the logger config asks for them, so receivers which
+// report errors (crash reporters, issue trackers) get the stack of the log call itself
+// instead of re-capturing it at some unknown depth inside seelog:
+//     <seelog stackdepth="32" stacklevel="error">
+// 'stackdepth' is the maximal number of frames, 'stacklevel' is the lowest level whose
+// records get a stack (error by default). The stack is written by the %Stack verb and
+// by the "stack" key of %Json and %MsgPack, and is decoded into Record.Stack.
+type StackFrame struct {
+	Func string `json:"func"` // Function name, e.g. "main.main"
+	File string `json:"file"` // Full path of the source file
+	Line int    `json:"line"`
+}
+
+// callerStackDepth is the maximal number of frames of a CallerStack field.
+const callerStackDepth = 64
+
+// CallerStack creates a field with the stack of the calling goroutine, starting skip
+// frames above the caller of CallerStack. The value is a []StackFrame, written as an
+// array of {"func","file","line"} objects by %Json and %MsgPack and as a trace by text
+// verbs. Unlike the stacks captured by the config, it is attached to a single record:
+//     logger.Criticalw("worker crashed", seelog.Err("error", err), seelog.CallerStack("stack", 0))
+func CallerStack(key string, skip int) Field {
+	return Field{Key: key, Value: captureStack(skip+1, callerStackDepth)}
+}
+
+// String returns the frame as in a Go panic trace: function name, then the tab
+// indented file and line.
+func (frame StackFrame) String() string {
+	return frame.Func + "\n\t" + frame.File + ":" + strconv.Itoa(frame.Line)
+}
+
+// stackCapture tells which records get a captured stack.
+type stackCapture struct {
+	Depth    int
+	MinLevel LogLevel
+}
+
+// allows returns true if records of the level get a stack.
+func (capture *stackCapture) allows(level LogLevel) bool {
+	return capture != nil && level.severity() >= capture.MinLevel.severity()
+}
+
+// captureStack returns at most depth frames of the stack, starting "skip" frames
+// above the caller, as specificContext does.
+func captureStack(skip int, depth int) []StackFrame {
+	pcs := make([]uintptr, depth)
+	count := runtime.Callers(skip+2, pcs)
+	if count == 0 {
+		return nil
+	}
+
+	stack := make([]StackFrame, 0, count)
+	frames := runtime.CallersFrames(pcs[:count])
+	for {
+		frame, more := frames.Next()
+		funcName := strings.TrimPrefix(frame.Function, workingDir)
+		stack = append(stack, StackFrame{funcName, frame.File, frame.Line})
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// setContextStack attaches a captured stack to a context created by specificContext.
+// Error contexts get no stack: the caller could not be found.
+func setContextStack(context logContextInterface, stack []StackFrame) {
+	if ctx, ok := context.(*logContext); ok {
+		ctx.stack = stack
+	}
+}
+
+func verbStack(message string, level LogLevel, context logContextInterface) interface{} {
+	return stackString(context.Stack())
+}
+
+// stackString returns the frames of the stack on separate lines.
+func stackString(stack []StackFrame) string {
+	buf := new(bytes.Buffer)
+	for i, frame := range stack {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(frame.String())
+	}
+	return buf.String()
+}
+
+// writeJsonStack writes the stack as the "stack" array of {"func","file","line"}
+// objects. Nothing is written for an empty stack.
+func writeJsonStack(buf *bytes.Buffer, stack []StackFrame) {
+	if len(stack) == 0 {
+		return
+	}
+
+	buf.WriteString(`,"stack":[`)
+	for i, frame := range stack {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`{"func":`)
+		writeJsonString(buf, frame.Func)
+		buf.WriteString(`,"file":`)
+		writeJsonString(buf, frame.File)
+		buf.WriteString(`,"line":`)
+		buf.WriteString(strconv.Itoa(frame.Line))
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+}
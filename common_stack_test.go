@@ -0,0 +1,173 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func logStackTestError(logger LoggerInterface) {
+	logger.Error("failed")
+}
+
+func TestStackVerb(t *testing.T) {
+	output := new(bytes.Buffer)
+	logger := newTestLogger(t, testLoggerConfig{format: "%Msg%n%Stack%n", stackDepth: 4, stackLevel: ErrorLvl, writers: []interface{}{output}})
+	logger.Info("started")
+	logStackTestError(logger)
+	logger.Close()
+	lines := strings.Split(output.String(), "\n")
+
+	if lines[0] != "started" || lines[1] != "" {
+		t.Fatalf("Info record must have no stack: %q", lines)
+	}
+	if lines[2] != "failed" {
+		t.Fatalf("Unexpected message: %q", lines)
+	}
+	if !strings.HasSuffix(lines[3], ".logStackTestError") || !strings.HasSuffix(lines[5], ".TestStackVerb") {
+		t.Errorf("Stack must start at the log call: %q", lines[3:])
+	}
+	if !strings.HasPrefix(lines[4], "\t") || !strings.Contains(lines[4], "common_stack_test.go:") {
+		t.Errorf("Unexpected frame location: %q", lines[4])
+	}
+	if frames := (len(lines) - 4) / 2; frames != 4 {
+		t.Errorf("Stack must be limited to 4 frames, got %d: %q", frames, lines[3:])
+	}
+}
+
+func TestStackJson(t *testing.T) {
+	output := new(bytes.Buffer)
+	logger := newTestLogger(t, testLoggerConfig{format: "%Json%n", stackDepth: 4, stackLevel: ErrorLvl, writers: []interface{}{output}})
+	logStackTestError(logger)
+	logger.Close()
+	data := output.Bytes()
+
+	var record struct {
+		Stack []struct {
+			Func string
+			File string
+			Line int
+		}
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Stack) != 4 {
+		t.Fatalf("Unexpected stack: %s", data)
+	}
+	if frame := record.Stack[0]; !strings.HasSuffix(frame.Func, ".logStackTestError") ||
+		filepath.Base(frame.File) != "common_stack_test.go" || frame.Line == 0 {
+		t.Errorf("Unexpected first frame: %+v", frame)
+	}
+}
+
+func TestStackMsgPack(t *testing.T) {
+	output := new(bytes.Buffer)
+	logger := newTestLogger(t, testLoggerConfig{format: "%MsgPack", stackDepth: 4, stackLevel: ErrorLvl, writers: []interface{}{output}})
+	logger.Warn("slow")
+	logStackTestError(logger)
+	logger.Close()
+	decoder := NewMsgPackDecoder(output)
+
+	warn, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warn.Stack != nil {
+		t.Errorf("Warn record must have no stack: %v", warn.Stack)
+	}
+
+	record, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Stack) != 4 || !strings.HasSuffix(record.Stack[1].Func, ".TestStackMsgPack") {
+		t.Fatalf("Unexpected stack: %v", record.Stack)
+	}
+	if record.Stack[0].Line == 0 || filepath.Base(record.Stack[0].File) != "common_stack_test.go" {
+		t.Errorf("Unexpected first frame: %+v", record.Stack[0])
+	}
+}
+
+func logCallerStackTest(logger StructuredLogger) {
+	logger.Warnw("crashed", Group("panic", CallerStack("stack", 0)))
+}
+
+func TestCallerStackField(t *testing.T) {
+	output := new(bytes.Buffer)
+	logger := newTestLogger(t, testLoggerConfig{format: "%Json%n", stackDepth: 4, stackLevel: ErrorLvl, writers: []interface{}{output}})
+	logCallerStackTest(logger)
+	logger.Close()
+	data := output.Bytes()
+
+	var record struct {
+		Stack []StackFrame
+		Panic struct {
+			Stack []StackFrame
+		}
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Stack != nil {
+		t.Errorf("Warn record must have no config stack: %s", data)
+	}
+	stack := record.Panic.Stack
+	if len(stack) < 2 || !strings.HasSuffix(stack[0].Func, ".logCallerStackTest") ||
+		!strings.HasSuffix(stack[1].Func, ".TestCallerStackField") {
+		t.Fatalf("Unexpected stack: %s", data)
+	}
+
+	field := CallerStack("stack", 0)
+	if text := FieldValueString(field.Value); !strings.HasPrefix(text, field.Value.([]StackFrame)[0].String()+"\n") {
+		t.Errorf("Unexpected text of the stack: %q", text)
+	}
+}
+
+func TestStackConfig(t *testing.T) {
+	invalid := []string{
+		`<seelog stacklevel="warn"><outputs><console/></outputs></seelog>`,
+		`<seelog stackdepth="0"><outputs><console/></outputs></seelog>`,
+		`<seelog stackdepth="8" stacklevel="off"><outputs><console/></outputs></seelog>`,
+	}
+	for _, config := range invalid {
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Config must be rejected: %s", config)
+		}
+	}
+
+	description, err := DescribeConfig([]byte(
+		`<seelog stackdepth="8" stacklevel="warn"><outputs><console/></outputs></seelog>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.Settings["stackdepth"] != "8" || description.Settings["stacklevel"] != "warn" {
+		t.Errorf("Unexpected settings: %v", description.Settings)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+)
+
+// File descriptor of the standard error
+const stderrFd = 2
+
+// Captured lines longer than this are split into several records
+const maxStderrLineSize = 64 << 10
+
+// StderrFieldKey is the key of the field attached to records captured by CaptureStderr.
+const StderrFieldKey = "stream"
+
+var (
+	stderrCaptureMutex  sync.Mutex
+	stderrCaptureActive bool
+)
+
+// CaptureStderr redirects the standard error of the whole process (file descriptor 2)
+// into a pipe and writes every line read from it to logger at the specified level with
+// the field stream=stderr. Output of C libraries and of the Go runtime which bypasses
+// os.Stderr is captured as well.
+//
+// Fatal runtime errors are additionally written to the original standard error, because
+// the process exits before the captured lines can be logged. The logger must not write
+// to standard error itself. Call the returned func to restore the original standard
+// error; it waits until all captured lines are passed to the logger.
+func CaptureStderr(logger LoggerInterface, level LogLevel) (restore func() error, err error) {
+	if logger == nil {
+		return nil, errors.New("Logger can not be nil")
+	}
+	if level < TraceLvl || level > CriticalLvl {
+		return nil, errors.New("Capture level must be between Trace and Critical")
+	}
+
+	stderrCaptureMutex.Lock()
+	defer stderrCaptureMutex.Unlock()
+	if stderrCaptureActive {
+		return nil, errors.New("Stderr is already captured")
+	}
+
+	originalFd, err := dupFd(stderrFd)
+	if err != nil {
+		return nil, err
+	}
+	original := os.NewFile(uintptr(originalFd), "stderr")
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		original.Close()
+		return nil, err
+	}
+
+	err = redirectFd(int(writer.Fd()), stderrFd)
+	writer.Close()
+	if err != nil {
+		reader.Close()
+		original.Close()
+		return nil, err
+	}
+
+	debug.SetCrashOutput(original, debug.CrashOptions{})
+	stderrCaptureActive = true
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer reader.Close()
+
+		// The pipe is drained until EOF: closing it early would make the next write to
+		// the standard error fail with EPIPE and kill the process with SIGPIPE
+		lines := bufio.NewReaderSize(reader, maxStderrLineSize)
+		for {
+			line, readErr := lines.ReadSlice('\n')
+			if len(line) > 0 {
+				line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+				logWithLevel(logger, level, newFieldsMessage(
+					newLogMessage([]interface{}{string(line)}), []Field{Str(StderrFieldKey, "stderr")}))
+			}
+			if readErr == bufio.ErrBufferFull {
+				continue
+			}
+			if readErr != nil {
+				if readErr != io.EOF {
+					reportInternalError(readErr)
+				}
+				return
+			}
+		}
+	}()
+
+	var restoreOnce sync.Once
+	restore = func() error {
+		var restoreErr error
+		restoreOnce.Do(func() {
+			stderrCaptureMutex.Lock()
+			defer stderrCaptureMutex.Unlock()
+
+			// Closes the last reference to the pipe writer, so the reader gets EOF
+			restoreErr = redirectFd(int(original.Fd()), stderrFd)
+			debug.SetCrashOutput(nil, debug.CrashOptions{})
+			original.Close()
+			stderrCaptureActive = false
+
+			if restoreErr == nil {
+				<-done
+				logger.Flush()
+			}
+		})
+		return restoreErr
+	}
+
+	return restore, nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCaptureStderr(t *testing.T) {
+	output := new(bytes.Buffer)
+	logger, err := LoggerFromWriterWithMinLevel(output, TraceLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restore, err := CaptureStderr(logger, WarnLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CaptureStderr(logger, WarnLvl); err == nil {
+		t.Error("Expected error for a second capture")
+	}
+
+	fmt.Fprintln(os.Stderr, "first line")
+	fmt.Fprint(os.Stderr, "second line\nunterminated")
+
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	logged := output.String()
+	for _, expected := range []string{"[Warn] first line", "[Warn] second line", "[Warn] unterminated"} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, logged)
+		}
+	}
+
+	restore, err = CaptureStderr(logger, WarnLvl)
+	if err != nil {
+		t.Fatalf("Capture after restore failed: %s", err)
+	}
+	restore()
+}
+
+func TestCaptureStderrLongLine(t *testing.T) {
+	output := new(bytes.Buffer)
+	logger, err := LoggerFromWriterWithMinLevel(output, TraceLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restore, err := CaptureStderr(logger, WarnLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(os.Stderr, strings.Repeat("x", maxStderrLineSize+10))
+	fmt.Fprintln(os.Stderr, "after the long line")
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	logged := output.String()
+	if !strings.Contains(logged, "[Warn] xxxxxxxxxx\n") {
+		t.Errorf("Expected the rest of the long line in its own record")
+	}
+	if !strings.Contains(logged, "[Warn] after the long line") {
+		t.Errorf("Expected the capture to go on after a long line")
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// swapLogger is a LoggerInterface which passes all calls to an underlying logger that
+// can be replaced at any time. A replaced logger is flushed and closed after the swap,
+// so the messages it queued are still written.
+type swapLogger struct {
+	mutex   sync.RWMutex
+	logger  LoggerInterface
+	closed  bool
+	onClose func() // Called once when the swapLogger is closed; may be nil
+}
+
+func newSwapLogger(logger LoggerInterface, onClose func()) *swapLogger {
+	return &swapLogger{logger: logger, onClose: onClose}
+}
+
+// swap replaces the underlying logger and disposes the previous one. Does nothing
+// but closing the new logger if the swapLogger is closed.
+func (sLogger *swapLogger) swap(logger LoggerInterface) {
+	sLogger.mutex.Lock()
+	if sLogger.closed {
+		sLogger.mutex.Unlock()
+		logger.Close()
+		return
+	}
+	previous := sLogger.logger
+	sLogger.logger = logger
+	sLogger.mutex.Unlock()
+
+	previous.Flush()
+	previous.Close()
+}
+
+func (sLogger *swapLogger) current() LoggerInterface {
+	sLogger.mutex.RLock()
+	defer sLogger.mutex.RUnlock()
+	return sLogger.logger
+}
+
+// acquire returns the underlying logger, which swap does not close until release is
+// called. Calls are passed to the logger before release, so that no record goes to a
+// logger which swap has just closed.
+func (sLogger *swapLogger) acquire() (logger LoggerInterface, release func()) {
+	sLogger.mutex.RLock()
+	return sLogger.logger, sLogger.mutex.RUnlock
+}
+
+func (sLogger *swapLogger) Tracef(format string, params ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.traceWithCallDepth(loggerFuncCallDepth, newLogFormattedMessage(format, params))
+}
+
+func (sLogger *swapLogger) Debugf(format string, params ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.debugWithCallDepth(loggerFuncCallDepth, newLogFormattedMessage(format, params))
+}
+
+func (sLogger *swapLogger) Infof(format string, params ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.infoWithCallDepth(loggerFuncCallDepth, newLogFormattedMessage(format, params))
+}
+
+func (sLogger *swapLogger) Warnf(format string, params ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.warnWithCallDepth(loggerFuncCallDepth, newLogFormattedMessage(format, params))
+}
+
+func (sLogger *swapLogger) Errorf(format string, params ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.errorWithCallDepth(loggerFuncCallDepth, newLogFormattedMessage(format, params))
+}
+
+func (sLogger *swapLogger) Criticalf(format string, params ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.criticalWithCallDepth(loggerFuncCallDepth, newLogFormattedMessage(format, params))
+}
+
+func (sLogger *swapLogger) Trace(v ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.traceWithCallDepth(loggerFuncCallDepth, newLogMessage(v))
+}
+
+func (sLogger *swapLogger) Debug(v ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.debugWithCallDepth(loggerFuncCallDepth, newLogMessage(v))
+}
+
+func (sLogger *swapLogger) Info(v ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.infoWithCallDepth(loggerFuncCallDepth, newLogMessage(v))
+}
+
+func (sLogger *swapLogger) Warn(v ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.warnWithCallDepth(loggerFuncCallDepth, newLogMessage(v))
+}
+
+func (sLogger *swapLogger) Error(v ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.errorWithCallDepth(loggerFuncCallDepth, newLogMessage(v))
+}
+
+func (sLogger *swapLogger) Critical(v ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.criticalWithCallDepth(loggerFuncCallDepth, newLogMessage(v))
+}
+
+func (sLogger *swapLogger) Tracew(message string, fields ...Field) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.traceWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (sLogger *swapLogger) Debugw(message string, fields ...Field) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.debugWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (sLogger *swapLogger) Infow(message string, fields ...Field) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.infoWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (sLogger *swapLogger) Warnw(message string, fields ...Field) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.warnWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (sLogger *swapLogger) Errorw(message string, fields ...Field) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.errorWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (sLogger *swapLogger) Criticalw(message string, fields ...Field) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.criticalWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (sLogger *swapLogger) Logf(level LogLevel, format string, params ...interface{}) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logWithCallDepth(logger, level, loggerFuncCallDepth, newLogFormattedMessage(format, params))
+}
+
+func (sLogger *swapLogger) traceWithCallDepth(callDepth int, message fmt.Stringer) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.traceWithCallDepth(callDepth+1, message)
+}
+
+func (sLogger *swapLogger) debugWithCallDepth(callDepth int, message fmt.Stringer) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.debugWithCallDepth(callDepth+1, message)
+}
+
+func (sLogger *swapLogger) infoWithCallDepth(callDepth int, message fmt.Stringer) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.infoWithCallDepth(callDepth+1, message)
+}
+
+func (sLogger *swapLogger) warnWithCallDepth(callDepth int, message fmt.Stringer) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.warnWithCallDepth(callDepth+1, message)
+}
+
+func (sLogger *swapLogger) errorWithCallDepth(callDepth int, message fmt.Stringer) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.errorWithCallDepth(callDepth+1, message)
+}
+
+func (sLogger *swapLogger) criticalWithCallDepth(callDepth int, message fmt.Stringer) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.criticalWithCallDepth(callDepth+1, message)
+}
+
+func (sLogger *swapLogger) logWithCallDepth(level LogLevel, callDepth int, message fmt.Stringer) {
+	logger, release := sLogger.acquire()
+	defer release()
+	logWithCallDepth(logger, level, callDepth+1, message)
+}
+
+func (sLogger *swapLogger) boostLevel(level LogLevel, duration time.Duration) {
+	logger, release := sLogger.acquire()
+	defer release()
+	if booster, ok := logger.(levelBooster); ok {
+		booster.boostLevel(level, duration)
+	}
+}
+
+func (sLogger *swapLogger) eventW(callDepth int, name string, fields []Field) {
+	logger, release := sLogger.acquire()
+	defer release()
+	if emitter, ok := logger.(eventEmitter); ok {
+		emitter.eventW(callDepth+1, name, fields)
+	}
+}
+
+func (sLogger *swapLogger) Close() {
+	sLogger.mutex.Lock()
+	if sLogger.closed {
+		sLogger.mutex.Unlock()
+		return
+	}
+	sLogger.closed = true
+	logger := sLogger.logger
+	sLogger.mutex.Unlock()
+
+	if sLogger.onClose != nil {
+		sLogger.onClose()
+	}
+	logger.Flush()
+	logger.Close()
+}
+
+func (sLogger *swapLogger) Flush() {
+	logger, release := sLogger.acquire()
+	defer release()
+	logger.Flush()
+}
+
+func (sLogger *swapLogger) Closed() bool {
+	sLogger.mutex.RLock()
+	defer sLogger.mutex.RUnlock()
+	return sLogger.closed
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// blockingLogger blocks in Info calls until proceed is closed.
+type blockingLogger struct {
+	LoggerInterface
+	logging chan struct{} // Closed when an Info call started
+	proceed chan struct{}
+	closed  chan struct{}
+}
+
+func (logger *blockingLogger) infoWithCallDepth(callDepth int, message fmt.Stringer) {
+	close(logger.logging)
+	<-logger.proceed
+}
+
+func (logger *blockingLogger) Flush() {
+}
+
+func (logger *blockingLogger) Close() {
+	close(logger.closed)
+}
+
+func TestSwapLoggerWaitsForCalls(t *testing.T) {
+	previous := &blockingLogger{logging: make(chan struct{}), proceed: make(chan struct{}), closed: make(chan struct{})}
+	next, err := LoggerFromWriterWithMinLevel(io.Discard, TraceLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sLogger := newSwapLogger(previous, nil)
+	defer sLogger.Close()
+
+	go sLogger.Info("msg")
+	<-previous.logging
+
+	swapped := make(chan struct{})
+	go func() {
+		defer close(swapped)
+		sLogger.swap(next)
+	}()
+
+	select {
+	case <-previous.closed:
+		t.Fatal("Logger was closed during a call")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(previous.proceed)
+	<-swapped
+	<-previous.closed
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+)
+
+// outputTargets are the outputs a message is explicitly directed to (see LoggerTo).
+type outputTargets struct {
+	ids  []string
+	only bool // Skip the normal dispatch
+}
+
+func newOutputTargets(ids []string, only bool) (*outputTargets, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("No output ids")
+	}
+	for _, id := range ids {
+		if id == "" {
+			return nil, errors.New("Output id can not be empty")
+		}
+	}
+	return mergeTargets(&outputTargets{only: only}, &outputTargets{ids: ids, only: only}), nil
+}
+
+func (targets *outputTargets) has(id string) bool {
+	for _, target := range targets.ids {
+		if target == id {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeTargets returns the outputs of both targets, which skip the normal dispatch only
+// if both do. Either may be nil.
+func mergeTargets(targets, other *outputTargets) *outputTargets {
+	if other == nil {
+		return targets
+	}
+	if targets == nil {
+		return other
+	}
+
+	merged := &outputTargets{only: targets.only && other.only}
+	for _, id := range append(append([]string(nil), targets.ids...), other.ids...) {
+		if !merged.has(id) {
+			merged.ids = append(merged.ids, id)
+		}
+	}
+	return merged
+}
+
+// messageTargets returns the outputs a message logged with the context is directed to.
+func messageTargets(context logContextInterface) (*outputTargets, bool) {
+	options := recordOptionsOf(context)
+	if options == nil || options.targets == nil {
+		return nil, false
+	}
+	return options.targets, true
+}
+
+// LoggerTo returns a logger which writes to the given logger, but directs its messages
+// to the outputs with the given ids only (see the 'id' attribute of outputs). The
+// messages skip the normal dispatch: filters and other outputs around and beside the
+// targeted outputs are bypassed, while the levels of the logger, the exceptions and
+// the minimum levels of the outputs still apply. An unknown id is reported as an
+// internal error.
+//
+// Example:
+//     audit := seelog.LoggerTo(logger, "audit")
+//     audit.Infow("role granted", seelog.Str("user", user), seelog.Str("role", role))
+func LoggerTo(logger LoggerInterface, ids ...string) (StructuredLogger, error) {
+	return loggerWithTargets(logger, ids, true)
+}
+
+// LoggerAlsoTo acts as LoggerTo, but the messages are dispatched normally as well. The
+// targeted outputs get each message once, even if the normal dispatch reaches them too.
+func LoggerAlsoTo(logger LoggerInterface, ids ...string) (StructuredLogger, error) {
+	return loggerWithTargets(logger, ids, false)
+}
+
+func loggerWithTargets(logger LoggerInterface, ids []string, only bool) (StructuredLogger, error) {
+	targets, err := newOutputTargets(ids, only)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldsLogger{logger: logger, options: &recordOptions{targets: targets}}, nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputTargets(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	logger, err := LoggerFromConfigAsString(`
+<seelog type="sync">
+    <outputs formatid="msg">
+        <file id="main" path="` + dir + `/main.log"/>
+        <filter levels="critical">
+            <file id="audit" path="` + dir + `/audit.log"/>
+        </filter>
+    </outputs>
+    <formats>
+        <format id="msg" format="%Msg %Fields%n"/>
+    </formats>
+</seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	audit, err := LoggerTo(LoggerWithFields(logger, Str("k", "v")), "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	both, err := LoggerAlsoTo(logger, "audit", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	facade, _ := NewFacade(logger)
+
+	logger.Info("normal")
+	audit.Infow("audit only", Int("n", 1))
+	both.Info("everywhere once")
+	logger.Critical("critical")
+	facade.To("audit").Warn("facade")
+	facade.AlsoTo("audit").Info("facade also")
+	facade.To("audit").Logf(WarnLvl, "facade %s", "logf")
+	logger.Close()
+
+	expected := map[string]string{
+		"main.log":  "normal \neverywhere once \ncritical \nfacade also \n",
+		"audit.log": "audit only k=v n=1\neverywhere once \ncritical \nfacade \nfacade also \nfacade logf \n",
+	}
+	for name, content := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: expected %q, got %q", name, content, data)
+		}
+	}
+
+	if _, err := LoggerTo(logger); err == nil {
+		t.Error("Expected error for no output ids")
+	}
+	if _, err := LoggerTo(logger, ""); err == nil {
+		t.Error("Expected error for an empty output id")
+	}
+}
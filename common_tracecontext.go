@@ -0,0 +1,236 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Names of the W3C Trace Context headers.
+const (
+	TraceparentHeader = "traceparent"
+	BaggageHeader     = "baggage"
+)
+
+// Keys of the fields produced from W3C Trace Context headers.
+const (
+	TraceIdFieldKey    = "trace_id"
+	SpanIdFieldKey     = "span_id"
+	TraceFlagsFieldKey = "trace_flags"
+	BaggageFieldPrefix = "baggage."
+)
+
+const (
+	traceparentMinLen   = 55
+	traceIdHexLen       = 32
+	parentIdHexLen      = 16
+	baggageMaxMembers   = 180
+	baggageMaxBytes     = 8192
+	traceFlagSampledBit = 0x01
+)
+
+// TraceParent represents a parsed W3C 'traceparent' header.
+type TraceParent struct {
+	Version  string // Two lowercase hex digits
+	TraceId  string // 32 lowercase hex digits
+	ParentId string // 16 lowercase hex digits, the span id of the caller
+	Flags    byte
+}
+
+// Sampled returns true if the caller has recorded the trace.
+func (tp *TraceParent) Sampled() bool {
+	return tp.Flags&traceFlagSampledBit != 0
+}
+
+// Fields returns the trace id, span id and trace flags as logging fields.
+func (tp *TraceParent) Fields() []Field {
+	return []Field{
+		Str(TraceIdFieldKey, tp.TraceId),
+		Str(SpanIdFieldKey, tp.ParentId),
+		Str(TraceFlagsFieldKey, hex.EncodeToString([]byte{tp.Flags})),
+	}
+}
+
+func (tp *TraceParent) String() string {
+	return tp.Version + "-" + tp.TraceId + "-" + tp.ParentId + "-" + hex.EncodeToString([]byte{tp.Flags})
+}
+
+// ParseTraceparent parses the value of a W3C 'traceparent' header.
+// Headers of future versions are accepted as long as their first four parts are valid.
+func ParseTraceparent(header string) (*TraceParent, error) {
+	header = strings.TrimSpace(header)
+	if len(header) < traceparentMinLen {
+		return nil, errors.New("Traceparent is too short: " + header)
+	}
+
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 {
+		return nil, errors.New("Traceparent must have 4 parts: " + header)
+	}
+
+	version, traceId, parentId, flags := parts[0], parts[1], parts[2], parts[3]
+
+	if !isLowerHex(version, 2) || version == "ff" {
+		return nil, errors.New("Traceparent has invalid version: " + version)
+	}
+	if version == "00" && len(parts) != 4 {
+		return nil, errors.New("Traceparent of version 00 must have exactly 4 parts: " + header)
+	}
+	if !isLowerHex(traceId, traceIdHexLen) || isAllZeros(traceId) {
+		return nil, errors.New("Traceparent has invalid trace id: " + traceId)
+	}
+	if !isLowerHex(parentId, parentIdHexLen) || isAllZeros(parentId) {
+		return nil, errors.New("Traceparent has invalid parent id: " + parentId)
+	}
+	if !isLowerHex(flags, 2) {
+		return nil, errors.New("Traceparent has invalid flags: " + flags)
+	}
+
+	flagsValue, err := strconv.ParseUint(flags, 16, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TraceParent{version, traceId, parentId, byte(flagsValue)}, nil
+}
+
+// ParseBaggage parses the value of a W3C 'baggage' header. Each list member
+// becomes a field with BaggageFieldPrefix prepended to its key. Member properties
+// are dropped and values are percent-decoded.
+func ParseBaggage(header string) ([]Field, error) {
+	if len(header) > baggageMaxBytes {
+		return nil, errors.New("Baggage exceeds " + strconv.Itoa(baggageMaxBytes) + " bytes")
+	}
+
+	fields := make([]Field, 0)
+	for _, member := range strings.Split(header, ",") {
+		member = strings.TrimSpace(member)
+		if len(member) == 0 {
+			continue
+		}
+
+		if len(fields) == baggageMaxMembers {
+			return nil, errors.New("Baggage has more than " + strconv.Itoa(baggageMaxMembers) + " members")
+		}
+
+		if propIndex := strings.Index(member, ";"); propIndex != -1 {
+			member = member[:propIndex]
+		}
+
+		eqIndex := strings.Index(member, "=")
+		if eqIndex <= 0 {
+			return nil, errors.New("Baggage member has no key: " + member)
+		}
+
+		key := strings.TrimSpace(member[:eqIndex])
+		value, err := url.PathUnescape(strings.TrimSpace(member[eqIndex+1:]))
+		if err != nil {
+			return nil, errors.New("Baggage member has invalid value: " + member)
+		}
+
+		fields = append(fields, Str(BaggageFieldPrefix+key, value))
+	}
+
+	return fields, nil
+}
+
+// TraceFieldsFromHeaders converts raw 'traceparent' and 'baggage' header values to
+// logging fields. Empty values are skipped. If any value is invalid, an error is
+// returned together with the fields parsed from the valid one.
+func TraceFieldsFromHeaders(traceparent, baggage string) ([]Field, error) {
+	fields := make([]Field, 0)
+	var firstErr error
+
+	if len(traceparent) != 0 {
+		tp, err := ParseTraceparent(traceparent)
+		if err != nil {
+			firstErr = err
+		} else {
+			fields = append(fields, tp.Fields()...)
+		}
+	}
+
+	if len(baggage) != 0 {
+		baggageFields, err := ParseBaggage(baggage)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			fields = append(fields, baggageFields...)
+		}
+	}
+
+	return fields, firstErr
+}
+
+// TraceFieldsFromRequest returns logging fields built from the trace context headers
+// of an incoming request. As the W3C spec requires, invalid headers are ignored.
+func TraceFieldsFromRequest(r *http.Request) []Field {
+	fields, _ := TraceFieldsFromHeaders(r.Header.Get(TraceparentHeader), joinedHeader(r.Header, BaggageHeader))
+	return fields
+}
+
+// ContextFromRequest returns the request context carrying the trace context fields
+// of the request. Use LoggerWithContext to log with them.
+//
+// Example:
+//     func handler(w http.ResponseWriter, r *http.Request) {
+//         logger := log.LoggerWithContext(log.CurrentLogger(), log.ContextFromRequest(r))
+//         logger.Info("handling request")
+//     }
+func ContextFromRequest(r *http.Request) context.Context {
+	return ContextWithFields(r.Context(), TraceFieldsFromRequest(r)...)
+}
+
+// joinedHeader returns all values of a (possibly repeated) list header joined by commas.
+func joinedHeader(header http.Header, name string) string {
+	return strings.Join(header.Values(name), ",")
+}
+
+func isLowerHex(str string, length int) bool {
+	if len(str) != length {
+		return false
+	}
+
+	for i := 0; i < len(str); i++ {
+		char := str[i]
+		if !(char >= '0' && char <= '9') && !(char >= 'a' && char <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isAllZeros(str string) bool {
+	return strings.Trim(str, "0") == ""
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type traceparentTest struct {
+	header        string
+	traceId       string
+	parentId      string
+	sampled       bool
+	errorExpected bool
+}
+
+var traceparentTests = []traceparentTest{
+	{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true, false},
+	{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", false, false},
+	{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true, false},
+	{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "", "", false, true},
+	{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", "", false, true},
+	{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", "", false, true},
+	{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "", "", false, true},
+	{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", "", false, true},
+	{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz", "", "", false, true},
+	{"garbage", "", "", false, true},
+}
+
+func TestParseTraceparent(t *testing.T) {
+	for _, test := range traceparentTests {
+		tp, err := ParseTraceparent(test.header)
+		if (err != nil) != test.errorExpected {
+			t.Errorf("Input: %s\n* Expected error: %t. Got error: %v", test.header, test.errorExpected, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if tp.TraceId != test.traceId || tp.ParentId != test.parentId || tp.Sampled() != test.sampled {
+			t.Errorf("Input: %s\n* Got: %s, sampled: %t", test.header, tp, tp.Sampled())
+		}
+	}
+}
+
+func TestParseBaggage(t *testing.T) {
+	fields, err := ParseBaggage("userId=alice, serverNode = DF%2028;prop=1,isProduction=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Field{
+		Str("baggage.userId", "alice"),
+		Str("baggage.serverNode", "DF 28"),
+		Str("baggage.isProduction", "false"),
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v, got %v", expected, fields)
+	}
+
+	if _, err := ParseBaggage("=novalue"); err == nil {
+		t.Error("Expected error for member without a key")
+	}
+}
+
+func TestTraceFieldsFromRequest(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Add(BaggageHeader, "a=1")
+	r.Header.Add(BaggageHeader, "b=2")
+
+	fields := FieldsFromContext(ContextFromRequest(r))
+	if value, _ := findField(fields, TraceIdFieldKey); value != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Unexpected trace id: %v", fields)
+	}
+	if value, _ := findField(fields, BaggageFieldPrefix+"b"); value != "2" {
+		t.Errorf("Unexpected baggage: %v", fields)
+	}
+
+	r.Header.Set(TraceparentHeader, "invalid")
+	fields = TraceFieldsFromRequest(r)
+	if _, ok := findField(fields, TraceIdFieldKey); ok {
+		t.Errorf("Invalid traceparent must be ignored, got: %v", fields)
+	}
+}
+
+func TestFieldsReachFormatter(t *testing.T) {
+	bytesVerifier, err := newBytesVerifier(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := newTestLogger(t, testLoggerConfig{format: "%Msg %Field(trace_id) %Field(user)", writers: []interface{}{bytesVerifier}})
+
+	ctx := ContextWithFields(context.Background(), Str(TraceIdFieldKey, "abc"))
+	ctxLogger := LoggerWithContext(logger, ctx)
+
+	bytesVerifier.ExpectBytes([]byte("hello abc bob"))
+	ctxLogger.Infow("hello", Str("user", "bob"))
+	bytesVerifier.MustNotExpect()
+
+	bytesVerifier.ExpectBytes([]byte("plain abc "))
+	ctxLogger.Info("plain")
+	bytesVerifier.MustNotExpect()
+}
@@ -92,10 +92,13 @@ func (disp *dispatcher) Dispatch(
 	context logContextInterface,
 	errorFunc func(err error)) {
 
-	for _, writer := range disp.writers {
-		err := writer.Write(message, level, context)
-		if err != nil {
-			errorFunc(err)
+	// Events are written only by eventsDispatcher.
+	if _, isEvent := eventName(context); !isEvent {
+		for _, writer := range disp.writers {
+			err := writer.Write(message, level, context)
+			if err != nil {
+				errorFunc(err)
+			}
 		}
 	}
 
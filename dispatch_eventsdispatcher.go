@@ -0,0 +1,118 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// An eventsDispatcher writes event records (see LoggerEventW) to underlying writers as
+// JSON lines and ignores all other records. Formats of the writers are not used.
+type eventsDispatcher struct {
+	writers []io.Writer
+}
+
+func newEventsDispatcher(receivers []interface{}) (*eventsDispatcher, error) {
+	if len(receivers) == 0 {
+		return nil, errors.New("Receivers cannot be nil or empty")
+	}
+
+	events := new(eventsDispatcher)
+	for _, receiver := range receivers {
+		switch writer := receiver.(type) {
+		case *formattedWriter:
+			events.writers = append(events.writers, writer.Writer())
+		case dispatcherInterface:
+			return nil, errors.New("Events outputs cannot contain dispatchers")
+		case io.Writer:
+			events.writers = append(events.writers, writer)
+		default:
+			return nil, errors.New("Method can receive only io.Writer")
+		}
+	}
+
+	return events, nil
+}
+
+func (events *eventsDispatcher) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	name, ok := eventName(context)
+	if !ok {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(`{"time":`)
+	writeJsonString(buf, context.CallTime().Format(time.RFC3339Nano))
+	buf.WriteString(`,"` + EventFieldKey + `":`)
+	writeJsonString(buf, name)
+	writeJsonFields(buf, context.Fields(), true)
+	buf.WriteString("}\n")
+
+	for _, writer := range events.writers {
+		_, err := writer.Write(buf.Bytes())
+		if err != nil {
+			errorFunc(err)
+		}
+	}
+}
+
+func (events *eventsDispatcher) Flush() {
+	for _, writer := range events.writers {
+		if flusher, ok := writer.(flusherInterface); ok {
+			flusher.Flush()
+		}
+	}
+}
+
+func (events *eventsDispatcher) Close() error {
+	for _, writer := range events.writers {
+		if flusher, ok := writer.(flusherInterface); ok {
+			flusher.Flush()
+		}
+		if closer, ok := writer.(io.Closer); ok {
+			err := closer.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (events *eventsDispatcher) String() string {
+	str := "eventsDispatcher ->\n    ->Writers:\n"
+	for _, writer := range events.writers {
+		str += fmt.Sprintf("        ->%s\n", writer)
+	}
+	return str
+}
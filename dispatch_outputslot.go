@@ -0,0 +1,283 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// An outputSlot holds a receiver with an id, given by the id attribute in the config or
+// by AddLoggerOutput. The receiver can be replaced or removed and its minimum level
+// changed while the logger runs.
+type outputSlot struct {
+	id       string
+	format   *formatter            // Format inherited by the receiver
+	formats  map[string]*formatter // Formats available to the receiver
+	mutex    sync.RWMutex
+	receiver *dispatcher // nil if the output is removed
+	minLevel LogLevel
+}
+
+func newOutputSlot(id string, format *formatter, formats map[string]*formatter, receiver interface{}) (*outputSlot, error) {
+	disp, err := createDispatcher(format, []interface{}{receiver})
+	if err != nil {
+		return nil, err
+	}
+
+	return &outputSlot{id: id, format: format, formats: formats, receiver: disp, minLevel: TraceLvl}, nil
+}
+
+func (slot *outputSlot) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	// Targeted outputs get the message directly from the output tree
+	if targets, ok := messageTargets(context); ok && targets.has(slot.id) {
+		return
+	}
+	slot.deliver(message, level, context, errorFunc)
+}
+
+// deliver passes a message to the receiver if the level is not below the minimum one.
+func (slot *outputSlot) deliver(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	slot.mutex.RLock()
+	defer slot.mutex.RUnlock()
+
+	if slot.receiver == nil {
+		return
+	}
+	if _, isEvent := eventName(context); !isEvent && level.severity() < slot.minLevel.severity() {
+		return
+	}
+	slot.receiver.Dispatch(message, level, context, errorFunc)
+}
+
+func (slot *outputSlot) Flush() {
+	slot.mutex.RLock()
+	defer slot.mutex.RUnlock()
+
+	if slot.receiver != nil {
+		slot.receiver.Flush()
+	}
+}
+
+func (slot *outputSlot) Close() error {
+	previous := slot.replace(nil)
+	if previous == nil {
+		return nil
+	}
+	return previous.Close()
+}
+
+// current returns the receiver of the slot; nil if the output is removed.
+func (slot *outputSlot) current() *dispatcher {
+	slot.mutex.RLock()
+	defer slot.mutex.RUnlock()
+	return slot.receiver
+}
+
+// replace sets the receiver of the slot and returns the previous one, which the caller
+// must close. A nil receiver removes the output.
+func (slot *outputSlot) replace(receiver *dispatcher) *dispatcher {
+	slot.mutex.Lock()
+	defer slot.mutex.Unlock()
+
+	previous := slot.receiver
+	slot.receiver = receiver
+	return previous
+}
+
+func (slot *outputSlot) setMinLevel(level LogLevel) {
+	slot.mutex.Lock()
+	defer slot.mutex.Unlock()
+	slot.minLevel = level
+}
+
+func (slot *outputSlot) String() string {
+	return fmt.Sprintf("outputSlot %s ->\n%s", slot.id, slot.current())
+}
+
+// findOutputSlot returns the output with the given id in the tree of disp; nil if there
+// is no such output. Removed outputs are skipped.
+func findOutputSlot(disp dispatcherInterface, id string) *outputSlot {
+	switch disp := disp.(type) {
+	case *outputSlot:
+		receiver := disp.current()
+		if receiver == nil {
+			return nil
+		}
+		if disp.id == id {
+			return disp
+		}
+		return findOutputSlot(receiver, id)
+	case interface {
+		Dispatchers() []dispatcherInterface
+	}:
+		for _, child := range disp.Dispatchers() {
+			if slot := findOutputSlot(child, id); slot != nil {
+				return slot
+			}
+		}
+	}
+	return nil
+}
+
+// An outputTree dispatches messages to the output tree of a config and to the outputs
+// added at runtime.
+type outputTree struct {
+	root   dispatcherInterface
+	format *formatter   // Format of the added outputs without a formatid
+	mutex  sync.Mutex   // Serializes changes of the added outputs
+	added  atomic.Value // []*outputSlot, copied on write
+}
+
+func newOutputTree(root dispatcherInterface) *outputTree {
+	tree := &outputTree{root: root, format: defaultformatter}
+	if split, ok := root.(*splitDispatcher); ok {
+		tree.format = split.formatter
+	}
+	tree.added.Store([]*outputSlot(nil))
+	return tree
+}
+
+func (tree *outputTree) addedOutputs() []*outputSlot {
+	return tree.added.Load().([]*outputSlot)
+}
+
+func (tree *outputTree) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if targets, ok := messageTargets(context); ok {
+		for _, id := range targets.ids {
+			slot := tree.find(id)
+			if slot == nil {
+				errorFunc(fmt.Errorf("Output '%s' does not exist", id))
+				continue
+			}
+			slot.deliver(message, level, context, errorFunc)
+		}
+		if targets.only {
+			return
+		}
+	}
+
+	tree.root.Dispatch(message, level, context, errorFunc)
+	for _, slot := range tree.addedOutputs() {
+		slot.Dispatch(message, level, context, errorFunc)
+	}
+}
+
+func (tree *outputTree) Flush() {
+	tree.root.Flush()
+	for _, slot := range tree.addedOutputs() {
+		slot.Flush()
+	}
+}
+
+func (tree *outputTree) Close() error {
+	err := tree.root.Close()
+	for _, slot := range tree.addedOutputs() {
+		if closeErr := slot.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// find returns the output with the given id; nil if there is no such output.
+func (tree *outputTree) find(id string) *outputSlot {
+	if slot := findOutputSlot(tree.root, id); slot != nil {
+		return slot
+	}
+	for _, slot := range tree.addedOutputs() {
+		if found := findOutputSlot(slot, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// add adds an output unless an output with the same id exists. Added outputs which
+// were removed since are dropped.
+func (tree *outputTree) add(slot *outputSlot) error {
+	tree.mutex.Lock()
+	defer tree.mutex.Unlock()
+
+	if tree.find(slot.id) != nil {
+		return fmt.Errorf("Output '%s' already exists", slot.id)
+	}
+
+	var added []*outputSlot
+	for _, existing := range tree.addedOutputs() {
+		if existing.current() != nil {
+			added = append(added, existing)
+		}
+	}
+	tree.added.Store(append(added, slot))
+	return nil
+}
+
+// replace replaces the receiver of the output with the given id by the one returned by
+// create, which gets the format and the formats of the output. A nil receiver removes
+// the output. The previous receiver is closed.
+func (tree *outputTree) replace(id string, create func(format *formatter, formats map[string]*formatter) (*dispatcher, error)) error {
+	tree.mutex.Lock()
+	slot := tree.find(id)
+	if slot == nil {
+		tree.mutex.Unlock()
+		return fmt.Errorf("Output '%s' does not exist", id)
+	}
+
+	receiver, err := create(slot.format, slot.formats)
+	if err != nil {
+		tree.mutex.Unlock()
+		return err
+	}
+	previous := slot.replace(receiver)
+	tree.mutex.Unlock()
+
+	return previous.Close()
+}
+
+func (tree *outputTree) setMinLevel(id string, level LogLevel) error {
+	tree.mutex.Lock()
+	defer tree.mutex.Unlock()
+
+	slot := tree.find(id)
+	if slot == nil {
+		return fmt.Errorf("Output '%s' does not exist", id)
+	}
+	slot.setMinLevel(level)
+	return nil
+}
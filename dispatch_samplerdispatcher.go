@@ -0,0 +1,127 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A samplerDispatcher writes a share of messages of each level to underlying receivers
+// and optionally limits the number of written messages per second. Sampling is
+// deterministic: with rate 0.01 every 100th message of the level is written.
+// Events (see LoggerEventW) are never sampled.
+type samplerDispatcher struct {
+	*dispatcher
+	rates  map[LogLevel]float64
+	limit  float64 // Messages per second; 0 if not limited
+	burst  float64
+	mutex  sync.Mutex
+	shares map[LogLevel]float64
+	tokens float64
+	last   time.Time
+}
+
+// newSamplerDispatcher creates a sampler. Levels missing in rates are not sampled.
+// limit is the maximal number of messages per second, 0 for no limit; burst is the number
+// of messages which may be written at once, at least 1.
+func newSamplerDispatcher(formatter *formatter, receivers []interface{},
+	rates map[LogLevel]float64, limit float64, burst int) (*samplerDispatcher, error) {
+	for level, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("Sampling rate of level %s must be between 0 and 1. Got: %v", level, rate)
+		}
+	}
+	if limit < 0 {
+		return nil, errors.New("Rate limit cannot be negative")
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	disp, err := createDispatcher(formatter, receivers)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler := &samplerDispatcher{
+		dispatcher: disp,
+		rates:      rates,
+		limit:      limit,
+		burst:      float64(burst),
+		shares:     make(map[LogLevel]float64),
+		tokens:     float64(burst),
+	}
+	return sampler, nil
+}
+
+func (sampler *samplerDispatcher) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent || sampler.allows(level, context.CallTime()) {
+		sampler.dispatcher.Dispatch(message, level, context, errorFunc)
+	}
+}
+
+func (sampler *samplerDispatcher) allows(level LogLevel, t time.Time) bool {
+	sampler.mutex.Lock()
+	defer sampler.mutex.Unlock()
+
+	if rate, ok := sampler.rates[level]; ok {
+		sampler.shares[level] += rate
+		if sampler.shares[level] < 1-1e-9 {
+			return false
+		}
+		sampler.shares[level] -= 1
+	}
+
+	if sampler.limit == 0 {
+		return true
+	}
+
+	if !sampler.last.IsZero() && t.After(sampler.last) {
+		sampler.tokens += t.Sub(sampler.last).Seconds() * sampler.limit
+		if sampler.tokens > sampler.burst {
+			sampler.tokens = sampler.burst
+		}
+	}
+	if sampler.last.IsZero() || t.After(sampler.last) {
+		sampler.last = t
+	}
+
+	if sampler.tokens < 1 {
+		return false
+	}
+	sampler.tokens -= 1
+	return true
+}
+
+func (sampler *samplerDispatcher) String() string {
+	return fmt.Sprintf("samplerDispatcher %v, limit %v/s ->\n%s", sampler.rates, sampler.limit, sampler.dispatcher)
+}
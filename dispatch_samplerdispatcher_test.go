@@ -0,0 +1,121 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingWriter struct {
+	count int
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	writer.count++
+	return len(p), nil
+}
+
+func TestSamplerDispatcherRates(t *testing.T) {
+	writer := new(countingWriter)
+	rates := map[LogLevel]float64{InfoLvl: 0.01, DebugLvl: 0, ErrorLvl: 1}
+	sampler, err := newSamplerDispatcher(onlyMessageFormatForTest, []interface{}{writer}, rates, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	context := &logContext{callTime: time.Now()}
+	for _, test := range []struct {
+		level    LogLevel
+		expected int
+	}{
+		{InfoLvl, 10}, {DebugLvl, 0}, {ErrorLvl, 1000}, {WarnLvl, 1000},
+	} {
+		writer.count = 0
+		for i := 0; i < 1000; i++ {
+			sampler.Dispatch("msg", test.level, context, func(err error) {})
+		}
+		if writer.count != test.expected {
+			t.Errorf("Level %s: expected %d messages, got %d", test.level, test.expected, writer.count)
+		}
+	}
+
+	writer.count = 0
+	sampler.Dispatch("signup", DebugLvl, &eventContext{context, "signup"}, func(err error) {})
+	if writer.count != 0 {
+		t.Error("Events must not be written to text writers")
+	}
+}
+
+func TestSamplerDispatcherLimit(t *testing.T) {
+	writer := new(countingWriter)
+	sampler, err := newSamplerDispatcher(onlyMessageFormatForTest, []interface{}{writer}, nil, 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		sampler.Dispatch("msg", InfoLvl, &logContext{callTime: start}, func(err error) {})
+	}
+	if writer.count != 5 {
+		t.Errorf("Expected a burst of 5 messages, got %d", writer.count)
+	}
+
+	// One second later ten more tokens are available, but at most the burst
+	for i := 0; i < 100; i++ {
+		sampler.Dispatch("msg", InfoLvl, &logContext{callTime: start.Add(time.Second)}, func(err error) {})
+	}
+	if writer.count != 10 {
+		t.Errorf("Expected 10 messages after one second, got %d", writer.count)
+	}
+}
+
+func TestSamplerConfig(t *testing.T) {
+	config := `
+	<seelog type="sync">
+		<outputs>
+			<console/>
+			<sampler rate="0.01" error="1" limit="100" burst="10"><console/></sampler>
+		</outputs>
+	</seelog>`
+	if _, err := configFromReader(strings.NewReader(config)); err != nil {
+		t.Error(err)
+	}
+
+	invalidConfigs := []string{
+		`<seelog><outputs><sampler rate="2"><console/></sampler></outputs></seelog>`,
+		`<seelog><outputs><sampler info="abc"><console/></sampler></outputs></seelog>`,
+		`<seelog><outputs><sampler limit="-1"><console/></sampler></outputs></seelog>`,
+		`<seelog><outputs><sampler rate="0.5"/></outputs></seelog>`,
+		`<seelog><outputs><sampler off="0.5"><console/></sampler></outputs></seelog>`,
+	}
+	for _, config := range invalidConfigs {
+		if _, err := configFromReader(strings.NewReader(config)); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// SLOCounts holds the numbers of good and bad events counted by an slo receiver.
+type SLOCounts struct {
+	Good uint64
+	Bad  uint64
+}
+
+// Total returns the number of all counted events.
+func (counts SLOCounts) Total() uint64 {
+	return counts.Good + counts.Bad
+}
+
+// sloCounter holds the counts of one slo name. Counters are kept for the whole
+// life of the process, so counts survive logger replacement.
+type sloCounter struct {
+	good uint64
+	bad  uint64
+}
+
+var (
+	sloCountersMutex sync.Mutex
+	sloCounters      = make(map[string]*sloCounter)
+)
+
+func getSloCounter(name string) *sloCounter {
+	sloCountersMutex.Lock()
+	defer sloCountersMutex.Unlock()
+
+	counter, ok := sloCounters[name]
+	if !ok {
+		counter = new(sloCounter)
+		sloCounters[name] = counter
+	}
+	return counter
+}
+
+// GetSLOCounts returns the counts of the slo receiver with the given name.
+func GetSLOCounts(name string) (SLOCounts, bool) {
+	sloCountersMutex.Lock()
+	counter, ok := sloCounters[name]
+	sloCountersMutex.Unlock()
+	if !ok {
+		return SLOCounts{}, false
+	}
+
+	return SLOCounts{atomic.LoadUint64(&counter.good), atomic.LoadUint64(&counter.bad)}, true
+}
+
+// SLONames returns the sorted names of all slo receivers created so far.
+func SLONames() []string {
+	sloCountersMutex.Lock()
+	defer sloCountersMutex.Unlock()
+
+	names := make([]string, 0, len(sloCounters))
+	for name := range sloCounters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sloRule matches a record when all of its set conditions match.
+type sloRule struct {
+	levels  map[LogLevel]bool
+	message *regexp.Regexp
+	field   string
+	op      string
+	value   string
+	number  float64
+	numeric bool
+}
+
+var sloRuleOps = map[string]string{
+	"eq": "==", "ne": "!=", "lt": "<", "le": "<=", "gt": ">", "ge": ">=",
+	"==": "==", "!=": "!=", "<": "<", "<=": "<=", ">": ">", ">=": ">=",
+}
+
+// newSloRule creates a rule. Empty arguments are not checked. A field without op and value
+// only has to be present; an empty op with a value means "==".
+func newSloRule(levels []LogLevel, message string, field string, op string, value string) (*sloRule, error) {
+	rule := new(sloRule)
+
+	if len(levels) > 0 {
+		rule.levels = make(map[LogLevel]bool)
+		for _, level := range levels {
+			rule.levels[level] = true
+		}
+	}
+
+	if message != "" {
+		re, err := regexp.Compile(message)
+		if err != nil {
+			return nil, err
+		}
+		rule.message = re
+	}
+
+	if field == "" {
+		if op != "" || value != "" {
+			return nil, errors.New("Rule has a comparison but no field")
+		}
+		return rule, nil
+	}
+
+	rule.field = field
+	if op == "" && value == "" {
+		return rule, nil
+	}
+	if op == "" {
+		op = "=="
+	}
+	normalized, ok := sloRuleOps[op]
+	if !ok {
+		return nil, errors.New("Unknown rule operator: " + op)
+	}
+
+	rule.op, rule.value = normalized, value
+	number, err := strconv.ParseFloat(value, 64)
+	rule.number, rule.numeric = number, err == nil
+	if !rule.numeric && rule.op != "==" && rule.op != "!=" {
+		return nil, errors.New("Operator '" + op + "' needs a numeric value, got: " + value)
+	}
+
+	return rule, nil
+}
+
+func (rule *sloRule) matches(message string, level LogLevel, context logContextInterface) bool {
+	if rule.levels != nil && !rule.levels[level] {
+		return false
+	}
+	if rule.message != nil && !rule.message.MatchString(message) {
+		return false
+	}
+	if rule.field == "" {
+		return true
+	}
+
+	value, ok := findField(context.Fields(), rule.field)
+	if !ok || rule.op == "" {
+		return ok
+	}
+
+	if number, isNumber := sloNumber(value); isNumber && rule.numeric {
+		switch rule.op {
+		case "==":
+			return number == rule.number
+		case "!=":
+			return number != rule.number
+		case "<":
+			return number < rule.number
+		case "<=":
+			return number <= rule.number
+		case ">":
+			return number > rule.number
+		case ">=":
+			return number >= rule.number
+		}
+	}
+
+	switch rule.op {
+	case "==":
+		return FieldValueString(value) == rule.value
+	case "!=":
+		return FieldValueString(value) != rule.value
+	}
+	return false
+}
+
+func sloNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		number, err := strconv.ParseFloat(v, 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// An sloCounterDispatcher classifies records into good and bad events and counts them.
+// A record is an event if it matches any event rule (every record, if there are none)
+// and a bad event if it also matches any bad rule.
+type sloCounterDispatcher struct {
+	name       string
+	counter    *sloCounter
+	eventRules []*sloRule
+	badRules   []*sloRule
+}
+
+func newSloCounterDispatcher(name string, eventRules []*sloRule, badRules []*sloRule) (*sloCounterDispatcher, error) {
+	if name == "" {
+		return nil, errors.New("SLO name cannot be empty")
+	}
+	if len(badRules) == 0 {
+		return nil, errors.New("SLO '" + name + "' has no bad event rules")
+	}
+
+	return &sloCounterDispatcher{name, getSloCounter(name), eventRules, badRules}, nil
+}
+
+func matchesAnySloRule(rules []*sloRule, message string, level LogLevel, context logContextInterface) bool {
+	for _, rule := range rules {
+		if rule.matches(message, level, context) {
+			return true
+		}
+	}
+	return false
+}
+
+func (slo *sloCounterDispatcher) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+	if len(slo.eventRules) > 0 && !matchesAnySloRule(slo.eventRules, message, level, context) {
+		return
+	}
+
+	if matchesAnySloRule(slo.badRules, message, level, context) {
+		atomic.AddUint64(&slo.counter.bad, 1)
+	} else {
+		atomic.AddUint64(&slo.counter.good, 1)
+	}
+}
+
+func (slo *sloCounterDispatcher) Flush() {
+}
+
+func (slo *sloCounterDispatcher) Close() error {
+	return nil
+}
+
+func (slo *sloCounterDispatcher) String() string {
+	return fmt.Sprintf("sloCounterDispatcher: %s, %d event rules, %d bad rules\n", slo.name, len(slo.eventRules), len(slo.badRules))
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"testing"
+)
+
+// removeSloCounter removes the counts of an slo receiver, so that the next receiver with
+// the name counts from zero.
+func removeSloCounter(name string) {
+	sloCountersMutex.Lock()
+	defer sloCountersMutex.Unlock()
+	delete(sloCounters, name)
+}
+
+func TestSloCounter(t *testing.T) {
+	t.Cleanup(func() { removeSloCounter("test-api") })
+
+	config := `
+	<seelog type="sync">
+		<outputs>
+			<slo name="test-api">
+				<event field="code"/>
+				<event msg="^request"/>
+				<bad field="code" op="ge" value="500"/>
+				<bad levels="error,critical" msg="timeout"/>
+			</slo>
+		</outputs>
+	</seelog>`
+
+	logger, err := LoggerFromConfigAsString(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	structured := logger.(StructuredLogger)
+	structured.Infow("served", Int("code", 200))
+	structured.Infow("served", Int("code", 404))
+	structured.Errorw("served", Int("code", 503))
+	structured.Errorw("served", Str("code", "500"))
+	logger.Error("request timeout")
+	logger.Info("request timeout")
+	logger.Error("unrelated timeout")
+
+	counts, ok := GetSLOCounts("test-api")
+	if !ok {
+		t.Fatal("Counts not found")
+	}
+	expected := SLOCounts{Good: 3, Bad: 3}
+	if counts != expected {
+		t.Errorf("Expected %+v, got %+v", expected, counts)
+	}
+	if counts.Total() != 6 {
+		t.Errorf("Expected total 6, got %d", counts.Total())
+	}
+}
+
+type sloRuleTest struct {
+	field         string
+	op            string
+	value         string
+	fieldValue    interface{}
+	expected      bool
+	errorExpected bool
+}
+
+var sloRuleTests = []sloRuleTest{
+	{"status", "", "", "any", true, false},
+	{"status", "", "ok", "ok", true, false},
+	{"status", "ne", "ok", "failed", true, false},
+	{"latency", ">", "0.5", 0.75, true, false},
+	{"latency", "le", "0.5", 0.75, false, false},
+	{"code", "==", "500", uint64(500), true, false},
+	{"code", "lt", "500", "abc", false, false},
+	{"code", "gt", "abc", nil, false, true},
+	{"code", "~", "1", nil, false, true},
+	{"", "gt", "1", nil, false, true},
+}
+
+func TestSloRule(t *testing.T) {
+	for _, test := range sloRuleTests {
+		rule, err := newSloRule(nil, "", test.field, test.op, test.value)
+		if (err != nil) != test.errorExpected {
+			t.Errorf("Rule %s %s %s: expected error: %t, got: %v", test.field, test.op, test.value, test.errorExpected, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		context := &logContext{fields: []Field{Any(test.field, test.fieldValue)}}
+		if matches := rule.matches("", InfoLvl, context); matches != test.expected {
+			t.Errorf("Rule %s %s %s on %v: expected %t", test.field, test.op, test.value, test.fieldValue, test.expected)
+		}
+	}
+}
@@ -31,7 +31,17 @@ To create a logger, use one of the following constructors:
   func LoggerFromConfigAsBytes
   func LoggerFromConfigAsFile
   func LoggerFromConfigAsString
+  func LoggerFromConfigAsURL
+  func LoggerFromConfigPipeline
+  func LoggerFromConfigPipelineAsBytes
+  func LoggerFromJSON
+  func LoggerFromJSONFile
   func LoggerFromWriterWithMinLevel
+Configs can also be built in code with NewConfig, which returns a ConfigBuilder:
+  logger, err := log.NewConfig().Sync().AddConsole().Format("%Msg%n").Build()
+DevConfig and ProdConfig return builders preset for local development and for production services:
+  logger, err := log.ProdConfig("/var/log/myservice").Build()
+RegisterFlags adds -log.level, -log.config and -log.format flags which override the loaded config.
 Example:
   import log "github.com/cihub/seelog"
 
@@ -65,8 +75,8 @@ Example:
 
 Having loggers as variables is convenient if you are writing your own package with internal logging or if you have 
 several loggers with different options.
-But for most standalone apps it is more convenient to use package level funcs and vars. There is a package level
-var 'Current' made for it. You can replace it with another logger using 'ReplaceLogger' and then use package level funcs:
+But for most standalone apps it is more convenient to use package level funcs. They write to the current logger
+(see 'CurrentLogger'). You can replace it with another logger using 'ReplaceLogger' and then use package level funcs:
   import log "github.com/cihub/seelog"
 
   func main() {
@@ -83,11 +93,14 @@ Last lines
       log.Trace("test")
       log.Debugf("var = %s", "abc")
 do the same as
-      log.Current.Trace("test")
-      log.Current.Debugf("var = %s", "abc")
-In this example the 'Current' logger was replaced using a 'ReplaceLogger' call and became equal to 'logger' variable created from config. 
+      log.CurrentLogger().Trace("test")
+      log.CurrentLogger().Debugf("var = %s", "abc")
+In this example the current logger was replaced using a 'ReplaceLogger' call and became equal to 'logger' variable created from config. 
 This way you are able to use package level funcs instead of passing the logger variable.
 
+The package level funcs are shims for the methods of the Facade returned by 'Std'. Facade methods are safe to call
+concurrently with 'ReplaceLogger'; packages which wrap them should use 'Std().Skip' to report their callers.
+
 Configuration
 
 Main seelog point is to configure logger via config files and not the code. So you can only specify
@@ -104,6 +117,10 @@ There are many sections covering different aspects of seelog, but the most impor
 After you understand these concepts, check the 'Reference' section on the main wiki page to get the up-to-date
 list of dispatchers, receivers, formats, and logger types.
 
+Programs whose log consumers depend on the exact output of upstream seelog (github.com/cihub/seelog) can
+call SetUpstreamCompatible before loading the config. Configs are then limited to the upstream schema and
+verbs, and the output is byte-identical to upstream.
+
 Here is an example config with all these features:
     <seelog type="adaptive" mininterval="2000000" maxinterval="100000000" critmsgcount="500" minlevel="debug">
         <exceptions>
@@ -131,6 +148,358 @@ logs to console, all.log, and errors.log depending on the log level. Its output
 use log level 'debug' and higher (minlevel is set) for all files with names that don't start with 'test'. For files starting with 'test'
 this logger prohibits all levels below 'error'.
 
+Exceptions can also select the packages of callers by import path. A pattern ending with "/*" matches a package
+and all packages below it:
+    <exception package="github.com/me/app/db/*" minlevel="debug"/>
+
+Loggers can capture the caller stack of a record, so that error reporters get the frames of the log call
+itself. 'stackdepth' limits the number of frames and 'stacklevel' is the lowest level which gets a stack
+(error by default). The stack is written by %Stack and by the "stack" key of %Json and %MsgPack:
+    <seelog stackdepth="32" stacklevel="error">
+
+%QueueDepth and %DispatchLatency show whether records are delayed inside the logger itself. %QueueDepth is
+the number of records waiting in the queue of an asynchronous logger when the record was enqueued (always 0
+for sync loggers), %DispatchLatency is the time from the log call until the record is formatted:
+    <format id="pipeline" format="%Ns [%Level] [queue=%QueueDepth latency=%DispatchLatency] %Msg%n"/>
+
+Occasional huge messages, e.g. payload dumps, can be compressed in file outputs: %CompressedMsg(N) writes
+messages longer than N bytes gzipped and base64-encoded after the CompressedMessagePrefix marker, which
+DecompressMessages restores, and %CompressedMsgPack(N) compresses them in binary records, which MsgPackDecoder
+decompresses transparently:
+    <format id="dump" format="%Date %Time [%Level] %CompressedMsg(16384)%n"/>
+
+Custom levels are declared in the customlevels section, each one ranked above a built-in level. They can be
+used wherever built-in levels can, and are logged with Logf. LogLevelFromString finds them once a logger is
+created from the config (see RegisterLevel):
+    <seelog minlevel="notice">
+        <customlevels>
+            <level name="notice" above="info"/>
+            <level name="audit" above="critical"/>
+        </customlevels>
+
+Sections in 'when' elements are used only if their conditions hold, so one config file can serve all
+environments. 'env' checks an environment variable ("NAME=value", "NAME!=value" or "NAME"), 'hostname'
+matches the host name; both accept '*' wildcards:
+    <outputs>
+        <console/>
+        <when env="ENV=production">
+            <file path="alerts.log"/>
+        </when>
+    </outputs>
+
+Passwords can be kept out of config files with secret references: "env://NAME" and "file://PATH" are built in,
+other schemes are resolved by providers registered with RegisterSecretProvider:
+    <smtp senderaddress="..." sendername="..." hostname="..." hostport="587" username="alerts" password="secret://smtp">
+
+Loggers of one process configured with the same rolling file, by relative or absolute path, share one writer
+and its rotation state, so that e.g. a logger created by tests does not rename the file under the application
+logger. The file is closed when the last of these loggers is closed; the settings of the first one are used.
+
+The websocket output serves a WebSocket endpoint which broadcasts messages to its clients, for live tailing
+in browsers without touching files: opening the path in a browser shows the messages as they come. Clients
+filter levels with the minlevel, maxlevel and levels query parameters, e.g. /logs?minlevel=warn. Clients
+which do not keep up miss messages. Bind it to localhost, as there is no authentication:
+    <websocket addr="localhost:9090" path="/logs"/>
+
+The udp output sends every message as one datagram, for collectors where some loss is acceptable. Messages
+longer than 'maxsize' bytes (1472 by default) are truncated. Under load, i.e. beyond 'load' messages per
+second, only a share of 'sample' of the messages is sent, 0 by default:
+    <udp addr="collector.local:9999" maxsize="8192" load="1000" sample="0.1"/>
+
+The syslog output sends messages to the local syslog daemon, or over udp or tcp to a remote one, framed as in
+RFC 5424 or RFC 3164. Levels are mapped to syslog severities, which 'severities' can override:
+    <syslog net="udp" addr="logs.local:514" rfc="3164" facility="local0" tag="billing" severities="info:notice"/>
+
+The journald output writes to the systemd journal over its native protocol. Besides MESSAGE and PRIORITY, the
+entries carry CODE_FILE, CODE_LINE, CODE_FUNC, SYSLOG_IDENTIFIER (the 'tag') and the record fields, upper-cased:
+    <journald tag="billing" severities="info:notice"/>
+
+The fluent output ships messages to Fluentd or Fluent Bit over the forward protocol, as records with the
+formatted message, the level and the fields. With ack="true" every message waits for the aggregator to
+acknowledge it, so a slow aggregator slows the logger down instead of losing messages:
+    <fluent addr="fluentd.local:24224" tag="app.billing" ack="true" tls="true"/>
+
+The gelf output sends messages to Graylog in the GELF format, with the first line of the formatted message
+as the short message, the level as the syslog severity, and the caller (_file, _line, _func) and the fields
+as additional fields. Over udp, messages can be compressed with gzip and are chunked; over tcp they are
+null-terminated:
+    <gelf net="udp" addr="graylog.local:12201" compress="gzip"/>
+
+The logstash output sends messages to a Logstash tcp input with the json_lines codec, as events with
+"@timestamp", "@version", the formatted message, the level and the fields. While Logstash is unreachable,
+messages are kept in memory, up to 'buffersize' bytes, dropping the oldest ones, and are sent once it is
+back; it is dialed again at most every 'reconnect':
+    <logstash addr="logstash.local:5000" tls="true" buffersize="4194304" reconnect="5s"/>
+
+The elasticsearch output indexes messages with the _bulk API, as documents with "@timestamp", the formatted
+message, the level and the fields, in the index named by the 'index' format. Messages are sent in batches,
+and batches rejected with 429 or 5xx are sent again with exponential backoff. 'username' and 'password' set
+basic auth, 'apikey' api key auth. Requests are gzipped unless 'compression' is none; a request rejected
+with 415 is sent again at once uncompressed:
+    <elasticsearch endpoint="https://es.local:9200" index="logs-%Date(2006.01.02)" apikey="env://ES_API_KEY"/>
+
+The redis output pushes messages to a Redis list (as read by the Logstash redis input), or publishes them to
+a channel with mode="publish". Unless it has a formatid, messages are written as JSON records:
+    <redis addr="localhost:6379" key="logstash" password="env://REDIS_PASSWORD"/>
+
+The grpc output streams messages to the LogIngest service of proto/logingest.proto, for shops with a log-ingest
+RPC service, or to a service with the same messages at 'method'. It speaks gRPC over HTTP/2 without a gRPC
+library; 'tls' and 'cacertdirpath' set TLS, and 'cert' and 'key' a client certificate for mTLS. Records are
+sent again on a new stream until the server acknowledges them, and at most 'window' records wait for an
+acknowledgement; further messages wait up to 'timeout' and are then dropped:
+    <grpc addr="ingest.local:443" tls="true" cert="client.pem" key="client.key" token="env://INGEST_TOKEN" window="5000"/>
+
+The http output POSTs messages to any endpoint, such as a webhook: one per request with batch="none", or in
+batches as a JSON array (the default) or as ndjson. Unless it has a formatid, messages are written as JSON
+records. 'username' and 'password' set basic auth, 'token' a bearer token, and header elements add headers.
+Requests failing with 429 or 5xx are sent again with exponential backoff; with 'bufferpath', records still not
+delivered are kept in that file and sent once the endpoint is back. Bodies are compressed with 'compression'
+none or gzip; with auto, the default, they are sent uncompressed until the endpoint advertises gzip with
+Accept-Encoding. A body which the endpoint rejects with 415 is sent again at once uncompressed:
+    <http url="https://hooks.local/logs" batch="ndjson" compression="gzip" token="env://HOOK_TOKEN" bufferpath="http.buf">
+        <header name="X-Source" value="billing"/>
+    </http>
+
+The multiplex output writes to stdout with every message tagged with a stream, so that the application,
+access and audit logs of one process can share a pipe and be separated by a sidecar. Lines are written as
+"stream channel line", or with framing="binary" as frames of Docker's multiplexed format whose payload
+starts with the stream tag:
+    <multiplex stream="access" channel="stdout"/>
+
+The eventlog output reports messages to the Windows Event Log as Information (trace to info), Warning or
+Error (error and critical) events. 'install' registers the event source, which needs administrator rights:
+    <eventlog source="Billing" eventid="100" install="true"/>
+
+The objectstore output uploads messages straight to S3-compatible storage, for hosts without a writable
+disk. Messages are collected in memory into objects of at most 'maxsize' bytes, uploaded at the latest
+'maxinterval' after their first message and on Flush and Close:
+    <objectstore endpoint="https://s3.amazonaws.com" bucket="logs" prefix="billing/" region="eu-west-1"
+        accesskey="env://AWS_ACCESS_KEY_ID" secretkey="env://AWS_SECRET_ACCESS_KEY" maxinterval="5m"/>
+
+The s3archive output archives messages in S3-compatible storage for the long term, without a separate
+shipper. Messages are buffered in chunk files in 'dir' and uploaded gzipped at 'maxsize' bytes or 'maxinterval'
+after the first message of a chunk; chunks which fail to upload stay in 'dir', also across restarts, up to
+'buffersize' bytes. 'key' is a format for the object keys, by default "service/yyyy/mm/dd/hh/host-pid-...":
+    <s3archive endpoint="https://s3.amazonaws.com" bucket="archive" region="eu-west-1" dir="/var/spool/app/archive"
+        service="billing" key="%Field(service)/%UTCDate(2006-01-02)/%Field(host)-%Field(pid)-%Field(seq).log.gz"/>
+
+The gcsarchive output does the same for a Google Cloud Storage bucket, with resumable uploads. It authenticates
+with Application Default Credentials: the file at GOOGLE_APPLICATION_CREDENTIALS or of "gcloud auth
+application-default login", or else the metadata server, as with GKE workload identity. 'credentials' names a
+service account key or user credentials file instead:
+    <gcsarchive bucket="archive" dir="/var/spool/app/archive" service="billing" maxinterval="15m"/>
+
+The azureblob output appends messages to append blobs in an Azure Blob Storage container, one block at 'maxsize'
+bytes (at most 4 MiB) or 'maxinterval' after its first message. 'blob' is a format for the blob names, by
+default "service/yyyy/mm/dd/host-pid.log", so that every day starts a new blob. It authorizes with the 'sas'
+token, or else with the managed identity of the VM, AKS node or App Service ('clientid' for a user-assigned one):
+    <azureblob account="prodlogs" container="app" service="billing" blob="%Field(service)/%UTCDate(2006-01-02).log"/>
+
+The sqlite output inserts messages into a table of a local SQLite database, with the columns id, timestamp, level,
+source, message and fields (JSON), in one transaction per 'maxcount' messages or 'maxinterval'. The oldest rows
+are deleted beyond 'maxrows' rows or 'maxsize' bytes of the database. It uses database/sql, so the application
+imports a driver, registered as 'driver' ("sqlite3" by default, as github.com/mattn/go-sqlite3 does):
+    <sqlite path="/var/lib/app/logs.db" maxrows="100000"/>
+
+The postgres output copies messages into a PostgreSQL table with COPY, in batches of 'maxcount' messages or
+'maxinterval'. The columns are time (timestamptz), level, message and fields (jsonb) unless <column> children
+name them, each filled with one of those values, source, or a field of the message (NULL when it is missing):
+    <postgres addr="db.local:5432" user="logger" password="env://PGPASSWORD" database="app" table="audit_log">
+        <column name="at" value="time"/>
+        <column name="action" value="message"/>
+        <column name="user_id" field="user"/>
+    </postgres>
+
+The mongodb output inserts messages as documents with time, level, message and the fields into a MongoDB
+collection, in batches of 'maxcount' messages or 'maxinterval'. The collection can be created 'capped' at a size
+in bytes or expire documents after 'ttl'; 'w', 'journal' and 'wtimeout' set the write concern. It authenticates
+with SCRAM-SHA-256 when 'user' is set, against 'authsource' ("admin" by default):
+    <mongodb addr="mongo.local:27017" database="app" user="logger" password="env://MONGO_PASSWORD" capped="1073741824"/>
+
+The clickhouse output inserts messages into a ClickHouse table over the HTTP interface, as JSONEachRow rows with
+time, level, message, fields (a JSON string) and every field under its own key; keys without a column are
+skipped. It batches, retries, buffers and compresses like the http output, with the same attributes, but starts
+with gzip; async="true" leaves collecting small batches to the server:
+    <clickhouse url="http://clickhouse.local:8123" database="logs" table="app" user="logger" maxinterval="2s"/>
+
+The influxdb output writes messages as InfluxDB line protocol points, so that events can be graphed next to
+metrics. Every message is a point of the measurement (log by default), tagged with its level and the fields
+named in tags, with the formatted message as the message field and every other field as a field of its own;
+numeric and boolean fields keep their type. Points go to a bucket (org, bucket and token) or to a database of
+InfluxDB 1 (database, username and password). They are batched, retried, buffered and compressed like the http
+output, but start with gzip:
+    <influxdb url="http://influx.local:8086" org="ops" bucket="events" token="env://INFLUX_TOKEN" tags="service"/>
+
+The slack output posts messages of minlevel (warn by default) and above to a Slack incoming webhook; its format is
+the message template. Route children send the given levels to another webhook or channel. Every destination drops
+repeats of a message within dedup (5m by default) and messages beyond limit per interval (10 per minute), and the
+next message posted says how many were suppressed; dedup="0" or limit="0" turn these off:
+    <slack url="env://SLACK_WEBHOOK" channel="#alerts" minlevel="error"><route levels="critical" channel="#oncall"/></slack>
+
+The telegram output sends messages of minlevel (warn by default) and above to Telegram chats through a bot. Messages
+are collected for delay (2s by default) after the first one and sent as one Telegram message, and at most limit
+Telegram messages (20 per minute by default) are sent per interval; while the limit is reached, messages keep
+being collected, so an error storm ends up in a few long messages:
+    <telegram token="env://TELEGRAM_TOKEN" chats="-1001234567890" minlevel="error" delay="5s"/>
+
+The pagerduty output triggers PagerDuty alerts (Events API v2) for messages of minlevel (critical by default) and
+above. The summary is the formatted message and the custom details are its fields. The dedup key is a format,
+%Func:%Line by default, so each log statement is one alert; a trigger is sent again for a key only after repeat
+(5m by default). With autoresolve, an alert is resolved once it has not been triggered for that long:
+    <pagerduty routingkey="env://PAGERDUTY_KEY" component="billing" autoresolve="15m"/>
+
+The datadog output sends messages to the Datadog logs intake of a site (datadoghq.com by default), as logs with the
+formatted message, the level as status and the fields as attributes. ddsource, ddtags, service and hostname come
+from the attributes source, tags, service and hostname, and fields with these keys override them (ddtags fields
+are added). Batches are compressed and retried like those of the http output, starting with gzip, and retries
+wait as long as a 429 asks:
+    <datadog apikey="env://DD_API_KEY" site="datadoghq.eu" service="billing" tags="env:prod"/>
+
+The splunk output sends messages to a Splunk HTTP Event Collector as events with the formatted message, the level
+and the fields, in the index, source and sourcetype (_json by default) given. It batches, retries, buffers and
+compresses like the http output, but starts with gzip. With ack="true" and indexer acknowledgement enabled on the
+collector, a batch counts as sent only once it is acknowledged, and is sent again if it is not within acktimeout
+(30s by default):
+    <splunk url="https://splunk.local:8088" token="env://SPLUNK_HEC_TOKEN" index="app" ack="true"/>
+
+The cloudwatch output sends messages to a log stream of AWS CloudWatch Logs, in batches within the limits of
+PutLogEvents (10000 events, 1 MiB, 24 hours). The group and stream (host-pid by default) are created if they do
+not exist, the group with a retention in days if retention is set. The region defaults to AWS_REGION; without
+accesskey and secretkey, the keys of the environment (as in Lambda) or the role of the ECS task or EC2 instance
+are used. Throttled batches are retried with backoff:
+    <cloudwatch region="eu-west-1" group="/app/billing" retention="30"/>
+
+The cloudlogging output writes messages to Google Cloud Logging as entries with the severity of their level, the
+formatted message and the fields as jsonPayload, the caller as source location and the trace_id and span_id fields
+as trace and span. The project (project, GOOGLE_CLOUD_PROJECT or the metadata server) and the monitored resource
+(Cloud Run revision, Cloud Functions function, App Engine app, GKE container, GCE instance or global) are detected
+from the environment and the metadata server. It authenticates with the credentials file given or the application
+default credentials, and batches and retries like the cloudwatch output:
+    <cloudlogging log="billing" labels="team=payments"/>
+
+The azuremonitor output sends messages to an Azure Monitor Log Analytics workspace with the HTTP Data Collector API,
+as records of the custom log type logtype (stored in the table logtype_CL) with the columns Time, Level, Message
+and the fields. Requests are signed with the shared key of the workspace, which may be a secret reference; batching,
+retries and buffering are those of the http output:
+    <azuremonitor workspace="0b5f1a8e-7c2d-4e4b-9a61-3f2d8c9e1a77" sharedkey="env://LOG_ANALYTICS_KEY" logtype="Billing"/>
+
+The statsd output does not write messages at all: it counts them per level as the statsd counters
+prefix.level.<level> (log.level.error by default), and sends the numeric values of the fields listed in timers as
+timings, durations in milliseconds, so that log volume and latencies logged as fields become metrics. Counters are
+summed and sent over udp every interval (1s by default), with DogStatsD tags if tags is set:
+    <statsd addr="127.0.0.1:8125" prefix="billing.log" timers="duration,db.latency" tags="env:prod"/>
+
+The discard output drops messages but counts them per level, and counts the bytes they are formatted to, under its
+name ("discard" by default). GetDiscardCounts and ResetDiscardCounts read the counts, so the output measures
+formats and constraints in benchmarks, or mutes a noisy logger during an incident while still showing how much it
+logs:
+    <discard name="bench" formatid="json"/>
+
+The ringbuffer output keeps the last size records (1000 by default) in memory under its name ("ringbuffer" by
+default). GetRingBuffer returns the buffer, whose Snapshot and DumpTo give the recent history to a crash handler or
+a debug endpoint; with a filter on the other outputs, it can keep debug records which are not written anywhere:
+    <ringbuffer name="recent" size="500" formatid="debug"/>
+    ...
+    if buffer, ok := seelog.GetRingBuffer("recent"); ok {
+        buffer.DumpTo(os.Stderr)
+    }
+
+The mqtt output publishes messages to an MQTT broker (MQTT 3.1.1), to the topic which the topic format gives for
+each message ("seelog/%Level" by default), at QoS 0 (the default), 1 or 2, retained if retain is true. The url is
+mqtt:// or mqtts:// for TLS; cacertdirpath verifies the broker against the certificates of a directory. The
+connection is kept alive with pings and opened again when it fails:
+    <mqtt url="mqtts://broker.local" topic="devices/%Field(device)/logs/%Level" qos="1" user="gateway"
+        password="env://MQTT_PASSWORD"/>
+
+The zmq output sends messages over a ZeroMQ PUB or PUSH socket (type, push by default), speaking ZMTP 3 itself. It
+connects to the tcp endpoint, again with backoff when the connection fails, or binds to it with bind="true".
+Messages are preceded by a topic frame if topic is set, which PUB subscribers filter on. Like ZeroMQ, every peer
+queues up to hwm messages (1000 by default): PUB drops messages for peers which fall behind, PUSH waits up to
+timeout (5s by default) and then drops the message:
+    <zmq endpoint="tcp://collector.local:5558" type="push" hwm="10000" timeout="1s"/>
+
+The fifo output writes to a named pipe (on Unix), created with create="true", without blocking the logger on the
+reader: the pipe is opened without waiting for a reader, and writes wait at most timeout (10ms by default). Messages
+which the pipe does not take are kept in a buffer of buffersize bytes (1 MiB by default) and written when a reader
+opens the pipe again or catches up; when the reader goes away the pipe is opened again for the next one:
+    <fifo path="/run/app/log.fifo" create="true"/>
+
+The smtp output sends an email per message. With digest="true", messages of digestlevels (error and critical by
+default) are collected and sent in one email when digestcount of them are collected (100 by default) or
+digestinterval after the first one (5m by default), so that an error storm does not flood the mail server. In
+digestsubject, %Count is replaced with the number of messages, %Summary with the counts per level, %First with the
+first message and level names such as %Error with the count of that level:
+    <smtp senderaddress="..." sendername="..." hostname="..." hostport="587" username="..." password="..."
+        digest="true" digestinterval="10m" digestsubject="[billing] %Summary: %First">
+
+Alert emails are throttled with dedup and limit: a message which repeats one of the same level within dedup is
+dropped, and the next email notes how many were. Every recipient gets at most limit emails per interval (1h by
+default), further emails are dropped:
+    <smtp senderaddress="..." sendername="..." hostname="..." hostport="587" username="..." password="..."
+        dedup="30m" limit="20">
+
+To put the context of an alert in its email, the smtp output can get messages of all levels but send only those of
+minlevel and above: the last 'context' messages below it are kept, and those logged since the previous email are
+attached to the next one as context.log. With htmltemplate, the emails are HTML rendered from the html/template file
+with the record (.Record, or .Records in digests) and the context (.Context), e.g. {{range .Context}}{{.Text}}{{end}}:
+    <smtp senderaddress="..." sendername="..." hostname="..." hostport="587" username="..." password="..."
+        minlevel="error" context="50" htmltemplate="alert.html">
+
+For relays which require TLS and OAuth, like Office 365 and Gmail, tls="implicit" connects with TLS from the start
+(SMTPS, port 465) and tls="starttls" refuses servers without STARTTLS; by default STARTTLS is used when offered.
+tlsservername sets the name the server certificate is verified for. auth="xoauth2" authenticates with an access
+token instead of a password; the token is resolved for every email, so a secret provider can keep it fresh:
+    <smtp senderaddress="..." sendername="..." hostname="smtp.gmail.com" hostport="465" username="alerts@..."
+        tls="implicit" auth="xoauth2" token="secret://gmail">
+
+Recipients of the smtp output can be limited to some levels, so that one output sends criticals to the on-call
+alias and errors to the team; recipients without levels get all messages. A digest goes to the recipients of any of
+the levels in it:
+    <smtp senderaddress="..." sendername="..." hostname="..." hostport="587" username="..." password="...">
+        <recipient address="oncall@example.com" levels="critical"/>
+        <recipient address="team@example.com" levels="error"/>
+        <recipient address="archive@example.com"/>
+    </smtp>
+
+The conn output connects over TLS with tls="true", e.g. to rsyslog on port 6514. The server certificate is verified
+for servername (the host of addr by default) against the PEM bundle in cacert, or the system roots, and cert and
+key set a client certificate for collectors which require mutual authentication:
+    <conn net="tcp" addr="rsyslog.local:6514" tls="true" cacert="ca.pem" cert="client.pem" key="client.key"/>
+
+By default the conn output dials again on the next message after the connection fails, and the message is lost.
+With reconnect, buffersize or bufferpath, it dials again after reconnect (1s by default), doubling the wait up to
+maxreconnect (1m) while the remote end stays away, and keeps the messages meanwhile: buffersize bytes in memory,
+older ones in the file at bufferpath (up to bufferfilesize bytes, 64 MiB by default), which also keeps them over
+restarts. They are sent, oldest first, as soon as the connection is back:
+    <conn net="tcp" addr="collector.local:5000" buffersize="1048576" bufferpath="/var/spool/app/conn.buf"/>
+
+Writes to the conn output are limited by writetimeout (timeout by default), so a hung collector does not stall
+the goroutines logging through a sync logger: a write which times out counts as a lost connection. overflow sets
+what happens when the kept messages fill both memory and the buffer file: dropoldest (the default) drops the
+oldest of them, dropnewest drops the new message, and block makes the logging call wait until the collector
+takes the kept messages:
+    <conn net="tcp" addr="collector.local:5000" buffersize="65536" writetimeout="200ms" overflow="dropnewest"/>
+
+Outputs with an 'id' attribute can be replaced, removed or restricted to a minimum level while the logger runs,
+and new outputs can be added, without rebuilding the logger (see AddLoggerOutput):
+    <outputs>
+        <console id="console"/>
+    </outputs>
+    ...
+    seelog.SetLoggerOutputMinLevel(logger, "console", seelog.WarnLvl)
+
+Messages can also be directed to outputs by id at the call site, bypassing the filters around them, either
+instead of the normal dispatch (To, LoggerTo) or in addition to it (AlsoTo, LoggerAlsoTo):
+    seelog.To("audit").Infow("role granted", seelog.Str("user", user))
+
+Long-running operations can report that they are alive with Heartbeat, which writes a progress record every
+interval until a context is done, and then a completion record with the total duration:
+    seelog.Heartbeat(ctx, time.Minute, "syncing shard %d", id)
+
+A single record can be written as JSON to outputs with text formats, with AsJSON or LoggerAsJSON, or with a
+field FormatFieldKey="json":
+    seelog.AsJSON().Infow("snapshot", seelog.Any("state", state))
+
 Examples
 
 To learn seelog features faster you should check the examples package: https://github.com/cihub/seelog-examples
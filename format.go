@@ -25,9 +25,11 @@
 package seelog
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"strconv"
+	"sort"
 	"strings"
 	"time"
 	"unicode"
@@ -61,7 +63,7 @@ func init() {
 }
 
 type verbFunc func(message string, level LogLevel, context logContextInterface) interface{}
-type verbFuncCreator func(param string) verbFunc
+type verbFuncCreator func(param string) (verbFunc, error)
 
 var verbFuncs = map[string]verbFunc{
 	"Level":    verbLevel,
@@ -77,13 +79,58 @@ var verbFuncs = map[string]verbFunc{
 	"FuncShort":verbFunctionShort,
 	"Line":     verbLine,
 	"Time":     verbTime,
+	"UTCTime":  verbUTCTime,
 	"Ns":       verbNs,
+	"UTCNs":    verbUTCNs,
 	"n":        verbn,
 	"t":        verbt,
+	"Json":     verbJson,
+	"MsgPack":  verbMsgPack,
+	"Stack":    verbStack,
+	"LevelColor": verbLevelColor,
+	"QueueDepth": verbQueueDepth,
+	"DispatchLatency": verbDispatchLatency,
 }
 
 var verbFuncsParametrized = map[string]verbFuncCreator{
-	"Date": createDateTimeVerbFunc,
+	"Date":    createDateTimeVerbFunc,
+	"UTCDate": createUTCDateTimeVerbFunc,
+	"Field":   createFieldVerbFunc,
+	"Fields":  createFieldsVerbFunc,
+	"Id":      createIdVerbFunc,
+	"EscM":    createANSIEscapeFunc,
+	"CompressedMsg":     createCompressedMsgVerbFunc,
+	"CompressedMsgPack": createCompressedMsgPackVerbFunc,
+}
+
+// Verbs of upstream seelog, used in the upstream compatibility mode. Verbs are matched
+// by the longest name, so a fork verb like %LevelColor must not be known there: upstream
+// reads it as %Level followed by 'Color'.
+var upstreamVerbFuncs = map[string]verbFunc{
+	"Level":     verbLevel,
+	"Lev":       verbLev,
+	"LEVEL":     verbLEVEL,
+	"LEV":       verbLEV,
+	"l":         verbl,
+	"Msg":       verbMsg,
+	"FullPath":  verbFullPath,
+	"File":      verbFile,
+	"RelFile":   verbRelFile,
+	"Func":      verbFunction,
+	"FuncShort": verbFunctionShort,
+	"Line":      verbLine,
+	"Time":      verbTime,
+	"UTCTime":   verbUTCTime,
+	"Ns":        verbNs,
+	"UTCNs":     verbUTCNs,
+	"n":         verbn,
+	"t":         verbt,
+}
+
+var upstreamVerbFuncsParametrized = map[string]verbFuncCreator{
+	"Date":    createDateTimeVerbFunc,
+	"UTCDate": createUTCDateTimeVerbFunc,
+	"EscM":    createUpstreamANSIEscapeFunc,
 }
 
 // formatter is used to write messages in a specific format, inserting such additional data
@@ -92,12 +139,22 @@ type formatter struct {
 	fmtStringOriginal string
 	fmtString         string
 	verbFuncs         []verbFunc
+	locale            *FormatLocale // nil for locale-neutral output
+	upstream          bool          // Only upstream verbs are known, see SetUpstreamCompatible
 }
 
 // newFormatter creates a new formatter using a format string
 func newFormatter(formatString string) (*formatter, error) {
+	return newFormatterWithLocale(formatString, nil)
+}
+
+// newFormatterWithLocale creates a new formatter which renders date names and field
+// numbers according to the locale. See FormatLocale.
+func newFormatterWithLocale(formatString string, locale *FormatLocale) (*formatter, error) {
 	newformatter := new(formatter)
 	newformatter.fmtStringOriginal = formatString
+	newformatter.locale = locale
+	newformatter.upstream = UpstreamCompatible()
 
 	err := newformatter.buildVerbFuncs()
 	if err != nil {
@@ -107,6 +164,14 @@ func newFormatter(formatString string) (*formatter, error) {
 	return newformatter, nil
 }
 
+// localize wraps a verb according to the locale of the formatter, if there is one.
+func (formatter *formatter) localize(verb string, function verbFunc) verbFunc {
+	if formatter.locale == nil {
+		return function
+	}
+	return formatter.locale.localizeVerbFunc(verb, function)
+}
+
 func (formatter *formatter) buildVerbFuncs() error {
 	formatter.verbFuncs = make([]verbFunc, 0)
 	var fmtString string
@@ -154,7 +219,10 @@ func (formatter *formatter) extractVerbFunc(index int) (verbFunc, int, error) {
 		return function, index + verbLength - 1, nil
 	}
 
-	function, verbLength, ok = formatter.findVerbFuncParametrized(letterSequence, index)
+	function, verbLength, ok, err := formatter.findVerbFuncParametrized(letterSequence, index)
+	if err != nil {
+		return nil, 0, err
+	}
 	if ok {
 		return function, index + verbLength - 1, nil
 	}
@@ -180,12 +248,21 @@ func (formatter *formatter) extractLetterSequence(index int) string {
 	return letters
 }
 
+// verbTables returns the known plain and parametrized verbs.
+func (formatter *formatter) verbTables() (map[string]verbFunc, map[string]verbFuncCreator) {
+	if formatter.upstream {
+		return upstreamVerbFuncs, upstreamVerbFuncsParametrized
+	}
+	return verbFuncs, verbFuncsParametrized
+}
+
 func (formatter *formatter) findVerbFunc(letters string) (verbFunc, int, bool) {
+	functions, _ := formatter.verbTables()
 	currentVerb := letters
 	for i := 0; i < len(letters); i++ {
-		function, ok := verbFuncs[currentVerb]
+		function, ok := functions[currentVerb]
 		if ok {
-			return function, len(currentVerb), ok
+			return formatter.localize(currentVerb, function), len(currentVerb), ok
 		}
 		currentVerb = currentVerb[:len(currentVerb)-1]
 	}
@@ -193,49 +270,71 @@ func (formatter *formatter) findVerbFunc(letters string) (verbFunc, int, bool) {
 	return nil, 0, false
 }
 
-func (formatter *formatter) findVerbFuncParametrized(letters string, lettersStartIndex int) (verbFunc, int, bool) {
+func (formatter *formatter) findVerbFuncParametrized(letters string, lettersStartIndex int) (verbFunc, int, bool, error) {
+	_, functionCreators := formatter.verbTables()
 	currentVerb := letters
 	for i := 0; i < len(letters); i++ {
-		functionCreator, ok := verbFuncsParametrized[currentVerb]
+		functionCreator, ok := functionCreators[currentVerb]
 		if ok {
 			paramter := ""
 			parameterLen := 0
 			isVerbEqualsLetters := i == 0 // if not, then letter goes after verb, and verb is parameterless
 			if isVerbEqualsLetters {
 				userParamter := ""
-				userParamter, parameterLen, ok = formatter.findparameter(lettersStartIndex + len(currentVerb))
+				var err error
+				userParamter, parameterLen, ok, err = formatter.findparameter(lettersStartIndex + len(currentVerb))
+				if err != nil {
+					return nil, 0, false, err
+				}
 				if ok {
 					paramter = userParamter
 				}
 			}
 
-			return functionCreator(paramter), len(currentVerb) + parameterLen, true
+			function, err := functionCreator(paramter)
+			if err != nil {
+				return nil, 0, false, errors.New("Format error: %" + currentVerb + " at " +
+					strconv.Itoa(lettersStartIndex) + ": " + err.Error())
+			}
+			return formatter.localize(currentVerb, function), len(currentVerb) + parameterLen, true, nil
 		}
 
 		currentVerb = currentVerb[:len(currentVerb)-1]
 	}
 
-	return nil, 0, false
+	return nil, 0, false, nil
 }
 
-func (formatter *formatter) findparameter(startIndex int) (string, int, bool) {
+func (formatter *formatter) findparameter(startIndex int) (string, int, bool, error) {
 	if len(formatter.fmtStringOriginal) == startIndex || formatter.fmtStringOriginal[startIndex] != verbParameterStart {
-		return "", 0, false
+		return "", 0, false, nil
 	}
 
-	endIndex := strings.Index(formatter.fmtStringOriginal[startIndex:], string(verbParameterEnd)) + startIndex
+	endIndex := strings.Index(formatter.fmtStringOriginal[startIndex:], string(verbParameterEnd))
 	if endIndex == -1 {
-		return "", 0, false
+		return "", 0, false, errors.New("Format error: unmatched parenthesis at " + strconv.Itoa(startIndex) +
+			": " + formatter.fmtStringOriginal[startIndex:])
 	}
+	endIndex += startIndex
 
 	length := endIndex - startIndex + 1
 
-	return formatter.fmtStringOriginal[startIndex+1 : endIndex], length, true
+	return formatter.fmtStringOriginal[startIndex+1 : endIndex], length, true, nil
 }
 
 // Format processes a message with special verbs, log level, and context. Returns formatted string
 // with all verb identifiers changed to appropriate values.
 func (formatter *formatter) Format(message string, level LogLevel, context logContextInterface) string {
+	if options := recordOptionsOf(context); options != nil && options.format != nil &&
+		options.format != formatter && !formatter.isBinary() {
+		return options.format.Format(message, level, context)
+	}
+	return formatter.formatOwn(message, level, context)
+}
+
+// formatOwn formats a record with this format, even if the record sets another one. It
+// is used for strings which are not the record itself, e.g. names built from records.
+func (formatter *formatter) formatOwn(message string, level LogLevel, context logContextInterface) string {
 	if len(formatter.verbFuncs) == 0 {
 		return formatter.fmtString
 	}
@@ -248,6 +347,13 @@ func (formatter *formatter) Format(message string, level LogLevel, context logCo
 	return fmt.Sprintf(formatter.fmtString, params...)
 }
 
+// isBinary reports whether the format writes binary records, which a record can not
+// replace with text.
+func (formatter *formatter) isBinary() bool {
+	return strings.Contains(formatter.fmtStringOriginal, "%MsgPack") ||
+		strings.Contains(formatter.fmtStringOriginal, "%CompressedMsgPack")
+}
+
 func (formatter *formatter) String() string {
 	return formatter.fmtStringOriginal
 }
@@ -255,7 +361,8 @@ func (formatter *formatter) String() string {
 //=====================================================
 
 const (
-	wrongLogLevel = "WRONG_LOGLEVEL"
+	wrongLogLevel   = "WRONG_LOGLEVEL"
+	wrongEscapeCode = "WRONG_ESCAPE"
 )
 
 var levelToString = map[LogLevel]string{
@@ -291,14 +398,25 @@ var levelToShortestString = map[LogLevel]string{
 func verbLevel(message string, level LogLevel, context logContextInterface) interface{} {
 	levelStr, ok := levelToString[level]
 	if !ok {
+		if custom, ok := loadCustomLevels()[level]; ok {
+			return custom.title
+		}
 		return wrongLogLevel
 	}
 	return levelStr
 }
 
+// verbLev writes the short name of the level. Custom levels are shortened to at most
+// three letters.
 func verbLev(message string, level LogLevel, context logContextInterface) interface{} {
 	levelStr, ok := levelToShortString[level]
 	if !ok {
+		if custom, ok := loadCustomLevels()[level]; ok {
+			if len(custom.title) > 3 {
+				return custom.title[:3]
+			}
+			return custom.title
+		}
 		return wrongLogLevel
 	}
 	return levelStr
@@ -312,9 +430,59 @@ func verbLEV(message string, level LogLevel, context logContextInterface) interf
 	return strings.ToTitle(verbLev(message, level, context).(string))
 }
 
+// ANSI colors of levels written by %LevelColor
+var levelToANSIColor = map[LogLevel]string{
+	TraceLvl:    "\x1b[90m",
+	DebugLvl:    "\x1b[36m",
+	InfoLvl:     "\x1b[32m",
+	WarnLvl:     "\x1b[33m",
+	ErrorLvl:    "\x1b[31m",
+	CriticalLvl: "\x1b[1;35m",
+}
+
+// verbLevelColor starts the ANSI terminal color of the level. Reset it with %EscM(0).
+// Custom levels have the color of the built-in level they are registered above.
+// The console writer enables ANSI processing of Windows consoles and strips the
+// sequences if a console does not support them.
+//
+// Example:
+//     %LevelColor[%LEV]%EscM(0) %Msg%n
+func verbLevelColor(message string, level LogLevel, context logContextInterface) interface{} {
+	color, ok := levelToANSIColor[level]
+	if !ok {
+		if custom, ok := loadCustomLevels()[level]; ok {
+			return levelToANSIColor[custom.above]
+		}
+	}
+	return color
+}
+
+// createANSIEscapeFunc creates a verb which writes an ANSI escape sequence setting
+// the terminal graphics mode, e.g. %EscM(1;31) for bold red or %EscM(0) for reset.
+func createANSIEscapeFunc(param string) (verbFunc, error) {
+	escape := "\x1b[" + param + "m"
+	return func(message string, level LogLevel, context logContextInterface) interface{} {
+		return escape
+	}, nil
+}
+
+// createUpstreamANSIEscapeFunc creates the %EscM verb of upstream seelog, which writes
+// a marker instead of a reset sequence if there is no parameter.
+func createUpstreamANSIEscapeFunc(param string) (verbFunc, error) {
+	if param == "" {
+		return func(message string, level LogLevel, context logContextInterface) interface{} {
+			return wrongEscapeCode
+		}, nil
+	}
+	return createANSIEscapeFunc(param)
+}
+
 func verbl(message string, level LogLevel, context logContextInterface) interface{} {
 	levelStr, ok := levelToShortestString[level]
 	if !ok {
+		if custom, ok := loadCustomLevels()[level]; ok {
+			return custom.name[:1]
+		}
 		return wrongLogLevel
 	}
 	return levelStr
@@ -354,10 +522,86 @@ func verbTime(message string, level LogLevel, context logContextInterface) inter
 	return context.CallTime().Format(TimeFormat)
 }
 
+func verbUTCTime(message string, level LogLevel, context logContextInterface) interface{} {
+	return context.CallTime().UTC().Format(TimeFormat)
+}
+
 func verbNs(message string, level LogLevel, context logContextInterface) interface{} {
 	return context.CallTime().UnixNano()
 }
 
+func verbUTCNs(message string, level LogLevel, context logContextInterface) interface{} {
+	return context.CallTime().UTC().UnixNano()
+}
+
+// verbQueueDepth writes the number of records which were waiting in the queue of an
+// asynchronous logger when the record was enqueued. Synchronous loggers always write 0.
+func verbQueueDepth(message string, level LogLevel, context logContextInterface) interface{} {
+	return context.QueueDepth()
+}
+
+// verbDispatchLatency writes the time between the log call and the formatting of the
+// record, i.e. how long the record was delayed inside the logger, e.g. "1.204ms".
+func verbDispatchLatency(message string, level LogLevel, context logContextInterface) interface{} {
+	return time.Since(context.CallTime()).Round(time.Microsecond)
+}
+
+// verbJson writes the whole record as a JSON object: call time, level, message,
+// fields and the captured stack, if any. Field groups become nested objects, and fields
+// named like the keys of the record are prefixed with "fields.".
+func verbJson(message string, level LogLevel, context logContextInterface) interface{} {
+	buf := new(bytes.Buffer)
+	buf.WriteString(`{"time":`)
+	writeJsonString(buf, context.CallTime().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJsonString(buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJsonString(buf, message)
+	writeJsonFields(buf, prefixJsonRecordKeys(context.Fields()), true)
+	writeJsonStack(buf, context.Stack())
+	buf.WriteByte('}')
+
+	return buf.String()
+}
+
+// Keys which %Json writes itself. Fields with these keys are written with
+// jsonFieldKeyPrefix, so that records have no duplicate keys.
+var jsonRecordKeys = map[string]bool{"time": true, "level": true, "msg": true, "stack": true}
+
+const jsonFieldKeyPrefix = "fields."
+
+// prefixJsonRecordKeys returns fields with jsonFieldKeyPrefix added to the keys which
+// would be written next to the keys of %Json and are among them. Fields are returned as
+// they are if none is.
+func prefixJsonRecordKeys(fields []Field) []Field {
+	if !hasJsonRecordKey(fields) {
+		return fields
+	}
+	prefixed := make([]Field, len(fields))
+	for i, field := range fields {
+		if field.IsGroup() && field.Key == "" {
+			field.Group = prefixJsonRecordKeys(field.Group)
+		} else if jsonRecordKeys[field.Key] {
+			field.Key = jsonFieldKeyPrefix + field.Key
+		}
+		prefixed[i] = field
+	}
+	return prefixed
+}
+
+func hasJsonRecordKey(fields []Field) bool {
+	for _, field := range fields {
+		if field.IsGroup() && field.Key == "" {
+			if hasJsonRecordKey(field.Group) {
+				return true
+			}
+		} else if jsonRecordKeys[field.Key] {
+			return true
+		}
+	}
+	return false
+}
+
 func verbn(message string, level LogLevel, context logContextInterface) interface{} {
 	return "\n"
 }
@@ -366,12 +610,83 @@ func verbt(message string, level LogLevel, context logContextInterface) interfac
 	return "\t"
 }
 
-func createDateTimeVerbFunc(dateTimeFormat string) verbFunc {
+func createDateTimeVerbFunc(dateTimeFormat string) (verbFunc, error) {
 	format := dateTimeFormat
 	if format == "" {
 		format = DateDefaultFormat
 	}
 	return func(message string, level LogLevel, context logContextInterface) interface{} {
-		return time.Now().Format(format)
+		return context.CallTime().Format(format)
+	}, nil
+}
+
+func createUTCDateTimeVerbFunc(dateTimeFormat string) (verbFunc, error) {
+	format := dateTimeFormat
+	if format == "" {
+		format = DateDefaultFormat
+	}
+	return func(message string, level LogLevel, context logContextInterface) interface{} {
+		return context.CallTime().UTC().Format(format)
+	}, nil
+}
+
+func createFieldVerbFunc(key string) (verbFunc, error) {
+	return func(message string, level LogLevel, context logContextInterface) interface{} {
+		value, _ := findField(context.Fields(), key)
+		return FieldValueString(value)
+	}, nil
+}
+
+// Options of the %Fields verb
+const (
+	fieldsVerbSeparatorOption = "sep"
+	fieldsVerbOrderOption     = "order"
+	fieldsVerbOrderSorted     = "sorted"
+	fieldsVerbDefaultSep      = " "
+)
+
+// createFieldsVerbFunc creates a verb which writes all fields attached to a message
+// as key=value pairs. The parameter is a ';'-separated list of options:
+//     sep=<separator>   - separator between pairs (space by default)
+//     order=sorted      - sort pairs by key instead of keeping the order they were attached in
+// Other options are format errors.
+// Example:
+//     %Msg %Fields(sep=, ;order=sorted)
+func createFieldsVerbFunc(param string) (verbFunc, error) {
+	separator := fieldsVerbDefaultSep
+	sorted := false
+	for _, option := range strings.Split(param, ";") {
+		name, value := option, ""
+		if i := strings.Index(option, "="); i != -1 {
+			name, value = option[:i], option[i+1:]
+		}
+
+		switch strings.TrimSpace(name) {
+		case "":
+			// No options, or a trailing ';'
+		case fieldsVerbSeparatorOption:
+			separator = value
+		case fieldsVerbOrderOption:
+			if strings.TrimSpace(value) != fieldsVerbOrderSorted {
+				return nil, errors.New("invalid order '" + value + "', expected " + fieldsVerbOrderSorted)
+			}
+			sorted = true
+		default:
+			return nil, errors.New("unknown option '" + option + "'")
+		}
 	}
+
+	return func(message string, level LogLevel, context logContextInterface) interface{} {
+		fields := flattenFields(context.Fields())
+		if sorted {
+			fields = append([]Field(nil), fields...)
+			sort.SliceStable(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+		}
+
+		pairs := make([]string, len(fields))
+		for i, field := range fields {
+			pairs[i] = field.String()
+		}
+		return strings.Join(pairs, separator)
+	}, nil
 }
@@ -0,0 +1,132 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// CompressedMessagePrefix marks messages which %CompressedMsg wrote compressed: the
+// prefix is followed by the message compressed with gzip, in standard base64.
+// DecompressMessages restores them.
+const CompressedMessagePrefix = "seelog+gzip:"
+
+// Messages longer than this are compressed by %CompressedMsg and %CompressedMsgPack
+// without a parameter.
+const defaultCompressAbove = 64 << 10
+
+// %CompressedMsg(N) writes the message as %Msg does, unless it is longer than N bytes
+// (64 KiB by default): then it is compressed and written as CompressedMessagePrefix
+// followed by base64, so that an occasional huge payload dump takes a fraction of the
+// space and stays on one line. %CompressedMsgPack(N) writes records as %MsgPack does,
+// with large messages compressed under the "msgz" key, which MsgPackDecoder reads
+// transparently.
+//
+// Example:
+//     <format id="dump" format="%Date %Time [%Level] %CompressedMsg(16384)%n"/>
+
+func compressAboveParameter(param string) int {
+	compressAbove, err := strconv.Atoi(strings.TrimSpace(param))
+	if err != nil || compressAbove <= 0 {
+		return defaultCompressAbove
+	}
+	return compressAbove
+}
+
+func createCompressedMsgVerbFunc(param string) (verbFunc, error) {
+	compressAbove := compressAboveParameter(param)
+	return func(message string, level LogLevel, context logContextInterface) interface{} {
+		if len(message) <= compressAbove {
+			return message
+		}
+		return CompressedMessagePrefix + base64.StdEncoding.EncodeToString(gzipMessage(message))
+	}, nil
+}
+
+func createCompressedMsgPackVerbFunc(param string) (verbFunc, error) {
+	compressAbove := compressAboveParameter(param)
+	return func(message string, level LogLevel, context logContextInterface) interface{} {
+		return string(appendMsgPackRecord(nil, message, level, context, compressAbove))
+	}, nil
+}
+
+func gzipMessage(message string) []byte {
+	buf := new(bytes.Buffer)
+	writer := gzip.NewWriter(buf)
+	writer.Write([]byte(message))
+	writer.Close()
+	return buf.Bytes()
+}
+
+func gunzipMessage(data []byte) (string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	message, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
+
+// DecompressMessages returns text, e.g. a line of a log file, with the messages which
+// %CompressedMsg compressed restored.
+func DecompressMessages(text string) (string, error) {
+	var result strings.Builder
+	for {
+		start := strings.Index(text, CompressedMessagePrefix)
+		if start == -1 {
+			result.WriteString(text)
+			return result.String(), nil
+		}
+		result.WriteString(text[:start])
+		text = text[start+len(CompressedMessagePrefix):]
+
+		end := 0
+		for end < len(text) && isBase64Char(text[end]) {
+			end++
+		}
+		compressed, err := base64.StdEncoding.DecodeString(text[:end])
+		if err != nil {
+			return "", err
+		}
+		message, err := gunzipMessage(compressed)
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(message)
+		text = text[end:]
+	}
+}
+
+func isBase64Char(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/' || c == '='
+}
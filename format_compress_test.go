@@ -0,0 +1,97 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressedMsg(t *testing.T) {
+	formatter, err := newFormatter("[%Level] %CompressedMsg(100) end")
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := &logContext{callTime: time.Now()}
+
+	short := formatter.Format("short", InfoLvl, context)
+	if short != "[Info] short end" {
+		t.Errorf("Expected a short message to stay as is, got %q", short)
+	}
+
+	dump := strings.Repeat("payload ", 1000)
+	line := formatter.Format(dump, InfoLvl, context)
+	if !strings.HasPrefix(line, "[Info] "+CompressedMessagePrefix) || len(line) >= len(dump) {
+		t.Fatalf("Expected a compressed message, got %q", line)
+	}
+	if strings.ContainsAny(strings.TrimPrefix(strings.TrimSuffix(line, " end"), "[Info] "), " \n") {
+		t.Errorf("Expected no separators in the compressed message: %q", line)
+	}
+
+	restored, err := DecompressMessages(line + "\n" + line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[Info] " + dump + " end"
+	if restored != expected+"\n"+expected {
+		t.Errorf("Unexpected restored text: %.100q", restored)
+	}
+
+	if _, err := DecompressMessages(CompressedMessagePrefix + "AAAA"); err == nil {
+		t.Error("Expected an error for a corrupt message")
+	}
+}
+
+func TestCompressedMsgPack(t *testing.T) {
+	formatter, err := newFormatter("%CompressedMsgPack(100)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !formatter.isBinary() {
+		t.Error("Expected %CompressedMsgPack to be binary")
+	}
+	context := &logContext{callTime: time.Now(), fields: []Field{Str("user", "bob")}}
+
+	dump := strings.Repeat("payload ", 1000)
+	buf := new(bytes.Buffer)
+	buf.WriteString(formatter.Format(dump, ErrorLvl, context))
+	buf.WriteString(formatter.Format("short", InfoLvl, context))
+	if buf.Len() >= len(dump) {
+		t.Errorf("Expected the large message to be compressed, got %d bytes", buf.Len())
+	}
+
+	decoder := NewMsgPackDecoder(buf)
+	for _, expected := range []string{dump, "short"} {
+		record, err := decoder.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Message != expected || len(record.Fields) != 1 {
+			t.Errorf("Unexpected record: %.100v", record)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// FormatLocale describes how text formats render numbers and date names. It is set per
+// format with the 'locale' attribute:
+//     <format id="ops" format="%Date(2 January 2006) %Msg %Fields" locale="de"/>
+// Month and day names in %Date output and numbers in field values (%Field, %Fields)
+// are localized. Structured verbs (%Json, %MsgPack) always stay locale-neutral.
+type FormatLocale struct {
+	DecimalSeparator string     // Replaces '.' in fractional numbers
+	GroupSeparator   string     // Inserted between groups of three digits. Empty for no grouping.
+	MonthNames       [12]string // January..December
+	ShortMonthNames  [12]string // Jan..Dec
+	DayNames         [7]string  // Sunday..Saturday
+	ShortDayNames    [7]string  // Sun..Sat
+}
+
+var englishLocale = &FormatLocale{
+	DecimalSeparator: ".",
+	MonthNames: [12]string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"},
+	ShortMonthNames: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	DayNames:        [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	ShortDayNames:   [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+}
+
+var (
+	formatLocalesMutex sync.RWMutex
+	formatLocales      = map[string]*FormatLocale{
+		"en": englishLocale,
+		"de": {
+			DecimalSeparator: ",",
+			GroupSeparator:   ".",
+			MonthNames: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni",
+				"Juli", "August", "September", "Oktober", "November", "Dezember"},
+			ShortMonthNames: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+			DayNames:        [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+			ShortDayNames:   [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		},
+		"fr": {
+			DecimalSeparator: ",",
+			GroupSeparator:   " ",
+			MonthNames: [12]string{"janvier", "février", "mars", "avril", "mai", "juin",
+				"juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+			ShortMonthNames: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+			DayNames:        [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+			ShortDayNames:   [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		},
+		"es": {
+			DecimalSeparator: ",",
+			GroupSeparator:   ".",
+			MonthNames: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio",
+				"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+			ShortMonthNames: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sep", "oct", "nov", "dic"},
+			DayNames:        [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+			ShortDayNames:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		},
+	}
+)
+
+// RegisterFormatLocale makes a locale available to the 'locale' attribute of formats
+// under the given name. Registering an existing name replaces the locale.
+func RegisterFormatLocale(name string, locale *FormatLocale) error {
+	if name == "" {
+		return errors.New("Locale name can not be empty")
+	}
+	if locale == nil {
+		return errors.New("Locale can not be nil")
+	}
+
+	formatLocalesMutex.Lock()
+	defer formatLocalesMutex.Unlock()
+	formatLocales[name] = locale
+	return nil
+}
+
+func formatLocaleByName(name string) (*FormatLocale, bool) {
+	formatLocalesMutex.RLock()
+	defer formatLocalesMutex.RUnlock()
+	locale, ok := formatLocales[name]
+	return locale, ok
+}
+
+// Verbs which are not affected by the locale of a format
+var localeNeutralVerbs = map[string]bool{
+	"Json":              true,
+	"MsgPack":           true,
+	"CompressedMsgPack": true,
+	"Ns":                true,
+	"Stack":             true,
+	"UTCNs":             true,
+}
+
+// Verbs whose output contains month and day names
+var localeDateVerbs = map[string]bool{
+	"Date":    true,
+	"UTCDate": true,
+}
+
+// localizeVerbFunc wraps a verb so that it renders date names and field numbers
+// according to the locale.
+func (locale *FormatLocale) localizeVerbFunc(verb string, function verbFunc) verbFunc {
+	if localeNeutralVerbs[verb] {
+		return function
+	}
+
+	if localeDateVerbs[verb] {
+		return func(message string, level LogLevel, context logContextInterface) interface{} {
+			value := function(message, level, context)
+			str, ok := value.(string)
+			if !ok {
+				return value
+			}
+			return locale.localizeDateNames(str)
+		}
+	}
+
+	return func(message string, level LogLevel, context logContextInterface) interface{} {
+		return function(message, level, &localizedContext{context, locale})
+	}
+}
+
+// localizeDateNames replaces English month and day names produced by time.Format.
+func (locale *FormatLocale) localizeDateNames(str string) string {
+	var result strings.Builder
+	for i := 0; i < len(str); {
+		name, replacement := locale.findDateName(str[i:])
+		if name == "" {
+			result.WriteByte(str[i])
+			i++
+			continue
+		}
+		result.WriteString(replacement)
+		i += len(name)
+	}
+	return result.String()
+}
+
+func (locale *FormatLocale) findDateName(str string) (string, string) {
+	// Full names are checked first, as short names are their prefixes
+	for i, name := range englishLocale.MonthNames {
+		if strings.HasPrefix(str, name) {
+			return name, locale.MonthNames[i]
+		}
+	}
+	for i, name := range englishLocale.DayNames {
+		if strings.HasPrefix(str, name) {
+			return name, locale.DayNames[i]
+		}
+	}
+	for i, name := range englishLocale.ShortMonthNames {
+		if strings.HasPrefix(str, name) {
+			return name, locale.ShortMonthNames[i]
+		}
+	}
+	for i, name := range englishLocale.ShortDayNames {
+		if strings.HasPrefix(str, name) {
+			return name, locale.ShortDayNames[i]
+		}
+	}
+	return "", ""
+}
+
+// formatNumber renders the text representation of a number using the separators of the locale.
+func (locale *FormatLocale) formatNumber(number string) string {
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+
+	integer, fraction := number, ""
+	if i := strings.IndexAny(number, ".eE"); i != -1 {
+		integer, fraction = number[:i], number[i:]
+	}
+	if strings.HasPrefix(fraction, ".") {
+		fraction = locale.DecimalSeparator + fraction[1:]
+	}
+
+	if locale.GroupSeparator != "" && len(integer) > 3 {
+		var grouped strings.Builder
+		for i, digit := range integer {
+			if i > 0 && (len(integer)-i)%3 == 0 {
+				grouped.WriteString(locale.GroupSeparator)
+			}
+			grouped.WriteRune(digit)
+		}
+		integer = grouped.String()
+	}
+
+	return sign + integer + fraction
+}
+
+// localizedContext renders numeric field values as localized strings.
+type localizedContext struct {
+	logContextInterface
+	locale *FormatLocale
+}
+
+func (context *localizedContext) Fields() []Field {
+	return context.locale.localizeFields(context.logContextInterface.Fields())
+}
+
+func (locale *FormatLocale) localizeFields(fields []Field) []Field {
+	localized := make([]Field, len(fields))
+	for i, field := range fields {
+		switch value := field.Value.(type) {
+		case int64, uint64, float64:
+			field = Str(field.Key, locale.formatNumber(FieldValueString(value)))
+		}
+		if field.IsGroup() {
+			field = Group(field.Key, locale.localizeFields(field.Group)...)
+		}
+		localized[i] = field
+	}
+	return localized
+}
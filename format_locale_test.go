@@ -0,0 +1,80 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatLocale(t *testing.T) {
+	context, err := currentContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setContextFields(context, []Field{Float64("ratio", -1234.5), Group("http", Int("bytes", 1234567)), Str("path", "a.b")})
+
+	german, _ := formatLocaleByName("de")
+	formatter, err := newFormatterWithLocale("%Date(Monday, 2 January) %Fields %Field(http.bytes) %Json", german)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := german.localizeDateNames(time.Now().Format("Monday, 2 January"))
+	expected := date + " ratio=-1.234,5 http.bytes=1.234.567 path=a.b 1.234.567 {"
+	msg := formatter.Format("hi", InfoLvl, context)
+	if !strings.HasPrefix(msg, expected) {
+		t.Errorf("Expected prefix %q, got %q", expected, msg)
+	}
+	if !strings.Contains(msg, `"ratio":-1234.5,"http":{"bytes":1234567}`) {
+		t.Errorf("Json must stay locale-neutral: %s", msg)
+	}
+}
+
+func TestLocalizeDateNames(t *testing.T) {
+	french, _ := formatLocaleByName("fr")
+	moment := time.Date(2020, time.May, 6, 0, 0, 0, 0, time.UTC)
+
+	localized := french.localizeDateNames(moment.Format("Monday 2 January, Mon 2 Jan 2006"))
+	if localized != "mercredi 6 mai, mer. 6 mai 2020" {
+		t.Errorf("Unexpected localized date: %s", localized)
+	}
+}
+
+func TestFormatLocaleConfig(t *testing.T) {
+	config := `
+	<seelog type="sync">
+		<outputs formatid="local"><console/></outputs>
+		<formats><format id="local" format="%Msg" locale="%s"/></formats>
+	</seelog>`
+
+	if _, err := configFromReader(strings.NewReader(strings.Replace(config, "%s", "es", 1))); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if _, err := configFromReader(strings.NewReader(strings.Replace(config, "%s", "xx", 1))); err == nil {
+		t.Error("Expected error for an unknown locale")
+	}
+}
@@ -0,0 +1,636 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// %MsgPack writes every record as a MessagePack map, a compact binary alternative to
+// %Json for high-volume outputs. Records are self-delimiting, so a stream of them can
+// be read back with MsgPackDecoder. The map has the following keys:
+//     "time"   - call time as Unix nanoseconds (int)
+//     "level"  - level name (string)
+//     "msg"    - message (string)
+//     "msgz"   - message compressed with gzip (bin), instead of "msg" if the record was
+//                written with %CompressedMsgPack and the message is large
+//     "fields" - fields (map); groups are nested maps. Omitted if there are no fields.
+//     "stack"  - captured stack (array of maps with "func", "file" and "line" keys).
+//                Omitted if no stack was captured, see StackFrame.
+//
+// Example:
+//     <format id="binary" format="%MsgPack"/>
+
+// Record is a log record decoded by MsgPackDecoder or rendered by RenderConfig.
+// Caller info is not encoded by %MsgPack, so it is empty in decoded records; the
+// captured stack is.
+type Record struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+	Fields  []Field
+	Func    string // Function name, e.g. "main.main"
+	File    string // Path of the source file, e.g. "app/main.go"
+	Line    int
+	Stack   []StackFrame
+}
+
+// Keys of the record map
+const (
+	msgPackTimeKey              = "time"
+	msgPackLevelKey             = "level"
+	msgPackMessageKey           = "msg"
+	msgPackCompressedMessageKey = "msgz"
+	msgPackFieldsKey            = "fields"
+	msgPackStackKey             = "stack"
+
+	msgPackFrameFuncKey = "func"
+	msgPackFrameFileKey = "file"
+	msgPackFrameLineKey = "line"
+)
+
+func verbMsgPack(message string, level LogLevel, context logContextInterface) interface{} {
+	return string(appendMsgPackRecord(nil, message, level, context, 0))
+}
+
+// appendMsgPackRecord appends the record map. Messages longer than compressAbove bytes
+// are compressed, unless it is 0.
+func appendMsgPackRecord(buf []byte, message string, level LogLevel, context logContextInterface, compressAbove int) []byte {
+	fields := context.Fields()
+	fieldCount := countMsgPackFields(fields)
+
+	stack := context.Stack()
+
+	size := 3
+	if fieldCount > 0 {
+		size++
+	}
+	if len(stack) > 0 {
+		size++
+	}
+
+	buf = appendMsgPackMapHeader(buf, size)
+	buf = appendMsgPackString(buf, msgPackTimeKey)
+	buf = appendMsgPackInt(buf, context.CallTime().UnixNano())
+	buf = appendMsgPackString(buf, msgPackLevelKey)
+	buf = appendMsgPackString(buf, level.String())
+	if compressAbove > 0 && len(message) > compressAbove {
+		buf = appendMsgPackString(buf, msgPackCompressedMessageKey)
+		buf = appendMsgPackBin(buf, gzipMessage(message))
+	} else {
+		buf = appendMsgPackString(buf, msgPackMessageKey)
+		buf = appendMsgPackString(buf, message)
+	}
+	if fieldCount > 0 {
+		buf = appendMsgPackString(buf, msgPackFieldsKey)
+		buf = appendMsgPackFields(buf, fields, fieldCount)
+	}
+	if len(stack) > 0 {
+		buf = appendMsgPackString(buf, msgPackStackKey)
+		buf = appendMsgPackStack(buf, stack)
+	}
+
+	return buf
+}
+
+func appendMsgPackStack(buf []byte, stack []StackFrame) []byte {
+	buf = appendMsgPackArrayHeader(buf, len(stack))
+	for _, frame := range stack {
+		buf = appendMsgPackMapHeader(buf, 3)
+		buf = appendMsgPackString(buf, msgPackFrameFuncKey)
+		buf = appendMsgPackString(buf, frame.Func)
+		buf = appendMsgPackString(buf, msgPackFrameFileKey)
+		buf = appendMsgPackString(buf, frame.File)
+		buf = appendMsgPackString(buf, msgPackFrameLineKey)
+		buf = appendMsgPackInt(buf, int64(frame.Line))
+	}
+	return buf
+}
+
+// countMsgPackFields returns the number of map entries the fields produce, with
+// empty groups omitted and groups with empty keys inlined, as in %Json.
+func countMsgPackFields(fields []Field) int {
+	count := 0
+	for _, field := range fields {
+		switch {
+		case !field.IsGroup():
+			count++
+		case field.Key == "":
+			count += countMsgPackFields(field.Group)
+		case len(field.Group) > 0:
+			count++
+		}
+	}
+	return count
+}
+
+func appendMsgPackFields(buf []byte, fields []Field, count int) []byte {
+	buf = appendMsgPackMapHeader(buf, count)
+	return appendMsgPackFieldEntries(buf, fields)
+}
+
+func appendMsgPackFieldEntries(buf []byte, fields []Field) []byte {
+	for _, field := range fields {
+		if !field.IsGroup() {
+			buf = appendMsgPackString(buf, field.Key)
+			buf = appendMsgPackValue(buf, field.Value)
+			continue
+		}
+		if field.Key == "" {
+			buf = appendMsgPackFieldEntries(buf, field.Group)
+			continue
+		}
+		if len(field.Group) == 0 {
+			continue
+		}
+
+		buf = appendMsgPackString(buf, field.Key)
+		buf = appendMsgPackFields(buf, field.Group, countMsgPackFields(field.Group))
+	}
+	return buf
+}
+
+// appendMsgPackValue writes numbers, booleans and nil as MessagePack values of the same
+// type, times using the timestamp extension type and other values as strings.
+func appendMsgPackValue(buf []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0xc0)
+	case string:
+		return appendMsgPackString(buf, v)
+	case int64:
+		return appendMsgPackInt(buf, v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return appendMsgPackInt(buf, int64(v))
+		}
+		buf = append(buf, 0xcf)
+		return binary.BigEndian.AppendUint64(buf, v)
+	case float64:
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+	case bool:
+		if v {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case time.Time:
+		// timestamp 96: ext 8, length 12, type -1, nanoseconds uint32, seconds int64
+		buf = append(buf, 0xc7, 12, 0xff)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(v.Nanosecond()))
+		return binary.BigEndian.AppendUint64(buf, uint64(v.Unix()))
+	case []StackFrame:
+		return appendMsgPackStack(buf, v)
+	}
+	return appendMsgPackString(buf, FieldValueString(value))
+}
+
+func appendMsgPackMapHeader(buf []byte, size int) []byte {
+	switch {
+	case size < 16:
+		return append(buf, 0x80|byte(size))
+	case size <= math.MaxUint16:
+		return append(buf, 0xde, byte(size>>8), byte(size))
+	}
+	return append(buf, 0xdf, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+}
+
+func appendMsgPackArrayHeader(buf []byte, size int) []byte {
+	switch {
+	case size < 16:
+		return append(buf, 0x90|byte(size))
+	case size <= math.MaxUint16:
+		return append(buf, 0xdc, byte(size>>8), byte(size))
+	}
+	return append(buf, 0xdd, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+}
+
+func appendMsgPackString(buf []byte, str string) []byte {
+	size := len(str)
+	switch {
+	case size < 32:
+		buf = append(buf, 0xa0|byte(size))
+	case size <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(size))
+	case size <= math.MaxUint16:
+		buf = append(buf, 0xda, byte(size>>8), byte(size))
+	default:
+		buf = append(buf, 0xdb, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+	}
+	return append(buf, str...)
+}
+
+func appendMsgPackBin(buf []byte, data []byte) []byte {
+	size := len(data)
+	switch {
+	case size <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(size))
+	case size <= math.MaxUint16:
+		buf = append(buf, 0xc5, byte(size>>8), byte(size))
+	default:
+		buf = append(buf, 0xc6, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+	}
+	return append(buf, data...)
+}
+
+func appendMsgPackInt(buf []byte, value int64) []byte {
+	if value >= 0 && value < 128 {
+		return append(buf, byte(value))
+	}
+	if value < 0 && value >= -32 {
+		return append(buf, byte(value))
+	}
+
+	buf = append(buf, 0xd3)
+	return binary.BigEndian.AppendUint64(buf, uint64(value))
+}
+
+// MsgPackDecoder reads records written with the %MsgPack format from a stream.
+type MsgPackDecoder struct {
+	reader *bufio.Reader
+}
+
+// NewMsgPackDecoder creates a decoder which reads records from reader.
+func NewMsgPackDecoder(reader io.Reader) *MsgPackDecoder {
+	return &MsgPackDecoder{bufio.NewReader(reader)}
+}
+
+// Decode reads the next record. It returns io.EOF when there are no more records.
+func (decoder *MsgPackDecoder) Decode() (*Record, error) {
+	if _, err := decoder.reader.Peek(1); err != nil {
+		return nil, err
+	}
+
+	size, err := decoder.readMapHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	record := new(Record)
+	for i := 0; i < size; i++ {
+		key, err := decoder.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case msgPackTimeKey:
+			value, err := decoder.readValue()
+			if err != nil {
+				return nil, err
+			}
+			nanoseconds, ok := value.(int64)
+			if !ok {
+				return nil, errors.New("Invalid record time: " + fmt.Sprint(value))
+			}
+			record.Time = time.Unix(0, nanoseconds)
+		case msgPackLevelKey:
+			levelStr, err := decoder.readString()
+			if err != nil {
+				return nil, err
+			}
+			level, found := LogLevelFromString(levelStr)
+			if !found {
+				return nil, errors.New("Invalid record level: " + levelStr)
+			}
+			record.Level = level
+		case msgPackMessageKey:
+			record.Message, err = decoder.readString()
+			if err != nil {
+				return nil, err
+			}
+		case msgPackCompressedMessageKey:
+			compressed, err := decoder.readString()
+			if err != nil {
+				return nil, err
+			}
+			record.Message, err = gunzipMessage([]byte(compressed))
+			if err != nil {
+				return nil, err
+			}
+		case msgPackFieldsKey:
+			record.Fields, err = decoder.readFields()
+			if err != nil {
+				return nil, err
+			}
+		case msgPackStackKey:
+			value, err := decoder.readValue()
+			if err != nil {
+				return nil, err
+			}
+			record.Stack, err = stackFromMsgPack(value)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			// Unknown keys are skipped to allow the format to be extended
+			if _, err := decoder.readValue(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return record, nil
+}
+
+// stackFromMsgPack converts a decoded "stack" array to stack frames.
+func stackFromMsgPack(value interface{}) ([]StackFrame, error) {
+	frames, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("Invalid record stack: " + fmt.Sprint(value))
+	}
+
+	stack := make([]StackFrame, len(frames))
+	for i, frameValue := range frames {
+		frame, ok := frameValue.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("Invalid record stack frame: " + fmt.Sprint(frameValue))
+		}
+		stack[i].Func, _ = frame[msgPackFrameFuncKey].(string)
+		stack[i].File, _ = frame[msgPackFrameFileKey].(string)
+		line, _ := frame[msgPackFrameLineKey].(int64)
+		stack[i].Line = int(line)
+	}
+	return stack, nil
+}
+
+func (decoder *MsgPackDecoder) readFields() ([]Field, error) {
+	size, err := decoder.readMapHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]Field, 0, size)
+	for i := 0; i < size; i++ {
+		key, err := decoder.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		if decoder.nextIsMap() {
+			group, err := decoder.readFields()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, Group(key, group...))
+			continue
+		}
+
+		value, err := decoder.readValue()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+
+	return fields, nil
+}
+
+func (decoder *MsgPackDecoder) nextIsMap() bool {
+	next, err := decoder.reader.Peek(1)
+	if err != nil {
+		return false
+	}
+	return next[0]&0xf0 == 0x80 || next[0] == 0xde || next[0] == 0xdf
+}
+
+func (decoder *MsgPackDecoder) readMapHeader() (int, error) {
+	head, err := decoder.reader.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	switch {
+	case head&0xf0 == 0x80:
+		return int(head & 0x0f), nil
+	case head == 0xde:
+		size, err := decoder.readUint(2)
+		return int(size), err
+	case head == 0xdf:
+		size, err := decoder.readUint(4)
+		return int(size), err
+	}
+
+	return 0, fmt.Errorf("MsgPack: expected map, got type 0x%02x", head)
+}
+
+func (decoder *MsgPackDecoder) readString() (string, error) {
+	value, err := decoder.readValue()
+	if err != nil {
+		return "", err
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("MsgPack: expected string, got %T", value)
+	}
+	return str, nil
+}
+
+// readValue reads a value of any type. Integers are read as int64 (uint64 if they
+// do not fit), floats as float64,
+// timestamps as time.Time, maps and arrays as map[string]interface{} and []interface{}.
+func (decoder *MsgPackDecoder) readValue() (interface{}, error) {
+	head, err := decoder.reader.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	switch {
+	case head <= 0x7f:
+		return int64(head), nil
+	case head >= 0xe0:
+		return int64(int8(head)), nil
+	case head&0xe0 == 0xa0:
+		return decoder.readStringBody(int(head & 0x1f))
+	case head&0xf0 == 0x80:
+		return decoder.readMap(int(head & 0x0f))
+	case head&0xf0 == 0x90:
+		return decoder.readArray(int(head & 0x0f))
+	}
+
+	switch head {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		size, err := decoder.readUint(1)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readStringBody(int(size))
+	case 0xc5, 0xda:
+		size, err := decoder.readUint(2)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readStringBody(int(size))
+	case 0xc6, 0xdb:
+		size, err := decoder.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readStringBody(int(size))
+	case 0xca:
+		bits, err := decoder.readUint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 0xcb:
+		bits, err := decoder.readUint(8)
+		return math.Float64frombits(bits), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		value, err := decoder.readUint(1 << (head - 0xcc))
+		if value > math.MaxInt64 {
+			return value, err
+		}
+		return int64(value), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (head - 0xd0)
+		value, err := decoder.readUint(size)
+		shift := uint(64 - 8*size)
+		return int64(value<<shift) >> shift, err
+	case 0xd6, 0xd7, 0xc7:
+		return decoder.readTimestamp(head)
+	case 0xdc, 0xdd:
+		size, err := decoder.readUint(2 << (head - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readArray(int(size))
+	case 0xde, 0xdf:
+		size, err := decoder.readUint(2 << (head - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readMap(int(size))
+	}
+
+	return nil, fmt.Errorf("MsgPack: unsupported type 0x%02x", head)
+}
+
+// readTimestamp reads a value of the timestamp extension type (-1) in any of its
+// 32, 64 and 96 bit forms, or a Fluentd EventTime (extension type 0, see fluentWriter).
+func (decoder *MsgPackDecoder) readTimestamp(head byte) (interface{}, error) {
+	size := 4
+	if head == 0xd7 {
+		size = 8
+	} else if head == 0xc7 {
+		length, err := decoder.readUint(1)
+		if err != nil {
+			return nil, err
+		}
+		size = int(length)
+	}
+
+	extType, err := decoder.readUint(1)
+	if err != nil {
+		return nil, err
+	}
+	if extType == fluentEventTimeType && size == 8 {
+		data, err := decoder.readUint(8)
+		return time.Unix(int64(data>>32), int64(data&0xffffffff)), err
+	}
+	if int8(extType) != -1 {
+		return nil, fmt.Errorf("MsgPack: unsupported extension type %d", int8(extType))
+	}
+
+	switch size {
+	case 4:
+		seconds, err := decoder.readUint(4)
+		return time.Unix(int64(seconds), 0), err
+	case 8:
+		data, err := decoder.readUint(8)
+		return time.Unix(int64(data&0x3ffffffff), int64(data>>34)), err
+	case 12:
+		nanoseconds, err := decoder.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		seconds, err := decoder.readUint(8)
+		return time.Unix(int64(seconds), int64(nanoseconds)), err
+	}
+
+	return nil, fmt.Errorf("MsgPack: invalid timestamp length %d", size)
+}
+
+func (decoder *MsgPackDecoder) readStringBody(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(decoder.reader, data); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return string(data), nil
+}
+
+func (decoder *MsgPackDecoder) readMap(size int) (interface{}, error) {
+	result := make(map[string]interface{}, size)
+	for i := 0; i < size; i++ {
+		key, err := decoder.readString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := decoder.readValue()
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func (decoder *MsgPackDecoder) readArray(size int) (interface{}, error) {
+	result := make([]interface{}, size)
+	for i := range result {
+		value, err := decoder.readValue()
+		if err != nil {
+			return nil, err
+		}
+		result[i] = value
+	}
+	return result, nil
+}
+
+func (decoder *MsgPackDecoder) readUint(size int) (uint64, error) {
+	var data [8]byte
+	if _, err := io.ReadFull(decoder.reader, data[8-size:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.BigEndian.Uint64(data[:]), nil
+}
+
+// unexpectedEOF converts io.EOF met inside of a record to io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsgPackRoundTrip(t *testing.T) {
+	context, err := currentContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formatter, err := newFormatter("%MsgPack")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moment := time.Date(2020, 1, 2, 3, 4, 5, 6, time.Local)
+	fields := []Field{
+		Str("user", "bob"),
+		Group("http", Str("method", "GET"), Group("empty"), Group("", Int("status", 200))),
+		Str("long", strings.Repeat("x", 300)),
+		Int("negative", -100000),
+		Uint64("big", math.MaxUint64),
+		Float64("ratio", 0.25),
+		Bool("ok", false),
+		Time("at", moment),
+		Err("err", errors.New("failed")),
+		Err("noerr", nil),
+	}
+	expectedFields := []Field{
+		Str("user", "bob"),
+		Group("http", Str("method", "GET"), Int("status", 200)),
+		Str("long", strings.Repeat("x", 300)),
+		Int("negative", -100000),
+		Uint64("big", math.MaxUint64),
+		Float64("ratio", 0.25),
+		Bool("ok", false),
+		Time("at", moment),
+		Str("err", "failed"),
+		Err("noerr", nil),
+	}
+
+	stream := new(bytes.Buffer)
+	stream.WriteString(formatter.Format("plain", DebugLvl, context))
+	setContextFields(context, fields)
+	stream.WriteString(formatter.Format("with fields", ErrorLvl, context))
+
+	decoder := NewMsgPackDecoder(stream)
+
+	record, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Message != "plain" || record.Level != DebugLvl || len(record.Fields) != 0 ||
+		!record.Time.Equal(context.CallTime()) {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+
+	record, err = decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Message != "with fields" || record.Level != ErrorLvl || !reflect.DeepEqual(record.Fields, expectedFields) {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+
+	if _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	truncated := formatter.Format("truncated", InfoLvl, context)
+	decoder = NewMsgPackDecoder(strings.NewReader(truncated[:len(truncated)-1]))
+	if _, err := decoder.Decode(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
@@ -122,6 +122,9 @@ var formatTests = []formatTest{
 	{"%Lev%Msg%LEVEL%LEV%l%Msg", "Test", InfoLvl, "InfTestINFOINFiTest", false},
 	{"%n", "", CriticalLvl, "\n", false},
 	{"%t", "", CriticalLvl, "\t", false},
+	{"%LevelColor%Msg%EscM(0)", "A", ErrorLvl, "\x1b[31mA\x1b[0m", false},
+	{"%EscM(1;33)%Level", "", WarnLvl, "\x1b[1;33mWarn", false},
+	{"%QueueDepth", "", InfoLvl, "0", false},
 }
 
 func TestFormats(t *testing.T) {
@@ -186,3 +189,60 @@ func TestDateParametrizedFormat(t *testing.T) {
 		t.Errorf("Incorrect message: %v. Expected %v or %v", msg, dateBefore, dateAfter)
 	}
 }
+
+func TestFieldsFormat(t *testing.T) {
+	context, conErr := currentContext()
+	if conErr != nil {
+		t.Fatal("Cannot get current context:" + conErr.Error())
+		return
+	}
+	setContextFields(context, []Field{Str("user", "bob"), Str("id", "42")})
+
+	fieldsTests := map[string]string{
+		"%Msg %Fields":                     "msg user=bob id=42",
+		"%Msg [%Fields(sep=, )]":           "msg [user=bob, id=42]",
+		"%Msg %Fields(order=sorted)":       "msg id=42 user=bob",
+		"%Msg %Fields(sep=|;order=sorted)": "msg id=42|user=bob",
+	}
+
+	for format, expected := range fieldsTests {
+		form, err := newFormatter(format)
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+			continue
+		}
+
+		msg := form.Format("msg", TraceLvl, context)
+		if msg != expected {
+			t.Errorf("Format: %s. Expected %q, got %q", format, expected, msg)
+		}
+	}
+
+	for _, format := range []string{"%Fields(order=reverse)", "%Fields(sort=true)", "%Fields(sep=,;color)"} {
+		if _, err := newFormatter(format); err == nil || !strings.Contains(err.Error(), "Format error") {
+			t.Errorf("Format: %s. Expected a format error, got %v", format, err)
+		}
+	}
+}
+
+func TestPipelineFormat(t *testing.T) {
+	context := &logContext{callTime: time.Now().Add(-2 * time.Second)}
+	context.setQueueDepth(3)
+
+	form, err := newFormatter("%QueueDepth %DispatchLatency")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	parts := strings.Fields(form.Format("msg", InfoLvl, context))
+	if len(parts) != 2 || parts[0] != "3" {
+		t.Fatalf("Unexpected output: %q", parts)
+	}
+	latency, err := time.ParseDuration(parts[1])
+	if err != nil {
+		t.Fatalf("Cannot parse latency %q: %s", parts[1], err)
+	}
+	if latency < 2*time.Second {
+		t.Errorf("Expected latency of at least 2s, got %s", latency)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAwsImdsEndpoint    = "http://169.254.169.254"
+	defaultAwsEcsEndpoint     = "http://169.254.170.2"
+	awsImdsTokenTTL           = "21600"
+	awsCredentialsEarlyExpiry = 5 * time.Minute
+)
+
+// awsCredentials are the keys which sign AWS requests. sessionToken is set for
+// temporary credentials, which expire.
+type awsCredentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	expires      time.Time
+}
+
+// awsCredentialsProvider provides the credentials of AWS requests: the given keys, or
+// else the keys in the environment (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, as in Lambda), the task role of an ECS or Fargate task
+// (AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or _FULL_URI), or the instance role of an EC2
+// instance from the instance metadata service (IMDSv2). Temporary credentials are
+// cached until shortly before they expire.
+type awsCredentialsProvider struct {
+	static       *awsCredentials
+	imdsEndpoint string
+	client       *http.Client
+
+	mutex  sync.Mutex
+	cached *awsCredentials
+}
+
+func newAwsCredentialsProvider(accessKey, secretKey string) *awsCredentialsProvider {
+	provider := &awsCredentialsProvider{
+		imdsEndpoint: defaultAwsImdsEndpoint,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+	if accessKey == "" {
+		accessKey, secretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if accessKey != "" && secretKey != "" {
+			provider.static = &awsCredentials{accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), time.Time{}}
+		}
+	} else {
+		provider.static = &awsCredentials{accessKey: accessKey, secretKey: secretKey}
+	}
+	return provider
+}
+
+// credentials returns valid credentials, getting new ones if needed.
+func (provider *awsCredentialsProvider) credentials() (*awsCredentials, error) {
+	if provider.static != nil {
+		return provider.static, nil
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.cached != nil && time.Now().Before(provider.cached.expires) {
+		return provider.cached, nil
+	}
+
+	var credentials *awsCredentials
+	var err error
+	if os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" || os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "" {
+		credentials, err = provider.containerCredentials()
+	} else {
+		credentials, err = provider.instanceCredentials()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get AWS credentials: %s", err)
+	}
+	credentials.expires = credentials.expires.Add(-awsCredentialsEarlyExpiry)
+	provider.cached = credentials
+	return credentials, nil
+}
+
+// containerCredentials gets the credentials of the task role of an ECS task.
+func (provider *awsCredentialsProvider) containerCredentials() (*awsCredentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = defaultAwsEcsEndpoint + relative
+	}
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		request.Header.Set("Authorization", token)
+	}
+	body, err := provider.do(request)
+	if err != nil {
+		return nil, err
+	}
+	return parseAwsCredentials(body)
+}
+
+// instanceCredentials gets the credentials of the instance role of an EC2 instance.
+func (provider *awsCredentialsProvider) instanceCredentials() (*awsCredentials, error) {
+	request, err := http.NewRequest(http.MethodPut, provider.imdsEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", awsImdsTokenTTL)
+	token, err := provider.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	rolesURL := provider.imdsEndpoint + "/latest/meta-data/iam/security-credentials/"
+	request, err = http.NewRequest(http.MethodGet, rolesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-aws-ec2-metadata-token", string(token))
+	roles, err := provider.do(request)
+	if err != nil {
+		return nil, err
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return nil, errors.New("the instance has no role")
+	}
+
+	request, err = http.NewRequest(http.MethodGet, rolesURL+url.PathEscape(role), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-aws-ec2-metadata-token", string(token))
+	body, err := provider.do(request)
+	if err != nil {
+		return nil, err
+	}
+	return parseAwsCredentials(body)
+}
+
+func (provider *awsCredentialsProvider) do(request *http.Request) ([]byte, error) {
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", request.URL.Path, response.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func parseAwsCredentials(body []byte) (*awsCredentials, error) {
+	var result struct {
+		AccessKeyId     string
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.AccessKeyId == "" || result.SecretAccessKey == "" {
+		return nil, errors.New("unexpected credentials response")
+	}
+	return &awsCredentials{result.AccessKeyId, result.SecretAccessKey, result.Token, result.Expiration}, nil
+}
+
+func (provider *awsCredentialsProvider) String() string {
+	if provider.static != nil {
+		return "key " + provider.static.accessKey
+	}
+	return "role"
+}
+
+// signAwsRequest signs a request with AWS Signature Version 4 for a service in a region.
+// The host, the Content-Type header and the X-Amz-* headers are signed; X-Amz-Date,
+// and X-Amz-Security-Token for temporary credentials, are set first.
+func signAwsRequest(request *http.Request, body []byte, credentials *awsCredentials, region, service string,
+	now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	request.Header.Set("X-Amz-Date", amzDate)
+	if credentials.sessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.sessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := request.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = sha256Hex(body)
+	}
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		path,
+		strings.Replace(request.URL.Query().Encode(), "+", "%20", -1),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+credentials.secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+credentials.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
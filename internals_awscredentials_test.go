@@ -0,0 +1,136 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignAwsRequest(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite
+	request, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	credentials := &awsCredentials{accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAwsRequest(request, nil, credentials, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := request.Header.Get("Authorization"); auth != expected {
+		t.Errorf("Expected %s, got %s", expected, auth)
+	}
+
+	credentials.sessionToken = "session"
+	request, _ = http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signAwsRequest(request, nil, credentials, "us-east-1", "service", time.Now())
+	if request.Header.Get("X-Amz-Security-Token") != "session" {
+		t.Errorf("Expected the session token, got %v", request.Header)
+	}
+}
+
+func TestAwsCredentialsEnvironment(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	credentials, err := newAwsCredentialsProvider("", "").credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.accessKey != "AKID" || credentials.secretKey != "secret" || credentials.sessionToken != "session" {
+		t.Errorf("Unexpected credentials: %+v", credentials)
+	}
+	if credentials, _ := newAwsCredentialsProvider("KEY", "SECRET").credentials(); credentials.accessKey != "KEY" {
+		t.Errorf("Expected the given keys, got %+v", credentials)
+	}
+}
+
+func TestAwsCredentialsContainer(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/creds" || r.Header.Get("Authorization") != "task-token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"AccessKeyId":"ASIA","SecretAccessKey":"secret","Token":"session",` +
+			`"Expiration":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}`))
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL+"/creds")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "task-token")
+
+	provider := newAwsCredentialsProvider("", "")
+	for i := 0; i < 2; i++ {
+		credentials, err := provider.credentials()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if credentials.accessKey != "ASIA" || credentials.sessionToken != "session" {
+			t.Errorf("Unexpected credentials: %+v", credentials)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the credentials to be cached, got %d requests", requests)
+	}
+}
+
+func TestAwsCredentialsInstance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Write([]byte("imds-token"))
+		case r.Header.Get("X-aws-ec2-metadata-token") != "imds-token":
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("app-role\n"))
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/app-role":
+			w.Write([]byte(`{"Code":"Success","AccessKeyId":"ASIA","SecretAccessKey":"secret","Token":"session",` +
+				`"Expiration":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+
+	provider := newAwsCredentialsProvider("", "")
+	provider.imdsEndpoint = server.URL
+	credentials, err := provider.credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.accessKey != "ASIA" || credentials.secretKey != "secret" || credentials.sessionToken != "session" {
+		t.Errorf("Unexpected credentials: %+v", credentials)
+	}
+	if time.Until(credentials.expires) > time.Hour-awsCredentialsEarlyExpiry {
+		t.Errorf("Expected the credentials to expire early, got %s", credentials.expires)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAzureImdsEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureImdsAPIVersion      = "2018-02-01"
+	azureAppServiceVersion   = "2019-08-01"
+	azureTokenEarlyExpiry    = 5 * time.Minute
+)
+
+// azureManagedIdentity gets access tokens for a resource with the managed identity of
+// an Azure VM, scale set, AKS node pool or Container Instance from the instance metadata
+// service, or of an App Service or Functions app from the endpoint at IDENTITY_ENDPOINT.
+// clientID selects a user-assigned identity; if it is empty the system-assigned one is
+// used. Tokens are cached until shortly before they expire.
+type azureManagedIdentity struct {
+	endpoint string
+	header   string
+	version  string
+	clientID string
+	resource string
+	client   *http.Client
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newAzureManagedIdentity(clientID, resource string) *azureManagedIdentity {
+	identity := &azureManagedIdentity{
+		endpoint: defaultAzureImdsEndpoint,
+		version:  azureImdsAPIVersion,
+		clientID: clientID,
+		resource: resource,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+	if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && header != "" {
+		identity.endpoint = endpoint
+		identity.header = header
+		identity.version = azureAppServiceVersion
+	}
+	return identity
+}
+
+// accessToken returns a valid access token, getting a new one if needed.
+func (identity *azureManagedIdentity) accessToken() (string, error) {
+	identity.mutex.Lock()
+	defer identity.mutex.Unlock()
+
+	now := time.Now()
+	if identity.token != "" && now.Before(identity.expires) {
+		return identity.token, nil
+	}
+
+	query := url.Values{"api-version": {identity.version}, "resource": {identity.resource}}
+	if identity.clientID != "" {
+		query.Set("client_id", identity.clientID)
+	}
+	request, err := http.NewRequest(http.MethodGet, identity.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if identity.header != "" {
+		request.Header.Set("X-IDENTITY-HEADER", identity.header)
+	} else {
+		request.Header.Set("Metadata", "true")
+	}
+
+	response, err := identity.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("Cannot get an Azure access token: %s", err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Cannot get an Azure access token: %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	// The numbers come as strings from both endpoints
+	var token struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+		ExpiresOn   json.Number `json:"expires_on"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", errors.New("Cannot get an Azure access token: unexpected response")
+	}
+
+	expires := now.Add(time.Hour)
+	if seconds, err := strconv.ParseInt(string(token.ExpiresIn), 10, 64); err == nil {
+		expires = now.Add(time.Duration(seconds) * time.Second)
+	} else if unix, err := strconv.ParseInt(string(token.ExpiresOn), 10, 64); err == nil {
+		expires = time.Unix(unix, 0)
+	}
+	identity.token = token.AccessToken
+	identity.expires = expires.Add(-azureTokenEarlyExpiry)
+	return identity.token, nil
+}
+
+func (identity *azureManagedIdentity) String() string {
+	if identity.clientID != "" {
+		return "managed identity " + identity.clientID
+	}
+	return "managed identity"
+}
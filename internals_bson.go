@@ -0,0 +1,243 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// bsonDocument is a BSON document with its elements in order, as commands need.
+type bsonDocument []bsonElement
+
+type bsonElement struct {
+	key   string
+	value interface{}
+}
+
+// bsonObjectID is a BSON ObjectId.
+type bsonObjectID [12]byte
+
+// appendBsonDocument encodes a document. Values may be nil, strings, int32, int, int64,
+// uint64, float64, bool, time.Time, []byte (generic binary), bsonObjectID, bsonDocument
+// and []interface{} (arrays); other values are encoded as strings.
+func appendBsonDocument(buf []byte, document bsonDocument) []byte {
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0)
+	for _, element := range document {
+		buf = appendBsonElement(buf, element.key, element.value)
+	}
+	buf = append(buf, 0)
+	binary.LittleEndian.PutUint32(buf[start:], uint32(len(buf)-start))
+	return buf
+}
+
+func appendBsonElement(buf []byte, key string, value interface{}) []byte {
+	header := func(kind byte) []byte {
+		return append(append(append(buf, kind), key...), 0)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return header(0x0a)
+	case string:
+		return appendBsonString(header(0x02), v)
+	case int32:
+		return binary.LittleEndian.AppendUint32(header(0x10), uint32(v))
+	case int:
+		return binary.LittleEndian.AppendUint64(header(0x12), uint64(v))
+	case int64:
+		return binary.LittleEndian.AppendUint64(header(0x12), uint64(v))
+	case uint64:
+		if v <= math.MaxInt64 {
+			return binary.LittleEndian.AppendUint64(header(0x12), v)
+		}
+		return binary.LittleEndian.AppendUint64(header(0x01), math.Float64bits(float64(v)))
+	case float64:
+		return binary.LittleEndian.AppendUint64(header(0x01), math.Float64bits(v))
+	case bool:
+		if v {
+			return append(header(0x08), 1)
+		}
+		return append(header(0x08), 0)
+	case time.Time:
+		return binary.LittleEndian.AppendUint64(header(0x09), uint64(v.UnixNano()/int64(time.Millisecond)))
+	case []byte:
+		buf = binary.LittleEndian.AppendUint32(header(0x05), uint32(len(v)))
+		return append(append(buf, 0), v...)
+	case bsonObjectID:
+		return append(header(0x07), v[:]...)
+	case bsonDocument:
+		return appendBsonDocument(header(0x03), v)
+	case []interface{}:
+		array := make(bsonDocument, len(v))
+		for i, item := range v {
+			array[i] = bsonElement{fmt.Sprint(i), item}
+		}
+		return appendBsonDocument(header(0x04), array)
+	}
+	return appendBsonString(header(0x02), FieldValueString(value))
+}
+
+func appendBsonString(buf []byte, str string) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(str)+1))
+	return append(append(buf, str...), 0)
+}
+
+// bsonFields converts fields into the elements of a document; groups become nested
+// documents. Errors and stacks become strings, like other values without a BSON type.
+func bsonFields(fields []Field) bsonDocument {
+	var document bsonDocument
+	for _, field := range fields {
+		if !field.IsGroup() {
+			value := field.Value
+			switch value.(type) {
+			case nil, string, int64, uint64, float64, bool, time.Time:
+			default:
+				value = FieldValueString(value)
+			}
+			document = append(document, bsonElement{field.Key, value})
+			continue
+		}
+		if field.Key == "" {
+			document = append(document, bsonFields(field.Group)...)
+			continue
+		}
+		if len(field.Group) > 0 {
+			document = append(document, bsonElement{field.Key, bsonFields(field.Group)})
+		}
+	}
+	return document
+}
+
+var errBsonMalformed = errors.New("Malformed BSON document")
+
+// readBsonDocument decodes a document into a map. Nested documents are maps too, arrays
+// are []interface{}, binary values and ObjectIds []byte, int32 values int32 and int64 and
+// timestamp values int64. It returns the size of the document.
+func readBsonDocument(data []byte) (map[string]interface{}, int, error) {
+	if len(data) < 5 {
+		return nil, 0, errBsonMalformed
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size < 5 || size > len(data) || data[size-1] != 0 {
+		return nil, 0, errBsonMalformed
+	}
+
+	document := make(map[string]interface{})
+	body := data[4 : size-1]
+	for len(body) > 0 {
+		kind := body[0]
+		end := 1
+		for end < len(body) && body[end] != 0 {
+			end++
+		}
+		if end == len(body) {
+			return nil, 0, errBsonMalformed
+		}
+		key := string(body[1:end])
+		body = body[end+1:]
+
+		value, n, err := readBsonValue(kind, body)
+		if err != nil {
+			return nil, 0, err
+		}
+		document[key] = value
+		body = body[n:]
+	}
+	return document, size, nil
+}
+
+func readBsonValue(kind byte, body []byte) (interface{}, int, error) {
+	fixed := map[byte]int{0x01: 8, 0x07: 12, 0x08: 1, 0x09: 8, 0x0a: 0, 0x10: 4, 0x11: 8, 0x12: 8, 0x13: 16}
+	if size, ok := fixed[kind]; ok && len(body) < size {
+		return nil, 0, errBsonMalformed
+	}
+
+	switch kind {
+	case 0x01:
+		return math.Float64frombits(binary.LittleEndian.Uint64(body)), 8, nil
+	case 0x02:
+		if len(body) < 4 {
+			return nil, 0, errBsonMalformed
+		}
+		size := int(binary.LittleEndian.Uint32(body))
+		if size < 1 || 4+size > len(body) {
+			return nil, 0, errBsonMalformed
+		}
+		return string(body[4 : 4+size-1]), 4 + size, nil
+	case 0x03, 0x04:
+		document, size, err := readBsonDocument(body)
+		if err != nil || kind == 0x03 {
+			return document, size, err
+		}
+		array := make([]interface{}, len(document))
+		for i := range array {
+			array[i] = document[fmt.Sprint(i)]
+		}
+		return array, size, nil
+	case 0x05:
+		if len(body) < 5 {
+			return nil, 0, errBsonMalformed
+		}
+		size := int(binary.LittleEndian.Uint32(body))
+		if size < 0 || 5+size > len(body) {
+			return nil, 0, errBsonMalformed
+		}
+		return body[5 : 5+size], 5 + size, nil
+	case 0x07:
+		return body[:12], 12, nil
+	case 0x08:
+		return body[0] != 0, 1, nil
+	case 0x09:
+		millis := int64(binary.LittleEndian.Uint64(body))
+		return time.Unix(0, millis*int64(time.Millisecond)), 8, nil
+	case 0x0a:
+		return nil, 0, nil
+	case 0x10:
+		return int32(binary.LittleEndian.Uint32(body)), 4, nil
+	case 0x11, 0x12:
+		return int64(binary.LittleEndian.Uint64(body)), 8, nil
+	case 0x13:
+		return body[:16], 16, nil
+	}
+	return nil, 0, fmt.Errorf("Unsupported BSON type 0x%02x", kind)
+}
+
+// bsonNumber returns a numeric value of a decoded document as an int64.
+func bsonNumber(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBsonRoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 123000000)
+	id := bsonObjectID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	encoded := appendBsonDocument(nil, bsonDocument{
+		{"null", nil},
+		{"string", "héllo"},
+		{"int32", int32(-7)},
+		{"int64", int64(1) << 40},
+		{"uint64", uint64(math.MaxUint64)},
+		{"double", 2.5},
+		{"bool", true},
+		{"time", now},
+		{"binary", []byte{0, 1}},
+		{"id", id},
+		{"document", bsonDocument{{"nested", "yes"}}},
+		{"array", []interface{}{"a", int32(1)}},
+		{"other", time.Second},
+	})
+
+	document, size, err := readBsonDocument(append(encoded, 0xff))
+	if err != nil || size != len(encoded) {
+		t.Fatalf("Cannot decode: %v, size %d of %d", err, size, len(encoded))
+	}
+	expected := map[string]interface{}{
+		"null":     nil,
+		"string":   "héllo",
+		"int32":    int32(-7),
+		"int64":    int64(1) << 40,
+		"uint64":   float64(math.MaxUint64),
+		"double":   2.5,
+		"bool":     true,
+		"time":     now,
+		"binary":   []byte{0, 1},
+		"id":       id[:],
+		"document": map[string]interface{}{"nested": "yes"},
+		"array":    []interface{}{"a", int32(1)},
+		"other":    "1s",
+	}
+	if !reflect.DeepEqual(document, expected) {
+		t.Errorf("Expected %v, got %v", expected, document)
+	}
+
+	for _, malformed := range [][]byte{nil, encoded[:len(encoded)-1], {5, 0, 0, 0, 1}, {9, 0, 0, 0, 0x7f, 'k', 0, 0, 0}} {
+		if _, _, err := readBsonDocument(malformed); err == nil {
+			t.Errorf("Expected error for %v", malformed)
+		}
+	}
+}
+
+func TestBsonFields(t *testing.T) {
+	document := bsonFields([]Field{Str("user", "alice"), Group("", Int("inline", 1)), Group("empty"),
+		Group("http", Int("status", 200)), Err("err", nil)})
+	expected := bsonDocument{{"user", "alice"}, {"inline", int64(1)}, {"http", bsonDocument{{"status", int64(200)}}},
+		{"err", nil}}
+	if !reflect.DeepEqual(document, expected) {
+		t.Errorf("Expected %v, got %v", expected, document)
+	}
+}
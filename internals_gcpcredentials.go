@@ -0,0 +1,288 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGcpTokenURI     = "https://oauth2.googleapis.com/token"
+	defaultGcpMetadataHost = "metadata.google.internal"
+	gcpMetadataTokenPath   = "/computeMetadata/v1/instance/service-accounts/default/token"
+	gcpTokenEarlyExpiry    = time.Minute
+	gcpServiceAccount      = "service_account"
+	gcpAuthorizedUser      = "authorized_user"
+	gcpMetadata            = "metadata"
+)
+
+// gcpCredentials gets OAuth2 access tokens with Google Application Default Credentials:
+// the credentials file at GOOGLE_APPLICATION_CREDENTIALS or the one written by
+// "gcloud auth application-default login", holding a service account key or user
+// credentials, or else the metadata server of GCE, GKE with workload identity, Cloud
+// Run and the like (GCE_METADATA_HOST overrides its address). Tokens are cached until
+// shortly before they expire.
+type gcpCredentials struct {
+	kind         string
+	email        string
+	privateKey   *rsa.PrivateKey
+	keyID        string
+	clientID     string
+	clientSecret string
+	refreshToken string
+	tokenURI     string
+	metadataHost string
+	scope        string
+	client       *http.Client
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type gcpCredentialsFile struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// findGcpCredentials finds the credentials for the scope. file is the credentials file
+// to use; if it is empty the file is looked up as described for gcpCredentials.
+func findGcpCredentials(file, scope string) (*gcpCredentials, error) {
+	credentials := &gcpCredentials{scope: scope, client: &http.Client{Timeout: 30 * time.Second}}
+
+	if file == "" {
+		file = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if file == "" {
+		if wellKnown := gcloudCredentialsFile(); wellKnown != "" {
+			if _, err := os.Stat(wellKnown); err == nil {
+				file = wellKnown
+			}
+		}
+	}
+	if file == "" {
+		credentials.kind = gcpMetadata
+		credentials.metadataHost = os.Getenv("GCE_METADATA_HOST")
+		if credentials.metadataHost == "" {
+			credentials.metadataHost = defaultGcpMetadataHost
+		}
+		return credentials, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var content gcpCredentialsFile
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("Cannot parse Google credentials %s: %s", file, err)
+	}
+	credentials.kind = content.Type
+	credentials.tokenURI = content.TokenURI
+	if credentials.tokenURI == "" {
+		credentials.tokenURI = defaultGcpTokenURI
+	}
+
+	switch content.Type {
+	case gcpServiceAccount:
+		if content.ClientEmail == "" || content.PrivateKey == "" {
+			return nil, errors.New("Google service account credentials need client_email and private_key: " + file)
+		}
+		credentials.email = content.ClientEmail
+		credentials.keyID = content.PrivateKeyID
+		credentials.privateKey, err = parseRSAPrivateKey(content.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse the private key of %s: %s", file, err)
+		}
+	case gcpAuthorizedUser:
+		if content.ClientID == "" || content.ClientSecret == "" || content.RefreshToken == "" {
+			return nil, errors.New("Google user credentials need client_id, client_secret and refresh_token: " + file)
+		}
+		credentials.clientID = content.ClientID
+		credentials.clientSecret = content.ClientSecret
+		credentials.refreshToken = content.RefreshToken
+	default:
+		return nil, fmt.Errorf("Google credentials of type '%s' are not supported: %s", content.Type, file)
+	}
+	return credentials, nil
+}
+
+// gcloudCredentialsFile returns the path of the credentials file of
+// "gcloud auth application-default login".
+func gcloudCredentialsFile() string {
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud", "application_default_credentials.json")
+		}
+		return ""
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+	}
+	return ""
+}
+
+func parseRSAPrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// accessToken returns a valid access token, getting a new one if needed.
+func (credentials *gcpCredentials) accessToken() (string, error) {
+	credentials.mutex.Lock()
+	defer credentials.mutex.Unlock()
+
+	now := time.Now()
+	if credentials.token != "" && now.Before(credentials.expires) {
+		return credentials.token, nil
+	}
+
+	var request *http.Request
+	var err error
+	switch credentials.kind {
+	case gcpMetadata:
+		request, err = http.NewRequest(http.MethodGet, "http://"+credentials.metadataHost+gcpMetadataTokenPath, nil)
+		if err == nil {
+			request.Header.Set("Metadata-Flavor", "Google")
+		}
+	case gcpServiceAccount:
+		var assertion string
+		assertion, err = credentials.assertion(now)
+		if err == nil {
+			request, err = newFormRequest(credentials.tokenURI, url.Values{
+				"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+				"assertion":  {assertion},
+			})
+		}
+	default:
+		request, err = newFormRequest(credentials.tokenURI, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {credentials.clientID},
+			"client_secret": {credentials.clientSecret},
+			"refresh_token": {credentials.refreshToken},
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	response, err := credentials.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("Cannot get a Google access token: %s", err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Cannot get a Google access token: %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", errors.New("Cannot get a Google access token: unexpected response")
+	}
+
+	credentials.token = token.AccessToken
+	credentials.expires = now.Add(time.Duration(token.ExpiresIn)*time.Second - gcpTokenEarlyExpiry)
+	return credentials.token, nil
+}
+
+// assertion returns the JWT a service account exchanges for an access token.
+func (credentials *gcpCredentials) assertion(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": credentials.keyID})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   credentials.email,
+		"scope": credentials.scope,
+		"aud":   credentials.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, credentials.privateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func newFormRequest(uri string, values url.Values) (*http.Request, error) {
+	request, err := http.NewRequest(http.MethodPost, uri, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return request, nil
+}
+
+func (credentials *gcpCredentials) String() string {
+	if credentials.kind == gcpMetadata {
+		return "metadata server " + credentials.metadataHost
+	}
+	if credentials.kind == gcpServiceAccount {
+		return "service account " + credentials.email
+	}
+	return "user credentials"
+}
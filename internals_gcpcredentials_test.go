@@ -0,0 +1,163 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeGcpTestCredentials(t *testing.T, dir string, content map[string]string) string {
+	data, _ := json.Marshal(content)
+	file := filepath.Join(dir, "credentials.json")
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestGcpServiceAccount(t *testing.T) {
+	server := newGcsTestServer()
+	defer server.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	file := writeGcpTestCredentials(t, t.TempDir(), map[string]string{
+		"type":           "service_account",
+		"client_email":   "archiver@project.iam.gserviceaccount.com",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})),
+		"private_key_id": "key-1",
+		"token_uri":      server.URL + "/token",
+	})
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", file)
+
+	credentials, err := findGcpCredentials("", gcsScope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if token, err := credentials.accessToken(); err != nil || token != "token-1" {
+			t.Fatalf("Expected the cached token, got %s, %v", token, err)
+		}
+	}
+
+	form := server.forms[0]
+	if form["grant_type"][0] != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+		t.Errorf("Unexpected grant type: %v", form["grant_type"])
+	}
+	parts := strings.Split(form["assertion"][0], ".")
+	if len(parts) != 3 {
+		t.Fatalf("Unexpected assertion: %s", form["assertion"][0])
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+		t.Errorf("Invalid signature: %s", err)
+	}
+	var header, claims map[string]interface{}
+	headerJson, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	claimsJson, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(headerJson, &header)
+	json.Unmarshal(claimsJson, &claims)
+	if header["alg"] != "RS256" || header["kid"] != "key-1" {
+		t.Errorf("Unexpected header: %v", header)
+	}
+	if claims["iss"] != "archiver@project.iam.gserviceaccount.com" || claims["scope"] != gcsScope ||
+		claims["aud"] != server.URL+"/token" || claims["exp"].(float64)-claims["iat"].(float64) != 3600 {
+		t.Errorf("Unexpected claims: %v", claims)
+	}
+}
+
+func TestGcpAuthorizedUser(t *testing.T) {
+	server := newGcsTestServer()
+	defer server.Close()
+
+	// The file of "gcloud auth application-default login"
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", home)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	dir := filepath.Dir(gcloudCredentialsFile())
+	os.MkdirAll(dir, 0700)
+	writeGcpTestCredentials(t, dir, map[string]string{
+		"type":          "authorized_user",
+		"client_id":     "client",
+		"client_secret": "secret",
+		"refresh_token": "refresh",
+		"token_uri":     server.URL + "/token",
+	})
+	os.Rename(filepath.Join(dir, "credentials.json"), gcloudCredentialsFile())
+
+	credentials, err := findGcpCredentials("", gcsScope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err := credentials.accessToken(); err != nil || token != "token-1" {
+		t.Fatalf("Unexpected token: %s, %v", token, err)
+	}
+	form := server.forms[0]
+	if form["grant_type"][0] != "refresh_token" || form["client_id"][0] != "client" ||
+		form["client_secret"][0] != "secret" || form["refresh_token"][0] != "refresh" {
+		t.Errorf("Unexpected token request: %v", form)
+	}
+}
+
+func TestGcpCredentialsErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := []map[string]string{
+		{"type": "external_account"},
+		{"type": "service_account", "client_email": "a@b.c"},
+		{"type": "service_account", "client_email": "a@b.c", "private_key": "not a key"},
+		{"type": "authorized_user", "client_id": "client"},
+	}
+	for _, content := range invalid {
+		file := writeGcpTestCredentials(t, dir, content)
+		if _, err := findGcpCredentials(file, gcsScope); err == nil {
+			t.Errorf("Expected error for credentials: %v", content)
+		}
+	}
+
+	server := newGcsTestServer()
+	defer server.Close()
+	credentials := &gcpCredentials{kind: gcpMetadata, metadataHost: strings.TrimPrefix(server.URL, "http://") + "/missing",
+		client: server.Client()}
+	if _, err := credentials.accessToken(); err == nil {
+		t.Error("Expected error for a failing token request")
+	}
+}
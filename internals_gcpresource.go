@@ -0,0 +1,140 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const gcpMetadataPath = "/computeMetadata/v1/"
+
+// gcpResource is the monitored resource which log entries are written for.
+type gcpResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels"`
+}
+
+// gcpMetadataClient reads values of the metadata server of GCE, GKE, Cloud Run, App
+// Engine and Cloud Functions; GCE_METADATA_HOST overrides its address.
+type gcpMetadataClient struct {
+	host   string
+	client *http.Client
+}
+
+func newGcpMetadataClient() *gcpMetadataClient {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = defaultGcpMetadataHost
+	}
+	return &gcpMetadataClient{host: host, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// get returns the value at a path under computeMetadata/v1.
+func (metadata *gcpMetadataClient) get(path string) (string, error) {
+	request, err := http.NewRequest(http.MethodGet, "http://"+metadata.host+gcpMetadataPath+path, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+	response, err := metadata.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata %s: %s", path, response.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// lastPart returns the value at a path without the "projects/123/zones/" and the like
+// which zones and regions come with.
+func (metadata *gcpMetadataClient) lastPart(path string) string {
+	value, _ := metadata.get(path)
+	return value[strings.LastIndex(value, "/")+1:]
+}
+
+// detectGcpResource detects the resource which the process runs on from the
+// environment and the metadata server: a Cloud Run revision (or a Cloud Functions
+// function, which runs as one), an App Engine app, a GKE container, a GCE instance, or
+// else the project as a whole ("global"). project may be empty, to read it from
+// GOOGLE_CLOUD_PROJECT or the metadata server.
+func detectGcpResource(metadata *gcpMetadataClient, project string) (string, *gcpResource, error) {
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	_, metadataErr := metadata.get("instance/id")
+	onGcp := metadataErr == nil
+	if project == "" && onGcp {
+		project, _ = metadata.get("project/project-id")
+	}
+	if project == "" {
+		return "", nil, errors.New("Cannot detect the Google Cloud project; set it or GOOGLE_CLOUD_PROJECT")
+	}
+	if !onGcp {
+		return project, &gcpResource{Type: "global", Labels: map[string]string{"project_id": project}}, nil
+	}
+
+	labels := map[string]string{"project_id": project}
+	switch {
+	case os.Getenv("K_SERVICE") != "" && os.Getenv("FUNCTION_TARGET") != "":
+		labels["function_name"] = os.Getenv("K_SERVICE")
+		labels["region"] = metadata.lastPart("instance/region")
+		return project, &gcpResource{Type: "cloud_function", Labels: labels}, nil
+	case os.Getenv("K_SERVICE") != "":
+		labels["service_name"] = os.Getenv("K_SERVICE")
+		labels["revision_name"] = os.Getenv("K_REVISION")
+		labels["configuration_name"] = os.Getenv("K_CONFIGURATION")
+		labels["location"] = metadata.lastPart("instance/region")
+		return project, &gcpResource{Type: "cloud_run_revision", Labels: labels}, nil
+	case os.Getenv("GAE_SERVICE") != "":
+		labels["module_id"] = os.Getenv("GAE_SERVICE")
+		labels["version_id"] = os.Getenv("GAE_VERSION")
+		labels["zone"] = metadata.lastPart("instance/zone")
+		return project, &gcpResource{Type: "gae_app", Labels: labels}, nil
+	case os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		labels["location"], _ = metadata.get("instance/attributes/cluster-location")
+		labels["cluster_name"], _ = metadata.get("instance/attributes/cluster-name")
+		labels["namespace_name"] = os.Getenv("POD_NAMESPACE")
+		if labels["namespace_name"] == "" {
+			namespace, _ := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+			labels["namespace_name"] = strings.TrimSpace(string(namespace))
+		}
+		labels["pod_name"], _ = os.Hostname()
+		labels["container_name"] = os.Getenv("CONTAINER_NAME")
+		return project, &gcpResource{Type: "k8s_container", Labels: labels}, nil
+	}
+	labels["instance_id"], _ = metadata.get("instance/id")
+	labels["zone"] = metadata.lastPart("instance/zone")
+	return project, &gcpResource{Type: "gce_instance", Labels: labels}, nil
+}
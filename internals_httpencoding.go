@@ -0,0 +1,130 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Content codings of the request bodies of receivers which send over http
+const (
+	httpCompressionAuto = "auto"
+	httpCompressionNone = "none"
+	httpCompressionGzip = "gzip"
+)
+
+// httpEncoding chooses the content coding of the request bodies of a receiver. A
+// configured coding is kept while the server accepts it. In auto mode the coding starts
+// as one which the servers of the receiver are known to accept, and then follows what
+// the server advertises with Accept-Encoding in successful responses (RFC 7694). A
+// request which the server answers with 415 Unsupported Media Type is sent again at
+// once, without counting as a retry, in a coding which that response accepts, or
+// uncompressed, and the rejected coding is not used again.
+type httpEncoding struct {
+	auto     bool
+	coding   string
+	rejected map[string]bool
+}
+
+// newHttpEncoding returns the encoding for a compression setting. Auto, or an empty
+// setting, starts with autoCoding, or none if it is empty.
+func newHttpEncoding(compression, autoCoding string) (*httpEncoding, error) {
+	switch compression {
+	case "", httpCompressionAuto:
+		if autoCoding == "" {
+			autoCoding = httpCompressionNone
+		}
+		return &httpEncoding{auto: true, coding: autoCoding, rejected: make(map[string]bool)}, nil
+	case httpCompressionNone, httpCompressionGzip:
+		return &httpEncoding{coding: compression, rejected: make(map[string]bool)}, nil
+	}
+	return nil, errors.New("Http compression must be auto, none or gzip: " + compression)
+}
+
+// encode codes a request body in the current coding, which it returns.
+func (encoding *httpEncoding) encode(body []byte) ([]byte, string) {
+	if encoding.coding != httpCompressionGzip {
+		return body, httpCompressionNone
+	}
+	buf := new(bytes.Buffer)
+	writer := gzip.NewWriter(buf)
+	writer.Write(body)
+	writer.Close()
+	return buf.Bytes(), httpCompressionGzip
+}
+
+// update follows the codings which a response to a request in coding accepts. It
+// returns whether the request should be sent again in another coding.
+func (encoding *httpEncoding) update(response *http.Response, coding string) bool {
+	accept, isAccept := response.Header["Accept-Encoding"]
+	switch {
+	case response.StatusCode == http.StatusUnsupportedMediaType && coding != httpCompressionNone:
+		encoding.rejected[coding] = true
+		encoding.coding = encoding.accepted(strings.Join(accept, ","))
+		return true
+	case encoding.auto && isAccept && response.StatusCode/100 == 2:
+		encoding.coding = encoding.accepted(strings.Join(accept, ","))
+	}
+	return false
+}
+
+// accepted returns the coding which an Accept-Encoding value accepts, unless it was
+// rejected before, or none.
+func (encoding *httpEncoding) accepted(accept string) string {
+	qualities := make(map[string]float64)
+	for _, item := range strings.Split(accept, ",") {
+		params := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "x-gzip" {
+			name = httpCompressionGzip
+		}
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") || strings.HasPrefix(param, "Q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = value
+				}
+			}
+		}
+		if name != "" {
+			qualities[name] = quality
+		}
+	}
+
+	quality, isListed := qualities[httpCompressionGzip]
+	if !isListed {
+		quality = qualities["*"]
+	}
+	if quality > 0 && !encoding.rejected[httpCompressionGzip] {
+		return httpCompressionGzip
+	}
+	return httpCompressionNone
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// readHttpTestBody reads the body of a request, gunzipped if it is gzipped.
+func readHttpTestBody(r *http.Request) ([]byte, error) {
+	if r.Header.Get("Content-Encoding") != "gzip" {
+		return ioutil.ReadAll(r.Body)
+	}
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(reader)
+}
+
+func TestHttpEncodingEncode(t *testing.T) {
+	for _, compression := range []string{httpCompressionNone, httpCompressionGzip} {
+		encoding, err := newHttpEncoding(compression, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, coding := encoding.encode([]byte("first\nsecond\n"))
+		if coding != compression {
+			t.Errorf("Expected coding %s, got %s", compression, coding)
+		}
+
+		request := httptest.NewRequest(http.MethodPost, "http://collector.local", bytes.NewReader(body))
+		if coding != httpCompressionNone {
+			request.Header.Set("Content-Encoding", coding)
+		}
+		decoded, err := readHttpTestBody(request)
+		if err != nil || string(decoded) != "first\nsecond\n" {
+			t.Errorf("Unexpected %s body: %q, %v", compression, decoded, err)
+		}
+	}
+
+	if _, err := newHttpEncoding("zstd", ""); err == nil {
+		t.Error("Expected an error for an unknown compression")
+	}
+}
+
+func TestHttpEncodingUpdate(t *testing.T) {
+	response := func(status int, acceptEncoding ...string) *http.Response {
+		header := make(http.Header)
+		if len(acceptEncoding) > 0 {
+			header["Accept-Encoding"] = acceptEncoding
+		}
+		return &http.Response{StatusCode: status, Header: header}
+	}
+
+	// Auto mode follows what successful responses advertise
+	encoding, _ := newHttpEncoding(httpCompressionAuto, "")
+	if encoding.update(response(http.StatusOK), httpCompressionNone) || encoding.coding != httpCompressionNone {
+		t.Errorf("Expected no change without Accept-Encoding, got %s", encoding.coding)
+	}
+	if encoding.update(response(http.StatusOK, "gzip"), httpCompressionNone) || encoding.coding != httpCompressionGzip {
+		t.Errorf("Expected gzip after Accept-Encoding gzip, got %s", encoding.coding)
+	}
+	if encoding.update(response(http.StatusBadRequest, "identity"), httpCompressionGzip) || encoding.coding != httpCompressionGzip {
+		t.Errorf("Expected failed responses to be ignored, got %s", encoding.coding)
+	}
+
+	// A 415 is sent again, and the rejected coding is not used again
+	if !encoding.update(response(http.StatusUnsupportedMediaType), httpCompressionGzip) || encoding.coding != httpCompressionNone {
+		t.Errorf("Expected a resend uncompressed after 415, got %s", encoding.coding)
+	}
+	if encoding.update(response(http.StatusOK, "gzip"), httpCompressionNone) || encoding.coding != httpCompressionNone {
+		t.Errorf("Expected the rejected gzip to stay off, got %s", encoding.coding)
+	}
+	if encoding.update(response(http.StatusUnsupportedMediaType), httpCompressionNone) {
+		t.Error("Expected no resend of an uncompressed request")
+	}
+
+	// A configured coding is kept while the server accepts it
+	encoding, _ = newHttpEncoding(httpCompressionGzip, "")
+	if encoding.update(response(http.StatusOK, "identity"), httpCompressionGzip) || encoding.coding != httpCompressionGzip {
+		t.Errorf("Expected the configured gzip to be kept, got %s", encoding.coding)
+	}
+}
+
+func TestHttpEncodingAccepted(t *testing.T) {
+	tests := []struct {
+		accept   string
+		rejected string
+		expected string
+	}{
+		{"", "", httpCompressionNone},
+		{"identity", "", httpCompressionNone},
+		{"gzip", "", httpCompressionGzip},
+		{"x-gzip, deflate", "", httpCompressionGzip},
+		{"GZIP;Q=0.5, br", "", httpCompressionGzip},
+		{"gzip;q=0, *", "", httpCompressionNone},
+		{"*;q=0.1", "", httpCompressionGzip},
+		{"gzip", httpCompressionGzip, httpCompressionNone},
+	}
+	for _, test := range tests {
+		encoding, _ := newHttpEncoding(httpCompressionAuto, "")
+		if test.rejected != "" {
+			encoding.rejected[test.rejected] = true
+		}
+		if coding := encoding.accepted(test.accept); coding != test.expected {
+			t.Errorf("Expected %s for %q, got %s", test.expected, test.accept, coding)
+		}
+	}
+}
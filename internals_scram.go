@@ -0,0 +1,114 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const scramSHA256Mechanism = "SCRAM-SHA-256"
+
+// scramClient is the client side of a SCRAM-SHA-256 exchange (RFC 5802, RFC 7677),
+// without channel binding. The password is used as it is, without SASLprep.
+type scramClient struct {
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	authMessage     string
+	saltedPassword  []byte
+}
+
+// newScramClient starts an exchange for the user, which may be empty if the protocol
+// tells it otherwise.
+func newScramClient(user, password string) (*scramClient, error) {
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	clientNonce := base64.StdEncoding.EncodeToString(nonce)
+	user = strings.NewReplacer("=", "=3D", ",", "=2C").Replace(user)
+	return &scramClient{password: password, clientNonce: clientNonce, clientFirstBare: "n=" + user + ",r=" + clientNonce}, nil
+}
+
+// clientFirst returns the client-first-message.
+func (scram *scramClient) clientFirst() string {
+	return "n,," + scram.clientFirstBare
+}
+
+// clientFinal returns the client-final-message with the proof for the server-first-message.
+func (scram *scramClient) clientFinal(serverFirst string) ([]byte, error) {
+	attributes := make(map[string]string)
+	for _, attribute := range strings.Split(serverFirst, ",") {
+		if len(attribute) > 2 && attribute[1] == '=' {
+			attributes[attribute[:1]] = attribute[2:]
+		}
+	}
+	salt, err := base64.StdEncoding.DecodeString(attributes["s"])
+	if err != nil {
+		return nil, errors.New("Malformed SCRAM salt")
+	}
+	iterations, err := strconv.Atoi(attributes["i"])
+	if err != nil || iterations <= 0 {
+		return nil, errors.New("Malformed SCRAM iteration count")
+	}
+	if !strings.HasPrefix(attributes["r"], scram.clientNonce) {
+		return nil, errors.New("SCRAM nonce does not match")
+	}
+
+	scram.saltedPassword, err = pbkdf2.Key(sha256.New, scram.password, salt, iterations, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	clientFinalWithoutProof := "c=biws,r=" + attributes["r"]
+	scram.authMessage = scram.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof
+
+	clientKey := hmacSHA256(scram.saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	proof := hmacSHA256(storedKey[:], scram.authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	return []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verifyServer checks the signature of the server-final-message.
+func (scram *scramClient) verifyServer(serverFinal string) bool {
+	if scram.saltedPassword == nil || !strings.HasPrefix(serverFinal, "v=") {
+		return false
+	}
+	signature, err := base64.StdEncoding.DecodeString(serverFinal[2:])
+	if err != nil {
+		return false
+	}
+	serverKey := hmacSHA256(scram.saltedPassword, "Server Key")
+	return hmac.Equal(signature, hmacSHA256(serverKey, scram.authMessage))
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// scramTestServer is the server side of a SCRAM-SHA-256 exchange for tests of receivers.
+type scramTestServer struct {
+	password        string
+	user            string
+	clientFirstBare string
+	serverFirst     string
+}
+
+var scramTestSalt = []byte("saltsalt")
+
+// first answers the client-first-message.
+func (server *scramTestServer) first(clientFirst string) string {
+	server.clientFirstBare = strings.TrimPrefix(clientFirst, "n,,")
+	attributes := strings.SplitN(server.clientFirstBare, ",", 2)
+	server.user = strings.TrimPrefix(attributes[0], "n=")
+	server.serverFirst = "r=" + strings.TrimPrefix(attributes[1], "r=") + "server,s=" +
+		base64.StdEncoding.EncodeToString(scramTestSalt) + ",i=4096"
+	return server.serverFirst
+}
+
+// final checks the proof of the client-final-message and returns the server-final-message.
+func (server *scramTestServer) final(clientFinal string) (string, bool) {
+	proofIndex := strings.Index(clientFinal, ",p=")
+	if proofIndex < 0 {
+		return "", false
+	}
+	authMessage := server.clientFirstBare + "," + server.serverFirst + "," + clientFinal[:proofIndex]
+	salted, _ := pbkdf2.Key(sha256.New, server.password, scramTestSalt, 4096, sha256.Size)
+	storedKey := sha256.Sum256(hmacSHA256(salted, "Client Key"))
+	proof, _ := base64.StdEncoding.DecodeString(clientFinal[proofIndex+3:])
+	if len(proof) != sha256.Size {
+		return "", false
+	}
+	clientKey := hmacSHA256(storedKey[:], authMessage)
+	for i := range clientKey {
+		clientKey[i] ^= proof[i]
+	}
+	if sum := sha256.Sum256(clientKey); !hmac.Equal(sum[:], storedKey[:]) {
+		return "", false
+	}
+	serverKey := hmacSHA256(salted, "Server Key")
+	return "v=" + base64.StdEncoding.EncodeToString(hmacSHA256(serverKey, authMessage)), true
+}
+
+func TestScramClient(t *testing.T) {
+	server := &scramTestServer{password: "pencil"}
+	client, err := newScramClient("us,er=", "pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverFirst := server.first(client.clientFirst())
+	if server.user != "us=2Cer=3D" {
+		t.Errorf("Unexpected escaped user name: %s", server.user)
+	}
+	clientFinal, err := client.clientFinal(serverFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverFinal, ok := server.final(string(clientFinal))
+	if !ok {
+		t.Fatal("Expected a valid proof")
+	}
+	if !client.verifyServer(serverFinal) || client.verifyServer("v=AAAA") {
+		t.Error("Server signature verification is wrong")
+	}
+
+	wrong, _ := newScramClient("user", "wrong")
+	serverFirst = server.first(wrong.clientFirst())
+	clientFinal, _ = wrong.clientFinal(serverFirst)
+	if _, ok := server.final(string(clientFinal)); ok {
+		t.Error("Expected an invalid proof for a wrong password")
+	}
+	if _, err := wrong.clientFinal("r=other,s=c2FsdA==,i=4096"); err == nil {
+		t.Error("Expected an error for a nonce of another client")
+	}
+}
@@ -37,6 +37,12 @@ type xmlNode struct {
 	attributes map[string]string
 	children   []*xmlNode
 	value      string
+	location   string   // Where the node was declared, for error messages. Empty for xml nodes.
+	dir        string   // Directory of the config file of a root node, for includes.
+	includes   []string // Files included by a root node, set when its includes are resolved.
+	line       int      // 1-based position of the start tag in the xml config; 0 for other nodes.
+	column     int
+	levels     levelSet // Custom levels declared by the root config, set when the config is prepared.
 }
 
 func newNode() *xmlNode {
@@ -67,6 +73,14 @@ func (this *xmlNode) String() string {
 	return str
 }
 
+// errorName returns the name used to refer to the node in error messages.
+func (this *xmlNode) errorName() string {
+	if this.location != "" {
+		return this.location
+	}
+	return this.name
+}
+
 func (this *xmlNode) unmarshal(startEl xml.StartElement) error {
 	this.name = startEl.Name.Local
 
@@ -98,7 +112,7 @@ func (this *xmlNode) hasChildren() bool {
 func unmarshalConfig(reader io.Reader) (*xmlNode, error) {
 	xmlParser := xml.NewDecoder(reader)
 
-	config, err := unmarshalNode(xmlParser, nil)
+	config, err := unmarshalNode(xmlParser, nil, 0, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +120,7 @@ func unmarshalConfig(reader io.Reader) (*xmlNode, error) {
 		return nil, errors.New("Xml has no content")
 	}
 
-	nextConfigEntry, err := unmarshalNode(xmlParser, nil)
+	nextConfigEntry, err := unmarshalNode(xmlParser, nil, 0, 0)
 	if nextConfigEntry != nil {
 		return nil, errors.New("Xml contains more than one root element")
 	}
@@ -114,14 +128,18 @@ func unmarshalConfig(reader io.Reader) (*xmlNode, error) {
 	return config, nil
 }
 
-func unmarshalNode(xmlParser *xml.Decoder, curToken xml.Token) (node *xmlNode, err error) {
+// unmarshalNode reads a node starting with curToken, or with the next token if curToken
+// is nil. Line and column are the position of curToken.
+func unmarshalNode(xmlParser *xml.Decoder, curToken xml.Token, curLine, curColumn int) (node *xmlNode, err error) {
 	firstLoop := true
 	for {
 		var tok xml.Token
+		line, column := curLine, curColumn
 		if firstLoop && curToken != nil {
 			tok = curToken
 			firstLoop = false
 		} else {
+			line, column = xmlParser.InputPos()
 			tok, err = getNextToken(xmlParser)
 			if err != nil || tok == nil {
 				return
@@ -140,12 +158,13 @@ func unmarshalNode(xmlParser *xml.Decoder, curToken xml.Token) (node *xmlNode, e
 		case xml.StartElement:
 			if node == nil {
 				node = newNode()
+				node.line, node.column = line, column
 				err := node.unmarshal(tt)
 				if err != nil {
 					return nil, err
 				}
 			} else {
-				childNode, childErr := unmarshalNode(xmlParser, tok)
+				childNode, childErr := unmarshalNode(xmlParser, tok, line, column)
 				if childErr != nil {
 					return nil, childErr
 				}
@@ -0,0 +1,116 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package kvconfig
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consulWait is the longest time a Consul blocking query waits for a change.
+const consulWait = 5 * time.Minute
+
+// Consul watches a key of the Consul KV store with blocking queries.
+type Consul struct {
+	Address string       // Agent address, e.g. "http://127.0.0.1:8500"
+	Key     string       // Key path, e.g. "app/seelog"
+	Token   string       // ACL token; may be empty
+	Client  *http.Client // http.DefaultClient if nil
+}
+
+func (consul *Consul) Watch(ctx context.Context, update func(value []byte)) error {
+	if consul.Key == "" {
+		return errors.New("Consul key cannot be empty")
+	}
+
+	client := consul.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var index uint64
+	for {
+		value, newIndex, found, err := consul.get(ctx, client, index)
+		if err != nil {
+			return err
+		}
+
+		// The index is reset if it goes backwards, as the Consul docs require
+		changed := newIndex != index
+		if newIndex < index {
+			newIndex = 0
+		}
+		index = newIndex
+
+		if changed && found {
+			update(value)
+		}
+	}
+}
+
+// get reads the raw value of the key. If index is not 0, the request blocks until the
+// index of the key changes or the wait time passes.
+func (consul *Consul) get(ctx context.Context, client *http.Client, index uint64) (value []byte, newIndex uint64, found bool, err error) {
+	query := url.Values{"raw": {""}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", strconv.Itoa(int(consulWait/time.Second))+"s")
+	}
+	requestUrl := strings.TrimRight(consul.Address, "/") + "/v1/kv/" + strings.TrimLeft(consul.Key, "/") + "?" + query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, "GET", requestUrl, nil)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if consul.Token != "" {
+		request.Header.Set("X-Consul-Token", consul.Token)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer response.Body.Close()
+
+	newIndex, err = strconv.ParseUint(response.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, 0, false, errors.New("Consul response has no valid X-Consul-Index header")
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		value, err = io.ReadAll(response.Body)
+		return value, newIndex, err == nil, err
+	case http.StatusNotFound:
+		return nil, newIndex, false, nil
+	}
+	return nil, 0, false, errors.New("Unexpected Consul response status: " + response.Status)
+}
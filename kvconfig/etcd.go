@@ -0,0 +1,154 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package kvconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Etcd watches a key of etcd through the JSON gateway of the v3 API.
+type Etcd struct {
+	Endpoint string       // Client endpoint, e.g. "http://127.0.0.1:2379"
+	Key      string       // Key, e.g. "/app/seelog"
+	Client   *http.Client // http.DefaultClient if nil
+}
+
+type etcdKeyValue struct {
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+type etcdRangeResponse struct {
+	Header struct {
+		Revision string `json:"revision"`
+	} `json:"header"`
+	Kvs []etcdKeyValue `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Canceled bool `json:"canceled"`
+		Events   []struct {
+			Type string       `json:"type"`
+			Kv   etcdKeyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (etcd *Etcd) Watch(ctx context.Context, update func(value []byte)) error {
+	if etcd.Key == "" {
+		return errors.New("Etcd key cannot be empty")
+	}
+
+	client := etcd.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	key := base64.StdEncoding.EncodeToString([]byte(etcd.Key))
+
+	var current etcdRangeResponse
+	err := etcd.post(ctx, client, "/v3/kv/range", map[string]interface{}{"key": key}, func(decoder *json.Decoder) error {
+		return decoder.Decode(&current)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(current.Kvs) > 0 {
+		value, err := base64.StdEncoding.DecodeString(current.Kvs[0].Value)
+		if err != nil {
+			return err
+		}
+		update(value)
+	}
+
+	revision, err := strconv.ParseInt(current.Header.Revision, 10, 64)
+	if err != nil {
+		return errors.New("Etcd response has no valid revision")
+	}
+
+	watch := map[string]interface{}{
+		"create_request": map[string]interface{}{"key": key, "start_revision": strconv.FormatInt(revision+1, 10)},
+	}
+	return etcd.post(ctx, client, "/v3/watch", watch, func(decoder *json.Decoder) error {
+		for {
+			var response etcdWatchResponse
+			if err := decoder.Decode(&response); err != nil {
+				return err
+			}
+			if response.Error != nil {
+				return errors.New("Etcd watch failed: " + response.Error.Message)
+			}
+			if response.Result.Canceled {
+				return errors.New("Etcd watch was canceled")
+			}
+
+			for _, event := range response.Result.Events {
+				if event.Type == "DELETE" {
+					continue
+				}
+				value, err := base64.StdEncoding.DecodeString(event.Kv.Value)
+				if err != nil {
+					return err
+				}
+				update(value)
+			}
+		}
+	})
+}
+
+func (etcd *Etcd) post(ctx context.Context, client *http.Client, path string, body interface{}, read func(*json.Decoder) error) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(etcd.Endpoint, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return errors.New("Unexpected etcd response status: " + response.Status)
+	}
+	return read(json.NewDecoder(response.Body))
+}
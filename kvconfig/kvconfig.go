@@ -0,0 +1,155 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+// Package kvconfig keeps the seelog config in a key of a key-value store (Consul or etcd)
+// and applies every change of the key to the running process, so that log levels of a
+// whole cluster can be changed in one place.
+//
+// Example:
+//     stop, err := kvconfig.Start(&kvconfig.Consul{Address: "http://127.0.0.1:8500", Key: "app/seelog"})
+//     if err != nil {
+//         panic(err)
+//     }
+//     defer stop()
+//
+// Both stores are accessed over their HTTP APIs, so no client libraries are needed.
+package kvconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	log "seelog"
+)
+
+// Retry delays after failed requests to the store
+var (
+	MinRetryDelay = time.Second
+	MaxRetryDelay = 30 * time.Second
+)
+
+// A Backend watches a key of a key-value store.
+type Backend interface {
+	// Watch calls update with the current value of the key and then with every new value,
+	// until ctx is done or a request fails. A deleted or missing key is not reported.
+	Watch(ctx context.Context, update func(value []byte)) error
+}
+
+// ApplyConfig creates a logger from the config and makes it the current seelog logger.
+// JSON configs (starting with '{') and xml configs are supported.
+func ApplyConfig(config []byte) error {
+	var logger log.LoggerInterface
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(config), []byte("{")) {
+		logger, err = log.LoggerFromJSON(config)
+	} else {
+		logger, err = log.LoggerFromConfigAsBytes(config)
+	}
+	if err != nil {
+		return err
+	}
+
+	return log.ReplaceLogger(logger)
+}
+
+// Start waits for the first value of the key, applies it (see ApplyConfig) and keeps
+// applying new values until the returned func is called. Failed requests are retried;
+// invalid configs are reported as warnings to the current logger and the previous config
+// stays in use. Start fails if the first value can not be read or applied within
+// firstValueTimeout.
+func Start(backend Backend) (stop func(), err error) {
+	return start(backend, ApplyConfig)
+}
+
+// firstValueTimeout limits the time Start waits for the first config.
+var firstValueTimeout = 30 * time.Second
+
+func start(backend Backend, apply func([]byte) error) (stop func(), err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		firstSent := false
+		update := func(value []byte) {
+			err := apply(value)
+			if !firstSent {
+				select {
+				case first <- err:
+				default:
+				}
+				firstSent = err == nil
+				return
+			}
+			if err != nil {
+				log.Warnf("Config from the key-value store is not applied: %s", err)
+			}
+		}
+
+		delay := MinRetryDelay
+		for ctx.Err() == nil {
+			started := time.Now()
+			err := backend.Watch(ctx, update)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Warnf("Watching the config in the key-value store failed: %s", err)
+			}
+
+			if time.Since(started) > MaxRetryDelay {
+				delay = MinRetryDelay
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+			if delay *= 2; delay > MaxRetryDelay {
+				delay = MaxRetryDelay
+			}
+		}
+	}()
+
+	stop = func() {
+		cancel()
+		<-done
+	}
+
+	select {
+	case err = <-first:
+	case <-time.After(firstValueTimeout):
+		err = errors.New("No config received from the key-value store")
+	}
+	if err != nil {
+		stop()
+		return nil, err
+	}
+
+	return stop, nil
+}
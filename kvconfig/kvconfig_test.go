@@ -0,0 +1,189 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package kvconfig
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// applied collects the configs passed to apply.
+type applied struct {
+	mutex   sync.Mutex
+	values  []string
+	changed chan struct{}
+}
+
+func newApplied() *applied {
+	return &applied{changed: make(chan struct{}, 16)}
+}
+
+func (a *applied) apply(value []byte) error {
+	a.mutex.Lock()
+	a.values = append(a.values, string(value))
+	a.mutex.Unlock()
+	a.changed <- struct{}{}
+	if string(value) == "invalid" {
+		return errors.New("invalid config")
+	}
+	return nil
+}
+
+func (a *applied) waitFor(t *testing.T, count int) []string {
+	deadline := time.After(5 * time.Second)
+	for {
+		a.mutex.Lock()
+		values := append([]string(nil), a.values...)
+		a.mutex.Unlock()
+		if len(values) >= count {
+			return values
+		}
+		select {
+		case <-a.changed:
+		case <-deadline:
+			t.Fatalf("Expected %d applied configs, got %v", count, values)
+		}
+	}
+}
+
+func TestConsulWatch(t *testing.T) {
+	values := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/app/seelog" || r.Header.Get("X-Consul-Token") != "secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		index, _ := strconv.Atoi(r.URL.Query().Get("index"))
+		if index == 0 {
+			w.Header().Set("X-Consul-Index", "1")
+			w.Write([]byte("first"))
+			return
+		}
+		select {
+		case value := <-values:
+			w.Header().Set("X-Consul-Index", strconv.Itoa(index+1))
+			w.Write([]byte(value))
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	a := newApplied()
+	stop, err := start(&Consul{Address: server.URL, Key: "app/seelog", Token: "secret"}, a.apply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	values <- "second"
+	got := a.waitFor(t, 2)
+	if got[0] != "first" || got[1] != "second" {
+		t.Errorf("Unexpected applied configs: %v", got)
+	}
+}
+
+func TestEtcdWatch(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			if body["key"] != encode("/app/seelog") {
+				http.Error(w, "bad key", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"header": map[string]string{"revision": "7"},
+				"kvs":    []map[string]string{{"value": encode("first")}},
+			})
+		case "/v3/watch":
+			create, _ := body["create_request"].(map[string]interface{})
+			if create["start_revision"] != "8" {
+				http.Error(w, "bad revision", http.StatusBadRequest)
+				return
+			}
+			encoder := json.NewEncoder(w)
+			encoder.Encode(map[string]interface{}{"result": map[string]interface{}{"created": true}})
+			encoder.Encode(map[string]interface{}{"result": map[string]interface{}{"events": []map[string]interface{}{
+				{"type": "DELETE", "kv": map[string]string{}},
+				{"kv": map[string]string{"value": encode("second")}},
+			}}})
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	a := newApplied()
+	stop, err := start(&Etcd{Endpoint: server.URL, Key: "/app/seelog"}, a.apply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	got := a.waitFor(t, 2)
+	if len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Errorf("Unexpected applied configs: %v", got)
+	}
+}
+
+func TestStartFailsOnInvalidFirstConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", "1")
+		if r.URL.Query().Get("index") != "" {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("invalid"))
+	}))
+	defer server.Close()
+
+	a := newApplied()
+	if _, err := start(&Consul{Address: server.URL, Key: "app/seelog"}, a.apply); err == nil {
+		t.Error("Expected an error for an invalid first config")
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	if err := ApplyConfig([]byte(`<seelog type="sync"><outputs><console/></outputs></seelog>`)); err != nil {
+		t.Errorf("Xml config: %s", err)
+	}
+	if err := ApplyConfig([]byte(`{"seelog": {"type": "sync", "outputs": {"console": {}}}}`)); err != nil {
+		t.Errorf("JSON config: %s", err)
+	}
+	if err := ApplyConfig([]byte(`<seelog><bad/></seelog>`)); err == nil {
+		t.Error("Expected an error for an invalid config")
+	}
+}
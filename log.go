@@ -25,9 +25,9 @@
 package seelog
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 )
 
@@ -48,21 +48,36 @@ const (
    Detail usage please check seelogWrapper/log.go.
 
    -- liyu
- */
 
-var staticFuncCallDepth int
+   The call depth now lives in the Facade behind the package level funcs and is
+   changed atomically. Wrappers should prefer Std().Skip, which does not change
+   the depth for other callers.
+
+   Breaking change: the default depth is 3 instead of 0. The value keeps its
+   meaning, and 0 used to report a frame inside seelog, so absolute values
+   (SetStaticFuncCallDepth(3 + n) for a wrapper n frames deep) work as before,
+   but code which adds to GetStaticFuncCallDepth must no longer add the 3 itself:
+   SetStaticFuncCallDepth(GetStaticFuncCallDepth() + n).
+ */
 
+// GetStaticFuncCallDepth returns the call depth of the facade behind the package level
+// funcs. See Facade.CallDepth. It is 3 by default, which reports the caller of a package
+// level func; prior versions returned 0 here, see the note above.
 func GetStaticFuncCallDepth() int {
-  return staticFuncCallDepth
+	return stdFacade.CallDepth()
 }
 
+// SetStaticFuncCallDepth changes the call depth of the facade behind the package level
+// funcs and returns the previous one. See Facade.SetCallDepth.
 func SetStaticFuncCallDepth(v int) int {
-  old := staticFuncCallDepth
-  staticFuncCallDepth = v
-  return old
+	return stdFacade.SetCallDepth(v)
 }
 
 // Current is the logger used in all package level convenience funcs like 'Trace', 'Debug', 'Flush', etc.
+//
+// Deprecated: Current is still the logger of the package level funcs, but reading or
+// assigning it races with logging and with UseLogger and ReplaceLogger. Use CurrentLogger
+// and UseLogger or ReplaceLogger instead.
 var Current LoggerInterface
 
 // Default logger that is created from an empty config: "<seelog/>". It is not closed by a ReplaceLogger call.
@@ -70,10 +85,12 @@ var Default LoggerInterface
 
 var Disabled LoggerInterface
 
-var pkgOperationsMutex *sync.Mutex
+var (
+	stdFacade *Facade // Returned by Std
+	std       *Facade // Used by the package level funcs, which add a frame
+)
 
 func init() {
-	pkgOperationsMutex = new(sync.Mutex)
 	var err error
 
 	if Default == nil {
@@ -89,9 +106,24 @@ func init() {
 	}
 
 	Current = Default
+	stdFacade = newFacade(&Current)
+	std = stdFacade.Skip(1)
+}
+
+// Std returns the facade behind the package level funcs. Its methods act as the package
+// level funcs with the same names.
+func Std() *Facade {
+	return stdFacade
+}
+
+// CurrentLogger returns the logger used in all package level convenience funcs.
+func CurrentLogger() LoggerInterface {
+	return stdFacade.Logger()
 }
 
 func createLoggerFromConfig(config *logConfig) (LoggerInterface, error) {
+	activateLevels(config.Levels)
+
 	if config.LogType == syncloggerTypeFromString {
 		return newSyncLogger(config), nil
 	} else if config.LogType == asyncLooploggerTypeFromString {
@@ -139,8 +171,8 @@ func createLoggerFromConfig(config *logConfig) (LoggerInterface, error) {
 	return nil, errors.New("Invalid config log type/data")
 }
 
-// UseLogger sets the 'Current' package level logger variable to the specified value.
-// This variable is used in all Trace/Debug/... package level convenience funcs.
+// UseLogger sets the logger used in all Trace/Debug/... package level convenience funcs
+// (see CurrentLogger) to the specified value.
 //
 // Example:
 //
@@ -157,21 +189,7 @@ func createLoggerFromConfig(config *logConfig) (LoggerInterface, error) {
 //
 // To safely replace loggers, use ReplaceLogger.
 func UseLogger(logger LoggerInterface) error {
-	if logger == nil {
-		return errors.New("Logger can not be nil")
-	}
-
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-
-	oldLogger := Current
-	Current = logger
-
-	if oldLogger != nil {
-		oldLogger.Flush()
-	}
-
-	return nil
+	return std.UseLogger(logger)
 }
 
 // ReplaceLogger acts as UseLogger but the logger that was previously
@@ -194,116 +212,76 @@ func UseLogger(logger LoggerInterface) error {
 //         log.Debugf("var = %s", "abc")
 //     }
 func ReplaceLogger(logger LoggerInterface) error {
-	if logger == nil {
-		return errors.New("Logger can not be nil")
-	}
-
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-
-	defer func() {
-		if err := recover(); err != nil {
-			fmt.Println(err)
-		}
-	}()
-
-	if Current == Default {
-		Current.Flush()
-	} else if Current != nil && !Current.Closed() && Current != Disabled {
-		Current.Flush()
-		Current.Close()
-	}
-
-	Current = logger
-
-	return nil
+	return std.ReplaceLogger(logger)
 }
 
 // Tracef formats message according to format specifier
 // and writes to default logger with log level = Trace.
 func Tracef(format string, params ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.traceWithCallDepth(staticFuncCallDepth, newLogFormattedMessage(format, params))
+	std.Tracef(format, params...)
 }
 
 // Debugf formats message according to format specifier
 // and writes to default logger with log level = Debug.
 func Debugf(format string, params ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.debugWithCallDepth(staticFuncCallDepth, newLogFormattedMessage(format, params))
+	std.Debugf(format, params...)
 }
 
 // Infof formats message according to format specifier
 // and writes to default logger with log level = Info.
 func Infof(format string, params ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.infoWithCallDepth(staticFuncCallDepth, newLogFormattedMessage(format, params))
+	std.Infof(format, params...)
 }
 
 // Warnf formats message according to format specifier and writes to default logger with log level = Warn
 func Warnf(format string, params ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.warnWithCallDepth(staticFuncCallDepth, newLogFormattedMessage(format, params))
+	std.Warnf(format, params...)
 }
 
 // Errorf formats message according to format specifier and writes to default logger with log level = Error
 func Errorf(format string, params ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.errorWithCallDepth(staticFuncCallDepth, newLogFormattedMessage(format, params))
+	std.Errorf(format, params...)
 }
 
 // Criticalf formats message according to format specifier and writes to default logger with log level = Critical
 func Criticalf(format string, params ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.criticalWithCallDepth(staticFuncCallDepth, newLogFormattedMessage(format, params))
+	std.Criticalf(format, params...)
+}
+
+// Logf formats message according to format specifier and writes to default logger with the specified
+// log level, which may be a custom level. See RegisterLevel.
+func Logf(level LogLevel, format string, params ...interface{}) {
+	std.Logf(level, format, params...)
 }
 
 // Trace formats message using the default formats for its operands and writes to default logger with log level = Trace
 func Trace(v ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.traceWithCallDepth(staticFuncCallDepth, newLogMessage(v))
+	std.Trace(v...)
 }
 
 // Debug formats message using the default formats for its operands and writes to default logger with log level = Debug
 func Debug(v ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.debugWithCallDepth(staticFuncCallDepth, newLogMessage(v))
+	std.Debug(v...)
 }
 
 // Info formats message using the default formats for its operands and writes to default logger with log level = Info
 func Info(v ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.infoWithCallDepth(staticFuncCallDepth, newLogMessage(v))
+	std.Info(v...)
 }
 
 // Warn formats message using the default formats for its operands and writes to default logger with log level = Warn
 func Warn(v ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.warnWithCallDepth(staticFuncCallDepth, newLogMessage(v))
+	std.Warn(v...)
 }
 
 // Error formats message using the default formats for its operands and writes to default logger with log level = Error
 func Error(v ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.errorWithCallDepth(staticFuncCallDepth, newLogMessage(v))
+	std.Error(v...)
 }
 
 // Critical formats message using the default formats for its operands and writes to default logger with log level = Critical
 func Critical(v ...interface{}) {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.criticalWithCallDepth(staticFuncCallDepth, newLogMessage(v))
+	std.Critical(v...)
 }
 
 // Flush immediately processes all currently queued messages and all currently buffered messages.
@@ -314,7 +292,94 @@ func Critical(v ...interface{}) {
 //
 // Call this method when your app is going to shut down not to lose any log messages.
 func Flush() {
-	pkgOperationsMutex.Lock()
-	defer pkgOperationsMutex.Unlock()
-	Current.Flush()
+	std.Flush()
+}
+
+// Tracew writes the message with the attached fields to default logger with log level = Trace
+func Tracew(message string, fields ...Field) {
+	std.Tracew(message, fields...)
+}
+
+// Debugw writes the message with the attached fields to default logger with log level = Debug
+func Debugw(message string, fields ...Field) {
+	std.Debugw(message, fields...)
+}
+
+// Infow writes the message with the attached fields to default logger with log level = Info
+func Infow(message string, fields ...Field) {
+	std.Infow(message, fields...)
+}
+
+// Warnw writes the message with the attached fields to default logger with log level = Warn
+func Warnw(message string, fields ...Field) {
+	std.Warnw(message, fields...)
+}
+
+// Errorw writes the message with the attached fields to default logger with log level = Error
+func Errorw(message string, fields ...Field) {
+	std.Errorw(message, fields...)
+}
+
+// Criticalw writes the message with the attached fields to default logger with log level = Critical
+func Criticalw(message string, fields ...Field) {
+	std.Criticalw(message, fields...)
+}
+
+// EventW writes a product or business event to the default logger. See LoggerEventW.
+func EventW(name string, fields ...Field) error {
+	return std.EventW(name, fields...)
+}
+
+// BoostLevel makes the default logger write messages at the specified level and above for
+// the given duration. See BoostLoggerLevel.
+func BoostLevel(level LogLevel, duration time.Duration) error {
+	return std.BoostLevel(level, duration)
+}
+
+// AddOutput adds an output to the default logger. See AddLoggerOutput.
+func AddOutput(output string) error {
+	return std.AddOutput(output)
+}
+
+// ReplaceOutput replaces an output of the default logger. See ReplaceLoggerOutput.
+func ReplaceOutput(output string) error {
+	return std.ReplaceOutput(output)
+}
+
+// RemoveOutput removes an output from the default logger. See RemoveLoggerOutput.
+func RemoveOutput(id string) error {
+	return std.RemoveOutput(id)
+}
+
+// SetOutputMinLevel sets the minimum level of an output of the default logger.
+// See SetLoggerOutputMinLevel.
+func SetOutputMinLevel(id string, level LogLevel) error {
+	return std.SetOutputMinLevel(id, level)
+}
+
+// To returns a facade of the default logger which directs the messages to the outputs with
+// the given ids only. See LoggerTo.
+//
+// Example:
+//     seelog.To("audit").Infow("role granted", seelog.Str("user", user))
+func To(ids ...string) *Facade {
+	return stdFacade.To(ids...)
+}
+
+// AlsoTo returns a facade of the default logger which directs the messages to the outputs
+// with the given ids in addition to the normal dispatch. See LoggerAlsoTo.
+func AlsoTo(ids ...string) *Facade {
+	return stdFacade.AlsoTo(ids...)
+}
+
+// AsJSON returns a facade of the default logger which writes the messages as JSON records
+// to the outputs with text formats. See LoggerAsJSON.
+func AsJSON() *Facade {
+	return stdFacade.AsJSON()
+}
+
+// Heartbeat writes progress records with the formatted message to the default logger
+// every interval until ctx is done, and then a completion record. See LoggerHeartbeat.
+func Heartbeat(ctx context.Context, interval time.Duration, format string, params ...interface{}) error {
+	return std.Heartbeat(ctx, interval, format, params...)
 }
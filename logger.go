@@ -60,6 +60,60 @@ type LoggerInterface interface {
 	Closed() bool
 }
 
+// StructuredLogger is implemented by all loggers of this package in addition to
+// LoggerInterface. It is a separate interface, so that implementations of LoggerInterface
+// outside the package are not broken by its methods. Assert a LoggerInterface to it:
+//
+//     if structured, ok := logger.(seelog.StructuredLogger); ok {
+//         structured.Infow("request", seelog.Str("method", r.Method))
+//     }
+type StructuredLogger interface {
+	LoggerInterface
+
+	Tracew(message string, fields ...Field)
+	Debugw(message string, fields ...Field)
+	Infow(message string, fields ...Field)
+	Warnw(message string, fields ...Field)
+	Errorw(message string, fields ...Field)
+	Criticalw(message string, fields ...Field)
+
+	// Logf writes the message at the specified level, which may be a custom level
+	// defined with RegisterLevel.
+	Logf(level LogLevel, format string, params ...interface{})
+}
+
+// levelLogger is implemented by loggers which write messages at any level, custom
+// levels included.
+type levelLogger interface {
+	logWithCallDepth(level LogLevel, callDepth int, message fmt.Stringer)
+}
+
+// logWithCallDepth writes the message to the logger at any level. Loggers which do not
+// implement levelLogger get the messages at the built-in level at or right below it.
+func logWithCallDepth(logger LoggerInterface, level LogLevel, callDepth int, message fmt.Stringer) {
+	if lLogger, ok := logger.(levelLogger); ok {
+		lLogger.logWithCallDepth(level, callDepth+1, message)
+		return
+	}
+
+	severity := level.severity()
+	switch {
+	case level == Off:
+	case severity >= LogLevel(CriticalLvl).severity():
+		logger.criticalWithCallDepth(callDepth+1, message)
+	case severity >= LogLevel(ErrorLvl).severity():
+		logger.errorWithCallDepth(callDepth+1, message)
+	case severity >= LogLevel(WarnLvl).severity():
+		logger.warnWithCallDepth(callDepth+1, message)
+	case severity >= LogLevel(InfoLvl).severity():
+		logger.infoWithCallDepth(callDepth+1, message)
+	case severity >= LogLevel(DebugLvl).severity():
+		logger.debugWithCallDepth(callDepth+1, message)
+	case severity >= LogLevel(TraceLvl).severity():
+		logger.traceWithCallDepth(callDepth+1, message)
+	}
+}
+
 // innerLoggerInterface is an internal logging interface
 type innerLoggerInterface interface {
 	innerLog(level LogLevel, context logContextInterface, message fmt.Stringer)
@@ -76,6 +130,8 @@ type commonLogger struct {
 	closed       bool                // 'true' when all writers are closed, all data is flushed, logger is unusable.
 	unusedLevels []bool
 	innerLogger  innerLoggerInterface
+	boost        levelBoost // Temporary verbosity raise, see BoostLoggerLevel
+	outputs      *outputTree // Output tree of the config and outputs added at runtime
 }
 
 func newCommonLogger(config *logConfig, internalLogger innerLoggerInterface) *commonLogger {
@@ -83,9 +139,10 @@ func newCommonLogger(config *logConfig, internalLogger innerLoggerInterface) *co
 
 	cLogger.config = config
 	cLogger.contextCache = make(allowedContextCache)
-	cLogger.unusedLevels = make([]bool, Off)
+	cLogger.unusedLevels = make([]bool, 1<<8) // One per LogLevel value, custom levels included
 	cLogger.fillUnusedLevels()
 	cLogger.innerLogger = internalLogger
+	cLogger.outputs = newOutputTree(config.RootDispatcher)
 
 	return cLogger
 }
@@ -138,6 +195,34 @@ func (cLogger *commonLogger) Critical(v ...interface{}) {
 	cLogger.criticalWithCallDepth(loggerFuncCallDepth, newLogMessage(v))
 }
 
+func (cLogger *commonLogger) Tracew(message string, fields ...Field) {
+	cLogger.traceWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (cLogger *commonLogger) Debugw(message string, fields ...Field) {
+	cLogger.debugWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (cLogger *commonLogger) Infow(message string, fields ...Field) {
+	cLogger.infoWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (cLogger *commonLogger) Warnw(message string, fields ...Field) {
+	cLogger.warnWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (cLogger *commonLogger) Errorw(message string, fields ...Field) {
+	cLogger.errorWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (cLogger *commonLogger) Criticalw(message string, fields ...Field) {
+	cLogger.criticalWithCallDepth(loggerFuncCallDepth, newLogFieldsMessage(message, fields))
+}
+
+func (cLogger *commonLogger) Logf(level LogLevel, format string, params ...interface{}) {
+	cLogger.logWithCallDepth(level, loggerFuncCallDepth, newLogFormattedMessage(format, params))
+}
+
 func (cLogger *commonLogger) traceWithCallDepth(callDepth int, message fmt.Stringer) {
 	cLogger.log(TraceLvl, message, callDepth)
 }
@@ -163,6 +248,15 @@ func (cLogger *commonLogger) criticalWithCallDepth(callDepth int, message fmt.St
 	cLogger.innerLogger.Flush()
 }
 
+// logWithCallDepth logs at any level. Levels from Critical up flush the logger as
+// criticalWithCallDepth does.
+func (cLogger *commonLogger) logWithCallDepth(level LogLevel, callDepth int, message fmt.Stringer) {
+	cLogger.log(level, message, callDepth)
+	if level != Off && level.severity() >= LogLevel(CriticalLvl).severity() {
+		cLogger.innerLogger.Flush()
+	}
+}
+
 func (cLogger *commonLogger) Closed() bool {
 	return cLogger.closed
 }
@@ -199,11 +293,34 @@ func (cLogger *commonLogger) log(
 		return
 	}
 
-	if cLogger.unusedLevels[level] {
+	if cLogger.unusedLevels[level] && !cLogger.boost.allowsNow(level) {
 		return
 	}
 
 	context, _ := specificContext(stackCallDepth)
+	if cLogger.config.Stack.allows(level) {
+		setContextStack(context, captureStack(stackCallDepth, cLogger.config.Stack.Depth))
+	}
+
+	message = withGoroutineContext(message)
+	if level == CriticalLvl {
+		rememberCritical(message)
+	}
+	var options *recordOptions
+	if optionsMsg, ok := message.(*optionsMessage); ok {
+		options = optionsMsg.options
+	}
+	carrier, ok := message.(fieldsCarrier)
+	if ok {
+		fields, format := formatFromFields(carrier.Fields())
+		if format != nil {
+			options = (&recordOptions{format: format}).merge(options)
+		}
+		setContextFields(context, fields)
+	}
+	if options != nil {
+		context = &optionsContext{context, options}
+	}
 
 	// Context errors are not reported because there are situations
 	// in which context errors are normal Seelog usage cases. For 
@@ -228,8 +345,9 @@ func (cLogger *commonLogger) processLogMsg(
 		}
 	}()
 
-	if cLogger.config.IsAllowed(level, context) {
-		cLogger.config.RootDispatcher.Dispatch(message.String(), level, context, reportInternalError)
+	_, isEvent := eventName(context)
+	if isEvent || cLogger.boost.allows(level, context.CallTime()) || cLogger.config.IsAllowed(level, context) {
+		cLogger.outputs.Dispatch(message.String(), level, context, reportInternalError)
 	}
 }
 
@@ -281,6 +399,11 @@ func newLogFormattedMessage(format string, params []interface{}) *logFormattedMe
 	return message
 }
 
+// newLogFieldsMessage creates a message with fields attached at the call site.
+func newLogFieldsMessage(message string, fields []Field) fmt.Stringer {
+	return newFieldsMessage(newLogMessage([]interface{}{message}), fields)
+}
+
 func (message *logMessage) String() string {
 	return fmt.Sprint(message.params...)
 }
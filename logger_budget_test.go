@@ -0,0 +1,105 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"io"
+	"testing"
+)
+
+// budget is the most a benchmark may allocate per operation. The limits are a little
+// above the measured values, so they catch regressions and not noise.
+type budget struct {
+	allocs int64
+	bytes  int64
+}
+
+// checkBudget runs the benchmark and fails the test if it allocates over the budget.
+// Run with -short to skip the budget tests, e.g. under the race detector.
+func checkBudget(t *testing.T, benchmark func(*testing.B), limit budget) {
+	if testing.Short() {
+		t.Skip("Budget tests run benchmarks")
+	}
+	result := testing.Benchmark(benchmark)
+	if result.N == 0 {
+		t.Fatal("Benchmark failed")
+	}
+	if allocs := result.AllocsPerOp(); allocs > limit.allocs {
+		t.Errorf("Expected at most %d allocs/op, got %d", limit.allocs, allocs)
+	}
+	if bytes := result.AllocedBytesPerOp(); bytes > limit.bytes {
+		t.Errorf("Expected at most %d B/op, got %d", limit.bytes, bytes)
+	}
+	t.Logf("%d allocs/op, %d B/op", result.AllocsPerOp(), result.AllocedBytesPerOp())
+}
+
+func BenchmarkDisabledLevel(b *testing.B) {
+	logger := newTestLogger(b, testLoggerConfig{logType: syncloggerTypeFromString, minLevel: InfoLvl, format: "%Date %Time [%LEV] %Msg%n", writers: []interface{}{io.Discard}})
+	defer logger.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debugf("message %d", i)
+	}
+}
+
+func BenchmarkFormattedWrite(b *testing.B) {
+	logger := newTestLogger(b, testLoggerConfig{logType: syncloggerTypeFromString, minLevel: TraceLvl, format: "%Date %Time [%LEV] %Msg%n", writers: []interface{}{io.Discard}})
+	defer logger.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infof("message %d", i)
+	}
+}
+
+func BenchmarkAsyncEnqueue(b *testing.B) {
+	logger := newTestLogger(b, testLoggerConfig{logType: asyncLooploggerTypeFromString, minLevel: TraceLvl, format: "%Date %Time [%LEV] %Msg%n", writers: []interface{}{io.Discard}})
+	defer logger.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infof("message %d", i)
+
+		// Keep the queue below the overflow limit, which would flush in the loop
+		if i%1000 == 999 {
+			b.StopTimer()
+			logger.Flush()
+			b.StartTimer()
+		}
+	}
+}
+
+func TestDisabledLevelBudget(t *testing.T) {
+	checkBudget(t, BenchmarkDisabledLevel, budget{allocs: 2, bytes: 96})
+}
+
+func TestFormattedWriteBudget(t *testing.T) {
+	checkBudget(t, BenchmarkFormattedWrite, budget{allocs: 20, bytes: 1024})
+}
+
+func TestAsyncEnqueueBudget(t *testing.T) {
+	checkBudget(t, BenchmarkAsyncEnqueue, budget{allocs: 12, bytes: 768})
+}
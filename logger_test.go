@@ -0,0 +1,95 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testLoggerConfig describes a logger created by newTestLogger. The zero value describes a
+// sync logger for all levels which writes messages in the "%Msg%n" format.
+type testLoggerConfig struct {
+	logType    loggerTypeFromString
+	minLevel   LogLevel
+	format     string        // Format with id "msg"; "%Msg%n" if empty
+	stackDepth int           // Frames captured for records of stackLevel and above; 0 for none
+	stackLevel LogLevel
+	outputs    string        // XML of output elements, such as `<file path="app.log"/>`
+	writers    []interface{} // Writers and receivers which replace the outputs
+}
+
+// newTestLogger creates the logger described by the config. Errors fail the test.
+func newTestLogger(tb testing.TB, config testLoggerConfig) StructuredLogger {
+	tb.Helper()
+
+	format := config.format
+	if format == "" {
+		format = "%Msg%n"
+	}
+
+	root := newBuilderNode(seelogConfigId,
+		loggerTypeFromStringAttr, loggerTypeToStringRepresentations[config.logType],
+		minLevelId, config.minLevel.String())
+	if config.stackDepth > 0 {
+		root.attributes[stackDepthAttr] = strconv.Itoa(config.stackDepth)
+		root.attributes[stackLevelAttr] = config.stackLevel.String()
+	}
+
+	if config.outputs != "" {
+		outputs, err := unmarshalConfig(strings.NewReader("<" + outputsId + ">" + config.outputs + "</" + outputsId + ">"))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		outputs.attributes[outputFormatId] = "msg"
+		root.add(outputs)
+	}
+
+	formats := newBuilderNode(formatsId)
+	formats.add(newBuilderNode(formatId, formatKeyAttrId, "msg", formatAttrId, format))
+	root.add(formats)
+
+	conf, err := configFromRoot(root)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if len(config.writers) > 0 {
+		formatter, err := newFormatter(format)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		conf.RootDispatcher, err = newSplitDispatcher(formatter, config.writers)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	logger, err := createLoggerFromConfig(conf)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return logger.(StructuredLogger)
+}
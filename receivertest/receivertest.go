@@ -0,0 +1,306 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+// Package receivertest is a conformance test suite for custom seelog receivers.
+//
+// A receiver is an io.Writer which seelog writes formatted messages to. It may also
+// implement io.Closer and Flush(), which seelog calls on logger Close and Flush. Run
+// checks that a receiver delivers messages in order, delivers them on Flush and Close,
+// tolerates use after Close, does not block or panic while its destination fails and
+// recovers when the destination is back.
+//
+// Example:
+//     func TestMyReceiver(t *testing.T) {
+//         receivertest.Run(t, func(t *testing.T) *receivertest.Harness {
+//             server := newFakeServer(t)
+//             return &receivertest.Harness{
+//                 Receiver: myreceiver.New(server.Addr()),
+//                 Received: server.Messages,
+//                 Break:    server.Stop,
+//                 Restore:  server.Start,
+//             }
+//         })
+//     }
+package receivertest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	log "seelog"
+)
+
+// Timeout is how long Run waits for messages to arrive and for receiver calls to return.
+var Timeout = 5 * time.Second
+
+// Harness is a receiver under test together with access to its destination.
+type Harness struct {
+	// Receiver is the receiver under test. Required.
+	Receiver io.Writer
+
+	// Received returns the messages which arrived at the destination so far, in arrival
+	// order, decoded from the wire format of the receiver. Trailing newlines are ignored.
+	// Required.
+	Received func() []string
+
+	// Break makes the destination unavailable, e.g. stops a server. Restore makes it
+	// available again. Error and reconnect checks are skipped if they are nil.
+	Break   func()
+	Restore func()
+}
+
+// Factory creates a new harness for every check. Cleanup of the destination should be
+// registered with t.Cleanup; the receiver is closed by Run.
+type Factory func(t *testing.T) *Harness
+
+type flusher interface {
+	Flush()
+}
+
+// Run runs all checks as subtests of t.
+func Run(t *testing.T, factory Factory) {
+	t.Run("Ordering", func(t *testing.T) { testOrdering(t, newHarness(t, factory)) })
+	t.Run("Flush", func(t *testing.T) { testFlush(t, newHarness(t, factory)) })
+	t.Run("Close", func(t *testing.T) { testClose(t, newHarness(t, factory)) })
+	t.Run("Logger", func(t *testing.T) { testLogger(t, newHarness(t, factory)) })
+	t.Run("Error", func(t *testing.T) { testError(t, newHarness(t, factory)) })
+	t.Run("Reconnect", func(t *testing.T) { testReconnect(t, newHarness(t, factory)) })
+}
+
+func newHarness(t *testing.T, factory Factory) *Harness {
+	harness := factory(t)
+	if harness == nil || harness.Receiver == nil || harness.Received == nil {
+		t.Fatal("Factory must return a harness with Receiver and Received")
+	}
+	t.Cleanup(func() { closeReceiver(t, harness.Receiver) })
+	return harness
+}
+
+func message(i int) string {
+	return fmt.Sprintf("receivertest message %03d", i)
+}
+
+// within fails the test if f does not return within Timeout.
+func within(t *testing.T, what string, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+	case <-time.After(Timeout):
+		t.Fatalf("%s did not return within %s", what, Timeout)
+	}
+}
+
+func write(t *testing.T, receiver io.Writer, msg string) (err error) {
+	t.Helper()
+	within(t, "Write", func() {
+		_, err = receiver.Write([]byte(msg + "\n"))
+	})
+	return err
+}
+
+func flush(t *testing.T, receiver io.Writer) {
+	t.Helper()
+	if f, ok := receiver.(flusher); ok {
+		within(t, "Flush", f.Flush)
+	}
+}
+
+func closeReceiver(t *testing.T, receiver io.Writer) (err error) {
+	t.Helper()
+	if closer, ok := receiver.(io.Closer); ok {
+		within(t, "Close", func() { err = closer.Close() })
+	}
+	return err
+}
+
+// waitFor waits until the received messages satisfy check and returns them.
+func waitFor(harness *Harness, check func([]string) bool) ([]string, bool) {
+	deadline := time.Now().Add(Timeout)
+	for {
+		received := normalize(harness.Received())
+		if check(received) {
+			return received, true
+		}
+		if time.Now().After(deadline) {
+			return received, false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func normalize(messages []string) []string {
+	result := make([]string, len(messages))
+	for i, msg := range messages {
+		result[i] = strings.TrimRight(msg, "\r\n")
+	}
+	return result
+}
+
+// contains reports whether one of the messages contains msg. Messages written through a
+// logger are formatted, so they are matched by substring.
+func contains(messages []string, msg string) bool {
+	for _, m := range messages {
+		if strings.Contains(m, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+func testOrdering(t *testing.T, harness *Harness) {
+	const count = 100
+	for i := 0; i < count; i++ {
+		if err := write(t, harness.Receiver, message(i)); err != nil {
+			t.Fatalf("Write failed: %s", err)
+		}
+	}
+	flush(t, harness.Receiver)
+
+	received, ok := waitFor(harness, func(received []string) bool { return len(received) >= count })
+	if !ok {
+		t.Fatalf("Expected %d messages, received %d", count, len(received))
+	}
+	for i := 0; i < count; i++ {
+		if received[i] != message(i) {
+			t.Fatalf("Message %d: expected %q, got %q", i, message(i), received[i])
+		}
+	}
+	if len(received) > count {
+		t.Errorf("Expected %d messages, received %d", count, len(received))
+	}
+}
+
+func testFlush(t *testing.T, harness *Harness) {
+	if _, ok := harness.Receiver.(flusher); !ok {
+		t.Skip("Receiver does not implement Flush")
+	}
+
+	if err := write(t, harness.Receiver, message(1)); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	flush(t, harness.Receiver)
+
+	if received, ok := waitFor(harness, func(received []string) bool { return contains(received, message(1)) }); !ok {
+		t.Errorf("Flushed message did not arrive, received %q", received)
+	}
+}
+
+func testClose(t *testing.T, harness *Harness) {
+	if _, ok := harness.Receiver.(io.Closer); !ok {
+		t.Skip("Receiver does not implement io.Closer")
+	}
+
+	if err := write(t, harness.Receiver, message(1)); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if err := closeReceiver(t, harness.Receiver); err != nil {
+		t.Errorf("Close failed: %s", err)
+	}
+	if received, ok := waitFor(harness, func(received []string) bool { return contains(received, message(1)) }); !ok {
+		t.Errorf("Message written before Close did not arrive, received %q", received)
+	}
+
+	// Seelog may still write to a closed receiver during logger replacement
+	write(t, harness.Receiver, message(2))
+	closeReceiver(t, harness.Receiver)
+}
+
+func testLogger(t *testing.T, harness *Harness) {
+	logger, err := log.LoggerFromWriterWithMinLevel(harness.Receiver, log.TraceLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	within(t, "Logging", func() {
+		logger.Info(message(1))
+		logger.(log.StructuredLogger).Errorw(message(2), log.Str("key", "value"))
+		logger.Flush()
+	})
+
+	received, ok := waitFor(harness, func(received []string) bool {
+		return contains(received, message(1)) && contains(received, message(2))
+	})
+	if !ok {
+		t.Errorf("Logged messages did not arrive, received %q", received)
+	}
+}
+
+func testError(t *testing.T, harness *Harness) {
+	if harness.Break == nil || harness.Restore == nil {
+		t.Skip("Harness does not support destination failures")
+	}
+
+	harness.Break()
+	defer harness.Restore()
+
+	// Errors are allowed, blocking and panics are not
+	for i := 0; i < 10; i++ {
+		write(t, harness.Receiver, message(i))
+	}
+	flush(t, harness.Receiver)
+}
+
+func testReconnect(t *testing.T, harness *Harness) {
+	if harness.Break == nil || harness.Restore == nil {
+		t.Skip("Harness does not support destination failures")
+	}
+
+	if err := write(t, harness.Receiver, message(1)); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	flush(t, harness.Receiver)
+
+	harness.Break()
+	write(t, harness.Receiver, message(2))
+	flush(t, harness.Receiver)
+	harness.Restore()
+
+	// The receiver may need a few writes to notice the destination is back
+	restored := map[string]bool{}
+	deadline := time.Now().Add(Timeout)
+	for i := 3; ; i++ {
+		restored[message(i)] = true
+		write(t, harness.Receiver, message(i))
+		flush(t, harness.Receiver)
+
+		received := normalize(harness.Received())
+		for _, msg := range received {
+			if restored[msg] {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("No message arrived after the destination was restored, received %q", received)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
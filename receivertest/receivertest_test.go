@@ -0,0 +1,198 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package receivertest
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"testing"
+)
+
+// memoryReceiver keeps the written messages. It fails all writes while broken.
+type memoryReceiver struct {
+	mutex    sync.Mutex
+	messages []string
+	broken   bool
+	closed   bool
+}
+
+func (receiver *memoryReceiver) Write(bytes []byte) (int, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.broken || receiver.closed {
+		return 0, net.ErrClosed
+	}
+	receiver.messages = append(receiver.messages, string(bytes))
+	return len(bytes), nil
+}
+
+func (receiver *memoryReceiver) Close() error {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	receiver.closed = true
+	return nil
+}
+
+func (receiver *memoryReceiver) received() []string {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	return append([]string(nil), receiver.messages...)
+}
+
+func (receiver *memoryReceiver) setBroken(broken bool) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	receiver.broken = broken
+}
+
+func TestMemoryReceiver(t *testing.T) {
+	Run(t, func(t *testing.T) *Harness {
+		receiver := &memoryReceiver{}
+		return &Harness{
+			Receiver: receiver,
+			Received: receiver.received,
+			Break:    func() { receiver.setBroken(true) },
+			Restore:  func() { receiver.setBroken(false) },
+		}
+	})
+}
+
+// tcpReceiver writes to a TCP server and redials after a failed write.
+type tcpReceiver struct {
+	mutex sync.Mutex
+	addr  string
+	conn  net.Conn
+}
+
+func (receiver *tcpReceiver) Write(bytes []byte) (int, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.conn == nil {
+		conn, err := net.Dial("tcp", receiver.addr)
+		if err != nil {
+			return 0, err
+		}
+		receiver.conn = conn
+	}
+	n, err := receiver.conn.Write(bytes)
+	if err != nil {
+		receiver.conn.Close()
+		receiver.conn = nil
+	}
+	return n, err
+}
+
+func (receiver *tcpReceiver) Close() error {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.conn == nil {
+		return nil
+	}
+	err := receiver.conn.Close()
+	receiver.conn = nil
+	return err
+}
+
+// lineServer collects the lines sent to it. Stopping it closes the listener and all
+// accepted connections.
+type lineServer struct {
+	mutex    sync.Mutex
+	addr     string
+	listener net.Listener
+	conns    []net.Conn
+	lines    []string
+}
+
+func newLineServer(t *testing.T) *lineServer {
+	server := &lineServer{addr: "127.0.0.1:0"}
+	server.start(t)
+	t.Cleanup(server.stop)
+	return server
+}
+
+func (server *lineServer) start(t *testing.T) {
+	listener, err := net.Listen("tcp", server.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.mutex.Lock()
+	server.addr = listener.Addr().String()
+	server.listener = listener
+	server.mutex.Unlock()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mutex.Lock()
+			server.conns = append(server.conns, conn)
+			server.mutex.Unlock()
+			go server.serve(conn)
+		}
+	}()
+}
+
+func (server *lineServer) serve(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		server.mutex.Lock()
+		server.lines = append(server.lines, scanner.Text())
+		server.mutex.Unlock()
+	}
+}
+
+func (server *lineServer) stop() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.listener != nil {
+		server.listener.Close()
+		server.listener = nil
+	}
+	for _, conn := range server.conns {
+		conn.Close()
+	}
+	server.conns = nil
+}
+
+func (server *lineServer) received() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string(nil), server.lines...)
+}
+
+func TestTCPReceiver(t *testing.T) {
+	Run(t, func(t *testing.T) *Harness {
+		server := newLineServer(t)
+		return &Harness{
+			Receiver: &tcpReceiver{addr: server.addr},
+			Received: server.received,
+			Break:    server.stop,
+			Restore:  func() { server.start(t) },
+		}
+	})
+}
@@ -23,7 +23,7 @@
 
      Despite the level mapping,
        1. Semantics of golang's log remain the same. E.g., Fatal also means
-     Print() then os.Exit().
+     Print() then exit, after the logger is flushed and closed.
        2. All seelog's APIs also be exposed. E.g., log.Tracef() is exposed even
      though there is no mapping in golang's log.
 
@@ -35,20 +35,21 @@
 package seelogWrapper
 
 import (
+  "context"
+  "flag"
   "fmt"
   log "seelog"
   "io"
-  "os"
+  "time"
 )
 
-var seelogStaticFuncCallDepth int
+// std is the seelog facade with the frame of the wrapper funcs skipped, so the callers of
+// the wrapper funcs are the context of messages. All wrapper funcs call it directly.
+var std = log.Std().Skip(1)
 
 // Init the default logger to console, and in sync mode
 
 func init() {
-  seelogStaticFuncCallDepth = log.GetStaticFuncCallDepth()
-  log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth + 1)
-
   c := `<seelog type="sync">
           <outputs formatid="ccmp">
             <console />
@@ -70,52 +71,172 @@ func ReplaceLogger(logger log.LoggerInterface) error {
   return log.ReplaceLogger(logger)
 }
 
+func CurrentLogger() log.LoggerInterface {
+  return log.CurrentLogger()
+}
+
 func Tracef(format string, params ...interface{}) {
-  log.Tracef(format, params...)
+  std.Tracef(format, params...)
 }
 
 func Debugf(format string, params ...interface{}) {
-  log.Debugf(format, params...)
+  std.Debugf(format, params...)
 }
 
 func Infof(format string, params ...interface{}) {
-  log.Infof(format, params...)
+  std.Infof(format, params...)
 }
 
 func Warnf(format string, params ...interface{}) {
-  log.Warnf(format, params...)
+  std.Warnf(format, params...)
 }
 
 func Errorf(format string, params ...interface{}) {
-  log.Errorf(format, params...)
+  std.Errorf(format, params...)
 }
 
 func Criticalf(format string, params ...interface{}) {
-  log.Criticalf(format, params...)
+  std.Criticalf(format, params...)
+}
+
+func Logf(level log.LogLevel, format string, params ...interface{}) {
+  std.Logf(level, format, params...)
 }
 
 func Trace(v ...interface{}) {
-  log.Trace(v...)
+  std.Trace(v...)
 }
 
 func Debug(v ...interface{}) {
-  log.Debug(v...)
+  std.Debug(v...)
 }
 
 func Info(v ...interface{}) {
-  log.Info(v...)
+  std.Info(v...)
 }
 
 func Warn(v ...interface{}) {
-  log.Warn(v...)
+  std.Warn(v...)
 }
 
 func Error(v ...interface{}) {
-  log.Error(v...)
+  std.Error(v...)
 }
 
 func Critical(v ...interface{}) {
-  log.Error(v...)
+  std.Error(v...)
+}
+
+func Tracew(message string, fields ...log.Field) {
+  std.Tracew(message, fields...)
+}
+
+func Debugw(message string, fields ...log.Field) {
+  std.Debugw(message, fields...)
+}
+
+func Infow(message string, fields ...log.Field) {
+  std.Infow(message, fields...)
+}
+
+func Warnw(message string, fields ...log.Field) {
+  std.Warnw(message, fields...)
+}
+
+func Errorw(message string, fields ...log.Field) {
+  std.Errorw(message, fields...)
+}
+
+func Criticalw(message string, fields ...log.Field) {
+  std.Criticalw(message, fields...)
+}
+
+func PushContext(fields ...log.Field) (pop func()) {
+  return log.PushContext(fields...)
+}
+
+func PopContext() {
+  log.PopContext()
+}
+
+func GoWithContext(f func()) {
+  log.GoWithContext(f)
+}
+
+func EventW(name string, fields ...log.Field) error {
+  return log.EventW(name, fields...)
+}
+
+func BoostLevel(level log.LogLevel, duration time.Duration) error {
+  return log.BoostLevel(level, duration)
+}
+
+func AddOutput(output string) error {
+  return log.AddOutput(output)
+}
+
+func ReplaceOutput(output string) error {
+  return log.ReplaceOutput(output)
+}
+
+func RemoveOutput(id string) error {
+  return log.RemoveOutput(id)
+}
+
+func SetOutputMinLevel(id string, level log.LogLevel) error {
+  return log.SetOutputMinLevel(id, level)
+}
+
+func To(ids ...string) *log.Facade {
+  return log.To(ids...)
+}
+
+func AlsoTo(ids ...string) *log.Facade {
+  return log.AlsoTo(ids...)
+}
+
+func AsJSON() *log.Facade {
+  return log.AsJSON()
+}
+
+func Heartbeat(ctx context.Context, interval time.Duration, format string, v ...interface{}) error {
+  return log.Heartbeat(ctx, interval, format, v...)
+}
+
+func Main(run func() int) {
+  log.Main(run)
+}
+
+func Exit(code int) {
+  log.Exit(code)
+}
+
+func EnableSignalReload(fileName string) error {
+  return log.EnableSignalReload(fileName)
+}
+
+func WatchConfigFile(fileName string, interval time.Duration) (stop func(), err error) {
+  return log.WatchConfigFile(fileName, interval)
+}
+
+func DevConfig() *log.ConfigBuilder {
+  return log.DevConfig()
+}
+
+func ProdConfig(dir string) *log.ConfigBuilder {
+  return log.ProdConfig(dir)
+}
+
+func RegisterFlags(fs *flag.FlagSet) {
+  log.RegisterFlags(fs)
+}
+
+func SetUpstreamCompatible(enabled bool) {
+  log.SetUpstreamCompatible(enabled)
+}
+
+func RegisterLevel(name string, above log.LogLevel) (log.LogLevel, error) {
+  return log.RegisterLevel(name, above)
 }
 
 func Flush() {
@@ -138,6 +259,14 @@ func LoggerFromConfigAsString(data string) (log.LoggerInterface, error) {
   return log.LoggerFromConfigAsString(data)
 }
 
+func LoggerFromConfigs(base []byte, overlays ...[]byte) (log.LoggerInterface, error) {
+  return log.LoggerFromConfigs(base, overlays...)
+}
+
+func LoggerFromConfigAsURL(url string, refreshInterval time.Duration) (log.LoggerInterface, error) {
+  return log.LoggerFromConfigAsURL(url, refreshInterval)
+}
+
 func LoggerFromWriterWithMinLevel(output io.Writer,
   minLevel log.LogLevel) (log.LoggerInterface, error) {
   return log.LoggerFromWriterWithMinLevel(output, minLevel)
@@ -145,64 +274,73 @@ func LoggerFromWriterWithMinLevel(output io.Writer,
 
 // belows are APIs needed by our codebase
 
-// Fatal equals seelog.Error() then os.Exit(1)
+// Fatal equals seelog.Error() then Exit(1), which flushes and closes the logger first
 func Fatal(v ...interface{}) {
-  // +2 because Fatal -> Error -> seelog.Error, others are similar
-  log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth + 2)
-  defer log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth)
-  log.Error(v...)
-  os.Exit(1)
+  std.Error(v...)
+  log.Exit(1)
 }
 
-// Panic equals seelog.Critical() then panic()
+// Panic equals seelog.Critical() then panic(). The critical record carries the stack
+// of the panicking goroutine as the "panic" group of fields, see Panicw.
 func Panic(v ...interface{}) {
-  log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth + 2)
-  defer log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth)
-  log.Critical(v...)
+  std.Criticalw(fmt.Sprint(v...), panicFields(nil)...)
   panic("Panic in seelogWrapper, check last critical log for reason.!")
 }
 
 func Print(v ...interface{}) {
-  log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth + 2)
-  defer log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth)
-  log.Info(v...)
+  std.Info(v...)
 }
 
 func Println(v ...interface{}) {
-  log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth + 2)
-  defer log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth)
-  log.Info(fmt.Sprintln(v...))
+  std.Info(fmt.Sprintln(v...))
 }
 
 // Same side-effect as Fatal
 func Fatalf(format string, v ...interface{}) {
-  log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth + 2)
-  defer log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth)
-  s := fmt.Sprintf(format, v...)
-  Fatal(s)
+  std.Error(fmt.Sprintf(format, v...))
+  log.Exit(1)
 }
 
 // Same side-effect as Panic
 func Panicf(format string, v ...interface{}) {
-  log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth + 2)
-  defer log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth)
-  s := fmt.Sprintf(format, v...)
-  Panic(s)
+  std.Criticalw(fmt.Sprintf(format, v...), panicFields(nil)...)
+  panic("Panic in seelogWrapper, check last critical log for reason.!")
+}
+
+// Panicw equals seelog.Criticalw() then panic(). Besides the given fields and the
+// context fields of the goroutine, the critical record gets the stack of the
+// panicking goroutine, so crash records can be analyzed from structured outputs:
+//     {"msg":"cache corrupted","key":"users","panic":{"stack":[{"func":"main.load","file":"/app/main.go","line":42}]}}
+func Panicw(message string, fields ...log.Field) {
+  std.Criticalw(message, panicFields(fields)...)
+  panic("Panic in seelogWrapper, check last critical log for reason.!")
+}
+
+// panicFields adds the stack of the caller of a Panic func to the fields of its
+// critical record.
+func panicFields(fields []log.Field) []log.Field {
+  stack := log.Group("panic", log.CallerStack("stack", 2))
+  return append(fields[:len(fields):len(fields)], stack)
 }
 
 func Printf(format string, v ...interface{}) {
-  log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth + 2)
-  defer log.SetStaticFuncCallDepth(seelogStaticFuncCallDepth)
-  s := fmt.Sprintf(format, v...)
-  Print(s)
+  std.Info(fmt.Sprintf(format, v...))
 }
 
 // SetLoggerConfig sets logger with config.
 //
 // For details of how to write the seelog config,
 // check https://github.com/cihub/seelog/wiki
-func SetLoggerConfig(config string) {
-  logger, _ := log.LoggerFromConfigAsBytes([]byte(config))
+//
+// config is either an xml config string or a *seelog.ConfigBuilder.
+func SetLoggerConfig(config interface{}) {
+  var logger log.LoggerInterface
+  switch c := config.(type) {
+  case string:
+    logger, _ = log.LoggerFromConfigAsBytes([]byte(c))
+  case *log.ConfigBuilder:
+    logger, _ = c.Build()
+  }
   err := log.ReplaceLogger(logger)
   if err != nil {
     Panicf("Can not replace default logger with new config: %v", config)
@@ -5,6 +5,49 @@
 
 package seelogWrapper
 
+import (
+  "io/ioutil"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+// TestFatalFlushes runs Fatal and Fatalf in a child process with an async logger, which
+// must write the fatal records before the process exits with code 1.
+func TestFatalFlushes(t *testing.T) {
+  if path := os.Getenv("SEELOG_WRAPPER_FATAL_LOG"); path != "" {
+    logger, err := LoggerFromConfigAsString(`<seelog type="asynctimer" asyncinterval="60000000">
+                       <outputs formatid="msg"><file path="` + filepath.ToSlash(path) + `"/></outputs>
+                       <formats><format id="msg" format="%Msg%n"/></formats>
+                     </seelog>`)
+    if err != nil {
+      t.Fatal(err)
+    }
+    ReplaceLogger(logger)
+    if os.Getenv("SEELOG_WRAPPER_FATALF") != "" {
+      Fatalf("fatal %s", "formatted")
+    }
+    Fatal("fatal record")
+    return
+  }
+
+  for env, expected := range map[string]string{"SEELOG_WRAPPER_FATAL=1": "fatal record", "SEELOG_WRAPPER_FATALF=1": "fatal formatted"} {
+    path := filepath.Join(t.TempDir(), "fatal.log")
+    cmd := exec.Command(os.Args[0], "-test.run=^TestFatalFlushes$")
+    cmd.Env = append(os.Environ(), "SEELOG_WRAPPER_FATAL_LOG="+path, env)
+    err := cmd.Run()
+    if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+      t.Errorf("%s: expected exit code 1, got %v", env, err)
+    }
+    data, err := ioutil.ReadFile(path)
+    if err != nil || !strings.Contains(string(data), expected) {
+      t.Errorf("%s: expected %q to be flushed, got %q: %v", env, expected, data, err)
+    }
+  }
+}
+
 func ExampleAll() {
   Println("just Println")
   Print("just Print")
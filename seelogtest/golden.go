@@ -0,0 +1,126 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+// Package seelogtest contains helpers for testing seelog configs and formats.
+package seelogtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	log "seelog"
+)
+
+// UpdateEnv is the environment variable which makes Golden write the snapshots instead
+// of comparing with them.
+//
+// Example:
+//     SEELOGTEST_UPDATE=1 go test ./...
+const UpdateEnv = "SEELOGTEST_UPDATE"
+
+// Golden renders the records through the config (see seelog.RenderConfig) and compares the
+// output of all writers with the snapshot testdata/<test name>.golden. The test fails with
+// the first difference if they do not match. If the UpdateEnv environment variable is set,
+// the snapshot is written instead, so changes of formats and configs can be reviewed as
+// changes of the snapshot files.
+//
+// Example:
+//     func TestAccessLogFormat(t *testing.T) {
+//         seelogtest.Golden(t, config, []seelog.Record{
+//             {Level: seelog.InfoLvl, Message: "started", Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+//         })
+//     }
+func Golden(t testing.TB, config string, records []log.Record) {
+	t.Helper()
+
+	outputs, err := log.RenderConfig([]byte(config), records)
+	if err != nil {
+		t.Fatalf("Config can not be rendered: %s", err)
+	}
+	actual := snapshot(outputs)
+
+	fileName := filepath.Join("testdata", filepath.FromSlash(t.Name())+".golden")
+	if os.Getenv(UpdateEnv) != "" {
+		err := os.MkdirAll(filepath.Dir(fileName), 0755)
+		if err == nil {
+			err = os.WriteFile(fileName, actual, 0666)
+		}
+		if err != nil {
+			t.Fatalf("Snapshot can not be written: %s", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("Snapshot can not be read (set %s=1 to create it): %s", UpdateEnv, err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Output differs from %s (set %s=1 to update it)\n%s", fileName, UpdateEnv, difference(expected, actual))
+	}
+}
+
+// snapshot joins the outputs sorted by path, each one after a header line.
+func snapshot(outputs map[string][]byte) []byte {
+	paths := make([]string, 0, len(outputs))
+	for path := range outputs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "=== %s\n", path)
+		buf.Write(outputs[path])
+		if len(outputs[path]) > 0 && !bytes.HasSuffix(outputs[path], []byte("\n")) {
+			buf.WriteString("\n(no newline at end)\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// difference describes the first differing line of two snapshots.
+func difference(expected, actual []byte) string {
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var expectedLine, actualLine string
+		if i < len(expectedLines) {
+			expectedLine = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			actualLine = actualLines[i]
+		}
+		if i >= len(expectedLines) || i >= len(actualLines) || expectedLine != actualLine {
+			return fmt.Sprintf("line %d:\n  expected: %q\n  actual:   %q", i+1, expectedLine, actualLine)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelogtest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	log "seelog"
+)
+
+const goldenTestConfig = `<seelog type="asyncloop" minlevel="debug">
+	<outputs formatid="main">
+		<console/>
+		<filter levels="error,critical">
+			<file path="errors.log" formatid="error"/>
+		</filter>
+		<filter levels="critical">
+			<conn net="tcp" addr="localhost:1"/>
+		</filter>
+	</outputs>
+	<formats>
+		<format id="main" format="%Date(2006-01-02T15:04:05Z07:00) [%LEV] %Msg%n"/>
+		<format id="error" format="%Level %FuncShort@%File:%Line %Msg {%Field(user)}%n"/>
+	</formats>
+</seelog>`
+
+var goldenTestRecords = []log.Record{
+	{Level: log.TraceLvl, Message: "not logged"},
+	{Level: log.InfoLvl, Message: "started", Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	{
+		Level:   log.ErrorLvl,
+		Message: "login failed",
+		Time:    time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC),
+		Func:    "app/auth.Login",
+		File:    "auth/login.go",
+		Line:    42,
+		Fields:  []log.Field{log.Str("user", "bob")},
+	},
+}
+
+func TestGolden(t *testing.T) {
+	Golden(t, goldenTestConfig, goldenTestRecords)
+}
+
+func TestSnapshot(t *testing.T) {
+	actual := string(snapshot(map[string][]byte{"b": []byte("2"), "a": []byte("1\n"), "c": nil}))
+	expected := "=== a\n1\n=== b\n2\n(no newline at end)\n=== c\n"
+	if actual != expected {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+}
+
+func TestDifference(t *testing.T) {
+	diff := difference([]byte("a\nb\nc"), []byte("a\nx\nc"))
+	if !strings.HasPrefix(diff, "line 2:") || !strings.Contains(diff, `"b"`) || !strings.Contains(diff, `"x"`) {
+		t.Errorf("Unexpected difference: %s", diff)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+
+//go:build ignore
+// +build ignore
+
+// gen writes stdshim_generated.go: the constants, funcs and Logger methods of the
+// standard library log package, with their doc comments, implemented over a standard
+// library Logger. It fails if the standard library package has a name it does not know,
+// so that the shim is updated when the standard library grows.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+const output = "stdshim_generated.go"
+
+// bodies are the implementations of the funcs and methods by name. R stands for the
+// receiver: the Logger for methods and std for package funcs.
+var bodies = map[string]string{
+	"Print":     "if R.isDiscard() {\n\treturn\n}\nR.logger.Output(2, fmt.Sprint(v...))",
+	"Printf":    "if R.isDiscard() {\n\treturn\n}\nR.logger.Output(2, fmt.Sprintf(format, v...))",
+	"Println":   "if R.isDiscard() {\n\treturn\n}\nR.logger.Output(2, fmt.Sprintln(v...))",
+	"Fatal":     "R.logger.Output(2, fmt.Sprint(v...))\nseelog.Flush()\nos.Exit(1)",
+	"Fatalf":    "R.logger.Output(2, fmt.Sprintf(format, v...))\nseelog.Flush()\nos.Exit(1)",
+	"Fatalln":   "R.logger.Output(2, fmt.Sprintln(v...))\nseelog.Flush()\nos.Exit(1)",
+	"Panic":     "s := fmt.Sprint(v...)\nR.logger.Output(2, s)\nseelog.Flush()\npanic(s)",
+	"Panicf":    "s := fmt.Sprintf(format, v...)\nR.logger.Output(2, s)\nseelog.Flush()\npanic(s)",
+	"Panicln":   "s := fmt.Sprintln(v...)\nR.logger.Output(2, s)\nseelog.Flush()\npanic(s)",
+	"Output":    "return R.logger.Output(calldepth+1, s)",
+	"Flags":     "return R.logger.Flags()",
+	"SetFlags":  "R.logger.SetFlags(flag)",
+	"Prefix":    "return R.logger.Prefix()",
+	"SetPrefix": "R.logger.SetPrefix(prefix)",
+	"Writer":    "return R.logger.Writer()",
+	"SetOutput": "R.logger.SetOutput(w)",
+	"New":       "return &Logger{logger: stdlog.New(out, prefix, flag)}",
+	"Default":   "return std",
+}
+
+func main() {
+	if err := generate(); err != nil {
+		fmt.Fprintln(os.Stderr, "gen:", err)
+		os.Exit(1)
+	}
+}
+
+func generate() error {
+	pkg, err := importer.ForCompiler(token.NewFileSet(), "source", nil).Import("log")
+	if err != nil {
+		return err
+	}
+	docs, err := packageDocs("log")
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("// Code generated by gen.go from the standard library log package. DO NOT EDIT.\n\n")
+	buf.WriteString("package stdshim\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\tstdlog \"log\"\n\t\"os\"\n\n\t\"seelog\"\n)\n\n")
+
+	for _, group := range docs.Consts {
+		writeDoc(buf, group.Doc)
+		buf.WriteString("const (\n")
+		for _, spec := range group.Decl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			writeDoc(buf, valueSpec.Doc.Text())
+			for _, name := range valueSpec.Names {
+				fmt.Fprintf(buf, "%s = stdlog.%s", name.Name, name.Name)
+			}
+			if comment := valueSpec.Comment.Text(); comment != "" {
+				buf.WriteString(" // " + strings.TrimSpace(comment))
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(")\n\n")
+	}
+
+	funcDocs := make(map[string]string)
+	for _, function := range docs.Funcs {
+		funcDocs[function.Name] = function.Doc
+	}
+	for _, docType := range docs.Types {
+		if docType.Name != "Logger" {
+			return fmt.Errorf("unknown type %s", docType.Name)
+		}
+		for _, function := range docType.Funcs {
+			funcDocs[function.Name] = function.Doc
+		}
+		for _, method := range docType.Methods {
+			funcDocs["Logger."+method.Name] = method.Doc
+		}
+	}
+
+	names := pkg.Scope().Names()
+	sort.Strings(names)
+	for _, name := range names {
+		object := pkg.Scope().Lookup(name)
+		if !object.Exported() {
+			continue
+		}
+		switch object := object.(type) {
+		case *types.Const:
+			// Written above
+		case *types.TypeName:
+			if name != "Logger" {
+				return fmt.Errorf("unknown type %s", name)
+			}
+			methods := types.NewMethodSet(types.NewPointer(object.Type()))
+			for i := 0; i < methods.Len(); i++ {
+				method := methods.At(i).Obj().(*types.Func)
+				if !method.Exported() {
+					continue
+				}
+				if err := writeFunc(buf, method, "l", funcDocs["Logger."+method.Name()]); err != nil {
+					return err
+				}
+			}
+		case *types.Func:
+			if err := writeFunc(buf, object, "", funcDocs[name]); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown %T %s", object, name)
+		}
+	}
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("%s\n%s", err, buf)
+	}
+	return ioutil.WriteFile(output, source, 0644)
+}
+
+// packageDocs reads the doc comments of a standard library package.
+func packageDocs(path string) (*doc.Package, error) {
+	buildPkg, err := build.Import(path, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	files := make(map[string]*ast.File)
+	for _, name := range buildPkg.GoFiles {
+		file, err := parser.ParseFile(fset, buildPkg.Dir+"/"+name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = file
+	}
+	astPkg := &ast.Package{Name: buildPkg.Name, Files: files}
+	return doc.New(astPkg, path, 0), nil
+}
+
+func writeFunc(buf *bytes.Buffer, function *types.Func, receiver string, docText string) error {
+	body, ok := bodies[function.Name()]
+	if !ok {
+		return fmt.Errorf("unknown func %s", function.Name())
+	}
+	receiverExpr := "std"
+	if receiver != "" {
+		receiverExpr = receiver
+	}
+	body = strings.Replace(body, "R.", receiverExpr+".", -1)
+
+	writeDoc(buf, docText)
+	buf.WriteString("func ")
+	if receiver != "" {
+		buf.WriteString("(" + receiver + " *Logger) ")
+	}
+	signature := function.Type().(*types.Signature)
+	buf.WriteString(function.Name() + "(")
+	params := signature.Params()
+	for i := 0; i < params.Len(); i++ {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		paramType := typeString(params.At(i).Type())
+		if signature.Variadic() && i == params.Len()-1 {
+			paramType = "..." + strings.TrimPrefix(paramType, "[]")
+		}
+		buf.WriteString(params.At(i).Name() + " " + paramType)
+	}
+	buf.WriteString(")")
+	results := signature.Results()
+	if results.Len() == 1 {
+		buf.WriteString(" " + typeString(results.At(0).Type()))
+	} else if results.Len() > 1 {
+		return fmt.Errorf("unexpected results of %s", function.Name())
+	}
+	buf.WriteString(" {\n" + body + "\n}\n\n")
+	return nil
+}
+
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		if pkg.Path() == "log" {
+			return ""
+		}
+		return pkg.Name()
+	})
+}
+
+func writeDoc(buf *bytes.Buffer, text string) {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			buf.WriteString("//\n")
+		} else {
+			buf.WriteString("// " + line + "\n")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+// Package stdshim mirrors the standard library log package, so that code written for it
+// can move to seelog by changing the import path:
+//     import log "seelog/stdshim"
+//
+// The exported names, signatures and behavior are those of the standard library
+// package; loggers created with New write exactly what the standard library loggers
+// would. The only difference is the default output of the standard logger: instead of
+// os.Stderr it is the current seelog logger, which gets every line at the info level
+// with the caller of the log func as its context. The standard flags still apply, so
+// SetFlags(0) leaves the timestamps to the seelog formats. Fatal funcs flush seelog
+// before they exit.
+//
+// The package is generated from the standard library package with "go generate", and
+// its tests check that the exported surface still matches it.
+package stdshim
+
+//go:generate go run gen.go
+
+import (
+	"io"
+	stdlog "log"
+	"runtime"
+	"strings"
+
+	"seelog"
+)
+
+// A Logger represents an active logging object that generates lines of output to an
+// io.Writer, as a standard library Logger does. It can be used simultaneously from
+// multiple goroutines.
+type Logger struct {
+	logger *stdlog.Logger
+}
+
+var std = New(seelogWriter{}, "", LstdFlags)
+
+// seelogWriter passes the lines of the standard logger to the current seelog logger.
+type seelogWriter struct{}
+
+func (seelogWriter) Write(line []byte) (int, error) {
+	seelog.Std().Skip(callerSkip()).Info(strings.TrimSuffix(string(line), "\n"))
+	return len(line), nil
+}
+
+// callerSkip returns the number of frames from the caller of seelogWriter.Write to the
+// first caller outside the log packages, i.e. the caller of the log func.
+func callerSkip() int {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for skip := 1; ; skip++ {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "log.") && !strings.Contains(frame.Function, "/stdshim.") {
+			return skip
+		}
+		if !more {
+			return 0
+		}
+	}
+}
+
+// isDiscard reports whether the logger discards its output, in which case the Print
+// funcs do not format their arguments, as in the standard library.
+func (l *Logger) isDiscard() bool {
+	return l.logger.Writer() == io.Discard
+}
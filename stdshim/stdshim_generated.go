@@ -0,0 +1,280 @@
+// Code generated by gen.go from the standard library log package. DO NOT EDIT.
+
+package stdshim
+
+import (
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+
+	"seelog"
+)
+
+// These flags define which text to prefix to each log entry generated by the [Logger].
+// Bits are or'ed together to control what's printed.
+// With the exception of the Lmsgprefix flag, there is no
+// control over the order they appear (the order listed here)
+// or the format they present (as described in the comments).
+// The prefix is followed by a colon only when Llongfile or Lshortfile
+// is specified.
+// For example, flags Ldate | Ltime (or LstdFlags) produce,
+//
+//	2009/01/23 01:23:23 message
+//
+// while flags Ldate | Ltime | Lmicroseconds | Llongfile produce,
+//
+//	2009/01/23 01:23:23.123123 /a/b/c/d.go:23: message
+const (
+	Ldate         = stdlog.Ldate         // the date in the local time zone: 2009/01/23
+	Ltime         = stdlog.Ltime         // the time in the local time zone: 01:23:23
+	Lmicroseconds = stdlog.Lmicroseconds // microsecond resolution: 01:23:23.123123.  assumes Ltime.
+	Llongfile     = stdlog.Llongfile     // full file name and line number: /a/b/c/d.go:23
+	Lshortfile    = stdlog.Lshortfile    // final file name element and line number: d.go:23. overrides Llongfile
+	LUTC          = stdlog.LUTC          // if Ldate or Ltime is set, use UTC rather than the local time zone
+	Lmsgprefix    = stdlog.Lmsgprefix    // move the "prefix" from the beginning of the line to before the message
+	LstdFlags     = stdlog.LstdFlags     // initial values for the standard logger
+)
+
+// Default returns the standard logger used by the package-level output functions.
+func Default() *Logger {
+	return std
+}
+
+// Fatal is equivalent to [Print] followed by a call to [os.Exit](1).
+func Fatal(v ...any) {
+	std.logger.Output(2, fmt.Sprint(v...))
+	seelog.Flush()
+	os.Exit(1)
+}
+
+// Fatalf is equivalent to [Printf] followed by a call to [os.Exit](1).
+func Fatalf(format string, v ...any) {
+	std.logger.Output(2, fmt.Sprintf(format, v...))
+	seelog.Flush()
+	os.Exit(1)
+}
+
+// Fatalln is equivalent to [Println] followed by a call to [os.Exit](1).
+func Fatalln(v ...any) {
+	std.logger.Output(2, fmt.Sprintln(v...))
+	seelog.Flush()
+	os.Exit(1)
+}
+
+// Flags returns the output flags for the standard logger.
+// The flag bits are [Ldate], [Ltime], and so on.
+func Flags() int {
+	return std.logger.Flags()
+}
+
+// Fatal is equivalent to l.Print() followed by a call to [os.Exit](1).
+func (l *Logger) Fatal(v ...any) {
+	l.logger.Output(2, fmt.Sprint(v...))
+	seelog.Flush()
+	os.Exit(1)
+}
+
+// Fatalf is equivalent to l.Printf() followed by a call to [os.Exit](1).
+func (l *Logger) Fatalf(format string, v ...any) {
+	l.logger.Output(2, fmt.Sprintf(format, v...))
+	seelog.Flush()
+	os.Exit(1)
+}
+
+// Fatalln is equivalent to l.Println() followed by a call to [os.Exit](1).
+func (l *Logger) Fatalln(v ...any) {
+	l.logger.Output(2, fmt.Sprintln(v...))
+	seelog.Flush()
+	os.Exit(1)
+}
+
+// Flags returns the output flags for the logger.
+// The flag bits are [Ldate], [Ltime], and so on.
+func (l *Logger) Flags() int {
+	return l.logger.Flags()
+}
+
+// Output writes the output for a logging event. The string s contains
+// the text to print after the prefix specified by the flags of the
+// Logger. A newline is appended if the last character of s is not
+// already a newline. Calldepth is used to recover the PC and is
+// provided for generality, although at the moment on all pre-defined
+// paths it will be 2.
+func (l *Logger) Output(calldepth int, s string) error {
+	return l.logger.Output(calldepth+1, s)
+}
+
+// Panic is equivalent to l.Print() followed by a call to panic().
+func (l *Logger) Panic(v ...any) {
+	s := fmt.Sprint(v...)
+	l.logger.Output(2, s)
+	seelog.Flush()
+	panic(s)
+}
+
+// Panicf is equivalent to l.Printf() followed by a call to panic().
+func (l *Logger) Panicf(format string, v ...any) {
+	s := fmt.Sprintf(format, v...)
+	l.logger.Output(2, s)
+	seelog.Flush()
+	panic(s)
+}
+
+// Panicln is equivalent to l.Println() followed by a call to panic().
+func (l *Logger) Panicln(v ...any) {
+	s := fmt.Sprintln(v...)
+	l.logger.Output(2, s)
+	seelog.Flush()
+	panic(s)
+}
+
+// Prefix returns the output prefix for the logger.
+func (l *Logger) Prefix() string {
+	return l.logger.Prefix()
+}
+
+// Print calls l.Output to print to the logger.
+// Arguments are handled in the manner of [fmt.Print].
+func (l *Logger) Print(v ...any) {
+	if l.isDiscard() {
+		return
+	}
+	l.logger.Output(2, fmt.Sprint(v...))
+}
+
+// Printf calls l.Output to print to the logger.
+// Arguments are handled in the manner of [fmt.Printf].
+func (l *Logger) Printf(format string, v ...any) {
+	if l.isDiscard() {
+		return
+	}
+	l.logger.Output(2, fmt.Sprintf(format, v...))
+}
+
+// Println calls l.Output to print to the logger.
+// Arguments are handled in the manner of [fmt.Println].
+func (l *Logger) Println(v ...any) {
+	if l.isDiscard() {
+		return
+	}
+	l.logger.Output(2, fmt.Sprintln(v...))
+}
+
+// SetFlags sets the output flags for the logger.
+// The flag bits are [Ldate], [Ltime], and so on.
+func (l *Logger) SetFlags(flag int) {
+	l.logger.SetFlags(flag)
+}
+
+// SetOutput sets the output destination for the logger.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.logger.SetOutput(w)
+}
+
+// SetPrefix sets the output prefix for the logger.
+func (l *Logger) SetPrefix(prefix string) {
+	l.logger.SetPrefix(prefix)
+}
+
+// Writer returns the output destination for the logger.
+func (l *Logger) Writer() io.Writer {
+	return l.logger.Writer()
+}
+
+// New creates a new [Logger]. The out variable sets the
+// destination to which log data will be written.
+// The prefix appears at the beginning of each generated log line, or
+// after the log header if the [Lmsgprefix] flag is provided.
+// The flag argument defines the logging properties.
+func New(out io.Writer, prefix string, flag int) *Logger {
+	return &Logger{logger: stdlog.New(out, prefix, flag)}
+}
+
+// Output writes the output for a logging event. The string s contains
+// the text to print after the prefix specified by the flags of the
+// Logger. A newline is appended if the last character of s is not
+// already a newline. Calldepth is the count of the number of
+// frames to skip when computing the file name and line number
+// if [Llongfile] or [Lshortfile] is set; a value of 1 will print the details
+// for the caller of Output.
+func Output(calldepth int, s string) error {
+	return std.logger.Output(calldepth+1, s)
+}
+
+// Panic is equivalent to [Print] followed by a call to panic().
+func Panic(v ...any) {
+	s := fmt.Sprint(v...)
+	std.logger.Output(2, s)
+	seelog.Flush()
+	panic(s)
+}
+
+// Panicf is equivalent to [Printf] followed by a call to panic().
+func Panicf(format string, v ...any) {
+	s := fmt.Sprintf(format, v...)
+	std.logger.Output(2, s)
+	seelog.Flush()
+	panic(s)
+}
+
+// Panicln is equivalent to [Println] followed by a call to panic().
+func Panicln(v ...any) {
+	s := fmt.Sprintln(v...)
+	std.logger.Output(2, s)
+	seelog.Flush()
+	panic(s)
+}
+
+// Prefix returns the output prefix for the standard logger.
+func Prefix() string {
+	return std.logger.Prefix()
+}
+
+// Print calls Output to print to the standard logger.
+// Arguments are handled in the manner of [fmt.Print].
+func Print(v ...any) {
+	if std.isDiscard() {
+		return
+	}
+	std.logger.Output(2, fmt.Sprint(v...))
+}
+
+// Printf calls Output to print to the standard logger.
+// Arguments are handled in the manner of [fmt.Printf].
+func Printf(format string, v ...any) {
+	if std.isDiscard() {
+		return
+	}
+	std.logger.Output(2, fmt.Sprintf(format, v...))
+}
+
+// Println calls Output to print to the standard logger.
+// Arguments are handled in the manner of [fmt.Println].
+func Println(v ...any) {
+	if std.isDiscard() {
+		return
+	}
+	std.logger.Output(2, fmt.Sprintln(v...))
+}
+
+// SetFlags sets the output flags for the standard logger.
+// The flag bits are [Ldate], [Ltime], and so on.
+func SetFlags(flag int) {
+	std.logger.SetFlags(flag)
+}
+
+// SetOutput sets the output destination for the standard logger.
+func SetOutput(w io.Writer) {
+	std.logger.SetOutput(w)
+}
+
+// SetPrefix sets the output prefix for the standard logger.
+func SetPrefix(prefix string) {
+	std.logger.SetPrefix(prefix)
+}
+
+// Writer returns the output destination for the standard logger.
+func Writer() io.Writer {
+	return std.logger.Writer()
+}
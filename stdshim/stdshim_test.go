@@ -0,0 +1,222 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package stdshim_test
+
+import (
+	"bytes"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	stdlog "log"
+	"path/filepath"
+	"testing"
+
+	"seelog"
+	log "seelog/stdshim"
+)
+
+// TestSurface checks that the exported names and signatures are those of the standard
+// library package. If it fails after a Go upgrade, run "go generate".
+func TestSurface(t *testing.T) {
+	sources := importer.ForCompiler(token.NewFileSet(), "source", nil)
+	std, err := sources.Import("log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shim, err := sources.Import("seelog/stdshim")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdSurface, shimSurface := surface(std), surface(shim)
+	for name, signature := range stdSurface {
+		if shimSurface[name] != signature {
+			t.Errorf("%s: expected %q, got %q", name, signature, shimSurface[name])
+		}
+	}
+	for name := range shimSurface {
+		if _, ok := stdSurface[name]; !ok {
+			t.Errorf("%s is not in the standard library package", name)
+		}
+	}
+}
+
+// surface returns the exported names of a package with their types, and the methods
+// of its exported types.
+func surface(pkg *types.Package) map[string]string {
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Path()
+	}
+
+	result := make(map[string]string)
+	for _, name := range pkg.Scope().Names() {
+		object := pkg.Scope().Lookup(name)
+		if !object.Exported() {
+			continue
+		}
+		switch object := object.(type) {
+		case *types.Const:
+			result[name] = "const " + types.TypeString(object.Type(), qualifier) + " = " + object.Val().String()
+		case *types.TypeName:
+			result[name] = "type"
+			methods := types.NewMethodSet(types.NewPointer(object.Type()))
+			for i := 0; i < methods.Len(); i++ {
+				method := methods.At(i).Obj()
+				if method.Exported() {
+					result[name+"."+method.Name()] = types.TypeString(method.Type(), qualifier)
+				}
+			}
+		default:
+			result[name] = fmt.Sprintf("%T %s", object, types.TypeString(object.Type(), qualifier))
+		}
+	}
+	return result
+}
+
+// logger is the method set which the standard library and the shim loggers share.
+type logger interface {
+	Print(v ...any)
+	Printf(format string, v ...any)
+	Println(v ...any)
+	Output(calldepth int, s string) error
+	SetFlags(flag int)
+	SetPrefix(prefix string)
+	Flags() int
+	Prefix() string
+	Writer() io.Writer
+}
+
+type stringer struct{ called *bool }
+
+func (s stringer) String() string {
+	*s.called = true
+	return "stringer"
+}
+
+func TestCompatibility(t *testing.T) {
+	scenarios := map[string]func(l logger){
+		"print": func(l logger) {
+			l.Print("a", 1, 2, "b")
+			l.Printf("%d-%s", 3, "c")
+			l.Println("a", 1, 2, "b")
+			l.Print("ends with newline\n")
+			l.Print()
+		},
+		"prefix": func(l logger) {
+			l.SetPrefix("app: ")
+			l.Print("one")
+			l.SetFlags(log.Lmsgprefix)
+			l.Print("two")
+			l.Print(l.Prefix(), l.Flags())
+		},
+		"caller": func(l logger) {
+			l.SetFlags(log.Lshortfile)
+			l.Print("short")
+			l.SetFlags(log.Llongfile | log.Lmsgprefix)
+			l.SetPrefix("> ")
+			l.Output(1, "long")
+		},
+		"multiline": func(l logger) {
+			l.Print("line one\nline two")
+			l.Println()
+		},
+	}
+
+	for name, scenario := range scenarios {
+		stdOutput, shimOutput := new(bytes.Buffer), new(bytes.Buffer)
+		scenario(stdlog.New(stdOutput, "", 0))
+		scenario(log.New(shimOutput, "", 0))
+		if shimOutput.String() != stdOutput.String() {
+			t.Errorf("%s: expected %q, got %q", name, stdOutput.String(), shimOutput.String())
+		}
+	}
+}
+
+func TestLoggerState(t *testing.T) {
+	output := new(bytes.Buffer)
+	l := log.New(output, "p ", log.LstdFlags|log.LUTC)
+	if l.Writer() != output || l.Prefix() != "p " || l.Flags() != log.LstdFlags|log.LUTC {
+		t.Errorf("Unexpected logger state: %v %q %d", l.Writer(), l.Prefix(), l.Flags())
+	}
+
+	called := false
+	l.SetOutput(io.Discard)
+	l.Print(stringer{&called})
+	if called {
+		t.Error("Expected a discarding logger not to format its arguments")
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != "panic 1\n" {
+			t.Errorf("Unexpected panic value: %#v", recovered)
+		}
+	}()
+	l.Panicln("panic", 1)
+}
+
+func TestDefaultLogger(t *testing.T) {
+	if log.Default() != log.Default() || log.Flags() != log.LstdFlags || log.Prefix() != "" {
+		t.Fatalf("Unexpected default logger state: %d %q", log.Flags(), log.Prefix())
+	}
+
+	path := filepath.Join(t.TempDir(), "std.log")
+	seelogLogger, err := seelog.NewConfig().Sync().Format("[%Level] %File: %Msg%n").AddFile(path).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := seelog.CurrentLogger()
+	seelog.UseLogger(seelogLogger)
+	defer seelog.UseLogger(previous)
+
+	flags := log.Flags()
+	log.SetFlags(0)
+	defer log.SetFlags(flags)
+	log.Printf("hello %s", "seelog")
+	log.Println("hello again")
+	seelogLogger.Close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[Info] stdshim_test.go: hello seelog\n[Info] stdshim_test.go: hello again\n"
+	if string(content) != expected {
+		t.Errorf("Expected %q, got %q", expected, content)
+	}
+
+	writer := log.Writer()
+	output := new(bytes.Buffer)
+	log.SetOutput(output)
+	defer log.SetOutput(writer)
+	if log.Writer() != output {
+		t.Error("Expected SetOutput to change the writer")
+	}
+}
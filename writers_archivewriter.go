@@ -0,0 +1,351 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultArchiveMaxSize     = 16 << 20
+	defaultArchiveMaxInterval = 5 * time.Minute
+	defaultArchiveBufferSize  = 1 << 30
+	defaultArchiveKey         = "%Field(service)/%UTCDate(2006/01/02)/%UTCDate(15)/%Field(host)-%Field(pid)-%UTCDate(150405.000)-%Field(seq).log.gz"
+	archiveActiveFile         = "active.log"
+	archiveChunkPrefix        = "chunk-"
+	archiveChunkExt           = ".gz"
+)
+
+// archiveUploader uploads the compressed chunks of an archiveWriter.
+type archiveUploader interface {
+	upload(key string, data []byte) error
+}
+
+// archiveWriter archives messages in object storage. Messages are appended to a chunk
+// file in dir, so that they survive a crash of the process. When the chunk reaches
+// maxSize bytes or maxInterval after its first message, and on Flush and Close, it is
+// compressed with gzip into a file of its own and uploaded. Its key is the key format
+// applied to a record with the time of the first message of the chunk and the fields
+// service, host, pid and seq (the number of the chunk in its process).
+//
+// Chunks which fail to upload stay in dir and are uploaded with the next chunk, also by
+// the next process using dir; when they take more than bufferSize bytes the oldest are
+// deleted.
+type archiveWriter struct {
+	uploader    archiveUploader
+	key         *formatter
+	dir         string
+	service     string
+	host        string
+	maxSize     int64
+	maxInterval time.Duration
+	bufferSize  int64
+
+	mutex   sync.Mutex
+	active  *os.File
+	size    int64
+	started time.Time
+	timer   *time.Timer
+	seq     int
+	closed  bool
+}
+
+// newArchiveWriter creates an archive writer. Zero sizes, interval and an empty key or
+// service are replaced with the defaults. A chunk left in dir by a previous process is
+// compressed to be uploaded with the next one.
+func newArchiveWriter(uploader archiveUploader, dir, key, service string, maxSize int64,
+	maxInterval time.Duration, bufferSize int64) (*archiveWriter, error) {
+	if dir == "" {
+		return nil, errors.New("Archive directory can not be empty")
+	}
+	if key == "" {
+		key = defaultArchiveKey
+	}
+	keyFormatter, err := newFormatter(key)
+	if err != nil {
+		return nil, err
+	}
+	if service == "" {
+		service = filepath.Base(os.Args[0])
+	}
+	if maxSize == 0 {
+		maxSize = defaultArchiveMaxSize
+	}
+	if maxInterval == 0 {
+		maxInterval = defaultArchiveMaxInterval
+	}
+	if bufferSize == 0 {
+		bufferSize = defaultArchiveBufferSize
+	}
+	if maxSize < 0 || maxInterval < 0 || bufferSize < 0 {
+		return nil, errors.New("Archive sizes and interval can not be negative")
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	if err := os.MkdirAll(dir, defaultDirectoryPermissions); err != nil {
+		return nil, err
+	}
+
+	writer := &archiveWriter{
+		uploader:    uploader,
+		key:         keyFormatter,
+		dir:         dir,
+		service:     service,
+		host:        host,
+		maxSize:     maxSize,
+		maxInterval: maxInterval,
+		bufferSize:  bufferSize,
+	}
+
+	activePath := filepath.Join(dir, archiveActiveFile)
+	if info, err := os.Stat(activePath); err == nil {
+		writer.size = info.Size()
+		writer.started = info.ModTime()
+		if err := writer.seal(); err != nil {
+			return nil, err
+		}
+	}
+	return writer, nil
+}
+
+func (writer *archiveWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return 0, errors.New("Archive writer is closed")
+	}
+
+	if writer.active == nil {
+		file, err := os.OpenFile(filepath.Join(writer.dir, archiveActiveFile),
+			os.O_WRONLY|os.O_CREATE|os.O_APPEND, defaultFilePermissions)
+		if err != nil {
+			return 0, err
+		}
+		writer.active = file
+		writer.size = 0
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.maxInterval, writer.archiveOnTimer)
+	}
+
+	n, err := writer.active.Write(data)
+	writer.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if writer.size >= writer.maxSize {
+		err = writer.archive()
+	}
+	return n, err
+}
+
+func (writer *archiveWriter) archiveOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.active != nil && time.Since(writer.started) >= writer.maxInterval {
+		if err := writer.archive(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// archive seals the current chunk and uploads all sealed chunks.
+func (writer *archiveWriter) archive() error {
+	if err := writer.seal(); err != nil {
+		return err
+	}
+	return writer.upload()
+}
+
+// seal compresses the current chunk into a chunk file named after the time of its first
+// message, the pid and its number, and starts a new chunk.
+func (writer *archiveWriter) seal() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	if writer.active != nil {
+		writer.active.Close()
+		writer.active = nil
+	}
+	activePath := filepath.Join(writer.dir, archiveActiveFile)
+	if writer.size == 0 {
+		os.Remove(activePath)
+		return nil
+	}
+	writer.size = 0
+
+	writer.seq++
+	name := fmt.Sprintf("%s%019d-%d-%d%s", archiveChunkPrefix, writer.started.UnixNano(), os.Getpid(), writer.seq, archiveChunkExt)
+	chunkPath := filepath.Join(writer.dir, name)
+	if err := gzipFile(activePath, chunkPath+".tmp"); err != nil {
+		os.Remove(chunkPath + ".tmp")
+		return fmt.Errorf("Cannot compress archive chunk: %s", err)
+	}
+	if err := os.Rename(chunkPath+".tmp", chunkPath); err != nil {
+		return err
+	}
+	return os.Remove(activePath)
+}
+
+// upload uploads the sealed chunks, oldest first, and removes those which succeed. It
+// stops at the first failure, after deleting the oldest chunks beyond bufferSize.
+func (writer *archiveWriter) upload() error {
+	chunks, err := writer.chunks()
+	if err != nil {
+		return err
+	}
+
+	for len(chunks) > 0 {
+		name := chunks[0].Name()
+		data, err := ioutil.ReadFile(filepath.Join(writer.dir, name))
+		if err == nil {
+			err = writer.uploader.upload(writer.chunkKey(name), data)
+		}
+		if err != nil {
+			return writer.trim(chunks, fmt.Errorf("Cannot archive %s: %s", name, err))
+		}
+		os.Remove(filepath.Join(writer.dir, name))
+		chunks = chunks[1:]
+	}
+	return nil
+}
+
+// trim deletes the oldest chunks while all of them take more than bufferSize bytes.
+func (writer *archiveWriter) trim(chunks []os.FileInfo, err error) error {
+	var size int64
+	for _, chunk := range chunks {
+		size += chunk.Size()
+	}
+	deleted := 0
+	for ; size > writer.bufferSize && len(chunks) > 0; chunks = chunks[1:] {
+		if os.Remove(filepath.Join(writer.dir, chunks[0].Name())) == nil {
+			deleted++
+		}
+		size -= chunks[0].Size()
+	}
+	if deleted > 0 {
+		return fmt.Errorf("%s; %d chunks deleted beyond the buffer size", err, deleted)
+	}
+	return err
+}
+
+// chunks returns the sealed chunks, oldest first.
+func (writer *archiveWriter) chunks() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(writer.dir)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []os.FileInfo
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), archiveChunkPrefix) && strings.HasSuffix(info.Name(), archiveChunkExt) {
+			chunks = append(chunks, info)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Name() < chunks[j].Name() })
+	return chunks, nil
+}
+
+// chunkKey returns the key of a chunk file.
+func (writer *archiveWriter) chunkKey(name string) string {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, archiveChunkPrefix), archiveChunkExt), "-")
+	started := time.Now()
+	pid, seq := "", ""
+	if len(parts) == 3 {
+		if nanos, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
+			started = time.Unix(0, nanos)
+		}
+		pid, seq = parts[1], parts[2]
+	}
+	context := &logContext{callTime: started, fields: []Field{
+		Str("service", writer.service), Str("host", writer.host), Str("pid", pid), Str("seq", seq)}}
+	return strings.TrimSpace(writer.key.formatOwn("", InfoLvl, context))
+}
+
+// gzipFile compresses the file at source into a new file at target.
+func gzipFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, defaultFilePermissions)
+	if err != nil {
+		return err
+	}
+	compressor := gzip.NewWriter(out)
+	_, err = io.Copy(compressor, in)
+	if closeErr := compressor.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (writer *archiveWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.archive(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *archiveWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.archive()
+}
+
+func (writer *archiveWriter) String() string {
+	return fmt.Sprintf("archiveWriter: [%v, %s, %s, maxSize: %d, maxInterval: %s]",
+		writer.uploader, writer.dir, writer.key, writer.maxSize, writer.maxInterval)
+}
+
+// upload puts an archive chunk into the bucket.
+func (store *s3Bucket) upload(key string, data []byte) error {
+	return store.put(key, data, "application/gzip", time.Now())
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// archiveTestUploader keeps uploaded chunks decompressed, or fails while failing is set.
+type archiveTestUploader struct {
+	mutex   sync.Mutex
+	failing bool
+	keys    []string
+	chunks  []string
+}
+
+func (uploader *archiveTestUploader) upload(key string, data []byte) error {
+	uploader.mutex.Lock()
+	defer uploader.mutex.Unlock()
+
+	if uploader.failing {
+		return errors.New("unavailable")
+	}
+	text, err := gunzipMessage(data)
+	if err != nil {
+		return err
+	}
+	uploader.keys = append(uploader.keys, key)
+	uploader.chunks = append(uploader.chunks, text)
+	return nil
+}
+
+func archiveTestChunkFiles(t *testing.T, dir string) []string {
+	names, err := filepath.Glob(filepath.Join(dir, archiveChunkPrefix+"*"+archiveChunkExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestArchiveMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	uploader := &archiveTestUploader{}
+	writer, err := newArchiveWriter(uploader, dir, "", "app", 10, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer.Write([]byte("first\n"))
+	if len(uploader.chunks) != 0 {
+		t.Fatalf("Expected no chunk below the max size, got %q", uploader.chunks)
+	}
+	writer.Write([]byte("second\n"))
+	writer.Write([]byte("third\n"))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(uploader.chunks, "|") != "first\nsecond\n|third\n" {
+		t.Errorf("Unexpected chunks: %q", uploader.chunks)
+	}
+	host, _ := os.Hostname()
+	key := regexp.MustCompile(`^app/\d{4}/\d{2}/\d{2}/\d{2}/` + regexp.QuoteMeta(host) + `-\d+-\d{6}\.\d{3}-1\.log\.gz$`)
+	if !key.MatchString(uploader.keys[0]) || !strings.HasSuffix(uploader.keys[1], "-2.log.gz") {
+		t.Errorf("Unexpected keys: %v", uploader.keys)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected uploaded chunks to be removed, got %d files", len(files))
+	}
+}
+
+func TestArchiveMaxInterval(t *testing.T) {
+	uploader := &archiveTestUploader{}
+	writer, err := newArchiveWriter(uploader, t.TempDir(), "%Field(service)-%Field(seq)", "app", 0, 30*time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	writer.Write([]byte("late\n"))
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		uploader.mutex.Lock()
+		keys := uploader.keys
+		uploader.mutex.Unlock()
+		if len(keys) == 1 {
+			if keys[0] != "app-1" {
+				t.Errorf("Unexpected key: %s", keys[0])
+			}
+			return
+		}
+	}
+	t.Error("Chunk was not uploaded after maxinterval")
+}
+
+func TestArchiveRetry(t *testing.T) {
+	dir := t.TempDir()
+	uploader := &archiveTestUploader{failing: true}
+	writer, err := newArchiveWriter(uploader, dir, "", "app", 0, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte("first\n"))
+	if err := writer.archive(); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("Expected the failed upload to be reported, got %v", err)
+	}
+	if chunks := archiveTestChunkFiles(t, dir); len(chunks) != 1 {
+		t.Fatalf("Expected the chunk to be kept, got %v", chunks)
+	}
+
+	// A crash leaves the current chunk behind for the next process
+	writer.Write([]byte("second\n"))
+	writer.timer.Stop()
+	writer.active.Close()
+
+	uploader.failing = false
+	restarted, err := newArchiveWriter(uploader, dir, "", "app", 0, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Write([]byte("third\n"))
+	if err := restarted.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(uploader.chunks, "|") != "first\n|second\n|third\n" {
+		t.Errorf("Expected the kept chunks to be uploaded in order, got %q", uploader.chunks)
+	}
+}
+
+func TestArchiveBufferSize(t *testing.T) {
+	dir := t.TempDir()
+	uploader := &archiveTestUploader{failing: true}
+	writer, err := newArchiveWriter(uploader, dir, "", "app", 0, time.Hour, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte("first\n"))
+	writer.archive()
+	writer.Write([]byte("second\n"))
+	err = writer.archive()
+	if err == nil || !strings.Contains(err.Error(), "1 chunks deleted") {
+		t.Errorf("Expected the oldest chunk to be deleted, got %v", err)
+	}
+
+	uploader.failing = false
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(uploader.chunks, "|") != "second\n" {
+		t.Errorf("Unexpected chunks: %q", uploader.chunks)
+	}
+}
+
+func TestS3ArchiveConfig(t *testing.T) {
+	server := newObjectStoreTestServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<s3archive endpoint="`+server.URL+`" bucket="archive" dir="`+dir+`"
+		service="billing" accesskey="a" secretkey="b"/>`})
+	logger.Info("one")
+	logger.Info("two")
+	logger.Close()
+
+	objects, auth := server.contents()
+	if len(objects) != 1 || !strings.HasPrefix(auth[0], "AWS4-HMAC-SHA256 ") {
+		t.Fatalf("Expected the chunk to be uploaded on close, got %v", objects)
+	}
+	for path, body := range objects {
+		text, err := gunzipMessage([]byte(body))
+		if !strings.HasPrefix(path, "/archive/billing/") || err != nil || text != "one\ntwo\n" {
+			t.Errorf("Unexpected object %s: %q, %v", path, text, err)
+		}
+	}
+
+	invalid := []string{
+		`<s3archive bucket="archive" dir="` + dir + `"/>`,
+		`<s3archive endpoint="http://host" bucket="archive"/>`,
+		`<s3archive endpoint="http://host" bucket="archive" dir="` + dir + `" accesskey="a"/>`,
+		`<s3archive endpoint="http://host" bucket="archive" dir="` + dir + `" maxsize="big"/>`,
+		`<s3archive endpoint="http://host" bucket="archive" dir="` + dir + `" key="%Unknown"/>`,
+		`<s3archive endpoint="http://host" bucket="archive" dir="` + dir + `" prefix="app/"/>`,
+	}
+	for _, s3archive := range invalid {
+		config := `<seelog><outputs>` + s3archive + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAzureBlobName        = "%Field(service)/%UTCDate(2006/01/02)/%Field(host)-%Field(pid).log"
+	defaultAzureBlobMaxInterval = 10 * time.Second
+	azureMaxAppendBlock         = 4 << 20
+	azureStorageVersion         = "2021-08-06"
+	azureStorageResource        = "https://storage.azure.com/"
+	azureBlobExists             = "BlobAlreadyExists"
+	azureBlobNotFound           = "BlobNotFound"
+	azureBlockCountExceeded     = "BlockCountExceedsLimit"
+)
+
+// azureBlobWriter appends messages to append blobs in a container of Azure Blob Storage.
+// Messages are collected in memory and appended as a block when they reach maxSize bytes
+// (at most 4MiB), maxInterval after the first message of the block, on Flush and on
+// Close. The blob is the name format applied to a record with the time of the first
+// message of the block and the fields service, host and pid, so that a name with a date
+// starts a new blob every day; the blob is created when it is first appended to. When a
+// blob reaches the limit of 50000 blocks, the writer continues in name.1, name.2 and so on.
+//
+// Requests are authorized with a SAS token or, without one, with a managed identity.
+type azureBlobWriter struct {
+	container   *url.URL
+	sas         url.Values
+	identity    *azureManagedIdentity
+	name        *formatter
+	service     string
+	host        string
+	maxSize     int
+	maxInterval time.Duration
+	client      *http.Client
+
+	mutex   sync.Mutex
+	buffer  bytes.Buffer
+	started time.Time
+	timer   *time.Timer
+	blob    string
+	part    int
+	closed  bool
+}
+
+// newAzureBlobWriter creates a writer appending to blobs of the container at
+// containerURL, which may carry the SAS token instead of sas. An empty name or service and zero maxSize or maxInterval are replaced
+// with the defaults.
+func newAzureBlobWriter(containerURL, sas string, identity *azureManagedIdentity, name, service string,
+	maxSize int, maxInterval time.Duration) (*azureBlobWriter, error) {
+	container, err := url.Parse(containerURL)
+	if err != nil {
+		return nil, err
+	}
+	if container.Scheme != "http" && container.Scheme != "https" || container.Host == "" ||
+		strings.Trim(container.Path, "/") == "" {
+		return nil, errors.New("Azure container must be an http or https url with a container name: " + containerURL)
+	}
+	if sas == "" {
+		sas = container.RawQuery
+	}
+	container.RawQuery = ""
+	sasValues, err := url.ParseQuery(strings.TrimPrefix(sas, "?"))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse the SAS token: %s", err)
+	}
+	if len(sasValues) == 0 && identity == nil {
+		return nil, errors.New("Azure blob writer needs a SAS token or a managed identity")
+	}
+	if name == "" {
+		name = defaultAzureBlobName
+	}
+	nameFormatter, err := newFormatter(name)
+	if err != nil {
+		return nil, err
+	}
+	if service == "" {
+		service = filepath.Base(os.Args[0])
+	}
+	if maxSize == 0 {
+		maxSize = azureMaxAppendBlock
+	}
+	if maxSize < 0 || maxSize > azureMaxAppendBlock {
+		return nil, fmt.Errorf("maxSize must be between 1 and %d. Got: %d", azureMaxAppendBlock, maxSize)
+	}
+	if maxInterval == 0 {
+		maxInterval = defaultAzureBlobMaxInterval
+	}
+	if maxInterval < 0 {
+		return nil, fmt.Errorf("maxInterval can not be negative. Got: %s", maxInterval)
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	container.Path = strings.TrimRight(container.Path, "/")
+
+	return &azureBlobWriter{
+		container:   container,
+		sas:         sasValues,
+		identity:    identity,
+		name:        nameFormatter,
+		service:     service,
+		host:        host,
+		maxSize:     maxSize,
+		maxInterval: maxInterval,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (writer *azureBlobWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return 0, errors.New("Azure blob writer is closed")
+	}
+
+	var err error
+	if writer.buffer.Len() > 0 && writer.buffer.Len()+len(data) > writer.maxSize {
+		err = writer.appendBuffer()
+	}
+	if writer.buffer.Len() == 0 {
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.maxInterval, writer.appendOnTimer)
+	}
+	writer.buffer.Write(data)
+	if writer.buffer.Len() >= writer.maxSize {
+		if appendErr := writer.appendBuffer(); err == nil {
+			err = appendErr
+		}
+	}
+
+	return len(data), err
+}
+
+func (writer *azureBlobWriter) appendOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.buffer.Len() > 0 && time.Since(writer.started) >= writer.maxInterval {
+		if err := writer.appendBuffer(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// appendBuffer appends the collected messages to the blob, in blocks of at most 4MiB as a
+// message may be larger than maxSize. The messages are dropped if it fails.
+func (writer *azureBlobWriter) appendBuffer() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	if writer.buffer.Len() == 0 {
+		return nil
+	}
+	defer writer.buffer.Reset()
+
+	context := &logContext{callTime: writer.started, fields: []Field{
+		Str("service", writer.service), Str("host", writer.host), Str("pid", strconv.Itoa(os.Getpid()))}}
+	name := strings.TrimSpace(writer.name.formatOwn("", InfoLvl, context))
+	if name != writer.blob {
+		writer.blob = name
+		writer.part = 0
+	}
+
+	data := writer.buffer.Bytes()
+	for len(data) > 0 {
+		block := data
+		if len(block) > azureMaxAppendBlock {
+			block = block[:azureMaxAppendBlock]
+		}
+		if err := writer.appendBlock(block); err != nil {
+			return fmt.Errorf("Cannot append %d bytes of messages to %s: %s", len(data), writer.blobName(), err)
+		}
+		data = data[len(block):]
+	}
+	return nil
+}
+
+// appendBlock appends a block to the blob, creating the blob if it does not exist and
+// moving to the next part if it is full.
+func (writer *azureBlobWriter) appendBlock(block []byte) error {
+	code, err := writer.request(writer.blobName(), url.Values{"comp": {"appendblock"}}, nil, block)
+	switch code {
+	case azureBlobNotFound:
+	case azureBlockCountExceeded:
+		writer.part++
+	default:
+		return err
+	}
+	if _, err := writer.request(writer.blobName(), nil, http.Header{
+		"X-Ms-Blob-Type":         {"AppendBlob"},
+		"X-Ms-Blob-Content-Type": {"text/plain; charset=utf-8"},
+		"If-None-Match":          {"*"},
+	}, nil); err != nil {
+		return err
+	}
+	_, err = writer.request(writer.blobName(), url.Values{"comp": {"appendblock"}}, nil, block)
+	return err
+}
+
+// blobName returns the name of the current blob with its part number.
+func (writer *azureBlobWriter) blobName() string {
+	if writer.part == 0 {
+		return writer.blob
+	}
+	return writer.blob + "." + strconv.Itoa(writer.part)
+}
+
+// request puts to a blob and returns the x-ms-error-code of a failure. A blob which
+// already exists is not a failure.
+func (writer *azureBlobWriter) request(blob string, query url.Values, header http.Header, body []byte) (string, error) {
+	blobURL := *writer.container
+	blobURL.Path = writer.container.Path + "/" + blob
+	blobURL.RawPath = ""
+	values := url.Values{}
+	for key, value := range writer.sas {
+		values[key] = value
+	}
+	for key, value := range query {
+		values[key] = value
+	}
+	blobURL.RawQuery = values.Encode()
+
+	request, err := http.NewRequest(http.MethodPut, blobURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	for key, value := range header {
+		request.Header[key] = value
+	}
+	request.Header.Set("X-Ms-Version", azureStorageVersion)
+	request.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	if len(writer.sas) == 0 {
+		token, err := writer.identity.accessToken()
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := writer.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	code := response.Header.Get("X-Ms-Error-Code")
+	exists := code == azureBlobExists ||
+		response.StatusCode == http.StatusPreconditionFailed && request.Header.Get("If-None-Match") == "*"
+	if response.StatusCode/100 == 2 || exists {
+		io.Copy(ioutil.Discard, response.Body)
+		return "", nil
+	}
+	body, _ = ioutil.ReadAll(io.LimitReader(response.Body, 512))
+	return code, fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(body)))
+}
+
+func (writer *azureBlobWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.appendBuffer(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *azureBlobWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.appendBuffer()
+}
+
+func (writer *azureBlobWriter) String() string {
+	auth := "SAS"
+	if len(writer.sas) == 0 {
+		auth = writer.identity.String()
+	}
+	return fmt.Sprintf("azureBlobWriter: %s/%s, %s, maxSize: %d, maxInterval: %s",
+		writer.container, writer.name, auth, writer.maxSize, writer.maxInterval)
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// azureTestServer is a container of append blobs which accepts blockLimit blocks per
+// blob, and a managed identity endpoint at /identity.
+type azureTestServer struct {
+	*httptest.Server
+	blockLimit int
+
+	mutex    sync.Mutex
+	blobs    map[string][]string
+	auth     []string
+	tokens   int
+	identity http.Header
+}
+
+func newAzureTestServer(blockLimit int) *azureTestServer {
+	server := &azureTestServer{blockLimit: blockLimit, blobs: make(map[string][]string)}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+func (server *azureTestServer) handle(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if r.URL.Path == "/identity" {
+		server.tokens++
+		server.identity = r.Header
+		w.Write([]byte(`{"access_token": "token-` + r.URL.Query().Get("client_id") + `", "expires_in": "3599",
+			"resource": "` + r.URL.Query().Get("resource") + `"}`))
+		return
+	}
+
+	if r.Method != http.MethodPut || r.Header.Get("X-Ms-Version") == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	if sig := r.URL.Query().Get("sig"); sig != "" {
+		auth = "sig=" + sig
+	}
+	server.auth = append(server.auth, auth)
+
+	blob := strings.TrimPrefix(r.URL.Path, "/container/")
+	blocks, exists := server.blobs[blob]
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.URL.Query().Get("comp") == "appendblock" && !exists:
+		w.Header().Set("X-Ms-Error-Code", azureBlobNotFound)
+		w.WriteHeader(http.StatusNotFound)
+	case r.URL.Query().Get("comp") == "appendblock" && len(blocks) >= server.blockLimit:
+		w.Header().Set("X-Ms-Error-Code", azureBlockCountExceeded)
+		w.WriteHeader(http.StatusConflict)
+	case r.URL.Query().Get("comp") == "appendblock":
+		server.blobs[blob] = append(blocks, string(body))
+		w.WriteHeader(http.StatusCreated)
+	case r.Header.Get("X-Ms-Blob-Type") != "AppendBlob" || r.Header.Get("If-None-Match") != "*":
+		w.WriteHeader(http.StatusBadRequest)
+	case exists:
+		w.Header().Set("X-Ms-Error-Code", azureBlobExists)
+		w.WriteHeader(http.StatusConflict)
+	default:
+		server.blobs[blob] = []string{}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func TestAzureBlobAppend(t *testing.T) {
+	server := newAzureTestServer(2)
+	defer server.Close()
+
+	writer, err := newAzureBlobWriter(server.URL+"/container?sv=2021-08-06&sig=secret", "", nil,
+		"%Field(service).log", "billing", 8, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		if _, err := writer.Write([]byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if blocks := strings.Join(server.blobs["billing.log"], "|"); blocks != "one\ntwo\n|three\n" {
+		t.Errorf("Unexpected blocks of the blob: %q", blocks)
+	}
+	if blocks := strings.Join(server.blobs["billing.log.1"], "|"); blocks != "four\n" {
+		t.Errorf("Unexpected blocks of the next part: %q", blocks)
+	}
+	for _, auth := range server.auth {
+		if auth != "sig=secret" {
+			t.Fatalf("Unexpected authorization: %q", auth)
+		}
+	}
+}
+
+func TestAzureBlobManagedIdentity(t *testing.T) {
+	server := newAzureTestServer(100)
+	defer server.Close()
+	t.Setenv("IDENTITY_ENDPOINT", server.URL+"/identity")
+	t.Setenv("IDENTITY_HEADER", "header-secret")
+
+	server.blobs["app.log"] = []string{"old\n"}
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<azureblob endpoint="`+server.URL+`" container="container" blob="app.log"
+		clientid="client"/>`})
+	logger.Info("one")
+	logger.Flush()
+	logger.Info("two")
+	logger.Close()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if blocks := strings.Join(server.blobs["app.log"], "|"); blocks != "old\n|one\n|two\n" {
+		t.Errorf("Unexpected blocks: %q", blocks)
+	}
+	if server.tokens != 1 || server.identity.Get("X-Identity-Header") != "header-secret" {
+		t.Errorf("Unexpected token requests: %d, %v", server.tokens, server.identity)
+	}
+	for _, auth := range server.auth {
+		if auth != "Bearer token-client" {
+			t.Fatalf("Unexpected authorization: %q", auth)
+		}
+	}
+}
+
+func TestAzureBlobConfig(t *testing.T) {
+	invalid := []string{
+		`<azureblob account="logs"/>`,
+		`<azureblob container="app" sas="sig=x"/>`,
+		`<azureblob account="logs" endpoint="http://127.0.0.1:10000/devstoreaccount1" container="app"/>`,
+		`<azureblob endpoint="storage.local" container="app" sas="sig=x"/>`,
+		`<azureblob account="logs" container="app" sas="sig=x" maxsize="8388608"/>`,
+		`<azureblob account="logs" container="app" sas="sig=x" blob="%Unknown"/>`,
+		`<azureblob account="logs" container="app" sas="env://SEELOG_TEST_MISSING_SAS"/>`,
+		`<azureblob account="logs" container="app" bucket="archive"/>`,
+	}
+	for _, azureblob := range invalid {
+		config := `<seelog><outputs>` + azureblob + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
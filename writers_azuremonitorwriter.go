@@ -0,0 +1,188 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAzureMonitorLogType = "Seelog"
+	defaultAzureMonitorMaxSize = 25 << 20
+	azureMonitorMaxSize        = 30 << 20
+	azureMonitorApiVersion     = "2016-04-01"
+	azureMonitorResource       = "/api/logs"
+	azureMonitorTimeKey        = "Time"
+	azureMonitorLevelKey       = "Level"
+	azureMonitorMessageKey     = "Message"
+)
+
+var azureMonitorLogTypeRegexp = regexp.MustCompile(`^[A-Za-z0-9_]{1,100}$`)
+
+// azureMonitorWriter sends messages to an Azure Monitor Log Analytics workspace with the
+// HTTP Data Collector API, as records of a custom log type (which Log Analytics stores in
+// the table logType_CL). Every record has the call time as Time, which is its
+// TimeGenerated, the level as Level, the formatted message as Message and the fields of
+// the message as columns.
+//
+// Records are sent by an httpWriter, in JSON array batches with its retries and
+// buffering; every request is signed with the shared key of the workspace.
+type azureMonitorWriter struct {
+	formatter   *formatter
+	workspaceId string
+	logType     string
+	http        *httpWriter
+}
+
+// azureMonitorWriterOptions are the settings of an azuremonitor receiver.
+type azureMonitorWriterOptions struct {
+	url        string
+	logType    string
+	resourceId string
+}
+
+func newAzureMonitorWriter(formatter *formatter, workspaceId, sharedKey string, options azureMonitorWriterOptions,
+	httpOptions httpWriterOptions) (*azureMonitorWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if workspaceId == "" {
+		return nil, errors.New("Log Analytics workspace id can not be empty")
+	}
+	key, err := base64.StdEncoding.DecodeString(sharedKey)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("Log Analytics shared key must be a non empty base64 key")
+	}
+	if options.logType == "" {
+		options.logType = defaultAzureMonitorLogType
+	}
+	if !azureMonitorLogTypeRegexp.MatchString(options.logType) {
+		return nil, errors.New("Log Analytics log type can only have letters, digits and underscores, up to 100: " +
+			options.logType)
+	}
+	if options.url == "" {
+		options.url = "https://" + workspaceId + ".ods.opinsights.azure.com" + azureMonitorResource +
+			"?api-version=" + azureMonitorApiVersion
+	}
+	if httpOptions.maxSize == 0 {
+		httpOptions.maxSize = defaultAzureMonitorMaxSize
+	}
+	if httpOptions.maxSize > azureMonitorMaxSize {
+		return nil, fmt.Errorf("Log Analytics batches can have at most %d bytes", azureMonitorMaxSize)
+	}
+
+	httpOptions.batch = httpBatchArray
+	httpOptions.contentType = "application/json"
+	if httpOptions.headers == nil {
+		httpOptions.headers = make(http.Header)
+	}
+	httpOptions.headers.Set("Log-Type", options.logType)
+	httpOptions.headers.Set("time-generated-field", azureMonitorTimeKey)
+	if options.resourceId != "" {
+		httpOptions.headers.Set("x-ms-AzureResourceId", options.resourceId)
+	}
+	httpOptions.sign = func(request *http.Request, body []byte) error {
+		signAzureMonitorRequest(request, len(body), workspaceId, key, time.Now())
+		return nil
+	}
+	httpWriter, err := newHttpWriter(options.url, httpOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureMonitorWriter{
+		formatter:   formatter,
+		workspaceId: workspaceId,
+		logType:     options.logType,
+		http:        httpWriter,
+	}, nil
+}
+
+// signAzureMonitorRequest signs a Data Collector API request with the shared key of the
+// workspace: the Authorization header is the HMAC-SHA256 of the method, the length and
+// type of the content, the date and the resource.
+func signAzureMonitorRequest(request *http.Request, contentLength int, workspaceId string, key []byte, now time.Time) {
+	date := now.UTC().Format(http.TimeFormat)
+	request.Header.Set("x-ms-date", date)
+	stringToSign := request.Method + "\n" + strconv.Itoa(contentLength) + "\n" + request.Header.Get("Content-Type") +
+		"\nx-ms-date:" + date + "\n" + azureMonitorResource
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	request.Header.Set("Authorization", "SharedKey "+workspaceId+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+func (writer *azureMonitorWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	var columns []Field
+	for _, field := range context.Fields() {
+		switch field.Key {
+		case azureMonitorTimeKey, azureMonitorLevelKey, azureMonitorMessageKey:
+		default:
+			columns = append(columns, field)
+		}
+	}
+
+	record := new(bytes.Buffer)
+	record.WriteString(`{"` + azureMonitorTimeKey + `":"`)
+	record.WriteString(context.CallTime().UTC().Format(time.RFC3339Nano))
+	record.WriteString(`","` + azureMonitorLevelKey + `":"` + level.String() + `","` + azureMonitorMessageKey + `":`)
+	writeJsonString(record, strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n"))
+	writeJsonFields(record, columns, true)
+	record.WriteByte('}')
+
+	if _, err := writer.http.Write(record.Bytes()); err != nil {
+		errorFunc(err)
+	}
+}
+
+func (writer *azureMonitorWriter) Flush() {
+	writer.http.Flush()
+}
+
+func (writer *azureMonitorWriter) Close() error {
+	return writer.http.Close()
+}
+
+func (writer *azureMonitorWriter) String() string {
+	return fmt.Sprintf("azureMonitorWriter: [%s, %s, %s], format: %s\n",
+		writer.workspaceId, writer.logType, writer.http, writer.formatter)
+}
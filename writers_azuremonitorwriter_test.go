@@ -0,0 +1,93 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestAzureMonitorRecords(t *testing.T) {
+	server := newHttpTestServer(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	key := base64.StdEncoding.EncodeToString([]byte("shared key"))
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<azuremonitor formatid="msg" workspace="ws" sharedkey="`+key+`" logtype="Billing"
+		url="`+server.URL+`/api/logs?api-version=2016-04-01" resourceid="/subscriptions/s/vm" maxcount="2" backoff="1ms"/>`})
+	logger.Infow("paid", Int("amount", 10), Str("Level", "ignored"))
+	logger.Warn("slow")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(requests) != 2 || len(bodies) != 1 {
+		t.Fatalf("Expected a batch and a retry, got %q", bodies)
+	}
+	request := requests[1]
+	if request.Header.Get("Log-Type") != "Billing" || request.Header.Get("time-generated-field") != "Time" ||
+		request.Header.Get("x-ms-AzureResourceId") != "/subscriptions/s/vm" ||
+		request.URL.Query().Get("api-version") != "2016-04-01" {
+		t.Errorf("Unexpected request: %s %v", request.URL, request.Header)
+	}
+	mac := hmac.New(sha256.New, []byte("shared key"))
+	mac.Write([]byte("POST\n" + strconv.Itoa(len(bodies[0])) + "\napplication/json\nx-ms-date:" +
+		request.Header.Get("x-ms-date") + "\n/api/logs"))
+	if expected := "SharedKey ws:" + base64.StdEncoding.EncodeToString(mac.Sum(nil)); request.Header.Get("Authorization") != expected {
+		t.Errorf("Expected %s, got %s", expected, request.Header.Get("Authorization"))
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(bodies[0]), &records); err != nil || len(records) != 2 {
+		t.Fatalf("Expected two records, got %s: %v", bodies[0], err)
+	}
+	if records[0]["Message"] != "paid" || records[0]["Level"] != "info" || records[0]["amount"] != float64(10) ||
+		records[0]["Time"] == nil {
+		t.Errorf("Unexpected record: %v", records[0])
+	}
+	if records[1]["Message"] != "slow" || records[1]["Level"] != "warn" {
+		t.Errorf("Unexpected record: %v", records[1])
+	}
+}
+
+func TestAzureMonitorConfig(t *testing.T) {
+	invalid := []string{
+		`<azuremonitor sharedkey="a2V5"/>`,
+		`<azuremonitor workspace="ws"/>`,
+		`<azuremonitor workspace="ws" sharedkey="not base64!"/>`,
+		`<azuremonitor workspace="ws" sharedkey="a2V5" logtype="bad-type"/>`,
+		`<azuremonitor workspace="ws" sharedkey="a2V5" maxsize="40000000"/>`,
+		`<azuremonitor workspace="ws" sharedkey="a2V5" gzip="true"/>`,
+	}
+	for _, azureMonitor := range invalid {
+		config := `<seelog><outputs>` + azureMonitor + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultClickhouseDatabase = "default"
+	clickhouseTimeKey         = "time"
+	clickhouseLevelKey        = "level"
+	clickhouseMessageKey      = "message"
+	clickhouseFieldsKey       = "fields"
+)
+
+// clickhouseWriter inserts messages into a ClickHouse table over the HTTP interface, as
+// JSONEachRow rows with "time", "level", "message" (the formatted message), "fields" (all
+// fields as a JSON string, for JSONExtract and the like) and every field under its own
+// key, nested ones with dotted keys. Keys without a column are skipped, so columns named
+// after fields are filled and others are not needed; time is parsed best effort, so it
+// fits DateTime and DateTime64 columns.
+//
+// Rows are sent by an httpWriter, in ndjson batches with its retries and buffering. With
+// async the server collects small batches itself (async_insert), and acknowledges them
+// once they are written.
+type clickhouseWriter struct {
+	formatter *formatter
+	table     string
+	http      *httpWriter
+}
+
+func newClickhouseWriter(formatter *formatter, endpoint, database, table, user, password string, async bool,
+	options httpWriterOptions) (*clickhouseWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if table == "" {
+		return nil, errors.New("ClickHouse table can not be empty")
+	}
+	if database == "" {
+		database = defaultClickhouseDatabase
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"query": {"INSERT INTO " + clickhouseQuoteIdentifier(database) + "." + clickhouseQuoteIdentifier(table) +
+			" FORMAT JSONEachRow"},
+		"input_format_skip_unknown_fields": {"1"},
+		"date_time_input_format":           {"best_effort"},
+	}
+	if async {
+		query.Set("async_insert", "1")
+		query.Set("wait_for_async_insert", "1")
+	}
+	endpointURL.RawQuery = query.Encode()
+
+	options.batch = httpBatchNDJSON
+	if options.headers == nil {
+		options.headers = make(http.Header)
+	}
+	if user != "" {
+		options.headers.Set("X-ClickHouse-User", user)
+		options.headers.Set("X-ClickHouse-Key", password)
+	}
+	httpWriter, err := newHttpWriter(endpointURL.String(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clickhouseWriter{formatter: formatter, table: database + "." + table, http: httpWriter}, nil
+}
+
+// clickhouseQuoteIdentifier quotes a name with backquotes.
+func clickhouseQuoteIdentifier(name string) string {
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(name) + "`"
+}
+
+func (writer *clickhouseWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	fields := new(bytes.Buffer)
+	fields.WriteByte('{')
+	writeJsonFields(fields, context.Fields(), false)
+	fields.WriteByte('}')
+
+	row := new(bytes.Buffer)
+	row.WriteString(`{"` + clickhouseTimeKey + `":`)
+	writeJsonString(row, context.CallTime().UTC().Format(time.RFC3339Nano))
+	row.WriteString(`,"` + clickhouseLevelKey + `":`)
+	writeJsonString(row, level.String())
+	row.WriteString(`,"` + clickhouseMessageKey + `":`)
+	writeJsonString(row, strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n"))
+	row.WriteString(`,"` + clickhouseFieldsKey + `":`)
+	writeJsonString(row, fields.String())
+	for _, field := range flattenFields(context.Fields()) {
+		switch field.Key {
+		case clickhouseTimeKey, clickhouseLevelKey, clickhouseMessageKey, clickhouseFieldsKey:
+			continue
+		}
+		row.WriteByte(',')
+		writeJsonString(row, field.Key)
+		row.WriteByte(':')
+		writeJsonValue(row, field.Value)
+	}
+	row.WriteByte('}')
+
+	if _, err := writer.http.Write(row.Bytes()); err != nil {
+		errorFunc(err)
+	}
+}
+
+func (writer *clickhouseWriter) Flush() {
+	writer.http.Flush()
+}
+
+func (writer *clickhouseWriter) Close() error {
+	return writer.http.Close()
+}
+
+func (writer *clickhouseWriter) String() string {
+	return fmt.Sprintf("clickhouseWriter: [%s, %s], format: %s\n", writer.table, writer.http, writer.formatter)
+}
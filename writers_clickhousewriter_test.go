@@ -0,0 +1,92 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestClickhouseInsert(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<clickhouse formatid="msg" url="`+server.URL+`" database="logs" table="app"
+		user="logger" password="secret" async="true" maxcount="2"/>`})
+	logger.Infow("first", Str("user", "bob"), Int("status", 200))
+	logger.Error("second")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected one request, got %q", bodies)
+	}
+	query := requests[0].URL.Query()
+	if query.Get("query") != "INSERT INTO `logs`.`app` FORMAT JSONEachRow" || query.Get("async_insert") != "1" ||
+		query.Get("input_format_skip_unknown_fields") != "1" {
+		t.Errorf("Unexpected query: %v", query)
+	}
+	if requests[0].Header.Get("X-ClickHouse-User") != "logger" || requests[0].Header.Get("X-ClickHouse-Key") != "secret" {
+		t.Errorf("Unexpected headers: %v", requests[0].Header)
+	}
+
+	lines := strings.Split(strings.TrimSpace(bodies[0]), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected two rows, got %q", bodies[0])
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &row); err != nil {
+		t.Fatalf("Expected a JSON row, got %s: %s", lines[0], err)
+	}
+	if row["message"] != "first" || row["level"] != "info" || row["user"] != "bob" || row["status"] != float64(200) {
+		t.Errorf("Unexpected row: %v", row)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(row["fields"].(string)), &fields); err != nil || fields["user"] != "bob" {
+		t.Errorf("Unexpected fields: %v", row["fields"])
+	}
+	if _, ok := row["time"].(string); !ok {
+		t.Errorf("Expected a time, got %v", row)
+	}
+}
+
+func TestClickhouseConfig(t *testing.T) {
+	invalid := []string{
+		`<clickhouse table="app"/>`,
+		`<clickhouse url="http://clickhouse.local:8123"/>`,
+		`<clickhouse url="clickhouse.local:8123" table="app"/>`,
+		`<clickhouse url="http://clickhouse.local:8123" table="app" async="maybe"/>`,
+		`<clickhouse url="http://clickhouse.local:8123" table="app" maxinterval="soon"/>`,
+		`<clickhouse url="http://clickhouse.local:8123" table="app" batch="array"/>`,
+		`<clickhouse url="http://clickhouse.local:8123" table="app"><header name="a" value="b"/></clickhouse>`,
+	}
+	for _, clickhouse := range invalid {
+		config := `<seelog><outputs>` + clickhouse + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
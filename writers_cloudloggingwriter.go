@@ -0,0 +1,372 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCloudLoggingEndpoint    = "https://logging.googleapis.com"
+	defaultCloudLoggingLog         = "seelog"
+	defaultCloudLoggingMaxCount    = 1000
+	defaultCloudLoggingMaxInterval = 5 * time.Second
+	defaultCloudLoggingRetries     = 3
+	defaultCloudLoggingBackoff     = 500 * time.Millisecond
+	defaultCloudLoggingTimeout     = 30 * time.Second
+	cloudLoggingMaxSize            = 5 << 20
+	cloudLoggingScope              = "https://www.googleapis.com/auth/logging.write"
+	cloudLoggingWritePath          = "/v2/entries:write"
+)
+
+// cloudLoggingWriter writes messages to Google Cloud Logging with entries.write. Every
+// message is an entry with the severity of its level, its call time, its caller as the
+// source location and a jsonPayload holding the formatted message as "message" and the
+// fields of the message; the fields trace_id and span_id (see TraceParent) are the
+// trace and span of the entry instead. The project and the monitored resource are
+// detected at the first batch (see detectGcpResource), unless the project is set.
+//
+// Entries are collected in batches, which are sent when they reach maxCount entries or
+// 5 MiB, maxInterval after their first entry, on Flush and on Close. A batch which
+// fails with 429 or 5xx, or does not reach Cloud Logging, is sent again up to retries
+// times, waiting backoff, then twice as long and so on; if it still fails it is dropped
+// and reported. Entries which Cloud Logging rejects on their own are dropped with the
+// batch written (partialSuccess).
+type cloudLoggingWriter struct {
+	formatter   *formatter
+	endpoint    string
+	project     string
+	log         string
+	labels      map[string]string
+	credentials *gcpCredentials
+	metadata    *gcpMetadataClient
+	maxCount    int
+	maxInterval time.Duration
+	retries     int
+	backoff     time.Duration
+	client      *http.Client
+
+	mutex    sync.Mutex
+	resource *gcpResource
+	batch    []cloudLoggingEntry
+	size     int
+	started  time.Time
+	timer    *time.Timer
+	closed   bool
+}
+
+// cloudLoggingWriterOptions are the optional settings of a cloudlogging receiver. Zero
+// values other than retries are replaced with the defaults.
+type cloudLoggingWriterOptions struct {
+	endpoint    string
+	project     string
+	log         string
+	labels      map[string]string
+	credentials string
+	maxCount    int
+	maxInterval time.Duration
+	retries     int
+	backoff     time.Duration
+	timeout     time.Duration
+}
+
+// cloudLoggingEntry is a LogEntry without its trace, which needs the project.
+type cloudLoggingEntry struct {
+	json    []byte // Without the closing brace
+	traceId string
+}
+
+func newCloudLoggingWriter(formatter *formatter, options cloudLoggingWriterOptions) (*cloudLoggingWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if options.endpoint == "" {
+		options.endpoint = defaultCloudLoggingEndpoint
+	}
+	if options.log == "" {
+		options.log = defaultCloudLoggingLog
+	}
+	if options.maxCount == 0 {
+		options.maxCount = defaultCloudLoggingMaxCount
+	}
+	if options.maxInterval == 0 {
+		options.maxInterval = defaultCloudLoggingMaxInterval
+	}
+	if options.backoff == 0 {
+		options.backoff = defaultCloudLoggingBackoff
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultCloudLoggingTimeout
+	}
+	if options.maxCount < 0 || options.maxInterval < 0 || options.retries < 0 || options.backoff < 0 ||
+		options.timeout < 0 {
+		return nil, errors.New("Cloud Logging receiver settings can not be negative")
+	}
+	credentials, err := findGcpCredentials(options.credentials, cloudLoggingScope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cloudLoggingWriter{
+		formatter:   formatter,
+		endpoint:    strings.TrimRight(options.endpoint, "/") + cloudLoggingWritePath,
+		project:     options.project,
+		log:         options.log,
+		labels:      options.labels,
+		credentials: credentials,
+		metadata:    newGcpMetadataClient(),
+		maxCount:    options.maxCount,
+		maxInterval: options.maxInterval,
+		retries:     options.retries,
+		backoff:     options.backoff,
+		client:      &http.Client{Timeout: options.timeout},
+	}, nil
+}
+
+func (writer *cloudLoggingWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	var traceId, spanId string
+	var payload []Field
+	for _, field := range context.Fields() {
+		switch field.Key {
+		case TraceIdFieldKey:
+			traceId = FieldValueString(field.Value)
+		case SpanIdFieldKey:
+			spanId = FieldValueString(field.Value)
+		case TraceFlagsFieldKey, "message":
+		default:
+			payload = append(payload, field)
+		}
+	}
+
+	entry := new(bytes.Buffer)
+	entry.WriteString(`{"severity":"` + cloudLoggingSeverity(level) + `","timestamp":"`)
+	entry.WriteString(context.CallTime().UTC().Format(time.RFC3339Nano))
+	entry.WriteString(`","jsonPayload":{"message":`)
+	writeJsonString(entry, strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n"))
+	writeJsonFields(entry, payload, true)
+	entry.WriteByte('}')
+	if context.IsValid() {
+		entry.WriteString(`,"sourceLocation":{"file":`)
+		writeJsonString(entry, context.FullPath())
+		entry.WriteString(fmt.Sprintf(`,"line":"%d","function":`, context.Line()))
+		writeJsonString(entry, context.Func())
+		entry.WriteByte('}')
+	}
+	if spanId != "" {
+		entry.WriteString(`,"spanId":`)
+		writeJsonString(entry, spanId)
+	}
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("Cloud Logging writer is closed"))
+		return
+	}
+
+	if len(writer.batch) > 0 && writer.size+entry.Len() > cloudLoggingMaxSize {
+		if err := writer.sendBatch(); err != nil {
+			errorFunc(err)
+		}
+	}
+	if len(writer.batch) == 0 {
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.maxInterval, writer.sendOnTimer)
+	}
+	writer.batch = append(writer.batch, cloudLoggingEntry{json: entry.Bytes(), traceId: traceId})
+	writer.size += entry.Len()
+	if len(writer.batch) >= writer.maxCount {
+		if err := writer.sendBatch(); err != nil {
+			errorFunc(err)
+		}
+	}
+}
+
+// cloudLoggingSeverity returns the LogSeverity of a level.
+func cloudLoggingSeverity(level LogLevel) string {
+	switch level {
+	case TraceLvl, DebugLvl:
+		return "DEBUG"
+	case InfoLvl:
+		return "INFO"
+	case WarnLvl:
+		return "WARNING"
+	case ErrorLvl:
+		return "ERROR"
+	case CriticalLvl:
+		return "CRITICAL"
+	}
+	return "DEFAULT"
+}
+
+func (writer *cloudLoggingWriter) sendOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if len(writer.batch) > 0 && time.Since(writer.started) >= writer.maxInterval {
+		if err := writer.sendBatch(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// sendBatch sends the collected entries, with retries, and starts a new batch.
+func (writer *cloudLoggingWriter) sendBatch() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	batch := writer.batch
+	writer.batch = nil
+	writer.size = 0
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if writer.resource == nil {
+		project, resource, err := detectGcpResource(writer.metadata, writer.project)
+		if err != nil {
+			return fmt.Errorf("Cannot send %d messages to Cloud Logging: %s", len(batch), err)
+		}
+		writer.project, writer.resource = project, resource
+	}
+	body := writer.request(batch)
+
+	var err error
+	for attempt := 0; attempt <= writer.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(writer.backoff << uint(attempt-1))
+		}
+		var retry bool
+		if retry, err = writer.post(body); err == nil || !retry {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot send %d messages to Cloud Logging %s: %s", len(batch), writer.logName(), err)
+	}
+	return nil
+}
+
+func (writer *cloudLoggingWriter) logName() string {
+	return "projects/" + writer.project + "/logs/" + strings.Replace(writer.log, "/", "%2F", -1)
+}
+
+// request returns the body of the entries.write request of a batch.
+func (writer *cloudLoggingWriter) request(batch []cloudLoggingEntry) []byte {
+	body := bytes.NewBuffer(make([]byte, 0, 256+len(batch)*64))
+	body.WriteString(`{"logName":`)
+	writeJsonString(body, writer.logName())
+	resource, _ := json.Marshal(writer.resource)
+	body.WriteString(`,"resource":`)
+	body.Write(resource)
+	if len(writer.labels) > 0 {
+		labels, _ := json.Marshal(writer.labels)
+		body.WriteString(`,"labels":`)
+		body.Write(labels)
+	}
+	body.WriteString(`,"partialSuccess":true,"entries":[`)
+	for i, entry := range batch {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(entry.json)
+		if entry.traceId != "" {
+			body.WriteString(`,"trace":`)
+			writeJsonString(body, "projects/"+writer.project+"/traces/"+entry.traceId)
+		}
+		body.WriteByte('}')
+	}
+	body.WriteString(`]}`)
+	return body.Bytes()
+}
+
+// post posts a request and returns whether it may succeed if sent again when it fails.
+func (writer *cloudLoggingWriter) post(body []byte) (bool, error) {
+	token, err := writer.credentials.accessToken()
+	if err != nil {
+		return true, err
+	}
+	request, err := http.NewRequest(http.MethodPost, writer.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := writer.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	responseBody, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode != http.StatusOK {
+		return response.StatusCode == http.StatusTooManyRequests || response.StatusCode/100 == 5,
+			fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(responseBody)))
+	}
+	return false, nil
+}
+
+func (writer *cloudLoggingWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.sendBatch(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *cloudLoggingWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.sendBatch()
+}
+
+func (writer *cloudLoggingWriter) String() string {
+	return fmt.Sprintf("cloudLoggingWriter: [%s, %s], format: %s\n", writer.log, writer.credentials, writer.formatter)
+}
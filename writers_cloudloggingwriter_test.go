@@ -0,0 +1,203 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// gcpTestServer is a metadata server of a GCE instance and a Cloud Logging endpoint
+// which answers the given statuses, one per request, and 200 once they are used up.
+type gcpTestServer struct {
+	*httptest.Server
+	metadata map[string]string
+	statuses []int
+
+	mutex  sync.Mutex
+	bodies []map[string]interface{}
+}
+
+func newGcpTestServer(t *testing.T, statuses ...int) *gcpTestServer {
+	server := &gcpTestServer{
+		metadata: map[string]string{
+			"instance/id":        "4321",
+			"instance/zone":      "projects/123/zones/europe-west1-b",
+			"instance/region":    "projects/123/regions/europe-west1",
+			"project/project-id": "acme",
+			"instance/service-accounts/default/token": `{"access_token":"token","expires_in":3600}`,
+		},
+		statuses: statuses,
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	t.Setenv("HOME", t.TempDir())
+	for _, name := range []string{"K_SERVICE", "K_REVISION", "K_CONFIGURATION", "FUNCTION_TARGET", "GAE_SERVICE",
+		"GAE_VERSION", "KUBERNETES_SERVICE_HOST", "POD_NAMESPACE", "CONTAINER_NAME"} {
+		t.Setenv(name, "")
+	}
+	return server
+}
+
+func (server *gcpTestServer) serve(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, gcpMetadataPath) {
+		value, ok := server.metadata[strings.TrimPrefix(r.URL.Path, gcpMetadataPath)]
+		if !ok || r.Header.Get("Metadata-Flavor") != "Google" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(value))
+		return
+	}
+	if r.URL.Path != cloudLoggingWritePath || r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "unexpected request", http.StatusForbidden)
+		return
+	}
+	var body map[string]interface{}
+	data, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(data, &body)
+	server.bodies = append(server.bodies, body)
+	if len(server.statuses) > 0 {
+		status := server.statuses[0]
+		server.statuses = server.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+func TestCloudLoggingEntries(t *testing.T) {
+	server := newGcpTestServer(t, http.StatusServiceUnavailable)
+	defer server.Close()
+	t.Setenv("K_SERVICE", "billing")
+	t.Setenv("K_REVISION", "billing-00042")
+	t.Setenv("K_CONFIGURATION", "billing")
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<cloudlogging formatid="msg" endpoint="`+server.URL+`" log="app/billing"
+		labels="team=payments, tier=backend" maxcount="2" backoff="1ms"/>`})
+	logger.Infow("paid", Int("amount", 10), Str(TraceIdFieldKey, "0af7651916cd43dd8448eb211c80319c"),
+		Str(SpanIdFieldKey, "b7ad6b7169203331"))
+	logger.Warn("slow")
+	logger.Close()
+
+	if len(server.bodies) != 2 {
+		t.Fatalf("Expected a batch and a retry, got %v", server.bodies)
+	}
+	body := server.bodies[1]
+	if body["logName"] != "projects/acme/logs/app%2Fbilling" || body["partialSuccess"] != true {
+		t.Errorf("Unexpected request: %v", body)
+	}
+	resource := body["resource"].(map[string]interface{})
+	labels := resource["labels"].(map[string]interface{})
+	if resource["type"] != "cloud_run_revision" || labels["service_name"] != "billing" ||
+		labels["revision_name"] != "billing-00042" || labels["location"] != "europe-west1" ||
+		labels["project_id"] != "acme" {
+		t.Errorf("Unexpected resource: %v", resource)
+	}
+	if commonLabels := body["labels"].(map[string]interface{}); commonLabels["tier"] != "backend" {
+		t.Errorf("Unexpected labels: %v", commonLabels)
+	}
+
+	entries := body["entries"].([]interface{})
+	if len(entries) != 2 {
+		t.Fatalf("Expected two entries, got %v", entries)
+	}
+	first := entries[0].(map[string]interface{})
+	payload := first["jsonPayload"].(map[string]interface{})
+	if first["severity"] != "INFO" || payload["message"] != "paid" || payload["amount"] != float64(10) ||
+		payload[TraceIdFieldKey] != nil || first["spanId"] != "b7ad6b7169203331" ||
+		first["trace"] != "projects/acme/traces/0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("Unexpected entry: %v", first)
+	}
+	if location, _ := first["sourceLocation"].(map[string]interface{}); location == nil ||
+		!strings.HasSuffix(location["file"].(string), "writers_cloudloggingwriter_test.go") ||
+		!strings.HasSuffix(location["function"].(string), "TestCloudLoggingEntries") {
+		t.Errorf("Unexpected source location: %v", first["sourceLocation"])
+	}
+	second := entries[1].(map[string]interface{})
+	if second["severity"] != "WARNING" || second["trace"] != nil || second["timestamp"] == "" {
+		t.Errorf("Unexpected entry: %v", second)
+	}
+}
+
+func TestDetectGcpResource(t *testing.T) {
+	server := newGcpTestServer(t)
+	defer server.Close()
+	server.metadata["instance/attributes/cluster-name"] = "prod"
+	server.metadata["instance/attributes/cluster-location"] = "europe-west1"
+	metadata := newGcpMetadataClient()
+
+	project, resource, err := detectGcpResource(metadata, "")
+	if err != nil || project != "acme" || resource.Type != "gce_instance" ||
+		resource.Labels["instance_id"] != "4321" || resource.Labels["zone"] != "europe-west1-b" {
+		t.Errorf("Unexpected GCE resource %s: %v, %v", project, resource, err)
+	}
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("POD_NAMESPACE", "billing")
+	_, resource, err = detectGcpResource(metadata, "other")
+	if err != nil || resource.Type != "k8s_container" || resource.Labels["project_id"] != "other" ||
+		resource.Labels["cluster_name"] != "prod" || resource.Labels["location"] != "europe-west1" ||
+		resource.Labels["namespace_name"] != "billing" {
+		t.Errorf("Unexpected GKE resource: %v, %v", resource, err)
+	}
+
+	t.Setenv("GCE_METADATA_HOST", "127.0.0.1:1")
+	if _, _, err = detectGcpResource(newGcpMetadataClient(), ""); err == nil {
+		t.Error("Expected an error without a project")
+	}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "local")
+	project, resource, err = detectGcpResource(newGcpMetadataClient(), "")
+	if err != nil || project != "local" || resource.Type != "global" {
+		t.Errorf("Unexpected resource off Google Cloud %s: %v, %v", project, resource, err)
+	}
+}
+
+func TestCloudLoggingConfig(t *testing.T) {
+	invalid := []string{
+		`<cloudlogging labels="team"/>`,
+		`<cloudlogging maxcount="-1"/>`,
+		`<cloudlogging maxinterval="soon"/>`,
+		`<cloudlogging credentials="/nonexistent/credentials.json"/>`,
+		`<cloudlogging region="eu"/>`,
+		`<cloudlogging><header name="a" value="b"/></cloudlogging>`,
+	}
+	for _, cloudLogging := range invalid {
+		config := `<seelog><outputs>` + cloudLogging + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
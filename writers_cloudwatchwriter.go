@@ -0,0 +1,441 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultCloudWatchMaxInterval = 5 * time.Second
+	defaultCloudWatchRetries     = 5
+	defaultCloudWatchBackoff     = 500 * time.Millisecond
+	defaultCloudWatchTimeout     = 30 * time.Second
+	cloudWatchMaxCount           = 10000
+	cloudWatchMaxSize            = 1 << 20
+	cloudWatchEventOverhead      = 26
+	cloudWatchMaxEventSize       = 256<<10 - cloudWatchEventOverhead
+	cloudWatchMaxSpan            = 24 * time.Hour
+	cloudWatchTargetPrefix       = "Logs_20140328."
+)
+
+// cloudWatchWriter sends messages to a log stream of AWS CloudWatch Logs with
+// PutLogEvents. Every message is an event with the formatted message, cut at the size
+// CloudWatch allows, at its call time. The log group and stream are created when
+// CloudWatch reports that they do not exist, the group with a retention of retention
+// days if it is set. Requests are signed with the credentials of the settings, of the
+// environment or of the role of the task or instance (see awsCredentialsProvider).
+//
+// Events are collected in batches, which are sent when they reach maxCount events (at
+// most 10000), the 1 MiB which CloudWatch allows or a span of 24 hours, maxInterval
+// after their first event, on Flush and on Close. The sequence token of the stream is
+// kept and corrected when CloudWatch expects another one. A batch which is throttled or
+// fails with 5xx, or does not reach CloudWatch, is sent again up to retries times,
+// waiting backoff, then twice as long and so on; if it still fails it is dropped and
+// reported.
+type cloudWatchWriter struct {
+	formatter   *formatter
+	endpoint    string
+	region      string
+	group       string
+	stream      string
+	retention   int
+	credentials *awsCredentialsProvider
+	maxCount    int
+	maxInterval time.Duration
+	retries     int
+	backoff     time.Duration
+	client      *http.Client
+
+	mutex         sync.Mutex
+	batch         []cloudWatchEvent
+	size          int
+	first         int64 // Timestamps of the oldest and newest events of the batch
+	last          int64
+	started       time.Time
+	timer         *time.Timer
+	sequenceToken string
+	closed        bool
+}
+
+// cloudWatchWriterOptions are the optional settings of a cloudwatch receiver. Zero
+// values other than retention and retries are replaced with the defaults.
+type cloudWatchWriterOptions struct {
+	endpoint    string
+	stream      string
+	retention   int
+	accessKey   string
+	secretKey   string
+	maxCount    int
+	maxInterval time.Duration
+	retries     int
+	backoff     time.Duration
+	timeout     time.Duration
+}
+
+type cloudWatchEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+// cloudWatchError is an error which CloudWatch Logs returned.
+type cloudWatchError struct {
+	status                int
+	kind                  string
+	message               string
+	expectedSequenceToken string
+}
+
+func (err *cloudWatchError) Error() string {
+	return fmt.Sprintf("CloudWatch Logs %s (%d): %s", err.kind, err.status, err.message)
+}
+
+// retryable reports whether a request which failed with the error may succeed later.
+func (err *cloudWatchError) retryable() bool {
+	return err.kind == "ThrottlingException" || err.kind == "ServiceUnavailableException" || err.status/100 == 5
+}
+
+func newCloudWatchWriter(formatter *formatter, region, group string, options cloudWatchWriterOptions) (*cloudWatchWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, errors.New("CloudWatch Logs region is not set, nor is AWS_REGION")
+	}
+	if group == "" {
+		return nil, errors.New("CloudWatch Logs group can not be empty")
+	}
+	if options.endpoint == "" {
+		options.endpoint = "https://logs." + region + ".amazonaws.com"
+	}
+	if options.stream == "" {
+		host, _ := os.Hostname()
+		options.stream = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if options.maxCount == 0 || options.maxCount > cloudWatchMaxCount {
+		options.maxCount = cloudWatchMaxCount
+	}
+	if options.maxInterval == 0 {
+		options.maxInterval = defaultCloudWatchMaxInterval
+	}
+	if options.backoff == 0 {
+		options.backoff = defaultCloudWatchBackoff
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultCloudWatchTimeout
+	}
+	if options.maxCount < 0 || options.maxInterval < 0 || options.retries < 0 || options.backoff < 0 ||
+		options.timeout < 0 || options.retention < 0 {
+		return nil, errors.New("CloudWatch Logs receiver settings can not be negative")
+	}
+	if (options.accessKey == "") != (options.secretKey == "") {
+		return nil, errors.New("CloudWatch Logs receiver needs both an access key and a secret key, or neither")
+	}
+
+	return &cloudWatchWriter{
+		formatter:   formatter,
+		endpoint:    strings.TrimRight(options.endpoint, "/") + "/",
+		region:      region,
+		group:       group,
+		stream:      options.stream,
+		retention:   options.retention,
+		credentials: newAwsCredentialsProvider(options.accessKey, options.secretKey),
+		maxCount:    options.maxCount,
+		maxInterval: options.maxInterval,
+		retries:     options.retries,
+		backoff:     options.backoff,
+		client:      &http.Client{Timeout: options.timeout},
+	}, nil
+}
+
+func (writer *cloudWatchWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	text := strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n")
+	if len(text) > cloudWatchMaxEventSize {
+		text = text[:cloudWatchMaxEventSize]
+		for !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+	}
+	event := cloudWatchEvent{Timestamp: context.CallTime().UnixNano() / 1e6, Message: text}
+	size := len(text) + cloudWatchEventOverhead
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("CloudWatch Logs writer is closed"))
+		return
+	}
+
+	if len(writer.batch) > 0 && (writer.size+size > cloudWatchMaxSize || !writer.spans(event.Timestamp)) {
+		if err := writer.sendBatch(); err != nil {
+			errorFunc(err)
+		}
+	}
+	if len(writer.batch) == 0 {
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.maxInterval, writer.sendOnTimer)
+	}
+	if len(writer.batch) == 0 || event.Timestamp < writer.first {
+		writer.first = event.Timestamp
+	}
+	if len(writer.batch) == 0 || event.Timestamp > writer.last {
+		writer.last = event.Timestamp
+	}
+	writer.batch = append(writer.batch, event)
+	writer.size += size
+	if len(writer.batch) >= writer.maxCount {
+		if err := writer.sendBatch(); err != nil {
+			errorFunc(err)
+		}
+	}
+}
+
+// spans reports whether the batch with an event at timestamp would span less than 24
+// hours.
+func (writer *cloudWatchWriter) spans(timestamp int64) bool {
+	first, last := writer.first, writer.last
+	if timestamp < first {
+		first = timestamp
+	}
+	if timestamp > last {
+		last = timestamp
+	}
+	return time.Duration(last-first)*time.Millisecond < cloudWatchMaxSpan
+}
+
+func (writer *cloudWatchWriter) sendOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if len(writer.batch) > 0 && time.Since(writer.started) >= writer.maxInterval {
+		if err := writer.sendBatch(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// sendBatch sends the collected events, with retries, and starts a new batch.
+func (writer *cloudWatchWriter) sendBatch() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	batch := writer.batch
+	writer.batch = nil
+	writer.size = 0
+	if len(batch) == 0 {
+		return nil
+	}
+	// CloudWatch requires the events of a batch in chronological order
+	sort.SliceStable(batch, func(i, j int) bool { return batch[i].Timestamp < batch[j].Timestamp })
+
+	var err error
+	created := false
+	failures := 0
+	for attempt := 0; attempt <= writer.retries; attempt++ {
+		if failures > 0 {
+			time.Sleep(writer.backoff << uint(failures-1))
+		}
+		err = writer.putLogEvents(batch)
+		cloudWatchErr, isCloudWatchErr := err.(*cloudWatchError)
+		switch {
+		case err == nil:
+			return nil
+		case isCloudWatchErr && cloudWatchErr.kind == "DataAlreadyAcceptedException":
+			writer.sequenceToken = cloudWatchErr.expectedSequenceToken
+			return nil
+		case isCloudWatchErr && cloudWatchErr.kind == "InvalidSequenceTokenException" &&
+			cloudWatchErr.expectedSequenceToken != writer.sequenceToken:
+			writer.sequenceToken = cloudWatchErr.expectedSequenceToken
+			continue
+		case isCloudWatchErr && cloudWatchErr.kind == "ResourceNotFoundException" && !created:
+			if err = writer.createGroupAndStream(); err == nil {
+				created = true
+				continue
+			}
+			cloudWatchErr, isCloudWatchErr = err.(*cloudWatchError)
+		}
+
+		if isCloudWatchErr && !cloudWatchErr.retryable() {
+			break
+		}
+		failures++
+	}
+	return fmt.Errorf("Cannot send %d messages to CloudWatch Logs %s/%s: %s", len(batch), writer.group, writer.stream, err)
+}
+
+func (writer *cloudWatchWriter) putLogEvents(batch []cloudWatchEvent) error {
+	input := map[string]interface{}{
+		"logGroupName":  writer.group,
+		"logStreamName": writer.stream,
+		"logEvents":     batch,
+	}
+	if writer.sequenceToken != "" {
+		input["sequenceToken"] = writer.sequenceToken
+	}
+	var output struct {
+		NextSequenceToken string `json:"nextSequenceToken"`
+	}
+	if err := writer.call("PutLogEvents", input, &output); err != nil {
+		return err
+	}
+	writer.sequenceToken = output.NextSequenceToken
+	return nil
+}
+
+// createGroupAndStream creates the log group, with its retention, and the log stream,
+// unless they exist.
+func (writer *cloudWatchWriter) createGroupAndStream() error {
+	err := writer.call("CreateLogGroup", map[string]interface{}{"logGroupName": writer.group}, nil)
+	if err == nil && writer.retention > 0 {
+		err = writer.call("PutRetentionPolicy", map[string]interface{}{
+			"logGroupName":    writer.group,
+			"retentionInDays": writer.retention,
+		}, nil)
+	}
+	if err != nil && !isCloudWatchError(err, "ResourceAlreadyExistsException") {
+		return err
+	}
+
+	err = writer.call("CreateLogStream", map[string]interface{}{
+		"logGroupName":  writer.group,
+		"logStreamName": writer.stream,
+	}, nil)
+	if err != nil && !isCloudWatchError(err, "ResourceAlreadyExistsException") {
+		return err
+	}
+	writer.sequenceToken = ""
+	return nil
+}
+
+func isCloudWatchError(err error, kind string) bool {
+	cloudWatchErr, ok := err.(*cloudWatchError)
+	return ok && cloudWatchErr.kind == kind
+}
+
+// call calls an action of the CloudWatch Logs API and decodes its output, if output is
+// not nil.
+func (writer *cloudWatchWriter) call(action string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	credentials, err := writer.credentials.credentials()
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, writer.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", cloudWatchTargetPrefix+action)
+	signAwsRequest(request, body, credentials, writer.region, "logs", time.Now())
+
+	response, err := writer.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	responseBody, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<16))
+
+	if response.StatusCode != http.StatusOK {
+		var result struct {
+			Type                  string `json:"__type"`
+			Message               string `json:"message"`
+			MessageUpper          string `json:"Message"`
+			ExpectedSequenceToken string `json:"expectedSequenceToken"`
+		}
+		json.Unmarshal(responseBody, &result)
+		err := &cloudWatchError{
+			status:                response.StatusCode,
+			kind:                  result.Type[strings.LastIndex(result.Type, "#")+1:],
+			message:               result.Message + result.MessageUpper,
+			expectedSequenceToken: result.ExpectedSequenceToken,
+		}
+		if err.kind == "" {
+			err.kind = response.Status
+		}
+		return err
+	}
+	if output != nil {
+		return json.Unmarshal(responseBody, output)
+	}
+	return nil
+}
+
+func (writer *cloudWatchWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.sendBatch(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *cloudWatchWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.sendBatch()
+}
+
+func (writer *cloudWatchWriter) String() string {
+	return fmt.Sprintf("cloudWatchWriter: [%s, %s/%s, %s], format: %s\n",
+		writer.region, writer.group, writer.stream, writer.credentials, writer.formatter)
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cloudWatchTestServer is a CloudWatch Logs endpoint which answers the actions with the
+// given errors, one per call of the action, and with success once they are used up.
+type cloudWatchTestServer struct {
+	*httptest.Server
+	errors map[string][]string
+
+	mutex   sync.Mutex
+	actions []string
+	inputs  []map[string]interface{}
+}
+
+func newCloudWatchTestServer(errors map[string][]string) *cloudWatchTestServer {
+	server := &cloudWatchTestServer{errors: errors}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	return server
+}
+
+func (server *cloudWatchTestServer) serve(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/logs/aws4_request") {
+		http.Error(w, `{"__type":"UnrecognizedClientException"}`, http.StatusForbidden)
+		return
+	}
+	action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), cloudWatchTargetPrefix)
+	var input map[string]interface{}
+	body, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(body, &input)
+	server.actions = append(server.actions, action)
+	server.inputs = append(server.inputs, input)
+
+	if errs := server.errors[action]; len(errs) > 0 {
+		server.errors[action] = errs[1:]
+		status := http.StatusBadRequest
+		if errs[0] == "ServiceUnavailableException" {
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{
+			"__type":                "com.amazonaws.logs#" + errs[0],
+			"message":               "test",
+			"expectedSequenceToken": "expected",
+		})
+		return
+	}
+	if action == "PutLogEvents" {
+		w.Write([]byte(`{"nextSequenceToken":"next"}`))
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+func TestCloudWatchCreatesStream(t *testing.T) {
+	server := newCloudWatchTestServer(map[string][]string{
+		"PutLogEvents": {"ResourceNotFoundException", "InvalidSequenceTokenException", "ThrottlingException"},
+	})
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<cloudwatch formatid="msg" endpoint="`+server.URL+`" region="eu-west-1" group="/app"
+		stream="web-1" retention="7" accesskey="AKID" secretkey="secret" maxcount="2" backoff="1ms"/>`})
+	logger.Info("first")
+	logger.Error("second")
+	logger.Info("third")
+	logger.Close()
+
+	expected := []string{"PutLogEvents", "CreateLogGroup", "PutRetentionPolicy", "CreateLogStream",
+		"PutLogEvents", "PutLogEvents", "PutLogEvents", "PutLogEvents"}
+	if strings.Join(server.actions, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected %v, got %v", expected, server.actions)
+	}
+	if server.inputs[2]["retentionInDays"] != float64(7) || server.inputs[3]["logStreamName"] != "web-1" {
+		t.Errorf("Unexpected inputs: %v", server.inputs[1:4])
+	}
+	tokens := []interface{}{nil, "expected", "expected", "next"}
+	for i, token := range tokens {
+		if server.inputs[4+i]["sequenceToken"] != token {
+			t.Errorf("Expected sequence token %v, got %v", token, server.inputs[4+i]["sequenceToken"])
+		}
+	}
+	events := server.inputs[6]["logEvents"].([]interface{})
+	if len(events) != 2 || events[0].(map[string]interface{})["message"] != "first" {
+		t.Errorf("Unexpected events: %v", events)
+	}
+	if events[0].(map[string]interface{})["timestamp"].(float64) == 0 {
+		t.Errorf("Expected timestamps, got %v", events)
+	}
+}
+
+func TestCloudWatchBatchLimits(t *testing.T) {
+	server := newCloudWatchTestServer(nil)
+	defer server.Close()
+
+	writer, err := newCloudWatchWriter(predefinedFormats[predefinedPrefix+"fast"], "eu-west-1", "/app",
+		cloudWatchWriterOptions{endpoint: server.URL, accessKey: "AKID", secretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := strings.Repeat("x", 300<<10)
+	for i := 0; i < 5; i++ {
+		writer.Dispatch(message, InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	}
+	writer.Close()
+
+	if len(server.inputs) != 2 {
+		t.Fatalf("Expected two requests, got %d", len(server.inputs))
+	}
+	for i, count := range []int{4, 1} {
+		events := server.inputs[i]["logEvents"].([]interface{})
+		if len(events) != count {
+			t.Errorf("Expected %d events in request %d, got %d", count, i, len(events))
+		}
+		if size := len(events[0].(map[string]interface{})["message"].(string)); size != cloudWatchMaxEventSize {
+			t.Errorf("Expected events cut at %d bytes, got %d", cloudWatchMaxEventSize, size)
+		}
+	}
+}
+
+func TestCloudWatchConfig(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	invalid := []string{
+		`<cloudwatch region="eu-west-1"/>`,
+		`<cloudwatch group="/app"/>`,
+		`<cloudwatch region="eu-west-1" group="/app" accesskey="AKID"/>`,
+		`<cloudwatch region="eu-west-1" group="/app" retention="-1"/>`,
+		`<cloudwatch region="eu-west-1" group="/app" maxinterval="soon"/>`,
+		`<cloudwatch region="eu-west-1" group="/app" bucket="logs"/>`,
+	}
+	for _, cloudwatch := range invalid {
+		config := `<seelog><outputs>` + cloudwatch + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
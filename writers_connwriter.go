@@ -1,16 +1,16 @@
 // Copyright (c) 2012 - Cloud Instruments Co., Ltd.
-// 
+//
 // All rights reserved.
 //
 // Redistribution and use in source and binary forms, with or without
-// modification, are permitted provided that the following conditions are met: 
-// 
+// modification, are permitted provided that the following conditions are met:
+//
 // 1. Redistributions of source code must retain the above copyright notice, this
-//    list of conditions and the following disclaimer. 
+//    list of conditions and the following disclaimer.
 // 2. Redistributions in binary form must reproduce the above copyright notice,
 //    this list of conditions and the following disclaimer in the documentation
-//    and/or other materials provided with the distribution. 
-// 
+//    and/or other materials provided with the distribution.
+//
 // THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
 // ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
 // WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
@@ -25,18 +25,86 @@
 package seelog
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConnReconnect      = time.Second
+	defaultConnMaxReconnect   = time.Minute
+	defaultConnTimeout        = 5 * time.Second
+	defaultConnBufferFileSize = 64 << 20
+)
+
+// Overflow policies of a conn receiver, for messages which neither fit in memory nor in
+// the buffer file.
+const (
+	connOverflowDropOldest = "dropoldest" // drop the oldest kept messages
+	connOverflowDropNewest = "dropnewest" // drop the new message
+	connOverflowBlock      = "block"      // wait until the kept messages are sent
 )
 
 // connWriter is used to write to a stream-oriented network connection.
+//
+// With connWriterOptions it does not block on or lose messages when the remote end goes
+// away: after a failed dial, it dials again only after the reconnect interval, doubled
+// after every further failure up to maxReconnect. Messages which can not be sent are kept
+// in memory, up to bufferSize bytes; older messages beyond that are appended to the file
+// at bufferPath, up to bufferFileSize bytes, or else handled by the overflow policy. The
+// kept messages are sent, oldest first, once the connection is back, which is tried in the
+// background too.
+//
+// Every write is limited by writeTimeout, so that a hung remote end does not hold the
+// caller: it counts as unreachable once a write times out.
 type connWriter struct {
 	innerWriter    io.WriteCloser
 	reconnectOnMsg bool
 	recconect      bool
 	net            string
 	addr           string
+	tlsConfig      *tls.Config   // TLS is used if set
+	writeTimeout   time.Duration // no deadline if zero
+
+	managed        bool
+	reconnect      time.Duration
+	maxReconnect   time.Duration
+	timeout        time.Duration
+	overflow       string
+	bufferSize     int
+	bufferPath     string
+	bufferFileSize int64
+
+	mutex    sync.Mutex
+	conn     net.Conn
+	backoff  time.Duration
+	retryAt  time.Time
+	timer    *time.Timer
+	kept     [][]byte
+	keptSize int
+	dropped  int
+	closed   bool
+}
+
+// connWriterOptions are the optional settings of a conn receiver. With any of reconnect,
+// bufferSize, bufferPath and overflow set, the writer reconnects with backoff and keeps
+// messages while disconnected; other zero values are then replaced with the defaults.
+// writeTimeout defaults to timeout.
+type connWriterOptions struct {
+	tlsConfig      *tls.Config
+	reconnect      time.Duration
+	maxReconnect   time.Duration
+	timeout        time.Duration
+	writeTimeout   time.Duration
+	overflow       string
+	bufferSize     int
+	bufferPath     string
+	bufferFileSize int64
 }
 
 // Creates writer to the address addr on the network netName.
@@ -51,7 +119,70 @@ func newConnWriter(netName string, addr string, reconnectOnMsg bool) *connWriter
 	return newWriter
 }
 
+// Creates writer to the address addr on the network netName with the given options.
+func newConnWriterWithOptions(netName string, addr string, reconnectOnMsg bool, options connWriterOptions) (*connWriter, error) {
+	newWriter := newConnWriter(netName, addr, reconnectOnMsg)
+	newWriter.tlsConfig = options.tlsConfig
+
+	if options.timeout < 0 || options.writeTimeout < 0 {
+		return nil, errors.New("Conn receiver settings can not be negative")
+	}
+	if options.writeTimeout == 0 {
+		options.writeTimeout = options.timeout
+	}
+	switch options.overflow {
+	case "", connOverflowDropOldest, connOverflowDropNewest, connOverflowBlock:
+	default:
+		return nil, fmt.Errorf("Unknown conn overflow policy '%s'", options.overflow)
+	}
+
+	if options.reconnect == 0 && options.bufferSize == 0 && options.bufferPath == "" && options.overflow == "" {
+		newWriter.writeTimeout = options.writeTimeout
+		return newWriter, nil
+	}
+	if options.reconnect == 0 {
+		options.reconnect = defaultConnReconnect
+	}
+	if options.maxReconnect == 0 {
+		options.maxReconnect = defaultConnMaxReconnect
+	}
+	if options.maxReconnect < options.reconnect {
+		options.maxReconnect = options.reconnect
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultConnTimeout
+	}
+	if options.writeTimeout == 0 {
+		options.writeTimeout = options.timeout
+	}
+	if options.bufferFileSize == 0 {
+		options.bufferFileSize = defaultConnBufferFileSize
+	}
+	if options.overflow == "" {
+		options.overflow = connOverflowDropOldest
+	}
+	if options.reconnect < 0 || options.bufferSize < 0 || options.bufferFileSize < 0 {
+		return nil, errors.New("Conn receiver settings can not be negative")
+	}
+
+	newWriter.managed = true
+	newWriter.reconnect = options.reconnect
+	newWriter.maxReconnect = options.maxReconnect
+	newWriter.timeout = options.timeout
+	newWriter.writeTimeout = options.writeTimeout
+	newWriter.overflow = options.overflow
+	newWriter.bufferSize = options.bufferSize
+	newWriter.bufferPath = options.bufferPath
+	newWriter.bufferFileSize = options.bufferFileSize
+	newWriter.backoff = options.reconnect
+
+	return newWriter, nil
+}
+
 func (connWriter *connWriter) Close() error {
+	if connWriter.managed {
+		return connWriter.closeManaged()
+	}
 	if connWriter.innerWriter == nil {
 		return nil
 	}
@@ -60,6 +191,9 @@ func (connWriter *connWriter) Close() error {
 }
 
 func (connWriter *connWriter) Write(bytes []byte) (n int, err error) {
+	if connWriter.managed {
+		return connWriter.writeManaged(bytes)
+	}
 	if connWriter.neddedConnectOnMsg() {
 		err = connWriter.connect()
 		if err != nil {
@@ -71,6 +205,9 @@ func (connWriter *connWriter) Write(bytes []byte) (n int, err error) {
 		defer connWriter.innerWriter.Close()
 	}
 
+	if conn, ok := connWriter.innerWriter.(net.Conn); ok && connWriter.writeTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(connWriter.writeTimeout))
+	}
 	n, err = connWriter.innerWriter.Write(bytes)
 	if err != nil {
 		connWriter.recconect = true
@@ -89,6 +226,17 @@ func (connWriter *connWriter) connect() error {
 		connWriter.innerWriter = nil
 	}
 
+	if connWriter.tlsConfig != nil {
+		// net.Dialer keeps tcp connections alive by default
+		conn, err := tls.DialWithDialer(&net.Dialer{}, connWriter.net, connWriter.addr, connWriter.tlsConfig)
+		if err != nil {
+			return err
+		}
+		connWriter.innerWriter = conn
+
+		return nil
+	}
+
 	conn, err := net.Dial(connWriter.net, connWriter.addr)
 	if err != nil {
 		return err
@@ -116,3 +264,265 @@ func (connWriter *connWriter) neddedConnectOnMsg() bool {
 
 	return connWriter.reconnectOnMsg
 }
+
+func (connWriter *connWriter) writeManaged(bytes []byte) (int, error) {
+	connWriter.mutex.Lock()
+	defer connWriter.mutex.Unlock()
+
+	if connWriter.closed {
+		return 0, errors.New("Conn writer is closed")
+	}
+	connWriter.kept = append(connWriter.kept, append([]byte(nil), bytes...))
+	connWriter.keptSize += len(bytes)
+
+	err := connWriter.send()
+	if keepErr := connWriter.keep(); err == nil {
+		err = keepErr
+	}
+	for connWriter.overflow == connOverflowBlock && connWriter.keptSize > connWriter.bufferSize && !connWriter.closed {
+		connWriter.waitRetry()
+		if connWriter.closed {
+			break
+		}
+		err = connWriter.send()
+		if keepErr := connWriter.keep(); err == nil {
+			err = keepErr
+		}
+	}
+	return len(bytes), err
+}
+
+// waitRetry releases the writer until the next dial is due, or for the reconnect interval
+// if a dial would be due already, so that a blocked write does not spin.
+func (connWriter *connWriter) waitRetry() {
+	wait := time.Until(connWriter.retryAt)
+	if wait < connWriter.reconnect {
+		wait = connWriter.reconnect
+	}
+	connWriter.mutex.Unlock()
+	time.Sleep(wait)
+	connWriter.mutex.Lock()
+}
+
+// send writes the messages in the buffer file, then the ones kept in memory. A write
+// failing on an established connection is tried again once on a new one, as the
+// connection may be stale.
+func (connWriter *connWriter) send() error {
+	established := connWriter.conn != nil
+	err := connWriter.sendKept()
+	if err != nil && established {
+		err = connWriter.sendKept()
+	}
+	if connWriter.reconnectOnMsg {
+		connWriter.disconnect()
+	}
+	if err != nil {
+		connWriter.scheduleRetry()
+	}
+	return err
+}
+
+func (connWriter *connWriter) sendKept() error {
+	if len(connWriter.kept) == 0 && !connWriter.hasBufferFile() {
+		return nil
+	}
+	if connWriter.conn == nil {
+		if time.Now().Before(connWriter.retryAt) {
+			return nil
+		}
+		if err := connWriter.connectManaged(); err != nil {
+			connWriter.retryAt = time.Now().Add(connWriter.backoff)
+			connWriter.backoff *= 2
+			if connWriter.backoff > connWriter.maxReconnect {
+				connWriter.backoff = connWriter.maxReconnect
+			}
+			return err
+		}
+		connWriter.backoff = connWriter.reconnect
+	}
+
+	if connWriter.hasBufferFile() {
+		records, readErr := readRecordsFile(connWriter.bufferPath)
+		os.Remove(connWriter.bufferPath)
+		for i, record := range records {
+			if err := connWriter.writeRecord(record); err != nil {
+				// Kept for the next attempt
+				appendRecordsFile(connWriter.bufferPath, connWriter.bufferFileSize, records[i:])
+				return err
+			}
+		}
+		if readErr != nil {
+			reportInternalError(readErr)
+		}
+	}
+
+	for len(connWriter.kept) > 0 {
+		if err := connWriter.writeRecord(connWriter.kept[0]); err != nil {
+			return err
+		}
+		connWriter.keptSize -= len(connWriter.kept[0])
+		connWriter.kept[0] = nil
+		connWriter.kept = connWriter.kept[1:]
+	}
+	connWriter.kept = nil
+	return nil
+}
+
+func (connWriter *connWriter) writeRecord(record []byte) error {
+	connWriter.conn.SetWriteDeadline(time.Now().Add(connWriter.writeTimeout))
+	if _, err := connWriter.conn.Write(record); err != nil {
+		connWriter.disconnect()
+		return err
+	}
+	return nil
+}
+
+func (connWriter *connWriter) hasBufferFile() bool {
+	if connWriter.bufferPath == "" {
+		return false
+	}
+	_, err := os.Stat(connWriter.bufferPath)
+	return err == nil
+}
+
+// keep moves the oldest messages kept in memory beyond bufferSize to the buffer file, or
+// else applies the overflow policy, and reports the messages dropped while disconnected
+// once it is back. With the block policy the messages stay in memory for the caller to
+// wait on.
+func (connWriter *connWriter) keep() error {
+	var overflow [][]byte
+	overflowSize := 0
+	for connWriter.keptSize > connWriter.bufferSize {
+		overflow = append(overflow, connWriter.kept[0])
+		overflowSize += len(connWriter.kept[0])
+		connWriter.keptSize -= len(connWriter.kept[0])
+		connWriter.kept[0] = nil
+		connWriter.kept = connWriter.kept[1:]
+	}
+	if len(overflow) > 0 && (connWriter.bufferPath == "" ||
+		appendRecordsFile(connWriter.bufferPath, connWriter.bufferFileSize, overflow) != nil) {
+		switch connWriter.overflow {
+		case connOverflowDropOldest:
+			connWriter.dropped += len(overflow)
+		case connOverflowDropNewest:
+			connWriter.kept = append(overflow, connWriter.kept...)
+			connWriter.keptSize += overflowSize
+			for connWriter.keptSize > connWriter.bufferSize {
+				last := len(connWriter.kept) - 1
+				connWriter.keptSize -= len(connWriter.kept[last])
+				connWriter.kept[last] = nil
+				connWriter.kept = connWriter.kept[:last]
+				connWriter.dropped++
+			}
+		case connOverflowBlock:
+			connWriter.kept = append(overflow, connWriter.kept...)
+			connWriter.keptSize += overflowSize
+		}
+	}
+
+	if connWriter.dropped > 0 && connWriter.conn != nil {
+		dropped := connWriter.dropped
+		connWriter.dropped = 0
+		return fmt.Errorf("Dropped %d messages while %s was unreachable", dropped, connWriter.addr)
+	}
+	return nil
+}
+
+// scheduleRetry sends the kept messages when the connection may be back, so that they
+// are not held until the next message.
+func (connWriter *connWriter) scheduleRetry() {
+	if connWriter.timer != nil || connWriter.closed {
+		return
+	}
+	connWriter.timer = time.AfterFunc(time.Until(connWriter.retryAt), connWriter.retryOnTimer)
+}
+
+func (connWriter *connWriter) retryOnTimer() {
+	connWriter.mutex.Lock()
+	defer connWriter.mutex.Unlock()
+
+	connWriter.timer = nil
+	if connWriter.closed {
+		return
+	}
+	err := connWriter.send()
+	if keepErr := connWriter.keep(); err == nil {
+		err = keepErr
+	}
+	if err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (connWriter *connWriter) connectManaged() error {
+	dialer := &net.Dialer{Timeout: connWriter.timeout}
+
+	var conn net.Conn
+	var err error
+	if connWriter.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, connWriter.net, connWriter.addr, connWriter.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(connWriter.net, connWriter.addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	connWriter.conn = conn
+	return nil
+}
+
+func (connWriter *connWriter) disconnect() {
+	if connWriter.conn != nil {
+		connWriter.conn.Close()
+		connWriter.conn = nil
+	}
+}
+
+// Flush sends the kept messages, if the remote end is reachable again.
+func (connWriter *connWriter) Flush() {
+	if !connWriter.managed {
+		return
+	}
+	connWriter.mutex.Lock()
+	defer connWriter.mutex.Unlock()
+
+	err := connWriter.send()
+	if keepErr := connWriter.keep(); err == nil {
+		err = keepErr
+	}
+	if err != nil {
+		reportInternalError(err)
+	}
+}
+
+// closeManaged makes a last attempt to send the kept messages and closes the connection.
+// Messages in memory which can not be sent are moved to the buffer file, if any, for the
+// next run.
+func (connWriter *connWriter) closeManaged() error {
+	connWriter.mutex.Lock()
+	defer connWriter.mutex.Unlock()
+
+	if connWriter.closed {
+		return nil
+	}
+	connWriter.closed = true
+	if connWriter.timer != nil {
+		connWriter.timer.Stop()
+		connWriter.timer = nil
+	}
+
+	connWriter.retryAt = time.Time{}
+	err := connWriter.sendKept()
+	connWriter.disconnect()
+	// Messages saved to the buffer file are sent by the next run, so they are not lost
+	if len(connWriter.kept) > 0 && connWriter.bufferPath != "" &&
+		appendRecordsFile(connWriter.bufferPath, connWriter.bufferFileSize, connWriter.kept) == nil {
+		connWriter.kept = nil
+		err = nil
+	}
+	if undelivered := len(connWriter.kept) + connWriter.dropped; undelivered > 0 {
+		err = fmt.Errorf("Conn output to %s closed with %d undelivered messages", connWriter.addr, undelivered)
+	}
+	return err
+}
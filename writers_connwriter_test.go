@@ -0,0 +1,372 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCertificate returns a self-signed certificate for the given DNS name, usable by
+// servers and clients, and writes it and its key to PEM files in a new directory.
+func newTestCertificate(t *testing.T, name string) (certificate tls.Certificate, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, certFile, keyFile
+}
+
+func TestConnMutualTLS(t *testing.T) {
+	serverCert, serverCertFile, _ := newTestCertificate(t, "collector.test")
+	_, clientCertFile, clientKeyFile := newTestCertificate(t, "client.test")
+	clientCAs := x509.NewCertPool()
+	clientPEM, _ := ioutil.ReadFile(clientCertFile)
+	clientCAs.AppendCertsFromPEM(clientPEM)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+	}()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<conn net="tcp" addr="`+listener.Addr().String()+`" tls="true"
+		servername="collector.test" cacert="`+serverCertFile+`" cert="`+clientCertFile+`" key="`+clientKeyFile+`"/>`})
+	defer logger.Close()
+	logger.Info("hello")
+
+	select {
+	case line := <-lines:
+		if line != "hello\n" {
+			t.Errorf("Expected hello, got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a message over TLS")
+	}
+}
+
+// unusedTestAddr returns a local tcp address nothing listens on.
+func unusedTestAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+// readConnTest accepts one connection on listener and reads from it until it has read
+// expected.
+func readConnTest(t *testing.T, listener net.Listener, expected string) {
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, len(expected))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != expected {
+		t.Errorf("Expected %q, got %q: %v", expected, buf, err)
+	}
+}
+
+func TestConnReconnectsWithBackoff(t *testing.T) {
+	addr := unusedTestAddr(t)
+	writer, err := newConnWriterWithOptions("tcp", addr, false, connWriterOptions{
+		reconnect:  20 * time.Millisecond,
+		bufferSize: 1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	if _, err := writer.Write([]byte("first\n")); err == nil {
+		t.Error("Expected the failed dial to be reported")
+	}
+	// Not dialed again before the backoff
+	if _, err := writer.Write([]byte("second\n")); err != nil {
+		t.Error(err)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// Sent in the background once the remote end is back
+	readConnTest(t, listener, "first\nsecond\n")
+}
+
+func TestConnBufferFile(t *testing.T) {
+	addr := unusedTestAddr(t)
+	options := connWriterOptions{
+		reconnect:  time.Hour,
+		bufferSize: 7,
+		bufferPath: filepath.Join(t.TempDir(), "conn.buf"),
+	}
+	writer, err := newConnWriterWithOptions("tcp", addr, false, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"first\n", "second\n", "third\n"} {
+		writer.Write([]byte(message))
+	}
+	// The message in memory is moved to the file on close
+	if err := writer.Close(); err != nil {
+		t.Error(err)
+	}
+	if records, err := readRecordsFile(options.bufferPath); err != nil || len(records) != 3 {
+		t.Fatalf("Expected three messages in the buffer file, got %q: %v", records, err)
+	}
+
+	// Sent by the writer of the next run
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	writer, err = newConnWriterWithOptions("tcp", addr, false, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	writer.Flush()
+	readConnTest(t, listener, "first\nsecond\nthird\n")
+}
+
+func TestConnDropsBeyondBuffer(t *testing.T) {
+	addr := unusedTestAddr(t)
+	writer, err := newConnWriterWithOptions("tcp", addr, false, connWriterOptions{
+		reconnect:  time.Hour,
+		bufferSize: 14,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"first\n", "second\n", "third\n"} {
+		writer.Write([]byte(message))
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	writer.retryAt = time.Time{}
+	_, err = writer.Write([]byte("fourth\n"))
+	if err == nil || !strings.Contains(err.Error(), "Dropped 1 messages") {
+		t.Errorf("Expected the dropped message to be reported, got %v", err)
+	}
+	readConnTest(t, listener, "second\nthird\nfourth\n")
+	if err := writer.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConnWriteTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// Accepts, but never reads
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	writer, err := newConnWriterWithOptions("tcp", listener.Addr().String(), false, connWriterOptions{
+		writeTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	start := time.Now()
+	_, err = writer.Write(make([]byte, 64<<20))
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Write was held for %v", elapsed)
+	}
+}
+
+func TestConnOverflowDropNewest(t *testing.T) {
+	addr := unusedTestAddr(t)
+	writer, err := newConnWriterWithOptions("tcp", addr, false, connWriterOptions{
+		reconnect:  time.Hour,
+		bufferSize: 14,
+		overflow:   connOverflowDropNewest,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"first\n", "second\n", "third\n"} {
+		writer.Write([]byte(message))
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	writer.retryAt = time.Time{}
+	_, err = writer.Write([]byte("fourth\n"))
+	if err == nil || !strings.Contains(err.Error(), "Dropped 1 messages") {
+		t.Errorf("Expected the dropped message to be reported, got %v", err)
+	}
+	readConnTest(t, listener, "first\nsecond\nfourth\n")
+	if err := writer.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConnOverflowBlock(t *testing.T) {
+	addr := unusedTestAddr(t)
+	writer, err := newConnWriterWithOptions("tcp", addr, false, connWriterOptions{
+		reconnect:  20 * time.Millisecond,
+		bufferSize: 7,
+		overflow:   connOverflowBlock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	writer.Write([]byte("first\n"))
+
+	written := make(chan struct{})
+	go func() {
+		writer.Write([]byte("second\n"))
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("Expected the write to wait for the buffer")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	readConnTest(t, listener, "first\nsecond\n")
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the write to return once the messages were sent")
+	}
+}
+
+func TestConnTLSConfig(t *testing.T) {
+	_, certFile, keyFile := newTestCertificate(t, "client.test")
+	invalid := []string{
+		`<conn net="tcp" addr="localhost:6514" cacert="` + certFile + `"/>`,
+		`<conn net="tcp" addr="localhost:6514" tls="yes"/>`,
+		`<conn net="tcp" addr="localhost:6514" tls="true" cert="` + certFile + `"/>`,
+		`<conn net="tcp" addr="localhost:6514" tls="true" cert="` + certFile + `" key="` + certFile + `"/>`,
+		`<conn net="tcp" addr="localhost:6514" tls="true" cacert="` + keyFile + `"/>`,
+		`<conn net="tcp" addr="localhost:6514" tls="true" cacert="/nonexistent/ca.pem"/>`,
+		`<conn net="tcp" addr="localhost" tls="true"/>`,
+		`<conn net="tcp" addr="localhost:5000" reconnect="soon"/>`,
+		`<conn net="tcp" addr="localhost:5000" buffersize="-1"/>`,
+		`<conn net="tcp" addr="localhost:5000" bufferpath="conn.buf" bufferfilesize="big"/>`,
+		`<conn net="tcp" addr="localhost:5000" writetimeout="never"/>`,
+		`<conn net="tcp" addr="localhost:5000" overflow="dropall"/>`,
+	}
+	for _, conn := range invalid {
+		config := `<seelog><outputs>` + conn + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
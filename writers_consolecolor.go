@@ -0,0 +1,69 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	consoleColorsOnce      sync.Once
+	consoleColorsSupported bool
+)
+
+// consoleSupportsColors returns true if the console shows the ANSI escape sequences
+// written by %LevelColor and %EscM as colors. On Windows virtual terminal processing is
+// enabled for the console on the first call; consoles which do not support it would
+// print the sequences as text, so the console writer strips them instead.
+func consoleSupportsColors() bool {
+	consoleColorsOnce.Do(func() {
+		consoleColorsSupported = enableConsoleColors(os.Stdout)
+	})
+	return consoleColorsSupported
+}
+
+// stripANSIEscapes removes ANSI control sequences (ESC '[' parameters final-byte) from data.
+func stripANSIEscapes(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0x1b || i+1 >= len(data) || data[i+1] != '[' {
+			result = append(result, data[i])
+			continue
+		}
+
+		// Parameter and intermediate bytes are 0x20-0x3f, the final byte is 0x40-0x7e
+		j := i + 2
+		for j < len(data) && data[j] >= 0x20 && data[j] <= 0x3f {
+			j++
+		}
+		if j < len(data) && data[j] >= 0x40 && data[j] <= 0x7e {
+			i = j
+			continue
+		}
+		result = append(result, data[i])
+	}
+	return result
+}
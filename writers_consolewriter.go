@@ -39,6 +39,10 @@ func newConsoleWriter() (writer *consoleWriter, err error) {
 
 // Create folder and file on WriteLog/Write first call
 func (console *consoleWriter) Write(bytes []byte) (int, error) {
+	if !consoleSupportsColors() {
+		_, err := fmt.Print(string(stripANSIEscapes(bytes)))
+		return len(bytes), err
+	}
 	return fmt.Print(string(bytes))
 }
 
@@ -0,0 +1,211 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	defaultDatadogSite     = "datadoghq.com"
+	defaultDatadogSource   = "go"
+	defaultDatadogMaxCount = 1000
+	defaultDatadogMaxSize  = 4 << 20
+	datadogMaxCount        = 1000
+	datadogMaxSize         = 5 << 20
+	datadogMessageKey      = "message"
+	datadogStatusKey       = "status"
+	datadogTimestampKey    = "timestamp"
+	datadogSourceKey       = "ddsource"
+	datadogTagsKey         = "ddtags"
+	datadogServiceKey      = "service"
+	datadogHostnameKey     = "hostname"
+)
+
+// datadogWriter sends messages to the Datadog logs intake (v2). Every message is a log
+// with the formatted message, its level as status, the call time in milliseconds and
+// the fields of the message as attributes. ddsource, service and hostname come from the
+// settings, unless the message has fields with these keys; a ddtags field is added to
+// the tags of the settings.
+//
+// Logs are sent by an httpWriter, in JSON array batches, gzipped at first, with its
+// retries and buffering; Datadog answers 429 with Retry-After, which the retries wait for.
+type datadogWriter struct {
+	formatter *formatter
+	source    string
+	tags      string
+	service   string
+	hostname  string
+	http      *httpWriter
+}
+
+// datadogWriterOptions are the settings of a datadog receiver.
+type datadogWriterOptions struct {
+	site     string
+	url      string
+	source   string
+	tags     string
+	service  string
+	hostname string
+}
+
+func newDatadogWriter(formatter *formatter, apiKey string, options datadogWriterOptions,
+	httpOptions httpWriterOptions) (*datadogWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if apiKey == "" {
+		return nil, errors.New("Datadog API key can not be empty")
+	}
+	if options.site == "" {
+		options.site = defaultDatadogSite
+	}
+	if options.url == "" {
+		options.url = "https://http-intake.logs." + options.site + "/api/v2/logs"
+	}
+	if options.source == "" {
+		options.source = defaultDatadogSource
+	}
+	if options.hostname == "" {
+		options.hostname, _ = os.Hostname()
+	}
+	if httpOptions.maxCount == 0 {
+		httpOptions.maxCount = defaultDatadogMaxCount
+	}
+	if httpOptions.maxSize == 0 {
+		httpOptions.maxSize = defaultDatadogMaxSize
+	}
+	if httpOptions.maxCount > datadogMaxCount || httpOptions.maxSize > datadogMaxSize {
+		return nil, fmt.Errorf("Datadog batches can have at most %d logs and %d bytes", datadogMaxCount, datadogMaxSize)
+	}
+
+	httpOptions.batch = httpBatchArray
+	httpOptions.contentType = "application/json"
+	if httpOptions.headers == nil {
+		httpOptions.headers = make(http.Header)
+	}
+	httpOptions.headers.Set("DD-API-KEY", apiKey)
+	httpWriter, err := newHttpWriter(options.url, httpOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &datadogWriter{
+		formatter: formatter,
+		source:    options.source,
+		tags:      options.tags,
+		service:   options.service,
+		hostname:  options.hostname,
+		http:      httpWriter,
+	}, nil
+}
+
+func (writer *datadogWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	settings := map[string]string{
+		datadogSourceKey:   writer.source,
+		datadogTagsKey:     writer.tags,
+		datadogServiceKey:  writer.service,
+		datadogHostnameKey: writer.hostname,
+	}
+	var attributes []Field
+	for _, field := range context.Fields() {
+		switch field.Key {
+		case datadogSourceKey, datadogServiceKey, datadogHostnameKey:
+			if value := FieldValueString(field.Value); value != "" {
+				settings[field.Key] = value
+			}
+		case datadogTagsKey:
+			if value := FieldValueString(field.Value); value != "" && writer.tags != "" {
+				settings[field.Key] = writer.tags + "," + value
+			} else if value != "" {
+				settings[field.Key] = value
+			}
+		case datadogMessageKey, datadogStatusKey, datadogTimestampKey:
+		default:
+			attributes = append(attributes, field)
+		}
+	}
+
+	log := new(bytes.Buffer)
+	log.WriteString(`{"` + datadogMessageKey + `":`)
+	writeJsonString(log, strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n"))
+	log.WriteString(`,"` + datadogStatusKey + `":`)
+	writeJsonString(log, datadogStatus(level))
+	log.WriteString(fmt.Sprintf(`,"%s":%d`, datadogTimestampKey, context.CallTime().UnixNano()/1e6))
+	for _, key := range []string{datadogSourceKey, datadogTagsKey, datadogServiceKey, datadogHostnameKey} {
+		if settings[key] != "" {
+			log.WriteString(`,"` + key + `":`)
+			writeJsonString(log, settings[key])
+		}
+	}
+	writeJsonFields(log, attributes, true)
+	log.WriteByte('}')
+
+	if _, err := writer.http.Write(log.Bytes()); err != nil {
+		errorFunc(err)
+	}
+}
+
+// datadogStatus returns the Datadog status of a level.
+func datadogStatus(level LogLevel) string {
+	switch level {
+	case TraceLvl, DebugLvl:
+		return "debug"
+	case InfoLvl:
+		return "info"
+	case WarnLvl:
+		return "warn"
+	case ErrorLvl:
+		return "error"
+	case CriticalLvl:
+		return "critical"
+	}
+	return level.String()
+}
+
+func (writer *datadogWriter) Flush() {
+	writer.http.Flush()
+}
+
+func (writer *datadogWriter) Close() error {
+	return writer.http.Close()
+}
+
+func (writer *datadogWriter) String() string {
+	return fmt.Sprintf("datadogWriter: [%s, %s], format: %s\n", writer.service, writer.http, writer.formatter)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDatadogLogs(t *testing.T) {
+	server := newHttpTestServer(http.StatusTooManyRequests)
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<datadog formatid="msg" apikey="abc" url="`+server.URL+`/api/v2/logs" service="billing"
+		tags="env:prod" hostname="web-1" maxcount="2" backoff="1ms"/>`})
+	logger.Infow("paid", Int("amount", 10), Str("ddtags", "tenant:acme"), Str("service", "payments"))
+	logger.Warn("slow")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(requests) != 2 || len(bodies) != 1 {
+		t.Fatalf("Expected a batch and a retry, got %q", bodies)
+	}
+	request := requests[1]
+	if request.Header.Get("DD-API-KEY") != "abc" || request.Header.Get("Content-Encoding") != "gzip" ||
+		request.URL.Path != "/api/v2/logs" {
+		t.Errorf("Unexpected request: %s %v", request.URL, request.Header)
+	}
+
+	var logs []map[string]interface{}
+	if err := json.Unmarshal([]byte(bodies[0]), &logs); err != nil {
+		t.Fatalf("Expected a JSON array, got %s: %s", bodies[0], err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("Expected two logs, got %v", logs)
+	}
+	first := logs[0]
+	if first["message"] != "paid" || first["status"] != "info" || first["amount"] != float64(10) ||
+		first["ddsource"] != "go" || first["ddtags"] != "env:prod,tenant:acme" || first["service"] != "payments" ||
+		first["hostname"] != "web-1" {
+		t.Errorf("Unexpected log: %v", first)
+	}
+	if _, ok := first["timestamp"].(float64); !ok {
+		t.Errorf("Expected a timestamp, got %v", first)
+	}
+	second := logs[1]
+	if second["status"] != "warn" || second["ddtags"] != "env:prod" || second["service"] != "billing" {
+		t.Errorf("Unexpected log: %v", second)
+	}
+}
+
+func TestDatadogConfig(t *testing.T) {
+	invalid := []string{
+		`<datadog/>`,
+		`<datadog apikey="abc" maxcount="5000"/>`,
+		`<datadog apikey="abc" url="intake.local"/>`,
+		`<datadog apikey="abc" compression="brotli"/>`,
+		`<datadog apikey="abc" batch="ndjson"/>`,
+		`<datadog apikey="abc"><header name="a" value="b"/></datadog>`,
+	}
+	for _, datadog := range invalid {
+		config := `<seelog><outputs>` + datadog + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
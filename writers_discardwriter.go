@@ -0,0 +1,160 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DiscardCounts holds the numbers of messages a discard receiver dropped per level,
+// and the number of bytes they were formatted to.
+type DiscardCounts struct {
+	Levels map[LogLevel]uint64
+	Bytes  uint64
+}
+
+// Total returns the number of all dropped messages.
+func (counts DiscardCounts) Total() uint64 {
+	var total uint64
+	for _, count := range counts.Levels {
+		total += count
+	}
+	return total
+}
+
+// discardCounter holds the counts of one discard name. Like the slo counters they are
+// kept for the whole life of the process, so counts survive logger replacement.
+type discardCounter struct {
+	levels [256]uint64
+	bytes  uint64
+}
+
+var (
+	discardCountersMutex sync.Mutex
+	discardCounters      = make(map[string]*discardCounter)
+)
+
+func getDiscardCounter(name string) *discardCounter {
+	discardCountersMutex.Lock()
+	defer discardCountersMutex.Unlock()
+
+	counter, ok := discardCounters[name]
+	if !ok {
+		counter = new(discardCounter)
+		discardCounters[name] = counter
+	}
+	return counter
+}
+
+// GetDiscardCounts returns the counts of the discard receiver with the given name.
+func GetDiscardCounts(name string) (DiscardCounts, bool) {
+	return loadDiscardCounts(name, atomic.LoadUint64)
+}
+
+// ResetDiscardCounts returns the counts of the discard receiver with the given name and
+// starts counting from zero, for example between benchmark runs.
+func ResetDiscardCounts(name string) (DiscardCounts, bool) {
+	return loadDiscardCounts(name, func(count *uint64) uint64 {
+		return atomic.SwapUint64(count, 0)
+	})
+}
+
+func loadDiscardCounts(name string, load func(*uint64) uint64) (DiscardCounts, bool) {
+	discardCountersMutex.Lock()
+	counter, ok := discardCounters[name]
+	discardCountersMutex.Unlock()
+	if !ok {
+		return DiscardCounts{}, false
+	}
+
+	counts := DiscardCounts{Levels: make(map[LogLevel]uint64), Bytes: load(&counter.bytes)}
+	for level := range counter.levels {
+		if count := load(&counter.levels[level]); count > 0 {
+			counts.Levels[LogLevel(level)] = count
+		}
+	}
+	return counts, true
+}
+
+// DiscardNames returns the sorted names of all discard receivers created so far.
+func DiscardNames() []string {
+	discardCountersMutex.Lock()
+	defer discardCountersMutex.Unlock()
+
+	names := make([]string, 0, len(discardCounters))
+	for name := range discardCounters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// discardWriter drops messages, counting them per level in the counter of its name.
+// Messages are formatted all the same, and the bytes counted, so that the receiver
+// measures the cost of formats and constraints without the cost of any output.
+type discardWriter struct {
+	name      string
+	formatter *formatter
+	counter   *discardCounter
+}
+
+func newDiscardWriter(name string, formatter *formatter) (*discardWriter, error) {
+	if name == "" {
+		return nil, errors.New("Discard receiver name can not be empty")
+	}
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	return &discardWriter{name: name, formatter: formatter, counter: getDiscardCounter(name)}, nil
+}
+
+func (writer *discardWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	text := writer.formatter.Format(message, level, context)
+	atomic.AddUint64(&writer.counter.levels[level], 1)
+	atomic.AddUint64(&writer.counter.bytes, uint64(len(text)))
+}
+
+func (writer *discardWriter) Flush() {
+}
+
+func (writer *discardWriter) Close() error {
+	return nil
+}
+
+func (writer *discardWriter) String() string {
+	return fmt.Sprintf("discardWriter: %s, format: %s\n", writer.name, writer.formatter)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"testing"
+)
+
+// removeDiscardCounter removes the counts of a discard receiver, so that the next receiver
+// with the name counts from zero.
+func removeDiscardCounter(name string) {
+	discardCountersMutex.Lock()
+	defer discardCountersMutex.Unlock()
+	delete(discardCounters, name)
+}
+
+func TestDiscardCounts(t *testing.T) {
+	t.Cleanup(func() { removeDiscardCounter("test-discard") })
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<discard name="test-discard" formatid="msg"/>`})
+	logger.Info("first")
+	logger.Info("second")
+	logger.Error("third")
+	logger.Flush()
+
+	counts, ok := GetDiscardCounts("test-discard")
+	if !ok {
+		t.Fatal("Expected the discard counter to exist")
+	}
+	if counts.Levels[InfoLvl] != 2 || counts.Levels[ErrorLvl] != 1 || counts.Total() != 3 ||
+		counts.Bytes != uint64(len("first\nsecond\nthird\n")) {
+		t.Errorf("Unexpected counts: %+v", counts)
+	}
+
+	if reset, _ := ResetDiscardCounts("test-discard"); reset.Total() != 3 {
+		t.Errorf("Expected the counts before the reset, got %+v", reset)
+	}
+	logger.Warn("fourth")
+	logger.Close()
+	if counts, _ = GetDiscardCounts("test-discard"); counts.Total() != 1 || counts.Levels[WarnLvl] != 1 {
+		t.Errorf("Expected counting to start over, got %+v", counts)
+	}
+
+	found := false
+	for _, name := range DiscardNames() {
+		found = found || name == "test-discard"
+	}
+	if !found {
+		t.Errorf("Expected test-discard in %v", DiscardNames())
+	}
+	if _, ok := GetDiscardCounts("test-discard-missing"); ok {
+		t.Error("Expected no counts for an unknown name")
+	}
+}
+
+func TestDiscardConfig(t *testing.T) {
+	invalid := []string{
+		`<discard name=""/>`,
+		`<discard addr="127.0.0.1:1"/>`,
+		`<discard><file path="a.log"/></discard>`,
+	}
+	for _, discard := range invalid {
+		config := `<seelog><outputs>` + discard + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
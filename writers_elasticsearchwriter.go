@@ -0,0 +1,361 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	elasticsearchTimestampKey        = "@timestamp"
+	elasticsearchMessageKey          = "message"
+	elasticsearchLevelKey            = "level"
+	defaultElasticsearchIndex        = "logs-%Date"
+	defaultElasticsearchMaxCount     = 500
+	defaultElasticsearchMaxSize      = 5 << 20
+	defaultElasticsearchMaxInterval  = 5 * time.Second
+	defaultElasticsearchRetries      = 3
+	defaultElasticsearchBackoff      = 500 * time.Millisecond
+	elasticsearchTooManyRequests     = http.StatusTooManyRequests
+	elasticsearchResponseBodyMaxSize = 1 << 20
+)
+
+// elasticsearchWriter indexes messages in Elasticsearch with the _bulk API. Every message
+// is a document with "@timestamp", the formatted message ("message"), the level ("level")
+// and the fields of the message, created in the index which the index format yields for
+// the message, e.g. "logs-%Date(2006.01.02)" for daily indices. The index format is not
+// replaced by formats which records set (see AsJSON).
+//
+// Documents are collected in batches, which are sent when they reach maxCount documents
+// or maxSize bytes, maxInterval after their first document, on Flush and on Close. A
+// batch which fails with 429 or 5xx, or does not reach the server, is sent again up to
+// retries times, waiting backoff, then twice as long and so on; documents rejected with
+// 429 are sent again the same way. Documents which still fail are dropped and reported.
+// Bulk requests are compressed as encoding chooses, with gzip at first in auto mode.
+type elasticsearchWriter struct {
+	formatter   *formatter
+	endpoint    *url.URL
+	index       *formatter
+	username    string
+	password    string
+	apiKey      string
+	encoding    *httpEncoding
+	maxCount    int
+	maxSize     int
+	maxInterval time.Duration
+	retries     int
+	backoff     time.Duration
+	client      *http.Client
+
+	mutex   sync.Mutex
+	batch   [][]byte // Action and document lines
+	size    int
+	started time.Time
+	timer   *time.Timer
+	closed  bool
+}
+
+func newElasticsearchWriter(formatter *formatter, endpoint, index, username, password, apiKey, compression string,
+	maxCount, maxSize int, maxInterval time.Duration, retries int, backoff time.Duration) (*elasticsearchWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" || endpointURL.Host == "" {
+		return nil, errors.New("Elasticsearch endpoint must be an http or https url: " + endpoint)
+	}
+	if index == "" {
+		index = defaultElasticsearchIndex
+	}
+	indexFormatter, err := newFormatter(index)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey != "" && username != "" {
+		return nil, errors.New("Elasticsearch api key and user name can not be used together")
+	}
+	encoding, err := newHttpEncoding(compression, httpCompressionGzip)
+	if err != nil {
+		return nil, err
+	}
+	if maxCount <= 0 {
+		return nil, fmt.Errorf("maxCount can not be less or equal to 0. Got: %d", maxCount)
+	}
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("maxSize can not be less or equal to 0. Got: %d", maxSize)
+	}
+	if maxInterval <= 0 {
+		return nil, fmt.Errorf("maxInterval can not be less or equal to 0. Got: %s", maxInterval)
+	}
+	if retries < 0 {
+		return nil, fmt.Errorf("retries can not be less than 0. Got: %d", retries)
+	}
+
+	return &elasticsearchWriter{
+		formatter:   formatter,
+		endpoint:    endpointURL,
+		index:       indexFormatter,
+		username:    username,
+		password:    password,
+		apiKey:      apiKey,
+		encoding:    encoding,
+		maxCount:    maxCount,
+		maxSize:     maxSize,
+		maxInterval: maxInterval,
+		retries:     retries,
+		backoff:     backoff,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (writer *elasticsearchWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	index := strings.TrimSpace(writer.index.formatOwn(message, level, context))
+	entry := writer.entry(index, writer.formatter.Format(message, level, context), level, context)
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("Elasticsearch writer is closed"))
+		return
+	}
+
+	if len(writer.batch) == 0 {
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.maxInterval, writer.sendOnTimer)
+	}
+	writer.batch = append(writer.batch, entry)
+	writer.size += len(entry)
+	if len(writer.batch) >= writer.maxCount || writer.size >= writer.maxSize {
+		if err := writer.sendBatch(); err != nil {
+			errorFunc(err)
+		}
+	}
+}
+
+// entry encodes a message as the action and document lines of a bulk request.
+func (writer *elasticsearchWriter) entry(index, text string, level LogLevel, context logContextInterface) []byte {
+	var documentFields []Field
+	for _, field := range context.Fields() {
+		switch field.Key {
+		case elasticsearchTimestampKey, elasticsearchMessageKey, elasticsearchLevelKey:
+		default:
+			documentFields = append(documentFields, field)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(`{"create":{"_index":`)
+	writeJsonString(buf, index)
+	buf.WriteString("}}\n")
+	buf.WriteString(`{"` + elasticsearchTimestampKey + `":`)
+	writeJsonString(buf, context.CallTime().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"` + elasticsearchMessageKey + `":`)
+	writeJsonString(buf, strings.TrimRight(text, "\r\n"))
+	buf.WriteString(`,"` + elasticsearchLevelKey + `":`)
+	writeJsonString(buf, level.String())
+	writeJsonFields(buf, documentFields, true)
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func (writer *elasticsearchWriter) sendOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if len(writer.batch) > 0 && time.Since(writer.started) >= writer.maxInterval {
+		if err := writer.sendBatch(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// sendBatch sends the collected documents, with retries, and starts a new batch.
+func (writer *elasticsearchWriter) sendBatch() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	batch := writer.batch
+	writer.batch = nil
+	writer.size = 0
+
+	dropped := 0
+	var lastErr error
+	for attempt := 0; len(batch) > 0; attempt++ {
+		if attempt > writer.retries {
+			dropped += len(batch)
+			break
+		}
+		if attempt > 0 {
+			time.Sleep(writer.backoff << uint(attempt-1))
+		}
+
+		var failed int
+		var err error
+		batch, failed, err = writer.bulk(batch)
+		dropped += failed
+		if err != nil {
+			lastErr = err
+		}
+	}
+	if dropped > 0 {
+		return fmt.Errorf("Cannot index %d messages in Elasticsearch: %s", dropped, lastErr)
+	}
+	return nil
+}
+
+// elasticsearchBulkResponse is the part of a bulk response which tells the failed documents.
+type elasticsearchBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// bulk sends a bulk request. It returns the entries which may succeed if sent again and
+// the number of entries which failed for good.
+func (writer *elasticsearchWriter) bulk(batch [][]byte) ([][]byte, int, error) {
+	bulkURL := *writer.endpoint
+	bulkURL.Path = strings.TrimRight(writer.endpoint.Path, "/") + "/_bulk"
+
+	var response *http.Response
+	for {
+		body, coding := writer.encoding.encode(bytes.Join(batch, nil))
+		request, err := http.NewRequest(http.MethodPost, bulkURL.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, len(batch), err
+		}
+		request.Header.Set("Content-Type", "application/x-ndjson")
+		if coding != httpCompressionNone {
+			request.Header.Set("Content-Encoding", coding)
+		}
+		if writer.apiKey != "" {
+			request.Header.Set("Authorization", "ApiKey "+writer.apiKey)
+		} else if writer.username != "" {
+			request.Header.Set("Authorization", basicAuthorization(writer.username, writer.password))
+		}
+
+		response, err = writer.client.Do(request)
+		if err != nil {
+			return batch, 0, err
+		}
+		if !writer.encoding.update(response, coding) {
+			break
+		}
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, elasticsearchResponseBodyMaxSize))
+	if response.StatusCode/100 != 2 {
+		if len(body) > 512 {
+			body = body[:512]
+		}
+		err := fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(body)))
+		if response.StatusCode == elasticsearchTooManyRequests || response.StatusCode/100 == 5 {
+			return batch, 0, err
+		}
+		return nil, len(batch), err
+	}
+
+	var bulkResponse elasticsearchBulkResponse
+	if err := json.Unmarshal(body, &bulkResponse); err != nil {
+		return nil, len(batch), fmt.Errorf("Cannot read bulk response: %s", err)
+	}
+	if !bulkResponse.Errors {
+		return nil, 0, nil
+	}
+
+	var rejected [][]byte
+	failed := 0
+	var firstErr error
+	for i, item := range bulkResponse.Items {
+		for _, result := range item {
+			if result.Error == nil || i >= len(batch) {
+				continue
+			}
+			if result.Status == elasticsearchTooManyRequests {
+				rejected = append(rejected, batch[i])
+			} else {
+				failed++
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %s", result.Error.Type, result.Error.Reason)
+			}
+		}
+	}
+	return rejected, failed, firstErr
+}
+
+func (writer *elasticsearchWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.sendBatch(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *elasticsearchWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.sendBatch()
+}
+
+func (writer *elasticsearchWriter) String() string {
+	return fmt.Sprintf("elasticsearchWriter: [%s, %s, %d, %d, %s], format: %s\n",
+		writer.endpoint, writer.index, writer.maxCount, writer.maxSize, writer.maxInterval, writer.formatter)
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// elasticsearchTestServer answers bulk requests with the given statuses, one per
+// request, and with success once they are used up. Item statuses of 429 reject the
+// documents at these positions of the first request.
+type elasticsearchTestServer struct {
+	*httptest.Server
+	statuses      []int
+	rejectedItems map[int]bool
+
+	mutex     sync.Mutex
+	requests  int
+	auth      []string
+	documents []map[string]interface{}
+	indices   []string
+}
+
+func newElasticsearchTestServer(statuses []int, rejectedItems map[int]bool) *elasticsearchTestServer {
+	server := &elasticsearchTestServer{statuses: statuses, rejectedItems: rejectedItems}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	return server
+}
+
+func (server *elasticsearchTestServer) serve(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.requests++
+	server.auth = append(server.auth, r.Header.Get("Authorization"))
+	if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	if len(server.statuses) > 0 {
+		status := server.statuses[0]
+		server.statuses = server.statuses[1:]
+		http.Error(w, "busy", status)
+		return
+	}
+
+	body, _ := readHttpTestBody(r)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	var items []string
+	errors := false
+	for i := 0; scanner.Scan(); i++ {
+		var action map[string]map[string]string
+		json.Unmarshal(scanner.Bytes(), &action)
+		scanner.Scan()
+		if server.rejectedItems[i] {
+			items = append(items, `{"create":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}`)
+			errors = true
+			continue
+		}
+		var document map[string]interface{}
+		json.Unmarshal(scanner.Bytes(), &document)
+		server.documents = append(server.documents, document)
+		server.indices = append(server.indices, action["create"]["_index"])
+		items = append(items, `{"create":{"status":201}}`)
+	}
+	server.rejectedItems = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	response, _ := json.Marshal(errors)
+	w.Write([]byte(`{"errors":` + string(response) + `,"items":[` + strings.Join(items, ",") + `]}`))
+}
+
+func TestElasticsearchBulk(t *testing.T) {
+	server := newElasticsearchTestServer(nil, nil)
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<elasticsearch endpoint="`+server.URL+`" index="logs-%Level-%Date(2006)"
+		username="elastic" password="secret" maxcount="2"/>`})
+	logger.Infow("first", Str("user", "bob"), Str("message", "shadowed"))
+	logger.Error("second")
+	logger.Info("third")
+
+	server.mutex.Lock()
+	if server.requests != 1 || len(server.documents) != 2 {
+		t.Errorf("Expected a batch of two documents, got %d requests, %v", server.requests, server.documents)
+	}
+	server.mutex.Unlock()
+
+	logger.Close()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.documents) != 3 {
+		t.Fatalf("Expected three documents, got %v", server.documents)
+	}
+	document := server.documents[0]
+	if document["message"] != "first" || document["level"] != "info" || document["user"] != "bob" {
+		t.Errorf("Unexpected document: %v", document)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, document["@timestamp"].(string)); err != nil {
+		t.Errorf("Unexpected timestamp: %v", document["@timestamp"])
+	}
+	year := time.Now().Format("2006")
+	if server.indices[0] != "logs-Info-"+year || server.indices[1] != "logs-Error-"+year {
+		t.Errorf("Unexpected indices: %v", server.indices)
+	}
+	if server.auth[0] != "Basic ZWxhc3RpYzpzZWNyZXQ=" {
+		t.Errorf("Unexpected authorization: %s", server.auth[0])
+	}
+}
+
+func TestElasticsearchRetry(t *testing.T) {
+	server := newElasticsearchTestServer([]int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		map[int]bool{1: true})
+	defer server.Close()
+
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newElasticsearchWriter(formatter, server.URL, "", "", "", "key", "", 10, 1<<20, time.Minute, 3, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"first", "second", "third"} {
+		writer.Dispatch(message, InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// 429, 503, the second document rejected, and the second document again
+	if server.requests != 4 {
+		t.Errorf("Expected 4 requests, got %d", server.requests)
+	}
+	var messages []string
+	for _, document := range server.documents {
+		messages = append(messages, document["message"].(string))
+	}
+	if strings.Join(messages, ",") != "first,third,second" {
+		t.Errorf("Unexpected messages: %v", messages)
+	}
+	if server.auth[0] != "ApiKey key" {
+		t.Errorf("Unexpected authorization: %s", server.auth[0])
+	}
+}
+
+func TestElasticsearchRetriesExhausted(t *testing.T) {
+	server := newElasticsearchTestServer([]int{500, 500, 500}, nil)
+	defer server.Close()
+
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newElasticsearchWriter(formatter, server.URL, "", "", "", "", "", 10, 1<<20, time.Minute, 2, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Dispatch("lost", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	err = writer.Close()
+	if err == nil || !strings.Contains(err.Error(), "Cannot index 1 messages") {
+		t.Errorf("Expected the dropped message to be reported, got %v", err)
+	}
+	if server.requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", server.requests)
+	}
+}
+
+func TestElasticsearchCompressionFallback(t *testing.T) {
+	server := newElasticsearchTestServer([]int{http.StatusUnsupportedMediaType}, nil)
+	defer server.Close()
+
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newElasticsearchWriter(formatter, server.URL, "", "", "", "", "", 10, 1<<20, time.Minute, 0, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Dispatch("plain", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// The gzipped request, answered with 415, and the same uncompressed
+	if server.requests != 2 || len(server.documents) != 1 || server.documents[0]["message"] != "plain" {
+		t.Errorf("Expected the document after 2 requests, got %d requests, %v", server.requests, server.documents)
+	}
+}
+
+func TestElasticsearchConfig(t *testing.T) {
+	invalid := []string{
+		`<elasticsearch/>`,
+		`<elasticsearch endpoint="es:9200"/>`,
+		`<elasticsearch endpoint="http://es:9200" maxcount="0"/>`,
+		`<elasticsearch endpoint="http://es:9200" backoff="later"/>`,
+		`<elasticsearch endpoint="http://es:9200" compression="lz4"/>`,
+		`<elasticsearch endpoint="http://es:9200" username="elastic" apikey="key"/>`,
+		`<elasticsearch endpoint="http://es:9200" index="logs-%Unknown"/>`,
+	}
+	for _, elasticsearch := range invalid {
+		config := `<seelog><outputs>` + elasticsearch + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
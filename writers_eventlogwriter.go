@@ -0,0 +1,142 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Windows event types.
+const (
+	eventLogError       = 0x0001
+	eventLogWarning     = 0x0002
+	eventLogInformation = 0x0004
+)
+
+// eventLogTypes maps levels to event types. Custom levels have the type of the built-in
+// level they are registered above.
+var eventLogTypes = map[LogLevel]uint16{
+	TraceLvl:    eventLogInformation,
+	DebugLvl:    eventLogInformation,
+	InfoLvl:     eventLogInformation,
+	WarnLvl:     eventLogWarning,
+	ErrorLvl:    eventLogError,
+	CriticalLvl: eventLogError,
+}
+
+func eventLogType(level LogLevel) uint16 {
+	if eventType, ok := eventLogTypes[level]; ok {
+		return eventType
+	}
+	if custom, ok := loadCustomLevels()[level]; ok {
+		return eventLogType(custom.above)
+	}
+	return eventLogInformation
+}
+
+// eventLogWriter reports messages to the Windows Event Log (the Application log) under
+// an event source, as Information, Warning or Error events depending on the level.
+type eventLogWriter struct {
+	formatter *formatter
+	source    string
+	eventId   uint32
+
+	mutex  sync.Mutex
+	handle uintptr
+}
+
+// newEventLogWriter opens the event source, the program name by default. With install
+// the source is registered first, so that the Event Viewer shows the messages without
+// complaining about a missing description; this needs administrator rights once.
+func newEventLogWriter(formatter *formatter, source string, eventId uint32, install bool) (*eventLogWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if source == "" {
+		source = strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	}
+	if eventId == 0 {
+		eventId = 1
+	}
+
+	if install {
+		if err := installEventSource(source); err != nil {
+			return nil, fmt.Errorf("Cannot register event source %s: %s", source, err)
+		}
+	}
+	handle, err := openEventSource(source)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open event source %s: %s", source, err)
+	}
+
+	return &eventLogWriter{formatter: formatter, source: source, eventId: eventId, handle: handle}, nil
+}
+
+func (writer *eventLogWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	text := strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n")
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.handle == 0 {
+		errorFunc(errors.New("Event source " + writer.source + " is closed"))
+		return
+	}
+	if err := reportEvent(writer.handle, eventLogType(level), writer.eventId, text); err != nil {
+		errorFunc(err)
+	}
+}
+
+func (writer *eventLogWriter) Flush() {
+}
+
+func (writer *eventLogWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.handle == 0 {
+		return nil
+	}
+	err := closeEventSource(writer.handle)
+	writer.handle = 0
+	return err
+}
+
+func (writer *eventLogWriter) String() string {
+	return fmt.Sprintf("eventLogWriter: [%s, %d], format: %s\n", writer.source, writer.eventId, writer.formatter)
+}
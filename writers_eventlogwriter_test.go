@@ -0,0 +1,68 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestEventLogType(t *testing.T) {
+	t.Cleanup(resetCustomLevels)
+	custom, err := RegisterLevel("eventlog_notice", WarnLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[LogLevel]uint16{
+		DebugLvl:    eventLogInformation,
+		InfoLvl:     eventLogInformation,
+		WarnLvl:     eventLogWarning,
+		custom:      eventLogWarning,
+		CriticalLvl: eventLogError,
+	}
+	for level, expected := range types {
+		if eventType := eventLogType(level); eventType != expected {
+			t.Errorf("Level %d: expected event type %d, got %d", level, expected, eventType)
+		}
+	}
+}
+
+func TestEventLogConfig(t *testing.T) {
+	invalid := []string{
+		`<eventlog eventid="0"/>`,
+		`<eventlog eventid="70000"/>`,
+		`<eventlog install="maybe"/>`,
+		`<eventlog><console/></eventlog>`,
+	}
+	if runtime.GOOS != "windows" {
+		invalid = append(invalid, `<eventlog source="seelog"/>`)
+	}
+	for _, eventlog := range invalid {
+		config := `<seelog><outputs>` + eventlog + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
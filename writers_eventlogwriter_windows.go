@@ -0,0 +1,119 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+//go:build windows
+// +build windows
+
+package seelog
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	registerEventSourceProc   = advapi32.NewProc("RegisterEventSourceW")
+	deregisterEventSourceProc = advapi32.NewProc("DeregisterEventSource")
+	reportEventProc           = advapi32.NewProc("ReportEventW")
+	regCreateKeyExProc        = advapi32.NewProc("RegCreateKeyExW")
+	regSetValueExProc         = advapi32.NewProc("RegSetValueExW")
+)
+
+const eventLogSourcesKey = `SYSTEM\CurrentControlSet\Services\EventLog\Application\`
+
+// installEventSource registers the source in the Application log with EventCreate.exe as
+// its message file, which formats events with ids 1 to 1000 as their only string.
+func installEventSource(source string) error {
+	keyPath, err := syscall.UTF16PtrFromString(eventLogSourcesKey + source)
+	if err != nil {
+		return err
+	}
+
+	var key syscall.Handle
+	r, _, _ := regCreateKeyExProc.Call(uintptr(syscall.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(keyPath)),
+		0, 0, 0, uintptr(syscall.KEY_WRITE), 0, uintptr(unsafe.Pointer(&key)), 0)
+	if r != 0 {
+		return syscall.Errno(r)
+	}
+	defer syscall.RegCloseKey(key)
+
+	messageFile, err := syscall.UTF16FromString(`%SystemRoot%\System32\EventCreate.exe`)
+	if err != nil {
+		return err
+	}
+	if err := setRegistryValue(key, "EventMessageFile", syscall.REG_EXPAND_SZ,
+		unsafe.Pointer(&messageFile[0]), len(messageFile)*2); err != nil {
+		return err
+	}
+	types := uint32(eventLogError | eventLogWarning | eventLogInformation)
+	return setRegistryValue(key, "TypesSupported", syscall.REG_DWORD, unsafe.Pointer(&types), 4)
+}
+
+func setRegistryValue(key syscall.Handle, name string, valueType uint32, data unsafe.Pointer, size int) error {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	r, _, _ := regSetValueExProc.Call(uintptr(key), uintptr(unsafe.Pointer(namePtr)), 0,
+		uintptr(valueType), uintptr(data), uintptr(size))
+	if r != 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+func openEventSource(source string) (uintptr, error) {
+	sourcePtr, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return 0, err
+	}
+	handle, _, err := registerEventSourceProc.Call(0, uintptr(unsafe.Pointer(sourcePtr)))
+	if handle == 0 {
+		return 0, err
+	}
+	return handle, nil
+}
+
+func reportEvent(handle uintptr, eventType uint16, eventId uint32, message string) error {
+	text, err := syscall.UTF16PtrFromString(message)
+	if err != nil {
+		return err
+	}
+	strings := [1]*uint16{text}
+	ok, _, err := reportEventProc.Call(handle, uintptr(eventType), 0, uintptr(eventId), 0, 1, 0,
+		uintptr(unsafe.Pointer(&strings[0])), 0)
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+func closeEventSource(handle uintptr) error {
+	ok, _, err := deregisterEventSourceProc.Call(handle)
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
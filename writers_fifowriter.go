@@ -0,0 +1,237 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFifoBufferSize = 1 << 20
+	defaultFifoRetry      = time.Second
+	defaultFifoTimeout    = 10 * time.Millisecond
+)
+
+// errFifoNoReader is returned by openFifo when no process has the pipe open for reading.
+var errFifoNoReader = errors.New("Named pipe has no reader")
+
+// fifoWriter writes messages to a named pipe without depending on a reader. The pipe is
+// opened without blocking, which fails while no process reads it; writes wait at most
+// timeout for room in the pipe. Messages which cannot be written are kept, up to
+// bufferSize bytes, and written when the pipe is opened again or has room, trying every
+// retry; once the buffer is full new messages are dropped and counted. When the reader
+// goes away the pipe is closed and opened again for the next one, which never gets the
+// rest of a message written in part.
+type fifoWriter struct {
+	path       string
+	create     bool
+	bufferSize int
+	retry      time.Duration
+	timeout    time.Duration
+
+	mutex    sync.Mutex
+	file     *os.File
+	pending  [][]byte
+	size     int
+	partial  bool // The first pending message was written in part
+	lastOpen time.Time
+	timer    *time.Timer
+	dropped  uint64
+	dropping bool
+	closed   bool
+}
+
+// fifoWriterOptions are the optional settings of a fifo receiver. Zero values are
+// replaced with the defaults.
+type fifoWriterOptions struct {
+	create     bool
+	bufferSize int
+	retry      time.Duration
+	timeout    time.Duration
+}
+
+func newFifoWriter(path string, options fifoWriterOptions) (*fifoWriter, error) {
+	if path == "" {
+		return nil, errors.New("Named pipe path can not be empty")
+	}
+	if options.bufferSize == 0 {
+		options.bufferSize = defaultFifoBufferSize
+	}
+	if options.retry == 0 {
+		options.retry = defaultFifoRetry
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultFifoTimeout
+	}
+	if options.bufferSize < 0 || options.retry < 0 || options.timeout < 0 {
+		return nil, errors.New("Named pipe receiver settings can not be negative")
+	}
+	if options.create {
+		if err := createFifo(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return &fifoWriter{
+		path:       path,
+		create:     options.create,
+		bufferSize: options.bufferSize,
+		retry:      options.retry,
+		timeout:    options.timeout,
+	}, nil
+}
+
+func (writer *fifoWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return 0, errors.New("Named pipe writer is closed")
+	}
+	if writer.size+len(data) > writer.bufferSize {
+		writer.dropped++
+		writer.writePending(false)
+		if writer.dropping {
+			return 0, nil
+		}
+		// Reported once until the buffer is written
+		writer.dropping = true
+		return 0, fmt.Errorf("Named pipe %s buffer is full, dropping messages until it has a reader", writer.path)
+	}
+	writer.pending = append(writer.pending, append([]byte(nil), data...))
+	writer.size += len(data)
+	writer.writePending(false)
+	return len(data), nil
+}
+
+// writePending writes the pending messages as far as the pipe takes them, opening it if
+// needed, at most every retry unless force is set. It schedules another try while
+// messages are pending.
+func (writer *fifoWriter) writePending(force bool) error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	err := writer.tryWritePending(force)
+	if len(writer.pending) > 0 && !writer.closed {
+		writer.timer = time.AfterFunc(writer.retry, writer.writeOnTimer)
+	}
+	return err
+}
+
+func (writer *fifoWriter) tryWritePending(force bool) error {
+	if len(writer.pending) == 0 {
+		return nil
+	}
+	if writer.file == nil {
+		if !force && time.Since(writer.lastOpen) < writer.retry {
+			return nil
+		}
+		writer.lastOpen = time.Now()
+		file, err := openFifo(writer.path)
+		if err != nil {
+			return err
+		}
+		writer.file = file
+		if writer.partial {
+			writer.size -= len(writer.pending[0])
+			writer.pending = writer.pending[1:]
+			writer.partial = false
+		}
+	}
+
+	for len(writer.pending) > 0 {
+		writer.file.SetWriteDeadline(time.Now().Add(writer.timeout))
+		n, err := writer.file.Write(writer.pending[0])
+		writer.size -= n
+		if n == len(writer.pending[0]) {
+			writer.pending[0] = nil
+			writer.pending = writer.pending[1:]
+			writer.partial = false
+		} else {
+			writer.pending[0] = writer.pending[0][n:]
+			writer.partial = writer.partial || n > 0
+		}
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			// The reader went away; the pipe is opened again for the next one
+			writer.file.Close()
+			writer.file = nil
+		}
+		return err
+	}
+	writer.dropping = false
+	return nil
+}
+
+func (writer *fifoWriter) writeOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.timer = nil
+	if !writer.closed {
+		writer.writePending(false)
+	}
+}
+
+// Flush writes the pending messages if a reader takes them.
+func (writer *fifoWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if !writer.closed {
+		writer.writePending(true)
+	}
+}
+
+func (writer *fifoWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	writer.writePending(true)
+	if writer.file != nil {
+		writer.file.Close()
+		writer.file = nil
+	}
+	if len(writer.pending) > 0 {
+		return fmt.Errorf("Named pipe %s closed with %d messages not written", writer.path, len(writer.pending))
+	}
+	return nil
+}
+
+func (writer *fifoWriter) String() string {
+	return fmt.Sprintf("fifoWriter: [%s, buffer %d, retry %s, dropped %d]", writer.path, writer.bufferSize,
+		writer.retry, writer.dropped)
+}
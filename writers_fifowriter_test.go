@@ -0,0 +1,143 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func openFifoTestReader(t *testing.T, path string) *os.File {
+	reader, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+func readFifoTest(t *testing.T, reader *os.File, expected string) {
+	buf := make([]byte, len(expected))
+	reader.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for read := 0; read < len(buf); {
+		n, err := reader.Read(buf[read:])
+		if err != nil {
+			t.Fatalf("Expected %q, read %q: %s", expected, buf[:read], err)
+		}
+		read += n
+	}
+	if string(buf) != expected {
+		t.Errorf("Expected %q, got %q", expected, buf)
+	}
+}
+
+func TestFifoReaderComesAndGoes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Named pipes are Unix only")
+	}
+	path := filepath.Join(t.TempDir(), "log.fifo")
+	writer, err := newFifoWriter(path, fifoWriterOptions{create: true, retry: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Kept while there is no reader
+	if _, err := writer.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	reader := openFifoTestReader(t, path)
+	writer.Flush()
+	readFifoTest(t, reader, "first\n")
+	writer.Write([]byte("second\n"))
+	readFifoTest(t, reader, "second\n")
+
+	// The reader goes away and another one comes
+	reader.Close()
+	writer.Write([]byte("third\n"))
+	if writer.Flush(); len(writer.pending) != 1 {
+		t.Fatalf("Expected the message to be kept, got %q", writer.pending)
+	}
+	reader = openFifoTestReader(t, path)
+	defer reader.Close()
+	writer.Flush()
+	readFifoTest(t, reader, "third\n")
+
+	if err := writer.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFifoBufferFull(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Named pipes are Unix only")
+	}
+	path := filepath.Join(t.TempDir(), "log.fifo")
+	writer, err := newFifoWriter(path, fifoWriterOptions{create: true, bufferSize: 10, retry: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	for _, message := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := writer.Write([]byte(message)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 1 || writer.dropped != 2 {
+		t.Errorf("Expected two messages dropped and reported once, got %d: %v", writer.dropped, errs)
+	}
+
+	reader := openFifoTestReader(t, path)
+	defer reader.Close()
+	writer.Flush()
+	readFifoTest(t, reader, "first\n")
+	if _, err := writer.Write([]byte("fourth\n")); err != nil {
+		t.Error(err)
+	}
+	readFifoTest(t, reader, "fourth\n")
+	if err := writer.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFifoConfig(t *testing.T) {
+	dir := t.TempDir()
+	invalid := []string{
+		`<fifo/>`,
+		`<fifo path="` + dir + `/log.fifo" buffersize="-1"/>`,
+		`<fifo path="` + dir + `/log.fifo" retry="soon"/>`,
+		`<fifo path="` + dir + `/log.fifo" create="maybe"/>`,
+		`<fifo path="` + dir + `/missing/log.fifo" create="true"/>`,
+	}
+	for _, fifo := range invalid {
+		config := `<seelog><outputs>` + fifo + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fluentEventTimeType is the MessagePack extension type of Fluentd EventTime values.
+const fluentEventTimeType = 0
+
+const (
+	fluentMessageKey     = "message"
+	fluentLevelKey       = "level"
+	defaultFluentTag     = "seelog"
+	defaultFluentTimeout = 5 * time.Second
+	fluentChunkIdSize    = 16
+	fluentChunkOptionKey = "chunk"
+	fluentAckResponseKey = "ack"
+)
+
+// fluentWriter sends messages to a Fluentd or Fluent Bit aggregator over the forward
+// protocol, in the message mode: every message is an entry [tag, time, record] with the
+// time as an EventTime and the record a map of the formatted message ("message"), the
+// level ("level") and the fields of the message.
+//
+// With ack every entry carries a chunk id, and the writer waits for the aggregator to
+// acknowledge it before the next message, so a slow aggregator slows the logger down
+// (an async logger queues and eventually sheds, see SubscribeOverload) instead of
+// messages being lost in socket buffers. An entry which fails, or is not acknowledged,
+// is sent again once on a new connection.
+type fluentWriter struct {
+	formatter *formatter
+	addr      string
+	tag       string
+	ack       bool
+	tlsConfig *tls.Config // nil for plain tcp
+	timeout   time.Duration
+
+	mutex   sync.Mutex
+	conn    net.Conn
+	decoder *MsgPackDecoder
+}
+
+func newFluentWriter(formatter *formatter, addr, tag string, ack bool, tlsConfig *tls.Config, timeout time.Duration) (*fluentWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if addr == "" {
+		return nil, errors.New("Fluent address can not be empty")
+	}
+	if tag == "" {
+		tag = defaultFluentTag
+	}
+	if timeout <= 0 {
+		timeout = defaultFluentTimeout
+	}
+
+	return &fluentWriter{formatter: formatter, addr: addr, tag: tag, ack: ack, tlsConfig: tlsConfig, timeout: timeout}, nil
+}
+
+func (writer *fluentWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	chunk := ""
+	if writer.ack {
+		var id [fluentChunkIdSize]byte
+		if _, err := rand.Read(id[:]); err != nil {
+			errorFunc(err)
+			return
+		}
+		chunk = base64.StdEncoding.EncodeToString(id[:])
+	}
+	entry := writer.entry(writer.formatter.Format(message, level, context), level, context, chunk)
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	err := writer.send(entry, chunk)
+	if err != nil {
+		// The connection may be stale
+		writer.disconnect()
+		err = writer.send(entry, chunk)
+	}
+	if err != nil {
+		writer.disconnect()
+		errorFunc(err)
+	}
+}
+
+// entry encodes a message as a forward protocol entry.
+func (writer *fluentWriter) entry(text string, level LogLevel, context logContextInterface, chunk string) []byte {
+	fields := context.Fields()
+	var recordFields []Field
+	for _, field := range fields {
+		if field.Key != fluentMessageKey && field.Key != fluentLevelKey {
+			recordFields = append(recordFields, field)
+		}
+	}
+
+	size := 3
+	if chunk != "" {
+		size = 4
+	}
+	buf := make([]byte, 0, 64+len(text))
+	buf = appendMsgPackArrayHeader(buf, size)
+	buf = appendMsgPackString(buf, writer.tag)
+
+	callTime := context.CallTime()
+	buf = append(buf, 0xd7, fluentEventTimeType)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(callTime.Unix()))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(callTime.Nanosecond()))
+
+	buf = appendMsgPackMapHeader(buf, 2+countMsgPackFields(recordFields))
+	buf = appendMsgPackString(buf, fluentMessageKey)
+	buf = appendMsgPackString(buf, strings.TrimRight(text, "\r\n"))
+	buf = appendMsgPackString(buf, fluentLevelKey)
+	buf = appendMsgPackString(buf, level.String())
+	buf = appendMsgPackFieldEntries(buf, recordFields)
+
+	if chunk != "" {
+		buf = appendMsgPackMapHeader(buf, 1)
+		buf = appendMsgPackString(buf, fluentChunkOptionKey)
+		buf = appendMsgPackString(buf, chunk)
+	}
+	return buf
+}
+
+// send writes an entry and, if chunk is set, waits for its acknowledgement.
+func (writer *fluentWriter) send(entry []byte, chunk string) error {
+	if writer.conn == nil {
+		if err := writer.connect(); err != nil {
+			return err
+		}
+	}
+
+	writer.conn.SetDeadline(time.Now().Add(writer.timeout))
+	if _, err := writer.conn.Write(entry); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+
+	response, err := writer.decoder.readValue()
+	if err != nil {
+		return err
+	}
+	responseMap, ok := response.(map[string]interface{})
+	if !ok || responseMap[fluentAckResponseKey] != chunk {
+		return fmt.Errorf("Fluent aggregator did not acknowledge chunk %s: %v", chunk, response)
+	}
+	return nil
+}
+
+func (writer *fluentWriter) connect() error {
+	dialer := &net.Dialer{Timeout: writer.timeout, KeepAlive: 30 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if writer.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", writer.addr, writer.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", writer.addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	writer.conn = conn
+	writer.decoder = NewMsgPackDecoder(conn)
+	return nil
+}
+
+func (writer *fluentWriter) disconnect() {
+	if writer.conn != nil {
+		writer.conn.Close()
+		writer.conn = nil
+		writer.decoder = nil
+	}
+}
+
+func (writer *fluentWriter) Flush() {
+}
+
+func (writer *fluentWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.disconnect()
+	return nil
+}
+
+func (writer *fluentWriter) String() string {
+	return fmt.Sprintf("fluentWriter: [%s, %s, ack %v, tls %v], format: %s\n",
+		writer.addr, writer.tag, writer.ack, writer.tlsConfig != nil, writer.formatter)
+}
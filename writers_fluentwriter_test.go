@@ -0,0 +1,161 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fluentTestServer decodes the forward protocol entries it receives and acknowledges
+// the chunks, except the first ack of every connection if dropFirstAck is set.
+type fluentTestServer struct {
+	listener     net.Listener
+	dropFirstAck bool
+	mutex        sync.Mutex
+	entries      [][]interface{}
+}
+
+func newFluentTestServer(t *testing.T, dropFirstAck bool) *fluentTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fluentTestServer{listener: listener, dropFirstAck: dropFirstAck}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (server *fluentTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	decoder := NewMsgPackDecoder(conn)
+	for first := true; ; first = false {
+		value, err := decoder.readValue()
+		if err != nil {
+			return
+		}
+		entry, _ := value.([]interface{})
+		server.mutex.Lock()
+		server.entries = append(server.entries, entry)
+		server.mutex.Unlock()
+
+		if len(entry) == 4 {
+			if first && server.dropFirstAck {
+				return
+			}
+			chunk := entry[3].(map[string]interface{})["chunk"].(string)
+			ack := appendMsgPackMapHeader(nil, 1)
+			ack = appendMsgPackString(ack, "ack")
+			ack = appendMsgPackString(ack, chunk)
+			conn.Write(ack)
+		}
+	}
+}
+
+func (server *fluentTestServer) received() [][]interface{} {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([][]interface{}(nil), server.entries...)
+}
+
+func TestFluentForward(t *testing.T) {
+	server := newFluentTestServer(t, false)
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<fluent addr="`+server.listener.Addr().String()+`" tag="app.test"/>`})
+	before := time.Now()
+	logger.Infow("hello", Str("user", "bob"), Group("http", Int("status", 200)))
+	logger.Close()
+
+	var entries [][]interface{}
+	for deadline := time.Now().Add(5 * time.Second); len(entries) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		entries = server.received()
+	}
+	if len(entries) != 1 || len(entries[0]) != 3 {
+		t.Fatalf("Expected one entry without options, got %v", entries)
+	}
+	entry := entries[0]
+	if entry[0] != "app.test" {
+		t.Errorf("Unexpected tag: %v", entry[0])
+	}
+	if eventTime, ok := entry[1].(time.Time); !ok || eventTime.Before(before.Truncate(time.Second)) {
+		t.Errorf("Unexpected time: %v", entry[1])
+	}
+	record := entry[2].(map[string]interface{})
+	if record["message"] != "hello" || record["level"] != "info" || record["user"] != "bob" {
+		t.Errorf("Unexpected record: %v", record)
+	}
+	if http, ok := record["http"].(map[string]interface{}); !ok || http["status"] != int64(200) {
+		t.Errorf("Unexpected group: %v", record["http"])
+	}
+}
+
+func TestFluentAck(t *testing.T) {
+	server := newFluentTestServer(t, true)
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<fluent addr="`+server.listener.Addr().String()+`" ack="true" timeout="1s"/>`})
+	logger.Info("acknowledged")
+	logger.Close()
+
+	// The entry without an ack is sent again on a new connection
+	entries := server.received()
+	if len(entries) != 2 || len(entries[1]) != 4 {
+		t.Fatalf("Expected the entry to be sent twice, got %v", entries)
+	}
+	if entries[0][3].(map[string]interface{})["chunk"] != entries[1][3].(map[string]interface{})["chunk"] {
+		t.Errorf("Expected the same chunk id: %v", entries)
+	}
+	if entries[1][0] != defaultFluentTag {
+		t.Errorf("Unexpected tag: %v", entries[1][0])
+	}
+}
+
+func TestFluentConfig(t *testing.T) {
+	invalid := []string{
+		`<fluent/>`,
+		`<fluent addr="fluentd"/>`,
+		`<fluent addr="fluentd:24224" ack="maybe"/>`,
+		`<fluent addr="fluentd:24224" timeout="soon"/>`,
+		`<fluent addr="fluentd:24224" cacertdirpath="` + t.TempDir() + `"/>`,
+	}
+	for _, fluent := range invalid {
+		config := `<seelog><outputs>` + fluent + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGcsEndpoint   = "https://storage.googleapis.com"
+	defaultGcsChunkSize  = 8 << 20
+	defaultGcsBackoff    = time.Second
+	gcsChunkGranularity  = 256 << 10
+	gcsRetries           = 4
+	gcsScope             = "https://www.googleapis.com/auth/devstorage.read_write"
+	gcsResumeIncomplete  = 308
+	gcsObjectContentType = "application/gzip"
+)
+
+// gcsTransientError is an upload error after which the upload may be resumed.
+type gcsTransientError struct {
+	error
+}
+
+// gcsBucket uploads archive chunks into a Google Cloud Storage bucket with resumable
+// uploads of the JSON API, in requests of chunkSize bytes. When a request fails with
+// 429, 5xx or a network error, the upload asks how much the server has and resumes from
+// there, up to gcsRetries times in a row, waiting backoff, then twice as long and so on.
+type gcsBucket struct {
+	endpoint    string
+	bucket      string
+	chunkSize   int
+	backoff     time.Duration
+	credentials *gcpCredentials
+	client      *http.Client
+}
+
+func newGcsBucket(endpoint, bucket string, chunkSize int, credentials *gcpCredentials) (*gcsBucket, error) {
+	if endpoint == "" {
+		endpoint = defaultGcsEndpoint
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" || endpointURL.Host == "" {
+		return nil, errors.New("GCS endpoint must be an http or https url: " + endpoint)
+	}
+	if bucket == "" {
+		return nil, errors.New("GCS bucket can not be empty")
+	}
+	if chunkSize == 0 {
+		chunkSize = defaultGcsChunkSize
+	}
+	if chunkSize < 0 || chunkSize%gcsChunkGranularity != 0 {
+		return nil, fmt.Errorf("GCS chunk size must be a multiple of %d. Got: %d", gcsChunkGranularity, chunkSize)
+	}
+
+	return &gcsBucket{
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		bucket:      bucket,
+		chunkSize:   chunkSize,
+		backoff:     defaultGcsBackoff,
+		credentials: credentials,
+		client:      &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (store *gcsBucket) upload(key string, data []byte) error {
+	var session string
+	err := store.withRetries(func() error {
+		var err error
+		session, err = store.startSession(key, len(data))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	offset, failures := 0, 0
+	for {
+		end := offset + store.chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		done, next, err := store.put(session, data[offset:end], offset, len(data))
+		if err == nil && done {
+			return nil
+		}
+		if err == nil {
+			offset, failures = next, 0
+			continue
+		}
+		if _, isTransient := err.(gcsTransientError); !isTransient || failures == gcsRetries {
+			return err
+		}
+		failures++
+		time.Sleep(store.backoff << uint(failures-1))
+
+		// The server may have kept a part of the failed request
+		done, next, err = store.put(session, nil, -1, len(data))
+		if err == nil && done {
+			return nil
+		}
+		if err == nil {
+			offset = next
+		}
+	}
+}
+
+// withRetries calls do until it returns nil or an error which is not transient, at most
+// gcsRetries times, with exponential backoff. The first call is made at once.
+func (store *gcsBucket) withRetries(do func() error) error {
+	var err error
+	for attempt := 0; attempt <= gcsRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(store.backoff << uint(attempt-1))
+		}
+		err = do()
+		if _, isTransient := err.(gcsTransientError); !isTransient {
+			return err
+		}
+	}
+	return err
+}
+
+// startSession starts a resumable upload and returns the url of its session.
+func (store *gcsBucket) startSession(key string, size int) (string, error) {
+	uploadURL := store.endpoint + "/upload/storage/v1/b/" + url.PathEscape(store.bucket) +
+		"/o?uploadType=resumable&name=" + url.QueryEscape(key)
+	request, err := http.NewRequest(http.MethodPost, uploadURL, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("X-Upload-Content-Type", gcsObjectContentType)
+	request.Header.Set("X-Upload-Content-Length", strconv.Itoa(size))
+
+	response, err := store.do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if err := gcsResponseError(response, http.StatusOK); err != nil {
+		return "", err
+	}
+	session := response.Header.Get("Location")
+	if session == "" {
+		return "", errors.New("GCS did not return an upload session")
+	}
+	return session, nil
+}
+
+// put sends the part of an object at offset, or asks how much of the object the server
+// has if offset is -1. It returns whether the object is complete, and else the offset
+// to continue at.
+func (store *gcsBucket) put(session string, part []byte, offset, size int) (bool, int, error) {
+	request, err := http.NewRequest(http.MethodPut, session, bytes.NewReader(part))
+	if err != nil {
+		return false, 0, err
+	}
+	if offset < 0 {
+		request.Header.Set("Content-Range", "bytes */"+strconv.Itoa(size))
+	} else {
+		request.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+len(part)-1, size))
+	}
+
+	response, err := store.do(request)
+	if err != nil {
+		return false, 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == gcsResumeIncomplete {
+		io.Copy(ioutil.Discard, response.Body)
+		// "bytes=0-N" when the server has N+1 bytes
+		next := 0
+		if received := response.Header.Get("Range"); received != "" {
+			last, err := strconv.Atoi(received[strings.LastIndex(received, "-")+1:])
+			if err != nil {
+				return false, 0, errors.New("GCS returned an invalid range: " + received)
+			}
+			next = last + 1
+		}
+		return false, next, nil
+	}
+	if err := gcsResponseError(response, http.StatusOK, http.StatusCreated); err != nil {
+		return false, 0, err
+	}
+	return true, size, nil
+}
+
+// do sends an authorized request. Network errors are transient.
+func (store *gcsBucket) do(request *http.Request) (*http.Response, error) {
+	token, err := store.credentials.accessToken()
+	if err != nil {
+		return nil, gcsTransientError{err}
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := store.client.Do(request)
+	if err != nil {
+		return nil, gcsTransientError{err}
+	}
+	return response, nil
+}
+
+// gcsResponseError returns an error for a response without one of the expected
+// statuses, which is transient for 408, 429 and 5xx.
+func gcsResponseError(response *http.Response, expected ...int) error {
+	for _, status := range expected {
+		if response.StatusCode == status {
+			io.Copy(ioutil.Discard, response.Body)
+			return nil
+		}
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+	err := fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(body)))
+	if response.StatusCode == http.StatusRequestTimeout || response.StatusCode == http.StatusTooManyRequests ||
+		response.StatusCode/100 == 5 {
+		return gcsTransientError{err}
+	}
+	return err
+}
+
+func (store *gcsBucket) String() string {
+	return fmt.Sprintf("%s/%s with %s", store.endpoint, store.bucket, store.credentials)
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gcsTestServer serves the token endpoint of OAuth2, the token path of the metadata
+// server and resumable uploads of GCS. It fails the next failChunks chunk requests with
+// 503, after keeping the first half of their data.
+type gcsTestServer struct {
+	*httptest.Server
+	failChunks int
+
+	mutex         sync.Mutex
+	tokenRequests []*http.Request
+	forms         []map[string][]string
+	tokens        int
+	auth          []string
+	sessions      map[string]string
+	uploads       map[string][]byte
+	objects       map[string][]byte
+	chunkRequests int
+}
+
+var gcsTestContentRange = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+)$`)
+
+func newGcsTestServer() *gcsTestServer {
+	server := &gcsTestServer{
+		sessions: make(map[string]string),
+		uploads:  make(map[string][]byte),
+		objects:  make(map[string][]byte),
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	return server
+}
+
+func (server *gcsTestServer) serve(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/token" || r.URL.Path == gcpMetadataTokenPath:
+		if r.URL.Path == gcpMetadataTokenPath && r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		r.ParseForm()
+		server.tokenRequests = append(server.tokenRequests, r)
+		server.forms = append(server.forms, r.PostForm)
+		server.tokens++
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600,"token_type":"Bearer"}`, server.tokens)
+	case strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/"):
+		server.auth = append(server.auth, r.Header.Get("Authorization"))
+		if r.Method != http.MethodPost || r.URL.Query().Get("uploadType") != "resumable" ||
+			r.Header.Get("X-Upload-Content-Type") != gcsObjectContentType {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		bucket := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/upload/storage/v1/b/"), "/o")
+		session := "/session/" + strconv.Itoa(len(server.sessions)+1)
+		server.sessions[session] = bucket + "/" + r.URL.Query().Get("name")
+		w.Header().Set("Location", server.URL+session)
+	case strings.HasPrefix(r.URL.Path, "/session/"):
+		server.auth = append(server.auth, r.Header.Get("Authorization"))
+		name, ok := server.sessions[r.URL.Path]
+		if !ok || r.Method != http.MethodPut {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		received := server.uploads[r.URL.Path]
+		contentRange := r.Header.Get("Content-Range")
+		var total int
+		if strings.HasPrefix(contentRange, "bytes */") {
+			total, _ = strconv.Atoi(strings.TrimPrefix(contentRange, "bytes */"))
+		} else {
+			match := gcsTestContentRange.FindStringSubmatch(contentRange)
+			if match == nil {
+				http.Error(w, "bad range", http.StatusBadRequest)
+				return
+			}
+			first, _ := strconv.Atoi(match[1])
+			total, _ = strconv.Atoi(match[3])
+			if first != len(received) {
+				http.Error(w, "unexpected offset", http.StatusBadRequest)
+				return
+			}
+			server.chunkRequests++
+			if server.failChunks > 0 {
+				server.failChunks--
+				server.uploads[r.URL.Path] = append(received, body[:len(body)/2]...)
+				http.Error(w, "backend error", http.StatusServiceUnavailable)
+				return
+			}
+			received = append(received, body...)
+			server.uploads[r.URL.Path] = received
+		}
+		if len(received) < total {
+			if len(received) > 0 {
+				w.Header().Set("Range", "bytes=0-"+strconv.Itoa(len(received)-1))
+			}
+			w.WriteHeader(gcsResumeIncomplete)
+			return
+		}
+		server.objects[name] = received
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newGcsTestBucket(t *testing.T, server *gcsTestServer, chunkSize int) *gcsBucket {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	credentials, err := findGcpCredentials("", gcsScope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := newGcsBucket(server.URL, "archive", chunkSize, credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.backoff = time.Millisecond
+	return store
+}
+
+func TestGcsResumableUpload(t *testing.T) {
+	server := newGcsTestServer()
+	defer server.Close()
+	store := newGcsTestBucket(t, server, gcsChunkGranularity)
+
+	data := make([]byte, 2*gcsChunkGranularity+1000)
+	rand.Read(data)
+	server.failChunks = 1
+	if err := store.upload("billing/2020/01/01/chunk.log.gz", data); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.upload("billing/small.log.gz", []byte("small")); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if string(server.objects["archive/billing/2020/01/01/chunk.log.gz"]) != string(data) {
+		t.Errorf("Unexpected object of %d bytes", len(server.objects["archive/billing/2020/01/01/chunk.log.gz"]))
+	}
+	if string(server.objects["archive/billing/small.log.gz"]) != "small" {
+		t.Errorf("Unexpected objects: %v", server.objects)
+	}
+	// The first chunk failed, the upload resumed from its middle with two more, and one
+	if server.chunkRequests != 4 {
+		t.Errorf("Expected 4 chunk requests, got %d", server.chunkRequests)
+	}
+	if server.tokens != 1 {
+		t.Errorf("Expected the token to be cached, got %d token requests", server.tokens)
+	}
+	for _, auth := range server.auth {
+		if auth != "Bearer token-1" {
+			t.Errorf("Unexpected authorization: %s", auth)
+		}
+	}
+}
+
+func TestGcsUploadFailure(t *testing.T) {
+	server := newGcsTestServer()
+	defer server.Close()
+	store := newGcsTestBucket(t, server, gcsChunkGranularity)
+
+	server.failChunks = gcsRetries + 1
+	err := store.upload("lost.log.gz", []byte("lost"))
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected the upload to fail after %d retries, got %v", gcsRetries, err)
+	}
+
+	store.endpoint = server.URL + "/unknown"
+	if err := store.upload("lost.log.gz", []byte("lost")); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected an error for an unknown endpoint, got %v", err)
+	}
+}
+
+func TestGcsArchiveConfig(t *testing.T) {
+	server := newGcsTestServer()
+	defer server.Close()
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	dir := t.TempDir()
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<gcsarchive endpoint="`+server.URL+`" bucket="archive" dir="`+dir+`"
+		service="billing" key="%Field(service)/%Field(seq).log.gz"/>`})
+	logger.Info("one")
+	logger.Info("two")
+	logger.Close()
+
+	server.mutex.Lock()
+	object := server.objects["archive/billing/1.log.gz"]
+	server.mutex.Unlock()
+	if text, err := gunzipMessage(object); err != nil || text != "one\ntwo\n" {
+		t.Errorf("Unexpected object: %q, %v", text, err)
+	}
+
+	invalid := []string{
+		`<gcsarchive dir="` + dir + `"/>`,
+		`<gcsarchive bucket="archive"/>`,
+		`<gcsarchive bucket="archive" dir="` + dir + `" chunksize="1000"/>`,
+		`<gcsarchive bucket="archive" dir="` + dir + `" endpoint="storage.local"/>`,
+		`<gcsarchive bucket="archive" dir="` + dir + `" credentials="missing.json"/>`,
+		`<gcsarchive bucket="archive" dir="` + dir + `" accesskey="a"/>`,
+	}
+	for _, gcsarchive := range invalid {
+		config := `<seelog><outputs>` + gcsarchive + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gelfVersion          = "1.1"
+	defaultGelfNet       = "udp"
+	defaultGelfChunkSize = 1420
+	defaultGelfTimeout   = 5 * time.Second
+	gelfMinChunkSize     = 512
+	gelfMaxChunks        = 128
+	gelfChunkHeaderSize  = 12
+	gelfCompressNone     = "none"
+	gelfCompressGzip     = "gzip"
+)
+
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// gelfWriter sends messages to Graylog in the GELF 1.1 format. The first line of the
+// formatted message is the short_message and the whole message the full_message if it
+// has more lines. The level is mapped to the syslog severity, the caller to the _file,
+// _line and _func additional fields, and the fields of the message to additional
+// fields, with groups flattened to dotted keys.
+//
+// Over udp messages may be compressed with gzip and are split into chunks of at most
+// chunkSize bytes, up to the 128 chunks GELF allows. Over tcp messages are terminated
+// by a null byte, and a message which fails is sent again once on a new connection.
+type gelfWriter struct {
+	formatter *formatter
+	net       string
+	addr      string
+	host      string
+	compress  string
+	chunkSize int
+	timeout   time.Duration
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+func newGelfWriter(formatter *formatter, netName, addr, host, compress string, chunkSize int,
+	timeout time.Duration) (*gelfWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if addr == "" {
+		return nil, errors.New("GELF address can not be empty")
+	}
+	if netName == "" {
+		netName = defaultGelfNet
+	}
+	if netName != "udp" && netName != "tcp" {
+		return nil, errors.New("GELF network must be udp or tcp: " + netName)
+	}
+	if compress == "" {
+		compress = gelfCompressNone
+	}
+	if compress != gelfCompressNone && compress != gelfCompressGzip {
+		return nil, errors.New("GELF compression must be none or gzip: " + compress)
+	}
+	if compress != gelfCompressNone && netName == "tcp" {
+		return nil, errors.New("GELF messages can not be compressed over tcp")
+	}
+	if chunkSize == 0 {
+		chunkSize = defaultGelfChunkSize
+	}
+	if chunkSize < gelfMinChunkSize {
+		return nil, fmt.Errorf("GELF chunk size can not be less than %d. Got: %d", gelfMinChunkSize, chunkSize)
+	}
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	if timeout <= 0 {
+		timeout = defaultGelfTimeout
+	}
+
+	return &gelfWriter{
+		formatter: formatter,
+		net:       netName,
+		addr:      addr,
+		host:      host,
+		compress:  compress,
+		chunkSize: chunkSize,
+		timeout:   timeout,
+	}, nil
+}
+
+func (writer *gelfWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	payload := writer.message(writer.formatter.Format(message, level, context), level, context)
+	if writer.compress == gelfCompressGzip {
+		payload = gzipMessage(string(payload))
+	}
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	var err error
+	if writer.net == "udp" {
+		err = writer.sendChunks(payload)
+	} else {
+		payload = append(payload, 0)
+		err = writer.send(payload)
+		if err != nil {
+			// The connection may be stale
+			writer.disconnect()
+			err = writer.send(payload)
+		}
+	}
+	if err != nil {
+		writer.disconnect()
+		errorFunc(err)
+	}
+}
+
+// message encodes a message as a GELF JSON object.
+func (writer *gelfWriter) message(text string, level LogLevel, context logContextInterface) []byte {
+	text = strings.TrimRight(text, "\r\n")
+	shortMessage := text
+	if i := strings.IndexByte(text, '\n'); i != -1 {
+		shortMessage = strings.TrimRight(text[:i], "\r")
+	}
+	if shortMessage == "" {
+		shortMessage = "-"
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(`{"version":"` + gelfVersion + `","host":`)
+	writeJsonString(buf, writer.host)
+	buf.WriteString(`,"short_message":`)
+	writeJsonString(buf, shortMessage)
+	if shortMessage != text {
+		buf.WriteString(`,"full_message":`)
+		writeJsonString(buf, text)
+	}
+	callTime := context.CallTime()
+	buf.WriteString(`,"timestamp":` + strconv.FormatFloat(float64(callTime.UnixNano()/1e3)/1e6, 'f', 6, 64))
+	buf.WriteString(`,"level":` + strconv.Itoa(syslogSeverity(defaultSyslogSeverities, level)))
+	if context.IsValid() {
+		buf.WriteString(`,"_file":`)
+		writeJsonString(buf, context.ShortPath())
+		buf.WriteString(`,"_line":` + strconv.Itoa(context.Line()) + `,"_func":`)
+		writeJsonString(buf, context.Func())
+	}
+	for _, field := range flattenFields(context.Fields()) {
+		buf.WriteString(",")
+		writeJsonString(buf, gelfFieldName(field.Key))
+		buf.WriteString(":")
+		writeJsonValue(buf, field.Value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes()
+}
+
+// gelfFieldName returns the name of the additional field for a field key: the key with
+// an underscore prepended and characters GELF does not allow replaced. "id" becomes
+// "_id_", as "_id" is reserved.
+func gelfFieldName(key string) string {
+	name := []byte("_" + key)
+	for i := 1; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			name[i] = '_'
+		}
+	}
+	if string(name) == "_id" {
+		return "_id_"
+	}
+	return string(name)
+}
+
+// sendChunks sends a payload as one datagram, or as chunks if it is too large.
+func (writer *gelfWriter) sendChunks(payload []byte) error {
+	if len(payload) <= writer.chunkSize {
+		return writer.send(payload)
+	}
+
+	dataSize := writer.chunkSize - gelfChunkHeaderSize
+	count := (len(payload) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return fmt.Errorf("GELF message of %d bytes needs %d chunks, more than %d", len(payload), count, gelfMaxChunks)
+	}
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+	chunk := make([]byte, 0, writer.chunkSize)
+	for seq := 0; seq < count; seq++ {
+		end := (seq + 1) * dataSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = append(chunk, id[:]...)
+		chunk = append(chunk, byte(seq), byte(count))
+		chunk = append(chunk, payload[seq*dataSize:end]...)
+		if err := writer.send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (writer *gelfWriter) send(data []byte) error {
+	if writer.conn == nil {
+		conn, err := net.DialTimeout(writer.net, writer.addr, writer.timeout)
+		if err != nil {
+			return err
+		}
+		writer.conn = conn
+	}
+
+	writer.conn.SetWriteDeadline(time.Now().Add(writer.timeout))
+	_, err := writer.conn.Write(data)
+	return err
+}
+
+func (writer *gelfWriter) disconnect() {
+	if writer.conn != nil {
+		writer.conn.Close()
+		writer.conn = nil
+	}
+}
+
+func (writer *gelfWriter) Flush() {
+}
+
+func (writer *gelfWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.disconnect()
+	return nil
+}
+
+func (writer *gelfWriter) String() string {
+	return fmt.Sprintf("gelfWriter: [%s, %s, %s, compress %s], format: %s\n",
+		writer.net, writer.addr, writer.host, writer.compress, writer.formatter)
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGelfUDPChunks(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<gelf addr="`+conn.LocalAddr().String()+`" host="web-1" compress="gzip" chunksize="512"/>`})
+	// Random text does not compress below a chunk
+	randomBytes := make([]byte, 3000)
+	rand.Read(randomBytes)
+	random := base64.StdEncoding.EncodeToString(randomBytes)
+	logger.Errorw("first line\n"+random, Str("user", "bob"), Str("id", "42"), Group("http", Int("status", 500)))
+	logger.Close()
+
+	var chunks [][]byte
+	var payload bytes.Buffer
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		datagram := make([]byte, 2048)
+		n, _, err := conn.ReadFrom(datagram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk := datagram[:n]
+		if len(chunk) > 512 || !bytes.HasPrefix(chunk, gelfChunkMagic) {
+			t.Fatalf("Unexpected datagram of %d bytes: %q", len(chunk), chunk[:2])
+		}
+		chunks = append(chunks, chunk)
+		payload.Write(chunk[gelfChunkHeaderSize:])
+		if int(chunk[10]) == int(chunk[11])-1 {
+			break
+		}
+	}
+	for seq, chunk := range chunks {
+		if !bytes.Equal(chunk[2:10], chunks[0][2:10]) || int(chunk[10]) != seq || int(chunk[11]) != len(chunks) {
+			t.Errorf("Unexpected chunk header: %v", chunk[:gelfChunkHeaderSize])
+		}
+	}
+
+	message, err := gunzipMessage(payload.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gelf map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &gelf); err != nil {
+		t.Fatal(err)
+	}
+	if gelf["version"] != "1.1" || gelf["host"] != "web-1" || gelf["short_message"] != "first line" ||
+		gelf["level"] != float64(3) || gelf["_user"] != "bob" || gelf["_id_"] != "42" || gelf["_http.status"] != float64(500) {
+		t.Errorf("Unexpected message: %v", gelf)
+	}
+	if !strings.HasSuffix(gelf["full_message"].(string), random) {
+		t.Errorf("Unexpected full message: %.40v", gelf["full_message"])
+	}
+	if !strings.HasSuffix(gelf["_file"].(string), "writers_gelfwriter_test.go") || gelf["_func"] == "" {
+		t.Errorf("Unexpected caller: %v %v", gelf["_file"], gelf["_func"])
+	}
+	if timestamp := gelf["timestamp"].(float64); time.Since(time.Unix(int64(timestamp), 0)) > time.Minute {
+		t.Errorf("Unexpected timestamp: %v", timestamp)
+	}
+}
+
+func TestGelfTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	messages := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			message, err := reader.ReadString(0)
+			if err != nil {
+				return
+			}
+			messages <- strings.TrimSuffix(message, "\x00")
+		}
+	}()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<gelf net="tcp" addr="`+listener.Addr().String()+`"/>`})
+	logger.Info("one")
+	logger.Warn("two")
+	logger.Close()
+
+	for _, expected := range []string{"one", "two"} {
+		select {
+		case message := <-messages:
+			var gelf map[string]interface{}
+			if err := json.Unmarshal([]byte(message), &gelf); err != nil || gelf["short_message"] != expected {
+				t.Errorf("Unexpected message %q: %v", message, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for messages")
+		}
+	}
+}
+
+func TestGelfConfig(t *testing.T) {
+	invalid := []string{
+		`<gelf/>`,
+		`<gelf addr="graylog"/>`,
+		`<gelf addr="graylog:12201" net="unix"/>`,
+		`<gelf addr="graylog:12201" compress="zstd"/>`,
+		`<gelf addr="graylog:12201" net="tcp" compress="gzip"/>`,
+		`<gelf addr="graylog:12201" chunksize="100"/>`,
+	}
+	for _, gelf := range invalid {
+		config := `<seelog><outputs>` + gelf + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
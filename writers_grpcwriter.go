@@ -0,0 +1,472 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGrpcMethod    = "/seelog.ingest.v1.LogIngest/Stream"
+	defaultGrpcWindow    = 1000
+	defaultGrpcTimeout   = 10 * time.Second
+	defaultGrpcReconnect = 5 * time.Second
+	grpcFrameHeaderSize  = 5
+	grpcMaxAckSize       = 1 << 16
+)
+
+var errGrpcWriteTimeout = errors.New("timeout writing to the stream")
+
+// grpcWriter streams messages to the Stream method of the LogIngest service of
+// proto/logingest.proto, or of a service with the same messages at another method.
+// gRPC runs over HTTP/2 with TLS, or over unencrypted HTTP/2 without it; a client
+// certificate gives mTLS. Records are encoded by hand, so no gRPC library is needed.
+//
+// Records stay pending until the server acknowledges them. There are never more than
+// window pending records: a message waits up to timeout for an Ack when the window is
+// full, and is dropped if none comes. When the stream breaks, the pending records are
+// sent again on a new stream, opened at most every reconnect.
+type grpcWriter struct {
+	formatter *formatter
+	url       string
+	headers   http.Header
+	client    *http.Client
+	window    int
+	timeout   time.Duration
+	reconnect time.Duration
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	stream  *grpcStream
+	pending [][]byte
+	retryAt time.Time
+	closed  bool
+}
+
+// grpcStream is one call of the Stream method. Frames are written to body and sent
+// by the transport as the flow control of the HTTP/2 stream allows.
+type grpcStream struct {
+	body *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+func newGrpcWriter(formatter *formatter, addr, method string, tlsConfig *tls.Config, headers http.Header,
+	window int, timeout, reconnect time.Duration) (*grpcWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if addr == "" {
+		return nil, errors.New("gRPC address can not be empty")
+	}
+	if method == "" {
+		method = defaultGrpcMethod
+	}
+	if !strings.HasPrefix(method, "/") || strings.Count(method, "/") != 2 {
+		return nil, errors.New("gRPC method must be /package.Service/Method: " + method)
+	}
+	if window == 0 {
+		window = defaultGrpcWindow
+	}
+	if window < 0 || timeout < 0 || reconnect < 0 {
+		return nil, errors.New("gRPC settings can not be negative")
+	}
+	if timeout == 0 {
+		timeout = defaultGrpcTimeout
+	}
+
+	scheme := "http"
+	protocols := new(http.Protocols)
+	if tlsConfig != nil {
+		scheme = "https"
+		protocols.SetHTTP2(true)
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	endpoint := scheme + "://" + addr + method
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, err
+	}
+
+	writer := &grpcWriter{
+		formatter: formatter,
+		url:       endpoint,
+		headers:   headers,
+		client: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+			Protocols:       protocols,
+			// Pings find connections which died without a reset
+			HTTP2: &http.HTTP2Config{SendPingTimeout: timeout, PingTimeout: timeout},
+		}},
+		window:    window,
+		timeout:   timeout,
+		reconnect: reconnect,
+	}
+	writer.cond = sync.NewCond(&writer.mutex)
+	return writer, nil
+}
+
+func (writer *grpcWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	frame := grpcFrame(grpcRecord(writer.formatter.Format(message, level, context), level, context))
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("gRPC writer is closed"))
+		return
+	}
+	if !writer.waitFor(func() bool { return len(writer.pending) < writer.window }) {
+		errorFunc(fmt.Errorf("gRPC window of %d records is full, message dropped", writer.window))
+		return
+	}
+
+	writer.pending = append(writer.pending, frame)
+	if writer.stream == nil {
+		writer.connect()
+	} else if err := writer.stream.write(frame, writer.timeout); err != nil {
+		writer.disconnect(writer.stream, err)
+	}
+}
+
+// waitFor waits up to timeout for the condition, opening a stream if there is none.
+// It returns false at once if no stream can be opened before the next reconnect.
+func (writer *grpcWriter) waitFor(condition func() bool) bool {
+	if condition() {
+		return true
+	}
+	timer := time.AfterFunc(writer.timeout, func() {
+		writer.mutex.Lock()
+		writer.cond.Broadcast()
+		writer.mutex.Unlock()
+	})
+	defer timer.Stop()
+
+	deadline := time.Now().Add(writer.timeout)
+	for !condition() {
+		if writer.stream == nil {
+			writer.connect()
+		}
+		if writer.stream == nil || !time.Now().Before(deadline) {
+			return false
+		}
+		writer.cond.Wait()
+	}
+	return true
+}
+
+// connect opens a stream and sends the pending records on it, unless the last stream
+// broke less than reconnect ago.
+func (writer *grpcWriter) connect() {
+	if time.Now().Before(writer.retryAt) {
+		return
+	}
+
+	reader, body := io.Pipe()
+	request, err := http.NewRequest(http.MethodPost, writer.url, reader)
+	if err != nil {
+		writer.retryAt = time.Now().Add(writer.reconnect)
+		reportInternalError(err)
+		return
+	}
+	for name, values := range writer.headers {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", "application/grpc")
+	request.Header.Set("TE", "trailers")
+
+	stream := &grpcStream{body: body, done: make(chan struct{})}
+	writer.stream = stream
+	go writer.receive(stream, request)
+
+	for _, frame := range writer.pending {
+		if err := stream.write(frame, writer.timeout); err != nil {
+			writer.disconnect(stream, err)
+			return
+		}
+	}
+}
+
+// disconnect ends a stream which broke, or which the server ended with err nil.
+func (writer *grpcWriter) disconnect(stream *grpcStream, err error) {
+	stream.body.CloseWithError(err)
+	if writer.stream == stream {
+		writer.stream = nil
+		writer.retryAt = time.Now().Add(writer.reconnect)
+	}
+}
+
+// receive runs the call of a stream and reads its Acks until it ends.
+func (writer *grpcWriter) receive(stream *grpcStream, request *http.Request) {
+	response, err := writer.client.Do(request)
+	if err == nil {
+		err = writer.readAcks(stream, response)
+		response.Body.Close()
+	}
+
+	writer.mutex.Lock()
+	writer.disconnect(stream, err)
+	closed := writer.closed
+	writer.cond.Broadcast()
+	writer.mutex.Unlock()
+
+	if err != nil && !closed {
+		reportInternalError(fmt.Errorf("gRPC stream to %s: %s", writer.url, err))
+	}
+	stream.err = err
+	close(stream.done)
+}
+
+func (writer *grpcWriter) readAcks(stream *grpcStream, response *http.Response) error {
+	if response.StatusCode != http.StatusOK {
+		return errors.New("unexpected response " + response.Status)
+	}
+	if status := response.Header.Get("Grpc-Status"); status != "" {
+		// Trailers-only response
+		return grpcStatusError(status, response.Header.Get("Grpc-Message"))
+	}
+
+	reader := bufio.NewReader(response.Body)
+	header := make([]byte, grpcFrameHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if header[0] != 0 {
+			return errors.New("compressed messages are not supported")
+		}
+		size := binary.BigEndian.Uint32(header[1:])
+		if size > grpcMaxAckSize {
+			return fmt.Errorf("Ack of %d bytes is too large", size)
+		}
+		ack := make([]byte, size)
+		if _, err := io.ReadFull(reader, ack); err != nil {
+			return err
+		}
+		count, err := grpcAckCount(ack)
+		if err != nil {
+			return err
+		}
+		writer.acknowledge(stream, count)
+	}
+	return grpcStatusError(response.Trailer.Get("Grpc-Status"), response.Trailer.Get("Grpc-Message"))
+}
+
+// acknowledge removes acknowledged records from the pending ones. Acks of a stream
+// which is no longer the current one are ignored, as its records were sent again.
+func (writer *grpcWriter) acknowledge(stream *grpcStream, count uint64) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.stream != stream {
+		return
+	}
+	if count > uint64(len(writer.pending)) {
+		count = uint64(len(writer.pending))
+	}
+	writer.pending = writer.pending[count:]
+	writer.cond.Broadcast()
+}
+
+// write writes a frame, giving up after timeout, as a pipe has no deadlines.
+func (stream *grpcStream) write(frame []byte, timeout time.Duration) error {
+	timer := time.AfterFunc(timeout, func() { stream.body.CloseWithError(errGrpcWriteTimeout) })
+	defer timer.Stop()
+	_, err := stream.body.Write(frame)
+	return err
+}
+
+// Flush waits up to timeout for the pending records to be acknowledged.
+func (writer *grpcWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.waitFor(func() bool { return len(writer.pending) == 0 })
+}
+
+// Close waits for the pending records to be acknowledged, half-closes the stream and
+// waits for the server to end it.
+func (writer *grpcWriter) Close() error {
+	writer.mutex.Lock()
+	if writer.closed {
+		writer.mutex.Unlock()
+		return nil
+	}
+	writer.closed = true
+	writer.waitFor(func() bool { return len(writer.pending) == 0 })
+	stream := writer.stream
+	pending := len(writer.pending)
+	if stream != nil {
+		stream.body.Close()
+	}
+	writer.mutex.Unlock()
+
+	var err error
+	if stream != nil {
+		select {
+		case <-stream.done:
+			err = stream.err
+		case <-time.After(writer.timeout):
+			stream.body.CloseWithError(errGrpcWriteTimeout)
+			err = errors.New("timeout waiting for the end of the stream")
+		}
+	}
+	writer.client.CloseIdleConnections()
+
+	if pending > 0 {
+		return fmt.Errorf("%d records were not acknowledged by %s", pending, writer.url)
+	}
+	if err != nil {
+		return fmt.Errorf("gRPC stream to %s: %s", writer.url, err)
+	}
+	return nil
+}
+
+func (writer *grpcWriter) String() string {
+	return fmt.Sprintf("grpcWriter: [%s, window %d], format: %s\n", writer.url, writer.window, writer.formatter)
+}
+
+// grpcStatusError returns the error of a gRPC status, or nil for OK.
+func grpcStatusError(status, message string) error {
+	if status == "0" {
+		return nil
+	}
+	if status == "" {
+		return errors.New("stream ended without a status")
+	}
+	if unescaped, err := url.PathUnescape(message); err == nil {
+		message = unescaped
+	}
+	return fmt.Errorf("status %s: %s", status, message)
+}
+
+// grpcFrame prefixes a message with the gRPC frame header: not compressed and its size.
+func grpcFrame(message []byte) []byte {
+	frame := make([]byte, grpcFrameHeaderSize, grpcFrameHeaderSize+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// grpcRecord encodes a message as a LogRecord of proto/logingest.proto.
+func grpcRecord(text string, level LogLevel, context logContextInterface) []byte {
+	var buf []byte
+	buf = appendProtoVarint(buf, 1, uint64(context.CallTime().UnixNano()))
+	buf = appendProtoString(buf, 2, level.String())
+	buf = appendProtoString(buf, 3, strings.TrimRight(text, "\r\n"))
+	for _, field := range flattenFields(context.Fields()) {
+		var entry []byte
+		entry = appendProtoString(entry, 1, field.Key)
+		entry = appendProtoString(entry, 2, FieldValueString(field.Value))
+		buf = appendProtoBytes(buf, 4, entry)
+	}
+	if context.IsValid() {
+		buf = appendProtoString(buf, 5, context.ShortPath())
+		buf = appendProtoVarint(buf, 6, uint64(int64(context.Line())))
+		buf = appendProtoString(buf, 7, context.Func())
+	}
+	return buf
+}
+
+// grpcAckCount decodes the count of an Ack, skipping unknown fields.
+func grpcAckCount(ack []byte) (uint64, error) {
+	var count uint64
+	for len(ack) > 0 {
+		tag, n := binary.Uvarint(ack)
+		if n <= 0 {
+			return 0, errors.New("malformed Ack")
+		}
+		ack = ack[n:]
+		switch tag & 7 {
+		case 0:
+			value, n := binary.Uvarint(ack)
+			if n <= 0 {
+				return 0, errors.New("malformed Ack")
+			}
+			if tag>>3 == 1 {
+				count = value
+			}
+			ack = ack[n:]
+		case 1, 5:
+			size := 8
+			if tag&7 == 5 {
+				size = 4
+			}
+			if len(ack) < size {
+				return 0, errors.New("malformed Ack")
+			}
+			ack = ack[size:]
+		case 2:
+			size, n := binary.Uvarint(ack)
+			if n <= 0 || uint64(len(ack)-n) < size {
+				return 0, errors.New("malformed Ack")
+			}
+			ack = ack[n+int(size):]
+		default:
+			return 0, errors.New("malformed Ack")
+		}
+	}
+	return count, nil
+}
+
+func appendProtoVarint(buf []byte, field int, value uint64) []byte {
+	if value == 0 {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(field)<<3)
+	return binary.AppendUvarint(buf, value)
+}
+
+func appendProtoBytes(buf []byte, field int, value []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendProtoString(buf []byte, field int, value string) []byte {
+	if value == "" {
+		return buf
+	}
+	return appendProtoBytes(buf, field, []byte(value))
+}
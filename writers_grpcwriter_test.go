@@ -0,0 +1,324 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type grpcTestRecord struct {
+	level   string
+	message string
+	fields  map[string]string
+	file    string
+	line    uint64
+}
+
+// grpcTestServer serves the Stream method of proto/logingest.proto. It acknowledges
+// every record if ack is set, and ends the first stream with status 14 after its first
+// record if failFirst is set.
+type grpcTestServer struct {
+	*httptest.Server
+	ack       bool
+	failFirst bool
+
+	mutex   sync.Mutex
+	streams int
+	headers []http.Header
+	paths   []string
+	records []grpcTestRecord
+}
+
+func newGrpcTestServer(ack, failFirst, useTLS bool) *grpcTestServer {
+	server := &grpcTestServer{ack: ack, failFirst: failFirst}
+	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.serve))
+	if useTLS {
+		server.EnableHTTP2 = true
+		server.StartTLS()
+	} else {
+		server.Config.Protocols = new(http.Protocols)
+		server.Config.Protocols.SetUnencryptedHTTP2(true)
+		server.Start()
+	}
+	return server
+}
+
+func (server *grpcTestServer) serve(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	server.streams++
+	stream := server.streams
+	server.headers = append(server.headers, r.Header)
+	server.paths = append(server.paths, r.URL.Path)
+	server.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	reader := bufio.NewReader(r.Body)
+	header := make([]byte, grpcFrameHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+		message := make([]byte, binary.BigEndian.Uint32(header[1:]))
+		if _, err := io.ReadFull(reader, message); err != nil {
+			break
+		}
+		server.mutex.Lock()
+		server.records = append(server.records, decodeGrpcTestRecord(message))
+		server.mutex.Unlock()
+
+		if server.failFirst && stream == 1 {
+			w.Header().Set("Grpc-Status", "14")
+			w.Header().Set("Grpc-Message", "going%20away")
+			return
+		}
+		if server.ack {
+			// An unknown field, which clients must skip
+			w.Write(grpcFrame([]byte{0x08, 1, 0x12, 1, 'x'}))
+			w.(http.Flusher).Flush()
+		}
+	}
+	w.Header().Set("Grpc-Status", "0")
+}
+
+func (server *grpcTestServer) messages() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	var messages []string
+	for _, record := range server.records {
+		messages = append(messages, record.message)
+	}
+	return messages
+}
+
+// decodeGrpcTestMessage decodes the varint and length-delimited fields of a message.
+func decodeGrpcTestMessage(data []byte) (map[uint64]uint64, map[uint64][]string) {
+	varints := make(map[uint64]uint64)
+	strs := make(map[uint64][]string)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		data = data[n:]
+		value, n := binary.Uvarint(data)
+		data = data[n:]
+		if tag&7 == 0 {
+			varints[tag>>3] = value
+			continue
+		}
+		strs[tag>>3] = append(strs[tag>>3], string(data[:value]))
+		data = data[value:]
+	}
+	return varints, strs
+}
+
+func decodeGrpcTestRecord(data []byte) grpcTestRecord {
+	varints, strs := decodeGrpcTestMessage(data)
+	first := func(values []string) string {
+		if len(values) == 0 {
+			return ""
+		}
+		return values[0]
+	}
+	record := grpcTestRecord{
+		level:   first(strs[2]),
+		message: first(strs[3]),
+		fields:  make(map[string]string),
+		file:    first(strs[5]),
+		line:    varints[6],
+	}
+	for _, entry := range strs[4] {
+		_, keyValue := decodeGrpcTestMessage([]byte(entry))
+		record.fields[first(keyValue[1])] = first(keyValue[2])
+	}
+	return record
+}
+
+func TestGrpcStream(t *testing.T) {
+	server := newGrpcTestServer(true, false, false)
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<grpc addr="`+server.Listener.Addr().String()+`" token="abc"/>`})
+	logger.Infow("first", Str("user", "bob"), Group("req", Int("id", 7)))
+	logger.Error("second")
+	logger.Close()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.records) != 2 {
+		t.Fatalf("Expected two records, got %v", server.records)
+	}
+	record := server.records[0]
+	if record.message != "first" || record.level != "info" || record.fields["user"] != "bob" || record.fields["req.id"] != "7" {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+	if !strings.HasSuffix(record.file, "_test.go") || record.line == 0 {
+		t.Errorf("Unexpected caller: %s:%d", record.file, record.line)
+	}
+	if server.records[1].level != "error" {
+		t.Errorf("Unexpected level: %s", server.records[1].level)
+	}
+	if server.paths[0] != defaultGrpcMethod || server.headers[0].Get("Content-Type") != "application/grpc" ||
+		server.headers[0].Get("Authorization") != "Bearer abc" {
+		t.Errorf("Unexpected request: %s %v", server.paths[0], server.headers[0])
+	}
+	if server.streams != 1 {
+		t.Errorf("Expected one stream, got %d", server.streams)
+	}
+}
+
+func TestGrpcTLS(t *testing.T) {
+	server := newGrpcTestServer(true, false, true)
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newGrpcWriter(formatter, server.Listener.Addr().String(), "/acme.logs.Ingest/Stream",
+		&tls.Config{RootCAs: roots}, nil, 0, time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Dispatch("secure", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if messages := server.messages(); len(messages) != 1 || messages[0] != "secure" {
+		t.Errorf("Unexpected messages: %v", messages)
+	}
+	if server.paths[0] != "/acme.logs.Ingest/Stream" {
+		t.Errorf("Unexpected method: %s", server.paths[0])
+	}
+}
+
+func TestGrpcReconnect(t *testing.T) {
+	server := newGrpcTestServer(true, true, false)
+	defer server.Close()
+
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newGrpcWriter(formatter, server.Listener.Addr().String(), "", nil, nil, 0, time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Dispatch("first", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		writer.mutex.Lock()
+		broken := writer.stream == nil
+		writer.mutex.Unlock()
+		if broken {
+			break
+		}
+	}
+
+	writer.Dispatch("second", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// The first record was not acknowledged by the first stream and is sent again
+	if messages := strings.Join(server.messages(), ","); messages != "first,first,second" {
+		t.Errorf("Unexpected messages: %s", messages)
+	}
+	if server.streams != 2 {
+		t.Errorf("Expected two streams, got %d", server.streams)
+	}
+}
+
+func TestGrpcWindow(t *testing.T) {
+	server := newGrpcTestServer(false, false, false)
+	defer server.Close()
+
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newGrpcWriter(formatter, server.Listener.Addr().String(), "", nil, nil, 2, 50*time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dropped []error
+	for _, message := range []string{"first", "second", "third"} {
+		writer.Dispatch(message, InfoLvl, &logContext{callTime: time.Now()}, func(err error) { dropped = append(dropped, err) })
+	}
+	if len(dropped) != 1 || !strings.Contains(dropped[0].Error(), "window of 2 records is full") {
+		t.Errorf("Expected the third message to be dropped, got %v", dropped)
+	}
+
+	err = writer.Close()
+	if err == nil || !strings.Contains(err.Error(), "2 records were not acknowledged") {
+		t.Errorf("Expected the records which were not acknowledged to be reported, got %v", err)
+	}
+	if messages := strings.Join(server.messages(), ","); messages != "first,second" {
+		t.Errorf("Unexpected messages: %s", messages)
+	}
+}
+
+func TestGrpcAckCount(t *testing.T) {
+	valid := map[string]uint64{
+		"":                   0,
+		"\x08\x05":           5,
+		"\x08\xac\x02":       300,
+		"\x12\x02ab\x08\x03": 3,
+		"\x19\x00\x00\x00\x00\x00\x00\x00\x00\x08\x01": 1,
+	}
+	for ack, expected := range valid {
+		if count, err := grpcAckCount([]byte(ack)); err != nil || count != expected {
+			t.Errorf("Expected %d for %q, got %d, %v", expected, ack, count, err)
+		}
+	}
+	for _, ack := range []string{"\x08", "\x12\x05ab", "\x0b"} {
+		if _, err := grpcAckCount([]byte(ack)); err == nil {
+			t.Errorf("Expected error for %q", ack)
+		}
+	}
+}
+
+func TestGrpcConfig(t *testing.T) {
+	invalid := []string{
+		`<grpc/>`,
+		`<grpc addr="ingest.local"/>`,
+		`<grpc addr="ingest.local:443" method="Stream"/>`,
+		`<grpc addr="ingest.local:443" tls="maybe"/>`,
+		`<grpc addr="ingest.local:443" cert="client.pem"/>`,
+		`<grpc addr="ingest.local:443" cert="missing.pem" key="missing.key"/>`,
+		`<grpc addr="ingest.local:443" window="0"/>`,
+		`<grpc addr="ingest.local:443" window="-1"/>`,
+		`<grpc addr="ingest.local:443" reconnect="soon"/>`,
+		`<grpc addr="ingest.local:443"><header name="x" value="y"/></grpc>`,
+	}
+	for _, grpc := range invalid {
+		config := `<seelog><outputs>` + grpc + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batch modes of the http receiver
+const (
+	httpBatchNone   = "none"
+	httpBatchArray  = "array"
+	httpBatchNDJSON = "ndjson"
+)
+
+const (
+	defaultHttpBatch       = httpBatchArray
+	defaultHttpMaxCount    = 100
+	defaultHttpMaxSize     = 1 << 20
+	defaultHttpMaxInterval = 5 * time.Second
+	defaultHttpRetries     = 3
+	defaultHttpBackoff     = 500 * time.Millisecond
+	defaultHttpTimeout     = 10 * time.Second
+	defaultHttpBufferSize  = 64 << 20
+	maxHttpRetryAfter      = time.Minute
+)
+
+// httpWriter POSTs messages to an http endpoint. Every message is one record, formatted
+// by the format of the output, and records are sent one per request (batch "none"), or
+// in batches as a JSON array ("array") or as newline-delimited records ("ndjson").
+// Batches are sent when they reach maxCount records or maxSize bytes, maxInterval after
+// their first record, on Flush and on Close. Request bodies are compressed as encoding
+// chooses.
+//
+// A request which fails with 429 or 5xx, or does not reach the server, is sent again up
+// to retries times, waiting backoff, then twice as long and so on, or as long as the
+// server asks for with Retry-After, up to a minute. If it still fails and
+// bufferPath is set, its records are appended to the file at bufferPath, up to bufferSize
+// bytes, and are sent again after the next request which succeeds; otherwise, and on
+// other errors, they are dropped and reported.
+type httpWriter struct {
+	url         string
+	batch       string
+	contentType string
+	headers     http.Header
+	encoding    *httpEncoding
+	maxCount    int
+	maxSize     int
+	maxInterval time.Duration
+	retries     int
+	backoff     time.Duration
+	bufferPath  string
+	bufferSize  int64
+	confirm     func(response *http.Response) (bool, error)
+	sign        func(request *http.Request, body []byte) error
+	client      *http.Client
+
+	mutex   sync.Mutex
+	records [][]byte
+	size    int
+	started time.Time
+	timer   *time.Timer
+	closed  bool
+}
+
+// httpWriterOptions are the optional settings of an http receiver. Zero values are
+// replaced with the defaults.
+type httpWriterOptions struct {
+	batch       string
+	contentType string
+	headers     http.Header
+	maxCount    int
+	maxSize     int
+	maxInterval time.Duration
+	retries     int
+	backoff     time.Duration
+	timeout     time.Duration
+	bufferPath  string
+	bufferSize  int64
+
+	// compression is the content coding of request bodies: auto (the default), none or
+	// gzip. In auto mode bodies are first coded with autoCompression, a coding which the
+	// servers of the receiver are known to accept, or none.
+	compression     string
+	autoCompression string
+
+	// confirm, if set, is called with successful responses, for receivers whose servers
+	// confirm requests separately. Like post, it returns whether a request which it
+	// finds failed may succeed if sent again.
+	confirm func(response *http.Response) (bool, error)
+
+	// sign, if set, is called with every request and its body before it is sent, for
+	// receivers whose servers authenticate requests by a signature of their content.
+	sign func(request *http.Request, body []byte) error
+}
+
+func newHttpWriter(endpoint string, options httpWriterOptions) (*httpWriter, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" || endpointURL.Host == "" {
+		return nil, errors.New("Http receiver url must be an http or https url: " + endpoint)
+	}
+
+	if options.batch == "" {
+		options.batch = defaultHttpBatch
+	}
+	if options.contentType == "" {
+		options.contentType = "application/json"
+		if options.batch == httpBatchNDJSON {
+			options.contentType = "application/x-ndjson"
+		}
+	}
+	switch options.batch {
+	case httpBatchNone:
+		options.maxCount = 1
+	case httpBatchArray, httpBatchNDJSON:
+	default:
+		return nil, errors.New("Http batch mode must be none, array or ndjson: " + options.batch)
+	}
+	if options.maxCount == 0 {
+		options.maxCount = defaultHttpMaxCount
+	}
+	if options.maxSize == 0 {
+		options.maxSize = defaultHttpMaxSize
+	}
+	if options.maxInterval == 0 {
+		options.maxInterval = defaultHttpMaxInterval
+	}
+	if options.backoff == 0 {
+		options.backoff = defaultHttpBackoff
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultHttpTimeout
+	}
+	if options.bufferSize == 0 {
+		options.bufferSize = defaultHttpBufferSize
+	}
+	if options.maxCount < 0 || options.maxSize < 0 || options.maxInterval < 0 || options.retries < 0 ||
+		options.backoff < 0 || options.timeout < 0 || options.bufferSize < 0 {
+		return nil, errors.New("Http receiver settings can not be negative")
+	}
+	encoding, err := newHttpEncoding(options.compression, options.autoCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpWriter{
+		url:         endpointURL.String(),
+		batch:       options.batch,
+		contentType: options.contentType,
+		headers:     options.headers,
+		encoding:    encoding,
+		maxCount:    options.maxCount,
+		maxSize:     options.maxSize,
+		maxInterval: options.maxInterval,
+		retries:     options.retries,
+		backoff:     options.backoff,
+		bufferPath:  options.bufferPath,
+		bufferSize:  options.bufferSize,
+		confirm:     options.confirm,
+		sign:        options.sign,
+		client:      &http.Client{Timeout: options.timeout},
+	}, nil
+}
+
+func (writer *httpWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return 0, errors.New("Http writer is closed")
+	}
+
+	record := bytes.TrimRight(data, "\r\n")
+	if len(writer.records) == 0 && writer.maxCount > 1 {
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.maxInterval, writer.sendOnTimer)
+	}
+	writer.records = append(writer.records, append([]byte(nil), record...))
+	writer.size += len(record)
+
+	var err error
+	if len(writer.records) >= writer.maxCount || writer.size >= writer.maxSize {
+		err = writer.sendRecords()
+	}
+	return len(data), err
+}
+
+func (writer *httpWriter) sendOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if len(writer.records) > 0 && time.Since(writer.started) >= writer.maxInterval {
+		if err := writer.sendRecords(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// sendRecords sends the collected records and starts a new batch. After a successful
+// request the buffered records, if any, are sent.
+func (writer *httpWriter) sendRecords() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	records := writer.records
+	writer.records = nil
+	writer.size = 0
+	if len(records) == 0 {
+		return nil
+	}
+
+	sent, retry, err := writer.send(records)
+	if err == nil {
+		return writer.sendBuffered()
+	}
+	records = records[sent:]
+	if retry && writer.bufferPath != "" {
+		if bufferErr := writer.appendToBuffer(records); bufferErr != nil {
+			return fmt.Errorf("Cannot send %d records to %s: %s; %s", len(records), writer.url, err, bufferErr)
+		}
+		return nil
+	}
+	return fmt.Errorf("Cannot send %d records to %s: %s", len(records), writer.url, err)
+}
+
+// send sends records in requests of at most maxCount records, with retries. It returns
+// the number of records sent before a request failed, its error and whether the rest of
+// the records may succeed later.
+func (writer *httpWriter) send(records [][]byte) (int, bool, error) {
+	sent := 0
+	for sent < len(records) {
+		count := len(records) - sent
+		if count > writer.maxCount {
+			count = writer.maxCount
+		}
+
+		var retry bool
+		var err error
+		for attempt := 0; attempt <= writer.retries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(httpRetryWait(err, writer.backoff<<uint(attempt-1)))
+			}
+			retry, err = writer.post(records[sent : sent+count])
+			if err == nil || !retry {
+				break
+			}
+		}
+		if err != nil {
+			return sent, retry, err
+		}
+		sent += count
+	}
+	return sent, false, nil
+}
+
+// post sends one request. It returns whether a failed request may succeed if sent again.
+func (writer *httpWriter) post(records [][]byte) (bool, error) {
+	var body []byte
+	switch writer.batch {
+	case httpBatchArray:
+		body = append(append([]byte("["), bytes.Join(records, []byte(","))...), ']')
+	case httpBatchNDJSON:
+		body = append(bytes.Join(records, []byte("\n")), '\n')
+	default:
+		body = records[0]
+	}
+
+	var response *http.Response
+	for {
+		codedBody, coding := writer.encoding.encode(body)
+		request, err := writer.newRequest(codedBody, coding)
+		if err != nil {
+			return false, err
+		}
+		response, err = writer.client.Do(request)
+		if err != nil {
+			return true, err
+		}
+		if !writer.encoding.update(response, coding) {
+			break
+		}
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		responseBody, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode/100 == 5
+		return retry, &httpStatusError{
+			status:     response.Status,
+			body:       strings.TrimSpace(string(responseBody)),
+			retryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	if writer.confirm != nil {
+		return writer.confirm(response)
+	}
+	io.Copy(ioutil.Discard, response.Body)
+	return false, nil
+}
+
+// newRequest makes the request of a body in coding.
+func (writer *httpWriter) newRequest(body []byte, coding string) (*http.Request, error) {
+	request, err := http.NewRequest(http.MethodPost, writer.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range writer.headers {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", writer.contentType)
+	if coding != httpCompressionNone {
+		request.Header.Set("Content-Encoding", coding)
+	}
+	if writer.sign != nil {
+		if err := writer.sign(request, body); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// httpStatusError is the error of a request which the server answered with an error
+// status. retryAfter is the wait the server asked for with Retry-After, if any.
+type httpStatusError struct {
+	status     string
+	body       string
+	retryAfter time.Duration
+}
+
+func (err *httpStatusError) Error() string {
+	return err.status + ": " + err.body
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as a date, into the wait it
+// asks for at now. It returns 0 if there is no header or it cannot be parsed.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// httpRetryWait returns how long to wait before sending a failed request again: backoff,
+// or the longer wait the server asked for, up to maxHttpRetryAfter.
+func httpRetryWait(err error, backoff time.Duration) time.Duration {
+	statusErr, ok := err.(*httpStatusError)
+	if !ok || statusErr.retryAfter <= backoff {
+		return backoff
+	}
+	if statusErr.retryAfter > maxHttpRetryAfter {
+		return maxHttpRetryAfter
+	}
+	return statusErr.retryAfter
+}
+
+// appendToBuffer appends records to the buffer file.
+func (writer *httpWriter) appendToBuffer(records [][]byte) error {
+	return appendRecordsFile(writer.bufferPath, writer.bufferSize, records)
+}
+
+// readBuffer reads the records of the buffer file.
+func (writer *httpWriter) readBuffer() ([][]byte, error) {
+	return readRecordsFile(writer.bufferPath)
+}
+
+// appendRecordsFile appends records to the file at path, each as its length in decimal,
+// a newline and the record, unless the file would grow beyond maxSize bytes.
+func appendRecordsFile(path string, maxSize int64, records [][]byte) error {
+	var data bytes.Buffer
+	for _, record := range records {
+		data.WriteString(strconv.Itoa(len(record)) + "\n")
+		data.Write(record)
+	}
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	if size+int64(data.Len()) > maxSize {
+		return fmt.Errorf("buffer %s is full, %d records dropped", path, len(records))
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, defaultFilePermissions)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data.Bytes())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readRecordsFile reads the records appended to the file at path by appendRecordsFile.
+// A missing file has no records.
+func readRecordsFile(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records [][]byte
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return records, nil
+		}
+		if err != nil {
+			return records, fmt.Errorf("buffer %s is truncated", path)
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(line, "\n"))
+		if err != nil || size < 0 {
+			return records, fmt.Errorf("buffer %s is corrupt", path)
+		}
+		record := make([]byte, size)
+		if _, err := io.ReadFull(reader, record); err != nil {
+			return records, fmt.Errorf("buffer %s is truncated", path)
+		}
+		records = append(records, record)
+	}
+}
+
+// sendBuffered sends the records of the buffer file and removes it if they succeed.
+func (writer *httpWriter) sendBuffered() error {
+	if writer.bufferPath == "" {
+		return nil
+	}
+	records, readErr := writer.readBuffer()
+	if len(records) == 0 {
+		if readErr != nil {
+			os.Remove(writer.bufferPath)
+		}
+		return readErr
+	}
+
+	sent, retry, err := writer.send(records)
+	os.Remove(writer.bufferPath)
+	if err != nil && retry {
+		// Kept for the next attempt
+		return writer.appendToBuffer(records[sent:])
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot send %d buffered records to %s: %s", len(records), writer.url, err)
+	}
+	return readErr
+}
+
+func (writer *httpWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.sendRecords(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *httpWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.sendRecords()
+}
+
+func (writer *httpWriter) String() string {
+	return fmt.Sprintf("httpWriter: [%s, %s, %d, %d, %s, %s]",
+		writer.url, writer.batch, writer.maxCount, writer.maxSize, writer.maxInterval, writer.bufferPath)
+}
+
+// basicAuthorization returns the value of an Authorization header for basic auth.
+func basicAuthorization(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
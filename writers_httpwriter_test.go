@@ -0,0 +1,339 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// httpTestServer answers requests with the given statuses, one per request, and with
+// success once they are used up. Responses advertise acceptEncoding, if set.
+type httpTestServer struct {
+	*httptest.Server
+	statuses       []int
+	acceptEncoding string
+
+	mutex    sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func newHttpTestServer(statuses ...int) *httpTestServer {
+	server := &httpTestServer{statuses: statuses}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	return server
+}
+
+func (server *httpTestServer) serve(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.requests = append(server.requests, r)
+	if server.acceptEncoding != "" {
+		w.Header().Set("Accept-Encoding", server.acceptEncoding)
+	}
+	if len(server.statuses) > 0 {
+		status := server.statuses[0]
+		server.statuses = server.statuses[1:]
+		http.Error(w, "unavailable", status)
+		return
+	}
+
+	body, err := readHttpTestBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.bodies = append(server.bodies, string(body))
+}
+
+func (server *httpTestServer) sent() ([]*http.Request, []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]*http.Request(nil), server.requests...), append([]string(nil), server.bodies...)
+}
+
+func TestHttpArray(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger, err := LoggerFromConfigAsString(`<seelog type="sync"><outputs>
+		<http url="` + server.URL + `/logs" maxcount="2" username="app" password="secret">
+			<header name="X-Source" value="billing"/>
+		</http>
+	</outputs></seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.(StructuredLogger).Infow("first", Str("user", "bob"))
+	logger.Error("second")
+	logger.Info("third")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(bodies) != 2 {
+		t.Fatalf("Expected two requests, got %v", bodies)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(bodies[0]), &records); err != nil {
+		t.Fatalf("Expected a JSON array, got %s: %s", bodies[0], err)
+	}
+	if len(records) != 2 || records[0]["msg"] != "first" || records[0]["user"] != "bob" || records[1]["msg"] != "second" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+	request := requests[0]
+	if request.URL.Path != "/logs" || request.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected request: %s %s", request.URL.Path, request.Header.Get("Content-Type"))
+	}
+	if request.Header.Get("Authorization") != "Basic YXBwOnNlY3JldA==" || request.Header.Get("X-Source") != "billing" {
+		t.Errorf("Unexpected headers: %v", request.Header)
+	}
+}
+
+func TestHttpNDJSONGzip(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<http formatid="msg" url="`+server.URL+`" batch="ndjson" compression="gzip" token="abc"/>`})
+	logger.Info("first")
+	logger.Info("second")
+	logger.Flush()
+
+	requests, bodies := server.sent()
+	if len(bodies) != 1 || bodies[0] != "first\nsecond\n" {
+		t.Fatalf("Unexpected bodies: %q", bodies)
+	}
+	if requests[0].Header.Get("Content-Type") != "application/x-ndjson" || requests[0].Header.Get("Authorization") != "Bearer abc" ||
+		requests[0].Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Unexpected headers: %v", requests[0].Header)
+	}
+	logger.Close()
+}
+
+func TestHttpCompressionFallback(t *testing.T) {
+	server := newHttpTestServer(http.StatusUnsupportedMediaType)
+	defer server.Close()
+	server.acceptEncoding = "identity"
+
+	// The rejected request is sent again at once, although retries are off
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<http formatid="msg" url="`+server.URL+`" batch="ndjson" compression="gzip" retries="0"/>`})
+	logger.Info("first")
+	logger.Flush()
+	logger.Info("second")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	var encodings []string
+	for _, request := range requests {
+		encodings = append(encodings, request.Header.Get("Content-Encoding"))
+	}
+	if strings.Join(encodings, ",") != "gzip,," {
+		t.Errorf("Expected a gzipped request, then two uncompressed ones, got %q", encodings)
+	}
+	if len(bodies) != 2 || bodies[0] != "first\n" || bodies[1] != "second\n" {
+		t.Errorf("Unexpected bodies: %q", bodies)
+	}
+}
+
+func TestHttpCompressionNegotiation(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+	server.acceptEncoding = "br, gzip;q=0.5"
+
+	// Uncompressed until the server advertises gzip
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<http formatid="msg" url="`+server.URL+`" batch="ndjson"/>`})
+	logger.Info("first")
+	logger.Flush()
+	logger.Info("second")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(requests) != 2 || requests[0].Header.Get("Content-Encoding") != "" ||
+		requests[1].Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected an uncompressed request, then a gzipped one, got %d requests", len(requests))
+	}
+	if bodies[0] != "first\n" || bodies[1] != "second\n" {
+		t.Errorf("Unexpected bodies: %q", bodies)
+	}
+}
+
+func TestHttpBatchInterval(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<http formatid="msg" url="`+server.URL+`" batch="none" contenttype="text/plain"/>`})
+	defer logger.Close()
+	logger.Info("first")
+	logger.Info("second")
+
+	requests, bodies := server.sent()
+	if strings.Join(bodies, ",") != "first,second" || requests[0].Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected a request per message, got %q", bodies)
+	}
+
+	logger = newTestLogger(t, testLoggerConfig{outputs: `<http formatid="msg" url="`+server.URL+`" batch="ndjson" maxinterval="20ms"/>`})
+	defer logger.Close()
+	logger.Info("third")
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if _, bodies = server.sent(); len(bodies) == 3 {
+			break
+		}
+	}
+	if len(bodies) != 3 || bodies[2] != "third\n" {
+		t.Errorf("Expected the batch to be sent after maxinterval, got %q", bodies)
+	}
+}
+
+func TestHttpRetry(t *testing.T) {
+	server := newHttpTestServer(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer server.Close()
+
+	writer, err := newHttpWriter(server.URL, httpWriterOptions{batch: httpBatchNDJSON, retries: 2, backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte("first\n"))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if requests, bodies := server.sent(); len(requests) != 3 || len(bodies) != 1 || bodies[0] != "first\n" {
+		t.Errorf("Expected two retries, got %d requests, %q", len(requests), bodies)
+	}
+
+	server = newHttpTestServer(http.StatusBadRequest)
+	defer server.Close()
+	writer, _ = newHttpWriter(server.URL, httpWriterOptions{retries: 2, backoff: time.Millisecond})
+	writer.Write([]byte(`"lost"`))
+	err = writer.Close()
+	if err == nil || !strings.Contains(err.Error(), "Cannot send 1 records") {
+		t.Errorf("Expected the dropped record to be reported, got %v", err)
+	}
+	if requests, _ := server.sent(); len(requests) != 1 {
+		t.Errorf("Expected no retries of a bad request, got %d requests", len(requests))
+	}
+}
+
+func TestHttpOverflowBuffer(t *testing.T) {
+	server := newHttpTestServer(500, 500, 500, 500)
+	defer server.Close()
+
+	bufferPath := filepath.Join(t.TempDir(), "http.buf")
+	writer, err := newHttpWriter(server.URL, httpWriterOptions{batch: httpBatchNDJSON, retries: 1,
+		backoff: time.Millisecond, bufferPath: bufferPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer.Write([]byte("first\n"))
+	writer.Write([]byte("multi\nline\n"))
+	writer.Flush()
+	writer.Write([]byte("second\n"))
+	writer.Flush()
+	if _, bodies := server.sent(); len(bodies) != 0 {
+		t.Fatalf("Expected no records to be delivered, got %q", bodies)
+	}
+	if records, err := writer.readBuffer(); err != nil || len(records) != 3 {
+		t.Fatalf("Expected three buffered records, got %q, %v", records, err)
+	}
+
+	writer.Write([]byte("third\n"))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_, bodies := server.sent()
+	if strings.Join(bodies, "|") != "third\n|first\nmulti\nline\nsecond\n" {
+		t.Errorf("Expected the buffered records after recovery, got %q", bodies)
+	}
+	if records, _ := writer.readBuffer(); len(records) != 0 {
+		t.Errorf("Expected the buffer to be removed, got %q", records)
+	}
+
+	full, _ := newHttpWriter("http://127.0.0.1:1", httpWriterOptions{retries: 0, bufferPath: bufferPath, bufferSize: 10})
+	full.Write([]byte(`"larger than the buffer"`))
+	if err := full.Close(); err == nil || !strings.Contains(err.Error(), "is full") {
+		t.Errorf("Expected the full buffer to be reported, got %v", err)
+	}
+}
+
+func TestHttpRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	retryAfters := map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"soon":                          0,
+		"Wed, 01 May 2024 12:00:10 GMT": 10 * time.Second,
+		"Wed, 01 May 2024 11:00:00 GMT": 0,
+	}
+	for value, expected := range retryAfters {
+		if wait := parseRetryAfter(value, now); wait != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, value, wait)
+		}
+	}
+
+	waits := []struct {
+		err      error
+		expected time.Duration
+	}{
+		{errors.New("refused"), time.Second},
+		{&httpStatusError{retryAfter: 100 * time.Millisecond}, time.Second},
+		{&httpStatusError{retryAfter: 5 * time.Second}, 5 * time.Second},
+		{&httpStatusError{retryAfter: time.Hour}, maxHttpRetryAfter},
+	}
+	for _, wait := range waits {
+		if got := httpRetryWait(wait.err, time.Second); got != wait.expected {
+			t.Errorf("Expected %s after %v, got %s", wait.expected, wait.err, got)
+		}
+	}
+}
+
+func TestHttpConfig(t *testing.T) {
+	invalid := []string{
+		`<http/>`,
+		`<http url="hooks.local/logs"/>`,
+		`<http url="ftp://hooks.local/logs"/>`,
+		`<http url="http://hooks.local" batch="xml"/>`,
+		`<http url="http://hooks.local" compression="zstd"/>`,
+		`<http url="http://hooks.local" maxinterval="soon"/>`,
+		`<http url="http://hooks.local" retries="-1"/>`,
+		`<http url="http://hooks.local" username="app" token="abc"/>`,
+		`<http url="http://hooks.local"><header value="x"/></http>`,
+		`<http url="http://hooks.local"><recipient address="a@b.c"/></http>`,
+		`<http url="http://hooks.local" formatid="unknown"/>`,
+	}
+	for _, httpReceiver := range invalid {
+		config := `<seelog><outputs>` + httpReceiver + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInfluxMeasurement = "log"
+	defaultInfluxPrecision   = "ns"
+	influxLevelTag           = "level"
+	influxMessageField       = "message"
+)
+
+var influxPrecisions = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", ` `, "\r", ` `, "\t", ` `)
+	influxKeyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", ` `, "\r", ` `, "\t", ` `)
+	influxStringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// influxWriter writes messages as InfluxDB line protocol points, so that log events
+// can be queried and graphed next to metrics. Every message is a point of the
+// measurement, tagged with its level and with the fields named in tags, at the call
+// time of the message. The formatted message is the "message" field, and every other
+// field is a point field of its own: integers, floats and booleans keep their type,
+// anything else is written as a string. Nested fields have dotted keys. InfluxDB 1 has
+// no unsigned integers, so they are written as integers to a database, clamped to the
+// largest one.
+//
+// Points are sent by an httpWriter, in newline-delimited batches with its retries and
+// buffering, to the /api/v2/write endpoint of a bucket (InfluxDB 2 and later) or to the
+// /write endpoint of a database (InfluxDB 1 and compatible servers).
+type influxWriter struct {
+	formatter   *formatter
+	measurement string
+	tags        map[string]bool
+	precision   time.Duration
+	unsigned    bool
+	http        *httpWriter
+}
+
+// influxWriterOptions are the settings of an influxdb receiver.
+type influxWriterOptions struct {
+	org         string
+	bucket      string
+	database    string
+	measurement string
+	tags        []string
+	precision   string
+}
+
+func newInfluxWriter(formatter *formatter, endpoint string, options influxWriterOptions,
+	httpOptions httpWriterOptions) (*influxWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if (options.bucket == "") == (options.database == "") {
+		return nil, errors.New("InfluxDB receiver needs either a bucket or a database")
+	}
+	if options.measurement == "" {
+		options.measurement = defaultInfluxMeasurement
+	}
+	if options.precision == "" {
+		options.precision = defaultInfluxPrecision
+	}
+	precision, ok := influxPrecisions[options.precision]
+	if !ok {
+		return nil, errors.New("InfluxDB precision must be ns, us, ms or s: " + options.precision)
+	}
+
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"precision": {options.precision}}
+	if options.bucket != "" {
+		endpointURL.Path = strings.TrimSuffix(endpointURL.Path, "/") + "/api/v2/write"
+		query.Set("bucket", options.bucket)
+		if options.org != "" {
+			query.Set("org", options.org)
+		}
+	} else {
+		endpointURL.Path = strings.TrimSuffix(endpointURL.Path, "/") + "/write"
+		query.Set("db", options.database)
+	}
+	endpointURL.RawQuery = query.Encode()
+
+	httpOptions.batch = httpBatchNDJSON
+	httpOptions.contentType = "text/plain; charset=utf-8"
+	httpWriter, err := newHttpWriter(endpointURL.String(), httpOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]bool, len(options.tags))
+	for _, tag := range options.tags {
+		tags[tag] = true
+	}
+	return &influxWriter{
+		formatter:   formatter,
+		measurement: options.measurement,
+		tags:        tags,
+		precision:   precision,
+		unsigned:    options.bucket != "",
+		http:        httpWriter,
+	}, nil
+}
+
+func (writer *influxWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+	if _, err := writer.http.Write(writer.point(message, level, context)); err != nil {
+		errorFunc(err)
+	}
+}
+
+// point formats a message as a line protocol point.
+func (writer *influxWriter) point(message string, level LogLevel, context logContextInterface) []byte {
+	fields := flattenFields(context.Fields())
+
+	point := new(bytes.Buffer)
+	point.WriteString(influxMeasurementEscaper.Replace(writer.measurement))
+	point.WriteString("," + influxLevelTag + "=")
+	point.WriteString(influxKeyEscaper.Replace(level.String()))
+	for _, field := range fields {
+		if !writer.tags[field.Key] || field.Key == influxLevelTag || field.Value == nil {
+			continue
+		}
+		value := FieldValueString(field.Value)
+		if value == "" {
+			continue
+		}
+		point.WriteByte(',')
+		point.WriteString(influxKeyEscaper.Replace(field.Key))
+		point.WriteByte('=')
+		point.WriteString(influxKeyEscaper.Replace(value))
+	}
+
+	point.WriteString(" " + influxMessageField + "=")
+	writeInfluxString(point, strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n"))
+	for _, field := range fields {
+		if writer.tags[field.Key] || field.Key == influxMessageField || field.Key == "" || field.Value == nil {
+			continue
+		}
+		if value, ok := field.Value.(float64); ok && (math.IsNaN(value) || math.IsInf(value, 0)) {
+			continue
+		}
+		point.WriteByte(',')
+		point.WriteString(influxKeyEscaper.Replace(field.Key))
+		point.WriteByte('=')
+		writer.writeValue(point, field.Value)
+	}
+
+	point.WriteByte(' ')
+	point.WriteString(strconv.FormatInt(context.CallTime().UnixNano()/int64(writer.precision), 10))
+	return point.Bytes()
+}
+
+// writeValue writes a field value with its line protocol type.
+func (writer *influxWriter) writeValue(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10) + "i")
+	case uint64:
+		if writer.unsigned {
+			buf.WriteString(strconv.FormatUint(v, 10) + "u")
+		} else if v <= math.MaxInt64 {
+			buf.WriteString(strconv.FormatUint(v, 10) + "i")
+		} else {
+			buf.WriteString(strconv.FormatInt(math.MaxInt64, 10) + "i")
+		}
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	default:
+		writeInfluxString(buf, FieldValueString(value))
+	}
+}
+
+func writeInfluxString(buf *bytes.Buffer, value string) {
+	buf.WriteByte('"')
+	buf.WriteString(influxStringEscaper.Replace(value))
+	buf.WriteByte('"')
+}
+
+func (writer *influxWriter) Flush() {
+	writer.http.Flush()
+}
+
+func (writer *influxWriter) Close() error {
+	return writer.http.Close()
+}
+
+func (writer *influxWriter) String() string {
+	return fmt.Sprintf("influxWriter: [%s, %s], format: %s\n", writer.measurement, writer.http, writer.formatter)
+}
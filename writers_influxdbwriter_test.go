@@ -0,0 +1,112 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInfluxBucket(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<influxdb formatid="msg" url="`+server.URL+`" org="ops" bucket="events"
+		token="abc" measurement="app events" tags="service" precision="ms" maxcount="2"/>`})
+	logger.Infow(`deploy "v2"`, Str("service", "billing api"), Int("replicas", 3), Uint64("bytes", 10),
+		Float64("ratio", 0.5), Bool("ok", true), Str("user", "bob"))
+	logger.Error("failed")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected one request, got %q", bodies)
+	}
+	request := requests[0]
+	query := request.URL.Query()
+	if request.URL.Path != "/api/v2/write" || query.Get("org") != "ops" || query.Get("bucket") != "events" ||
+		query.Get("precision") != "ms" {
+		t.Errorf("Unexpected request: %s", request.URL)
+	}
+	if request.Header.Get("Authorization") != "Token abc" || !strings.HasPrefix(request.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("Unexpected headers: %v", request.Header)
+	}
+
+	points := strings.Split(strings.TrimSuffix(bodies[0], "\n"), "\n")
+	if len(points) != 2 {
+		t.Fatalf("Expected two points, got %q", bodies[0])
+	}
+	expected := `app\ events,level=info,service=billing\ api message="deploy \"v2\"",replicas=3i,bytes=10u,ratio=0.5,ok=true,user="bob" `
+	if !strings.HasPrefix(points[0], expected) {
+		t.Errorf("Expected point %q, got %q", expected, points[0])
+	}
+	if !strings.HasPrefix(points[1], `app\ events,level=error message="failed" `) {
+		t.Errorf("Unexpected point: %q", points[1])
+	}
+	if timestamp := points[0][len(expected):]; len(timestamp) != 13 {
+		t.Errorf("Expected a timestamp in milliseconds, got %q", timestamp)
+	}
+}
+
+func TestInfluxDatabase(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<influxdb formatid="msg" url="`+server.URL+`/" database="logs"
+		username="app" password="secret" maxcount="1"/>`})
+	logger.Infow("sent", Uint64("bytes", 1<<63))
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected one request, got %q", bodies)
+	}
+	if requests[0].URL.Path != "/write" || requests[0].URL.Query().Get("db") != "logs" ||
+		requests[0].Header.Get("Authorization") != "Basic YXBwOnNlY3JldA==" {
+		t.Errorf("Unexpected request: %s %v", requests[0].URL, requests[0].Header)
+	}
+	if !strings.HasPrefix(bodies[0], `log,level=info message="sent",bytes=9223372036854775807i `) {
+		t.Errorf("Unexpected point: %q", bodies[0])
+	}
+}
+
+func TestInfluxConfig(t *testing.T) {
+	invalid := []string{
+		`<influxdb bucket="events"/>`,
+		`<influxdb url="http://influx.local:8086"/>`,
+		`<influxdb url="http://influx.local:8086" bucket="events" database="logs"/>`,
+		`<influxdb url="influx.local:8086" bucket="events"/>`,
+		`<influxdb url="http://influx.local:8086" bucket="events" precision="m"/>`,
+		`<influxdb url="http://influx.local:8086" bucket="events" username="app" token="abc"/>`,
+		`<influxdb url="http://influx.local:8086" bucket="events" maxcount="many"/>`,
+		`<influxdb url="http://influx.local:8086" bucket="events" batch="array"/>`,
+	}
+	for _, influxdb := range invalid {
+		config := `<seelog><outputs>` + influxdb + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
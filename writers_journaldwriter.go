@@ -0,0 +1,177 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// journaldSocket is the socket of the native journal protocol.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldWriter sends messages to the systemd journal over its native protocol, so
+// records keep their metadata as journal fields: MESSAGE is the formatted message,
+// PRIORITY the syslog severity of the level, CODE_FILE, CODE_LINE and CODE_FUNC the
+// caller, SYSLOG_IDENTIFIER the tag. The fields of a record are added with upper case
+// keys, nested fields joined by '_' (http.method becomes HTTP_METHOD).
+type journaldWriter struct {
+	formatter  *formatter
+	socket     string
+	severities map[LogLevel]int
+	tag        string
+
+	mutex sync.Mutex
+	conn  *net.UnixConn
+}
+
+func newJournaldWriter(formatter *formatter, socket string, severities map[LogLevel]int, tag string) (*journaldWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if socket == "" {
+		socket = journaldSocket
+	}
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+
+	return &journaldWriter{formatter: formatter, socket: socket, severities: severities, tag: tag}, nil
+}
+
+func (writer *journaldWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	writeJournalField(buf, "MESSAGE", strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n"))
+	writeJournalField(buf, "PRIORITY", strconv.Itoa(syslogSeverity(writer.severities, level)))
+	writeJournalField(buf, "SYSLOG_IDENTIFIER", writer.tag)
+	if context.IsValid() {
+		writeJournalField(buf, "CODE_FILE", context.FullPath())
+		writeJournalField(buf, "CODE_LINE", strconv.Itoa(context.Line()))
+		writeJournalField(buf, "CODE_FUNC", context.Func())
+	}
+	for _, field := range flattenFields(context.Fields()) {
+		if name := journalFieldName(field.Key); name != "" {
+			writeJournalField(buf, name, FieldValueString(field.Value))
+		}
+	}
+
+	if err := writer.send(buf.Bytes()); err != nil {
+		errorFunc(err)
+	}
+}
+
+// journalFieldName makes a field key a journal field name: upper case letters, digits
+// and '_', not starting with '_' or a digit, at most 64 characters. An empty name is
+// returned if nothing is left.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// writeJournalField writes a field of the native protocol: "NAME=value\n", or for values
+// with newlines "NAME\n", the little-endian 64-bit length of the value, the value, "\n".
+func writeJournalField(buf *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(name + "=" + value + "\n")
+		return
+	}
+
+	buf.WriteString(name + "\n")
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.Write(size[:])
+	buf.WriteString(value + "\n")
+}
+
+// send writes an entry in a datagram. Entries too large for a datagram are passed as a
+// file descriptor where the platform allows it.
+func (writer *journaldWriter) send(data []byte) error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: writer.socket, Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		writer.conn = conn
+	}
+
+	_, err := writer.conn.Write(data)
+	if err == nil {
+		return nil
+	}
+	if isDatagramTooLarge(err) {
+		return sendJournalFile(writer.conn, data)
+	}
+	return err
+}
+
+func (writer *journaldWriter) Flush() {
+}
+
+func (writer *journaldWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.conn == nil {
+		return nil
+	}
+	err := writer.conn.Close()
+	writer.conn = nil
+	return err
+}
+
+func (writer *journaldWriter) String() string {
+	return fmt.Sprintf("journaldWriter: [%s, %s], format: %s\n", writer.socket, writer.tag, writer.formatter)
+}
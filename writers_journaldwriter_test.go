@@ -0,0 +1,108 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournaldFields(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("No unix sockets")
+	}
+	path := filepath.Join(t.TempDir(), "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<journald socket="`+path+`" tag="billing" severities="warn:notice"/>`})
+	defer logger.Close()
+	logger.Warnw("two\nlines", Str("user-id", "42"), Group("http", Int("status", 500)))
+
+	buf := make([]byte, 4096)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := buf[:n]
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len("two\nlines")))
+	expected := []string{
+		"MESSAGE\n" + string(size[:]) + "two\nlines\n",
+		"PRIORITY=5\n",
+		"SYSLOG_IDENTIFIER=billing\n",
+		"CODE_FUNC=",
+		"CODE_FILE=",
+		"USER_ID=42\n",
+		"HTTP_STATUS=500\n",
+	}
+	for _, field := range expected {
+		if !bytes.Contains(entry, []byte(field)) {
+			t.Errorf("Expected %q in entry %q", field, entry)
+		}
+	}
+	if !strings.Contains(string(entry), "CODE_LINE=") {
+		t.Errorf("Expected CODE_LINE in entry %q", entry)
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	names := map[string]string{
+		"http.method": "HTTP_METHOD",
+		"_private":    "PRIVATE",
+		"2fa":         "FA",
+		"___":         "",
+	}
+	for key, expected := range names {
+		if name := journalFieldName(key); name != expected {
+			t.Errorf("journalFieldName(%q) = %q, expected %q", key, name, expected)
+		}
+	}
+}
+
+func TestJournaldConfig(t *testing.T) {
+	invalid := []string{
+		`<journald severities="info"/>`,
+		`<journald net="udp"/>`,
+		`<journald><console/></journald>`,
+	}
+	for _, journald := range invalid {
+		config := `<seelog><outputs>` + journald + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logstashTimestampKey      = "@timestamp"
+	logstashVersionKey        = "@version"
+	logstashVersion           = "1"
+	logstashMessageKey        = "message"
+	logstashLevelKey          = "level"
+	defaultLogstashTimeout    = 5 * time.Second
+	defaultLogstashKeepAlive  = 30 * time.Second
+	defaultLogstashBufferSize = 1 << 20
+	defaultLogstashReconnect  = time.Second
+)
+
+// logstashWriter sends messages to Logstash over tcp, or tls, in the shape of the
+// json_lines codec: one JSON object per line with "@timestamp", "@version", the
+// formatted message ("message"), the level ("level") and the fields of the message.
+//
+// Lines which can not be sent are spilled to an in-memory buffer of at most bufferSize
+// bytes, dropping the oldest lines when it is full, and are sent first once the
+// connection is back. After a failed dial, the writer does not dial again for the
+// reconnect interval, so an unreachable Logstash does not stall every message.
+type logstashWriter struct {
+	formatter  *formatter
+	addr       string
+	tlsConfig  *tls.Config // nil for plain tcp
+	timeout    time.Duration
+	keepAlive  time.Duration
+	bufferSize int
+	reconnect  time.Duration
+
+	mutex     sync.Mutex
+	conn      net.Conn
+	retryAt   time.Time
+	spilled   [][]byte
+	spillSize int
+	dropped   int
+}
+
+func newLogstashWriter(formatter *formatter, addr string, tlsConfig *tls.Config,
+	timeout, keepAlive time.Duration, bufferSize int, reconnect time.Duration) (*logstashWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if addr == "" {
+		return nil, errors.New("Logstash address can not be empty")
+	}
+	if timeout <= 0 {
+		timeout = defaultLogstashTimeout
+	}
+	if bufferSize < 0 {
+		return nil, errors.New("Logstash buffer size can not be negative")
+	}
+
+	return &logstashWriter{
+		formatter:  formatter,
+		addr:       addr,
+		tlsConfig:  tlsConfig,
+		timeout:    timeout,
+		keepAlive:  keepAlive,
+		bufferSize: bufferSize,
+		reconnect:  reconnect,
+	}, nil
+}
+
+func (writer *logstashWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	line := writer.line(writer.formatter.Format(message, level, context), level, context)
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.spill(line)
+	if err := writer.send(); err != nil {
+		errorFunc(err)
+	}
+}
+
+// line encodes a message as a json_lines event.
+func (writer *logstashWriter) line(text string, level LogLevel, context logContextInterface) []byte {
+	var eventFields []Field
+	for _, field := range context.Fields() {
+		switch field.Key {
+		case logstashTimestampKey, logstashVersionKey, logstashMessageKey, logstashLevelKey:
+		default:
+			eventFields = append(eventFields, field)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(`{"` + logstashTimestampKey + `":`)
+	writeJsonString(buf, context.CallTime().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"` + logstashVersionKey + `":"` + logstashVersion + `","` + logstashMessageKey + `":`)
+	writeJsonString(buf, strings.TrimRight(text, "\r\n"))
+	buf.WriteString(`,"` + logstashLevelKey + `":`)
+	writeJsonString(buf, level.String())
+	writeJsonFields(buf, eventFields, true)
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// spill appends a line to the buffer, dropping the oldest lines if it gets too large.
+func (writer *logstashWriter) spill(line []byte) {
+	writer.spilled = append(writer.spilled, line)
+	writer.spillSize += len(line)
+	for writer.spillSize > writer.bufferSize && len(writer.spilled) > 1 {
+		writer.spillSize -= len(writer.spilled[0])
+		writer.spilled[0] = nil
+		writer.spilled = writer.spilled[1:]
+		writer.dropped++
+	}
+}
+
+// send writes the spilled lines. A write failing on an established connection is
+// tried again once on a new one, as the connection may be stale.
+func (writer *logstashWriter) send() error {
+	established := writer.conn != nil
+	err := writer.sendSpilled()
+	if err != nil && established {
+		err = writer.sendSpilled()
+	}
+	if err != nil {
+		return err
+	}
+
+	if writer.dropped > 0 {
+		dropped := writer.dropped
+		writer.dropped = 0
+		return fmt.Errorf("Dropped %d messages while Logstash at %s was unreachable", dropped, writer.addr)
+	}
+	return nil
+}
+
+func (writer *logstashWriter) sendSpilled() error {
+	if len(writer.spilled) == 0 {
+		return nil
+	}
+	if writer.conn == nil {
+		if time.Now().Before(writer.retryAt) {
+			return nil
+		}
+		if err := writer.connect(); err != nil {
+			writer.retryAt = time.Now().Add(writer.reconnect)
+			return err
+		}
+	}
+
+	for len(writer.spilled) > 0 {
+		line := writer.spilled[0]
+		writer.conn.SetWriteDeadline(time.Now().Add(writer.timeout))
+		if _, err := writer.conn.Write(line); err != nil {
+			writer.disconnect()
+			return err
+		}
+		writer.spillSize -= len(line)
+		writer.spilled[0] = nil
+		writer.spilled = writer.spilled[1:]
+	}
+	writer.spilled = nil
+	return nil
+}
+
+func (writer *logstashWriter) connect() error {
+	keepAlive := writer.keepAlive
+	if keepAlive == 0 {
+		keepAlive = -1 // Disabled
+	}
+	dialer := &net.Dialer{Timeout: writer.timeout, KeepAlive: keepAlive}
+
+	var conn net.Conn
+	var err error
+	if writer.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", writer.addr, writer.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", writer.addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	writer.conn = conn
+	return nil
+}
+
+func (writer *logstashWriter) disconnect() {
+	if writer.conn != nil {
+		writer.conn.Close()
+		writer.conn = nil
+	}
+}
+
+// Flush sends the spilled lines, if Logstash is reachable again.
+func (writer *logstashWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.send(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+// Close makes a last attempt to send the spilled lines and closes the connection.
+func (writer *logstashWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.retryAt = time.Time{}
+	err := writer.send()
+	writer.disconnect()
+	if len(writer.spilled) > 0 {
+		err = fmt.Errorf("Logstash output closed with %d undelivered messages", len(writer.spilled)+writer.dropped)
+	}
+	return err
+}
+
+func (writer *logstashWriter) String() string {
+	return fmt.Sprintf("logstashWriter: [%s, tls %v, buffer %d], format: %s\n",
+		writer.addr, writer.tlsConfig != nil, writer.bufferSize, writer.formatter)
+}
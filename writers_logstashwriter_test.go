@@ -0,0 +1,178 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logstashTestServer collects the json_lines events it receives.
+type logstashTestServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	events   []map[string]interface{}
+}
+
+func newLogstashTestServer(t *testing.T, addr string) *logstashTestServer {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &logstashTestServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (server *logstashTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var event map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			event = map[string]interface{}{"invalid": scanner.Text()}
+		}
+		server.mutex.Lock()
+		server.events = append(server.events, event)
+		server.mutex.Unlock()
+	}
+}
+
+func (server *logstashTestServer) waitEvents(count int) []map[string]interface{} {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		server.mutex.Lock()
+		events := append([]map[string]interface{}(nil), server.events...)
+		server.mutex.Unlock()
+		if len(events) >= count {
+			return events
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func TestLogstashJsonLines(t *testing.T) {
+	server := newLogstashTestServer(t, "127.0.0.1:0")
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<logstash addr="`+server.listener.Addr().String()+`"/>`})
+	logger.Warnw("hello", Str("user", "bob"), Str("@version", "9"), Group("http", Int("status", 200)))
+	logger.Close()
+
+	events := server.waitEvents(1)
+	if len(events) != 1 {
+		t.Fatalf("Expected one event, got %v", events)
+	}
+	event := events[0]
+	if event["@version"] != "1" || event["message"] != "hello" || event["level"] != "warn" || event["user"] != "bob" {
+		t.Errorf("Unexpected event: %v", event)
+	}
+	if timestamp, ok := event["@timestamp"].(string); !ok || !strings.HasSuffix(timestamp, "Z") {
+		t.Errorf("Unexpected timestamp: %v", event["@timestamp"])
+	}
+	if http, ok := event["http"].(map[string]interface{}); !ok || http["status"] != float64(200) {
+		t.Errorf("Unexpected group: %v", event["http"])
+	}
+}
+
+func TestLogstashSpill(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newLogstashWriter(formatter, addr, nil, time.Second, 0, 200, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []error
+	errorFunc := func(err error) { errs = append(errs, err) }
+
+	for _, message := range []string{"first", "second", "third"} {
+		context := &logContext{callTime: time.Now()}
+		writer.Dispatch(message, InfoLvl, context, errorFunc)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("Expected dial errors, got %v", errs)
+	}
+	if len(writer.spilled) != 2 || writer.dropped != 1 {
+		t.Fatalf("Expected the oldest line to be dropped, got %d spilled, %d dropped", len(writer.spilled), writer.dropped)
+	}
+
+	server := newLogstashTestServer(t, addr)
+	defer server.listener.Close()
+
+	// The buffer holds two lines, so the fourth one drops the second
+	errs = nil
+	writer.Dispatch("fourth", InfoLvl, &logContext{callTime: time.Now()}, errorFunc)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "Dropped 2 messages") {
+		t.Errorf("Expected the dropped messages to be reported, got %v", errs)
+	}
+	if err := writer.Close(); err != nil {
+		t.Errorf("Unexpected close error: %s", err)
+	}
+
+	events := server.waitEvents(2)
+	var messages []string
+	for _, event := range events {
+		messages = append(messages, event["message"].(string))
+	}
+	if strings.Join(messages, ",") != "third,fourth" {
+		t.Errorf("Unexpected messages: %v", messages)
+	}
+}
+
+func TestLogstashConfig(t *testing.T) {
+	invalid := []string{
+		`<logstash/>`,
+		`<logstash addr="logstash"/>`,
+		`<logstash addr="logstash:5000" tls="maybe"/>`,
+		`<logstash addr="logstash:5000" keepalive="often"/>`,
+		`<logstash addr="logstash:5000" buffersize="-1"/>`,
+		`<logstash addr="logstash:5000" cacertdirpath="` + t.TempDir() + `"/>`,
+	}
+	for _, logstash := range invalid {
+		config := `<seelog><outputs>` + logstash + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMongoCollection  = "logs"
+	defaultMongoAuthSource  = "admin"
+	defaultMongoMaxCount    = 500
+	defaultMongoMaxInterval = time.Second
+	defaultMongoTimeout     = 10 * time.Second
+	mongoOpMsg              = 2013
+	mongoMaxMessageSize     = 48 << 20
+	mongoDuplicateKey       = 11000
+	mongoNamespaceExists    = 48
+	mongoTimeKey            = "time"
+	mongoLevelKey           = "level"
+	mongoMessageKey         = "message"
+	mongoIDKey              = "_id"
+)
+
+type mongoWriterOptions struct {
+	database     string
+	collection   string
+	user         string
+	password     string
+	authSource   string
+	tlsConfig    *tls.Config // nil for plain tcp
+	capped       int64
+	ttl          time.Duration
+	writeConcern bsonDocument // nil for the default of the server
+	maxCount     int
+	maxInterval  time.Duration
+	timeout      time.Duration
+}
+
+// mongoWriter inserts messages into a MongoDB collection with OP_MSG insert commands,
+// speaking the wire protocol itself. Every message is a document with "_id", "time"
+// (a date), "level", "message" (the formatted message) and the fields of the message,
+// groups as nested documents. It authenticates with SCRAM-SHA-256 if user is set, over
+// TLS if tlsConfig is set. It connects to a single server, the primary.
+//
+// On the first connection the collection is created capped at capped bytes, or an
+// index on "time" deletes documents ttl after their time; both are left alone if the
+// collection or index already exist. The insert uses writeConcern, by default that of
+// the server.
+//
+// Documents are collected in batches, which are inserted when they reach maxCount
+// documents, maxInterval after their first document, on Flush and on Close. A batch
+// which fails on the connection is inserted again once on a new connection; ids are set
+// by the writer, so documents which made it the first time are not duplicated. Documents
+// which the server rejects are dropped and reported.
+type mongoWriter struct {
+	formatter *formatter
+	addr      string
+	options   mongoWriterOptions
+
+	mutex     sync.Mutex
+	batch     [][]byte // Encoded documents
+	size      int
+	started   time.Time
+	timer     *time.Timer
+	conn      net.Conn
+	requestID int32
+	prepared  bool
+	closed    bool
+}
+
+func newMongoWriter(formatter *formatter, addr string, options mongoWriterOptions) (*mongoWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if addr == "" {
+		return nil, errors.New("MongoDB address can not be empty")
+	}
+	if options.database == "" {
+		return nil, errors.New("MongoDB database can not be empty")
+	}
+	if options.collection == "" {
+		options.collection = defaultMongoCollection
+	}
+	if options.authSource == "" {
+		options.authSource = defaultMongoAuthSource
+	}
+	if options.capped < 0 || options.ttl < 0 {
+		return nil, errors.New("MongoDB capped size and ttl can not be negative")
+	}
+	if options.capped > 0 && options.ttl > 0 {
+		return nil, errors.New("MongoDB collection can not be both capped and with a ttl")
+	}
+	if options.maxCount <= 0 {
+		return nil, fmt.Errorf("maxCount can not be less or equal to 0. Got: %d", options.maxCount)
+	}
+	if options.maxInterval <= 0 {
+		return nil, fmt.Errorf("maxInterval can not be less or equal to 0. Got: %s", options.maxInterval)
+	}
+	if options.timeout <= 0 {
+		options.timeout = defaultMongoTimeout
+	}
+
+	return &mongoWriter{formatter: formatter, addr: addr, options: options}, nil
+}
+
+func (writer *mongoWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	document := bsonDocument{
+		{mongoIDKey, newBsonObjectID(context.CallTime())},
+		{mongoTimeKey, context.CallTime()},
+		{mongoLevelKey, level.String()},
+		{mongoMessageKey, strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n")},
+	}
+	for _, element := range bsonFields(context.Fields()) {
+		switch element.key {
+		case mongoIDKey, mongoTimeKey, mongoLevelKey, mongoMessageKey:
+		default:
+			document = append(document, element)
+		}
+	}
+	encoded := appendBsonDocument(nil, document)
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("MongoDB writer is closed"))
+		return
+	}
+
+	if len(writer.batch) > 0 && writer.size+len(encoded) > mongoMaxMessageSize/2 {
+		if err := writer.insertBatch(); err != nil {
+			errorFunc(err)
+		}
+	}
+	if len(writer.batch) == 0 {
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.options.maxInterval, writer.insertOnTimer)
+	}
+	writer.batch = append(writer.batch, encoded)
+	writer.size += len(encoded)
+	if len(writer.batch) >= writer.options.maxCount {
+		if err := writer.insertBatch(); err != nil {
+			errorFunc(err)
+		}
+	}
+}
+
+var (
+	mongoObjectIDMutex   sync.Mutex
+	mongoObjectIDProcess [5]byte
+	mongoObjectIDCounter uint32
+)
+
+func init() {
+	rand.Read(mongoObjectIDProcess[:])
+	var counter [4]byte
+	rand.Read(counter[:])
+	mongoObjectIDCounter = binary.BigEndian.Uint32(counter[:])
+}
+
+// newBsonObjectID creates an ObjectId as drivers do: seconds, a random value of the
+// process and a counter.
+func newBsonObjectID(now time.Time) bsonObjectID {
+	mongoObjectIDMutex.Lock()
+	mongoObjectIDCounter++
+	counter := mongoObjectIDCounter
+	mongoObjectIDMutex.Unlock()
+
+	var id bsonObjectID
+	binary.BigEndian.PutUint32(id[:], uint32(now.Unix()))
+	copy(id[4:9], mongoObjectIDProcess[:])
+	id[9], id[10], id[11] = byte(counter>>16), byte(counter>>8), byte(counter)
+	return id
+}
+
+func (writer *mongoWriter) insertOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if len(writer.batch) > 0 && time.Since(writer.started) >= writer.options.maxInterval {
+		if err := writer.insertBatch(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// insertBatch inserts the collected documents and starts a new batch.
+func (writer *mongoWriter) insertBatch() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	if len(writer.batch) == 0 {
+		return nil
+	}
+	batch := writer.batch
+	writer.batch = nil
+	writer.size = 0
+
+	err := writer.insert(batch, false)
+	if _, rejected := err.(*mongoError); err != nil && !rejected {
+		// The connection may be stale
+		writer.disconnect()
+		err = writer.insert(batch, true)
+		if _, rejected := err.(*mongoError); err != nil && !rejected {
+			writer.disconnect()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot insert %d messages into %s.%s: %s", len(batch), writer.options.database,
+			writer.options.collection, err)
+	}
+	return nil
+}
+
+// insert runs an insert command. When retrying, documents which were inserted before
+// do not count as failed.
+func (writer *mongoWriter) insert(batch [][]byte, retry bool) error {
+	if writer.conn == nil {
+		if err := writer.connect(); err != nil {
+			return err
+		}
+	}
+
+	command := bsonDocument{
+		{"insert", writer.options.collection},
+		{"ordered", false},
+	}
+	if writer.options.writeConcern != nil {
+		command = append(command, bsonElement{"writeConcern", writer.options.writeConcern})
+	}
+	command = append(command, bsonElement{"$db", writer.options.database})
+	reply, err := writer.command(command, batch)
+	if err != nil {
+		return err
+	}
+
+	writeErrors, _ := reply["writeErrors"].([]interface{})
+	failed := 0
+	var firstErr *mongoError
+	for _, writeError := range writeErrors {
+		writeErrorDocument, _ := writeError.(map[string]interface{})
+		mongoErr := newMongoError(writeErrorDocument)
+		if retry && mongoErr.code == mongoDuplicateKey {
+			continue
+		}
+		failed++
+		if firstErr == nil {
+			firstErr = mongoErr
+		}
+	}
+	if firstErr != nil {
+		return &mongoError{code: firstErr.code, message: fmt.Sprintf("%d documents failed: %s", failed, firstErr.message)}
+	}
+	if concernError, ok := reply["writeConcernError"].(map[string]interface{}); ok {
+		return newMongoError(concernError)
+	}
+	return nil
+}
+
+func (writer *mongoWriter) connect() error {
+	dialer := &net.Dialer{Timeout: writer.options.timeout, KeepAlive: 30 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if writer.options.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", writer.addr, writer.options.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", writer.addr)
+	}
+	if err != nil {
+		return err
+	}
+	writer.conn = conn
+
+	if err = writer.authenticate(); err == nil && !writer.prepared {
+		err = writer.prepare()
+	}
+	if err != nil {
+		writer.disconnect()
+		return err
+	}
+	return nil
+}
+
+// authenticate runs a SCRAM-SHA-256 conversation with saslStart and saslContinue.
+func (writer *mongoWriter) authenticate() error {
+	if writer.options.user == "" {
+		return nil
+	}
+	scram, err := newScramClient(writer.options.user, writer.options.password)
+	if err != nil {
+		return err
+	}
+
+	reply, err := writer.command(bsonDocument{
+		{"saslStart", int32(1)},
+		{"mechanism", scramSHA256Mechanism},
+		{"payload", []byte(scram.clientFirst())},
+		{"options", bsonDocument{{"skipEmptyExchange", true}}},
+		{"$db", writer.options.authSource},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	serverFirst, _ := reply["payload"].([]byte)
+	clientFinal, err := scram.clientFinal(string(serverFirst))
+	if err != nil {
+		return err
+	}
+
+	for step := 0; ; step++ {
+		reply, err = writer.command(bsonDocument{
+			{"saslContinue", int32(1)},
+			{"conversationId", reply["conversationId"]},
+			{"payload", clientFinal},
+			{"$db", writer.options.authSource},
+		}, nil)
+		if err != nil {
+			return err
+		}
+		if step == 0 {
+			serverFinal, _ := reply["payload"].([]byte)
+			if !scram.verifyServer(string(serverFinal)) {
+				return errors.New("MongoDB server signature does not match")
+			}
+		}
+		if done, _ := reply["done"].(bool); done || step > 0 {
+			return nil
+		}
+		clientFinal = []byte{}
+	}
+}
+
+// prepare creates the collection capped or its ttl index.
+func (writer *mongoWriter) prepare() error {
+	if writer.options.capped > 0 {
+		_, err := writer.command(bsonDocument{
+			{"create", writer.options.collection},
+			{"capped", true},
+			{"size", writer.options.capped},
+			{"$db", writer.options.database},
+		}, nil)
+		if mongoErr, ok := err.(*mongoError); err != nil && (!ok || mongoErr.code != mongoNamespaceExists) {
+			return err
+		}
+	}
+	if writer.options.ttl > 0 {
+		_, err := writer.command(bsonDocument{
+			{"createIndexes", writer.options.collection},
+			{"indexes", []interface{}{bsonDocument{
+				{"key", bsonDocument{{mongoTimeKey, int32(1)}}},
+				{"name", mongoTimeKey + "_ttl"},
+				{"expireAfterSeconds", int64(writer.options.ttl / time.Second)},
+			}}},
+			{"$db", writer.options.database},
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	writer.prepared = true
+	return nil
+}
+
+// command sends a command as an OP_MSG, with documents as the "documents" sequence, and
+// returns the reply. A reply which is not ok is a *mongoError.
+func (writer *mongoWriter) command(command bsonDocument, documents [][]byte) (map[string]interface{}, error) {
+	writer.requestID++
+	message := make([]byte, 16, 256)
+	binary.LittleEndian.PutUint32(message[4:], uint32(writer.requestID))
+	binary.LittleEndian.PutUint32(message[12:], mongoOpMsg)
+	message = append(message, 0, 0, 0, 0) // flagBits
+	message = append(message, 0)
+	message = appendBsonDocument(message, command)
+	if len(documents) > 0 {
+		start := len(message) + 1
+		message = append(message, 1, 0, 0, 0, 0)
+		message = append(message, "documents\x00"...)
+		for _, document := range documents {
+			message = append(message, document...)
+		}
+		binary.LittleEndian.PutUint32(message[start:], uint32(len(message)-start))
+	}
+	binary.LittleEndian.PutUint32(message, uint32(len(message)))
+
+	writer.conn.SetDeadline(time.Now().Add(writer.options.timeout))
+	if _, err := writer.conn.Write(message); err != nil {
+		return nil, err
+	}
+
+	var header [16]byte
+	if _, err := io.ReadFull(writer.conn, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[:])
+	if size < 21 || size > mongoMaxMessageSize || binary.LittleEndian.Uint32(header[12:]) != mongoOpMsg {
+		return nil, errors.New("Unexpected MongoDB reply")
+	}
+	body := make([]byte, size-16)
+	if _, err := io.ReadFull(writer.conn, body); err != nil {
+		return nil, err
+	}
+	if body[4] != 0 {
+		return nil, errors.New("Unexpected MongoDB reply section")
+	}
+	reply, _, err := readBsonDocument(body[5:])
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := bsonNumber(reply["ok"]); ok != 1 {
+		return nil, newMongoError(reply)
+	}
+	return reply, nil
+}
+
+func (writer *mongoWriter) disconnect() {
+	if writer.conn != nil {
+		writer.conn.Close()
+		writer.conn = nil
+	}
+}
+
+// mongoError is an error which the server replied with.
+type mongoError struct {
+	code    int64
+	message string
+}
+
+func newMongoError(document map[string]interface{}) *mongoError {
+	code, _ := bsonNumber(document["code"])
+	message, _ := document["errmsg"].(string)
+	return &mongoError{code: code, message: message}
+}
+
+func (err *mongoError) Error() string {
+	return fmt.Sprintf("%s (code %d)", err.message, err.code)
+}
+
+func (writer *mongoWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.insertBatch(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *mongoWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	err := writer.insertBatch()
+	writer.disconnect()
+	return err
+}
+
+func (writer *mongoWriter) String() string {
+	return fmt.Sprintf("mongoWriter: [%s, %s.%s, %d, %s], format: %s\n", writer.addr, writer.options.database,
+		writer.options.collection, writer.options.maxCount, writer.options.maxInterval, writer.formatter)
+}
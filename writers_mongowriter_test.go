@@ -0,0 +1,290 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mongoTestServer answers OP_MSG commands of mongoWriter, authenticating users with
+// password if it is set. It stores inserted documents by _id, rejects documents with the
+// message "invalid" and drops the connection after storing the documents of the first
+// insert if dropFirst is set.
+type mongoTestServer struct {
+	listener  net.Listener
+	password  string
+	dropFirst bool
+
+	mutex     sync.Mutex
+	conns     int
+	commands  []map[string]interface{}
+	documents []map[string]interface{}
+	ids       map[string]bool
+}
+
+func newMongoTestServer(t *testing.T, password string, dropFirst bool) *mongoTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &mongoTestServer{listener: listener, password: password, dropFirst: dropFirst, ids: make(map[string]bool)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mutex.Lock()
+			server.conns++
+			server.mutex.Unlock()
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (server *mongoTestServer) addr() string {
+	return server.listener.Addr().String()
+}
+
+func (server *mongoTestServer) Close() {
+	server.listener.Close()
+}
+
+func (server *mongoTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	authenticated := server.password == ""
+	var scram *scramTestServer
+	for {
+		var header [16]byte
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return
+		}
+		body := make([]byte, binary.LittleEndian.Uint32(header[:])-16)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		command, size, err := readBsonDocument(body[5:])
+		if err != nil {
+			return
+		}
+		var documents []map[string]interface{}
+		if sequence := body[5+size:]; len(sequence) > 0 {
+			sequence = sequence[5+len("documents\x00"):]
+			for len(sequence) > 0 {
+				document, size, err := readBsonDocument(sequence)
+				if err != nil {
+					return
+				}
+				documents = append(documents, document)
+				sequence = sequence[size:]
+			}
+		}
+
+		server.mutex.Lock()
+		server.commands = append(server.commands, command)
+		reply := bsonDocument{{"ok", float64(1)}}
+		drop := false
+		switch {
+		case command["saslStart"] != nil:
+			scram = &scramTestServer{password: server.password}
+			payload, _ := command["payload"].([]byte)
+			reply = append(reply, bsonElement{"conversationId", int32(1)},
+				bsonElement{"payload", []byte(scram.first(string(payload)))}, bsonElement{"done", false})
+		case command["saslContinue"] != nil:
+			payload, _ := command["payload"].([]byte)
+			serverFinal, ok := scram.final(string(payload))
+			if !ok {
+				reply = bsonDocument{{"ok", float64(0)}, {"errmsg", "Authentication failed."}, {"code", int32(18)}}
+				break
+			}
+			authenticated = true
+			reply = append(reply, bsonElement{"conversationId", int32(1)},
+				bsonElement{"payload", []byte(serverFinal)}, bsonElement{"done", true})
+		case !authenticated:
+			reply = bsonDocument{{"ok", float64(0)}, {"errmsg", "command requires authentication"}, {"code", int32(13)}}
+		case command["insert"] != nil:
+			var writeErrors []interface{}
+			n := int32(0)
+			for i, document := range documents {
+				id := string(document["_id"].([]byte))
+				switch {
+				case server.ids[id]:
+					writeErrors = append(writeErrors, bsonDocument{{"index", int32(i)}, {"code", int32(mongoDuplicateKey)},
+						{"errmsg", "E11000 duplicate key error"}})
+				case document["message"] == "invalid":
+					writeErrors = append(writeErrors, bsonDocument{{"index", int32(i)}, {"code", int32(121)},
+						{"errmsg", "Document failed validation"}})
+				default:
+					server.ids[id] = true
+					server.documents = append(server.documents, document)
+					n++
+				}
+			}
+			reply = append(reply, bsonElement{"n", n})
+			if writeErrors != nil {
+				reply = append(reply, bsonElement{"writeErrors", writeErrors})
+			}
+			drop = server.dropFirst
+			server.dropFirst = false
+		}
+		server.mutex.Unlock()
+		if drop {
+			return
+		}
+
+		message := make([]byte, 16)
+		binary.LittleEndian.PutUint32(message[8:], binary.LittleEndian.Uint32(header[4:]))
+		binary.LittleEndian.PutUint32(message[12:], mongoOpMsg)
+		message = append(message, 0, 0, 0, 0, 0)
+		message = appendBsonDocument(message, reply)
+		binary.LittleEndian.PutUint32(message, uint32(len(message)))
+		conn.Write(message)
+	}
+}
+
+func TestMongoInsert(t *testing.T) {
+	server := newMongoTestServer(t, "s3cret", false)
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<mongodb addr="`+server.addr()+`" database="app" user="logger" password="s3cret"
+		ttl="24h" w="majority" journal="true" wtimeout="5s" maxcount="2"/>`})
+	logger.Infow("one", Str("user", "alice"), Group("http", Int("status", 200)), Str("level", "ignored"))
+	logger.Warn("two")
+	logger.Error("three")
+	logger.Close()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.documents) != 3 {
+		t.Fatalf("Expected 3 documents, got %v", server.documents)
+	}
+	first := server.documents[0]
+	if first["level"] != "info" || first["message"] != "one" || first["user"] != "alice" ||
+		first["http"].(map[string]interface{})["status"] != int64(200) {
+		t.Errorf("Unexpected document: %v", first)
+	}
+	if callTime, ok := first["time"].(time.Time); !ok || time.Since(callTime) > time.Minute {
+		t.Errorf("Unexpected time: %v", first["time"])
+	}
+
+	var names []string
+	for _, command := range server.commands {
+		for _, name := range []string{"saslStart", "saslContinue", "createIndexes", "insert"} {
+			if command[name] != nil {
+				names = append(names, name)
+			}
+		}
+	}
+	if strings.Join(names, ",") != "saslStart,saslContinue,createIndexes,insert,insert" {
+		t.Errorf("Unexpected commands: %v", names)
+	}
+	index := server.commands[2]["indexes"].([]interface{})[0].(map[string]interface{})
+	if index["expireAfterSeconds"] != int64(86400) {
+		t.Errorf("Unexpected index: %v", index)
+	}
+	concern := server.commands[3]["writeConcern"].(map[string]interface{})
+	if concern["w"] != "majority" || concern["j"] != true || concern["wtimeout"] != int64(5000) {
+		t.Errorf("Unexpected write concern: %v", concern)
+	}
+	if server.commands[0]["$db"] != "admin" || server.commands[3]["$db"] != "app" || server.commands[3]["insert"] != "logs" {
+		t.Errorf("Unexpected databases: %v, %v", server.commands[0], server.commands[3])
+	}
+}
+
+func TestMongoRetryAndRejects(t *testing.T) {
+	server := newMongoTestServer(t, "", true)
+	defer server.Close()
+
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newMongoWriter(formatter, server.addr(), mongoWriterOptions{database: "app", capped: 1 << 20,
+		maxCount: 10, maxInterval: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"one", "two"} {
+		writer.Dispatch(message, InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	}
+	writer.Flush()
+
+	// The documents of the dropped connection are not inserted twice nor reported
+	server.mutex.Lock()
+	if len(server.documents) != 2 || server.conns != 2 {
+		t.Errorf("Expected 2 documents over 2 connections, got %d over %d", len(server.documents), server.conns)
+	}
+	if server.commands[0]["create"] != "logs" || server.commands[0]["size"] != int64(1<<20) {
+		t.Errorf("Unexpected create command: %v", server.commands[0])
+	}
+	server.mutex.Unlock()
+
+	writer.Dispatch("invalid", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	writer.Dispatch("three", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	if err := writer.Close(); err == nil || !strings.Contains(err.Error(), "1 documents failed: Document failed validation") {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.documents) != 3 || server.conns != 2 {
+		t.Errorf("Expected 3 documents over 2 connections, got %d over %d", len(server.documents), server.conns)
+	}
+}
+
+func TestMongoAuthFailure(t *testing.T) {
+	server := newMongoTestServer(t, "s3cret", false)
+	defer server.Close()
+
+	formatter, _ := newFormatter("%Msg")
+	writer, _ := newMongoWriter(formatter, server.addr(), mongoWriterOptions{database: "app", user: "logger",
+		password: "wrong", maxCount: 10, maxInterval: time.Minute})
+	writer.Dispatch("lost", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	if err := writer.Close(); err == nil || !strings.Contains(err.Error(), "Authentication failed") {
+		t.Errorf("Expected an authentication error, got %v", err)
+	}
+}
+
+func TestMongoConfig(t *testing.T) {
+	invalid := []string{
+		`<mongodb database="app"/>`,
+		`<mongodb addr="mongo.local:27017"/>`,
+		`<mongodb addr="mongo.local" database="app"/>`,
+		`<mongodb addr="mongo.local:27017" database="app" capped="1024" ttl="1h"/>`,
+		`<mongodb addr="mongo.local:27017" database="app" ttl="soon"/>`,
+		`<mongodb addr="mongo.local:27017" database="app" journal="maybe"/>`,
+		`<mongodb addr="mongo.local:27017" database="app" maxcount="0"/>`,
+		`<mongodb addr="mongo.local:27017" database="app" table="logs"/>`,
+	}
+	for _, mongodb := range invalid {
+		config := `<seelog><outputs>` + mongodb + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
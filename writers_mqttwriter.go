@@ -0,0 +1,452 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMqttTopic     = "seelog/%Level"
+	defaultMqttKeepAlive = 60 * time.Second
+	defaultMqttTimeout   = 10 * time.Second
+	mqttMaxTopic         = 65535
+	mqttMaxPacket        = 268435455
+
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttPubAck     = 0x40
+	mqttPubRec     = 0x50
+	mqttPubRel     = 0x62 // PUBREL has the reserved flags 0010
+	mqttPubComp    = 0x70
+	mqttPingReq    = 0xc0
+	mqttPingResp   = 0xd0
+	mqttDisconnect = 0xe0
+)
+
+var mqttConnAckErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// mqttWriter publishes messages to an MQTT broker with MQTT 3.1.1, speaking the protocol
+// itself. Every message is published with the formatted message as payload to the topic
+// which the topic format gives for it, at QoS 0, 1 or 2; with QoS 1 and 2 a publish
+// waits until the broker acknowledges it. The connection is opened at the first message,
+// over TLS if tlsConfig is set, and kept alive with pings when no message is published
+// for keepAlive.
+//
+// A publish which fails on the connection is sent again once on a new connection, as a
+// duplicate; if that fails too the message is dropped and reported.
+type mqttWriter struct {
+	formatter *formatter
+	addr      string
+	topic     *formatter
+	options   mqttWriterOptions
+
+	mutex    sync.Mutex
+	conn     net.Conn
+	reader   *bufio.Reader
+	packetID uint16
+	ping     *time.Timer
+	closed   bool
+}
+
+// mqttWriterOptions are the optional settings of an mqtt receiver. Zero values other
+// than qos are replaced with the defaults.
+type mqttWriterOptions struct {
+	topic     string
+	qos       byte
+	retain    bool
+	clientID  string
+	user      string
+	password  string
+	tlsConfig *tls.Config // nil for plain tcp
+	keepAlive time.Duration
+	timeout   time.Duration
+}
+
+// parseMqttURL returns the address of an mqtt, mqtts, tcp, ssl or tls url, whether it
+// uses TLS, and its user and password, if any. The port defaults to 1883, or 8883 over
+// TLS.
+func parseMqttURL(brokerURL string) (addr string, useTLS bool, user, password string, err error) {
+	parsed, err := url.Parse(brokerURL)
+	if err != nil {
+		return "", false, "", "", err
+	}
+	port := "1883"
+	switch parsed.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl", "tls":
+		useTLS = true
+		port = "8883"
+	default:
+		return "", false, "", "", errors.New("MQTT broker url must be an mqtt or mqtts url: " + brokerURL)
+	}
+	if parsed.Hostname() == "" {
+		return "", false, "", "", errors.New("MQTT broker url has no host: " + brokerURL)
+	}
+	if parsed.Port() != "" {
+		port = parsed.Port()
+	}
+	if parsed.User != nil {
+		user = parsed.User.Username()
+		password, _ = parsed.User.Password()
+	}
+	return net.JoinHostPort(parsed.Hostname(), port), useTLS, user, password, nil
+}
+
+func newMqttWriter(formatter *formatter, addr string, options mqttWriterOptions) (*mqttWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if addr == "" {
+		return nil, errors.New("MQTT broker address can not be empty")
+	}
+	if options.topic == "" {
+		options.topic = defaultMqttTopic
+	}
+	if options.qos > 2 {
+		return nil, fmt.Errorf("MQTT QoS must be 0, 1 or 2. Got: %d", options.qos)
+	}
+	if options.clientID == "" {
+		host, _ := os.Hostname()
+		options.clientID = "seelog-" + host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if options.password != "" && options.user == "" {
+		return nil, errors.New("MQTT password needs a user")
+	}
+	if options.keepAlive == 0 {
+		options.keepAlive = defaultMqttKeepAlive
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultMqttTimeout
+	}
+	if options.keepAlive < 0 || options.keepAlive > 65535*time.Second || options.timeout < 0 {
+		return nil, errors.New("MQTT keepalive must be between 1s and 65535s, and timeout can not be negative")
+	}
+	topic, err := newFormatter(options.topic)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mqttWriter{formatter: formatter, addr: addr, topic: topic, options: options}, nil
+}
+
+func (writer *mqttWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	topic := writer.topic.formatOwn(message, level, context)
+	if topic == "" || len(topic) > mqttMaxTopic || strings.ContainsAny(topic, "+#\x00") {
+		errorFunc(fmt.Errorf("Cannot publish to MQTT topic '%s': topics can not be empty or contain wildcards", topic))
+		return
+	}
+	payload := strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n")
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("MQTT writer is closed"))
+		return
+	}
+
+	writer.packetID++
+	if writer.packetID == 0 {
+		writer.packetID = 1
+	}
+	err := writer.publish(topic, payload, false)
+	if _, refused := err.(mqttRefusedError); err != nil && !refused {
+		// The connection may be stale
+		writer.disconnect()
+		err = writer.publish(topic, payload, true)
+	}
+	if err != nil {
+		writer.disconnect()
+		errorFunc(fmt.Errorf("Cannot publish to MQTT topic %s: %s", topic, err))
+	}
+}
+
+// publish publishes a message with the current packet id and waits for the
+// acknowledgement its QoS requires.
+func (writer *mqttWriter) publish(topic, payload string, dup bool) error {
+	if writer.conn == nil {
+		if err := writer.connect(); err != nil {
+			return err
+		}
+	}
+
+	flags := writer.options.qos << 1
+	if dup && writer.options.qos > 0 {
+		flags |= 0x08
+	}
+	if writer.options.retain {
+		flags |= 0x01
+	}
+	body := appendMqttString(nil, topic)
+	if writer.options.qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, writer.packetID)
+	}
+	body = append(body, payload...)
+	if err := writer.send(mqttPublish|flags, body); err != nil {
+		return err
+	}
+
+	switch writer.options.qos {
+	case 1:
+		return writer.expect(mqttPubAck, writer.packetID)
+	case 2:
+		if err := writer.expect(mqttPubRec, writer.packetID); err != nil {
+			return err
+		}
+		if err := writer.send(mqttPubRel, binary.BigEndian.AppendUint16(nil, writer.packetID)); err != nil {
+			return err
+		}
+		return writer.expect(mqttPubComp, writer.packetID)
+	}
+	return nil
+}
+
+// mqttRefusedError is a CONNACK refusing the connection.
+type mqttRefusedError string
+
+func (err mqttRefusedError) Error() string {
+	return "connection refused: " + string(err)
+}
+
+func (writer *mqttWriter) connect() error {
+	dialer := &net.Dialer{Timeout: writer.options.timeout, KeepAlive: 30 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if writer.options.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", writer.addr, writer.options.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", writer.addr)
+	}
+	if err != nil {
+		return err
+	}
+	writer.conn = conn
+	writer.reader = bufio.NewReader(conn)
+
+	flags := byte(0x02) // Clean session
+	if writer.options.user != "" {
+		flags |= 0x80
+	}
+	if writer.options.password != "" {
+		flags |= 0x40
+	}
+	body := appendMqttString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(writer.options.keepAlive/time.Second))
+	body = appendMqttString(body, writer.options.clientID)
+	if writer.options.user != "" {
+		body = appendMqttString(body, writer.options.user)
+	}
+	if writer.options.password != "" {
+		body = appendMqttString(body, writer.options.password)
+	}
+	if err = writer.send(mqttConnect, body); err == nil {
+		var ack []byte
+		ack, err = writer.receive(mqttConnAck)
+		if err == nil && (len(ack) != 2 || ack[1] != 0) {
+			reason := "malformed CONNACK"
+			if len(ack) == 2 {
+				reason = mqttConnAckErrors[ack[1]]
+				if reason == "" {
+					reason = "return code " + strconv.Itoa(int(ack[1]))
+				}
+			}
+			err = mqttRefusedError(reason)
+		}
+	}
+	if err != nil {
+		writer.disconnect()
+		return err
+	}
+	return nil
+}
+
+// send writes a packet and restarts the keep alive timer.
+func (writer *mqttWriter) send(header byte, body []byte) error {
+	if len(body) > mqttMaxPacket {
+		return fmt.Errorf("MQTT packet of %d bytes is too large", len(body))
+	}
+	packet := make([]byte, 0, len(body)+5)
+	packet = append(packet, header)
+	for length := len(body); ; {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+
+	writer.conn.SetWriteDeadline(time.Now().Add(writer.options.timeout))
+	if _, err := writer.conn.Write(packet); err != nil {
+		return err
+	}
+	if writer.ping == nil {
+		writer.ping = time.AfterFunc(writer.options.keepAlive, writer.pingOnTimer)
+	} else {
+		writer.ping.Reset(writer.options.keepAlive)
+	}
+	return nil
+}
+
+// receive reads the next packet, which must be of the given type, and returns its body.
+func (writer *mqttWriter) receive(packetType byte) ([]byte, error) {
+	writer.conn.SetReadDeadline(time.Now().Add(writer.options.timeout))
+	header, err := writer.reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		digit, err := writer.reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return nil, errors.New("malformed MQTT packet length")
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(writer.reader, body); err != nil {
+		return nil, err
+	}
+	if header&0xf0 != packetType&0xf0 {
+		return nil, fmt.Errorf("unexpected MQTT packet type %d", header>>4)
+	}
+	return body, nil
+}
+
+// expect receives an acknowledgement of a packet id.
+func (writer *mqttWriter) expect(packetType byte, packetID uint16) error {
+	for {
+		body, err := writer.receive(packetType)
+		if err != nil {
+			return err
+		}
+		if len(body) != 2 {
+			return errors.New("malformed MQTT acknowledgement")
+		}
+		// Acknowledgements of publishes which were given up on may still come
+		if binary.BigEndian.Uint16(body) == packetID {
+			return nil
+		}
+	}
+}
+
+func (writer *mqttWriter) pingOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.conn == nil {
+		return
+	}
+	err := writer.send(mqttPingReq, nil)
+	if err == nil {
+		_, err = writer.receive(mqttPingResp)
+	}
+	if err != nil {
+		// The next message connects again
+		writer.disconnect()
+	}
+}
+
+func appendMqttString(buf []byte, str string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(str)))
+	return append(buf, str...)
+}
+
+func (writer *mqttWriter) disconnect() {
+	if writer.ping != nil {
+		writer.ping.Stop()
+		writer.ping = nil
+	}
+	if writer.conn != nil {
+		writer.conn.Close()
+		writer.conn = nil
+		writer.reader = nil
+	}
+}
+
+func (writer *mqttWriter) Flush() {
+}
+
+func (writer *mqttWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	var err error
+	if writer.conn != nil {
+		err = writer.send(mqttDisconnect, nil)
+	}
+	writer.disconnect()
+	return err
+}
+
+func (writer *mqttWriter) String() string {
+	return fmt.Sprintf("mqttWriter: [%s, %s, qos %d], format: %s\n", writer.addr, writer.options.topic,
+		writer.options.qos, writer.formatter)
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mqttTestPublish struct {
+	topic   string
+	payload string
+	qos     byte
+	dup     bool
+	retain  bool
+}
+
+// mqttTestBroker accepts MQTT connections, acknowledges publishes as their QoS requires
+// and answers pings. With dropFirst the first connection is closed at its first publish.
+type mqttTestBroker struct {
+	listener   net.Listener
+	returnCode byte
+	dropFirst  bool
+
+	mutex     sync.Mutex
+	connects  []string // Client id, user and password
+	publishes []mqttTestPublish
+	pings     int
+}
+
+func newMqttTestBroker(t *testing.T) *mqttTestBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := &mqttTestBroker{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go broker.serve(conn)
+		}
+	}()
+	return broker
+}
+
+func readMqttTestPacket(reader *bufio.Reader) (byte, []byte, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for {
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(reader, body)
+	return header, body, err
+}
+
+func readMqttTestString(body []byte) (string, []byte) {
+	length := int(binary.BigEndian.Uint16(body))
+	return string(body[2 : 2+length]), body[2+length:]
+}
+
+func (broker *mqttTestBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		header, body, err := readMqttTestPacket(reader)
+		if err != nil {
+			return
+		}
+		broker.mutex.Lock()
+		switch header & 0xf0 {
+		case mqttConnect:
+			_, rest := readMqttTestString(body) // Protocol name
+			flags := rest[1]
+			clientID, rest := readMqttTestString(rest[4:])
+			connect := clientID
+			if flags&0x80 != 0 {
+				var user string
+				user, rest = readMqttTestString(rest)
+				connect += " " + user
+			}
+			if flags&0x40 != 0 {
+				password, _ := readMqttTestString(rest)
+				connect += " " + password
+			}
+			broker.connects = append(broker.connects, connect)
+			conn.Write([]byte{mqttConnAck, 2, 0, broker.returnCode})
+		case mqttPublish:
+			if broker.dropFirst {
+				broker.dropFirst = false
+				broker.mutex.Unlock()
+				return
+			}
+			topic, rest := readMqttTestString(body)
+			publish := mqttTestPublish{topic: topic, qos: header >> 1 & 3, dup: header&0x08 != 0, retain: header&1 != 0}
+			if publish.qos > 0 {
+				id := rest[:2]
+				rest = rest[2:]
+				if publish.qos == 1 {
+					conn.Write([]byte{mqttPubAck, 2, id[0], id[1]})
+				} else {
+					conn.Write([]byte{mqttPubRec, 2, id[0], id[1]})
+				}
+			}
+			publish.payload = string(rest)
+			broker.publishes = append(broker.publishes, publish)
+		case mqttPubRel & 0xf0:
+			conn.Write([]byte{mqttPubComp, 2, body[0], body[1]})
+		case mqttPingReq:
+			broker.pings++
+			conn.Write([]byte{mqttPingResp, 0})
+		case mqttDisconnect:
+			broker.mutex.Unlock()
+			return
+		}
+		broker.mutex.Unlock()
+	}
+}
+
+func (broker *mqttTestBroker) sent() ([]string, []mqttTestPublish, int) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	return append([]string(nil), broker.connects...), append([]mqttTestPublish(nil), broker.publishes...), broker.pings
+}
+
+func TestMqttPublish(t *testing.T) {
+	broker := newMqttTestBroker(t)
+	defer broker.listener.Close()
+	broker.dropFirst = true
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<mqtt formatid="msg" url="mqtt://gateway:secret@`+broker.listener.Addr().String()+`"
+		topic="devices/%Field(device)/%Level" qos="1" retain="true" clientid="edge-7"/>`})
+	logger.Infow("booted", Str("device", "d1"))
+	logger.Errorw("overheating", Str("device", "d2"))
+	logger.Infow("bad topic", Str("device", "+"))
+	logger.Close()
+
+	connects, publishes, _ := broker.sent()
+	if len(connects) != 2 || connects[0] != "edge-7 gateway secret" {
+		t.Errorf("Expected a connection again after the first one dropped, got %q", connects)
+	}
+	expected := []mqttTestPublish{
+		{topic: "devices/d1/Info", payload: "booted", qos: 1, dup: true, retain: true},
+		{topic: "devices/d2/Error", payload: "overheating", qos: 1, retain: true},
+	}
+	if len(publishes) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, publishes)
+	}
+	for i := range expected {
+		if publishes[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], publishes[i])
+		}
+	}
+}
+
+func TestMqttExactlyOnceAndPings(t *testing.T) {
+	broker := newMqttTestBroker(t)
+	defer broker.listener.Close()
+
+	writer, err := newMqttWriter(mustNewMsgFormatter(t), broker.listener.Addr().String(),
+		mqttWriterOptions{qos: 2, keepAlive: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	context, _ := currentContext()
+	writer.Dispatch("first", WarnLvl, context, func(err error) { t.Error(err) })
+	time.Sleep(100 * time.Millisecond)
+	writer.Dispatch("second", WarnLvl, context, func(err error) { t.Error(err) })
+	writer.Close()
+
+	connects, publishes, pings := broker.sent()
+	if len(connects) != 1 || !strings.HasPrefix(connects[0], "seelog-") {
+		t.Errorf("Expected one connection with the default client id, got %q", connects)
+	}
+	if len(publishes) != 2 || publishes[1].topic != "seelog/Warn" || publishes[1].qos != 2 || publishes[1].payload != "second" {
+		t.Errorf("Unexpected publishes: %+v", publishes)
+	}
+	if pings == 0 {
+		t.Error("Expected pings while idle")
+	}
+}
+
+func TestMqttRefused(t *testing.T) {
+	broker := newMqttTestBroker(t)
+	defer broker.listener.Close()
+	broker.returnCode = 5
+
+	writer, err := newMqttWriter(mustNewMsgFormatter(t), broker.listener.Addr().String(), mqttWriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	context, _ := currentContext()
+	var dispatchErr error
+	writer.Dispatch("first", InfoLvl, context, func(err error) { dispatchErr = err })
+	if dispatchErr == nil || !strings.Contains(dispatchErr.Error(), "not authorized") {
+		t.Errorf("Expected the refusal, got %v", dispatchErr)
+	}
+	if connects, _, _ := broker.sent(); len(connects) != 1 {
+		t.Errorf("Expected no second attempt after a refusal, got %q", connects)
+	}
+}
+
+func mustNewMsgFormatter(t *testing.T) *formatter {
+	formatter, err := newFormatter("%Msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return formatter
+}
+
+func TestMqttConfig(t *testing.T) {
+	invalid := []string{
+		`<mqtt/>`,
+		`<mqtt url="http://broker.local"/>`,
+		`<mqtt url="mqtt://"/>`,
+		`<mqtt url="mqtt://broker.local" qos="3"/>`,
+		`<mqtt url="mqtt://broker.local" retain="maybe"/>`,
+		`<mqtt url="mqtt://broker.local" keepalive="100000s"/>`,
+		`<mqtt url="mqtt://broker.local" password="secret"/>`,
+		`<mqtt url="mqtt://broker.local" topic="%Unknown"/>`,
+	}
+	for _, mqtt := range invalid {
+		config := `<seelog><outputs>` + mqtt + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+
+	addr, useTLS, user, password, err := parseMqttURL("mqtts://u:p@broker.local")
+	if err != nil || addr != "broker.local:8883" || !useTLS || user != "u" || password != "p" {
+		t.Errorf("Unexpected url parts: %s %v %s %s %v", addr, useTLS, user, password, err)
+	}
+}
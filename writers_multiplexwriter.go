@@ -0,0 +1,116 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Multiplexed stdout framings.
+const (
+	multiplexTextFraming   = "text"
+	multiplexBinaryFraming = "binary"
+)
+
+// multiplexChannels are the channels of the multiplexed stdout, with their ids in the
+// binary framing (those of Docker's attach protocol).
+var multiplexChannels = map[string]byte{
+	"stdout": 1,
+	"stderr": 2,
+}
+
+// multiplexMutex serializes the writes of all multiplexed outputs, so that the frames of
+// the streams sharing stdout never interleave.
+var multiplexMutex sync.Mutex
+
+// multiplexWriter writes messages to stdout tagged with their stream, so that several
+// kinds of logs of a process (application, access, audit...) can share a single pipe
+// and be separated again by whatever reads it.
+//
+// In the text framing every line of a message is written as "stream channel line". In
+// the binary framing, the one of Docker's multiplexed streams, a message is a frame: the
+// channel id byte, three zero bytes, the big-endian 32-bit payload size and the payload,
+// which is the stream tag, a space and the message.
+type multiplexWriter struct {
+	stream  string
+	channel string
+	framing string
+}
+
+func newMultiplexWriter(stream, channel, framing string) (*multiplexWriter, error) {
+	if stream == "" || strings.ContainsAny(stream, " \t\r\n") {
+		return nil, errors.New("Multiplexed stream tag must be a non-empty word: '" + stream + "'")
+	}
+	if channel == "" {
+		channel = "stdout"
+	}
+	if _, ok := multiplexChannels[channel]; !ok {
+		return nil, errors.New("Unknown multiplexed channel: " + channel)
+	}
+	if framing == "" {
+		framing = multiplexTextFraming
+	}
+	if framing != multiplexTextFraming && framing != multiplexBinaryFraming {
+		return nil, errors.New("Unknown multiplexed framing: " + framing)
+	}
+
+	return &multiplexWriter{stream: stream, channel: channel, framing: framing}, nil
+}
+
+func (writer *multiplexWriter) Write(data []byte) (int, error) {
+	frame := new(bytes.Buffer)
+	if writer.framing == multiplexBinaryFraming {
+		payload := writer.stream + " " + string(data)
+		var header [8]byte
+		header[0] = multiplexChannels[writer.channel]
+		binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+		frame.Write(header[:])
+		frame.WriteString(payload)
+	} else {
+		prefix := writer.stream + " " + writer.channel + " "
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\r\n"), "\n") {
+			frame.WriteString(prefix + strings.TrimRight(line, "\r") + "\n")
+		}
+	}
+
+	multiplexMutex.Lock()
+	defer multiplexMutex.Unlock()
+
+	_, err := os.Stdout.Write(frame.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (writer *multiplexWriter) String() string {
+	return fmt.Sprintf("multiplexWriter: [%s, %s, %s]", writer.stream, writer.channel, writer.framing)
+}
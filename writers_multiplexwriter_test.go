@@ -0,0 +1,98 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func captureMultiplexOutput(t *testing.T, outputs string, log func(logger LoggerInterface)) string {
+	path := filepath.Join(t.TempDir(), "stdout")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	stdout := os.Stdout
+	os.Stdout = file
+	defer func() { os.Stdout = stdout }()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: outputs})
+	log(logger)
+	logger.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMultiplexText(t *testing.T) {
+	output := captureMultiplexOutput(t,
+		`<multiplex stream="app"/><filter levels="warn"><multiplex stream="audit" channel="stderr"/></filter>`,
+		func(logger LoggerInterface) {
+			logger.Info("started")
+			logger.Warn("two\nlines")
+		})
+
+	expected := "app stdout started\n" +
+		"app stdout two\napp stdout lines\n" +
+		"audit stderr two\naudit stderr lines\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestMultiplexBinary(t *testing.T) {
+	output := captureMultiplexOutput(t, `<multiplex stream="access" channel="stderr" framing="binary"/>`,
+		func(logger LoggerInterface) {
+			logger.Info("GET /")
+		})
+
+	expected := "\x02\x00\x00\x00\x00\x00\x00\x0daccess GET /\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestMultiplexConfig(t *testing.T) {
+	invalid := []string{
+		`<multiplex/>`,
+		`<multiplex stream="two words"/>`,
+		`<multiplex stream="app" channel="stdin"/>`,
+		`<multiplex stream="app" framing="json"/>`,
+	}
+	for _, multiplex := range invalid {
+		config := `<seelog><outputs>` + multiplex + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
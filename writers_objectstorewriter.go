@@ -0,0 +1,287 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultObjectStoreMaxSize     = 8 << 20
+	defaultObjectStoreMaxInterval = time.Minute
+	defaultObjectStoreRegion      = "us-east-1"
+)
+
+// objectStoreWriter collects messages in memory and uploads them as objects to
+// S3-compatible storage, without a local file in between. An object is uploaded when it
+// reaches maxSize bytes, maxInterval after its first message, on Flush and on Close.
+// Messages are never split between objects.
+//
+// Objects are named prefix + UTC time of the first message + host + pid + sequence
+// number.
+type objectStoreWriter struct {
+	store       *s3Bucket
+	prefix      string
+	maxSize     int
+	maxInterval time.Duration
+
+	mutex   sync.Mutex
+	buffer  bytes.Buffer
+	started time.Time
+	timer   *time.Timer
+	seq     int
+	host    string
+	closed  bool
+}
+
+func newObjectStoreWriter(endpoint, bucket, prefix, region, accessKey, secretKey string,
+	maxSize int, maxInterval time.Duration) (*objectStoreWriter, error) {
+	store, err := newS3Bucket(endpoint, bucket, region, accessKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("maxSize can not be less or equal to 0. Got: %d", maxSize)
+	}
+	if maxInterval <= 0 {
+		return nil, fmt.Errorf("maxInterval can not be less or equal to 0. Got: %s", maxInterval)
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+
+	return &objectStoreWriter{
+		store:       store,
+		prefix:      prefix,
+		maxSize:     maxSize,
+		maxInterval: maxInterval,
+		host:        host,
+	}, nil
+}
+
+func (writer *objectStoreWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return 0, errors.New("Object store writer is closed")
+	}
+
+	var err error
+	if writer.buffer.Len() > 0 && writer.buffer.Len()+len(data) > writer.maxSize {
+		err = writer.uploadBuffer()
+	}
+	if writer.buffer.Len() == 0 {
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.maxInterval, writer.uploadOnTimer)
+	}
+	writer.buffer.Write(data)
+	if writer.buffer.Len() >= writer.maxSize {
+		if uploadErr := writer.uploadBuffer(); err == nil {
+			err = uploadErr
+		}
+	}
+
+	return len(data), err
+}
+
+func (writer *objectStoreWriter) uploadOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.buffer.Len() > 0 && time.Since(writer.started) >= writer.maxInterval {
+		if err := writer.uploadBuffer(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// uploadBuffer uploads the collected messages as an object and starts a new one. The
+// messages are dropped if the upload fails, so that a lasting outage does not grow the
+// buffer without bound.
+func (writer *objectStoreWriter) uploadBuffer() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	if writer.buffer.Len() == 0 {
+		return nil
+	}
+
+	writer.seq++
+	key := writer.prefix + writer.started.UTC().Format("20060102T150405.000000000Z") + "-" +
+		writer.host + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.Itoa(writer.seq) + ".log"
+	data := writer.buffer.Bytes()
+	err := writer.store.put(key, data, "text/plain; charset=utf-8", time.Now())
+	if err != nil {
+		err = fmt.Errorf("Cannot upload %d bytes of messages to %s: %s", len(data), key, err)
+	}
+	writer.buffer.Reset()
+
+	return err
+}
+
+// s3Bucket puts objects into a bucket of S3-compatible storage with path-style requests
+// (endpoint/bucket/key) signed with AWS Signature Version 4. Without an access key
+// requests are not signed.
+type s3Bucket struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func newS3Bucket(endpoint, bucket, region, accessKey, secretKey string) (*s3Bucket, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" || endpointURL.Host == "" {
+		return nil, errors.New("Object store endpoint must be an http or https url: " + endpoint)
+	}
+	if bucket == "" {
+		return nil, errors.New("Object store bucket can not be empty")
+	}
+	if region == "" {
+		region = defaultObjectStoreRegion
+	}
+
+	return &s3Bucket{
+		endpoint:  endpointURL,
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (store *s3Bucket) put(key string, data []byte, contentType string, now time.Time) error {
+	objectURL := *store.endpoint
+	objectURL.Path = strings.TrimRight(store.endpoint.Path, "/") + "/" + store.bucket + "/" + key
+	objectURL.RawPath = s3EscapePath(objectURL.Path)
+
+	request, err := http.NewRequest(http.MethodPut, objectURL.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	store.sign(request, data, now)
+
+	response, err := store.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 headers to a request.
+func (store *s3Bucket) sign(request *http.Request, data []byte, now time.Time) {
+	if store.accessKey == "" {
+		return
+	}
+
+	request.Header.Set("X-Amz-Content-Sha256", sha256Hex(data))
+	signAwsRequest(request, data, &awsCredentials{accessKey: store.accessKey, secretKey: store.secretKey},
+		store.region, "s3", now)
+}
+
+func (store *s3Bucket) String() string {
+	return fmt.Sprintf("%s/%s", store.endpoint, store.bucket)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath escapes an object path the way Signature Version 4 expects: everything
+// but unreserved characters and '/' is percent-encoded.
+func s3EscapePath(path string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			escaped.WriteByte(c)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+func (writer *objectStoreWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.uploadBuffer(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *objectStoreWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.uploadBuffer()
+}
+
+func (writer *objectStoreWriter) String() string {
+	return fmt.Sprintf("objectStoreWriter: %s/%s, maxSize: %d, maxInterval: %s",
+		writer.store, writer.prefix, writer.maxSize, writer.maxInterval)
+}
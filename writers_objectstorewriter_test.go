@@ -0,0 +1,170 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type objectStoreTestServer struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	objects map[string]string
+	auth    []string
+}
+
+func newObjectStoreTestServer() *objectStoreTestServer {
+	server := &objectStoreTestServer{objects: make(map[string]string)}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		server.objects[r.URL.Path] = string(body)
+		server.auth = append(server.auth, r.Header.Get("Authorization"))
+	}))
+	return server
+}
+
+func (server *objectStoreTestServer) contents() (map[string]string, []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	objects := make(map[string]string)
+	for key, body := range server.objects {
+		objects[key] = body
+	}
+	return objects, append([]string(nil), server.auth...)
+}
+
+func TestObjectStoreMaxSize(t *testing.T) {
+	server := newObjectStoreTestServer()
+	defer server.Close()
+
+	writer, err := newObjectStoreWriter(server.URL, "logs", "app/", "", "AKID", "secret", 14, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := writer.Write([]byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if objects, _ := server.contents(); len(objects) != 1 {
+		t.Fatalf("Expected 1 object before close, got %v", objects)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, auth := server.contents()
+	if len(objects) != 2 {
+		t.Fatalf("Expected 2 objects, got %v", objects)
+	}
+	key := regexp.MustCompile(`^/logs/app/\d{8}T\d{6}\.\d{9}Z-.+-\d+-\d+\.log$`)
+	bodies := make(map[string]bool)
+	for path, body := range objects {
+		if !key.MatchString(path) {
+			t.Errorf("Unexpected object path: %s", path)
+		}
+		bodies[body] = true
+	}
+	if !bodies["first\nsecond\n"] || !bodies["third\n"] {
+		t.Errorf("Messages are not split at the size bound: %v", objects)
+	}
+	signature := regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=AKID/\d{8}/us-east-1/s3/aws4_request, ` +
+		`SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`)
+	for _, header := range auth {
+		if !signature.MatchString(header) {
+			t.Errorf("Unexpected authorization: %s", header)
+		}
+	}
+}
+
+func TestObjectStoreMaxInterval(t *testing.T) {
+	server := newObjectStoreTestServer()
+	defer server.Close()
+
+	writer, err := newObjectStoreWriter(server.URL, "logs", "", "", "", "", 1<<20, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte("late\n"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if objects, auth := server.contents(); len(objects) == 1 {
+			if auth[0] != "" {
+				t.Errorf("Expected an unsigned request, got %s", auth[0])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Object was not uploaded after maxinterval")
+}
+
+func TestObjectStoreConfig(t *testing.T) {
+	server := newObjectStoreTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<objectstore endpoint="`+server.URL+`/base" bucket="logs" accesskey="a" secretkey="b"/>`})
+	logger.Info("one")
+	logger.Info("two")
+	logger.Close()
+
+	objects, _ := server.contents()
+	if len(objects) != 1 {
+		t.Fatalf("Expected the object to be uploaded on close, got %v", objects)
+	}
+	for path, body := range objects {
+		if !strings.HasPrefix(path, "/base/logs/") || body != "one\ntwo\n" {
+			t.Errorf("Unexpected object %s: %q", path, body)
+		}
+	}
+
+	invalid := []string{
+		`<objectstore bucket="logs"/>`,
+		`<objectstore endpoint="ftp://host" bucket="logs"/>`,
+		`<objectstore endpoint="http://host" bucket="logs" accesskey="a"/>`,
+		`<objectstore endpoint="http://host" bucket="logs" maxsize="big"/>`,
+		`<objectstore endpoint="http://host" bucket="logs" maxinterval="soon"/>`,
+	}
+	for _, objectstore := range invalid {
+		config := `<seelog><outputs>` + objectstore + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultPagerDutyURL      = "https://events.pagerduty.com/v2/enqueue"
+	defaultPagerDutyMinLevel = CriticalLvl
+	defaultPagerDutyDedupKey = "%Func:%Line"
+	defaultPagerDutyRepeat   = 5 * time.Minute
+	defaultPagerDutyRetries  = 3
+	defaultPagerDutyBackoff  = time.Second
+	defaultPagerDutyTimeout  = 10 * time.Second
+	pagerDutyMaxSummary      = 1024
+	pagerDutyMaxDedupKey     = 255
+)
+
+// pagerDutyWriter triggers PagerDuty alerts with the Events API v2 for messages of
+// minLevel and above. The summary of an alert is the message formatted by the format of
+// the output, its custom details are the fields of the message, and its dedup key is the
+// message formatted by the dedup key format. By default this is the function and line of
+// the log call, so every log statement is one alert however its arguments vary; keys
+// longer than PagerDuty allows are replaced with their SHA-256.
+//
+// PagerDuty folds triggers with the same dedup key into one alert, so a trigger is sent
+// again for a key only after repeat. With autoResolve, an alert is resolved once no
+// message has triggered it for that long; alerts which are open on Close are left open.
+// Events which fail with 429 or 5xx, or do not reach PagerDuty, are sent again up to
+// retries times, waiting backoff, then twice as long and so on.
+type pagerDutyWriter struct {
+	formatter   *formatter
+	url         string
+	routingKey  string
+	minLevel    LogLevel
+	dedupKey    *formatter
+	source      string
+	component   string
+	group       string
+	class       string
+	autoResolve time.Duration
+	retries     int
+	backoff     time.Duration
+	throttle    *alertThrottle
+	client      *http.Client
+
+	mutex  sync.Mutex
+	open   map[string]*pagerDutyAlert
+	closed bool
+}
+
+// pagerDutyAlert is an alert waiting to be resolved.
+type pagerDutyAlert struct {
+	timer     *time.Timer
+	triggered time.Time
+}
+
+// pagerDutyWriterOptions are the optional settings of a pagerduty receiver. Zero values
+// other than minLevel, autoResolve and retries are replaced with the defaults; a negative
+// repeat sends every trigger.
+type pagerDutyWriterOptions struct {
+	url         string
+	minLevel    LogLevel
+	dedupKey    string
+	source      string
+	component   string
+	group       string
+	class       string
+	repeat      time.Duration
+	autoResolve time.Duration
+	retries     int
+	backoff     time.Duration
+	timeout     time.Duration
+}
+
+// pagerDutyEvent is an event of the Events API v2.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string          `json:"summary"`
+	Source        string          `json:"source"`
+	Severity      string          `json:"severity"`
+	Timestamp     string          `json:"timestamp"`
+	Component     string          `json:"component,omitempty"`
+	Group         string          `json:"group,omitempty"`
+	Class         string          `json:"class,omitempty"`
+	CustomDetails json.RawMessage `json:"custom_details,omitempty"`
+}
+
+func newPagerDutyWriter(formatter *formatter, routingKey string, options pagerDutyWriterOptions) (*pagerDutyWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if routingKey == "" {
+		return nil, errors.New("PagerDuty routing key can not be empty")
+	}
+	if options.url == "" {
+		options.url = defaultPagerDutyURL
+	}
+	if options.dedupKey == "" {
+		options.dedupKey = defaultPagerDutyDedupKey
+	}
+	if options.source == "" {
+		options.source, _ = os.Hostname()
+	}
+	if options.repeat == 0 {
+		options.repeat = defaultPagerDutyRepeat
+	}
+	if options.backoff == 0 {
+		options.backoff = defaultPagerDutyBackoff
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultPagerDutyTimeout
+	}
+	if options.autoResolve < 0 || options.retries < 0 || options.backoff < 0 || options.timeout < 0 {
+		return nil, errors.New("PagerDuty receiver settings can not be negative")
+	}
+	dedupKey, err := newFormatter(options.dedupKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pagerDutyWriter{
+		formatter:   formatter,
+		url:         options.url,
+		routingKey:  routingKey,
+		minLevel:    options.minLevel,
+		dedupKey:    dedupKey,
+		source:      options.source,
+		component:   options.component,
+		group:       options.group,
+		class:       options.class,
+		autoResolve: options.autoResolve,
+		retries:     options.retries,
+		backoff:     options.backoff,
+		throttle:    newAlertThrottle(options.repeat, 0, 0),
+		client:      &http.Client{Timeout: options.timeout},
+		open:        make(map[string]*pagerDutyAlert),
+	}, nil
+}
+
+func (writer *pagerDutyWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent || level < writer.minLevel {
+		return
+	}
+
+	dedupKey := strings.TrimSpace(writer.dedupKey.formatOwn(message, level, context))
+	if len(dedupKey) > pagerDutyMaxDedupKey {
+		sum := sha256.Sum256([]byte(dedupKey))
+		dedupKey = hex.EncodeToString(sum[:])
+	}
+
+	writer.mutex.Lock()
+	if writer.closed {
+		writer.mutex.Unlock()
+		errorFunc(errors.New("PagerDuty writer is closed"))
+		return
+	}
+	if writer.autoResolve > 0 {
+		if alert, isOpen := writer.open[dedupKey]; isOpen {
+			alert.triggered = time.Now()
+		} else {
+			writer.open[dedupKey] = &pagerDutyAlert{
+				timer:     time.AfterFunc(writer.autoResolve, func() { writer.resolveOnTimer(dedupKey) }),
+				triggered: time.Now(),
+			}
+		}
+	}
+	writer.mutex.Unlock()
+
+	if _, ok := writer.throttle.allow(dedupKey, context.CallTime()); !ok {
+		return
+	}
+
+	details := new(bytes.Buffer)
+	details.WriteByte('{')
+	writeJsonFields(details, context.Fields(), false)
+	details.WriteByte('}')
+	payload := &pagerDutyPayload{
+		Summary:   pagerDutySummary(writer.formatter.Format(message, level, context)),
+		Source:    writer.source,
+		Severity:  pagerDutySeverity(level),
+		Timestamp: context.CallTime().UTC().Format(time.RFC3339Nano),
+		Component: writer.component,
+		Group:     writer.group,
+		Class:     writer.class,
+	}
+	if details.Len() > 2 {
+		payload.CustomDetails = details.Bytes()
+	}
+
+	err := writer.send(&pagerDutyEvent{
+		RoutingKey:  writer.routingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload:     payload,
+	})
+	if err != nil {
+		errorFunc(err)
+	}
+}
+
+func pagerDutySummary(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) > pagerDutyMaxSummary {
+		text = string([]rune(text)[:pagerDutyMaxSummary-1]) + "…"
+	}
+	return text
+}
+
+func pagerDutySeverity(level LogLevel) string {
+	switch {
+	case level >= CriticalLvl:
+		return "critical"
+	case level >= ErrorLvl:
+		return "error"
+	case level >= WarnLvl:
+		return "warning"
+	}
+	return "info"
+}
+
+func (writer *pagerDutyWriter) resolveOnTimer(dedupKey string) {
+	writer.mutex.Lock()
+	alert, isOpen := writer.open[dedupKey]
+	if writer.closed || !isOpen {
+		writer.mutex.Unlock()
+		return
+	}
+	if wait := writer.autoResolve - time.Since(alert.triggered); wait > 0 {
+		alert.timer = time.AfterFunc(wait, func() { writer.resolveOnTimer(dedupKey) })
+		writer.mutex.Unlock()
+		return
+	}
+	delete(writer.open, dedupKey)
+	writer.mutex.Unlock()
+
+	writer.throttle.forget(dedupKey)
+	err := writer.send(&pagerDutyEvent{
+		RoutingKey:  writer.routingKey,
+		EventAction: "resolve",
+		DedupKey:    dedupKey,
+	})
+	if err != nil {
+		reportInternalError(err)
+	}
+}
+
+// send posts an event, with retries.
+func (writer *pagerDutyWriter) send(event *pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(writer.backoff << uint(attempt-1))
+		}
+		retry, err := writer.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= writer.retries {
+			return fmt.Errorf("Cannot %s PagerDuty alert %s: %s", event.EventAction, event.DedupKey, err)
+		}
+	}
+}
+
+// post sends one request. It returns whether a failed request may succeed if sent again.
+func (writer *pagerDutyWriter) post(body []byte) (bool, error) {
+	response, err := writer.client.Post(writer.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	responseBody, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+	if response.StatusCode/100 != 2 {
+		err := fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(responseBody)))
+		return response.StatusCode == http.StatusTooManyRequests || response.StatusCode/100 == 5, err
+	}
+	return false, nil
+}
+
+func (writer *pagerDutyWriter) Flush() {
+}
+
+// Close stops auto-resolving: the alerts which are open stay open.
+func (writer *pagerDutyWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.closed = true
+	for dedupKey, alert := range writer.open {
+		alert.timer.Stop()
+		delete(writer.open, dedupKey)
+	}
+	return nil
+}
+
+func (writer *pagerDutyWriter) String() string {
+	return fmt.Sprintf("pagerDutyWriter: [%s, %s], format: %s\n", writer.minLevel, writer.source, writer.formatter)
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPagerDutyTrigger(t *testing.T) {
+	server := newHttpTestServer(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<pagerduty formatid="msg" url="`+server.URL+`" routingkey="abc" source="billing-1"
+		component="billing" backoff="1ms"/>`})
+	logger.Error("ignored")
+	for i := 0; i < 3; i++ {
+		logger.Criticalw("database down", Int("attempt", i))
+	}
+	logger.Critical("disk full")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(requests) != 3 || len(bodies) != 2 {
+		t.Fatalf("Expected two events and a retry, got %q", bodies)
+	}
+	var events []pagerDutyEvent
+	for _, body := range bodies {
+		var event pagerDutyEvent
+		if err := json.Unmarshal([]byte(body), &event); err != nil {
+			t.Fatalf("Expected a JSON event, got %s: %s", body, err)
+		}
+		events = append(events, event)
+	}
+
+	event := events[0]
+	if event.RoutingKey != "abc" || event.EventAction != "trigger" || !strings.Contains(event.DedupKey, "TestPagerDutyTrigger:") {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	payload := event.Payload
+	if payload.Summary != "database down" || payload.Source != "billing-1" || payload.Severity != "critical" ||
+		payload.Component != "billing" || string(payload.CustomDetails) != `{"attempt":0}` {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+	if events[1].Payload.Summary != "disk full" || events[1].DedupKey == event.DedupKey {
+		t.Errorf("Expected another alert, got %+v", events[1])
+	}
+}
+
+func TestPagerDutyAutoResolve(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<pagerduty formatid="msg" url="`+server.URL+`" routingkey="abc"
+		dedupkey="%Field(service)" autoresolve="50ms"/>`})
+	logger.Criticalw("down", Str("service", "db"))
+	time.Sleep(30 * time.Millisecond)
+	logger.Criticalw("still down", Str("service", "db"))
+	time.Sleep(30 * time.Millisecond)
+	if _, bodies := server.sent(); len(bodies) != 1 {
+		t.Fatalf("Expected the alert to stay open, got %q", bodies)
+	}
+	time.Sleep(60 * time.Millisecond)
+	logger.Criticalw("down again", Str("service", "db"))
+	logger.Close()
+
+	_, bodies := server.sent()
+	if len(bodies) != 3 {
+		t.Fatalf("Expected trigger, resolve and trigger, got %q", bodies)
+	}
+	var resolve pagerDutyEvent
+	json.Unmarshal([]byte(bodies[1]), &resolve)
+	if resolve.EventAction != "resolve" || resolve.DedupKey != "db" || resolve.Payload != nil {
+		t.Errorf("Unexpected resolve event: %s", bodies[1])
+	}
+	if !strings.Contains(bodies[2], `"down again"`) {
+		t.Errorf("Expected a new trigger, got %s", bodies[2])
+	}
+}
+
+func TestPagerDutyConfig(t *testing.T) {
+	invalid := []string{
+		`<pagerduty/>`,
+		`<pagerduty routingkey="abc" minlevel="loud"/>`,
+		`<pagerduty routingkey="abc" dedupkey="%Unknown"/>`,
+		`<pagerduty routingkey="abc" autoresolve="soon"/>`,
+		`<pagerduty routingkey="abc" retries="-1"/>`,
+		`<pagerduty routingkey="abc" severity="high"/>`,
+		`<pagerduty routingkey="abc"><route levels="error"/></pagerduty>`,
+	}
+	for _, pagerduty := range invalid {
+		config := `<seelog><outputs>` + pagerduty + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
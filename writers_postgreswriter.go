@@ -0,0 +1,546 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPostgresTable       = "logs"
+	defaultPostgresMaxCount    = 500
+	defaultPostgresMaxInterval = time.Second
+	defaultPostgresTimeout     = 10 * time.Second
+	postgresProtocolVersion    = 3 << 16
+	postgresSSLRequestCode     = 80877103
+	postgresMaxMessageSize     = 1 << 24
+	postgresTimeValue          = "time"
+	postgresLevelValue         = "level"
+	postgresMessageValue       = "message"
+	postgresFieldsValue        = "fields"
+	postgresSourceValue        = "source"
+)
+
+// postgresColumn is a column which the postgres writer fills, with one of the values
+// time, level, message (the formatted message), fields (a JSON object of all fields)
+// and source (file:line of the call), or else with the field of the message named field,
+// NULL if the message does not have it.
+type postgresColumn struct {
+	name  string
+	value string
+	field string
+}
+
+var defaultPostgresColumns = []postgresColumn{
+	{name: postgresTimeValue, value: postgresTimeValue},
+	{name: postgresLevelValue, value: postgresLevelValue},
+	{name: postgresMessageValue, value: postgresMessageValue},
+	{name: postgresFieldsValue, value: postgresFieldsValue},
+}
+
+type postgresWriterOptions struct {
+	user        string
+	password    string
+	database    string
+	table       string
+	columns     []postgresColumn
+	tlsConfig   *tls.Config // nil for plain tcp
+	maxCount    int
+	maxInterval time.Duration
+	timeout     time.Duration
+}
+
+// postgresWriter inserts messages into a PostgreSQL table with COPY FROM STDIN, speaking
+// the frontend/backend protocol itself. It authenticates with SCRAM-SHA-256, md5 or a
+// cleartext password, over TLS if tlsConfig is set. The columns default to time
+// (timestamptz), level (text), message (text) and fields (jsonb).
+//
+// Messages are collected in batches, which are copied when they reach maxCount messages,
+// maxInterval after their first message, on Flush and on Close. A batch which fails on
+// the connection is copied again once on a new connection; a batch which the server
+// rejects, e.g. for a missing column, is dropped and reported.
+type postgresWriter struct {
+	formatter *formatter
+	addr      string
+	options   postgresWriterOptions
+	copyQuery string
+
+	mutex   sync.Mutex
+	batch   [][]byte // Rows in the text format of COPY
+	started time.Time
+	timer   *time.Timer
+	conn    net.Conn
+	reader  *bufio.Reader
+	closed  bool
+}
+
+func newPostgresWriter(formatter *formatter, addr string, options postgresWriterOptions) (*postgresWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if addr == "" {
+		return nil, errors.New("PostgreSQL address can not be empty")
+	}
+	if options.user == "" {
+		return nil, errors.New("PostgreSQL user can not be empty")
+	}
+	if options.database == "" {
+		options.database = options.user
+	}
+	if options.table == "" {
+		options.table = defaultPostgresTable
+	}
+	if len(options.columns) == 0 {
+		options.columns = defaultPostgresColumns
+	}
+	for _, column := range options.columns {
+		if column.name == "" || (column.value == "") == (column.field == "") {
+			return nil, fmt.Errorf("PostgreSQL column '%s' needs either a value or a field", column.name)
+		}
+		switch column.value {
+		case "", postgresTimeValue, postgresLevelValue, postgresMessageValue, postgresFieldsValue, postgresSourceValue:
+		default:
+			return nil, fmt.Errorf("PostgreSQL column '%s' has an unknown value '%s'", column.name, column.value)
+		}
+	}
+	if options.maxCount <= 0 {
+		return nil, fmt.Errorf("maxCount can not be less or equal to 0. Got: %d", options.maxCount)
+	}
+	if options.maxInterval <= 0 {
+		return nil, fmt.Errorf("maxInterval can not be less or equal to 0. Got: %s", options.maxInterval)
+	}
+	if options.timeout <= 0 {
+		options.timeout = defaultPostgresTimeout
+	}
+
+	names := make([]string, len(options.columns))
+	for i, column := range options.columns {
+		names[i] = postgresQuoteIdentifier(column.name)
+	}
+	copyQuery := "COPY " + postgresQuoteIdentifier(options.table) + " (" + strings.Join(names, ", ") + ") FROM STDIN"
+
+	return &postgresWriter{formatter: formatter, addr: addr, options: options, copyQuery: copyQuery}, nil
+}
+
+// postgresQuoteIdentifier quotes a name, which may be qualified with a schema.
+func postgresQuoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.Replace(part, `"`, `""`, -1) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+func (writer *postgresWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	row := writer.row(writer.formatter.Format(message, level, context), level, context)
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("PostgreSQL writer is closed"))
+		return
+	}
+
+	if len(writer.batch) == 0 {
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.options.maxInterval, writer.copyOnTimer)
+	}
+	writer.batch = append(writer.batch, row)
+	if len(writer.batch) >= writer.options.maxCount {
+		if err := writer.copyBatch(); err != nil {
+			errorFunc(err)
+		}
+	}
+}
+
+// row encodes a message as a row in the text format of COPY.
+func (writer *postgresWriter) row(text string, level LogLevel, context logContextInterface) []byte {
+	buf := new(bytes.Buffer)
+	for i, column := range writer.options.columns {
+		if i > 0 {
+			buf.WriteByte('\t')
+		}
+		var value string
+		switch column.value {
+		case postgresTimeValue:
+			value = context.CallTime().UTC().Format(time.RFC3339Nano)
+		case postgresLevelValue:
+			value = level.String()
+		case postgresMessageValue:
+			value = strings.TrimRight(text, "\r\n")
+		case postgresFieldsValue:
+			fields := new(bytes.Buffer)
+			fields.WriteByte('{')
+			writeJsonFields(fields, context.Fields(), false)
+			fields.WriteByte('}')
+			value = fields.String()
+		case postgresSourceValue:
+			if context.IsValid() {
+				value = context.ShortPath() + ":" + strconv.Itoa(context.Line())
+			}
+		default:
+			fieldValue, ok := findField(context.Fields(), column.field)
+			if !ok || fieldValue == nil {
+				buf.WriteString(`\N`)
+				continue
+			}
+			value = FieldValueString(fieldValue)
+		}
+		postgresCopyEscaper.WriteString(buf, value)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// postgresCopyEscaper escapes values for the text format of COPY. NUL bytes are not
+// allowed in text values and are dropped.
+var postgresCopyEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", "")
+
+func (writer *postgresWriter) copyOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if len(writer.batch) > 0 && time.Since(writer.started) >= writer.options.maxInterval {
+		if err := writer.copyBatch(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// copyBatch copies the collected rows into the table and starts a new batch.
+func (writer *postgresWriter) copyBatch() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	if len(writer.batch) == 0 {
+		return nil
+	}
+	batch := writer.batch
+	writer.batch = nil
+
+	err := writer.copy(batch)
+	if _, rejected := err.(*postgresError); err != nil && !rejected {
+		// The connection may be stale
+		writer.disconnect()
+		err = writer.copy(batch)
+		if _, rejected := err.(*postgresError); err != nil && !rejected {
+			writer.disconnect()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot copy %d messages into %s: %s", len(batch), writer.options.table, err)
+	}
+	return nil
+}
+
+// copy runs a COPY of the rows. After an error of the server the connection is ready for
+// the next one.
+func (writer *postgresWriter) copy(batch [][]byte) error {
+	if writer.conn == nil {
+		if err := writer.connect(); err != nil {
+			return err
+		}
+	}
+	writer.conn.SetDeadline(time.Now().Add(writer.options.timeout))
+
+	if err := writer.send('Q', []byte(writer.copyQuery+"\x00")); err != nil {
+		return err
+	}
+	msgType, body, err := writer.receive()
+	if err != nil {
+		return err
+	}
+	if msgType == 'G' {
+		out := bufio.NewWriter(writer.conn)
+		for _, row := range batch {
+			out.WriteByte('d')
+			binary.Write(out, binary.BigEndian, int32(len(row)+4))
+			out.Write(row)
+		}
+		out.Write([]byte{'c', 0, 0, 0, 4})
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	} else if msgType == 'E' {
+		err = parsePostgresError(body)
+	} else {
+		return fmt.Errorf("Unexpected PostgreSQL message '%c'", msgType)
+	}
+
+	// CommandComplete or ErrorResponse, then ReadyForQuery
+	for {
+		msgType, body, readErr := writer.receive()
+		if readErr != nil {
+			return readErr
+		}
+		switch msgType {
+		case 'E':
+			if err == nil {
+				err = parsePostgresError(body)
+			}
+		case 'Z':
+			return err
+		}
+	}
+}
+
+func (writer *postgresWriter) connect() error {
+	dialer := &net.Dialer{Timeout: writer.options.timeout, KeepAlive: 30 * time.Second}
+	conn, err := dialer.Dial("tcp", writer.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(writer.options.timeout))
+
+	if writer.options.tlsConfig != nil {
+		request := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 8), postgresSSLRequestCode)
+		response := make([]byte, 1)
+		if _, err = conn.Write(request); err == nil {
+			_, err = io.ReadFull(conn, response)
+		}
+		if err == nil && response[0] != 'S' {
+			err = errors.New("PostgreSQL server does not support TLS")
+		}
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		tlsConn := tls.Client(conn, writer.options.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+
+	writer.conn = conn
+	writer.reader = bufio.NewReader(conn)
+	if err := writer.startup(); err != nil {
+		writer.disconnect()
+		return err
+	}
+	return nil
+}
+
+// startup sends the startup message and authenticates, up to ReadyForQuery.
+func (writer *postgresWriter) startup() error {
+	var params []byte
+	params = binary.BigEndian.AppendUint32(params, postgresProtocolVersion)
+	for _, param := range [][2]string{
+		{"user", writer.options.user},
+		{"database", writer.options.database},
+		{"application_name", "seelog"},
+		{"client_encoding", "UTF8"},
+	} {
+		params = append(append(append(append(params, param[0]...), 0), param[1]...), 0)
+	}
+	params = append(params, 0)
+	startup := binary.BigEndian.AppendUint32(nil, uint32(len(params)+4))
+	if _, err := writer.conn.Write(append(startup, params...)); err != nil {
+		return err
+	}
+
+	var scram *scramClient
+	for {
+		msgType, body, err := writer.receive()
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case 'E':
+			return parsePostgresError(body)
+		case 'Z':
+			return nil
+		case 'R':
+			if len(body) < 4 {
+				return errors.New("Malformed PostgreSQL authentication request")
+			}
+			var response []byte
+			switch code, data := binary.BigEndian.Uint32(body), body[4:]; code {
+			case 0: // AuthenticationOk
+				continue
+			case 3: // AuthenticationCleartextPassword
+				response = append([]byte(writer.options.password), 0)
+			case 5: // AuthenticationMD5Password
+				inner := md5.Sum([]byte(writer.options.password + writer.options.user))
+				outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), data...))
+				response = append([]byte("md5"+hex.EncodeToString(outer[:])), 0)
+			case 10: // AuthenticationSASL
+				if !bytes.Contains(data, []byte(scramSHA256Mechanism+"\x00")) {
+					return errors.New("PostgreSQL server does not offer " + scramSHA256Mechanism)
+				}
+				// The user name is taken from the startup message
+				scram, err = newScramClient("", writer.options.password)
+				if err != nil {
+					return err
+				}
+				first := scram.clientFirst()
+				response = append([]byte(scramSHA256Mechanism), 0)
+				response = binary.BigEndian.AppendUint32(response, uint32(len(first)))
+				response = append(response, first...)
+			case 11: // AuthenticationSASLContinue
+				if scram == nil {
+					return errors.New("Unexpected PostgreSQL SASL message")
+				}
+				if response, err = scram.clientFinal(string(data)); err != nil {
+					return err
+				}
+			case 12: // AuthenticationSASLFinal
+				if scram == nil || !scram.verifyServer(string(data)) {
+					return errors.New("PostgreSQL server signature does not match")
+				}
+				continue
+			default:
+				return fmt.Errorf("PostgreSQL authentication method %d is not supported", code)
+			}
+			if err := writer.send('p', response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (writer *postgresWriter) send(msgType byte, body []byte) error {
+	message := binary.BigEndian.AppendUint32([]byte{msgType}, uint32(len(body)+4))
+	_, err := writer.conn.Write(append(message, body...))
+	return err
+}
+
+// receive reads a message, skipping notices and parameter status messages.
+func (writer *postgresWriter) receive() (byte, []byte, error) {
+	for {
+		var header [5]byte
+		if _, err := io.ReadFull(writer.reader, header[:]); err != nil {
+			return 0, nil, err
+		}
+		size := binary.BigEndian.Uint32(header[1:])
+		if size < 4 || size > postgresMaxMessageSize {
+			return 0, nil, fmt.Errorf("Invalid PostgreSQL message size %d", size)
+		}
+		body := make([]byte, size-4)
+		if _, err := io.ReadFull(writer.reader, body); err != nil {
+			return 0, nil, err
+		}
+		switch header[0] {
+		case 'N', 'S', 'K':
+			continue
+		}
+		return header[0], body, nil
+	}
+}
+
+func (writer *postgresWriter) disconnect() {
+	if writer.conn != nil {
+		writer.conn.Close()
+		writer.conn = nil
+		writer.reader = nil
+	}
+}
+
+// postgresError is an ErrorResponse of the server.
+type postgresError struct {
+	severity string
+	code     string
+	message  string
+}
+
+func parsePostgresError(body []byte) *postgresError {
+	err := &postgresError{}
+	for len(body) > 1 {
+		end := bytes.IndexByte(body[1:], 0)
+		if end < 0 {
+			break
+		}
+		value := string(body[1 : end+1])
+		switch body[0] {
+		case 'S':
+			err.severity = value
+		case 'C':
+			err.code = value
+		case 'M':
+			err.message = value
+		}
+		body = body[end+2:]
+	}
+	return err
+}
+
+func (err *postgresError) Error() string {
+	return fmt.Sprintf("%s %s: %s", err.severity, err.code, err.message)
+}
+
+func (writer *postgresWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.copyBatch(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *postgresWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	err := writer.copyBatch()
+	if writer.conn != nil {
+		writer.send('X', nil)
+		writer.disconnect()
+	}
+	return err
+}
+
+func (writer *postgresWriter) String() string {
+	return fmt.Sprintf("postgresWriter: [%s, %s, %s, %d, %s], format: %s\n",
+		writer.addr, writer.options.database, writer.copyQuery, writer.options.maxCount, writer.options.maxInterval,
+		writer.formatter)
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// postgresTestServer accepts the startup of a PostgreSQL connection, authenticating with
+// auth ("scram-sha-256", "md5" or "trust"), and records COPY FROM STDIN data. Copies
+// into the table "missing" fail.
+type postgresTestServer struct {
+	listener net.Listener
+	auth     string
+	password string
+
+	mutex   sync.Mutex
+	conns   int
+	params  map[string]string
+	queries []string
+	rows    []string
+}
+
+func newPostgresTestServer(t *testing.T, auth, password string) *postgresTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &postgresTestServer{listener: listener, auth: auth, password: password}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mutex.Lock()
+			server.conns++
+			server.mutex.Unlock()
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (server *postgresTestServer) addr() string {
+	return server.listener.Addr().String()
+}
+
+func (server *postgresTestServer) Close() {
+	server.listener.Close()
+}
+
+func postgresTestMessage(msgType byte, body string) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{msgType}, uint32(len(body)+4)), body...)
+}
+
+func postgresTestAuth(code uint32, data string) []byte {
+	return postgresTestMessage('R', string(binary.BigEndian.AppendUint32(nil, code))+data)
+}
+
+func postgresTestError(code, message string) []byte {
+	return postgresTestMessage('E', "SERROR\x00C"+code+"\x00M"+message+"\x00\x00")
+}
+
+func (server *postgresTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	read := func() (byte, []byte, bool) {
+		var header [5]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return 0, nil, false
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[1:])-4)
+		_, err := io.ReadFull(reader, body)
+		return header[0], body, err == nil
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(reader, size[:]); err != nil {
+		return
+	}
+	startup := make([]byte, binary.BigEndian.Uint32(size[:])-4)
+	io.ReadFull(reader, startup)
+	params := make(map[string]string)
+	parts := strings.Split(string(startup[4:]), "\x00")
+	for i := 0; i+1 < len(parts); i += 2 {
+		params[parts[i]] = parts[i+1]
+	}
+	server.mutex.Lock()
+	server.params = params
+	server.mutex.Unlock()
+
+	switch server.auth {
+	case "md5":
+		conn.Write(postgresTestAuth(5, "salt"))
+		_, body, _ := read()
+		inner := md5.Sum([]byte(server.password + params["user"]))
+		outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), "salt"...))
+		if string(body) != "md5"+hex.EncodeToString(outer[:])+"\x00" {
+			conn.Write(postgresTestError("28P01", "password authentication failed"))
+			return
+		}
+	case "scram-sha-256":
+		conn.Write(postgresTestAuth(10, "SCRAM-SHA-256-PLUS\x00SCRAM-SHA-256\x00\x00"))
+		_, body, _ := read()
+		scram := &scramTestServer{password: server.password}
+		conn.Write(postgresTestAuth(11, scram.first(string(body[len("SCRAM-SHA-256")+5:]))))
+		_, body, _ = read()
+		serverFinal, ok := scram.final(string(body))
+		if !ok {
+			conn.Write(postgresTestError("28P01", "password authentication failed"))
+			return
+		}
+		conn.Write(postgresTestAuth(12, serverFinal))
+	}
+	conn.Write(postgresTestAuth(0, ""))
+	conn.Write(postgresTestMessage('S', "server_version\x0016.4\x00"))
+	conn.Write(postgresTestMessage('Z', "I"))
+
+	for {
+		msgType, body, ok := read()
+		if !ok || msgType == 'X' {
+			return
+		}
+		query := strings.TrimSuffix(string(body), "\x00")
+		server.mutex.Lock()
+		server.queries = append(server.queries, query)
+		server.mutex.Unlock()
+		if strings.Contains(query, `"missing"`) {
+			conn.Write(postgresTestError("42P01", `relation "missing" does not exist`))
+			conn.Write(postgresTestMessage('Z', "I"))
+			continue
+		}
+
+		conn.Write(postgresTestMessage('G', "\x00\x00\x00"))
+		var data bytes.Buffer
+		for {
+			msgType, body, ok := read()
+			if !ok {
+				return
+			}
+			if msgType == 'c' {
+				break
+			}
+			data.Write(body)
+		}
+		server.mutex.Lock()
+		rows := strings.Split(strings.TrimSuffix(data.String(), "\n"), "\n")
+		server.rows = append(server.rows, rows...)
+		server.mutex.Unlock()
+		conn.Write(postgresTestMessage('C', "COPY "+strconv.Itoa(len(rows))+"\x00"))
+		conn.Write(postgresTestMessage('Z', "I"))
+	}
+}
+
+func (server *postgresTestServer) copied() ([]string, []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string(nil), server.queries...), append([]string(nil), server.rows...)
+}
+
+func TestPostgresScramCopy(t *testing.T) {
+	server := newPostgresTestServer(t, "scram-sha-256", "s3cret")
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<postgres addr="`+server.addr()+`" user="logger" password="s3cret"
+		database="app" maxcount="2"/>`})
+	logger.Infow("one", Str("user", "alice"))
+	logger.Warn("two")
+	logger.Error("three")
+	logger.Close()
+
+	queries, rows := server.copied()
+	if len(queries) != 2 || queries[0] != `COPY "logs" ("time", "level", "message", "fields") FROM STDIN` {
+		t.Fatalf("Unexpected queries: %q", queries)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %q", rows)
+	}
+	columns := strings.Split(rows[0], "\t")
+	if _, err := time.Parse(time.RFC3339Nano, columns[0]); err != nil || columns[1] != "info" || columns[2] != "one" ||
+		columns[3] != `{"user":"alice"}` {
+		t.Errorf("Unexpected row: %q", rows[0])
+	}
+	if !strings.HasSuffix(rows[2], "\terror\tthree\t{}") {
+		t.Errorf("Unexpected row: %q", rows[2])
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.params["user"] != "logger" || server.params["database"] != "app" {
+		t.Errorf("Unexpected startup parameters: %v", server.params)
+	}
+}
+
+func TestPostgresColumns(t *testing.T) {
+	server := newPostgresTestServer(t, "md5", "s3cret")
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<postgres addr="`+server.addr()+`" user="logger" password="s3cret" table="audit.log">
+		<column name="action" value="message"/>
+		<column name="user_id" field="user"/>
+		<column name="status" field="http.status"/>
+	</postgres>`})
+	logger.Infow("grant\troot\nrole\\admin", Str("user", "alice"), Group("http", Int("status", 200)))
+	logger.Info("anonymous")
+	logger.Close()
+
+	queries, rows := server.copied()
+	if len(queries) != 1 || queries[0] != `COPY "audit"."log" ("action", "user_id", "status") FROM STDIN` {
+		t.Fatalf("Unexpected queries: %q", queries)
+	}
+	expected := []string{`grant\troot\nrole\\admin` + "\talice\t200", "anonymous\t\\N\t\\N"}
+	if strings.Join(rows, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected rows %q, got %q", expected, rows)
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.params["database"] != "logger" {
+		t.Errorf("Expected the user as the database, got %v", server.params)
+	}
+}
+
+func TestPostgresErrors(t *testing.T) {
+	server := newPostgresTestServer(t, "trust", "")
+	defer server.Close()
+
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newPostgresWriter(formatter, server.addr(), postgresWriterOptions{user: "logger", table: "missing",
+		maxCount: 1, maxInterval: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		var dispatchErr error
+		writer.Dispatch("lost", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { dispatchErr = err })
+		if dispatchErr == nil || !strings.Contains(dispatchErr.Error(), "42P01") {
+			t.Errorf("Expected an error of the server, got %v", dispatchErr)
+		}
+	}
+	writer.Close()
+	server.mutex.Lock()
+	if server.conns != 1 {
+		t.Errorf("Expected no reconnect after an error of the server, got %d connections", server.conns)
+	}
+	server.mutex.Unlock()
+
+	scram := newPostgresTestServer(t, "scram-sha-256", "s3cret")
+	defer scram.Close()
+	writer, _ = newPostgresWriter(formatter, scram.addr(), postgresWriterOptions{user: "logger", password: "wrong",
+		maxCount: 10, maxInterval: time.Minute})
+	writer.Dispatch("lost", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	if err := writer.Close(); err == nil || !strings.Contains(err.Error(), "28P01") {
+		t.Errorf("Expected an authentication error, got %v", err)
+	}
+}
+
+func TestPostgresConfig(t *testing.T) {
+	invalid := []string{
+		`<postgres user="logger"/>`,
+		`<postgres addr="db.local:5432"/>`,
+		`<postgres addr="db.local" user="logger"/>`,
+		`<postgres addr="db.local:5432" user="logger" maxcount="0"/>`,
+		`<postgres addr="db.local:5432" user="logger"><column value="time"/></postgres>`,
+		`<postgres addr="db.local:5432" user="logger"><column name="at"/></postgres>`,
+		`<postgres addr="db.local:5432" user="logger"><column name="at" value="time" field="time"/></postgres>`,
+		`<postgres addr="db.local:5432" user="logger"><column name="at" value="date"/></postgres>`,
+		`<postgres addr="db.local:5432" user="logger"><header name="at"/></postgres>`,
+		`<postgres addr="db.local:5432" user="logger" cacertdirpath="/nonexistent"/>`,
+	}
+	for _, postgres := range invalid {
+		config := `<seelog><outputs>` + postgres + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redis receiver modes.
+const (
+	redisLPushMode   = "lpush"
+	redisPublishMode = "publish"
+)
+
+const (
+	defaultRedisPoolSize = 2
+	defaultRedisTimeout  = 5 * time.Second
+)
+
+// redisWriter pushes messages to a Redis list (LPUSH, as read by the Logstash redis
+// input) or publishes them to a channel (PUBLISH). Every message is a separate command.
+// Connections are kept in a pool of up to poolSize idle ones, so concurrent messages do
+// not wait for each other; a message which fails on a pooled connection is retried once
+// on a new one, so restarts of the server lose nothing.
+type redisWriter struct {
+	addr     string
+	mode     string
+	key      string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mutex  sync.Mutex
+	idle   []*redisConn
+	size   int // Maximum number of idle connections
+	closed bool
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newRedisWriter(addr, mode, key, password string, db, poolSize int, timeout time.Duration) (*redisWriter, error) {
+	if addr == "" {
+		return nil, errors.New("Redis address can not be empty")
+	}
+	if mode == "" {
+		mode = redisLPushMode
+	}
+	if mode != redisLPushMode && mode != redisPublishMode {
+		return nil, errors.New("Unknown redis mode: " + mode)
+	}
+	if key == "" {
+		return nil, errors.New("Redis key can not be empty")
+	}
+	if db < 0 {
+		return nil, fmt.Errorf("db can not be less than 0. Got: %d", db)
+	}
+	if poolSize <= 0 {
+		poolSize = defaultRedisPoolSize
+	}
+	if timeout <= 0 {
+		timeout = defaultRedisTimeout
+	}
+
+	return &redisWriter{addr: addr, mode: mode, key: key, password: password, db: db,
+		timeout: timeout, size: poolSize}, nil
+}
+
+func (writer *redisWriter) Write(data []byte) (int, error) {
+	command := redisCommand(strings.ToUpper(writer.mode), writer.key, strings.TrimRight(string(data), "\r\n"))
+
+	conn, pooled, err := writer.get()
+	if err != nil {
+		return 0, err
+	}
+	err = conn.do(command, writer.timeout)
+	if err != nil && pooled && !isRedisError(err) {
+		// The pooled connection may be stale
+		conn.conn.Close()
+		conn, err = writer.dial()
+		if err != nil {
+			return 0, err
+		}
+		err = conn.do(command, writer.timeout)
+	}
+	if err != nil && !isRedisError(err) {
+		conn.conn.Close()
+		return 0, err
+	}
+
+	writer.put(conn)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// get returns an idle connection, or a new one if there is none.
+func (writer *redisWriter) get() (conn *redisConn, pooled bool, err error) {
+	writer.mutex.Lock()
+	if writer.closed {
+		writer.mutex.Unlock()
+		return nil, false, errors.New("Redis writer is closed")
+	}
+	if n := len(writer.idle); n > 0 {
+		conn = writer.idle[n-1]
+		writer.idle = writer.idle[:n-1]
+		writer.mutex.Unlock()
+		return conn, true, nil
+	}
+	writer.mutex.Unlock()
+
+	conn, err = writer.dial()
+	return conn, false, err
+}
+
+// put returns a connection to the pool, or closes it if the pool is full.
+func (writer *redisWriter) put(conn *redisConn) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed || len(writer.idle) >= writer.size {
+		conn.conn.Close()
+		return
+	}
+	writer.idle = append(writer.idle, conn)
+}
+
+// dial connects to the server, authenticates and selects the database.
+func (writer *redisWriter) dial() (*redisConn, error) {
+	netConn, err := net.DialTimeout("tcp", writer.addr, writer.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{netConn, bufio.NewReader(netConn)}
+	if writer.password != "" {
+		if err := conn.do(redisCommand("AUTH", writer.password), writer.timeout); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	if writer.db != 0 {
+		if err := conn.do(redisCommand("SELECT", strconv.Itoa(writer.db)), writer.timeout); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// redisCommand encodes a command as a RESP array of bulk strings.
+func redisCommand(args ...string) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	return buf.Bytes()
+}
+
+// redisError is an error reply of the server. The connection stays usable after it.
+type redisError string
+
+func (err redisError) Error() string {
+	return "Redis: " + string(err)
+}
+
+func isRedisError(err error) bool {
+	_, ok := err.(redisError)
+	return ok
+}
+
+// do sends a command and reads its reply, which must be a simple string or an integer,
+// as the replies of all commands the writer sends are.
+func (conn *redisConn) do(command []byte, timeout time.Duration) error {
+	conn.conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.conn.Write(command); err != nil {
+		return err
+	}
+	line, err := conn.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return errors.New("Empty redis reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return redisError(line[1:])
+	}
+	return errors.New("Unexpected redis reply: " + line)
+}
+
+func (writer *redisWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.closed = true
+	for _, conn := range writer.idle {
+		conn.conn.Close()
+	}
+	writer.idle = nil
+	return nil
+}
+
+func (writer *redisWriter) String() string {
+	return fmt.Sprintf("redisWriter: [%s, %s %s, db %d, pool %d]", writer.addr, writer.mode, writer.key, writer.db, writer.size)
+}
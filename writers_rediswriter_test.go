@@ -0,0 +1,193 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// redisTestServer records the commands it receives and replies to AUTH, SELECT, LPUSH
+// and PUBLISH as Redis does.
+type redisTestServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	commands []string
+	conns    []net.Conn
+}
+
+func newRedisTestServer(t *testing.T) *redisTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &redisTestServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mutex.Lock()
+			server.conns = append(server.conns, conn)
+			server.mutex.Unlock()
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (server *redisTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRedisTestCommand(reader)
+		if err != nil {
+			return
+		}
+		server.mutex.Lock()
+		server.commands = append(server.commands, strings.Join(args, " "))
+		server.mutex.Unlock()
+
+		reply := ":1\r\n"
+		switch {
+		case args[0] == "AUTH" && args[1] != "secret":
+			reply = "-WRONGPASS invalid password\r\n"
+		case args[0] == "AUTH", args[0] == "SELECT":
+			reply = "+OK\r\n"
+		}
+		io.WriteString(conn, reply)
+	}
+}
+
+func readRedisTestCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, count)
+	for i := range args {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+// dropConnections closes the server side of all connections, as a server restart does.
+func (server *redisTestServer) dropConnections() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for _, conn := range server.conns {
+		conn.Close()
+	}
+	server.conns = nil
+}
+
+func (server *redisTestServer) received() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string(nil), server.commands...)
+}
+
+func TestRedisLPush(t *testing.T) {
+	server := newRedisTestServer(t)
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<redis addr="`+server.listener.Addr().String()+`" key="logs" password="secret" db="2"/>`})
+	logger.Infow("one", Int("n", 1))
+	server.dropConnections()
+	logger.Info("two")
+	logger.Close()
+
+	commands := server.received()
+	expected := []string{"AUTH secret", "SELECT 2", "LPUSH logs {", "AUTH secret", "SELECT 2", "LPUSH logs {"}
+	if len(commands) != len(expected) {
+		t.Fatalf("Expected %d commands, got %q", len(expected), commands)
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(commands[i], prefix) {
+			t.Errorf("Command %d: expected %q, got %q", i, prefix, commands[i])
+		}
+	}
+	if !strings.Contains(commands[2], `"msg":"one","n":1}`) || strings.HasSuffix(commands[2], "\n") {
+		t.Errorf("Unexpected record: %q", commands[2])
+	}
+}
+
+func TestRedisPublish(t *testing.T) {
+	server := newRedisTestServer(t)
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<redis addr="`+server.listener.Addr().String()+`" mode="publish" key="events" formatid="msg"/>`})
+	logger.Info("hello")
+	logger.Close()
+
+	commands := server.received()
+	if len(commands) != 1 || commands[0] != "PUBLISH events hello" {
+		t.Errorf("Unexpected commands: %q", commands)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	server := newRedisTestServer(t)
+	defer server.listener.Close()
+
+	writer, err := newRedisWriter(server.listener.Addr().String(), "", "logs", "wrong", 0, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if _, err := writer.Write([]byte("message")); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Expected an authentication error, got %v", err)
+	}
+
+	invalid := []string{
+		`<redis key="logs"/>`,
+		`<redis addr="localhost:6379"/>`,
+		`<redis addr="localhost:6379" key="logs" mode="rpush"/>`,
+		`<redis addr="localhost:6379" key="logs" db="-1"/>`,
+		`<redis addr="localhost:6379" key="logs" timeout="soon"/>`,
+	}
+	for _, redis := range invalid {
+		config := `<seelog><outputs>` + redis + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultRingBufferSize = 1000
+
+// RingBufferRecord is a record kept by a ring buffer receiver.
+type RingBufferRecord struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string // The message as logged
+	Text    string // The message formatted with the format of the receiver
+	Fields  []Field
+}
+
+// RingBuffer keeps the last records written to a ringbuffer receiver in memory, so that
+// a crash handler or a debug endpoint can show the recent history. Like the slo counters
+// ring buffers are kept for the whole life of the process, so they survive logger
+// replacement; a receiver of a new config with another size resizes the buffer of its
+// name, keeping the most recent records.
+type RingBuffer struct {
+	mutex   sync.Mutex
+	records []RingBufferRecord
+	next    int // Index the next record is written to
+	count   int
+	total   uint64
+}
+
+var (
+	ringBuffersMutex sync.Mutex
+	ringBuffers      = make(map[string]*RingBuffer)
+)
+
+// newRingBuffer returns a buffer of the given size which is not registered under a name.
+func newRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{records: make([]RingBufferRecord, size)}
+}
+
+func getRingBuffer(name string, size int) *RingBuffer {
+	ringBuffersMutex.Lock()
+	defer ringBuffersMutex.Unlock()
+
+	buffer, ok := ringBuffers[name]
+	if !ok {
+		buffer = newRingBuffer(size)
+		ringBuffers[name] = buffer
+		return buffer
+	}
+	buffer.resize(size)
+	return buffer
+}
+
+// GetRingBuffer returns the buffer of the ringbuffer receiver with the given name.
+func GetRingBuffer(name string) (*RingBuffer, bool) {
+	ringBuffersMutex.Lock()
+	defer ringBuffersMutex.Unlock()
+
+	buffer, ok := ringBuffers[name]
+	return buffer, ok
+}
+
+// RingBufferNames returns the sorted names of all ringbuffer receivers created so far.
+func RingBufferNames() []string {
+	ringBuffersMutex.Lock()
+	defer ringBuffersMutex.Unlock()
+
+	names := make([]string, 0, len(ringBuffers))
+	for name := range ringBuffers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (buffer *RingBuffer) add(record RingBufferRecord) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	buffer.records[buffer.next] = record
+	buffer.next = (buffer.next + 1) % len(buffer.records)
+	if buffer.count < len(buffer.records) {
+		buffer.count++
+	}
+	buffer.total++
+}
+
+func (buffer *RingBuffer) resize(size int) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	if size == len(buffer.records) {
+		return
+	}
+	records := buffer.snapshot()
+	if len(records) > size {
+		records = records[len(records)-size:]
+	}
+	buffer.records = make([]RingBufferRecord, size)
+	buffer.count = copy(buffer.records, records)
+	buffer.next = buffer.count % size
+}
+
+// snapshot returns the kept records, oldest first. The caller holds the mutex.
+func (buffer *RingBuffer) snapshot() []RingBufferRecord {
+	records := make([]RingBufferRecord, 0, buffer.count)
+	start := (buffer.next - buffer.count + len(buffer.records)) % len(buffer.records)
+	for i := 0; i < buffer.count; i++ {
+		records = append(records, buffer.records[(start+i)%len(buffer.records)])
+	}
+	return records
+}
+
+// Snapshot returns a copy of the kept records, oldest first.
+func (buffer *RingBuffer) Snapshot() []RingBufferRecord {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	return buffer.snapshot()
+}
+
+// DumpTo writes the formatted text of the kept records to w, oldest first, and returns
+// the number of bytes written.
+func (buffer *RingBuffer) DumpTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, record := range buffer.Snapshot() {
+		n, err := io.WriteString(w, record.Text)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Len returns the number of kept records.
+func (buffer *RingBuffer) Len() int {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	return buffer.count
+}
+
+// Total returns the number of records written to the buffer, including the ones which
+// were overwritten since.
+func (buffer *RingBuffer) Total() uint64 {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	return buffer.total
+}
+
+// Reset drops the kept records.
+func (buffer *RingBuffer) Reset() {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	buffer.records = make([]RingBufferRecord, len(buffer.records))
+	buffer.next = 0
+	buffer.count = 0
+}
+
+// ringBufferWriter keeps the last records in the RingBuffer of its name.
+type ringBufferWriter struct {
+	name      string
+	formatter *formatter
+	buffer    *RingBuffer
+}
+
+func newRingBufferWriter(name string, size int, formatter *formatter) (*ringBufferWriter, error) {
+	if name == "" {
+		return nil, errors.New("Ring buffer name can not be empty")
+	}
+	if size == 0 {
+		size = defaultRingBufferSize
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("Ring buffer size must be positive. Got: %d", size)
+	}
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	return &ringBufferWriter{name: name, formatter: formatter, buffer: getRingBuffer(name, size)}, nil
+}
+
+func (writer *ringBufferWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	writer.buffer.add(RingBufferRecord{
+		Time:    context.CallTime(),
+		Level:   level,
+		Message: message,
+		Text:    writer.formatter.Format(message, level, context),
+		Fields:  append([]Field(nil), context.Fields()...),
+	})
+}
+
+func (writer *ringBufferWriter) Flush() {
+}
+
+func (writer *ringBufferWriter) Close() error {
+	return nil
+}
+
+func (writer *ringBufferWriter) String() string {
+	return fmt.Sprintf("ringBufferWriter: %s, format: %s\n", writer.name, writer.formatter)
+}
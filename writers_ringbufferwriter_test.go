@@ -0,0 +1,98 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"testing"
+)
+
+// removeRingBuffer removes the buffer of a ringbuffer receiver, so that the next receiver
+// with the name starts with an empty buffer.
+func removeRingBuffer(name string) {
+	ringBuffersMutex.Lock()
+	defer ringBuffersMutex.Unlock()
+	delete(ringBuffers, name)
+}
+
+func TestRingBufferKeepsLastRecords(t *testing.T) {
+	t.Cleanup(func() { removeRingBuffer("test-ring") })
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<ringbuffer name="test-ring" size="3" formatid="msg"/>`})
+	logger.Info("first")
+	logger.Debugw("second", Int("n", 2))
+	logger.Info("third")
+	logger.Error("fourth")
+	logger.Close()
+
+	buffer, ok := GetRingBuffer("test-ring")
+	if !ok {
+		t.Fatal("Expected the ring buffer to exist")
+	}
+	var dump bytes.Buffer
+	if _, err := buffer.DumpTo(&dump); err != nil || dump.String() != "second\nthird\nfourth\n" {
+		t.Errorf("Unexpected dump %q: %v", dump.String(), err)
+	}
+	records := buffer.Snapshot()
+	if len(records) != 3 || records[0].Message != "second" || records[0].Level != DebugLvl ||
+		len(records[0].Fields) != 1 || records[2].Level != ErrorLvl || records[2].Time.IsZero() {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+	if buffer.Len() != 3 || buffer.Total() != 4 {
+		t.Errorf("Expected 3 of 4 records, got %d of %d", buffer.Len(), buffer.Total())
+	}
+
+	// A new config resizes the buffer of the name, keeping the most recent records
+	logger = newTestLogger(t, testLoggerConfig{outputs: `<ringbuffer name="test-ring" size="2" formatid="msg"/>`})
+	logger.Info("fifth")
+	logger.Close()
+	if dump.Reset(); buffer.Len() != 2 {
+		t.Errorf("Expected 2 records, got %d", buffer.Len())
+	}
+	buffer.DumpTo(&dump)
+	if dump.String() != "fourth\nfifth\n" {
+		t.Errorf("Unexpected dump after resizing: %q", dump.String())
+	}
+
+	buffer.Reset()
+	if buffer.Len() != 0 || len(buffer.Snapshot()) != 0 {
+		t.Errorf("Expected no records after a reset, got %d", buffer.Len())
+	}
+}
+
+func TestRingBufferConfig(t *testing.T) {
+	invalid := []string{
+		`<ringbuffer name=""/>`,
+		`<ringbuffer size="0"/>`,
+		`<ringbuffer size="-1"/>`,
+		`<ringbuffer path="recent.log"/>`,
+	}
+	for _, ringBuffer := range invalid {
+		config := `<seelog><outputs>` + ringBuffer + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// rollingFileRegistry holds the rolling file writers of the process by absolute file
+// path, so that loggers configured with the same rolling file, e.g. the logger of an
+// application and a logger created by its tests, write through one writer and share
+// its rotation state instead of renaming the file under each other.
+var (
+	rollingFileRegistry      = make(map[string]*rollingFileEntry)
+	rollingFileRegistryMutex sync.Mutex
+)
+
+// rollingFileEntry is a registered rolling file writer with the number of handles to it.
+type rollingFileEntry struct {
+	path   string
+	writer *rollingFileWriter
+	mutex  sync.Mutex // Serializes writes and rotation of all handles
+	refs   int
+}
+
+// sharedRollingFileWriter is a handle to a registered rolling file writer. The writer
+// is closed, and unregistered, when its last handle is closed.
+type sharedRollingFileWriter struct {
+	entry  *rollingFileEntry
+	closed bool
+}
+
+// shareRollingFileWriter returns a handle to the registered writer of the file of
+// writer, registering writer if the file has none. If the registered writer rolls
+// the file with other settings, its settings win and the mismatch is reported.
+func shareRollingFileWriter(writer *rollingFileWriter) (*sharedRollingFileWriter, error) {
+	path, err := filepath.Abs(writer.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	rollingFileRegistryMutex.Lock()
+	defer rollingFileRegistryMutex.Unlock()
+
+	entry, ok := rollingFileRegistry[path]
+	if !ok {
+		entry = &rollingFileEntry{path: path, writer: writer}
+		rollingFileRegistry[path] = entry
+	} else if entry.writer.String() != writer.String() {
+		reportInternalError(fmt.Errorf("Rolling file %s is already in use with other settings, which are kept: %s",
+			path, entry.writer))
+	}
+	entry.refs++
+
+	return &sharedRollingFileWriter{entry: entry}, nil
+}
+
+func (shared *sharedRollingFileWriter) Write(bytes []byte) (n int, err error) {
+	shared.entry.mutex.Lock()
+	defer shared.entry.mutex.Unlock()
+
+	return shared.entry.writer.Write(bytes)
+}
+
+func (shared *sharedRollingFileWriter) Close() error {
+	rollingFileRegistryMutex.Lock()
+	defer rollingFileRegistryMutex.Unlock()
+
+	if shared.closed {
+		return nil
+	}
+	shared.closed = true
+
+	shared.entry.refs--
+	if shared.entry.refs > 0 {
+		return nil
+	}
+	delete(rollingFileRegistry, shared.entry.path)
+
+	shared.entry.mutex.Lock()
+	defer shared.entry.mutex.Unlock()
+
+	return shared.entry.writer.Close()
+}
+
+func (shared *sharedRollingFileWriter) String() string {
+	return shared.entry.writer.String()
+}
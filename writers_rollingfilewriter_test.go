@@ -27,6 +27,9 @@ package seelog
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -98,3 +101,50 @@ createRollingSizeFileWriterTestCase([]string{"log.testlog.1"}, "log.testlog", 10
 //createRollingDatefileWriterTestCase([]string{}, "log.txt", "02.01.2006", 1, []string{}),
 //createRollingDatefileWriterTestCase([]string{}, "log.txt", "02.01.2006.000000", 2, []string{}),
 }
+
+func TestSharedRollingFileWriter(t *testing.T) {
+	dir := t.TempDir()
+	config := `<seelog type="sync"><outputs formatid="msg">
+		<rollingfile type="size" filename="` + filepath.Join(dir, "shared.log") + `" maxsize="40" maxrolls="100"/>
+		</outputs><formats><format id="msg" format="%Msg%n"/></formats></seelog>`
+
+	first, err := LoggerFromConfigAsString(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoggerFromConfigAsString(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		first.Infof("first %02d", i)
+		second.Infof("second %02d", i)
+	}
+	first.Close()
+	second.Info("after close")
+	second.Close()
+
+	rollingFileRegistryMutex.Lock()
+	_, registered := rollingFileRegistry[filepath.Join(dir, "shared.log")]
+	rollingFileRegistryMutex.Unlock()
+	if registered {
+		t.Error("Expected the file to be unregistered after the last logger was closed")
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "shared.log*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines += strings.Count(string(content), "\n")
+	}
+	if lines != 41 {
+		t.Errorf("Expected 41 lines in %d files, got %d", len(files), lines)
+	}
+}
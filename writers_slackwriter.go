@@ -0,0 +1,352 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSlackMinLevel = WarnLvl
+	defaultSlackDedup    = 5 * time.Minute
+	defaultSlackLimit    = 10
+	defaultSlackInterval = time.Minute
+	defaultSlackTimeout  = 10 * time.Second
+
+	// maxAlertThrottleKeys caps the alerts remembered for dedup. When more distinct alerts
+	// arrive within the dedup window, the oldest are forgotten and may repeat.
+	maxAlertThrottleKeys = 1024
+)
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// alertThrottle keeps an error storm from flooding a chat channel. An alert which repeats
+// one sent within the dedup window is dropped, and so is any alert beyond limit alerts
+// per interval. The dropped alerts are counted, so that the next alert sent can say how
+// many were suppressed. A zero dedup or limit disables that check.
+type alertThrottle struct {
+	dedup    time.Duration
+	limit    int
+	interval time.Duration
+
+	mutex      sync.Mutex
+	seen       map[string]time.Time
+	seenOrder  []seenAlert // Keys of seen in the order they were sent, for expiry
+	sent       []time.Time
+	suppressed int
+}
+
+// seenAlert is a key of alertThrottle.seen with the time it was sent.
+type seenAlert struct {
+	key  string
+	time time.Time
+}
+
+func newAlertThrottle(dedup time.Duration, limit int, interval time.Duration) *alertThrottle {
+	return &alertThrottle{dedup: dedup, limit: limit, interval: interval, seen: make(map[string]time.Time)}
+}
+
+// allow reports whether an alert with the given key may be sent at t, and if so returns
+// the number of alerts suppressed since the last one sent.
+func (throttle *alertThrottle) allow(key string, t time.Time) (int, bool) {
+	throttle.mutex.Lock()
+	defer throttle.mutex.Unlock()
+
+	if throttle.dedup > 0 {
+		for len(throttle.seenOrder) > 0 && t.Sub(throttle.seenOrder[0].time) >= throttle.dedup {
+			throttle.dropOldestSeen()
+		}
+		if _, isSeen := throttle.seen[key]; isSeen {
+			throttle.suppressed++
+			return 0, false
+		}
+	}
+
+	if throttle.limit > 0 {
+		for len(throttle.sent) > 0 && t.Sub(throttle.sent[0]) >= throttle.interval {
+			throttle.sent = throttle.sent[1:]
+		}
+		if len(throttle.sent) >= throttle.limit {
+			throttle.suppressed++
+			return 0, false
+		}
+		throttle.sent = append(throttle.sent, t)
+	}
+
+	if throttle.dedup > 0 {
+		throttle.seen[key] = t
+		throttle.seenOrder = append(throttle.seenOrder, seenAlert{key, t})
+		for len(throttle.seenOrder) > maxAlertThrottleKeys {
+			throttle.dropOldestSeen()
+		}
+	}
+	suppressed := throttle.suppressed
+	throttle.suppressed = 0
+	return suppressed, true
+}
+
+// dropOldestSeen removes the oldest entry of seenOrder, and its key from seen unless the
+// key was forgotten and sent again since.
+func (throttle *alertThrottle) dropOldestSeen() {
+	oldest := throttle.seenOrder[0]
+	throttle.seenOrder = throttle.seenOrder[1:]
+	if seenTime, isSeen := throttle.seen[oldest.key]; isSeen && seenTime.Equal(oldest.time) {
+		delete(throttle.seen, oldest.key)
+	}
+}
+
+// reserve takes a place for a message at t within the limit per interval, and returns 0,
+// or returns how long to wait for a place. It does not count suppressed alerts, and
+// ignores dedup.
+func (throttle *alertThrottle) reserve(t time.Time) time.Duration {
+	throttle.mutex.Lock()
+	defer throttle.mutex.Unlock()
+
+	if throttle.limit <= 0 {
+		return 0
+	}
+	for len(throttle.sent) > 0 && t.Sub(throttle.sent[0]) >= throttle.interval {
+		throttle.sent = throttle.sent[1:]
+	}
+	if len(throttle.sent) >= throttle.limit {
+		return throttle.sent[0].Add(throttle.interval).Sub(t)
+	}
+	throttle.sent = append(throttle.sent, t)
+	return 0
+}
+
+// forget lets the next alert with the given key through dedup.
+func (throttle *alertThrottle) forget(key string) {
+	throttle.mutex.Lock()
+	defer throttle.mutex.Unlock()
+
+	delete(throttle.seen, key)
+}
+
+// takeSuppressed returns the number of alerts suppressed since the last one sent, and
+// resets it.
+func (throttle *alertThrottle) takeSuppressed() int {
+	throttle.mutex.Lock()
+	defer throttle.mutex.Unlock()
+
+	suppressed := throttle.suppressed
+	throttle.suppressed = 0
+	return suppressed
+}
+
+// slackRoute is a destination of slack messages: an incoming webhook and optionally a
+// channel, for the given levels. The default route has no levels and takes the rest.
+type slackRoute struct {
+	levels   []LogLevel
+	url      string
+	channel  string
+	throttle *alertThrottle
+}
+
+func (route *slackRoute) matches(level LogLevel) bool {
+	for _, routeLevel := range route.levels {
+		if routeLevel == level {
+			return true
+		}
+	}
+	return false
+}
+
+// slackWriter posts messages of minLevel and above to Slack incoming webhooks. The text
+// of a message is formatted by the format of the output, so the format is the template
+// of the Slack message; Slack markup such as *bold* and `code` may be used in it.
+//
+// Messages go to the first route whose levels include their level, or else to the
+// default route. Every route is throttled on its own (see alertThrottle); messages are
+// deduplicated by level and unformatted message, so repeats with different times or
+// fields are dropped too. A count of suppressed messages is added to the next message
+// posted to the route, and posted on its own on Close.
+type slackWriter struct {
+	formatter *formatter
+	minLevel  LogLevel
+	username  string
+	icon      string
+	routes    []*slackRoute
+	client    *http.Client
+}
+
+// slackWriterOptions are the optional settings of a slack receiver. Zero values other
+// than minLevel are replaced with the defaults; negative dedup or limit disable those
+// checks.
+type slackWriterOptions struct {
+	minLevel LogLevel
+	channel  string
+	username string
+	icon     string
+	routes   []*slackRoute
+	dedup    time.Duration
+	limit    int
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func newSlackWriter(formatter *formatter, url string, options slackWriterOptions) (*slackWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, errors.New("Slack webhook url must be an http or https url")
+	}
+	if options.dedup == 0 {
+		options.dedup = defaultSlackDedup
+	}
+	if options.limit == 0 {
+		options.limit = defaultSlackLimit
+	}
+	if options.interval == 0 {
+		options.interval = defaultSlackInterval
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultSlackTimeout
+	}
+	if options.interval < 0 || options.timeout < 0 {
+		return nil, errors.New("Slack receiver interval and timeout can not be negative")
+	}
+
+	routes := append(append([]*slackRoute(nil), options.routes...), &slackRoute{url: url, channel: options.channel})
+	for _, route := range routes {
+		if route.url == "" {
+			route.url = url
+		} else if !strings.HasPrefix(route.url, "https://") && !strings.HasPrefix(route.url, "http://") {
+			return nil, errors.New("Slack webhook url must be an http or https url")
+		}
+		route.throttle = newAlertThrottle(options.dedup, options.limit, options.interval)
+	}
+
+	return &slackWriter{
+		formatter: formatter,
+		minLevel:  options.minLevel,
+		username:  options.username,
+		icon:      options.icon,
+		routes:    routes,
+		client:    &http.Client{Timeout: options.timeout},
+	}, nil
+}
+
+func (writer *slackWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent || level < writer.minLevel {
+		return
+	}
+
+	route := writer.route(level)
+	suppressed, ok := route.throttle.allow(level.String()+"\x00"+message, context.CallTime())
+	if !ok {
+		return
+	}
+
+	text := slackEscaper.Replace(strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n"))
+	if suppressed > 0 {
+		text += "\n" + slackSuppressedText(suppressed)
+	}
+	if err := writer.post(route, text); err != nil {
+		errorFunc(err)
+	}
+}
+
+func (writer *slackWriter) route(level LogLevel) *slackRoute {
+	for _, route := range writer.routes {
+		if route.matches(level) {
+			return route
+		}
+	}
+	return writer.routes[len(writer.routes)-1]
+}
+
+func slackSuppressedText(suppressed int) string {
+	if suppressed == 1 {
+		return "_1 more message suppressed_"
+	}
+	return fmt.Sprintf("_%d more messages suppressed_", suppressed)
+}
+
+func (writer *slackWriter) post(route *slackRoute, text string) error {
+	payload := map[string]string{"text": text}
+	if route.channel != "" {
+		payload["channel"] = route.channel
+	}
+	if writer.username != "" {
+		payload["username"] = writer.username
+	}
+	if strings.HasPrefix(writer.icon, ":") {
+		payload["icon_emoji"] = writer.icon
+	} else if writer.icon != "" {
+		payload["icon_url"] = writer.icon
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	response, err := writer.client.Post(route.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		responseBody, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("Slack webhook returned %s: %s", response.Status, strings.TrimSpace(string(responseBody)))
+	}
+	io.Copy(ioutil.Discard, response.Body)
+	return nil
+}
+
+func (writer *slackWriter) Flush() {
+}
+
+// Close posts the counts of messages suppressed since the last message of each route.
+func (writer *slackWriter) Close() error {
+	var lastErr error
+	for _, route := range writer.routes {
+		if suppressed := route.throttle.takeSuppressed(); suppressed > 0 {
+			if err := writer.post(route, slackSuppressedText(suppressed)); err != nil {
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+func (writer *slackWriter) String() string {
+	return fmt.Sprintf("slackWriter: [%s, %d routes], format: %s\n", writer.minLevel, len(writer.routes), writer.formatter)
+}
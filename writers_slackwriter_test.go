@@ -0,0 +1,175 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertThrottle(t *testing.T) {
+	throttle := newAlertThrottle(time.Minute, 2, 10*time.Second)
+	start := time.Now()
+
+	steps := []struct {
+		key        string
+		offset     time.Duration
+		allowed    bool
+		suppressed int
+	}{
+		{"a", 0, true, 0},
+		{"a", time.Second, false, 0},
+		{"b", 2 * time.Second, true, 1},
+		{"c", 3 * time.Second, false, 0},
+		{"c", 11 * time.Second, true, 1},
+		{"a", 30 * time.Second, false, 0},
+		{"a", 61 * time.Second, true, 1},
+	}
+	for i, step := range steps {
+		suppressed, allowed := throttle.allow(step.key, start.Add(step.offset))
+		if allowed != step.allowed || suppressed != step.suppressed {
+			t.Errorf("Step %d: expected %v with %d suppressed, got %v with %d",
+				i, step.allowed, step.suppressed, allowed, suppressed)
+		}
+	}
+	if suppressed := throttle.takeSuppressed(); suppressed != 0 {
+		t.Errorf("Expected nothing suppressed, got %d", suppressed)
+	}
+}
+
+func TestAlertThrottleKeyCap(t *testing.T) {
+	throttle := newAlertThrottle(time.Hour, 0, 0)
+	start := time.Now()
+
+	for i := 0; i < maxAlertThrottleKeys+10; i++ {
+		if _, allowed := throttle.allow(fmt.Sprint(i), start); !allowed {
+			t.Fatalf("Alert %d must be allowed", i)
+		}
+	}
+	if len(throttle.seen) != maxAlertThrottleKeys || len(throttle.seenOrder) != maxAlertThrottleKeys {
+		t.Fatalf("Expected %d keys, got %d in seen and %d in order",
+			maxAlertThrottleKeys, len(throttle.seen), len(throttle.seenOrder))
+	}
+	if _, allowed := throttle.allow("0", start); !allowed {
+		t.Error("The oldest alert must be forgotten beyond the cap")
+	}
+	if _, allowed := throttle.allow("20", start); allowed {
+		t.Error("A recent alert must still be deduplicated")
+	}
+
+	throttle.forget("20")
+	if _, allowed := throttle.allow("20", start.Add(time.Minute)); !allowed {
+		t.Error("A forgotten alert must be allowed")
+	}
+	if _, allowed := throttle.allow("21", start.Add(time.Hour)); !allowed {
+		t.Error("An alert must be allowed after the dedup window")
+	}
+	if _, allowed := throttle.allow("20", start.Add(time.Hour)); allowed {
+		t.Error("An alert sent again must not expire with its older entry")
+	}
+}
+
+func TestSlackRoutes(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<slack formatid="msg" url="`+server.URL+`/default" channel="#alerts"
+		username="billing" icon=":fire:" limit="2">
+		<route levels="critical" url="`+server.URL+`/oncall"/>
+	</slack>`})
+	logger.Info("ignored")
+	logger.Warn("disk <90%> full")
+	logger.Warn("disk <90%> full")
+	logger.Error("first")
+	logger.Error("second")
+	logger.Critical("down")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(bodies) != 4 {
+		t.Fatalf("Expected four posts, got %q", bodies)
+	}
+	expected := []struct{ path, text string }{
+		{"/default", "disk &lt;90%&gt; full"},
+		{"/default", "first\n_1 more message suppressed_"},
+		{"/oncall", "down"},
+		{"/default", "_1 more message suppressed_"},
+	}
+	for i, post := range expected {
+		var payload map[string]string
+		if err := json.Unmarshal([]byte(bodies[i]), &payload); err != nil {
+			t.Fatalf("Expected a JSON payload, got %s: %s", bodies[i], err)
+		}
+		if requests[i].URL.Path != post.path || payload["text"] != post.text {
+			t.Errorf("Expected %q to %s, got %q to %s", post.text, post.path, payload["text"], requests[i].URL.Path)
+		}
+	}
+	var payload map[string]string
+	json.Unmarshal([]byte(bodies[0]), &payload)
+	if payload["channel"] != "#alerts" || payload["username"] != "billing" || payload["icon_emoji"] != ":fire:" {
+		t.Errorf("Unexpected payload: %v", payload)
+	}
+}
+
+func TestSlackSuppressedCount(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<slack formatid="msg" url="`+server.URL+`" minlevel="error" dedup="0" interval="50ms" limit="1"/>`})
+	logger.Error("first")
+	logger.Error("second")
+	logger.Error("third")
+	time.Sleep(60 * time.Millisecond)
+	logger.Error("fourth")
+	logger.Close()
+
+	_, bodies := server.sent()
+	if len(bodies) != 2 || !strings.Contains(bodies[1], `fourth\n_2 more messages suppressed_`) {
+		t.Errorf("Unexpected posts: %q", bodies)
+	}
+}
+
+func TestSlackConfig(t *testing.T) {
+	invalid := []string{
+		`<slack/>`,
+		`<slack url="hooks.slack.com/services/x"/>`,
+		`<slack url="https://hooks.slack.com/services/x" minlevel="loud"/>`,
+		`<slack url="https://hooks.slack.com/services/x" dedup="soon"/>`,
+		`<slack url="https://hooks.slack.com/services/x" limit="-1"/>`,
+		`<slack url="https://hooks.slack.com/services/x"><route channel="#oncall"/></slack>`,
+		`<slack url="https://hooks.slack.com/services/x"><route levels="loud"/></slack>`,
+		`<slack url="https://hooks.slack.com/services/x"><route levels="error" url="ftp://x"/></slack>`,
+		`<slack url="https://hooks.slack.com/services/x"><header name="a" value="b"/></slack>`,
+	}
+	for _, slack := range invalid {
+		config := `<seelog><outputs>` + slack + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -25,13 +25,23 @@
 package seelog
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"html/template"
 	"io/ioutil"
+	"mime/multipart"
+	"net"
 	"net/smtp"
+	"net/textproto"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -40,6 +50,19 @@ const (
 	rfc5321SubjectPattern = "From: %s <%s>\nSubject: %s\n"
 )
 
+// TLS modes of the smtp receiver
+const (
+	smtpTLSAuto     = "auto"     // STARTTLS if the server supports it
+	smtpTLSStartTLS = "starttls" // STARTTLS, failing if the server does not support it
+	smtpTLSImplicit = "implicit" // TLS from the start, as on port 465
+)
+
+// Authentication mechanisms of the smtp receiver
+const (
+	smtpAuthPlain   = "plain"
+	smtpAuthXoauth2 = "xoauth2"
+)
+
 // smtpWriter is used to send emails via given SMTP-server.
 type smtpWriter struct {
 	auth               smtp.Auth
@@ -50,6 +73,9 @@ type smtpWriter struct {
 	senderName         string
 	recipientAddresses []string
 	caCertDirPaths     []string
+	tlsMode            string
+	tlsServerName      string // Verified instead of hostName if set
+	tlsSkipVerify      bool
 }
 
 // newSmtpWriter returns a new SMTP-writer.
@@ -63,6 +89,7 @@ func newSmtpWriter(sa, sn string, ras []string, hn, hp, un, pwd string, cacdps [
 		senderName:         sn,
 		recipientAddresses: ras,
 		caCertDirPaths:     cacdps,
+		tlsMode:            smtpTLSAuto,
 	}
 }
 
@@ -71,6 +98,39 @@ func prepareMessage(senderAddr, senderName, subject string, body []byte) []byte
 	return append(h, body...)
 }
 
+// xoauth2Auth authenticates with an OAuth 2.0 access token, as Office 365 and Gmail
+// require. The token is resolved as a secret reference on every authentication, so that a
+// secret provider can hand out fresh tokens.
+type xoauth2Auth struct {
+	userName string
+	token    string
+}
+
+func newXoauth2Auth(userName, token string) smtp.Auth {
+	return &xoauth2Auth{userName: userName, token: token}
+}
+
+func (auth *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like smtp.PlainAuth, do not send the token over unencrypted connections but to localhost.
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	token, err := resolveSecret(auth.token)
+	if err != nil {
+		return "", nil, err
+	}
+	return "XOAUTH2", []byte("user=" + auth.userName + "\x01auth=Bearer " + token + "\x01\x01"), nil
+}
+
+// Next answers the error details the server sends when the token is refused with an
+// empty response, after which the server fails the authentication.
+func (auth *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
 // getTLSConfig gets paths of PEM files with certificates,
 // host server name and tries to create an appropriate TLS.Config.
 func getTLSConfig(pemFileDirPaths []string, hostName string) (config *tls.Config, err error) {
@@ -120,15 +180,33 @@ func getTLSConfig(pemFileDirPaths []string, hostName string) (config *tls.Config
 // SendMail accepts TLS configuration, connects to the server at addr,
 // switches to TLS if possible, authenticates with mechanism a if possible,
 // and then sends an email from address from, to addresses to, with message msg.
-func sendMailWithTLSConfig(config *tls.Config, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
-	c, err := smtp.Dial(addr)
-	if err != nil {
+// In tlsMode implicit the connection is encrypted from the start, in starttls
+// the server must support switching to TLS.
+func sendMailWithTLSConfig(config *tls.Config, tlsMode string, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	var c *smtp.Client
+	var err error
+	if tlsMode == smtpTLSImplicit {
+		var conn *tls.Conn
+		if conn, err = tls.Dial("tcp", addr, config); err != nil {
+			return err
+		}
+		host, _, _ := net.SplitHostPort(addr)
+		if c, err = smtp.NewClient(conn, host); err != nil {
+			conn.Close()
+			return err
+		}
+	} else if c, err = smtp.Dial(addr); err != nil {
 		return err
 	}
+	defer c.Close()
 	// Check if the server supports STARTTLS extension.
-	if ok, _ := c.Extension("STARTTLS"); ok {
-		if err = c.StartTLS(config); err != nil {
-			return err
+	if tlsMode != smtpTLSImplicit {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(config); err != nil {
+				return err
+			}
+		} else if tlsMode == smtpTLSStartTLS {
+			return errors.New("Smtp server does not support STARTTLS")
 		}
 	}
 	// Check if the server supports AUTH extension and use given smtp.Auth.
@@ -167,33 +245,52 @@ func sendMailWithTLSConfig(config *tls.Config, addr string, a smtp.Auth, from st
 // Write pushes a text message properly composed according to RFC 5321
 // to a post server, which sends it to the recipients.
 func (smtpw *smtpWriter) Write(data []byte) (int, error) {
-	var err error
-	if smtpw.caCertDirPaths == nil {
-		err = smtp.SendMail(
-			smtpw.hostNameWithPort,
-			smtpw.auth,
-			smtpw.senderAddress,
-			smtpw.recipientAddresses,
-			prepareMessage(smtpw.senderAddress, smtpw.senderName, subjectPhrase, data),
-		)
-	} else {
-		config, e := getTLSConfig(smtpw.caCertDirPaths, smtpw.hostName)
-		if e != nil {
-			return 0, e
-		}
-		err = sendMailWithTLSConfig(
-			config,
+	if err := smtpw.sendTo(smtpw.recipientAddresses, subjectPhrase, data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// sendTo sends one email with the given subject and body to the given recipients.
+func (smtpw *smtpWriter) sendTo(recipients []string, subject string, body []byte) error {
+	if smtpw.caCertDirPaths == nil && smtpw.tlsMode == smtpTLSAuto && smtpw.tlsServerName == "" && !smtpw.tlsSkipVerify {
+		return smtp.SendMail(
 			smtpw.hostNameWithPort,
 			smtpw.auth,
 			smtpw.senderAddress,
-			smtpw.recipientAddresses,
-			prepareMessage(smtpw.senderAddress, smtpw.senderName, subjectPhrase, data),
+			recipients,
+			prepareMessage(smtpw.senderAddress, smtpw.senderName, subject, body),
 		)
 	}
+	config, err := smtpw.tlsConfig()
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return len(data), nil
+	return sendMailWithTLSConfig(
+		config,
+		smtpw.tlsMode,
+		smtpw.hostNameWithPort,
+		smtpw.auth,
+		smtpw.senderAddress,
+		recipients,
+		prepareMessage(smtpw.senderAddress, smtpw.senderName, subject, body),
+	)
+}
+
+func (smtpw *smtpWriter) tlsConfig() (*tls.Config, error) {
+	serverName := smtpw.hostName
+	if smtpw.tlsServerName != "" {
+		serverName = smtpw.tlsServerName
+	}
+	config := &tls.Config{ServerName: serverName}
+	if smtpw.caCertDirPaths != nil {
+		var err error
+		if config, err = getTLSConfig(smtpw.caCertDirPaths, serverName); err != nil {
+			return nil, err
+		}
+	}
+	config.InsecureSkipVerify = smtpw.tlsSkipVerify
+	return config, nil
 }
 
 // Close closes down SMTP-connection.
@@ -201,3 +298,453 @@ func (smtpWriter *smtpWriter) Close() error {
 	// Do nothing as Write method opens and closes connection automatically
 	return nil
 }
+
+const (
+	defaultSmtpDigestCount    = 100
+	defaultSmtpDigestInterval = 5 * time.Minute
+	defaultSmtpDigestSubject  = "Diagnostic digest from server: %Summary"
+	defaultSmtpLimitInterval  = time.Hour
+	smtpContextFileName       = "context.log"
+)
+
+var defaultSmtpDigestLevels = []LogLevel{ErrorLvl, CriticalLvl}
+
+// smtpAlertWriter keeps an error storm from sending an email per message, and adds the
+// context needed to debug to the emails.
+//
+// Messages below minLevel are not sent; the last contextSize of them are kept, and the
+// ones logged since the previous email are added to the next one: its template gets them
+// as .Context, and they are attached as context.log.
+//
+// In digest mode, messages of the digest levels are collected and sent together in one
+// email, when digestCount of them are collected, digestInterval after the first one and
+// on Close. Messages of other levels are sent one per email as usual. The subject of a
+// digest is a template: %Count is replaced with the number of messages in it, %Summary
+// with their counts per level, e.g. "2 critical, 40 error", %First with the first message,
+// and the name of a level, e.g. %Critical or %Error, with the number of messages of that
+// level.
+//
+// Recipients with levels get only emails with messages of these levels; a digest goes to
+// the recipients of any level of its messages.
+//
+// A message which repeats one of the same level within the dedup window is dropped, and a
+// note of how many were is added to the next email, or sent on its own on Close. Every
+// recipient gets at most limit emails per interval (see alertThrottle); emails beyond the
+// limit of all recipients are dropped.
+//
+// With a template, emails are sent as HTML rendered from it with smtpTemplateData.
+type smtpAlertWriter struct {
+	formatter      *formatter
+	smtp           *smtpWriter
+	minLevel       LogLevel
+	context        *RingBuffer
+	template       *template.Template
+	digestLevels   []LogLevel
+	digestCount    int
+	digestInterval time.Duration
+	digestSubject  string
+	dedup          *alertThrottle
+	limits         map[string]*alertThrottle
+
+	recipientLevels map[string][]LogLevel
+
+	mutex         sync.Mutex
+	records       []RingBufferRecord
+	recordContext []RingBufferRecord
+	counts        map[LogLevel]int
+	timer         *time.Timer
+	suppressed    int
+	closed        bool
+}
+
+// smtpAlertOptions are the settings of the digest mode, the throttling, the context and the
+// recipients per level of an smtp receiver. Zero values of the digest settings are replaced
+// with the defaults when digest is set; a zero dedup or limit disables that check.
+// Recipients missing in recipientLevels get messages of all levels.
+type smtpAlertOptions struct {
+	minLevel       LogLevel
+	contextSize    int
+	template       *template.Template
+	digest         bool
+	digestLevels   []LogLevel
+	digestCount    int
+	digestInterval time.Duration
+	digestSubject  string
+	dedup          time.Duration
+	limit          int
+	limitInterval  time.Duration
+
+	recipientLevels map[string][]LogLevel
+}
+
+// smtpTemplateData is passed to the template of HTML emails.
+type smtpTemplateData struct {
+	Subject    string
+	Record     RingBufferRecord   // The first record of the email
+	Records    []RingBufferRecord // All records of the email, more than one in digests
+	Summary    string             // The counts of the records per level in digests
+	Context    []RingBufferRecord // The records below minlevel logged before, oldest first
+	Suppressed int                // The number of duplicates dropped since the previous email
+}
+
+func newSmtpAlertWriter(formatter *formatter, sender *smtpWriter, options smtpAlertOptions) (*smtpAlertWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if options.digest {
+		if len(options.digestLevels) == 0 {
+			options.digestLevels = defaultSmtpDigestLevels
+		}
+		if options.digestCount == 0 {
+			options.digestCount = defaultSmtpDigestCount
+		}
+		if options.digestInterval == 0 {
+			options.digestInterval = defaultSmtpDigestInterval
+		}
+		if options.digestSubject == "" {
+			options.digestSubject = defaultSmtpDigestSubject
+		}
+	} else {
+		options.digestLevels = nil
+	}
+	if options.limitInterval == 0 {
+		options.limitInterval = defaultSmtpLimitInterval
+	}
+	if options.contextSize < 0 || options.digestCount < 0 || options.digestInterval < 0 || options.dedup < 0 ||
+		options.limit < 0 || options.limitInterval < 0 {
+		return nil, errors.New("Smtp receiver digest, throttling and context settings can not be negative")
+	}
+
+	writer := &smtpAlertWriter{
+		formatter:      formatter,
+		smtp:           sender,
+		minLevel:       options.minLevel,
+		template:       options.template,
+		digestLevels:   options.digestLevels,
+		digestCount:    options.digestCount,
+		digestInterval: options.digestInterval,
+		digestSubject:  options.digestSubject,
+		counts:         make(map[LogLevel]int),
+
+		recipientLevels: options.recipientLevels,
+	}
+	if options.contextSize > 0 {
+		writer.context = newRingBuffer(options.contextSize)
+	}
+	if options.dedup > 0 {
+		writer.dedup = newAlertThrottle(options.dedup, 0, 0)
+	}
+	if options.limit > 0 {
+		writer.limits = make(map[string]*alertThrottle)
+		for _, recipient := range sender.recipientAddresses {
+			writer.limits[recipient] = newAlertThrottle(0, options.limit, options.limitInterval)
+		}
+	}
+	return writer, nil
+}
+
+func (writer *smtpAlertWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("Smtp writer is closed"))
+		return
+	}
+	record := RingBufferRecord{
+		Time:    context.CallTime(),
+		Level:   level,
+		Message: message,
+		Text:    writer.formatter.Format(message, level, context),
+		Fields:  append([]Field(nil), context.Fields()...),
+	}
+	if level.severity() < writer.minLevel.severity() {
+		if writer.context != nil {
+			writer.context.add(record)
+		}
+		return
+	}
+	if writer.dedup != nil {
+		suppressed, ok := writer.dedup.allow(level.String()+"\x00"+message, record.Time)
+		writer.suppressed += suppressed
+		if !ok {
+			return
+		}
+	}
+
+	if !writer.isDigestLevel(level) {
+		if err := writer.sendMail(subjectPhrase, []RingBufferRecord{record}, "", writer.takeContext()); err != nil {
+			errorFunc(err)
+		}
+		return
+	}
+
+	if len(writer.records) == 0 {
+		writer.recordContext = writer.takeContext()
+		writer.timer = time.AfterFunc(writer.digestInterval, writer.sendOnTimer)
+	}
+	writer.records = append(writer.records, record)
+	writer.counts[level]++
+
+	if len(writer.records) >= writer.digestCount {
+		if err := writer.sendDigest(); err != nil {
+			errorFunc(err)
+		}
+	}
+}
+
+func (writer *smtpAlertWriter) isDigestLevel(level LogLevel) bool {
+	for _, digestLevel := range writer.digestLevels {
+		if digestLevel == level {
+			return true
+		}
+	}
+	return false
+}
+
+// takeContext returns the context records logged since the previous email.
+func (writer *smtpAlertWriter) takeContext() []RingBufferRecord {
+	if writer.context == nil {
+		return nil
+	}
+	records := writer.context.Snapshot()
+	writer.context.Reset()
+	return records
+}
+
+// recipientsFor returns the recipients of an email with the given records. Emails without
+// records go to all recipients.
+func (writer *smtpAlertWriter) recipientsFor(records []RingBufferRecord) []string {
+	if writer.recipientLevels == nil || len(records) == 0 {
+		return writer.smtp.recipientAddresses
+	}
+	var recipients []string
+	for _, recipient := range writer.smtp.recipientAddresses {
+		levels, restricted := writer.recipientLevels[recipient]
+		if !restricted {
+			recipients = append(recipients, recipient)
+			continue
+		}
+	records:
+		for _, record := range records {
+			for _, level := range levels {
+				if record.Level == level {
+					recipients = append(recipients, recipient)
+					break records
+				}
+			}
+		}
+	}
+	return recipients
+}
+
+// sendMail sends an email of the given records to their recipients within their limits,
+// with the note of suppressed duplicates if there are any.
+func (writer *smtpAlertWriter) sendMail(subject string, records []RingBufferRecord, summary string,
+	context []RingBufferRecord) error {
+	recipients := writer.recipientsFor(records)
+	if writer.limits != nil {
+		now := time.Now()
+		var allowed []string
+		for _, recipient := range recipients {
+			if writer.limits[recipient].reserve(now) == 0 {
+				allowed = append(allowed, recipient)
+			}
+		}
+		recipients = allowed
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	writer.takeSuppressed()
+	data := smtpTemplateData{
+		Subject:    subject,
+		Records:    records,
+		Summary:    summary,
+		Context:    context,
+		Suppressed: writer.suppressed,
+	}
+	if len(records) > 0 {
+		data.Record = records[0]
+	}
+	body, err := writer.mailBody(data)
+	if err != nil {
+		return err
+	}
+	writer.suppressed = 0
+	return writer.smtp.sendTo(recipients, subject, body)
+}
+
+// mailBody returns the body of an email, following the subject header. Without a template
+// and context it is the plain text of the records; otherwise it is a MIME message of the
+// text, or of the HTML rendered from the template, and of the context attached.
+func (writer *smtpAlertWriter) mailBody(data smtpTemplateData) ([]byte, error) {
+	var text strings.Builder
+	if data.Summary != "" {
+		text.WriteString("\n" + data.Summary + "\n\n")
+	}
+	for _, record := range data.Records {
+		text.WriteString(record.Text)
+	}
+	if data.Suppressed > 0 {
+		trimmed := strings.TrimRight(text.String(), "\r\n")
+		text.Reset()
+		text.WriteString(trimmed + "\n\n" + smtpSuppressedText(data.Suppressed) + "\n")
+	}
+	if len(data.Records) == 0 || writer.template == nil && len(data.Context) == 0 {
+		return []byte(text.String()), nil
+	}
+
+	contentType, content := "text/plain; charset=UTF-8", []byte(strings.TrimLeft(text.String(), "\n"))
+	if writer.template != nil {
+		var html bytes.Buffer
+		if err := writer.template.Execute(&html, data); err != nil {
+			return nil, err
+		}
+		contentType, content = "text/html; charset=UTF-8", html.Bytes()
+	}
+
+	var body bytes.Buffer
+	body.WriteString("MIME-Version: 1.0\n")
+	if len(data.Context) == 0 {
+		body.WriteString("Content-Type: " + contentType + "\n\n")
+		body.Write(content)
+		return body.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&body)
+	body.WriteString("Content-Type: multipart/mixed; boundary=" + parts.Boundary() + "\n\n")
+	part, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+	if err != nil {
+		return nil, err
+	}
+	part.Write(content)
+	part, err = parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"text/plain; charset=UTF-8"},
+		"Content-Disposition": {`attachment; filename="` + smtpContextFileName + `"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range data.Context {
+		part.Write([]byte(record.Text))
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// takeSuppressed adds the duplicates suppressed since the last one let through.
+func (writer *smtpAlertWriter) takeSuppressed() {
+	if writer.dedup != nil {
+		writer.suppressed += writer.dedup.takeSuppressed()
+	}
+}
+
+func smtpSuppressedText(suppressed int) string {
+	if suppressed == 1 {
+		return "Suppressed 1 duplicate"
+	}
+	return fmt.Sprintf("Suppressed %d duplicates", suppressed)
+}
+
+func (writer *smtpAlertWriter) sendOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.sendDigest(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+// sendDigest sends the collected messages in one email and starts a new digest.
+func (writer *smtpAlertWriter) sendDigest() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	records, context, counts := writer.records, writer.recordContext, writer.counts
+	writer.records = nil
+	writer.recordContext = nil
+	writer.counts = make(map[LogLevel]int)
+	if len(records) == 0 {
+		return nil
+	}
+
+	subject := writer.digestSubjectFor(len(records), counts, records[0].Message)
+	if err := writer.sendMail(subject, records, smtpDigestSummary(writer.digestLevels, counts), context); err != nil {
+		return fmt.Errorf("Cannot send a digest of %d messages: %s", len(records), err)
+	}
+	return nil
+}
+
+// digestSubjectFor fills in the subject template. Line breaks are replaced with spaces,
+// so that messages can not add headers.
+func (writer *smtpAlertWriter) digestSubjectFor(count int, counts map[LogLevel]int, first string) string {
+	replacements := []string{
+		"%Count", strconv.Itoa(count),
+		"%Summary", smtpDigestSummary(writer.digestLevels, counts),
+		"%First", first,
+	}
+	for _, level := range writer.digestLevels {
+		name := level.String()
+		if name == "" {
+			continue
+		}
+		replacements = append(replacements, "%"+strings.ToUpper(name[:1])+name[1:], strconv.Itoa(counts[level]))
+	}
+	subject := strings.NewReplacer(replacements...).Replace(writer.digestSubject)
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(subject)
+}
+
+// smtpDigestSummary returns the counts of messages of the levels which have any, from the
+// highest level down, e.g. "2 critical, 40 error".
+func smtpDigestSummary(levels []LogLevel, counts map[LogLevel]int) string {
+	sorted := append([]LogLevel(nil), levels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].severity() > sorted[j].severity() })
+
+	var parts []string
+	for _, level := range sorted {
+		if counts[level] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[level], level))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Flush does not send the collected messages: loggers flush on every critical message,
+// which would send an email per message again in a storm of them.
+func (writer *smtpAlertWriter) Flush() {
+}
+
+// Close sends the collected messages, and the note of suppressed duplicates if it is not
+// sent with them.
+func (writer *smtpAlertWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	err := writer.sendDigest()
+	if writer.takeSuppressed(); err == nil && writer.suppressed > 0 {
+		err = writer.sendMail(subjectPhrase, nil, "", nil)
+	}
+	return err
+}
+
+func (writer *smtpAlertWriter) String() string {
+	return fmt.Sprintf("smtpAlertWriter: [%s, %s, %v, %d, %s], format: %s\n", writer.smtp.hostNameWithPort,
+		writer.minLevel, writer.digestLevels, writer.digestCount, writer.digestInterval, writer.formatter)
+}
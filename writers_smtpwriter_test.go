@@ -0,0 +1,475 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpTestServer is a minimal SMTP server which accepts any mail and passes on the
+// authentication, recipients and data of every email. With startTLS set it supports
+// STARTTLS.
+type smtpTestServer struct {
+	listener net.Listener
+	startTLS *tls.Config
+	mails    chan smtpTestMail
+}
+
+type smtpTestMail struct {
+	auth       string
+	recipients []string
+	data       string
+}
+
+func newSmtpTestServer(t *testing.T) *smtpTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startSmtpTestServer(listener, nil)
+}
+
+// newSmtpTLSTestServer starts a server with a certificate for mail.test, speaking TLS from
+// the start if implicit is set or supporting STARTTLS otherwise. It returns the server and
+// a directory with the certificate in a PEM file.
+func newSmtpTLSTestServer(t *testing.T, implicit bool) (*smtpTestServer, string) {
+	certificate, certFile, _ := newTestCertificate(t, "mail.test")
+	certDir := filepath.Dir(certFile)
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicit {
+		return startSmtpTestServer(tls.NewListener(listener, config), nil), certDir
+	}
+	return startSmtpTestServer(listener, config), certDir
+}
+
+func startSmtpTestServer(listener net.Listener, startTLS *tls.Config) *smtpTestServer {
+	server := &smtpTestServer{listener: listener, startTLS: startTLS, mails: make(chan smtpTestMail, 16)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (server *smtpTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	conn.Write([]byte("220 localhost\r\n"))
+	var auth string
+	var recipients []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch command := strings.ToUpper(strings.Fields(line)[0]); command {
+		case "EHLO":
+			if _, isTLS := conn.(*tls.Conn); server.startTLS != nil && !isTLS {
+				conn.Write([]byte("250-localhost\r\n250-STARTTLS\r\n250 AUTH PLAIN XOAUTH2\r\n"))
+			} else {
+				conn.Write([]byte("250-localhost\r\n250 AUTH PLAIN XOAUTH2\r\n"))
+			}
+		case "STARTTLS":
+			conn.Write([]byte("220 go ahead\r\n"))
+			conn = tls.Server(conn, server.startTLS)
+			defer conn.Close()
+			reader = bufio.NewReader(conn)
+		case "AUTH":
+			fields := strings.Fields(line)
+			response, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			auth = fields[1] + " " + string(response)
+			conn.Write([]byte("235 ok\r\n"))
+		case "DATA":
+			conn.Write([]byte("354 go on\r\n"))
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			server.mails <- smtpTestMail{auth, recipients, data.String()}
+			recipients = nil
+			conn.Write([]byte("250 ok\r\n"))
+		case "RCPT":
+			recipients = append(recipients, strings.Trim(line[strings.Index(line, ":")+1:], "<>\r\n"))
+			conn.Write([]byte("250 ok\r\n"))
+		case "QUIT":
+			conn.Write([]byte("221 bye\r\n"))
+			return
+		default:
+			conn.Write([]byte("250 ok\r\n"))
+		}
+	}
+}
+
+func (server *smtpTestServer) port() string {
+	_, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	return port
+}
+
+func (server *smtpTestServer) nextMail(t *testing.T) smtpTestMail {
+	select {
+	case mail := <-server.mails:
+		return mail
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an email")
+		return smtpTestMail{}
+	}
+}
+
+func smtpTestConfig(port, digest string) string {
+	return `<smtp senderaddress="log@b.c" sendername="log" hostname="127.0.0.1" hostport="` + port +
+		`" username="u" password="p" ` + digest + `><recipient address="ops@b.c"/><recipient address="dev@b.c"/></smtp>`
+}
+
+func TestSmtpDigest(t *testing.T) {
+	server := newSmtpTestServer(t)
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: smtpTestConfig(server.port(),
+		`digest="true" digestcount="3" digestinterval="1h" digestsubject="[billing] %Count: %Summary (%Error errors) %First"`)})
+	logger.Error("first\r\nBcc: evil@b.c")
+	logger.Critical("down")
+	logger.Warn("disk full")
+	if mail := server.nextMail(t).data; !strings.Contains(mail, "Subject: "+subjectPhrase+"\r\n") ||
+		!strings.HasSuffix(mail, "disk full\r\n") {
+		t.Errorf("Expected the warning to be sent on its own, got %q", mail)
+	}
+	logger.Error("second")
+
+	mail := server.nextMail(t).data
+	expectedSubject := "Subject: [billing] 3: 1 critical, 2 error (2 errors) first Bcc: evil@b.c\r\n"
+	if !strings.Contains(mail, expectedSubject) {
+		t.Errorf("Expected %q in %q", expectedSubject, mail)
+	}
+	expectedBody := "\r\n1 critical, 2 error\r\n\r\nfirst\r\nBcc: evil@b.c\r\ndown\r\nsecond\r\n"
+	if !strings.HasSuffix(mail, expectedBody) {
+		t.Errorf("Expected body %q in %q", expectedBody, mail)
+	}
+
+	logger.Error("last")
+	logger.Close()
+	if mail := server.nextMail(t).data; !strings.Contains(mail, "Subject: [billing] 1: 1 error (1 errors) last\r\n") {
+		t.Errorf("Expected the rest to be sent on close, got %q", mail)
+	}
+}
+
+func TestSmtpDigestInterval(t *testing.T) {
+	server := newSmtpTestServer(t)
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: smtpTestConfig(server.port(), `digest="true" digestlevels="warn" digestinterval="50ms"`)})
+	defer logger.Close()
+	logger.Warn("first")
+	logger.Warn("second")
+	mail := server.nextMail(t).data
+	if !strings.Contains(mail, "Subject: Diagnostic digest from server: 2 warn\r\n") ||
+		!strings.HasSuffix(mail, "first\r\nsecond\r\n") {
+		t.Errorf("Expected a digest of both warnings, got %q", mail)
+	}
+}
+
+func TestSmtpDedup(t *testing.T) {
+	server := newSmtpTestServer(t)
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: smtpTestConfig(server.port(), `dedup="1h"`)})
+	logger.Error("disk full")
+	logger.Error("disk full")
+	logger.Error("disk full")
+	logger.Warn("disk full")
+	logger.Error("down")
+	logger.Error("down")
+	logger.Close()
+
+	expected := []string{
+		"disk full\r\n",
+		"disk full\r\n\r\nSuppressed 2 duplicates\r\n",
+		"down\r\n",
+		"\r\n\r\nSuppressed 1 duplicate\r\n",
+	}
+	for _, body := range expected {
+		if mail := server.nextMail(t).data; !strings.HasSuffix(mail, "Subject: "+subjectPhrase+"\r\n"+body) {
+			t.Errorf("Expected body %q, got %q", body, mail)
+		}
+	}
+}
+
+func TestSmtpLimit(t *testing.T) {
+	server := newSmtpTestServer(t)
+	defer server.listener.Close()
+
+	sender := newSmtpWriter("log@b.c", "log", []string{"ops@b.c", "dev@b.c"}, "127.0.0.1", server.port(), "u", "p", nil)
+	writer, err := newSmtpAlertWriter(mustNewMsgFormatter(t), sender, smtpAlertOptions{limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ops@b.c has one email of its limit left
+	writer.limits["ops@b.c"].reserve(time.Now())
+	context, err := currentContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"first", "second", "third"} {
+		writer.Dispatch(message, ErrorLvl, context, func(err error) { t.Error(err) })
+	}
+
+	expected := [][]string{{"ops@b.c", "dev@b.c"}, {"dev@b.c"}}
+	for _, recipients := range expected {
+		if mail := server.nextMail(t); strings.Join(mail.recipients, ",") != strings.Join(recipients, ",") {
+			t.Errorf("Expected an email to %v, got %v", recipients, mail.recipients)
+		}
+	}
+	select {
+	case mail := <-server.mails:
+		t.Errorf("Expected no more emails, got one to %v", mail.recipients)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// readSmtpTestParts returns the content types and contents of the parts of a multipart
+// email.
+func readSmtpTestParts(t *testing.T, data string) ([]string, []string) {
+	message, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Expected a multipart email, got %q: %v", message.Header.Get("Content-Type"), err)
+	}
+	var types, contents []string
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return types, contents
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type")+"; "+part.FileName())
+		contents = append(contents, string(content))
+	}
+}
+
+func TestSmtpContext(t *testing.T) {
+	server := newSmtpTestServer(t)
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: smtpTestConfig(server.port(), `minlevel="error" context="2"`)})
+	defer logger.Close()
+	logger.Info("connecting")
+	logger.Debug("retrying")
+	logger.Warn("giving up")
+	logger.Error("down")
+	logger.Error("still down")
+
+	types, contents := readSmtpTestParts(t, server.nextMail(t).data)
+	expectedTypes := []string{"text/plain; charset=UTF-8; ", "text/plain; charset=UTF-8; context.log"}
+	expectedContents := []string{"down\r\n", "retrying\r\ngiving up\r\n"}
+	if strings.Join(types, "|") != strings.Join(expectedTypes, "|") ||
+		strings.Join(contents, "|") != strings.Join(expectedContents, "|") {
+		t.Errorf("Expected parts %q %q, got %q %q", expectedTypes, expectedContents, types, contents)
+	}
+	// Nothing was logged below minlevel since
+	if mail := server.nextMail(t).data; !strings.HasSuffix(mail, "Subject: "+subjectPhrase+"\r\nstill down\r\n") {
+		t.Errorf("Expected a plain email, got %q", mail)
+	}
+}
+
+func TestSmtpHtmlTemplate(t *testing.T) {
+	server := newSmtpTestServer(t)
+	defer server.listener.Close()
+
+	templatePath := filepath.Join(t.TempDir(), "alert.html")
+	template := `<h1>{{.Record.Level}}: {{.Record.Message}}</h1>{{range .Context}}<pre>{{.Text}}</pre>{{end}}`
+	if err := ioutil.WriteFile(templatePath, []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: smtpTestConfig(server.port(), `minlevel="error" context="10" htmltemplate="`+templatePath+`"`)})
+	defer logger.Close()
+	logger.Warn("slow <db>")
+	logger.Error("down")
+
+	types, contents := readSmtpTestParts(t, server.nextMail(t).data)
+	expectedTypes := []string{"text/html; charset=UTF-8; ", "text/plain; charset=UTF-8; context.log"}
+	expectedContents := []string{"<h1>error: down</h1><pre>slow &lt;db&gt;\r\n</pre>", "slow <db>\r\n"}
+	if strings.Join(types, "|") != strings.Join(expectedTypes, "|") ||
+		strings.Join(contents, "|") != strings.Join(expectedContents, "|") {
+		t.Errorf("Expected parts %q %q, got %q %q", expectedTypes, expectedContents, types, contents)
+	}
+
+	logger.Error("still down")
+	message, err := mail.ReadMessage(strings.NewReader(server.nextMail(t).data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(message.Body)
+	contentType := message.Header.Get("Content-Type")
+	if contentType != "text/html; charset=UTF-8" || strings.TrimSpace(string(body)) != "<h1>error: still down</h1>" {
+		t.Errorf("Expected an html email, got %q: %q", contentType, body)
+	}
+}
+
+func TestSmtpXoauth2ImplicitTLS(t *testing.T) {
+	server, certDir := newSmtpTLSTestServer(t, true)
+	defer server.listener.Close()
+
+	t.Setenv("SMTP_TEST_TOKEN", "first-token")
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<smtp senderaddress="log@b.c" sendername="log" hostname="127.0.0.1" hostport="`+
+		server.port()+`" username="log@b.c" tls="implicit" tlsservername="mail.test" auth="xoauth2"
+		token="env://SMTP_TEST_TOKEN"><recipient address="ops@b.c"/><cacertdirpath path="`+certDir+`"/></smtp>`})
+	defer logger.Close()
+
+	logger.Error("down")
+	if mail := server.nextMail(t); mail.auth != "XOAUTH2 user=log@b.c\x01auth=Bearer first-token\x01\x01" {
+		t.Errorf("Expected the token, got %q", mail.auth)
+	}
+	// The token is resolved again for every email
+	t.Setenv("SMTP_TEST_TOKEN", "second-token")
+	logger.Error("still down")
+	if mail := server.nextMail(t); !strings.Contains(mail.auth, "auth=Bearer second-token") {
+		t.Errorf("Expected the refreshed token, got %q", mail.auth)
+	}
+}
+
+func TestSmtpStartTLS(t *testing.T) {
+	server, certDir := newSmtpTLSTestServer(t, false)
+	defer server.listener.Close()
+
+	sender := newSmtpWriter("log@b.c", "log", []string{"ops@b.c"}, "127.0.0.1", server.port(), "u", "p", []string{certDir})
+	sender.tlsMode = smtpTLSStartTLS
+	if err := sender.sendTo(sender.recipientAddresses, subjectPhrase, []byte("down\n")); err == nil {
+		t.Error("Expected the certificate not to be valid for 127.0.0.1")
+	}
+	sender.tlsServerName = "mail.test"
+	if err := sender.sendTo(sender.recipientAddresses, subjectPhrase, []byte("down\n")); err != nil {
+		t.Fatal(err)
+	}
+	if mail := server.nextMail(t); mail.auth != "PLAIN \x00u\x00p" {
+		t.Errorf("Expected plain authentication over TLS, got %q", mail.auth)
+	}
+
+	plainServer := newSmtpTestServer(t)
+	defer plainServer.listener.Close()
+	sender = newSmtpWriter("log@b.c", "log", []string{"ops@b.c"}, "127.0.0.1", plainServer.port(), "u", "p", nil)
+	sender.tlsMode = smtpTLSStartTLS
+	if err := sender.sendTo(sender.recipientAddresses, subjectPhrase, []byte("down\n")); err == nil {
+		t.Error("Expected an error from a server without STARTTLS")
+	}
+}
+
+func TestSmtpRecipientLevels(t *testing.T) {
+	server := newSmtpTestServer(t)
+	defer server.listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<smtp senderaddress="log@b.c" sendername="log" hostname="127.0.0.1" hostport="`+
+		server.port()+`" username="u" password="p" digest="true" digestlevels="warn" digestcount="2">
+		<recipient address="oncall@b.c" levels="critical"/>
+		<recipient address="team@b.c" levels="error"/>
+		<recipient address="team@b.c" levels="warn"/>
+		<recipient address="archive@b.c"/>
+	</smtp>`})
+	defer logger.Close()
+	logger.Critical("down")
+	logger.Error("failed")
+	logger.Info("started")
+	logger.Warn("slow")
+	logger.Warn("slower")
+
+	expected := []string{
+		"oncall@b.c,archive@b.c",
+		"team@b.c,archive@b.c",
+		"archive@b.c",
+		"team@b.c,archive@b.c",
+	}
+	for _, recipients := range expected {
+		if mail := server.nextMail(t); strings.Join(mail.recipients, ",") != recipients {
+			t.Errorf("Expected an email to %s, got %v", recipients, mail.recipients)
+		}
+	}
+}
+
+func TestSmtpDigestConfig(t *testing.T) {
+	invalid := []string{
+		`digestcount="10"`,
+		`digest="false" digestsubject="%Count"`,
+		`digest="maybe"`,
+		`digest="true" digestlevels="loud"`,
+		`digest="true" digestcount="-1"`,
+		`digest="true" digestinterval="soon"`,
+		`dedup="-1m"`,
+		`limit="many"`,
+		`context="-1"`,
+		`minlevel="loud"`,
+		`htmltemplate="/nonexistent/alert.html"`,
+		`tls="sometimes"`,
+		`tlsskipverify="maybe"`,
+		`auth="login"`,
+		`auth="xoauth2"`,
+	}
+	for _, digest := range invalid {
+		config := `<seelog><outputs>` + smtpTestConfig("25", digest) + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+
+	config := `<seelog><outputs><smtp senderaddress="log@b.c" sendername="log" hostname="h" hostport="25" username="u"
+		password="p"><recipient address="a@b.c" levels="loud"/></smtp></outputs></seelog>`
+	if _, err := LoggerFromConfigAsString(config); err == nil {
+		t.Errorf("Expected error for config: %s", config)
+	}
+}
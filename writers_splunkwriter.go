@@ -0,0 +1,260 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSplunkSourceType   = "_json"
+	defaultSplunkAckTimeout   = 30 * time.Second
+	defaultSplunkAckInterval  = 500 * time.Millisecond
+	splunkEventPath           = "/services/collector/event"
+	splunkAckPath             = "/services/collector/ack"
+	splunkResponseBodyMaxSize = 64 << 10
+)
+
+// splunkWriter sends messages to a Splunk HTTP Event Collector. Every message is an
+// event at its call time, whose data is an object with the formatted message, the level
+// and the fields of the message; index, source, sourcetype and host are those of the
+// settings.
+//
+// Events are sent by an httpWriter, in batches of concatenated events with its retries
+// and buffering. With ack, the collector must have indexer acknowledgement enabled: the
+// requests are sent on a channel, and a batch counts as sent only once the collector
+// acknowledges it, which is polled every ackInterval. A batch which is not acknowledged
+// within ackTimeout is sent again, like a failed request, so it may be indexed twice.
+type splunkWriter struct {
+	formatter   *formatter
+	index       string
+	source      string
+	sourceType  string
+	host        string
+	ackURL      string
+	headers     http.Header
+	ackTimeout  time.Duration
+	ackInterval time.Duration
+	client      *http.Client
+	http        *httpWriter
+}
+
+// splunkWriterOptions are the settings of a splunk receiver. Zero values are replaced
+// with the defaults.
+type splunkWriterOptions struct {
+	index       string
+	source      string
+	sourceType  string
+	host        string
+	ack         bool
+	channel     string
+	ackTimeout  time.Duration
+	ackInterval time.Duration
+}
+
+func newSplunkWriter(formatter *formatter, endpoint, token string, options splunkWriterOptions,
+	httpOptions httpWriterOptions) (*splunkWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if token == "" {
+		return nil, errors.New("Splunk HEC token can not be empty")
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if options.sourceType == "" {
+		options.sourceType = defaultSplunkSourceType
+	}
+	if options.host == "" {
+		options.host, _ = os.Hostname()
+	}
+	if options.ackTimeout == 0 {
+		options.ackTimeout = defaultSplunkAckTimeout
+	}
+	if options.ackInterval == 0 {
+		options.ackInterval = defaultSplunkAckInterval
+	}
+	if options.ackTimeout < 0 || options.ackInterval < 0 {
+		return nil, errors.New("Splunk acknowledgement durations can not be negative")
+	}
+
+	writer := &splunkWriter{
+		formatter:   formatter,
+		index:       options.index,
+		source:      options.source,
+		sourceType:  options.sourceType,
+		host:        options.host,
+		ackTimeout:  options.ackTimeout,
+		ackInterval: options.ackInterval,
+	}
+
+	path := strings.TrimRight(endpointURL.Path, "/")
+	path = strings.TrimSuffix(path, splunkEventPath)
+	ackURL := *endpointURL
+	ackURL.Path = path + splunkAckPath
+	endpointURL.Path = path + splunkEventPath
+
+	httpOptions.batch = httpBatchNDJSON
+	httpOptions.contentType = "application/json"
+	if httpOptions.headers == nil {
+		httpOptions.headers = make(http.Header)
+	}
+	httpOptions.headers.Set("Authorization", "Splunk "+token)
+	if options.ack {
+		if options.channel == "" {
+			options.channel = newUUIDv7()
+		}
+		httpOptions.headers.Set("X-Splunk-Request-Channel", options.channel)
+		httpOptions.confirm = writer.confirm
+		writer.ackURL = ackURL.String()
+		writer.headers = httpOptions.headers
+		writer.client = &http.Client{Timeout: httpOptions.timeout}
+		if writer.client.Timeout == 0 {
+			writer.client.Timeout = defaultHttpTimeout
+		}
+	}
+	writer.http, err = newHttpWriter(endpointURL.String(), httpOptions)
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (writer *splunkWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	event := new(bytes.Buffer)
+	callTime := context.CallTime()
+	event.WriteString(fmt.Sprintf(`{"time":%d.%06d`, callTime.Unix(), callTime.Nanosecond()/1e3))
+	for _, metadata := range []struct{ key, value string }{
+		{"host", writer.host},
+		{"index", writer.index},
+		{"source", writer.source},
+		{"sourcetype", writer.sourceType},
+	} {
+		if metadata.value != "" {
+			event.WriteString(`,"` + metadata.key + `":`)
+			writeJsonString(event, metadata.value)
+		}
+	}
+	event.WriteString(`,"event":{"message":`)
+	writeJsonString(event, strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n"))
+	event.WriteString(`,"level":`)
+	writeJsonString(event, level.String())
+	writeJsonFields(event, context.Fields(), true)
+	event.WriteString("}}")
+
+	if _, err := writer.http.Write(event.Bytes()); err != nil {
+		errorFunc(err)
+	}
+}
+
+// confirm waits until the collector acknowledges the request of a response.
+func (writer *splunkWriter) confirm(response *http.Response) (bool, error) {
+	var result struct {
+		AckID *int64 `json:"ackId"`
+	}
+	err := json.NewDecoder(io.LimitReader(response.Body, splunkResponseBodyMaxSize)).Decode(&result)
+	if err != nil || result.AckID == nil {
+		return false, errors.New("Splunk HEC response has no ackId; is indexer acknowledgement enabled?")
+	}
+	ackID := strconv.FormatInt(*result.AckID, 10)
+
+	deadline := time.Now().Add(writer.ackTimeout)
+	for {
+		acked, err := writer.acknowledged(ackID)
+		if err != nil {
+			return true, err
+		}
+		if acked {
+			return false, nil
+		}
+		if time.Now().Add(writer.ackInterval).After(deadline) {
+			return true, fmt.Errorf("Splunk HEC did not acknowledge request %s within %s", ackID, writer.ackTimeout)
+		}
+		time.Sleep(writer.ackInterval)
+	}
+}
+
+// acknowledged asks the collector whether a request is acknowledged.
+func (writer *splunkWriter) acknowledged(ackID string) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, writer.ackURL, strings.NewReader(`{"acks":[`+ackID+`]}`))
+	if err != nil {
+		return false, err
+	}
+	for name, values := range writer.headers {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := writer.client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, splunkResponseBodyMaxSize))
+	if response.StatusCode/100 != 2 {
+		return false, fmt.Errorf("Splunk HEC ack returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Acks map[string]bool `json:"acks"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false, err
+	}
+	return result.Acks[ackID], nil
+}
+
+func (writer *splunkWriter) Flush() {
+	writer.http.Flush()
+}
+
+func (writer *splunkWriter) Close() error {
+	return writer.http.Close()
+}
+
+func (writer *splunkWriter) String() string {
+	return fmt.Sprintf("splunkWriter: [%s, %s, %s], format: %s\n", writer.index, writer.sourceType, writer.http, writer.formatter)
+}
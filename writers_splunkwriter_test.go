@@ -0,0 +1,176 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// splunkTestCollector is an HTTP Event Collector with indexer acknowledgement, which
+// acknowledges a request at the given poll.
+type splunkTestCollector struct {
+	*httptest.Server
+	ackAt int
+
+	mutex    sync.Mutex
+	events   []string
+	channels []string
+	polls    int
+}
+
+func newSplunkTestCollector(ackAt int) *splunkTestCollector {
+	collector := &splunkTestCollector{ackAt: ackAt}
+	collector.Server = httptest.NewServer(http.HandlerFunc(collector.serve))
+	return collector
+}
+
+func (collector *splunkTestCollector) serve(w http.ResponseWriter, r *http.Request) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	if r.Header.Get("Authorization") != "Splunk abc" {
+		http.Error(w, `{"text":"Invalid token","code":4}`, http.StatusForbidden)
+		return
+	}
+	body, _ := readHttpTestBody(r)
+	collector.channels = append(collector.channels, r.Header.Get("X-Splunk-Request-Channel"))
+	switch r.URL.Path {
+	case splunkEventPath:
+		collector.events = append(collector.events, string(body))
+		w.Write([]byte(`{"text":"Success","code":0,"ackId":7}`))
+	case splunkAckPath:
+		collector.polls++
+		acked := collector.polls >= collector.ackAt
+		if string(body) != `{"acks":[7]}` {
+			http.Error(w, "unexpected acks "+string(body), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]map[string]bool{"acks": {"7": acked}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSplunkEvents(t *testing.T) {
+	collector := newSplunkTestCollector(1)
+	defer collector.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<splunk formatid="msg" url="`+collector.URL+`" token="abc" index="app"
+		source="billing" host="web-1" maxcount="2"/>`})
+	logger.Infow("paid", Int("amount", 10))
+	logger.Error("failed")
+	logger.Close()
+
+	if len(collector.events) != 1 || collector.polls != 0 || collector.channels[0] != "" {
+		t.Fatalf("Expected one request without acknowledgement, got %q", collector.events)
+	}
+	lines := strings.Split(strings.TrimSpace(collector.events[0]), "\n")
+	var event struct {
+		Time       float64                `json:"time"`
+		Host       string                 `json:"host"`
+		Index      string                 `json:"index"`
+		Source     string                 `json:"source"`
+		SourceType string                 `json:"sourcetype"`
+		Event      map[string]interface{} `json:"event"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatalf("Expected a JSON event, got %s: %s", lines[0], err)
+	}
+	if event.Time == 0 || event.Host != "web-1" || event.Index != "app" || event.Source != "billing" ||
+		event.SourceType != "_json" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if event.Event["message"] != "paid" || event.Event["level"] != "info" || event.Event["amount"] != float64(10) {
+		t.Errorf("Unexpected event data: %v", event.Event)
+	}
+	if len(lines) != 2 || !strings.Contains(lines[1], `"message":"failed"`) {
+		t.Errorf("Unexpected events: %q", lines)
+	}
+}
+
+func TestSplunkAck(t *testing.T) {
+	collector := newSplunkTestCollector(2)
+	defer collector.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<splunk formatid="msg" url="`+collector.URL+`/services/collector/event" token="abc"
+		ack="true" channel="0b9a3a72-7d0a-4d4c-9d5e-7f1d9f6b0a11" maxcount="1"/>`})
+	logger.Info("paid")
+	logger.Close()
+
+	if len(collector.events) != 1 || collector.polls != 2 {
+		t.Fatalf("Expected one request acknowledged at the second poll, got %d requests and %d polls",
+			len(collector.events), collector.polls)
+	}
+	for _, channel := range collector.channels {
+		if channel != "0b9a3a72-7d0a-4d4c-9d5e-7f1d9f6b0a11" {
+			t.Errorf("Unexpected channel: %q", channel)
+		}
+	}
+}
+
+func TestSplunkAckTimeout(t *testing.T) {
+	collector := newSplunkTestCollector(1000)
+	defer collector.Close()
+
+	writer, err := newSplunkWriter(predefinedFormats[predefinedPrefix+"fast"], collector.URL, "abc",
+		splunkWriterOptions{ack: true, ackTimeout: 30 * time.Millisecond, ackInterval: 10 * time.Millisecond},
+		httpWriterOptions{retries: 1, backoff: time.Millisecond, maxCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.http.Write([]byte(`{"event":"x"}`)); err == nil || !strings.Contains(err.Error(), "did not acknowledge") {
+		t.Errorf("Expected an acknowledgement timeout, got %v", err)
+	}
+	if len(collector.events) != 2 {
+		t.Errorf("Expected the request to be sent again, got %d requests", len(collector.events))
+	}
+	if collector.channels[0] == "" {
+		t.Errorf("Expected a generated channel")
+	}
+}
+
+func TestSplunkConfig(t *testing.T) {
+	invalid := []string{
+		`<splunk token="abc"/>`,
+		`<splunk url="https://splunk.local:8088"/>`,
+		`<splunk url="splunk.local:8088" token="abc"/>`,
+		`<splunk url="https://splunk.local:8088" token="abc" ack="maybe"/>`,
+		`<splunk url="https://splunk.local:8088" token="abc" acktimeout="soon"/>`,
+		`<splunk url="https://splunk.local:8088" token="abc" batch="array"/>`,
+		`<splunk url="https://splunk.local:8088" token="abc"><header name="a" value="b"/></splunk>`,
+	}
+	for _, splunk := range invalid {
+		config := `<seelog><outputs>` + splunk + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
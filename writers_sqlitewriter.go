@@ -0,0 +1,303 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSqliteDriver      = "sqlite3"
+	defaultSqliteTable       = "logs"
+	defaultSqliteMaxCount    = 100
+	defaultSqliteMaxInterval = time.Second
+	sqliteTimeLayout         = "2006-01-02T15:04:05.000000Z"
+	sqlitePruneTarget        = 0.9
+)
+
+var sqlIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqliteWriter inserts messages into a table of a local SQLite database, with the
+// columns id, timestamp (UTC, as "2006-01-02T15:04:05.000000Z", which the SQLite date
+// functions understand), level, source (file:line of the call), message (the formatted
+// message) and fields (a JSON object). The table and an index on timestamp are created
+// if they do not exist.
+//
+// The writer uses database/sql with the driver registered under driver, so the
+// application imports one, e.g. github.com/mattn/go-sqlite3 ("sqlite3") or
+// modernc.org/sqlite ("sqlite"). Messages are inserted in one transaction per batch,
+// which is written when it reaches maxCount messages, maxInterval after its first
+// message, on Flush and on Close. After every batch the oldest rows are deleted beyond
+// maxRows rows and, when the database takes more than maxSize bytes, until it takes
+// about nine tenths of it; zero means no limit. Freed pages are reused, so the file
+// stops growing but does not shrink.
+type sqliteWriter struct {
+	formatter   *formatter
+	db          *sql.DB
+	driver      string
+	path        string
+	table       string
+	maxCount    int
+	maxInterval time.Duration
+	maxRows     int64
+	maxSize     int64
+
+	mutex   sync.Mutex
+	batch   []sqliteRow
+	started time.Time
+	timer   *time.Timer
+	closed  bool
+}
+
+type sqliteRow struct {
+	timestamp, level, source, message, fields string
+}
+
+func newSqliteWriter(formatter *formatter, driver, path, table string, maxCount int, maxInterval time.Duration,
+	maxRows, maxSize int64) (*sqliteWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if path == "" {
+		return nil, errors.New("SQLite database path can not be empty")
+	}
+	if driver == "" {
+		driver = defaultSqliteDriver
+	}
+	if table == "" {
+		table = defaultSqliteTable
+	}
+	if !sqlIdentifierRegexp.MatchString(table) {
+		return nil, errors.New("Invalid SQLite table name: " + table)
+	}
+	if maxCount <= 0 {
+		return nil, fmt.Errorf("maxCount can not be less or equal to 0. Got: %d", maxCount)
+	}
+	if maxInterval <= 0 {
+		return nil, fmt.Errorf("maxInterval can not be less or equal to 0. Got: %s", maxInterval)
+	}
+	if maxRows < 0 || maxSize < 0 {
+		return nil, errors.New("maxRows and maxSize can not be negative")
+	}
+
+	db, err := sql.Open(driver, path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open SQLite database %s (is a driver for '%s' imported?): %s", path, driver, err)
+	}
+	// SQLite has a single writer anyway
+	db.SetMaxOpenConns(1)
+	for _, statement := range []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (id INTEGER PRIMARY KEY AUTOINCREMENT, timestamp TEXT NOT NULL, " +
+			"level TEXT NOT NULL, source TEXT NOT NULL, message TEXT NOT NULL, fields TEXT NOT NULL)",
+		"CREATE INDEX IF NOT EXISTS " + table + "_timestamp ON " + table + " (timestamp)",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("Cannot create the log table in %s: %s", path, err)
+		}
+	}
+
+	return &sqliteWriter{
+		formatter:   formatter,
+		db:          db,
+		driver:      driver,
+		path:        path,
+		table:       table,
+		maxCount:    maxCount,
+		maxInterval: maxInterval,
+		maxRows:     maxRows,
+		maxSize:     maxSize,
+	}, nil
+}
+
+func (writer *sqliteWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	row := sqliteRow{
+		timestamp: context.CallTime().UTC().Format(sqliteTimeLayout),
+		level:     level.String(),
+		message:   strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n"),
+	}
+	if context.IsValid() {
+		row.source = fmt.Sprintf("%s:%d", context.ShortPath(), context.Line())
+	}
+	fields := new(bytes.Buffer)
+	fields.WriteByte('{')
+	writeJsonFields(fields, context.Fields(), false)
+	fields.WriteByte('}')
+	row.fields = fields.String()
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("SQLite writer is closed"))
+		return
+	}
+
+	if len(writer.batch) == 0 {
+		writer.started = time.Now()
+		writer.timer = time.AfterFunc(writer.maxInterval, writer.insertOnTimer)
+	}
+	writer.batch = append(writer.batch, row)
+	if len(writer.batch) >= writer.maxCount {
+		if err := writer.insertBatch(); err != nil {
+			errorFunc(err)
+		}
+	}
+}
+
+func (writer *sqliteWriter) insertOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if len(writer.batch) > 0 && time.Since(writer.started) >= writer.maxInterval {
+		if err := writer.insertBatch(); err != nil {
+			reportInternalError(err)
+		}
+	}
+}
+
+// insertBatch inserts the collected messages in a transaction, prunes the table and
+// starts a new batch. The messages are dropped if the transaction fails.
+func (writer *sqliteWriter) insertBatch() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	if len(writer.batch) == 0 {
+		return nil
+	}
+	batch := writer.batch
+	writer.batch = nil
+
+	if err := writer.insert(batch); err != nil {
+		return fmt.Errorf("Cannot insert %d messages into %s: %s", len(batch), writer.path, err)
+	}
+	if err := writer.prune(); err != nil {
+		return fmt.Errorf("Cannot prune %s: %s", writer.path, err)
+	}
+	return nil
+}
+
+func (writer *sqliteWriter) insert(batch []sqliteRow) error {
+	tx, err := writer.db.Begin()
+	if err != nil {
+		return err
+	}
+	statement, err := tx.Prepare("INSERT INTO " + writer.table +
+		" (timestamp, level, source, message, fields) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, row := range batch {
+		if _, err := statement.Exec(row.timestamp, row.level, row.source, row.message, row.fields); err != nil {
+			statement.Close()
+			tx.Rollback()
+			return err
+		}
+	}
+	statement.Close()
+	return tx.Commit()
+}
+
+// prune deletes the oldest rows beyond maxRows rows and maxSize bytes.
+func (writer *sqliteWriter) prune() error {
+	if writer.maxRows > 0 {
+		_, err := writer.db.Exec("DELETE FROM "+writer.table+" WHERE id <= (SELECT MAX(id) FROM "+writer.table+") - ?",
+			writer.maxRows)
+		if err != nil {
+			return err
+		}
+	}
+	if writer.maxSize == 0 {
+		return nil
+	}
+
+	var pageCount, freePages, pageSize int64
+	for pragma, value := range map[string]*int64{
+		"page_count": &pageCount, "freelist_count": &freePages, "page_size": &pageSize} {
+		if err := writer.db.QueryRow("PRAGMA " + pragma).Scan(value); err != nil {
+			return err
+		}
+	}
+	used := (pageCount - freePages) * pageSize
+	if used <= writer.maxSize {
+		return nil
+	}
+	var rows int64
+	if err := writer.db.QueryRow("SELECT COUNT(*) FROM " + writer.table).Scan(&rows); err != nil {
+		return err
+	}
+	// Rows are assumed to be of about the same size
+	excess := float64(used) - float64(writer.maxSize)*sqlitePruneTarget
+	_, err := writer.db.Exec("DELETE FROM "+writer.table+" WHERE id IN (SELECT id FROM "+writer.table+
+		" ORDER BY id LIMIT ?)", int64(float64(rows)*excess/float64(used))+1)
+	return err
+}
+
+func (writer *sqliteWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.insertBatch(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *sqliteWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	err := writer.insertBatch()
+	if closeErr := writer.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (writer *sqliteWriter) String() string {
+	return fmt.Sprintf("sqliteWriter: [%s, %s, %s, %d, %s, maxRows: %d, maxSize: %d], format: %s\n",
+		writer.driver, writer.path, writer.table, writer.maxCount, writer.maxInterval, writer.maxRows,
+		writer.maxSize, writer.formatter)
+}
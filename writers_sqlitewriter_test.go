@@ -0,0 +1,256 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sqliteTestDriver is a database/sql driver which understands just the statements of
+// sqliteWriter. Every row takes a page of 100 bytes.
+type sqliteTestDriver struct {
+	mutex     sync.Mutex
+	databases map[string]*sqliteTestDatabase
+}
+
+type sqliteTestDatabase struct {
+	rows    [][]driver.Value // id, timestamp, level, source, message, fields
+	nextID  int64
+	commits int
+	fail    bool
+}
+
+type sqliteTestConn struct {
+	driver   *sqliteTestDriver
+	database *sqliteTestDatabase
+}
+
+type sqliteTestStmt struct {
+	conn  *sqliteTestConn
+	query string
+}
+
+var testSqliteDriver = &sqliteTestDriver{databases: make(map[string]*sqliteTestDatabase)}
+
+func init() {
+	sql.Register("seelogtest", testSqliteDriver)
+}
+
+func (d *sqliteTestDriver) database(name string) *sqliteTestDatabase {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.databases[name] == nil {
+		d.databases[name] = &sqliteTestDatabase{nextID: 1}
+	}
+	return d.databases[name]
+}
+
+// removeOnCleanup removes the database with the name when the test ends, so that the
+// next test using the name starts with an empty database.
+func (d *sqliteTestDriver) removeOnCleanup(t *testing.T, name string) {
+	t.Cleanup(func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		delete(d.databases, name)
+	})
+}
+
+func (d *sqliteTestDriver) Open(name string) (driver.Conn, error) {
+	return &sqliteTestConn{driver: d, database: d.database(name)}, nil
+}
+
+func (c *sqliteTestConn) Prepare(query string) (driver.Stmt, error) {
+	return &sqliteTestStmt{conn: c, query: query}, nil
+}
+
+func (c *sqliteTestConn) Close() error              { return nil }
+func (c *sqliteTestConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *sqliteTestConn) Rollback() error           { return nil }
+
+func (c *sqliteTestConn) Commit() error {
+	c.driver.mutex.Lock()
+	defer c.driver.mutex.Unlock()
+	c.database.commits++
+	return nil
+}
+
+func (s *sqliteTestStmt) Close() error  { return nil }
+func (s *sqliteTestStmt) NumInput() int { return -1 }
+
+func (s *sqliteTestStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.driver.mutex.Lock()
+	defer s.conn.driver.mutex.Unlock()
+	database := s.conn.database
+
+	switch {
+	case strings.HasPrefix(s.query, "CREATE"):
+	case strings.HasPrefix(s.query, "INSERT INTO logs (timestamp, level, source, message, fields)"):
+		if database.fail {
+			return nil, errors.New("disk I/O error")
+		}
+		database.rows = append(database.rows, append([]driver.Value{database.nextID}, args...))
+		database.nextID++
+	case strings.Contains(s.query, "WHERE id <= (SELECT MAX(id)"):
+		var kept [][]driver.Value
+		for _, row := range database.rows {
+			if row[0].(int64) > database.nextID-1-args[0].(int64) {
+				kept = append(kept, row)
+			}
+		}
+		database.rows = kept
+	case strings.Contains(s.query, "ORDER BY id LIMIT ?"):
+		n := int(args[0].(int64))
+		if n > len(database.rows) {
+			n = len(database.rows)
+		}
+		database.rows = database.rows[n:]
+	default:
+		return nil, errors.New("unexpected statement: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *sqliteTestStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.driver.mutex.Lock()
+	defer s.conn.driver.mutex.Unlock()
+
+	switch s.query {
+	case "PRAGMA page_count", "SELECT COUNT(*) FROM logs":
+		return &sqliteTestRows{value: int64(len(s.conn.database.rows))}, nil
+	case "PRAGMA freelist_count":
+		return &sqliteTestRows{value: 0}, nil
+	case "PRAGMA page_size":
+		return &sqliteTestRows{value: 100}, nil
+	}
+	return nil, errors.New("unexpected query: " + s.query)
+}
+
+type sqliteTestRows struct {
+	value int64
+	done  bool
+}
+
+func (r *sqliteTestRows) Columns() []string { return []string{"value"} }
+func (r *sqliteTestRows) Close() error      { return nil }
+
+func (r *sqliteTestRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+func TestSqliteWriter(t *testing.T) {
+	testSqliteDriver.removeOnCleanup(t, "insert.db")
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<sqlite driver="seelogtest" path="insert.db" maxcount="2"/>`})
+	logger.Infow("one", Str("user", "alice"), Int("attempt", 2))
+	logger.Warn("two")
+	logger.Error("three")
+	logger.Close()
+
+	database := testSqliteDriver.database("insert.db")
+	if len(database.rows) != 3 || database.commits != 2 {
+		t.Fatalf("Expected 3 rows in 2 transactions, got %d rows in %d", len(database.rows), database.commits)
+	}
+	first := database.rows[0]
+	if first[2] != "info" || first[4] != "one" || !strings.Contains(first[3].(string), "writers_sqlitewriter_test.go:") {
+		t.Errorf("Unexpected row: %v", first)
+	}
+	if !strings.HasSuffix(first[1].(string), "Z") || len(first[1].(string)) != len(sqliteTimeLayout) {
+		t.Errorf("Unexpected timestamp: %v", first[1])
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(first[5].(string)), &fields); err != nil || fields["user"] != "alice" ||
+		fields["attempt"] != float64(2) {
+		t.Errorf("Unexpected fields: %v, %v", first[5], err)
+	}
+	if database.rows[2][4] != "three" || database.rows[2][5] != "{}" {
+		t.Errorf("Unexpected row: %v", database.rows[2])
+	}
+}
+
+func TestSqlitePruning(t *testing.T) {
+	testSqliteDriver.removeOnCleanup(t, "rows.db")
+	testSqliteDriver.removeOnCleanup(t, "size.db")
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<sqlite driver="seelogtest" path="rows.db" maxcount="1" maxrows="3"/>`})
+	for _, message := range []string{"1", "2", "3", "4", "5"} {
+		logger.Info(message)
+	}
+	logger.Close()
+	if rows := testSqliteDriver.database("rows.db").rows; len(rows) != 3 || rows[0][4] != "3" {
+		t.Errorf("Expected the newest 3 rows, got %v", rows)
+	}
+
+	// 10 rows of 100 bytes go beyond 900 bytes; rows are deleted down to 810 bytes
+	logger = newTestLogger(t, testLoggerConfig{outputs: `<sqlite driver="seelogtest" path="size.db" maxcount="10" maxsize="900"/>`})
+	for i := 0; i < 10; i++ {
+		logger.Info("message")
+	}
+	logger.Close()
+	if rows := testSqliteDriver.database("size.db").rows; len(rows) != 8 || rows[0][0] != int64(3) {
+		t.Errorf("Expected the newest 8 rows, got %d from %v", len(rows), rows[0][0])
+	}
+}
+
+func TestSqliteInsertFailure(t *testing.T) {
+	testSqliteDriver.removeOnCleanup(t, "failing.db")
+	testSqliteDriver.database("failing.db").fail = true
+	formatter, _ := newFormatter("%Msg")
+	writer, err := newSqliteWriter(formatter, "seelogtest", "failing.db", "", 10, defaultSqliteMaxInterval, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Dispatch("lost", InfoLvl, &logContext{callTime: time.Now()}, func(err error) { t.Error(err) })
+	if err := writer.Close(); err == nil || !strings.Contains(err.Error(), "Cannot insert 1 messages") {
+		t.Errorf("Expected an insert error, got %v", err)
+	}
+}
+
+func TestSqliteConfig(t *testing.T) {
+	invalid := []string{
+		`<sqlite driver="seelogtest"/>`,
+		`<sqlite path="logs.db" driver="unknown"/>`,
+		`<sqlite path="logs.db" driver="seelogtest" table="logs; DROP TABLE users"/>`,
+		`<sqlite path="logs.db" driver="seelogtest" maxcount="0"/>`,
+		`<sqlite path="logs.db" driver="seelogtest" maxrows="-1"/>`,
+		`<sqlite path="logs.db" driver="seelogtest" maxinterval="soon"/>`,
+	}
+	for _, sqlite := range invalid {
+		config := `<seelog><outputs>` + sqlite + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStatsdPrefix   = "log"
+	defaultStatsdInterval = time.Second
+	defaultStatsdMaxSize  = 1432 // The payload of one ethernet frame over IPv6
+)
+
+// statsdWriter turns messages into statsd metrics instead of forwarding their text:
+// every message increments the counter prefix.level.<level>, and the numeric values of
+// the timer fields (durations in milliseconds, other numbers as they are) are sent as
+// timings prefix.<field>. Counters are summed and sent with the timings every interval,
+// on Flush and on Close, in datagrams of at most maxSize bytes. tags, if set, are
+// appended to every metric in the DogStatsD format (|#env:prod,team:payments).
+//
+// Like the udp receiver it is fire-and-forget: metrics which cannot be sent are dropped
+// and reported.
+type statsdWriter struct {
+	addr     string
+	prefix   string
+	tags     string
+	timers   []string
+	interval time.Duration
+	maxSize  int
+
+	mutex    sync.Mutex
+	conn     net.Conn
+	counters map[string]int64
+	timings  []string
+	timer    *time.Timer
+	closed   bool
+}
+
+// statsdWriterOptions are the optional settings of a statsd receiver. Zero values are
+// replaced with the defaults.
+type statsdWriterOptions struct {
+	prefix   string
+	tags     string
+	timers   []string
+	interval time.Duration
+	maxSize  int
+}
+
+func newStatsdWriter(addr string, options statsdWriterOptions) (*statsdWriter, error) {
+	if addr == "" {
+		return nil, errors.New("Statsd address can not be empty")
+	}
+	if options.prefix == "" {
+		options.prefix = defaultStatsdPrefix
+	}
+	if options.interval == 0 {
+		options.interval = defaultStatsdInterval
+	}
+	if options.maxSize == 0 {
+		options.maxSize = defaultStatsdMaxSize
+	}
+	if options.interval < 0 {
+		return nil, errors.New("Statsd interval can not be negative")
+	}
+	if options.maxSize < 0 || options.maxSize > udpMaxDatagram {
+		return nil, fmt.Errorf("Statsd max size must be between 1 and %d. Got: %d", udpMaxDatagram, options.maxSize)
+	}
+	if strings.ContainsAny(options.prefix+strings.Join(options.timers, ""), ":|@#\n") ||
+		strings.ContainsAny(options.tags, "|@#\n") {
+		return nil, errors.New("Statsd prefix, tags and timers can not contain '|', '@', '#' or newlines, " +
+			"nor prefix and timers ':'")
+	}
+	tags := ""
+	if options.tags != "" {
+		tags = "|#" + options.tags
+	}
+
+	return &statsdWriter{
+		addr:     addr,
+		prefix:   strings.TrimSuffix(options.prefix, "."),
+		tags:     tags,
+		timers:   options.timers,
+		interval: options.interval,
+		maxSize:  options.maxSize,
+		counters: make(map[string]int64),
+	}, nil
+}
+
+func (writer *statsdWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	var timings []string
+	if len(writer.timers) > 0 {
+		fields := context.Fields()
+		for _, key := range writer.timers {
+			value, ok := findField(fields, key)
+			if !ok {
+				continue
+			}
+			if milliseconds, isNumber := statsdMilliseconds(value); isNumber {
+				timings = append(timings, writer.prefix+"."+key+":"+
+					strconv.FormatFloat(milliseconds, 'f', -1, 64)+"|ms"+writer.tags)
+			}
+		}
+	}
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("Statsd writer is closed"))
+		return
+	}
+	writer.counters[writer.prefix+".level."+level.String()]++
+	writer.timings = append(writer.timings, timings...)
+	if writer.timer == nil {
+		writer.timer = time.AfterFunc(writer.interval, writer.sendOnTimer)
+	}
+}
+
+// statsdMilliseconds returns the timing of a field value: durations in milliseconds and
+// numbers, or numeric strings, as they are.
+func statsdMilliseconds(value interface{}) (float64, bool) {
+	if duration, isDuration := value.(time.Duration); isDuration {
+		return float64(duration) / float64(time.Millisecond), true
+	}
+	return sloNumber(value)
+}
+
+func (writer *statsdWriter) sendOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.timer = nil
+	if err := writer.send(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+// send sends the counters and timings collected since the previous send.
+func (writer *statsdWriter) send() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	if len(writer.counters) == 0 && len(writer.timings) == 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(writer.counters)+len(writer.timings))
+	for name, count := range writer.counters {
+		lines = append(lines, name+":"+strconv.FormatInt(count, 10)+"|c"+writer.tags)
+	}
+	sort.Strings(lines)
+	lines = append(lines, writer.timings...)
+	writer.counters = make(map[string]int64)
+	writer.timings = nil
+
+	if writer.conn == nil {
+		conn, err := net.Dial("udp", writer.addr)
+		if err != nil {
+			return fmt.Errorf("Cannot send %d statsd metrics: %s", len(lines), err)
+		}
+		writer.conn = conn
+	}
+	var datagram bytes.Buffer
+	for i, line := range lines {
+		if datagram.Len() > 0 && datagram.Len()+1+len(line) > writer.maxSize {
+			if err := writer.write(datagram.Bytes()); err != nil {
+				return fmt.Errorf("Cannot send %d statsd metrics: %s", len(lines)-i, err)
+			}
+			datagram.Reset()
+		}
+		if datagram.Len() > 0 {
+			datagram.WriteByte('\n')
+		}
+		datagram.WriteString(line)
+	}
+	if err := writer.write(datagram.Bytes()); err != nil {
+		return fmt.Errorf("Cannot send statsd metrics: %s", err)
+	}
+	return nil
+}
+
+func (writer *statsdWriter) write(datagram []byte) error {
+	if _, err := writer.conn.Write(datagram); err != nil {
+		// The address is resolved again on the next send
+		writer.conn.Close()
+		writer.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (writer *statsdWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.send(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *statsdWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	err := writer.send()
+	if writer.conn != nil {
+		writer.conn.Close()
+		writer.conn = nil
+	}
+	return err
+}
+
+func (writer *statsdWriter) String() string {
+	return fmt.Sprintf("statsdWriter: [%s, %s, %v, %s]", writer.addr, writer.prefix, writer.timers, writer.interval)
+}
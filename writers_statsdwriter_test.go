@@ -0,0 +1,97 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdMetrics(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<statsd addr="`+server.LocalAddr().String()+`" prefix="app.log."
+		timers="duration,db.latency" tags="env:prod" interval="1h" maxsize="80"/>`})
+	logger.Infow("paid", Any("duration", 1500*time.Microsecond), Str("other", "x"))
+	logger.Errorw("failed", Group("db", Float64("latency", 12.5)))
+	logger.Error("failed again")
+	logger.Warnw("slow", Str("duration", "not a number"))
+	logger.Close()
+
+	datagrams := readUdpTestDatagrams(t, server)
+	expected := []string{
+		"app.log.level.error:2|c|#env:prod\napp.log.level.info:1|c|#env:prod",
+		"app.log.level.warn:1|c|#env:prod\napp.log.duration:1.5|ms|#env:prod",
+		"app.log.db.latency:12.5|ms|#env:prod",
+	}
+	if strings.Join(datagrams, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, datagrams)
+	}
+}
+
+func TestStatsdInterval(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	writer, err := newStatsdWriter(server.LocalAddr().String(), statsdWriterOptions{interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	context, _ := currentContext()
+	writer.Dispatch("first", InfoLvl, context, func(err error) { t.Error(err) })
+	writer.Dispatch("second", InfoLvl, context, func(err error) { t.Error(err) })
+
+	datagrams := readUdpTestDatagrams(t, server)
+	if len(datagrams) != 1 || datagrams[0] != "log.level.info:2|c" {
+		t.Errorf("Expected the counter after the interval, got %q", datagrams)
+	}
+}
+
+func TestStatsdConfig(t *testing.T) {
+	invalid := []string{
+		`<statsd/>`,
+		`<statsd addr="127.0.0.1:8125" maxsize="70000"/>`,
+		`<statsd addr="127.0.0.1:8125" interval="soon"/>`,
+		`<statsd addr="127.0.0.1:8125" prefix="a:b"/>`,
+		`<statsd addr="127.0.0.1:8125" tags="env|prod"/>`,
+		`<statsd addr="127.0.0.1:8125" formatid="msg"/>`,
+	}
+	for _, statsd := range invalid {
+		config := `<seelog><outputs>` + statsd + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
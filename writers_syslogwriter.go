@@ -0,0 +1,297 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog framings
+const (
+	syslogRFC3164 = "3164"
+	syslogRFC5424 = "5424"
+)
+
+const syslogTimeout = 10 * time.Second
+
+// syslogLocalPaths are the sockets of the local syslog daemon on common systems.
+var syslogLocalPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// defaultSyslogSeverities maps the built-in levels to syslog severities.
+var defaultSyslogSeverities = map[LogLevel]int{
+	TraceLvl:    7,
+	DebugLvl:    7,
+	InfoLvl:     6,
+	WarnLvl:     4,
+	ErrorLvl:    3,
+	CriticalLvl: 2,
+}
+
+// syslogWriter sends messages to a syslog daemon, framed as in RFC 3164 or RFC 5424.
+// The formatter produces the MSG part; the header carries the facility, the severity
+// of the level, the time of the call, the host name, the tag and the process id.
+// Over stream transports (tcp, unix) RFC 5424 messages are octet-counted and RFC 3164
+// messages are terminated by a newline (RFC 6587).
+type syslogWriter struct {
+	formatter  *formatter
+	local      bool   // The local daemon is used, over a unix socket found on connect
+	net        string // Network of the connection
+	addr       string
+	rfc        string
+	facility   int
+	severities map[LogLevel]int
+	tag        string
+	hostname   string
+	pid        int
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+func newSyslogWriter(formatter *formatter, netName, addr, rfc string, facility int,
+	severities map[LogLevel]int, tag string) (*syslogWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if netName != "" && addr == "" {
+		return nil, errors.New("Syslog address must be set for network '" + netName + "'")
+	}
+
+	hostname, _ := os.Hostname()
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+	return &syslogWriter{
+		formatter:  formatter,
+		local:      netName == "",
+		net:        netName,
+		addr:       addr,
+		rfc:        rfc,
+		facility:   facility,
+		severities: severities,
+		tag:        tag,
+		hostname:   hostname,
+		pid:        os.Getpid(),
+	}, nil
+}
+
+func (writer *syslogWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	msg := strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n")
+	err := writer.write(level, context.CallTime(), msg)
+	if err != nil {
+		errorFunc(err)
+	}
+}
+
+// syslogSeverity returns the syslog severity of the level. Custom levels without a
+// mapping of their own have the severity of the built-in level they are registered above.
+func syslogSeverity(severities map[LogLevel]int, level LogLevel) int {
+	if severity, ok := severities[level]; ok {
+		return severity
+	}
+	if custom, ok := loadCustomLevels()[level]; ok {
+		return syslogSeverity(severities, custom.above)
+	}
+	return syslogSeverities["notice"]
+}
+
+// frame returns the message with the syslog header and the framing of the transport.
+func (writer *syslogWriter) frame(level LogLevel, t time.Time, msg string) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("<" + strconv.Itoa(writer.facility*8+syslogSeverity(writer.severities, level)) + ">")
+
+	if writer.rfc == syslogRFC3164 {
+		buf.WriteString(t.Format(time.Stamp) + " ")
+		if !writer.local {
+			// Local daemons add the host name themselves
+			buf.WriteString(writer.hostname + " ")
+		}
+		buf.WriteString(writer.tag + "[" + strconv.Itoa(writer.pid) + "]: ")
+	} else {
+		buf.WriteString("1 " + t.Format("2006-01-02T15:04:05.000000Z07:00") + " ")
+		buf.WriteString(syslogHeaderField(writer.hostname, 255) + " ")
+		buf.WriteString(syslogHeaderField(writer.tag, 48) + " ")
+		buf.WriteString(strconv.Itoa(writer.pid) + " - - ")
+	}
+	buf.WriteString(msg)
+
+	if !writer.isStream() {
+		return buf.Bytes()
+	}
+	if writer.rfc == syslogRFC3164 {
+		buf.WriteByte('\n')
+		return buf.Bytes()
+	}
+	return append([]byte(strconv.Itoa(buf.Len())+" "), buf.Bytes()...)
+}
+
+// syslogHeaderField makes a value fit an RFC 5424 header field: printable ASCII without
+// spaces, at most maxLen characters, "-" if empty.
+func syslogHeaderField(value string, maxLen int) string {
+	field := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+	if field == "" {
+		return "-"
+	}
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+	return field
+}
+
+func (writer *syslogWriter) isStream() bool {
+	return writer.net == "tcp" || writer.net == "tcp4" || writer.net == "tcp6" || writer.net == "unix"
+}
+
+// write sends a message, connecting first if needed, as the framing depends on the
+// transport. A failed write is retried once over a new connection, as the daemon may
+// have been restarted.
+func (writer *syslogWriter) write(level LogLevel, t time.Time, msg string) error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if writer.conn == nil {
+			writer.conn, err = writer.connect()
+			if err != nil {
+				return err
+			}
+		}
+
+		writer.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		_, err = writer.conn.Write(writer.frame(level, t, msg))
+		if err == nil {
+			return nil
+		}
+		writer.conn.Close()
+		writer.conn = nil
+	}
+	return err
+}
+
+func (writer *syslogWriter) connect() (net.Conn, error) {
+	if !writer.local {
+		return net.DialTimeout(writer.net, writer.addr, syslogTimeout)
+	}
+
+	paths := syslogLocalPaths
+	if writer.addr != "" {
+		paths = []string{writer.addr}
+	}
+	for _, path := range paths {
+		for _, netName := range []string{"unixgram", "unix"} {
+			conn, err := net.DialTimeout(netName, path, syslogTimeout)
+			if err == nil {
+				writer.net = netName
+				return conn, nil
+			}
+		}
+	}
+	return nil, errors.New("Local syslog daemon not found")
+}
+
+func (writer *syslogWriter) Flush() {
+}
+
+func (writer *syslogWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.conn == nil {
+		return nil
+	}
+	err := writer.conn.Close()
+	writer.conn = nil
+	return err
+}
+
+func (writer *syslogWriter) String() string {
+	return fmt.Sprintf("syslogWriter: [%s, %s, RFC %s, facility %d], format: %s\n",
+		writer.net, writer.addr, writer.rfc, writer.facility, writer.formatter)
+}
+
+// parseSyslogSeverities parses a list of level:severity pairs, e.g. "info:notice,critical:alert",
+// which override the default severities of the levels.
+func parseSyslogSeverities(value string, declared levelSet) (map[LogLevel]int, error) {
+	severities := make(map[LogLevel]int)
+	for level, severity := range defaultSyslogSeverities {
+		severities[level] = severity
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("Syslog severity must be given as level:severity. Got: " + pair)
+		}
+		level, found := declared.fromString(strings.TrimSpace(parts[0]))
+		if !found || level == Off {
+			return nil, errors.New("Declared level not found: " + parts[0])
+		}
+		severity, found := syslogSeverities[strings.TrimSpace(parts[1])]
+		if !found {
+			return nil, errors.New("Unknown syslog severity: " + parts[1])
+		}
+		severities[level] = severity
+	}
+
+	return severities, nil
+}
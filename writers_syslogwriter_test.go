@@ -0,0 +1,170 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogUDP5424(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<syslog net="udp" addr="`+server.LocalAddr().String()+`" facility="local0" tag="my app"/>`})
+	defer logger.Close()
+	logger.Info("hello")
+
+	buf := make([]byte, 1024)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := server.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostname, _ := os.Hostname()
+	expected := regexp.MustCompile(`^<134>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) ` +
+		regexp.QuoteMeta(syslogHeaderField(hostname, 255)) + ` my_app ` + strconv.Itoa(os.Getpid()) + ` - - hello$`)
+	if !expected.Match(buf[:n]) {
+		t.Errorf("Unexpected message: %q", buf[:n])
+	}
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	addr := server.Addr().String()
+
+	logger5424 := newTestLogger(t, testLoggerConfig{outputs: `<syslog net="tcp" addr="`+addr+`" tag="app" severities="info:notice"/>`})
+	defer logger5424.Close()
+	logger3164 := newTestLogger(t, testLoggerConfig{outputs: `<syslog net="tcp" addr="`+addr+`" rfc="3164" tag="app"/>`})
+	defer logger3164.Close()
+
+	logger5424.Info("first")
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	length, err := reader.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(length))
+	if err != nil {
+		t.Fatalf("Expected an octet count. Got: %q", length)
+	}
+	message := make([]byte, size)
+	if _, err := reader.Read(message); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(message), "<13>1 ") || !strings.HasSuffix(string(message), " - - first") {
+		t.Errorf("Unexpected RFC 5424 message: %q", message)
+	}
+
+	logger3164.Error("second")
+	conn3164, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn3164.Close()
+	conn3164.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn3164).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := regexp.MustCompile(`^<11>[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d \S+ app\[` + strconv.Itoa(os.Getpid()) + `\]: second\n$`)
+	if !expected.MatchString(line) {
+		t.Errorf("Unexpected RFC 3164 message: %q", line)
+	}
+}
+
+func TestSyslogLocalSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("No unix sockets")
+	}
+	path := filepath.Join(t.TempDir(), "log")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	t.Cleanup(resetCustomLevels)
+	notice, err := RegisterLevel("syslog_notice", InfoLvl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<syslog addr="`+path+`" rfc="3164" facility="daemon" tag="app"/>`})
+	defer logger.Close()
+	logger.Logf(notice, "custom")
+
+	buf := make([]byte, 1024)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Local daemons add the host name; custom levels have the severity of the level below
+	expected := regexp.MustCompile(`^<30>[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d app\[\d+\]: custom$`)
+	if !expected.Match(buf[:n]) {
+		t.Errorf("Unexpected message: %q", buf[:n])
+	}
+}
+
+func TestSyslogConfig(t *testing.T) {
+	invalid := []string{
+		`<syslog net="http" addr="localhost:514"/>`,
+		`<syslog net="udp"/>`,
+		`<syslog rfc="5425"/>`,
+		`<syslog facility="local9"/>`,
+		`<syslog severities="info"/>`,
+		`<syslog severities="info:loud"/>`,
+		`<syslog><console/></syslog>`,
+	}
+	for _, syslog := range invalid {
+		config := `<seelog><outputs>` + syslog + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultTelegramAPI       = "https://api.telegram.org"
+	defaultTelegramMinLevel  = WarnLvl
+	defaultTelegramDelay     = 2 * time.Second
+	defaultTelegramLimit     = 20
+	defaultTelegramInterval  = time.Minute
+	defaultTelegramTimeout   = 10 * time.Second
+	telegramMaxMessageLength = 4096
+	telegramMaxLineLength    = telegramMaxMessageLength / 2
+	telegramMoreLength       = 32 // Room for the "… N more" line
+)
+
+// telegramWriter sends messages of minLevel and above to Telegram chats through a bot.
+// Messages are collected for delay after the first one, so a burst arrives as one
+// Telegram message with a line per message; lines are cut at half the length of a
+// Telegram message, and the messages which do not fit are counted in the last line. At most limit Telegram messages are sent per interval (see
+// alertThrottle): while the limit is reached, messages keep being collected and are sent
+// together as soon as it allows. Pending messages are sent on Flush and on Close.
+type telegramWriter struct {
+	formatter *formatter
+	api       string
+	token     string
+	chats     []string
+	minLevel  LogLevel
+	delay     time.Duration
+	throttle  *alertThrottle
+	client    *http.Client
+
+	mutex  sync.Mutex
+	lines  []string
+	length int
+	more   int
+	timer  *time.Timer
+	closed bool
+}
+
+// telegramWriterOptions are the optional settings of a telegram receiver. Zero values
+// other than minLevel are replaced with the defaults; a negative limit disables it.
+type telegramWriterOptions struct {
+	api      string
+	minLevel LogLevel
+	delay    time.Duration
+	limit    int
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func newTelegramWriter(formatter *formatter, token string, chats []string, options telegramWriterOptions) (*telegramWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if token == "" {
+		return nil, errors.New("Telegram bot token can not be empty")
+	}
+	if len(chats) == 0 {
+		return nil, errors.New("Telegram receiver needs at least one chat")
+	}
+	if options.api == "" {
+		options.api = defaultTelegramAPI
+	}
+	if options.delay == 0 {
+		options.delay = defaultTelegramDelay
+	}
+	if options.limit == 0 {
+		options.limit = defaultTelegramLimit
+	}
+	if options.interval == 0 {
+		options.interval = defaultTelegramInterval
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultTelegramTimeout
+	}
+	if options.delay < 0 || options.interval < 0 || options.timeout < 0 {
+		return nil, errors.New("Telegram receiver durations can not be negative")
+	}
+
+	return &telegramWriter{
+		formatter: formatter,
+		api:       strings.TrimRight(options.api, "/"),
+		token:     token,
+		chats:     chats,
+		minLevel:  options.minLevel,
+		delay:     options.delay,
+		throttle:  newAlertThrottle(0, options.limit, options.interval),
+		client:    &http.Client{Timeout: options.timeout},
+	}, nil
+}
+
+func (writer *telegramWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent || level < writer.minLevel {
+		return
+	}
+	line := strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n")
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		errorFunc(errors.New("Telegram writer is closed"))
+		return
+	}
+
+	if writer.timer == nil {
+		writer.timer = time.AfterFunc(writer.delay, writer.sendOnTimer)
+	}
+	if utf8.RuneCountInString(line) > telegramMaxLineLength {
+		line = string([]rune(line)[:telegramMaxLineLength-1]) + "…"
+	}
+	length := utf8.RuneCountInString(line) + 1
+	if writer.length+length > telegramMaxMessageLength-telegramMoreLength {
+		writer.more++
+		return
+	}
+	writer.lines = append(writer.lines, line)
+	writer.length += length
+}
+
+func (writer *telegramWriter) sendOnTimer() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.timer = nil
+	if len(writer.lines) == 0 {
+		return
+	}
+	if wait := writer.throttle.reserve(time.Now()); wait > 0 {
+		writer.timer = time.AfterFunc(wait, writer.sendOnTimer)
+		return
+	}
+	if err := writer.sendLines(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+// sendLines sends the collected lines to every chat as one message, and starts anew.
+func (writer *telegramWriter) sendLines() error {
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+	if len(writer.lines) == 0 {
+		return nil
+	}
+	text := strings.Join(writer.lines, "\n")
+	if writer.more > 0 {
+		text += fmt.Sprintf("\n… %d more", writer.more)
+	}
+	writer.lines = nil
+	writer.length = 0
+	writer.more = 0
+
+	var lastErr error
+	for _, chat := range writer.chats {
+		if err := writer.sendMessage(chat, text); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (writer *telegramWriter) sendMessage(chat, text string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  chat,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := writer.client.Post(writer.api+"/bot"+writer.token+"/sendMessage", "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		// The url contains the token, which errors of the client repeat.
+		return errors.New("Cannot send Telegram message: " + strings.Replace(err.Error(), writer.token, "***", -1))
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var result struct {
+			Description string `json:"description"`
+		}
+		json.NewDecoder(io.LimitReader(response.Body, 4096)).Decode(&result)
+		return fmt.Errorf("Cannot send Telegram message to chat %s: %s: %s", chat, response.Status, result.Description)
+	}
+	io.Copy(ioutil.Discard, response.Body)
+	return nil
+}
+
+func (writer *telegramWriter) Flush() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if err := writer.sendLines(); err != nil {
+		reportInternalError(err)
+	}
+}
+
+func (writer *telegramWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	return writer.sendLines()
+}
+
+func (writer *telegramWriter) String() string {
+	return fmt.Sprintf("telegramWriter: [%s, %s], format: %s\n", strings.Join(writer.chats, ","), writer.minLevel, writer.formatter)
+}
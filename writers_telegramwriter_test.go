@@ -0,0 +1,143 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelegramBatch(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<telegram formatid="msg" api="`+server.URL+`" token="123:abc" chats="42, -100"
+		delay="30ms"/>`})
+	logger.Info("ignored")
+	logger.Warn("first")
+	logger.Error("second")
+	time.Sleep(100 * time.Millisecond)
+	logger.Critical("third")
+	logger.Close()
+
+	requests, bodies := server.sent()
+	if len(bodies) != 4 {
+		t.Fatalf("Expected four messages, got %q", bodies)
+	}
+	expected := []struct{ chat, text string }{
+		{"42", "first\nsecond"},
+		{"-100", "first\nsecond"},
+		{"42", "third"},
+		{"-100", "third"},
+	}
+	for i, message := range expected {
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(bodies[i]), &payload); err != nil {
+			t.Fatalf("Expected a JSON payload, got %s: %s", bodies[i], err)
+		}
+		if payload["chat_id"] != message.chat || payload["text"] != message.text {
+			t.Errorf("Expected %q to %s, got %v", message.text, message.chat, payload)
+		}
+	}
+	if requests[0].URL.Path != "/bot123:abc/sendMessage" {
+		t.Errorf("Unexpected path: %s", requests[0].URL.Path)
+	}
+}
+
+func TestTelegramLimit(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<telegram formatid="msg" api="`+server.URL+`" token="abc" chats="42"
+		delay="10ms" limit="1" interval="200ms"/>`})
+	logger.Error("first")
+	time.Sleep(50 * time.Millisecond)
+	logger.Error("second")
+	time.Sleep(50 * time.Millisecond)
+	logger.Error("third")
+
+	if _, bodies := server.sent(); len(bodies) != 1 {
+		t.Fatalf("Expected one message within the limit, got %q", bodies)
+	}
+	time.Sleep(200 * time.Millisecond)
+	_, bodies := server.sent()
+	if len(bodies) != 2 || !strings.Contains(bodies[1], `"second\nthird"`) {
+		t.Errorf("Expected the held messages together, got %q", bodies)
+	}
+	logger.Close()
+}
+
+func TestTelegramLongBurst(t *testing.T) {
+	server := newHttpTestServer()
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<telegram formatid="msg" api="`+server.URL+`" token="abc" chats="42"/>`})
+	line := strings.Repeat("x", 1000)
+	for i := 0; i < 10; i++ {
+		logger.Error(line)
+	}
+	logger.Error(strings.Repeat("y", 5000))
+	logger.Flush()
+
+	_, bodies := server.sent()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected one message, got %d", len(bodies))
+	}
+	var payload map[string]interface{}
+	json.Unmarshal([]byte(bodies[0]), &payload)
+	text := payload["text"].(string)
+	if strings.Count(text, line) != 4 || !strings.HasSuffix(text, "\n… 7 more") {
+		t.Errorf("Unexpected text: %d characters ending with %q", len(text), text[len(text)-20:])
+	}
+
+	logger.Error(strings.Repeat("y", 5000))
+	logger.Close()
+	_, bodies = server.sent()
+	json.Unmarshal([]byte(bodies[1]), &payload)
+	if text := payload["text"].(string); len([]rune(text)) != telegramMaxLineLength || !strings.HasSuffix(text, "y…") {
+		t.Errorf("Expected a cut line, got %d characters", len([]rune(text)))
+	}
+}
+
+func TestTelegramConfig(t *testing.T) {
+	invalid := []string{
+		`<telegram chats="42"/>`,
+		`<telegram token="abc"/>`,
+		`<telegram token="abc" chats=","/>`,
+		`<telegram token="abc" chats="42" minlevel="loud"/>`,
+		`<telegram token="abc" chats="42" delay="soon"/>`,
+		`<telegram token="abc" chats="42" limit="-1"/>`,
+		`<telegram token="abc" chats="42" url="http://x"/>`,
+		`<telegram token="abc" chats="42"><route levels="error"/></telegram>`,
+	}
+	for _, telegram := range invalid {
+		config := `<seelog><outputs>` + telegram + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultUdpMaxSize = 1472 // The payload of one ethernet frame over IPv4
+	udpMaxDatagram    = 65507
+)
+
+// udpWriter sends every message as one datagram, fire-and-forget. Messages longer than
+// maxSize bytes are truncated at a character boundary. Once more than load messages
+// are written within a second, only a share of sample of the further messages of that
+// second is sent, deterministically as with the sampler: with sample 0.1 every 10th.
+// load 0 turns sampling off.
+type udpWriter struct {
+	addr    string
+	maxSize int
+	load    int
+	sample  float64
+
+	mutex   sync.Mutex
+	conn    net.Conn
+	second  time.Time
+	count   int
+	share   float64
+	skipped uint64
+}
+
+func newUdpWriter(addr string, maxSize, load int, sample float64) (*udpWriter, error) {
+	if addr == "" {
+		return nil, errors.New("UDP address can not be empty")
+	}
+	if maxSize == 0 {
+		maxSize = defaultUdpMaxSize
+	}
+	if maxSize < 0 || maxSize > udpMaxDatagram {
+		return nil, fmt.Errorf("UDP max size must be between 1 and %d. Got: %d", udpMaxDatagram, maxSize)
+	}
+	if load < 0 {
+		return nil, errors.New("UDP load can not be negative")
+	}
+	if sample < 0 || sample > 1 {
+		return nil, fmt.Errorf("UDP sample must be between 0 and 1. Got: %v", sample)
+	}
+
+	return &udpWriter{addr: addr, maxSize: maxSize, load: load, sample: sample}, nil
+}
+
+func (writer *udpWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if !writer.allows(time.Now()) {
+		writer.skipped++
+		return len(data), nil
+	}
+
+	if writer.conn == nil {
+		conn, err := net.Dial("udp", writer.addr)
+		if err != nil {
+			return 0, err
+		}
+		writer.conn = conn
+	}
+
+	datagram := data
+	if len(datagram) > writer.maxSize {
+		size := writer.maxSize
+		for size > 0 && !utf8.RuneStart(datagram[size]) {
+			size--
+		}
+		datagram = datagram[:size]
+	}
+	if _, err := writer.conn.Write(datagram); err != nil {
+		// The address is resolved again on the next message
+		writer.conn.Close()
+		writer.conn = nil
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// allows counts a message and tells whether it is sent.
+func (writer *udpWriter) allows(now time.Time) bool {
+	if writer.load == 0 {
+		return true
+	}
+
+	second := now.Truncate(time.Second)
+	if !second.Equal(writer.second) {
+		writer.second = second
+		writer.count = 0
+		writer.share = 0
+	}
+	writer.count++
+	if writer.count <= writer.load {
+		return true
+	}
+
+	writer.share += writer.sample
+	if writer.share < 1-1e-9 {
+		return false
+	}
+	writer.share -= 1
+	return true
+}
+
+func (writer *udpWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.conn == nil {
+		return nil
+	}
+	err := writer.conn.Close()
+	writer.conn = nil
+	return err
+}
+
+func (writer *udpWriter) String() string {
+	return fmt.Sprintf("udpWriter: [%s, max size %d, load %d, sample %v]",
+		writer.addr, writer.maxSize, writer.load, writer.sample)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readUdpTestDatagrams(t *testing.T, server net.PacketConn) []string {
+	var datagrams []string
+	buf := make([]byte, udpMaxDatagram)
+	for {
+		server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			return datagrams
+		}
+		datagrams = append(datagrams, string(buf[:n]))
+	}
+}
+
+func TestUdpDatagrams(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<udp addr="`+server.LocalAddr().String()+`" maxsize="16"/>`})
+	logger.Info("first")
+	logger.Info("second")
+	logger.Info("long message, truncated")
+	logger.Info("0123456789abcd€")
+	logger.Close()
+
+	datagrams := readUdpTestDatagrams(t, server)
+	expected := []string{"first\n", "second\n", "long message, tr", "0123456789abcd"}
+	if strings.Join(datagrams, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, datagrams)
+	}
+}
+
+func TestUdpSampling(t *testing.T) {
+	writer, err := newUdpWriter("127.0.0.1:9", 0, 3, 0.25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	sent := 0
+	for i := 0; i < 11; i++ {
+		if writer.allows(start.Add(time.Duration(i) * time.Millisecond)) {
+			sent++
+		}
+	}
+	// 3 within the load, and every 4th of the other 8
+	if sent != 5 {
+		t.Errorf("Expected 5 messages to be sent, got %d", sent)
+	}
+	if !writer.allows(start.Add(time.Second)) {
+		t.Error("Expected the count to start over in the next second")
+	}
+
+	unlimited, _ := newUdpWriter("127.0.0.1:9", 0, 0, 0)
+	for i := 0; i < 100; i++ {
+		if !unlimited.allows(start) {
+			t.Fatal("Expected no sampling without load")
+		}
+	}
+}
+
+func TestUdpConfig(t *testing.T) {
+	invalid := []string{
+		`<udp/>`,
+		`<udp addr="collector.local:9999" maxsize="70000"/>`,
+		`<udp addr="collector.local:9999" maxsize="big"/>`,
+		`<udp addr="collector.local:9999" sample="0.1"/>`,
+		`<udp addr="collector.local:9999" load="100" sample="2"/>`,
+		`<udp addr="collector.local:9999" net="tcp"/>`,
+	}
+	for _, udp := range invalid {
+		config := `<seelog><outputs>` + udp + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWebsocketPath   = "/"
+	defaultWebsocketBuffer = 256
+	websocketWriteTimeout  = 10 * time.Second
+	websocketMaxFrame      = 4096 // Of frames from clients, which are ignored but for control frames
+	websocketGUID          = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	websocketOpText        = 0x1
+	websocketOpClose       = 0x8
+	websocketOpPing        = 0x9
+	websocketOpPong        = 0xA
+)
+
+// websocketTailPage is served to browsers which open the path of the receiver. It
+// connects to the same url, so the level filters of the query apply.
+const websocketTailPage = `<!DOCTYPE html>
+<meta charset="utf-8">
+<title>seelog</title>
+<pre id="log"></pre>
+<script>
+var log = document.getElementById("log");
+var socket = new WebSocket(location.href.replace(/^http/, "ws"));
+socket.onmessage = function(event) {
+	log.appendChild(document.createTextNode(event.data));
+	window.scrollTo(0, document.body.scrollHeight);
+};
+socket.onclose = function() {
+	log.appendChild(document.createTextNode("[disconnected]\n"));
+};
+</script>
+`
+
+// websocketWriter runs a WebSocket endpoint at path on addr and sends every formatted
+// message as a text message to the connected clients. Clients filter levels with the
+// minlevel, maxlevel and levels query parameters, as in the config, e.g. with
+// ws://localhost:9090/logs?minlevel=warn. Every client has a buffer of buffer messages.
+// Messages for a client whose buffer is full are dropped, and the client is told how
+// many before the next message.
+type websocketWriter struct {
+	formatter *formatter
+	path      string
+	buffer    int
+	listener  net.Listener
+	server    *http.Server
+
+	mutex   sync.Mutex
+	clients map[*websocketClient]bool
+	closed  bool
+}
+
+type websocketClient struct {
+	conn        net.Conn
+	constraints logLevelConstraints
+	messages    chan string
+	dropped     uint64
+	writeMutex  sync.Mutex
+	closeOnce   sync.Once
+	done        chan struct{}
+}
+
+func newWebsocketWriter(formatter *formatter, addr, path string, buffer int) (*websocketWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	if path == "" {
+		path = defaultWebsocketPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.New("WebSocket path must start with '/': " + path)
+	}
+	if buffer == 0 {
+		buffer = defaultWebsocketBuffer
+	}
+	if buffer < 0 {
+		return nil, errors.New("WebSocket buffer can not be negative")
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := &websocketWriter{
+		formatter: formatter,
+		path:      path,
+		buffer:    buffer,
+		listener:  listener,
+		clients:   make(map[*websocketClient]bool),
+	}
+	writer.server = &http.Server{Handler: writer, ReadHeaderTimeout: websocketWriteTimeout}
+	go writer.server.Serve(listener)
+	return writer, nil
+}
+
+func (writer *websocketWriter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != writer.path {
+		http.NotFound(w, r)
+		return
+	}
+	constraints, err := websocketConstraints(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, websocketTailPage)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" {
+		http.Error(w, "Bad WebSocket handshake", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket is not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, bufrw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	_, err = io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+websocketAccept(key)+"\r\n\r\n")
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	client := &websocketClient{
+		conn:        conn,
+		constraints: constraints,
+		messages:    make(chan string, writer.buffer),
+		done:        make(chan struct{}),
+	}
+	writer.mutex.Lock()
+	if writer.closed {
+		writer.mutex.Unlock()
+		client.close(1001)
+		return
+	}
+	writer.clients[client] = true
+	writer.mutex.Unlock()
+
+	go client.send()
+	client.receive(bufrw.Reader)
+
+	writer.mutex.Lock()
+	delete(writer.clients, client)
+	writer.mutex.Unlock()
+}
+
+func (writer *websocketWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if len(writer.clients) == 0 {
+		return
+	}
+	formatted := writer.formatter.Format(message, level, context)
+	for client := range writer.clients {
+		if !client.constraints.IsAllowed(level) {
+			continue
+		}
+		select {
+		case client.messages <- formatted:
+		default:
+			atomic.AddUint64(&client.dropped, 1)
+		}
+	}
+}
+
+// send writes the messages of the client until it is closed.
+func (client *websocketClient) send() {
+	for {
+		select {
+		case message := <-client.messages:
+			if dropped := atomic.SwapUint64(&client.dropped, 0); dropped > 0 {
+				message = fmt.Sprintf("[%d messages dropped]\n", dropped) + message
+			}
+			if err := client.writeFrame(websocketOpText, []byte(message)); err != nil {
+				client.close(0)
+				return
+			}
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// receive reads the frames of the client, answering pings and close frames, until the
+// connection ends.
+func (client *websocketClient) receive(reader *bufio.Reader) {
+	defer client.close(0)
+
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return
+		}
+		opcode := header[0] & 0x0f
+		masked := header[1]&0x80 != 0
+		size := uint64(header[1] & 0x7f)
+		switch size {
+		case 126:
+			var extended [2]byte
+			if _, err := io.ReadFull(reader, extended[:]); err != nil {
+				return
+			}
+			size = uint64(binary.BigEndian.Uint16(extended[:]))
+		case 127:
+			var extended [8]byte
+			if _, err := io.ReadFull(reader, extended[:]); err != nil {
+				return
+			}
+			size = binary.BigEndian.Uint64(extended[:])
+		}
+		if !masked || size > websocketMaxFrame {
+			// Clients must mask their frames, and only send small ones to this endpoint
+			client.close(1002)
+			return
+		}
+		var mask [4]byte
+		if _, err := io.ReadFull(reader, mask[:]); err != nil {
+			return
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case websocketOpClose:
+			client.close(1000)
+			return
+		case websocketOpPing:
+			if err := client.writeFrame(websocketOpPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeFrame writes an unmasked, unfragmented frame.
+func (client *websocketClient) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch size := len(payload); {
+	case size < 126:
+		frame = append(frame, byte(size))
+	case size <= 0xffff:
+		frame = append(frame, 126, byte(size>>8), byte(size))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(size))
+	}
+	frame = append(frame, payload...)
+
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+	client.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	_, err := client.conn.Write(frame)
+	return err
+}
+
+// close sends a close frame with the status code, unless it is 0, and closes the
+// connection.
+func (client *websocketClient) close(status uint16) {
+	client.closeOnce.Do(func() {
+		if status != 0 {
+			client.writeFrame(websocketOpClose, []byte{byte(status >> 8), byte(status)})
+		}
+		close(client.done)
+		client.conn.Close()
+	})
+}
+
+func (writer *websocketWriter) Flush() {
+}
+
+func (writer *websocketWriter) Close() error {
+	writer.mutex.Lock()
+	writer.closed = true
+	clients := writer.clients
+	writer.clients = make(map[*websocketClient]bool)
+	writer.mutex.Unlock()
+
+	err := writer.server.Close()
+	for client := range clients {
+		client.close(1001)
+	}
+	return err
+}
+
+func (writer *websocketWriter) String() string {
+	return fmt.Sprintf("websocketWriter: [%s%s, buffer %d], format: %s\n",
+		writer.listener.Addr(), writer.path, writer.buffer, writer.formatter)
+}
+
+// websocketAccept returns the Sec-WebSocket-Accept header for a Sec-WebSocket-Key.
+func websocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// websocketConstraints returns the level constraints of the minlevel, maxlevel and
+// levels query parameters of a client.
+func websocketConstraints(query url.Values) (logLevelConstraints, error) {
+	attributes := make(map[string]string)
+	for _, name := range []string{minLevelId, maxLevelId, levelsId} {
+		if value, isSet := query[name]; isSet && len(value) > 0 {
+			attributes[name] = value[0]
+		}
+	}
+	return getConstraints(&xmlNode{name: "query", attributes: attributes})
+}
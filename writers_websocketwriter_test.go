@@ -0,0 +1,215 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newWebsocketTestWriter(t *testing.T, buffer int) *websocketWriter {
+	formatter, _ := newFormatter("%Msg%n")
+	writer, err := newWebsocketWriter(formatter, "127.0.0.1:0", "/logs", buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writer
+}
+
+// dialWebsocketTest connects a client and waits until the writer has clients of it.
+func dialWebsocketTest(t *testing.T, writer *websocketWriter, query string, clients int) (net.Conn, *bufio.Reader) {
+	addr := writer.listener.Addr().String()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(conn, "GET /logs%s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", query, addr)
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The example of RFC 6455
+	if response.StatusCode != http.StatusSwitchingProtocols ||
+		response.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected handshake response: %s %v", response.Status, response.Header)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		writer.mutex.Lock()
+		count := len(writer.clients)
+		writer.mutex.Unlock()
+		if count == clients {
+			break
+		}
+	}
+	return conn, reader
+}
+
+func readWebsocketTestFrame(t *testing.T, conn net.Conn, reader *bufio.Reader) (byte, string) {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		t.Fatal(err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Fatal("Server frames must not be masked")
+	}
+	size := int(header[1])
+	if size == 126 {
+		var extended [2]byte
+		io.ReadFull(reader, extended[:])
+		size = int(binary.BigEndian.Uint16(extended[:]))
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, string(payload)
+}
+
+func writeWebsocketTestFrame(conn net.Conn, opcode byte, payload string) {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	conn.Write(frame)
+}
+
+func TestWebsocketTail(t *testing.T) {
+	writer := newWebsocketTestWriter(t, 0)
+	defer writer.Close()
+
+	all, allReader := dialWebsocketTest(t, writer, "", 1)
+	defer all.Close()
+	alerts, alertsReader := dialWebsocketTest(t, writer, "?minlevel=error", 2)
+	defer alerts.Close()
+
+	context := &logContext{callTime: time.Now()}
+	writer.Dispatch("first", InfoLvl, context, func(err error) { t.Error(err) })
+	writer.Dispatch("second", ErrorLvl, context, func(err error) { t.Error(err) })
+
+	for _, expected := range []string{"first\n", "second\n"} {
+		if opcode, message := readWebsocketTestFrame(t, all, allReader); opcode != websocketOpText || message != expected {
+			t.Errorf("Expected %q, got %d %q", expected, opcode, message)
+		}
+	}
+	if _, message := readWebsocketTestFrame(t, alerts, alertsReader); message != "second\n" {
+		t.Errorf("Expected only the error, got %q", message)
+	}
+
+	writeWebsocketTestFrame(all, websocketOpPing, "ping")
+	if opcode, payload := readWebsocketTestFrame(t, all, allReader); opcode != websocketOpPong || payload != "ping" {
+		t.Errorf("Expected a pong, got %d %q", opcode, payload)
+	}
+	writeWebsocketTestFrame(alerts, websocketOpClose, "\x03\xe8")
+	if opcode, payload := readWebsocketTestFrame(t, alerts, alertsReader); opcode != websocketOpClose || payload != "\x03\xe8" {
+		t.Errorf("Expected a close frame, got %d %q", opcode, payload)
+	}
+
+	writer.Close()
+	if opcode, payload := readWebsocketTestFrame(t, all, allReader); opcode != websocketOpClose || payload != "\x03\xe9" {
+		t.Errorf("Expected a going away close frame, got %d %q", opcode, payload)
+	}
+}
+
+func TestWebsocketDropped(t *testing.T) {
+	writer := newWebsocketTestWriter(t, 1)
+	defer writer.Close()
+
+	server, conn := net.Pipe()
+	defer conn.Close()
+	constraints, _ := newMinMaxConstraints(TraceLvl, CriticalLvl)
+	client := &websocketClient{conn: server, constraints: constraints, messages: make(chan string, 1), done: make(chan struct{})}
+	writer.clients[client] = true
+
+	context := &logContext{callTime: time.Now()}
+	for _, message := range []string{"first", "second", "third"} {
+		writer.Dispatch(message, InfoLvl, context, func(err error) { t.Error(err) })
+	}
+	go client.send()
+	if _, message := readWebsocketTestFrame(t, conn, bufio.NewReader(conn)); message != "[2 messages dropped]\nfirst\n" {
+		t.Errorf("Expected the dropped messages to be reported, got %q", message)
+	}
+}
+
+func TestWebsocketPage(t *testing.T) {
+	writer := newWebsocketTestWriter(t, 0)
+	defer writer.Close()
+
+	base := "http://" + writer.listener.Addr().String()
+	response, err := http.Get(base + "/logs?levels=warn,error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || !strings.Contains(string(page), "new WebSocket") {
+		t.Errorf("Expected the tail page, got %s", response.Status)
+	}
+
+	for path, status := range map[string]int{"/logs?minlevel=loud": http.StatusBadRequest, "/other": http.StatusNotFound} {
+		response, err := http.Get(base + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != status {
+			t.Errorf("Expected %d for %s, got %s", status, path, response.Status)
+		}
+	}
+}
+
+func TestWebsocketConfig(t *testing.T) {
+	logger, err := LoggerFromConfigAsString(`<seelog><outputs><websocket addr="127.0.0.1:0" path="/logs"/></outputs></seelog>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	invalid := []string{
+		`<websocket/>`,
+		`<websocket addr="127.0.0.1:0" path="logs"/>`,
+		`<websocket addr="127.0.0.1:0" buffer="-1"/>`,
+		`<websocket addr="127.0.0.1:0" tls="true"/>`,
+		`<websocket addr="no port"/>`,
+	}
+	for _, websocket := range invalid {
+		config := `<seelog><outputs>` + websocket + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}
@@ -0,0 +1,631 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	zmqPub               = "PUB"
+	zmqPush              = "PUSH"
+	defaultZmqSocketType = zmqPush
+	defaultZmqHwm        = 1000
+	defaultZmqTimeout    = 5 * time.Second
+	zmqReconnectMin      = 100 * time.Millisecond
+	zmqReconnectMax      = 10 * time.Second
+	zmqMaxFrame          = 64 << 20
+
+	zmqFlagMore    = 0x01
+	zmqFlagLong    = 0x02
+	zmqFlagCommand = 0x04
+)
+
+// zmqPeerTypes are the socket types which a PUB or PUSH socket talks to.
+var zmqPeerTypes = map[string][]string{
+	zmqPub:  {"SUB", "XSUB"},
+	zmqPush: {"PULL"},
+}
+
+// zmqWriter is a ZeroMQ PUB or PUSH socket speaking ZMTP 3 with the NULL mechanism
+// itself. It connects to the endpoint, connecting again with backoff when the
+// connection fails, or binds to it and accepts any number of peers.
+//
+// Every message is a ZeroMQ message with the formatted message, preceded by a frame with
+// the topic if the topic format is set. Like a ZeroMQ socket every peer has a queue of
+// hwm messages: PUB sends every message to the peers subscribed to it (matching the
+// topic, or else the message, by prefix) and drops it for peers whose queue is full;
+// PUSH sends every message to one peer, round robin, and waits up to timeout for room in
+// its queue, then drops the message and reports it. A connecting PUSH socket queues
+// messages while it is not connected. On Close queued messages are sent for up to
+// timeout.
+type zmqWriter struct {
+	formatter  *formatter
+	topic      *formatter // nil for single frame messages
+	socketType string
+	addr       string
+	bind       bool
+	hwm        int
+	timeout    time.Duration
+
+	mutex    sync.Mutex
+	listener net.Listener
+	pipes    []*zmqPipe
+	next     int
+	closed   bool
+	done     chan struct{}
+	running  sync.WaitGroup
+	dropped  uint64
+}
+
+// zmqPipe is the queue of messages to a peer. A connecting socket has a single pipe for
+// all its connections.
+type zmqPipe struct {
+	queue chan [][]byte
+
+	mutex         sync.Mutex
+	connected     bool
+	subscriptions map[string]int
+}
+
+// zmqWriterOptions are the optional settings of a zmq receiver. Zero values are replaced
+// with the defaults.
+type zmqWriterOptions struct {
+	socketType string
+	bind       bool
+	topic      string
+	hwm        int
+	timeout    time.Duration
+}
+
+// parseZmqEndpoint returns the address of a tcp:// ZeroMQ endpoint.
+func parseZmqEndpoint(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "tcp" || parsed.Port() == "" {
+		return "", errors.New("ZeroMQ endpoint must be tcp://host:port: " + endpoint)
+	}
+	return parsed.Host, nil
+}
+
+func newZmqWriter(formatter *formatter, endpoint string, options zmqWriterOptions) (*zmqWriter, error) {
+	if formatter == nil {
+		return nil, errors.New("formatter can not be nil")
+	}
+	addr, err := parseZmqEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if options.socketType == "" {
+		options.socketType = defaultZmqSocketType
+	}
+	options.socketType = strings.ToUpper(options.socketType)
+	if _, ok := zmqPeerTypes[options.socketType]; !ok {
+		return nil, errors.New("ZeroMQ socket type must be pub or push: " + options.socketType)
+	}
+	if options.hwm == 0 {
+		options.hwm = defaultZmqHwm
+	}
+	if options.timeout == 0 {
+		options.timeout = defaultZmqTimeout
+	}
+	if options.hwm < 0 || options.timeout < 0 {
+		return nil, errors.New("ZeroMQ receiver settings can not be negative")
+	}
+	writer := &zmqWriter{
+		formatter:  formatter,
+		socketType: options.socketType,
+		addr:       addr,
+		bind:       options.bind,
+		hwm:        options.hwm,
+		timeout:    options.timeout,
+		done:       make(chan struct{}),
+	}
+	if options.topic != "" {
+		if writer.topic, err = newFormatter(options.topic); err != nil {
+			return nil, err
+		}
+	}
+	if writer.bind {
+		writer.listener, err = net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		writer.running.Add(1)
+		go writer.accept()
+	} else {
+		pipe := writer.newPipe()
+		writer.pipes = []*zmqPipe{pipe}
+		writer.running.Add(1)
+		go writer.connect(pipe)
+	}
+	return writer, nil
+}
+
+func (writer *zmqWriter) newPipe() *zmqPipe {
+	return &zmqPipe{queue: make(chan [][]byte, writer.hwm), subscriptions: make(map[string]int)}
+}
+
+// accept accepts peers until the writer is closed.
+func (writer *zmqWriter) accept() {
+	defer writer.running.Done()
+	for {
+		conn, err := writer.listener.Accept()
+		if err != nil {
+			select {
+			case <-writer.done:
+				return
+			default:
+			}
+			reportInternalError(fmt.Errorf("ZeroMQ %s socket cannot accept on %s: %s", writer.socketType, writer.addr, err))
+			time.Sleep(zmqReconnectMin)
+			continue
+		}
+
+		writer.running.Add(1)
+		go func() {
+			defer writer.running.Done()
+			writer.serve(writer.newPipe(), conn)
+		}()
+	}
+}
+
+// addPipe adds the pipe of an accepted peer once it is connected. It returns false if
+// the writer is closed.
+func (writer *zmqWriter) addPipe(pipe *zmqPipe) bool {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return false
+	}
+	writer.pipes = append(writer.pipes, pipe)
+	return true
+}
+
+func (writer *zmqWriter) removePipe(pipe *zmqPipe) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	for i, other := range writer.pipes {
+		if other == pipe {
+			writer.pipes = append(writer.pipes[:i], writer.pipes[i+1:]...)
+			return
+		}
+	}
+}
+
+// connect connects to the endpoint, and again with backoff when the connection fails,
+// until the writer is closed.
+func (writer *zmqWriter) connect(pipe *zmqPipe) {
+	defer writer.running.Done()
+	backoff := zmqReconnectMin
+	for {
+		conn, err := net.DialTimeout("tcp", writer.addr, writer.timeout)
+		if err == nil {
+			backoff = zmqReconnectMin
+			if writer.serve(pipe, conn) {
+				return
+			}
+		}
+		select {
+		case <-writer.done:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > zmqReconnectMax {
+			backoff = zmqReconnectMax
+		}
+	}
+}
+
+// serve runs the handshake on a connection and sends the messages of the pipe until the
+// connection fails or the writer is closed. Pipes of accepted peers are added to the
+// writer for that time. It returns whether the writer is closed and the pipe done.
+func (writer *zmqWriter) serve(pipe *zmqPipe, conn net.Conn) bool {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(writer.timeout))
+	if err := writer.handshake(conn, reader); err != nil {
+		reportInternalError(fmt.Errorf("ZeroMQ %s socket cannot connect with %s: %s", writer.socketType,
+			conn.RemoteAddr(), err))
+		return false
+	}
+	conn.SetDeadline(time.Time{})
+
+	if writer.bind {
+		if !writer.addPipe(pipe) {
+			return true
+		}
+		defer writer.removePipe(pipe)
+	}
+	pipe.mutex.Lock()
+	pipe.connected = true
+	pipe.subscriptions = make(map[string]int)
+	pipe.mutex.Unlock()
+	defer func() {
+		pipe.mutex.Lock()
+		pipe.connected = false
+		pipe.mutex.Unlock()
+	}()
+
+	failed := make(chan struct{})
+	go func() {
+		// Reads subscriptions, and notices peers going away
+		writer.readPeer(pipe, reader)
+		close(failed)
+	}()
+
+	for {
+		select {
+		case frames := <-pipe.queue:
+			if err := writeZmqMessage(conn, frames); err != nil {
+				return false
+			}
+		case <-failed:
+			return false
+		case <-writer.done:
+			// Lingers: sends what is queued, which Close waits for up to timeout
+			for {
+				select {
+				case frames := <-pipe.queue:
+					if err := writeZmqMessage(conn, frames); err != nil {
+						return true
+					}
+				default:
+					return true
+				}
+			}
+		}
+	}
+}
+
+func (writer *zmqWriter) handshake(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := conn.Write(zmqGreeting()); err != nil {
+		return err
+	}
+	if err := readZmqGreeting(reader); err != nil {
+		return err
+	}
+	if err := writeZmqFrame(conn, zmqFlagCommand, zmqReadyCommand(writer.socketType)); err != nil {
+		return err
+	}
+	flags, body, err := readZmqFrame(reader)
+	if err != nil {
+		return err
+	}
+	peerType, err := parseZmqReady(flags, body)
+	if err != nil {
+		return err
+	}
+	for _, expected := range zmqPeerTypes[writer.socketType] {
+		if peerType == expected {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s socket can not talk to a %s socket", writer.socketType, peerType)
+}
+
+// readPeer reads from a peer until the connection fails. PUB sockets keep the
+// subscriptions, sent as SUBSCRIBE and CANCEL commands (ZMTP 3.1) or as messages
+// starting with 1 and 0 (ZMTP 3.0).
+func (writer *zmqWriter) readPeer(pipe *zmqPipe, reader *bufio.Reader) {
+	for {
+		flags, body, err := readZmqFrame(reader)
+		if err != nil || writer.socketType != zmqPub {
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		var subscribe bool
+		var prefix string
+		switch {
+		case flags&zmqFlagCommand != 0:
+			name, data := parseZmqCommand(body)
+			if name != "SUBSCRIBE" && name != "CANCEL" {
+				continue
+			}
+			subscribe, prefix = name == "SUBSCRIBE", string(data)
+		case len(body) > 0 && body[0] <= 1:
+			subscribe, prefix = body[0] == 1, string(body[1:])
+		default:
+			continue
+		}
+
+		pipe.mutex.Lock()
+		if subscribe {
+			pipe.subscriptions[prefix]++
+		} else if pipe.subscriptions[prefix] > 1 {
+			pipe.subscriptions[prefix]--
+		} else {
+			delete(pipe.subscriptions, prefix)
+		}
+		pipe.mutex.Unlock()
+	}
+}
+
+// subscribed reports whether the peer of the pipe is connected and subscribed to a
+// message starting with key.
+func (pipe *zmqPipe) subscribed(key []byte) bool {
+	pipe.mutex.Lock()
+	defer pipe.mutex.Unlock()
+
+	if !pipe.connected {
+		return false
+	}
+	for prefix := range pipe.subscriptions {
+		if bytes.HasPrefix(key, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (writer *zmqWriter) Dispatch(
+	message string,
+	level LogLevel,
+	context logContextInterface,
+	errorFunc func(err error)) {
+	if _, isEvent := eventName(context); isEvent {
+		return
+	}
+
+	var frames [][]byte
+	if writer.topic != nil {
+		frames = append(frames, []byte(writer.topic.formatOwn(message, level, context)))
+	}
+	frames = append(frames, []byte(strings.TrimRight(writer.formatter.Format(message, level, context), "\r\n")))
+
+	writer.mutex.Lock()
+	if writer.closed {
+		writer.mutex.Unlock()
+		errorFunc(errors.New("ZeroMQ writer is closed"))
+		return
+	}
+	if writer.socketType == zmqPub {
+		for _, pipe := range writer.pipes {
+			if !pipe.subscribed(frames[0]) {
+				continue
+			}
+			select {
+			case pipe.queue <- frames:
+			default:
+				atomic.AddUint64(&writer.dropped, 1)
+			}
+		}
+		writer.mutex.Unlock()
+		return
+	}
+
+	if len(writer.pipes) == 0 {
+		writer.mutex.Unlock()
+		atomic.AddUint64(&writer.dropped, 1)
+		errorFunc(fmt.Errorf("ZeroMQ PUSH socket on %s has no peers", writer.addr))
+		return
+	}
+	pipe := writer.pipes[writer.next%len(writer.pipes)]
+	writer.next++
+	writer.mutex.Unlock()
+
+	select {
+	case pipe.queue <- frames:
+		return
+	default:
+	}
+	timer := time.NewTimer(writer.timeout)
+	defer timer.Stop()
+	select {
+	case pipe.queue <- frames:
+	case <-writer.done:
+		errorFunc(errors.New("ZeroMQ writer is closed"))
+	case <-timer.C:
+		atomic.AddUint64(&writer.dropped, 1)
+		errorFunc(fmt.Errorf("ZeroMQ PUSH socket on %s reached its high water mark of %d messages", writer.addr, writer.hwm))
+	}
+}
+
+func (writer *zmqWriter) Flush() {
+}
+
+// Close stops accepting messages and lets the connections send what is queued for up to
+// timeout.
+func (writer *zmqWriter) Close() error {
+	writer.mutex.Lock()
+	if writer.closed {
+		writer.mutex.Unlock()
+		return nil
+	}
+	writer.closed = true
+	close(writer.done)
+	if writer.listener != nil {
+		writer.listener.Close()
+	}
+	writer.mutex.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		writer.running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(writer.timeout):
+	}
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	unsent := 0
+	for _, pipe := range writer.pipes {
+		unsent += len(pipe.queue)
+	}
+	if unsent > 0 {
+		return fmt.Errorf("ZeroMQ %s socket on %s closed with %d messages not sent", writer.socketType, writer.addr, unsent)
+	}
+	return nil
+}
+
+func (writer *zmqWriter) String() string {
+	mode := "connect"
+	if writer.bind {
+		mode = "bind"
+	}
+	return fmt.Sprintf("zmqWriter: [%s, %s %s, hwm %d, dropped %d], format: %s\n", writer.socketType, mode,
+		writer.addr, writer.hwm, atomic.LoadUint64(&writer.dropped), writer.formatter)
+}
+
+// zmqGreeting returns the ZMTP 3.0 greeting of a client with the NULL mechanism.
+func zmqGreeting() []byte {
+	greeting := make([]byte, 64)
+	greeting[0], greeting[9] = 0xff, 0x7f
+	greeting[10], greeting[11] = 3, 0
+	copy(greeting[12:32], "NULL")
+	return greeting
+}
+
+func readZmqGreeting(reader io.Reader) error {
+	greeting := make([]byte, 64)
+	if _, err := io.ReadFull(reader, greeting); err != nil {
+		return err
+	}
+	if greeting[0] != 0xff || greeting[9] != 0x7f {
+		return errors.New("not a ZMTP peer")
+	}
+	if greeting[10] < 3 {
+		return fmt.Errorf("ZMTP %d.%d is not supported", greeting[10], greeting[11])
+	}
+	if mechanism := string(bytes.TrimRight(greeting[12:32], "\x00")); mechanism != "NULL" {
+		return errors.New("ZMTP mechanism " + mechanism + " is not supported")
+	}
+	return nil
+}
+
+// zmqReadyCommand returns the body of a READY command with the socket type.
+func zmqReadyCommand(socketType string) []byte {
+	body := append([]byte{5}, "READY"...)
+	body = append(body, byte(len("Socket-Type")))
+	body = append(body, "Socket-Type"...)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(socketType)))
+	return append(body, socketType...)
+}
+
+// parseZmqCommand returns the name and the data of a command.
+func parseZmqCommand(body []byte) (string, []byte) {
+	if len(body) == 0 || len(body) < 1+int(body[0]) {
+		return "", nil
+	}
+	return string(body[1 : 1+body[0]]), body[1+body[0]:]
+}
+
+// parseZmqReady returns the socket type of a READY command.
+func parseZmqReady(flags byte, body []byte) (string, error) {
+	name, properties := parseZmqCommand(body)
+	if flags&zmqFlagCommand == 0 || name != "READY" {
+		return "", errors.New("expected a READY command")
+	}
+	for len(properties) > 0 {
+		nameLength := int(properties[0])
+		if len(properties) < 1+nameLength+4 {
+			break
+		}
+		propertyName := string(properties[1 : 1+nameLength])
+		valueLength := int(binary.BigEndian.Uint32(properties[1+nameLength:]))
+		properties = properties[1+nameLength+4:]
+		if len(properties) < valueLength {
+			break
+		}
+		if strings.EqualFold(propertyName, "Socket-Type") {
+			return string(properties[:valueLength]), nil
+		}
+		properties = properties[valueLength:]
+	}
+	return "", errors.New("READY command without a socket type")
+}
+
+func writeZmqFrame(w io.Writer, flags byte, body []byte) error {
+	header := make([]byte, 0, 9)
+	if len(body) > 255 {
+		header = append(header, flags|zmqFlagLong)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(body)))
+	} else {
+		header = append(header, flags, byte(len(body)))
+	}
+	_, err := w.Write(append(header, body...))
+	return err
+}
+
+func writeZmqMessage(w io.Writer, frames [][]byte) error {
+	for i, frame := range frames {
+		var flags byte
+		if i < len(frames)-1 {
+			flags = zmqFlagMore
+		}
+		if err := writeZmqFrame(w, flags, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readZmqFrame(reader *bufio.Reader) (byte, []byte, error) {
+	flags, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&zmqFlagLong != 0 {
+		var long [8]byte
+		if _, err := io.ReadFull(reader, long[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(long[:])
+	} else {
+		short, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(short)
+	}
+	if size > zmqMaxFrame {
+		return 0, nil, fmt.Errorf("ZMTP frame of %d bytes is too large", size)
+	}
+	body := make([]byte, size)
+	_, err = io.ReadFull(reader, body)
+	return flags, body, err
+}
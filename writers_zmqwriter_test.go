@@ -0,0 +1,182 @@
+// Copyright (c) 2012 - Cloud Instruments Co., Ltd.
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package seelog
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// zmqTestPeer runs the handshake of a socket of socketType on conn.
+func zmqTestPeer(t *testing.T, conn net.Conn, socketType string) *bufio.Reader {
+	reader := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write(zmqGreeting())
+	if err := readZmqGreeting(reader); err != nil {
+		t.Fatal(err)
+	}
+	writeZmqFrame(conn, zmqFlagCommand, zmqReadyCommand(socketType))
+	flags, body, err := readZmqFrame(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseZmqReady(flags, body); err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+// readZmqTestMessage reads a message and returns its frames joined with '|'.
+func readZmqTestMessage(t *testing.T, reader *bufio.Reader) string {
+	var frames []string
+	for {
+		flags, body, err := readZmqFrame(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, string(body))
+		if flags&zmqFlagMore == 0 {
+			return strings.Join(frames, "|")
+		}
+	}
+}
+
+func TestZmqPushConnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	logger := newTestLogger(t, testLoggerConfig{outputs: `<zmq formatid="msg" endpoint="tcp://`+listener.Addr().String()+`"/>`})
+	// Queued until the connection is made
+	logger.Info("first")
+	logger.Info("second")
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := zmqTestPeer(t, conn, "PULL")
+	for _, expected := range []string{"first", "second"} {
+		if message := readZmqTestMessage(t, reader); message != expected {
+			t.Errorf("Expected %s, got %s", expected, message)
+		}
+	}
+
+	logger.Info(strings.Repeat("x", 300))
+	logger.Close()
+	if message := readZmqTestMessage(t, reader); len(message) != 300 {
+		t.Errorf("Expected a long frame of 300 bytes, got %d", len(message))
+	}
+}
+
+func TestZmqPubSubscriptions(t *testing.T) {
+	writer, err := newZmqWriter(mustNewMsgFormatter(t), "tcp://127.0.0.1:0", zmqWriterOptions{
+		socketType: "pub",
+		bind:       true,
+		topic:      "%Level",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	conn, err := net.Dial("tcp", writer.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := zmqTestPeer(t, conn, "SUB")
+	writeZmqFrame(conn, 0, append([]byte{1}, "Error"...))
+	writeZmqFrame(conn, zmqFlagCommand, append([]byte{9}, "SUBSCRIBECrit"...))
+
+	context, _ := currentContext()
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		writer.mutex.Lock()
+		subscribed := len(writer.pipes) == 1 && writer.pipes[0].subscribed([]byte("Critical"))
+		writer.mutex.Unlock()
+		if subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the subscriptions to arrive")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	writer.Dispatch("ignored", InfoLvl, context, func(err error) { t.Error(err) })
+	writer.Dispatch("failed", ErrorLvl, context, func(err error) { t.Error(err) })
+	writer.Dispatch("crashed", CriticalLvl, context, func(err error) { t.Error(err) })
+
+	for _, expected := range []string{"Error|failed", "Critical|crashed"} {
+		if message := readZmqTestMessage(t, reader); message != expected {
+			t.Errorf("Expected %s, got %s", expected, message)
+		}
+	}
+}
+
+func TestZmqPushHighWaterMark(t *testing.T) {
+	writer, err := newZmqWriter(mustNewMsgFormatter(t), "tcp://127.0.0.1:1", zmqWriterOptions{
+		hwm:     2,
+		timeout: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	context, _ := currentContext()
+	var errs []error
+	for i := 0; i < 3; i++ {
+		writer.Dispatch("message", InfoLvl, context, func(err error) { errs = append(errs, err) })
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "high water mark") {
+		t.Errorf("Expected the third message to be dropped, got %v", errs)
+	}
+	if err := writer.Close(); err == nil || !strings.Contains(err.Error(), "2 messages not sent") {
+		t.Errorf("Expected the queued messages to be reported, got %v", err)
+	}
+}
+
+func TestZmqConfig(t *testing.T) {
+	invalid := []string{
+		`<zmq/>`,
+		`<zmq endpoint="ipc:///tmp/logs"/>`,
+		`<zmq endpoint="tcp://collector.local"/>`,
+		`<zmq endpoint="tcp://collector.local:5558" type="req"/>`,
+		`<zmq endpoint="tcp://collector.local:5558" hwm="-1"/>`,
+		`<zmq endpoint="tcp://collector.local:5558" bind="maybe"/>`,
+		`<zmq endpoint="tcp://collector.local:5558" topic="%Unknown"/>`,
+	}
+	for _, zmq := range invalid {
+		config := `<seelog><outputs>` + zmq + `</outputs></seelog>`
+		if _, err := LoggerFromConfigAsString(config); err == nil {
+			t.Errorf("Expected error for config: %s", config)
+		}
+	}
+}